@@ -0,0 +1,282 @@
+package claude
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/metrics"
+)
+
+// MessageSubtypeApprovalRequired is the subtype of the system message sent on
+// a process's output channel when Claude wants to run a Bash command matching
+// one of the approval patterns. Approval describes the command. The turn
+// waits until ApproveToolUse or DenyToolUse is called, or the approval times out.
+const MessageSubtypeApprovalRequired = "approval_required"
+
+// ErrNoPendingApproval is returned when deciding on a tool call that isn't waiting for approval
+var ErrNoPendingApproval = errors.New("no approval pending for that tool call")
+
+// ApprovalRequest is a tool call waiting for a user to approve or deny it
+type ApprovalRequest struct {
+	ToolUseID string `json:"tool_use_id"`
+	ToolName  string `json:"tool_name"`
+	Command   string `json:"command"`
+}
+
+// permissionRequest is the can_use_tool control request the CLI sends when
+// started with --permission-prompt-tool stdio
+type permissionRequest struct {
+	RequestID string `json:"request_id"`
+	Request   struct {
+		Subtype   string          `json:"subtype"`
+		ToolName  string          `json:"tool_name"`
+		Input     json.RawMessage `json:"input"`
+		ToolUseID string          `json:"tool_use_id"`
+	} `json:"request"`
+}
+
+// controlResponse answers a control request from the CLI
+type controlResponse struct {
+	Subtype   string              `json:"subtype"`
+	RequestID string              `json:"request_id"`
+	Response  *permissionDecision `json:"response,omitempty"`
+	Error     string              `json:"error,omitempty"`
+}
+
+// permissionDecision is the answer to a can_use_tool request
+type permissionDecision struct {
+	Behavior     string          `json:"behavior"` // "allow" or "deny"
+	UpdatedInput json.RawMessage `json:"updatedInput,omitempty"`
+	Message      string          `json:"message,omitempty"`
+}
+
+// pendingApproval is a permission request held until a user decides on it
+type pendingApproval struct {
+	requestID string
+	input     json.RawMessage
+	timer     *time.Timer
+}
+
+// approvals are a process's tool calls waiting for a decision, by tool use ID
+type approvals struct {
+	mu      sync.Mutex
+	pending map[string]*pendingApproval
+}
+
+func (a *approvals) add(toolUseID string, approval *pendingApproval) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.pending == nil {
+		a.pending = make(map[string]*pendingApproval)
+	}
+	a.pending[toolUseID] = approval
+}
+
+// take removes and returns the approval pending for toolUseID
+func (a *approvals) take(toolUseID string) (*pendingApproval, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	approval, ok := a.pending[toolUseID]
+	if ok {
+		delete(a.pending, toolUseID)
+		if approval.timer != nil {
+			approval.timer.Stop()
+		}
+	}
+	return approval, ok
+}
+
+// waiting reports whether the turn is held on a user's decision
+func (a *approvals) waiting() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.pending) > 0
+}
+
+// compileApprovalPatterns compiles the configured patterns, skipping invalid ones
+func compileApprovalPatterns(patterns []string) []*regexp.Regexp {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			slog.Warn("Ignoring invalid approval pattern", "pattern", pattern, "error", err)
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled
+}
+
+// approvalArgs removes Bash from the allowed tools and has the CLI ask the
+// service about each Bash call instead, so calls matching an approval
+// pattern can be held. It returns allowed unchanged when Bash isn't allowed.
+func approvalArgs(allowed []string) ([]string, []string) {
+	if !slices.Contains(allowed, "Bash") {
+		return allowed, nil
+	}
+	allowed = slices.DeleteFunc(slices.Clone(allowed), func(tool string) bool { return tool == "Bash" })
+	return allowed, []string{"--permission-prompt-tool", "stdio"}
+}
+
+// needsApproval reports whether a Bash command matches an approval pattern
+func (s *Service) needsApproval(command string) bool {
+	for _, re := range s.approvalPatterns {
+		if re.MatchString(command) {
+			return true
+		}
+	}
+	return false
+}
+
+// handlePermissionRequest answers a tool permission request from the CLI.
+// Bash commands are allowed unless they match an approval pattern, in which
+// case the request is held and an approval_required message returned for the
+// caller to send on. Other tools weren't allowed, so they are denied as the
+// CLI would have without asking.
+func (s *Service) handlePermissionRequest(process *Process, line []byte) (Message, bool) {
+	var req permissionRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		slog.Error("Failed to parse Claude control request",
+			"correlation_id", process.correlationID,
+			"error", err,
+			"action", "control_request_parse_failed",
+		)
+		return Message{}, false
+	}
+	if req.Request.Subtype != "can_use_tool" {
+		go process.respond(controlResponse{Subtype: "error", RequestID: req.RequestID, Error: "unsupported control request " + req.Request.Subtype})
+		return Message{}, false
+	}
+
+	if req.Request.ToolName != "Bash" {
+		// Worded like the CLI's own denial so it's still reported as ErrToolDenied
+		go process.respond(denyResponse(req.RequestID, fmt.Sprintf("Claude requested permissions to use %s, but you haven't granted it yet.", req.Request.ToolName)))
+		return Message{}, false
+	}
+
+	var input struct {
+		Command string `json:"command"`
+	}
+	json.Unmarshal(req.Request.Input, &input)
+	if !s.needsApproval(input.Command) {
+		go process.respond(allowResponse(req.RequestID, req.Request.Input))
+		return Message{}, false
+	}
+
+	toolUseID := req.Request.ToolUseID
+	if toolUseID == "" {
+		toolUseID = req.RequestID
+	}
+	pending := &pendingApproval{requestID: req.RequestID, input: req.Request.Input}
+	if timeout := s.config.Approval.Timeout; timeout > 0 {
+		pending.timer = time.AfterFunc(timeout, func() {
+			if s.decide(process, toolUseID, false, "No one approved the command in time") == nil {
+				metrics.ClaudeApprovalsTotal.WithLabelValues("timeout").Inc()
+			}
+		})
+	}
+	process.approvals.add(toolUseID, pending)
+
+	approval := &ApprovalRequest{ToolUseID: toolUseID, ToolName: req.Request.ToolName, Command: input.Command}
+	slog.Info("Claude command waiting for approval",
+		"correlation_id", process.correlationID,
+		"session_id", process.FlowSessionID(),
+		"tool_use_id", toolUseID,
+		"command", input.Command,
+		"action", "approval_required",
+	)
+	events.Publish(s.events, events.TopicApprovalRequired, events.ApprovalRequired{
+		SessionID: process.FlowSessionID(),
+		UserID:    process.UserID(),
+		ToolUseID: toolUseID,
+		ToolName:  approval.ToolName,
+		Command:   approval.Command,
+	})
+	return Message{
+		Type:      "system",
+		Subtype:   MessageSubtypeApprovalRequired,
+		SessionID: process.sessionID,
+		Approval:  approval,
+	}, true
+}
+
+// ApproveToolUse lets a tool call held for approval run
+func (s *Service) ApproveToolUse(sessionID, toolUseID string) error {
+	return s.decideToolUse(sessionID, toolUseID, true)
+}
+
+// DenyToolUse refuses a tool call held for approval; Claude is told the user denied it
+func (s *Service) DenyToolUse(sessionID, toolUseID string) error {
+	return s.decideToolUse(sessionID, toolUseID, false)
+}
+
+func (s *Service) decideToolUse(sessionID, toolUseID string, approved bool) error {
+	process, exists := s.getProcess(sessionID)
+	if !exists {
+		return fmt.Errorf("session %s: %w", sessionID, ErrSessionNotRunning)
+	}
+	if err := s.decide(process.current(), toolUseID, approved, "The user denied this command"); err != nil {
+		return err
+	}
+
+	decision := "denied"
+	if approved {
+		decision = "approved"
+	}
+	metrics.ClaudeApprovalsTotal.WithLabelValues(decision).Inc()
+	slog.Info("Claude command "+decision,
+		"correlation_id", process.correlationID,
+		"session_id", sessionID,
+		"tool_use_id", toolUseID,
+		"action", "approval_"+decision,
+	)
+	return nil
+}
+
+// decide answers a held permission request; reason is what Claude is told when it's denied
+func (s *Service) decide(process *Process, toolUseID string, approved bool, reason string) error {
+	approval, ok := process.approvals.take(toolUseID)
+	if !ok {
+		return fmt.Errorf("tool use %s: %w", toolUseID, ErrNoPendingApproval)
+	}
+	process.heartbeat() // The wait for a decision isn't a hung turn
+	if approved {
+		return process.respond(allowResponse(approval.requestID, approval.input))
+	}
+	return process.respond(denyResponse(approval.requestID, reason))
+}
+
+func allowResponse(requestID string, input json.RawMessage) controlResponse {
+	return controlResponse{
+		Subtype:   "success",
+		RequestID: requestID,
+		Response:  &permissionDecision{Behavior: "allow", UpdatedInput: input},
+	}
+}
+
+func denyResponse(requestID, message string) controlResponse {
+	return controlResponse{
+		Subtype:   "success",
+		RequestID: requestID,
+		Response:  &permissionDecision{Behavior: "deny", Message: message},
+	}
+}
+
+// respond writes a control response to the CLI's stdin
+func (p *Process) respond(response controlResponse) error {
+	select {
+	case p.controlChan <- controlRequest{Type: "control_response", response: &response}:
+		return nil
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("timeout sending control response")
+	case <-p.ctx.Done():
+		return fmt.Errorf("session cancelled")
+	}
+}
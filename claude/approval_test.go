@@ -0,0 +1,126 @@
+package claude
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newApprovalService(timeout time.Duration) *Service {
+	return NewService(Config{Approval: config.ClaudeApprovalConfig{
+		Patterns: []string{`\brm\s+-rf\b`, `git\s+push\s+--force`},
+		Timeout:  timeout,
+	}})
+}
+
+func permissionLine(toolUseID, tool, command string) []byte {
+	input, _ := json.Marshal(map[string]string{"command": command})
+	line, _ := json.Marshal(map[string]interface{}{
+		"type":       "control_request",
+		"request_id": "req-" + toolUseID,
+		"request": map[string]interface{}{
+			"subtype":     "can_use_tool",
+			"tool_name":   tool,
+			"input":       json.RawMessage(input),
+			"tool_use_id": toolUseID,
+		},
+	})
+	return line
+}
+
+// nextResponse returns the control response the process wrote for the CLI
+func nextResponse(t *testing.T, process *Process) controlResponse {
+	select {
+	case request := <-process.controlChan:
+		require.NotNil(t, request.response)
+		return *request.response
+	case <-time.After(time.Second):
+		t.Fatal("no control response sent")
+		return controlResponse{}
+	}
+}
+
+func TestApprovalsRouteBashThroughPermissionPrompt(t *testing.T) {
+	config := Config{Tools: []string{"Read", "Bash"}, Approval: config.ClaudeApprovalConfig{Patterns: []string{"rm -rf"}}}
+	assert.Equal(t, []string{"--allowedTools", "Read", "--permission-prompt-tool", "stdio"},
+		newSessionOptions(nil).cliArgs(config, nil))
+	assert.Equal(t, []string{"--allowedTools", "Read"},
+		newSessionOptions([]SessionOption{WithAllowedTools("Read")}).cliArgs(config, nil))
+	assert.Equal(t, []string{"Read", "Bash"}, config.Tools)
+}
+
+func TestPermissionRequestAllowsSafeCommands(t *testing.T) {
+	s := newApprovalService(time.Minute)
+	process := newIdleProcess("cli-1")
+
+	_, held := s.handlePermissionRequest(process, permissionLine("tool-1", "Bash", "go test ./..."))
+	assert.False(t, held)
+	response := nextResponse(t, process)
+	assert.Equal(t, "req-tool-1", response.RequestID)
+	assert.Equal(t, "allow", response.Response.Behavior)
+	assert.JSONEq(t, `{"command":"go test ./..."}`, string(response.Response.UpdatedInput))
+
+	_, held = s.handlePermissionRequest(process, permissionLine("tool-2", "WebFetch", ""))
+	assert.False(t, held)
+	assert.Equal(t, "deny", nextResponse(t, process).Response.Behavior)
+}
+
+func TestApproveToolUse(t *testing.T) {
+	s := newApprovalService(time.Minute)
+	process := newIdleProcess("cli-1")
+	s.sessions[process.sessionID] = process
+
+	msg, held := s.handlePermissionRequest(process, permissionLine("tool-1", "Bash", "rm -rf build"))
+	require.True(t, held)
+	assert.Equal(t, MessageSubtypeApprovalRequired, msg.Subtype)
+	assert.Equal(t, &ApprovalRequest{ToolUseID: "tool-1", ToolName: "Bash", Command: "rm -rf build"}, msg.Approval)
+	assert.True(t, process.approvals.waiting())
+	assert.Empty(t, process.controlChan)
+
+	require.NoError(t, s.ApproveToolUse("cli-1", "tool-1"))
+	response := nextResponse(t, process)
+	assert.Equal(t, "req-tool-1", response.RequestID)
+	assert.Equal(t, "allow", response.Response.Behavior)
+	assert.False(t, process.approvals.waiting())
+
+	assert.ErrorIs(t, s.DenyToolUse("cli-1", "tool-1"), ErrNoPendingApproval)
+	assert.ErrorIs(t, s.ApproveToolUse("cli-2", "tool-1"), ErrSessionNotRunning)
+}
+
+func TestDenyToolUse(t *testing.T) {
+	s := newApprovalService(time.Minute)
+	process := newIdleProcess("cli-1")
+	s.sessions[process.sessionID] = process
+
+	_, held := s.handlePermissionRequest(process, permissionLine("tool-1", "Bash", "git push --force origin main"))
+	require.True(t, held)
+	require.NoError(t, s.DenyToolUse("cli-1", "tool-1"))
+	response := nextResponse(t, process)
+	assert.Equal(t, "deny", response.Response.Behavior)
+	assert.NotEmpty(t, response.Response.Message)
+}
+
+func TestUnansweredApprovalIsDenied(t *testing.T) {
+	s := newApprovalService(10 * time.Millisecond)
+	process := newIdleProcess("cli-1")
+
+	_, held := s.handlePermissionRequest(process, permissionLine("tool-1", "Bash", "rm -rf /"))
+	require.True(t, held)
+	assert.Equal(t, "deny", nextResponse(t, process).Response.Behavior)
+	assert.False(t, process.approvals.waiting())
+}
+
+func TestControlResponseWireFormat(t *testing.T) {
+	request := controlRequest{Type: "control_response", response: &controlResponse{
+		Subtype:   "success",
+		RequestID: "req-1",
+		Response:  &permissionDecision{Behavior: "deny", Message: "no"},
+	}}
+	m, err := json.Marshal(request.wire())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"control_response","response":{"subtype":"success","request_id":"req-1","response":{"behavior":"deny","message":"no"}}}`, string(m))
+}
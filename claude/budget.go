@@ -0,0 +1,202 @@
+package claude
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/metrics"
+	"github.com/google/uuid"
+)
+
+// MessageSubtypeBudgetExceeded is the subtype of the system message sent on a
+// process's output channel after the turn that used up the session's budget.
+// Text describes the limit reached, e.g. "the 50 turn limit". The session
+// takes no more prompts until ContinueSession is called.
+const MessageSubtypeBudgetExceeded = "budget_exceeded"
+
+// ErrBudgetExceeded is returned when sending to a session paused by its budget
+var ErrBudgetExceeded = errors.New("session budget exceeded")
+
+// Budget limits, used as metric labels
+const (
+	budgetTokens   = "tokens"
+	budgetTurns    = "turns"
+	budgetDuration = "duration"
+)
+
+// sessionBudget is a session's spend against its budget. The supervisor hands
+// it to the process that replaces a restarted one.
+type sessionBudget struct {
+	mu          sync.Mutex
+	limits      config.ClaudeBudgetConfig
+	started     time.Time
+	tokens      int64
+	turns       int
+	paused      string // Limit the session reached; empty while it may take prompts
+	interrupted bool   // Set once the turn running past the time limit has been interrupted
+}
+
+func newSessionBudget(limits config.ClaudeBudgetConfig) *sessionBudget {
+	return &sessionBudget{limits: limits, started: time.Now()}
+}
+
+// exceeded returns the limit the spend has reached, if any; the caller holds mu
+func (b *sessionBudget) exceeded() string {
+	switch {
+	case b.limits.MaxTokens > 0 && b.tokens >= b.limits.MaxTokens:
+		return budgetTokens
+	case b.limits.MaxTurns > 0 && b.turns >= b.limits.MaxTurns:
+		return budgetTurns
+	case b.limits.MaxDuration > 0 && time.Since(b.started) >= b.limits.MaxDuration:
+		return budgetDuration
+	}
+	return ""
+}
+
+// describe explains a limit to the user, e.g. "the 50 turn limit"
+func (b *sessionBudget) describe(limit string) string {
+	switch limit {
+	case budgetTokens:
+		return fmt.Sprintf("the %d token limit", b.limits.MaxTokens)
+	case budgetTurns:
+		return fmt.Sprintf("the %d turn limit", b.limits.MaxTurns)
+	case budgetDuration:
+		return fmt.Sprintf("the %s time limit", b.limits.MaxDuration)
+	}
+	return "its budget"
+}
+
+// spend adds a finished turn and pauses the session if that used up the
+// budget. It returns the limit reached when it pauses.
+func (b *sessionBudget) spend(result Message) string {
+	if b == nil {
+		return ""
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.turns++
+	if result.Usage != nil {
+		b.tokens += result.Usage.InputTokens + result.Usage.OutputTokens + result.Usage.CacheCreationInputTokens
+	}
+	if b.paused != "" {
+		return ""
+	}
+	b.paused = b.exceeded()
+	return b.paused
+}
+
+// interruptDue reports whether the turn in progress has run past the time
+// limit; it reports true only once per budget
+func (b *sessionBudget) interruptDue() bool {
+	if b == nil || b.limits.MaxDuration <= 0 {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.interrupted || time.Since(b.started) < b.limits.MaxDuration {
+		return false
+	}
+	b.interrupted = true
+	return true
+}
+
+// pausedBy describes the limit that paused the session, or returns "" if it isn't paused
+func (b *sessionBudget) pausedBy() string {
+	if b == nil {
+		return ""
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.paused == "" {
+		return ""
+	}
+	return b.describe(b.paused)
+}
+
+// reset starts a fresh budget, reporting whether the session was paused
+func (b *sessionBudget) reset() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.paused == "" {
+		return false
+	}
+	b.started = time.Now()
+	b.tokens = 0
+	b.turns = 0
+	b.paused = ""
+	b.interrupted = false
+	return true
+}
+
+// enforceTimeLimit interrupts a turn that has run past the session's time
+// limit; its result message then pauses the session
+func (s *Service) enforceTimeLimit(process *Process) {
+	if !process.inTurn.Load() || !process.budget.interruptDue() {
+		return
+	}
+
+	request := controlRequest{
+		Type:      "control_request",
+		RequestID: uuid.NewString(),
+	}
+	request.Request.Subtype = "interrupt"
+
+	// This runs on the stdout goroutine, which must not wait on stdin
+	select {
+	case process.controlChan <- request:
+		slog.Info("Interrupting Claude turn past the session time limit",
+			"correlation_id", process.correlationID,
+			"session_id", process.FlowSessionID(),
+			"action", "budget_interrupt",
+		)
+	default:
+	}
+}
+
+// spendBudget counts a finished turn against the session's budget and
+// returns the message announcing the pause if the turn used it up
+func (s *Service) spendBudget(process *Process, result Message) (Message, bool) {
+	limit := process.budget.spend(result)
+	if limit == "" {
+		return Message{}, false
+	}
+
+	metrics.ClaudeBudgetsExceededTotal.WithLabelValues(limit).Inc()
+	slog.Warn("Claude session reached its budget",
+		"correlation_id", process.correlationID,
+		"session_id", process.FlowSessionID(),
+		"limit", limit,
+		"action", "budget_exceeded",
+	)
+	return Message{
+		Type:      "system",
+		Subtype:   MessageSubtypeBudgetExceeded,
+		SessionID: result.SessionID,
+		Text:      process.budget.pausedBy(),
+	}, true
+}
+
+// ContinueSession gives a session paused by its budget a fresh budget so it
+// takes prompts again. It does nothing if the session isn't paused.
+func (s *Service) ContinueSession(sessionID string) error {
+	process, exists := s.getProcess(sessionID)
+	if !exists {
+		return fmt.Errorf("session %s: %w", sessionID, ErrSessionNotRunning)
+	}
+	if process.current().budget.reset() {
+		slog.Info("Continuing Claude session with a fresh budget",
+			"correlation_id", process.correlationID,
+			"session_id", sessionID,
+			"action", "budget_continue",
+		)
+	}
+	return nil
+}
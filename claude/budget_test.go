@@ -0,0 +1,70 @@
+package claude
+
+import (
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudgetPausesSessionAtTurnLimit(t *testing.T) {
+	s := NewService(Config{})
+	process := newIdleProcess("cli-1")
+	process.budget = newSessionBudget(config.ClaudeBudgetConfig{MaxTurns: 2})
+	s.sessions[process.sessionID] = process
+
+	_, paused := s.spendBudget(process, Message{Type: "result"})
+	assert.False(t, paused)
+	msg, paused := s.spendBudget(process, Message{Type: "result", SessionID: "cli-1"})
+	require.True(t, paused)
+	assert.Equal(t, MessageSubtypeBudgetExceeded, msg.Subtype)
+	assert.Equal(t, "the 2 turn limit", msg.Text)
+
+	err := s.SendMessage(process, "keep going")
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+	assert.Empty(t, process.inputChan)
+
+	require.NoError(t, s.ContinueSession("cli-1"))
+	require.NoError(t, s.SendMessage(process, "keep going"))
+	assert.Len(t, process.inputChan, 1)
+}
+
+func TestBudgetCountsTokens(t *testing.T) {
+	budget := newSessionBudget(config.ClaudeBudgetConfig{MaxTokens: 1000})
+	assert.Empty(t, budget.spend(Message{Type: "result", Usage: &Usage{InputTokens: 300, OutputTokens: 200, CacheReadInputTokens: 5000}}))
+	assert.Equal(t, budgetTokens, budget.spend(Message{Type: "result", Usage: &Usage{InputTokens: 400, OutputTokens: 100}}))
+	assert.Equal(t, "the 1000 token limit", budget.pausedBy())
+}
+
+func TestBudgetInterruptsTurnPastTimeLimit(t *testing.T) {
+	s := NewService(Config{})
+	process := newIdleProcess("cli-1")
+	process.budget = newSessionBudget(config.ClaudeBudgetConfig{MaxDuration: time.Minute})
+	process.inTurn.Store(true)
+
+	s.enforceTimeLimit(process)
+	assert.Empty(t, process.controlChan)
+
+	process.budget.started = time.Now().Add(-2 * time.Minute)
+	s.enforceTimeLimit(process)
+	request := <-process.controlChan
+	assert.Equal(t, "interrupt", request.Request.Subtype)
+
+	// Only one interrupt per budget
+	s.enforceTimeLimit(process)
+	assert.Empty(t, process.controlChan)
+
+	_, paused := s.spendBudget(process, Message{Type: "result"})
+	assert.True(t, paused)
+}
+
+func TestContinueSessionWithoutPause(t *testing.T) {
+	s := NewService(Config{})
+	process := newIdleProcess("cli-1")
+	s.sessions[process.sessionID] = process
+
+	assert.NoError(t, s.ContinueSession("cli-1"))
+	assert.ErrorIs(t, s.ContinueSession("missing"), ErrSessionNotRunning)
+}
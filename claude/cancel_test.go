@@ -0,0 +1,51 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIdleProcess(sessionID string) *Process {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Process{
+		sessionID:   sessionID,
+		ctx:         ctx,
+		cancel:      cancel,
+		inputChan:   make(chan Input, 10),
+		controlChan: make(chan controlRequest, 1),
+	}
+}
+
+func TestCancelTurnSendsInterrupt(t *testing.T) {
+	s := NewService(Config{})
+	process := newIdleProcess("cli-1")
+	flowID := "flow-1"
+	process.flowSessionID.Store(&flowID)
+	s.sessions[process.sessionID] = process
+
+	require.NoError(t, s.SendMessage(process, "write a long essay"))
+	<-process.inputChan
+
+	require.NoError(t, s.CancelTurn(flowID))
+	request := <-process.controlChan
+	assert.Equal(t, "control_request", request.Type)
+	assert.Equal(t, "interrupt", request.Request.Subtype)
+	assert.NotEmpty(t, request.RequestID)
+
+	data, err := json.Marshal(request)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"control_request","request_id":"`+request.RequestID+`","request":{"subtype":"interrupt"}}`, string(data))
+}
+
+func TestCancelTurnWithoutActiveTurn(t *testing.T) {
+	s := NewService(Config{})
+	process := newIdleProcess("cli-1")
+	s.sessions[process.sessionID] = process
+
+	assert.ErrorIs(t, s.CancelTurn("cli-1"), ErrNoActiveTurn)
+	assert.ErrorIs(t, s.CancelTurn("missing"), ErrSessionNotRunning)
+}
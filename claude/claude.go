@@ -4,26 +4,40 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/breadchris/flow/config"
 	"github.com/breadchris/flow/deps"
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/metrics"
 	"github.com/breadchris/flow/models"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type Config struct {
-	Debug    bool
-	DebugDir string
-	Tools    []string
+	Debug         bool
+	DebugDir      string
+	Tools         []string
+	Model         string                       // Passed as --model; empty uses the CLI's default
+	FallbackModel string                       // Model a rate-limited turn is retried on; empty disables fallback
+	Scheduler     config.ClaudeSchedulerConfig // Process limits; zero values are unlimited
+	Sandbox       config.ClaudeSandboxConfig   // Resource limits and isolation for the CLI; the zero value runs it directly
+	Budget        config.ClaudeBudgetConfig    // Default session budget; zero values are unlimited
+	Approval      config.ClaudeApprovalConfig  // Bash commands held for a user's approval; no patterns disables approvals
 }
 
 // ClaudeMDConfig represents a CLAUDE.md configuration
@@ -57,10 +71,17 @@ type ClaudeMDUpdateRequest struct {
 	Content     *string `json:"content"`
 }
 
+
 type Service struct {
-	config   Config
-	sessions map[string]*Process
-	mu       sync.RWMutex
+	config      Config
+	sessions    map[string]*Process
+	mu          sync.RWMutex
+	events      *events.Bus      // Receives session.message events; may be nil
+	usage       *UsageTracker    // Records each turn's token usage; may be nil
+	transcripts *TranscriptStore // Saves each turn's prompt and replies; may be nil
+	scheduler   *Scheduler       // Limits how many processes run at once
+
+	approvalPatterns []*regexp.Regexp // Compiled Config.Approval.Patterns
 }
 
 // ClaudeService provides database-integrated Claude session management
@@ -70,6 +91,14 @@ type ClaudeService struct {
 	db         *gorm.DB
 	config     Config
 	debug      bool
+	events     *events.Bus
+	pool       *Pool                      // Pre-warmed processes for new sessions; nil when disabled
+	jobs       *Jobs                      // Background one-shot tasks
+	janitor    *janitor                   // Keeps session directories within their disk quotas
+	output     map[string]*OutputPipeline // Post-processors by output consumer
+	supervisor config.ClaudeSupervisorConfig
+	personas   map[string]string // System prompts by persona name
+	turns      sync.Map          // Flow session ID to the *sync.Mutex held while one of its turns runs
 }
 
 // SessionInfo represents session metadata stored in database
@@ -84,6 +113,7 @@ type SessionInfo struct {
 	ProcessExists bool
 }
 
+
 type Process struct {
 	sessionID     string
 	cmd           *exec.Cmd
@@ -100,12 +130,71 @@ type Process struct {
 	stdinLogFile  *os.File
 	stdoutLogFile *os.File
 	stderrLogFile *os.File
-	isHealthy     bool
-	lastHeartbeat time.Time
-	inputChan     chan Input   // Channel for sending messages to Claude
-	outputChan    chan Message // Channel for receiving messages from Claude
-	initComplete  chan bool    // Signal when initialization is complete
-	errorChan     chan Message // Channel for forwarding stderr errors
+	isHealthy     atomic.Bool
+	lastHeartbeat atomic.Int64            // Unix nanoseconds of the last sign of life, see heartbeat
+	inputChan     chan Input              // Channel for sending messages to Claude
+	outputChan    chan Message            // Channel for receiving messages from Claude
+	initComplete  chan bool               // Signal when initialization is complete
+	errorChan     chan *SessionError      // Failures reported by the CLI; never closed
+	flowSessionID atomic.Pointer[string]  // Flow session this process serves, for events
+	userID        atomic.Pointer[string]  // User the flow session belongs to, for usage accounting
+	controlChan   chan controlRequest     // Channel for protocol control requests such as interrupts
+	inTurn        atomic.Bool             // Set from a prompt until its result message
+	exited        atomic.Bool             // Set once stdout closes, which means the CLI has exited
+	replacedBy    atomic.Pointer[Process] // Process resumed by the supervisor in place of this one
+	transcript    transcriptBuffer        // Messages of the turn in progress, saved when it ends
+	slot          atomic.Pointer[func()]  // Releases the process's scheduler slot; nil when it holds none
+	tools         toolHooks               // Callbacks registered with OnToolUse
+	model         atomic.Pointer[string]  // Model the process was started with or fell back to
+	lastPrompt    atomic.Pointer[Input]   // Most recent prompt, resent if its turn falls back to another model
+	replay        atomic.Pointer[string]  // Transcript summary sent ahead of the first prompt of a replayed session
+	replayed      atomic.Bool             // Started from a transcript summary because --resume failed
+	summarized    atomic.Bool             // Started from the session's summary because the session grew long
+	budget        *sessionBudget          // Spend against the session's budget
+	approvals     approvals               // Bash commands waiting for a user to approve them
+	dirs          []Directory             // Directories the session works in, with resolved paths
+}
+
+// heartbeat records that the process has shown a sign of life
+func (p *Process) heartbeat() {
+	p.lastHeartbeat.Store(time.Now().UnixNano())
+}
+
+// sinceHeartbeat returns how long ago the process last showed a sign of life
+func (p *Process) sinceHeartbeat() time.Duration {
+	return time.Since(time.Unix(0, p.lastHeartbeat.Load()))
+}
+
+// current follows supervisor restarts to the process now serving this one's session
+func (p *Process) current() *Process {
+	for next := p.replacedBy.Load(); next != nil; next = p.replacedBy.Load() {
+		p = next
+	}
+	return p
+}
+
+// holdSlot makes the process responsible for releasing a scheduler slot when it stops
+func (p *Process) holdSlot(release func()) {
+	p.slot.Store(&release)
+	// The process may have been stopped before it was handed the slot
+	if p.ctx.Err() != nil {
+		p.releaseSlot()
+	}
+}
+
+// takeSlot removes the process's scheduler slot without releasing it
+func (p *Process) takeSlot() func() {
+	if release := p.slot.Swap(nil); release != nil {
+		return *release
+	}
+	return nil
+}
+
+// releaseSlot frees the process's scheduler slot, if it holds one
+func (p *Process) releaseSlot() {
+	if release := p.takeSlot(); release != nil {
+		release()
+	}
 }
 
 // GetCorrelationID returns the correlation ID for this process
@@ -113,6 +202,38 @@ func (p *Process) GetCorrelationID() string {
 	return p.correlationID
 }
 
+// FlowSessionID returns the flow session ID the process was started for,
+// falling back to the Claude CLI session ID
+func (p *Process) FlowSessionID() string {
+	if id := p.flowSessionID.Load(); id != nil {
+		return *id
+	}
+	return p.sessionID
+}
+
+// UserID returns the user the process was started for, if known
+func (p *Process) UserID() string {
+	if id := p.userID.Load(); id != nil {
+		return *id
+	}
+	return ""
+}
+
+// SessionDir returns the directory the process works in, where Claude writes its files
+func (p *Process) SessionDir() string {
+	p = p.current()
+	if len(p.dirs) == 0 {
+		return ""
+	}
+	return p.dirs[0].Path
+}
+
+// Exited reports whether the process's Claude CLI has exited, after which it
+// can't take more messages
+func (p *Process) Exited() bool {
+	return p.current().exited.Load()
+}
+
 // Message represents a message from Claude CLI
 type Message struct {
 	Type      string          `json:"type"`
@@ -122,8 +243,46 @@ type Message struct {
 	ParentID  string          `json:"parent_tool_use_id,omitempty"`
 	Result    string          `json:"result,omitempty"`
 	IsError   bool            `json:"is_error,omitempty"`
+	// Set on result messages: usage and cost of the turn that just finished
+	Usage        *Usage  `json:"usage,omitempty"`
+	TotalCostUSD float64 `json:"total_cost_usd,omitempty"`
+	// Event is the raw API event of a stream_event message; Text is the delta of a PartialText message
+	Event json.RawMessage `json:"event,omitempty"`
+	Text  string          `json:"text,omitempty"`
+	// Set on approval_required messages: the command waiting for a decision
+	Approval *ApprovalRequest `json:"approval,omitempty"`
+}
+
+// controlRequest is a stream-json control message sent to the CLI alongside user input
+type controlRequest struct {
+	Type      string `json:"type"`
+	RequestID string `json:"request_id"`
+	Request   struct {
+		Subtype string `json:"subtype"`
+		Model   string `json:"model,omitempty"` // For set_model
+	} `json:"request"`
+	retry    *Input           // Written to stdin right after the request, so it's handled under the new settings
+	response *controlResponse // Set to answer a request from the CLI instead of making one
 }
 
+// wire returns the message written to stdin for the request
+func (r controlRequest) wire() any {
+	if r.response != nil {
+		return struct {
+			Type     string           `json:"type"`
+			Response *controlResponse `json:"response"`
+		}{Type: "control_response", Response: r.response}
+	}
+	return r
+}
+
+var (
+	// ErrSessionNotRunning is returned when a session has no Claude process
+	ErrSessionNotRunning = errors.New("session is not running")
+	// ErrNoActiveTurn is returned by CancelTurn when Claude is not working on a prompt
+	ErrNoActiveTurn = errors.New("no turn in progress")
+)
+
 type Input struct {
 	Type    string       `json:"type"`
 	Message InputMessage `json:"message"`
@@ -139,6 +298,14 @@ type InputMessageContent struct {
 	Text string `json:"text"`
 }
 
+// CheckCLI verifies that the claude CLI is installed and on PATH
+func CheckCLI() error {
+	if _, err := exec.LookPath("claude"); err != nil {
+		return fmt.Errorf("claude CLI not found: %w", err)
+	}
+	return nil
+}
+
 func NewService(config Config) *Service {
 	// Set default values if not provided
 	if len(config.Tools) == 0 {
@@ -149,8 +316,10 @@ func NewService(config Config) *Service {
 	}
 
 	return &Service{
-		config:   config,
-		sessions: make(map[string]*Process),
+		config:           config,
+		sessions:         make(map[string]*Process),
+		scheduler:        newScheduler(config.Scheduler),
+		approvalPatterns: compileApprovalPatterns(config.Approval.Patterns),
 	}
 }
 
@@ -167,35 +336,6 @@ func (s *Service) createDebugDirectory(correlationID string) (string, error) {
 	return debugDir, nil
 }
 
-// formatUserError converts Claude CLI stderr messages into user-friendly error messages
-func (s *Service) formatUserError(stderrLine string) string {
-	lowerLine := strings.ToLower(stderrLine)
-
-	// Handle JSON parsing errors
-	if strings.Contains(lowerLine, "syntaxerror") && strings.Contains(lowerLine, "json") {
-		if strings.Contains(stderrLine, "\"asdf\"") || strings.Contains(stderrLine, "'asdf'") {
-			return "Invalid input format. Please provide a valid question or command instead of random text."
-		}
-		return "Invalid input format. Please ensure your input is properly formatted text or valid JSON."
-	}
-
-	// Handle other common errors
-	if strings.Contains(lowerLine, "parsing") && strings.Contains(lowerLine, "error") {
-		return "Unable to process your input. Please check the format and try again."
-	}
-
-	if strings.Contains(lowerLine, "timeout") {
-		return "Request timed out. Please try again or simplify your request."
-	}
-
-	if strings.Contains(lowerLine, "failed") {
-		return "Command failed to execute. Please check your input and try again."
-	}
-
-	// Default error message for unrecognized errors
-	return "An error occurred while processing your request. Please try again."
-}
-
 // openDebugFiles opens debug log files for stdin, stdout, and stderr
 func (s *Service) openDebugFiles(debugDir string) (*os.File, *os.File, *os.File, error) {
 	if debugDir == "" {
@@ -252,18 +392,21 @@ func (process *Process) validateProcessHealth() bool {
 		return false
 	}
 
-	// Check if process is still running
-	if err := process.cmd.Process.Signal(os.Signal(nil)); err != nil {
+	// An exited process stays signalable until it is reaped, so check stdout too
+	if process.exited.Load() {
+		return false
+	}
+	if err := process.cmd.Process.Signal(syscall.Signal(0)); err != nil {
 		return false
 	}
 
-	process.lastHeartbeat = time.Now()
-	process.isHealthy = true
+	process.isHealthy.Store(true)
 	return true
 }
 
 // monitorStderr monitors stderr output from the Claude process
 func (s *Service) monitorStderr(process *Process) {
+
 	slog.Debug("Starting stderr monitoring",
 		"correlation_id", process.correlationID,
 		"session_id", process.sessionID,
@@ -304,34 +447,13 @@ func (s *Service) monitorStderr(process *Process) {
 				"action", "stderr_critical_error",
 			)
 
-			// Create user-friendly error message
-			userError := s.formatUserError(line)
-			errorMsg := Message{
-				Type:      "error",
-				Subtype:   "process_error",
+			process.reportError(&SessionError{
+				Kind:      classifyError(line),
 				SessionID: process.sessionID,
-				Message: json.RawMessage(fmt.Sprintf(`{"error": "%s", "source": "claude_process", "timestamp": "%s", "details": "%s"}`,
-					userError, time.Now().Format(time.RFC3339), strings.ReplaceAll(line, `"`, `\"`))),
-				IsError: true,
-			}
-
-			// Send error to error channel (non-blocking)
-			select {
-			case process.errorChan <- errorMsg:
-				slog.Debug("Error message sent to error channel",
-					"correlation_id", process.correlationID,
-					"session_id", process.sessionID,
-					"action", "error_forwarded",
-				)
-			default:
-				slog.Warn("Error channel full, dropping error message",
-					"correlation_id", process.correlationID,
-					"session_id", process.sessionID,
-					"action", "error_dropped",
-				)
-			}
+				Detail:    line,
+			})
 
-			process.isHealthy = false
+			process.isHealthy.Store(false)
 		}
 	}
 
@@ -357,33 +479,67 @@ func (s *Service) CreateSession() (*Process, error) {
 	return s.CreateSessionWithOptions("")
 }
 
-func (s *Service) CreateSessionWithOptions(workingDir string) (*Process, error) {
-	return s.CreateSessionWithMultipleDirs([]string{workingDir})
+// CreateSessionWithOptions creates a new Claude session in workingDir, with
+// opts overriding the service defaults such as which tools are allowed
+func (s *Service) CreateSessionWithOptions(workingDir string, opts ...SessionOption) (*Process, error) {
+	return s.CreateSessionWithMultipleDirs([]string{workingDir}, opts...)
 }
 
-// CreateSessionWithMultipleDirs creates a new Claude session with multiple directories
-func (s *Service) CreateSessionWithMultipleDirs(dirs []string) (*Process, error) {
-	startTime := time.Now()
-	correlationID := uuid.New().String()
+// CreateSessionWithMultipleDirs creates a new Claude session with multiple
+// directories, waiting for a free process slot if the scheduler is at capacity
+func (s *Service) CreateSessionWithMultipleDirs(dirs []string, opts ...SessionOption) (*Process, error) {
+	return s.CreateSessionWithDirectories(readWriteDirs(dirs), opts...)
+}
 
-	// Validate that at least one directory is provided for isolation
-	if len(dirs) == 0 {
-		return nil, fmt.Errorf("at least one directory must be provided for Claude session isolation")
+// CreateSessionWithDirectories creates a new Claude session whose directories
+// each say whether Claude may change them. The first is the working directory.
+func (s *Service) CreateSessionWithDirectories(dirs []Directory, opts ...SessionOption) (*Process, error) {
+	options := newSessionOptions(opts)
+	return s.schedule(options, func() (*Process, error) {
+		return s.startSession(dirs, options)
+	})
+}
+
+// schedule runs start once the session has a process slot and hands the slot to the started process
+func (s *Service) schedule(options SessionOptions, start func() (*Process, error)) (*Process, error) {
+	release := options.slot
+	if release == nil {
+		var err error
+		if release, err = s.scheduler.Acquire(options.userID, options.onQueued); err != nil {
+			return nil, err
+		}
 	}
 
-	// Ensure the first directory exists and will be used as working directory
-	sessionDir := dirs[0]
-	if sessionDir == "" {
-		return nil, fmt.Errorf("session directory (first directory) cannot be empty")
+	process, err := start()
+	if err != nil {
+		release()
+		return nil, err
 	}
+	process.holdSlot(release)
+	return process, nil
+}
 
-	// Validate that the session directory exists and is accessible
-	if _, err := os.Stat(sessionDir); err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("session directory does not exist: %s", sessionDir)
-		}
-		return nil, fmt.Errorf("session directory is not accessible: %s (%w)", sessionDir, err)
+// startSession starts a Claude process without waiting for the scheduler
+func (s *Service) startSession(dirs []Directory, options SessionOptions) (*Process, error) {
+	startTime := time.Now()
+	process, err := s.createSessionWithMultipleDirs(dirs, options)
+	metrics.ClaudeSessionStartsTotal.WithLabelValues(metrics.Result(err)).Inc()
+	if err == nil {
+		metrics.ClaudeSessionStartDuration.Observe(time.Since(startTime).Seconds())
 	}
+	return process, err
+}
+
+func (s *Service) createSessionWithMultipleDirs(dirs []Directory, opts SessionOptions) (*Process, error) {
+	startTime := time.Now()
+	correlationID := uuid.New().String()
+
+	// Validate the directories; the first is used as the working directory
+	dirs, err := resolveDirectories(dirs)
+	if err != nil {
+		return nil, err
+	}
+	sessionDir := dirs[0].Path
 
 	slog.Info("Creating new Claude CLI session",
 		"correlation_id", correlationID,
@@ -430,15 +586,10 @@ func (s *Service) CreateSessionWithMultipleDirs(dirs []string) (*Process, error)
 		"--input-format", "stream-json",
 		"--output-format", "stream-json",
 		"--verbose",
-		"--allowedTools", strings.Join(s.config.Tools, ","),
-	}
-	
-	// Add all directories that are not empty
-	for _, dir := range dirs {
-		if dir != "" {
-			args = append(args, "--add-dir", dir)
-		}
+		"--include-partial-messages",
 	}
+	args = append(args, opts.cliArgs(s.config, dirs)...)
+	args = append(args, directoryArgs(dirs)...)
 
 	slog.Debug("Claude CLI command prepared",
 		"correlation_id", correlationID,
@@ -448,10 +599,21 @@ func (s *Service) CreateSessionWithMultipleDirs(dirs []string) (*Process, error)
 		"action", "claude_cmd_prepared",
 	)
 
-	cmd := exec.CommandContext(ctx, "claude", args...)
-	
-	// Set working directory to the session directory for isolation
-	cmd.Dir = sessionDir
+	// Runs from the session directory for isolation
+	cmd, err := sandboxCommand(ctx, s.config.Sandbox, sessionDir, dirs, args)
+	if err != nil {
+		cancel()
+		if stdinLogFile != nil {
+			stdinLogFile.Close()
+		}
+		if stdoutLogFile != nil {
+			stdoutLogFile.Close()
+		}
+		if stderrLogFile != nil {
+			stderrLogFile.Close()
+		}
+		return nil, fmt.Errorf("failed to prepare Claude sandbox: %w", err)
+	}
 	
 	slog.Info("Claude CLI will execute from session directory",
 		"correlation_id", correlationID,
@@ -555,6 +717,7 @@ func (s *Service) CreateSessionWithMultipleDirs(dirs []string) (*Process, error)
 
 	process := &Process{
 		cmd:           cmd,
+		dirs:          dirs,
 		stdin:         stdin,
 		stdout:        stdout,
 		stderr:        stderr,
@@ -568,13 +731,18 @@ func (s *Service) CreateSessionWithMultipleDirs(dirs []string) (*Process, error)
 		stdinLogFile:  stdinLogFile,
 		stdoutLogFile: stdoutLogFile,
 		stderrLogFile: stderrLogFile,
-		isHealthy:     true,
-		lastHeartbeat: time.Now(),
 		inputChan:     make(chan Input, 10),   // Buffered channel for input
 		outputChan:    make(chan Message, 10), // Buffered channel for output
 		initComplete:  make(chan bool, 1),     // Signal channel for init
-		errorChan:     make(chan Message, 10), // Buffered channel for errors
+		errorChan:     make(chan *SessionError, 10),
+		controlChan:   make(chan controlRequest, 1),
 	}
+	process.isHealthy.Store(true)
+	process.heartbeat()
+	if model := opts.model(s.config); model != "" {
+		process.model.Store(&model)
+	}
+	process.budget = opts.sessionBudget(s.config)
 
 	// Start stderr monitoring in background
 	go s.monitorStderr(process)
@@ -629,6 +797,7 @@ func (s *Service) CreateSessionWithMultipleDirs(dirs []string) (*Process, error)
 	// Add to active sessions
 	s.mu.Lock()
 	s.sessions[process.sessionID] = process
+	metrics.ClaudeSessionsActive.Set(float64(len(s.sessions)))
 	s.mu.Unlock()
 
 	return process, nil
@@ -638,6 +807,8 @@ func (s *Service) CreateSessionWithMultipleDirs(dirs []string) (*Process, error)
 func (s *Service) handleStdout(process *Process) {
 	defer close(process.outputChan)
 	defer close(process.initComplete)
+	defer process.exited.Store(true)
+	defer s.storeTranscript(process) // Keep what arrived of a turn cut short
 
 	slog.Debug("Starting stdout handler",
 		"correlation_id", process.correlationID,
@@ -653,6 +824,7 @@ func (s *Service) handleStdout(process *Process) {
 		}
 
 		messageCount++
+		process.heartbeat()
 
 		// Log to debug file if enabled
 		process.logToDebugFile(process.stdoutLogFile, "STDOUT", []byte(line))
@@ -693,18 +865,104 @@ func (s *Service) handleStdout(process *Process) {
 			continue
 		}
 
-		// Send to output channel
-		select {
-		case process.outputChan <- msg:
-		case <-process.ctx.Done():
-			slog.Debug("Context cancelled, stopping stdout handler",
+		// The CLI asks before running a tool that wasn't allowed up front
+		if msg.Type == "control_request" {
+			if approval, ok := s.handlePermissionRequest(process, []byte(line)); ok {
+				select {
+				case process.outputChan <- approval:
+				case <-process.ctx.Done():
+					return
+				}
+			}
+			continue
+		}
+
+		// Only text deltas are forwarded from the partial message stream
+		if msg.Type == "stream_event" {
+			partial, ok := partialText(msg)
+			if !ok {
+				continue
+			}
+			msg = partial
+		}
+
+		// A rate-limited turn is retried on the fallback model instead of ending
+		if s.fallBack(process, msg) {
+			msg = Message{Type: "system", Subtype: MessageSubtypeModelFallback, SessionID: msg.SessionID, Text: process.Model()}
+		}
+		s.enforceTimeLimit(process)
+		for _, sessionErr := range messageErrors(msg) {
+			process.reportError(sessionErr)
+		}
+
+		events.Publish(s.events, events.TopicSessionMessage, events.SessionMessage{
+			SessionID: process.FlowSessionID(),
+			Type:      msg.Type,
+			Subtype:   msg.Subtype,
+			Message:   msg.Message,
+			Result:    msg.Result,
+			IsError:   msg.IsError,
+			Text:      msg.Text,
+		})
+
+		if msg.Type == "control_response" {
+			slog.Debug("Received Claude control response",
 				"correlation_id", process.correlationID,
-				"action", "stdout_handler_cancelled",
+				"action", "control_response_received",
 			)
-			return
+			continue
+		}
+
+		// Only turns started by SendMessage are saved, which leaves out the init turn
+		if process.inTurn.Load() && msg.Type != MessageTypePartialText {
+			process.transcript.add(msg)
+		}
+		process.tools.dispatch(msg)
+
+		outgoing := []Message{msg}
+		if msg.Type == "result" {
+			s.storeTranscript(process)
+			process.inTurn.Store(false)
+			if err := s.usage.Record(process.FlowSessionID(), process.UserID(), msg); err != nil {
+				slog.Warn("Failed to record Claude usage",
+					"correlation_id", process.correlationID,
+					"error", err,
+				)
+			}
+			if paused, ok := s.spendBudget(process, msg); ok {
+				outgoing = append(outgoing, paused)
+				events.Publish(s.events, events.TopicSessionMessage, events.SessionMessage{
+					SessionID: process.FlowSessionID(),
+					Type:      paused.Type,
+					Subtype:   paused.Subtype,
+					Text:      paused.Text,
+				})
+			}
+		}
+
+		// Send to output channel
+		for _, out := range outgoing {
+			select {
+			case process.outputChan <- out:
+			case <-process.ctx.Done():
+				slog.Debug("Context cancelled, stopping stdout handler",
+					"correlation_id", process.correlationID,
+					"action", "stdout_handler_cancelled",
+				)
+				return
+			}
 		}
 	}
 
+	// Output only ends on its own when the CLI exits without being stopped
+	if process.ctx.Err() == nil {
+		process.reportError(&SessionError{
+			Kind:      ErrCrash,
+			SessionID: process.sessionID,
+			Detail:    "Claude CLI exited unexpectedly",
+		})
+	}
+
 	if err := process.stdoutScanner.Err(); err != nil {
 		slog.Error("Stdout scanner error",
 			"correlation_id", process.correlationID,
@@ -777,6 +1035,55 @@ func (s *Service) handleStdin(process *Process) {
 				"action", "stdin_message_sent",
 			)
 
+		case request := <-process.controlChan:
+			m, err := json.Marshal(request.wire())
+			if err != nil {
+				slog.Error("Failed to marshal Claude control request",
+					"correlation_id", process.correlationID,
+					"error", err,
+					"action", "stdin_control_marshal_failed",
+				)
+				continue
+			}
+
+			process.logToDebugFile(process.stdinLogFile, "STDIN", m)
+
+			if _, err := fmt.Fprintln(process.stdin, string(m)); err != nil {
+				slog.Error("Failed to write control request to Claude stdin",
+					"correlation_id", process.correlationID,
+					"error", err,
+					"action", "stdin_write_failed",
+				)
+				return
+			}
+
+			slog.Info("Sent control request to Claude",
+				"correlation_id", process.correlationID,
+				"subtype", request.Request.Subtype,
+				"action", "stdin_control_sent",
+			)
+
+			if request.retry != nil {
+				m, err := json.Marshal(request.retry)
+				if err != nil {
+					slog.Error("Failed to marshal retried Claude input",
+						"correlation_id", process.correlationID,
+						"error", err,
+						"action", "stdin_retry_marshal_failed",
+					)
+					continue
+				}
+				process.logToDebugFile(process.stdinLogFile, "STDIN", m)
+				if _, err := fmt.Fprintln(process.stdin, string(m)); err != nil {
+					slog.Error("Failed to write retried input to Claude stdin",
+						"correlation_id", process.correlationID,
+						"error", err,
+						"action", "stdin_write_failed",
+					)
+					return
+				}
+			}
+
 		case <-process.ctx.Done():
 			slog.Debug("Context cancelled, stopping stdin handler",
 				"correlation_id", process.correlationID,
@@ -789,6 +1096,14 @@ func (s *Service) handleStdin(process *Process) {
 }
 
 func (s *Service) SendMessage(process *Process, text string) error {
+	process = process.current()
+	if process.ctx.Err() != nil {
+		return fmt.Errorf("session cancelled")
+	}
+	if limit := process.budget.pausedBy(); limit != "" {
+		return fmt.Errorf("%w: reached %s", ErrBudgetExceeded, limit)
+	}
+
 	message := Input{
 		Type: "user",
 		Message: InputMessage{
@@ -802,10 +1117,29 @@ func (s *Service) SendMessage(process *Process, text string) error {
 		},
 	}
 
+	// A conversation restarted from a transcript summary gets it with its first prompt
+	sent := message
+	replay := process.replay.Swap(nil)
+	if replay != nil {
+		sent.Message.Content = []InputMessageContent{{Type: "text", Text: *replay + text}}
+	}
+
 	select {
-	case process.inputChan <- message:
+	case process.inputChan <- sent:
+		process.heartbeat()
+		process.inTurn.Store(true)
+		process.lastPrompt.Store(&sent)
+		if raw, err := json.Marshal(message.Message); err == nil {
+			process.transcript.add(Message{Type: message.Type, Message: raw})
+			events.Publish(s.events, events.TopicSessionMessage, events.SessionMessage{
+				SessionID: process.FlowSessionID(),
+				Type:      message.Type,
+				Message:   raw,
+			})
+		}
 		return nil
 	case <-time.After(5 * time.Second):
+		process.replay.CompareAndSwap(nil, replay)
 		return fmt.Errorf("timeout sending message")
 	case <-process.ctx.Done():
 		return fmt.Errorf("session cancelled")
@@ -813,7 +1147,64 @@ func (s *Service) SendMessage(process *Process, text string) error {
 }
 
 func (s *Service) ReceiveMessages(process *Process) <-chan Message {
-	return process.outputChan
+	return process.current().outputChan
+}
+
+// Errors returns the failures a process reports, such as authentication or
+// rate-limit errors. The channel isn't closed, so read it alongside
+// ReceiveMessages and stop when that channel closes.
+func (s *Service) Errors(process *Process) <-chan *SessionError {
+	return process.current().errorChan
+}
+
+// getProcess looks a process up by Claude CLI session ID or flow session ID
+func (s *Service) getProcess(sessionID string) (*Process, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if process, exists := s.sessions[sessionID]; exists {
+		return process, true
+	}
+
+	// New sessions are keyed by the Claude CLI session ID rather than the flow session ID
+	for _, process := range s.sessions {
+		if process.FlowSessionID() == sessionID {
+			return process, true
+		}
+	}
+	return nil, false
+}
+
+// CancelTurn asks the Claude CLI to stop the turn in progress for a session.
+// Unlike StopSession the process keeps running with its conversation intact;
+// the interrupted turn ends with a result message as usual.
+func (s *Service) CancelTurn(sessionID string) error {
+	process, exists := s.getProcess(sessionID)
+	if !exists {
+		return fmt.Errorf("session %s: %w", sessionID, ErrSessionNotRunning)
+	}
+	if !process.inTurn.Load() {
+		return ErrNoActiveTurn
+	}
+
+	request := controlRequest{
+		Type:      "control_request",
+		RequestID: uuid.NewString(),
+	}
+	request.Request.Subtype = "interrupt"
+
+	select {
+	case process.controlChan <- request:
+		slog.Info("Interrupting Claude turn",
+			"correlation_id", process.correlationID,
+			"session_id", sessionID,
+			"action", "turn_cancel",
+		)
+		return nil
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("timeout sending interrupt")
+	case <-process.ctx.Done():
+		return fmt.Errorf("session cancelled")
+	}
 }
 
 func (s *Service) StopSession(sessionID string) {
@@ -829,107 +1220,160 @@ func (s *Service) StopSession(sessionID string) {
 	if exists {
 		delete(s.sessions, sessionID)
 	}
+	metrics.ClaudeSessionsActive.Set(float64(len(s.sessions)))
 	s.mu.Unlock()
 
-	if exists {
-		correlationID := process.correlationID
-		sessionUptime := time.Since(process.startTime)
-
-		slog.Info("Found active session to stop",
-			"correlation_id", correlationID,
+	if !exists {
+		slog.Warn("Attempted to stop non-existent session",
 			"session_id", sessionID,
-			"session_uptime_ms", sessionUptime.Milliseconds(),
-			"action", "session_found_for_stop",
+			"action", "session_not_found_for_stop",
 		)
+		return
+	}
 
-		// Clean up process
-		slog.Debug("Cleaning up Claude process",
-			"correlation_id", correlationID,
-			"session_id", sessionID,
-			"pid", func() int {
-				if process.cmd != nil && process.cmd.Process != nil {
-					return process.cmd.Process.Pid
-				}
-				return 0
-			}(),
-			"action", "process_cleanup_start",
-		)
+	s.stopProcess(sessionID, process, startTime)
+}
 
-		// Close debug files
-		process.closeDebugFiles()
+// stopProcess shuts down a process that is no longer in the active sessions
+func (s *Service) stopProcess(sessionID string, process *Process, startTime time.Time) {
+	correlationID := process.correlationID
+	sessionUptime := time.Since(process.startTime)
 
-		process.cancel()
+	slog.Info("Found active session to stop",
+		"correlation_id", correlationID,
+		"session_id", sessionID,
+		"session_uptime_ms", sessionUptime.Milliseconds(),
+		"action", "session_found_for_stop",
+	)
 
-		// Close channels to signal goroutines to stop
-		if process.inputChan != nil {
-			close(process.inputChan)
-		}
-		if process.errorChan != nil {
-			close(process.errorChan)
-		}
-		// Note: outputChan and initComplete are closed by the handleStdout goroutine
+	// Clean up process
+	slog.Debug("Cleaning up Claude process",
+		"correlation_id", correlationID,
+		"session_id", sessionID,
+		"pid", func() int {
+			if process.cmd != nil && process.cmd.Process != nil {
+				return process.cmd.Process.Pid
+			}
+			return 0
+		}(),
+		"action", "process_cleanup_start",
+	)
 
-		if process.stdin != nil {
-			process.stdin.Close()
-		}
-		if process.stdout != nil {
-			process.stdout.Close()
-		}
-		if process.stderr != nil {
-			process.stderr.Close()
-		}
+	// Close debug files
+	process.closeDebugFiles()
 
-		if process.cmd != nil {
-			if err := process.cmd.Wait(); err != nil {
-				slog.Warn("Claude process exited with error",
-					"correlation_id", correlationID,
-					"session_id", sessionID,
-					"error", err,
-					"action", "process_wait_error",
-				)
-			} else {
-				slog.Debug("Claude process exited cleanly",
-					"correlation_id", correlationID,
-					"session_id", sessionID,
-					"action", "process_exited_clean",
-				)
-			}
-		}
+	process.cancel()
+	process.releaseSlot()
 
-		totalStopDuration := time.Since(startTime)
-		slog.Info("Claude session stopped successfully",
-			"correlation_id", correlationID,
-			"session_id", sessionID,
-			"session_uptime_ms", sessionUptime.Milliseconds(),
-			"stop_duration_ms", totalStopDuration.Milliseconds(),
-			"action", "session_stopped",
-		)
-	} else {
-		slog.Warn("Attempted to stop non-existent session",
-			"session_id", sessionID,
-			"action", "session_not_found_for_stop",
-		)
+	// Close channels to signal goroutines to stop
+	if process.inputChan != nil {
+		close(process.inputChan)
+	}
+	// Note: outputChan and initComplete are closed by the handleStdout goroutine
+
+	if process.stdin != nil {
+		process.stdin.Close()
+	}
+	if process.stdout != nil {
+		process.stdout.Close()
+	}
+	if process.stderr != nil {
+		process.stderr.Close()
+	}
+
+	if process.cmd != nil {
+		if err := process.cmd.Wait(); err != nil {
+			slog.Warn("Claude process exited with error",
+				"correlation_id", correlationID,
+				"session_id", sessionID,
+				"error", err,
+				"action", "process_wait_error",
+			)
+		} else {
+			slog.Debug("Claude process exited cleanly",
+				"correlation_id", correlationID,
+				"session_id", sessionID,
+				"action", "process_exited_clean",
+			)
+		}
 	}
+
+	totalStopDuration := time.Since(startTime)
+	slog.Info("Claude session stopped successfully",
+		"correlation_id", correlationID,
+		"session_id", sessionID,
+		"session_uptime_ms", sessionUptime.Milliseconds(),
+		"stop_duration_ms", totalStopDuration.Milliseconds(),
+		"action", "session_stopped",
+	)
 }
 
 // NewClaudeService creates a new database-integrated Claude service
 func NewClaudeService(d deps.Deps) *ClaudeService {
 	config := Config{
-		Debug:    d.Config.ClaudeDebug,
-		DebugDir: "/tmp/claude-sessions",
-		Tools:    []string{"Read", "Write", "Bash"},
+		Debug:         d.Config.ClaudeDebug,
+		DebugDir:      "/tmp/claude-sessions",
+		Tools:         []string{"Read", "Write", "Bash"},
+		Model:         d.Config.Claude.Model,
+		FallbackModel: d.Config.Claude.FallbackModel,
+		Scheduler:     d.Config.Claude.Scheduler,
+		Sandbox:       d.Config.Claude.Sandbox,
+		Budget:        d.Config.Claude.Budget,
+		Approval:      d.Config.Claude.Approval,
 	}
 
 	service := NewService(config)
+	service.events = d.Events
+	service.usage = NewUsageTracker(d.DB)
+	service.transcripts = NewTranscriptStore(d.DB)
 	gitService := NewGitService()
 
-	return &ClaudeService{
+	cs := &ClaudeService{
 		service:    service,
 		gitService: gitService,
 		db:         d.DB,
 		config:     config,
 		debug:      config.Debug,
+		events:     d.Events,
+		supervisor: d.Config.Claude.Supervisor,
+		personas:   d.Config.Claude.Personas,
+		jobs:       NewJobs(service, d.DB, d.Events),
+		output: map[string]*OutputPipeline{
+			OutputSlack: NewOutputPipeline(d.Config.Claude.Output.Slack, d.Config.Claude.Output),
+			OutputHTTP:  NewOutputPipeline(d.Config.Claude.Output.HTTP, d.Config.Claude.Output),
+		},
+	}
+
+	cs.janitor = newJanitor(d.Config.Claude.Storage, filepath.Join("./data", "session"), cs.runningSessionDirs)
+
+	// Sessions that ask for a specific CLAUDE.md configuration always start a fresh process
+	if d.Config.Claude.Pool.Size > 0 {
+		cs.pool = newPool(d.Config.Claude.Pool, cs.warmSession, cs.discardWarmSession)
 	}
+	return cs
+}
+
+// sessionStarted ties process to its flow session and publishes session.started
+func (cs *ClaudeService) sessionStarted(process *Process, started events.SessionStarted) {
+	process.flowSessionID.Store(&started.SessionID)
+	process.userID.Store(&started.UserID)
+	events.Publish(cs.events, events.TopicSessionStarted, started)
+}
+
+// Output returns the post-processors a consumer runs on Claude's messages;
+// nil, which changes nothing, for a consumer without any
+func (cs *ClaudeService) Output(consumer string) *OutputPipeline {
+	return cs.output[consumer]
+}
+
+// Usage returns the tracker holding per-session and per-user token usage
+func (cs *ClaudeService) Usage() *UsageTracker {
+	return cs.service.usage
+}
+
+// Jobs returns the runner for background one-shot Claude tasks
+func (cs *ClaudeService) Jobs() *Jobs {
+	return cs.jobs
 }
 
 // GetDB returns the database instance for external access
@@ -938,34 +1382,68 @@ func (cs *ClaudeService) GetDB() *gorm.DB {
 }
 
 // CreateSessionWithPersistence creates a new Claude session and persists it to database
-func (cs *ClaudeService) CreateSessionWithPersistence(threadTS, channelID, userID, workingDir string) (*Process, *SessionInfo, error) {
-	return cs.CreateSessionWithPersistenceAndConfig(threadTS, channelID, userID, workingDir, "")
+func (cs *ClaudeService) CreateSessionWithPersistence(threadTS, channelID, userID, workingDir string, opts ...SessionOption) (*Process, *SessionInfo, error) {
+	return cs.CreateSessionWithPersistenceAndConfig(threadTS, channelID, userID, workingDir, "", opts...)
 }
 
 // CreateSessionWithPersistenceAndConfig creates a new Claude session with specified CLAUDE.md configuration
-func (cs *ClaudeService) CreateSessionWithPersistenceAndConfig(threadTS, channelID, userID, workingDir, configID string) (*Process, *SessionInfo, error) {
+func (cs *ClaudeService) CreateSessionWithPersistenceAndConfig(threadTS, channelID, userID, workingDir, configID string, opts ...SessionOption) (*Process, *SessionInfo, error) {
+	uploadDir := filepath.Join("./data", "slack-uploads", threadTS)
+	opts = append(opts, WithUser(userID))
+	options := newSessionOptions(opts)
+
+	// Sessions using the default CLAUDE.md and tools can take a process that is already running
+	if configID == "" && options.isDefault() {
+		if warm, ok := cs.pool.Get(); ok {
+			// Warm processes don't hold a slot until they are handed out
+			release, err := cs.service.scheduler.Acquire(userID, options.onQueued)
+			if err != nil {
+				cs.discardWarmSession(warm)
+				return nil, nil, err
+			}
+			warm.process.holdSlot(release)
+			if err := os.MkdirAll(uploadDir, 0755); err != nil {
+				slog.Warn("Failed to create upload directory, Claude won't have access to uploaded files",
+					"upload_dir", uploadDir,
+					"error", err,
+					"thread_ts", threadTS)
+			} else if err := linkUploadDir(warm.sessionDir, uploadDir); err != nil {
+				slog.Warn("Failed to expose upload directory to warm Claude process",
+					"upload_dir", uploadDir,
+					"error", err,
+					"thread_ts", threadTS)
+			}
+			return cs.persistNewSession(warm.process, warm.sessionID, warm.sessionDir, uploadDir, threadTS, channelID, userID, options)
+		}
+	}
+
 	// Create session ID first
 	sessionID := uuid.New().String()
 	
-	// Create session-specific directory structure
+	// Create session-specific directory structure, unless the session shares one
 	sessionDir := filepath.Join("./data", "session", sessionID)
+	if options.sessionDir != "" {
+		sessionDir = options.sessionDir
+	}
 	if err := os.MkdirAll(sessionDir, 0755); err != nil {
 		return nil, nil, fmt.Errorf("failed to create session directory: %w", err)
 	}
 	
-	// Create CLAUDE.md in session directory from configuration
+	// Create CLAUDE.md in session directory from configuration. A shared
+	// directory keeps the one it has.
 	sessionClaudemd := filepath.Join(sessionDir, "CLAUDE.md")
-	if err := cs.createClaudeMDFromConfig(sessionClaudemd, configID); err != nil {
-		slog.Warn("Failed to create CLAUDE.md in session directory",
-			"session_id", sessionID,
-			"config_id", configID,
-			"error", err)
-		// Continue without CLAUDE.md - not critical
+	if _, err := os.Stat(sessionClaudemd); options.sessionDir == "" || err != nil {
+		if err := cs.createClaudeMDFromConfig(sessionClaudemd, configID); err != nil {
+			slog.Warn("Failed to create CLAUDE.md in session directory",
+				"session_id", sessionID,
+				"config_id", configID,
+				"error", err)
+			// Continue without CLAUDE.md - not critical
+		}
 	}
 	
-	// Prepare directories - use session directory as primary, include upload directory for this thread
-	uploadDir := filepath.Join("./data", "slack-uploads", threadTS)
-	dirs := []string{sessionDir, uploadDir}
+	// Prepare directories - use session directory as primary, include the thread's uploads read-only
+	dirs := []Directory{ReadWriteDir(sessionDir), ReadOnlyDir(uploadDir)}
 	
 	// Create upload directory if it doesn't exist
 	if err := os.MkdirAll(uploadDir, 0755); err != nil {
@@ -974,15 +1452,29 @@ func (cs *ClaudeService) CreateSessionWithPersistenceAndConfig(threadTS, channel
 			"error", err,
 			"thread_ts", threadTS)
 		// Continue without upload directory, but keep session directory as primary
-		dirs = []string{sessionDir}
+		dirs = dirs[:1]
 	}
+	dirs = append(dirs, options.dirs...)
 
 	// Create the Claude process using the underlying service with multiple directories
-	process, err := cs.service.CreateSessionWithMultipleDirs(dirs)
+	process, err := cs.service.CreateSessionWithDirectories(dirs, opts...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create Claude process: %w", err)
 	}
 
+	return cs.persistNewSession(process, sessionID, sessionDir, uploadDir, threadTS, channelID, userID, options)
+}
+
+// persistNewSession publishes session.started for a newly started process and records it in the database
+func (cs *ClaudeService) persistNewSession(process *Process, sessionID, sessionDir, uploadDir, threadTS, channelID, userID string, options SessionOptions) (*Process, *SessionInfo, error) {
+	cs.sessionStarted(process, events.SessionStarted{
+		SessionID:  sessionID,
+		UserID:     userID,
+		ThreadTS:   threadTS,
+		ChannelID:  channelID,
+		WorkingDir: sessionDir,
+	})
+
 	// Create session info
 	sessionInfo := &SessionInfo{
 		SessionID:     sessionID,
@@ -996,6 +1488,18 @@ func (cs *ClaudeService) CreateSessionWithPersistenceAndConfig(threadTS, channel
 	}
 
 	// Persist session to database
+	metadata := map[string]interface{}{
+		"thread_ts":         threadTS,
+		"channel_id":        channelID,
+		"working_dir":       sessionDir,
+		"session_dir":       sessionDir,
+		"upload_dir":        uploadDir,
+		"claude_session_id": process.sessionID,
+		"created_via":       "slack_bot",
+		"last_activity":     time.Now().Format(time.RFC3339),
+		"active":            true,
+	}
+	maps.Copy(metadata, options.metadata())
 	dbSession := &models.ClaudeSession{
 		Model: models.Model{
 			ID:        uuid.NewString(),
@@ -1006,16 +1510,7 @@ func (cs *ClaudeService) CreateSessionWithPersistenceAndConfig(threadTS, channel
 		UserID:    userID,
 		Title:     fmt.Sprintf("Slack Thread %s", threadTS),
 		Messages:  models.JSONField[interface{}]{Data: []interface{}{}},
-		Metadata: models.MakeJSONField(map[string]interface{}{
-			"thread_ts":      threadTS,
-			"channel_id":     channelID,
-			"working_dir":    sessionDir,
-			"session_dir":    sessionDir,
-			"upload_dir":     uploadDir,
-			"created_via":    "slack_bot",
-			"last_activity":  time.Now().Format(time.RFC3339),
-			"active":         true,
-		}),
+		Metadata:  models.MakeJSONField(metadata),
 	}
 
 	if err := cs.db.Create(dbSession).Error; err != nil {
@@ -1036,8 +1531,9 @@ func (cs *ClaudeService) CreateSessionWithPersistenceAndConfig(threadTS, channel
 	return process, sessionInfo, nil
 }
 
-// ResumeSession attempts to resume an existing Claude session using --resume
-func (cs *ClaudeService) ResumeSession(sessionID, userID string) (*Process, error) {
+// ResumeSession attempts to resume an existing Claude session using --resume.
+// opts are applied on top of the tool permissions the session was created with.
+func (cs *ClaudeService) ResumeSession(sessionID, userID string, opts ...SessionOption) (*Process, error) {
 	if cs.debug {
 		slog.Debug("Attempting to resume Claude session",
 			"session_id", sessionID,
@@ -1054,11 +1550,18 @@ func (cs *ClaudeService) ResumeSession(sessionID, userID string) (*Process, erro
 		return nil, fmt.Errorf("failed to query session: %w", err)
 	}
 
-	// Extract session directory and upload directory from metadata
+	// Extract session directory, upload directory, and tool permissions from metadata
+	cliSessionID := sessionID
 	sessionDir := ""
 	uploadDir := ""
+	var options SessionOptions
 	if dbSession.Metadata != nil {
 		metadata := dbSession.Metadata.Data
+		options = sessionOptionsFromMetadata(metadata)
+		// The CLI's own ID for the conversation, when it differs from the flow session's
+		if id, ok := metadata["claude_session_id"].(string); ok && id != "" {
+			cliSessionID = id
+		}
 		// Try session_dir first, fallback to working_dir for old sessions
 		if sd, exists := metadata["session_dir"]; exists {
 			if sdStr, ok := sd.(string); ok {
@@ -1082,27 +1585,62 @@ func (cs *ClaudeService) ResumeSession(sessionID, userID string) (*Process, erro
 		}
 	}
 
-	// Create Claude process with --resume argument, including upload directory if available
-	dirs := []string{sessionDir}
+	// Create Claude process with --resume argument, including upload directory read-only if available
+	dirs := []Directory{ReadWriteDir(sessionDir)}
 	if uploadDir != "" {
-		dirs = append(dirs, uploadDir)
+		dirs = append(dirs, ReadOnlyDir(uploadDir))
 		if cs.debug {
 			slog.Debug("Including upload directory in resumed session",
 				"session_id", sessionID,
 				"upload_dir", uploadDir)
 		}
 	}
+	dirs = append(dirs, options.dirs...)
 	
-	process, err := cs.createResumedProcessWithDirs(sessionID, dirs)
+	for _, opt := range append(opts, WithUser(userID)) {
+		opt(&options)
+	}
+	// A long session that was summarized continues from its summary in a new conversation
+	summaryPending := false
+	if dbSession.Metadata != nil {
+		summaryPending, _ = dbSession.Metadata.Data["summary_pending"].(bool)
+	}
+	process, err := cs.service.schedule(options, func() (*Process, error) {
+		if summaryPending {
+			return cs.startFromSummary(&dbSession, dirs, options)
+		}
+		process, err := cs.createResumedProcessWithDirs(cliSessionID, dirs, options)
+		if errors.Is(err, ErrResumeFailed) {
+			return cs.replaySession(&dbSession, dirs, options, err)
+		}
+		return process, err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to resume Claude process: %w", err)
 	}
 
+	started := events.SessionStarted{
+		SessionID:  sessionID,
+		UserID:     userID,
+		WorkingDir: sessionDir,
+		Resumed:    true,
+	}
+	if dbSession.Metadata != nil {
+		started.ThreadTS, _ = dbSession.Metadata.Data["thread_ts"].(string)
+		started.ChannelID, _ = dbSession.Metadata.Data["channel_id"].(string)
+	}
+	cs.sessionStarted(process, started)
+
 	// Update session metadata to mark as resumed
 	if dbSession.Metadata != nil {
 		metadata := dbSession.Metadata.Data
 		metadata["resumed_at"] = time.Now().Format(time.RFC3339)
 		metadata["last_activity"] = time.Now().Format(time.RFC3339)
+		// A replayed session continues in a new CLI conversation, which is resumed from now on
+		metadata["claude_session_id"] = process.sessionID
+		if process.Summarized() {
+			metadata["summary_pending"] = false
+		}
 	}
 
 	if err := cs.db.Save(&dbSession).Error; err != nil {
@@ -1121,14 +1659,17 @@ func (cs *ClaudeService) ResumeSession(sessionID, userID string) (*Process, erro
 
 // createResumedProcess creates a Claude process with --resume argument (single directory)
 func (cs *ClaudeService) createResumedProcess(sessionID, workingDir string) (*Process, error) {
-	return cs.createResumedProcessWithDirs(sessionID, []string{workingDir})
+	return cs.createResumedProcessWithDirs(sessionID, []Directory{ReadWriteDir(workingDir)}, SessionOptions{})
 }
 
 // createResumedProcessWithDirs creates a Claude process with --resume argument (multiple directories)
-func (cs *ClaudeService) createResumedProcessWithDirs(sessionID string, dirs []string) (*Process, error) {
+func (cs *ClaudeService) createResumedProcessWithDirs(sessionID string, dirs []Directory, options SessionOptions) (*Process, error) {
 	startTime := time.Now()
 	correlationID := uuid.New().String()
 
+	// The conversation can go on without a directory that has since gone away
+	dirs = usableDirectories(dirs, sessionID)
+
 	slog.Info("Resuming Claude CLI session",
 		"session_id", sessionID,
 		"correlation_id", correlationID,
@@ -1165,16 +1706,11 @@ func (cs *ClaudeService) createResumedProcessWithDirs(sessionID string, dirs []s
 		"--input-format", "stream-json",
 		"--output-format", "stream-json",
 		"--verbose",
-		"--allowedTools", strings.Join(cs.config.Tools, ","),
+		"--include-partial-messages",
 		"--resume", sessionID, // Key argument for resumption
 	}
-
-	// Add all directories that are not empty
-	for _, dir := range dirs {
-		if dir != "" {
-			args = append(args, "--add-dir", dir)
-		}
-	}
+	args = append(args, options.cliArgs(cs.config, dirs)...)
+	args = append(args, directoryArgs(dirs)...)
 
 	if cs.debug {
 		slog.Debug("Claude CLI resume command prepared",
@@ -1186,7 +1722,11 @@ func (cs *ClaudeService) createResumedProcessWithDirs(sessionID string, dirs []s
 		)
 	}
 
-	cmd := exec.CommandContext(ctx, "claude", args...)
+	cmd, err := sandboxCommand(ctx, cs.config.Sandbox, "", dirs, args)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to prepare Claude sandbox: %w", err)
+	}
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -1214,6 +1754,7 @@ func (cs *ClaudeService) createResumedProcessWithDirs(sessionID string, dirs []s
 	process := &Process{
 		sessionID:     sessionID, // Use the existing session ID
 		cmd:           cmd,
+		dirs:          dirs,
 		stdin:         stdin,
 		stdout:        stdout,
 		stderr:        stderr,
@@ -1227,31 +1768,37 @@ func (cs *ClaudeService) createResumedProcessWithDirs(sessionID string, dirs []s
 		stdinLogFile:  stdinLogFile,
 		stdoutLogFile: stdoutLogFile,
 		stderrLogFile: stderrLogFile,
-		isHealthy:     true,
-		lastHeartbeat: time.Now(),
 		inputChan:     make(chan Input, 10),
 		outputChan:    make(chan Message, 10),
 		initComplete:  make(chan bool, 1),
-		errorChan:     make(chan Message, 10),
+		errorChan:     make(chan *SessionError, 10),
+		controlChan:   make(chan controlRequest, 1),
+	}
+	process.isHealthy.Store(true)
+	process.heartbeat()
+	if model := options.model(cs.config); model != "" {
+		process.model.Store(&model)
 	}
+	process.budget = options.sessionBudget(cs.config)
 
 	// Start monitoring and handlers
 	go cs.service.monitorStderr(process)
 	go cs.service.handleStdout(process)
 	go cs.service.handleStdin(process)
 
-	// For resumed sessions, we don't send an initial message
-	// The session should already be initialized
+	// A resumed CLI prints nothing until it's sent a prompt, so one still running
+	// after the grace period has loaded the conversation. One that exits first,
+	// closing initComplete, couldn't, typically because its saved state is gone.
 	select {
 	case <-process.initComplete:
+		cancel()
+		process.closeDebugFiles()
+		return nil, fmt.Errorf("session %s: %w", sessionID, ErrResumeFailed)
+	case <-time.After(resumeGracePeriod):
 		slog.Info("Resumed Claude session initialized",
 			"correlation_id", correlationID,
 			"session_id", sessionID,
 			"action", "resumed_session_ready")
-	case <-time.After(10 * time.Second):
-		cancel()
-		process.closeDebugFiles()
-		return nil, fmt.Errorf("timeout waiting for resumed Claude session initialization")
 	case <-ctx.Done():
 		process.closeDebugFiles()
 		return nil, fmt.Errorf("context cancelled during resumed session initialization")
@@ -1260,6 +1807,7 @@ func (cs *ClaudeService) createResumedProcessWithDirs(sessionID string, dirs []s
 	// Add to active sessions
 	cs.service.mu.Lock()
 	cs.service.sessions[sessionID] = process
+	metrics.ClaudeSessionsActive.Set(float64(len(cs.service.sessions)))
 	cs.service.mu.Unlock()
 
 	slog.Info("Claude session resumed successfully",
@@ -1333,10 +1881,8 @@ func (cs *ClaudeService) GetSessionInfo(threadTS, userID string) (*SessionInfo,
 		}
 	}
 
-	// Check if process exists in memory
-	cs.service.mu.RLock()
-	_, processExists := cs.service.sessions[dbSession.SessionID]
-	cs.service.mu.RUnlock()
+	// Check if process exists in memory; it won't if the session was hibernated
+	_, processExists := cs.service.getProcess(dbSession.SessionID)
 
 	return &SessionInfo{
 		SessionID:     dbSession.SessionID,
@@ -1408,6 +1954,49 @@ func (cs *ClaudeService) SendMessage(process *Process, text string) error {
 	return cs.service.SendMessage(process, text)
 }
 
+// GetProcess returns the running Claude process for a session, if any
+func (cs *ClaudeService) GetProcess(sessionID string) (*Process, bool) {
+	return cs.service.getProcess(sessionID)
+}
+
+// TurnLock returns the lock a caller holds from sending a session a message
+// until it has read Claude's response. The process has a single output
+// channel, so only the turn's holder may read it.
+func (cs *ClaudeService) TurnLock(sessionID string) *sync.Mutex {
+	lock, _ := cs.turns.LoadOrStore(sessionID, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// CancelTurn interrupts the turn Claude is working on in a session, keeping the session's context
+func (cs *ClaudeService) CancelTurn(sessionID string) error {
+	return cs.service.CancelTurn(sessionID)
+}
+
+// ContinueSession gives a session paused by its budget a fresh one
+func (cs *ClaudeService) ContinueSession(sessionID string) error {
+	return cs.service.ContinueSession(sessionID)
+}
+
+// ApproveToolUse lets a Bash command held for approval run
+func (cs *ClaudeService) ApproveToolUse(sessionID, toolUseID string) error {
+	return cs.service.ApproveToolUse(sessionID, toolUseID)
+}
+
+// DenyToolUse refuses a Bash command held for approval
+func (cs *ClaudeService) DenyToolUse(sessionID, toolUseID string) error {
+	return cs.service.DenyToolUse(sessionID, toolUseID)
+}
+
+// Errors returns the failures a Claude process reports
+func (cs *ClaudeService) Errors(process *Process) <-chan *SessionError {
+	return cs.service.Errors(process)
+}
+
+// Query runs a single print mode prompt without keeping a Claude process, see Service.Query
+func (cs *ClaudeService) Query(ctx context.Context, prompt string, opts ...SessionOption) (string, error) {
+	return cs.service.Query(ctx, prompt, opts...)
+}
+
 // ReceiveMessages returns the output channel for a Claude process
 func (cs *ClaudeService) ReceiveMessages(process *Process) <-chan Message {
 	return cs.service.ReceiveMessages(process)
@@ -1427,11 +2016,11 @@ func (cs *ClaudeService) StopSession(sessionID string) {
 }
 
 // CreateGitSessionWithPersistence creates a new Claude session with git repository integration
-func (cs *ClaudeService) CreateGitSessionWithPersistence(threadTS, channelID, userID, repoPath, baseBranch string) (*Process, *SessionInfo, *GitSessionInfo, error) {
-	return cs.CreateGitSessionWithPersistenceAndConfig(threadTS, channelID, userID, repoPath, baseBranch, "")
+func (cs *ClaudeService) CreateGitSessionWithPersistence(threadTS, channelID, userID, repoPath, baseBranch string, opts ...SessionOption) (*Process, *SessionInfo, *GitSessionInfo, error) {
+	return cs.CreateGitSessionWithPersistenceAndConfig(threadTS, channelID, userID, repoPath, baseBranch, "", opts...)
 }
 
-func (cs *ClaudeService) CreateGitSessionWithPersistenceAndConfig(threadTS, channelID, userID, repoPath, baseBranch, configID string) (*Process, *SessionInfo, *GitSessionInfo, error) {
+func (cs *ClaudeService) CreateGitSessionWithPersistenceAndConfig(threadTS, channelID, userID, repoPath, baseBranch, configID string, opts ...SessionOption) (*Process, *SessionInfo, *GitSessionInfo, error) {
 	// Validate repository path
 	if repoPath == "" {
 		return nil, nil, nil, fmt.Errorf("repository path is required")
@@ -1468,9 +2057,9 @@ func (cs *ClaudeService) CreateGitSessionWithPersistenceAndConfig(threadTS, chan
 		// Continue without CLAUDE.md - not critical
 	}
 	
-	// Prepare directories - use worktree as primary, include upload directory for this thread
+	// Prepare directories - use worktree as primary, include the thread's uploads read-only
 	uploadDir := filepath.Join("./data", "slack-uploads", threadTS)
-	dirs := []string{worktreePath, uploadDir}
+	dirs := []Directory{ReadWriteDir(worktreePath), ReadOnlyDir(uploadDir)}
 	
 	// Create upload directory if it doesn't exist
 	if err := os.MkdirAll(uploadDir, 0755); err != nil {
@@ -1479,17 +2068,25 @@ func (cs *ClaudeService) CreateGitSessionWithPersistenceAndConfig(threadTS, chan
 			"error", err,
 			"thread_ts", threadTS)
 		// Continue without upload directory, but keep worktree as primary
-		dirs = []string{worktreePath}
+		dirs = dirs[:1]
 	}
 
 	// Create the Claude process using the underlying service with multiple directories
-	process, err := cs.service.CreateSessionWithMultipleDirs(dirs)
+	process, err := cs.service.CreateSessionWithDirectories(dirs, opts...)
 	if err != nil {
 		// Clean up worktree if process creation fails
 		cs.gitService.RemoveWorktree(repoPath, worktreePath)
 		return nil, nil, nil, fmt.Errorf("failed to create Claude process: %w", err)
 	}
 
+	cs.sessionStarted(process, events.SessionStarted{
+		SessionID:  sessionID,
+		UserID:     userID,
+		ThreadTS:   threadTS,
+		ChannelID:  channelID,
+		WorkingDir: worktreePath,
+	})
+
 	// Create session info
 	sessionInfo := &SessionInfo{
 		SessionID:     sessionID,
@@ -1512,6 +2109,23 @@ func (cs *ClaudeService) CreateGitSessionWithPersistenceAndConfig(threadTS, chan
 	}
 
 	// Persist session to database
+	metadata := map[string]interface{}{
+		"thread_ts":         threadTS,
+		"channel_id":        channelID,
+		"working_dir":       worktreePath,
+		"session_dir":       worktreePath,
+		"upload_dir":        uploadDir,
+		"claude_session_id": process.sessionID,
+		"created_via":       "git_session",
+		"last_activity":     time.Now().Format(time.RFC3339),
+		"active":            true,
+		"git_enabled":       true,
+		"repository_path":   repoPath,
+		"worktree_path":     worktreePath,
+		"branch_name":       branchName,
+		"base_branch":       baseBranch,
+	}
+	maps.Copy(metadata, newSessionOptions(opts).metadata())
 	dbSession := &models.ClaudeSession{
 		Model: models.Model{
 			ID:        uuid.NewString(),
@@ -1522,21 +2136,7 @@ func (cs *ClaudeService) CreateGitSessionWithPersistenceAndConfig(threadTS, chan
 		UserID:    userID,
 		Title:     fmt.Sprintf("Git Session %s", threadTS),
 		Messages:  models.JSONField[interface{}]{Data: []interface{}{}},
-		Metadata: models.MakeJSONField(map[string]interface{}{
-			"thread_ts":       threadTS,
-			"channel_id":      channelID,
-			"working_dir":     worktreePath,
-			"session_dir":     worktreePath,
-			"upload_dir":      uploadDir,
-			"created_via":     "git_session",
-			"last_activity":   time.Now().Format(time.RFC3339),
-			"active":          true,
-			"git_enabled":     true,
-			"repository_path": repoPath,
-			"worktree_path":   worktreePath,
-			"branch_name":     branchName,
-			"base_branch":     baseBranch,
-		}),
+		Metadata:  models.MakeJSONField(metadata),
 	}
 
 	if err := cs.db.Create(dbSession).Error; err != nil {
@@ -0,0 +1,136 @@
+package claude
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/breadchris/flow/metrics"
+)
+
+// Kinds of failure a Claude session reports on its error channel. Callers
+// branch on them with errors.Is.
+var (
+	ErrAuth        = errors.New("claude authentication failed")
+	ErrRateLimited = errors.New("claude is rate limited")
+	ErrToolDenied  = errors.New("claude was denied a tool")
+	ErrCrash       = errors.New("claude process crashed")
+	ErrTimeout     = errors.New("claude request timed out")
+)
+
+// SessionError is a failure reported by a Claude session. It wraps one of the
+// error kinds above, or none when the failure wasn't recognized.
+type SessionError struct {
+	Kind      error
+	SessionID string
+	Detail    string // CLI output the error was recognized from
+}
+
+func (e *SessionError) Error() string {
+	kind := "claude error"
+	if e.Kind != nil {
+		kind = e.Kind.Error()
+	}
+	if e.Detail == "" {
+		return kind
+	}
+	return kind + ": " + e.Detail
+}
+
+func (e *SessionError) Unwrap() error {
+	return e.Kind
+}
+
+// UserMessage explains the error to an end user
+func (e *SessionError) UserMessage() string {
+	switch e.Kind {
+	case ErrAuth:
+		return "Claude couldn't authenticate. An administrator needs to check the Claude CLI login or API key."
+	case ErrRateLimited:
+		return "Claude is rate limited right now. Please wait a minute and try again."
+	case ErrToolDenied:
+		return "Claude tried to use a tool this session isn't allowed to use."
+	case ErrCrash:
+		return "The Claude process stopped unexpectedly. Please try again."
+	case ErrTimeout:
+		return "Request timed out. Please try again or simplify your request."
+	}
+	return "An error occurred while processing your request. Please try again."
+}
+
+// errorKindLabel names an error kind for metrics
+func errorKindLabel(kind error) string {
+	switch kind {
+	case ErrAuth:
+		return "auth"
+	case ErrRateLimited:
+		return "rate_limited"
+	case ErrToolDenied:
+		return "tool_denied"
+	case ErrCrash:
+		return "crash"
+	case ErrTimeout:
+		return "timeout"
+	}
+	return "unknown"
+}
+
+// classifyError returns the kind of failure CLI output describes, or nil if it isn't recognized
+func classifyError(text string) error {
+	lower := strings.ToLower(text)
+	containsAny := func(markers ...string) bool {
+		for _, marker := range markers {
+			if strings.Contains(lower, marker) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case containsAny("invalid api key", "authentication", "unauthorized", "401", "/login", "not logged in"):
+		return ErrAuth
+	case isRateLimitError(text):
+		return ErrRateLimited
+	case containsAny("requested permissions to use", "haven't granted"):
+		return ErrToolDenied
+	case containsAny("timeout", "timed out", "etimedout"):
+		return ErrTimeout
+	case containsAny("uncaught", "fatal error", "out of memory", "segmentation fault"):
+		return ErrCrash
+	}
+	return nil
+}
+
+// messageErrors returns the failures reported by a message from the CLI:
+// a turn that ended in an error, or a tool call the session wasn't allowed to make
+func messageErrors(msg Message) []*SessionError {
+	if msg.Type == "result" && msg.IsError {
+		return []*SessionError{{Kind: classifyError(msg.Result), SessionID: msg.SessionID, Detail: msg.Result}}
+	}
+
+	var sessionErrors []*SessionError
+	if msg.Type == "user" {
+		for _, event := range ParseToolEvents(msg) {
+			if event.IsError && classifyError(event.Output) == ErrToolDenied {
+				sessionErrors = append(sessionErrors, &SessionError{Kind: ErrToolDenied, SessionID: msg.SessionID, Detail: event.Output})
+			}
+		}
+	}
+	return sessionErrors
+}
+
+// reportError sends err on the process's error channel without waiting for a reader
+func (p *Process) reportError(err *SessionError) {
+	metrics.ClaudeErrorsTotal.WithLabelValues(errorKindLabel(err.Kind)).Inc()
+	select {
+	case p.errorChan <- err:
+	default:
+		slog.Warn("Error channel full, dropping error",
+			"correlation_id", p.correlationID,
+			"session_id", p.sessionID,
+			"error", err,
+			"action", "error_dropped",
+		)
+	}
+}
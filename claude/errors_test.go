@@ -0,0 +1,58 @@
+package claude
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	for text, want := range map[string]error{
+		"Invalid API key · Please run /login":                                       ErrAuth,
+		"API Error: 429 {\"type\":\"rate_limit_error\"}":                            ErrRateLimited,
+		"Claude requested permissions to use Bash, but you haven't granted it yet.": ErrToolDenied,
+		"Error: Request timed out.":                                                 ErrTimeout,
+		"FATAL ERROR: Reached heap limit Allocation failed":                         ErrCrash,
+		"Warning: failed to read settings":                                          nil,
+	} {
+		assert.Equal(t, want, classifyError(text), text)
+	}
+}
+
+func TestSessionErrorWrapsKind(t *testing.T) {
+	var err error = &SessionError{Kind: ErrRateLimited, SessionID: "s1", Detail: "429"}
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.False(t, errors.Is(err, ErrAuth))
+	assert.Equal(t, "claude is rate limited: 429", err.Error())
+
+	var sessionErr *SessionError
+	require.ErrorAs(t, err, &sessionErr)
+	assert.Contains(t, sessionErr.UserMessage(), "rate limited")
+	assert.Equal(t, "claude error", (&SessionError{}).Error())
+}
+
+func TestMessageErrors(t *testing.T) {
+	errs := messageErrors(Message{Type: "result", IsError: true, Result: "Invalid API key", SessionID: "s1"})
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrAuth)
+	assert.Empty(t, messageErrors(Message{Type: "result", Result: "done"}))
+
+	denied := Message{Type: "user", Message: json.RawMessage(`{"role":"user","content":[` +
+		`{"type":"tool_result","tool_use_id":"t1","is_error":true,"content":"Claude requested permissions to use Bash, but you haven't granted it yet."},` +
+		`{"type":"tool_result","tool_use_id":"t2","is_error":true,"content":"exit status 1"}]}`)}
+	errs = messageErrors(denied)
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrToolDenied)
+}
+
+func TestReportErrorDoesNotBlock(t *testing.T) {
+	process := &Process{errorChan: make(chan *SessionError, 1)}
+	process.reportError(&SessionError{Kind: ErrCrash})
+	process.reportError(&SessionError{Kind: ErrTimeout})
+
+	assert.ErrorIs(t, <-NewService(Config{}).Errors(process), ErrCrash)
+	assert.Empty(t, process.errorChan)
+}
@@ -0,0 +1,382 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/deps"
+	"github.com/breadchris/flow/models"
+	"github.com/breadchris/flow/session"
+)
+
+// ExportFormat is the file format of an exported session transcript
+type ExportFormat string
+
+const (
+	ExportMarkdown ExportFormat = "markdown"
+	ExportJSON     ExportFormat = "json"
+)
+
+// maxExportToolOutput limits how much of each tool result a Markdown export shows
+const maxExportToolOutput = 4000
+
+// ParseExportFormat accepts "markdown", "md", or "json", defaulting to Markdown when empty
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "md", "markdown":
+		return ExportMarkdown, nil
+	case "json":
+		return ExportJSON, nil
+	}
+	return "", fmt.Errorf("unknown export format %q, expected markdown or json", s)
+}
+
+// ContentType returns the MIME type of the format
+func (f ExportFormat) ContentType() string {
+	if f == ExportJSON {
+		return "application/json"
+	}
+	return "text/markdown; charset=utf-8"
+}
+
+// Filename returns the name to give a session's exported transcript
+func (f ExportFormat) Filename(sessionID string) string {
+	ext := "md"
+	if f == ExportJSON {
+		ext = "json"
+	}
+	return fmt.Sprintf("claude-session-%s.%s", sessionID, ext)
+}
+
+// SessionExport is the JSON form of an exported session
+type SessionExport struct {
+	SessionID string            `json:"session_id"`
+	UserID    string            `json:"user_id"`
+	Title     string            `json:"title,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	Entries   []TranscriptEntry `json:"entries"`
+}
+
+// Kinds of transcript entries
+const (
+	EntryPrompt     = "prompt"
+	EntryResponse   = "response"
+	EntryToolCall   = "tool_call"
+	EntryToolResult = "tool_result"
+	EntryTurnEnd    = "turn_end"
+)
+
+// TranscriptEntry is one step of a session: a prompt, a piece of Claude's
+// reply, a tool call or its result, or the end of a turn
+type TranscriptEntry struct {
+	Kind      string          `json:"kind"`
+	Text      string          `json:"text,omitempty"`
+	Tool      string          `json:"tool,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	FilePath  string          `json:"file_path,omitempty"`
+	Diff      string          `json:"diff,omitempty"` // Change made by a file editing tool call
+	IsError   bool            `json:"is_error,omitempty"`
+	CostUSD   float64         `json:"cost_usd,omitempty"`
+}
+
+// contentBlock is one element of a Claude message's content array
+type contentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text"`
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+	ToolUseID string          `json:"tool_use_id"`
+	Content   json.RawMessage `json:"content"`
+	IsError   bool            `json:"is_error"`
+}
+
+// ExportSession renders a session's saved transcript as Markdown or JSON
+func (cs *ClaudeService) ExportSession(sessionID string, format ExportFormat) ([]byte, error) {
+	var session models.ClaudeSession
+	if err := cs.db.Where("session_id = ?", sessionID).First(&session).Error; err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	return renderExport(&session, format)
+}
+
+// renderExport renders a loaded session's transcript in format
+func renderExport(session *models.ClaudeSession, format ExportFormat) ([]byte, error) {
+	export, err := newSessionExport(session)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case ExportJSON:
+		return json.MarshalIndent(export, "", "  ")
+	case ExportMarkdown:
+		return []byte(export.Markdown()), nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// newSessionExport converts the messages saved for a session into transcript entries
+func newSessionExport(session *models.ClaudeSession) (*SessionExport, error) {
+	data, err := json.Marshal(session.Messages.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transcript: %w", err)
+	}
+	var messages []Message
+	if string(data) != "null" {
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("failed to read transcript: %w", err)
+		}
+	}
+
+	export := &SessionExport{
+		SessionID: session.SessionID,
+		UserID:    session.UserID,
+		Title:     session.Title,
+		CreatedAt: session.CreatedAt,
+		Entries:   []TranscriptEntry{},
+	}
+	for _, msg := range messages {
+		export.Entries = append(export.Entries, transcriptEntries(msg)...)
+	}
+	return export, nil
+}
+
+// transcriptEntries converts a saved message into the entries it contains
+func transcriptEntries(msg Message) []TranscriptEntry {
+	if msg.Type == "result" {
+		return []TranscriptEntry{{Kind: EntryTurnEnd, Text: msg.Result, IsError: msg.IsError, CostUSD: msg.TotalCostUSD}}
+	}
+	if msg.Type != "user" && msg.Type != "assistant" {
+		return nil
+	}
+
+	var body struct {
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(msg.Message, &body); err != nil {
+		return nil
+	}
+
+	textKind := EntryResponse
+	if msg.Type == "user" {
+		textKind = EntryPrompt
+	}
+
+	// Prompts may carry their content as a plain string
+	var text string
+	if err := json.Unmarshal(body.Content, &text); err == nil {
+		return []TranscriptEntry{{Kind: textKind, Text: text}}
+	}
+
+	var blocks []contentBlock
+	if err := json.Unmarshal(body.Content, &blocks); err != nil {
+		return nil
+	}
+
+	var entries []TranscriptEntry
+	for _, block := range blocks {
+		switch block.Type {
+		case "text":
+			if block.Text != "" {
+				entries = append(entries, TranscriptEntry{Kind: textKind, Text: block.Text})
+			}
+		case "tool_use":
+			entry := TranscriptEntry{Kind: EntryToolCall, Tool: block.Name, ToolUseID: block.ID, Input: block.Input}
+			entry.FilePath, entry.Diff = toolDiff(block.Name, block.Input)
+			entries = append(entries, entry)
+		case "tool_result":
+			entries = append(entries, TranscriptEntry{
+				Kind:      EntryToolResult,
+				ToolUseID: block.ToolUseID,
+				Text:      toolResultText(block.Content),
+				IsError:   block.IsError,
+			})
+		}
+	}
+	return entries
+}
+
+// toolResultText flattens a tool result's content, which is a string or a list of text blocks
+func toolResultText(content json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(content, &text); err == nil {
+		return text
+	}
+
+	var blocks []contentBlock
+	if err := json.Unmarshal(content, &blocks); err != nil {
+		return string(content)
+	}
+	var parts []string
+	for _, block := range blocks {
+		if block.Type == "text" {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// toolDiff returns the file and a unified-style diff for calls to file editing tools
+func toolDiff(tool string, input json.RawMessage) (string, string) {
+	var args struct {
+		FilePath  string `json:"file_path"`
+		OldString string `json:"old_string"`
+		NewString string `json:"new_string"`
+		Content   string `json:"content"`
+		Edits     []struct {
+			OldString string `json:"old_string"`
+			NewString string `json:"new_string"`
+		} `json:"edits"`
+	}
+	if err := json.Unmarshal(input, &args); err != nil || args.FilePath == "" {
+		return "", ""
+	}
+
+	var diff strings.Builder
+	switch tool {
+	case "Edit":
+		fmt.Fprintf(&diff, "--- a/%s\n+++ b/%s\n", args.FilePath, args.FilePath)
+		writeDiffLines(&diff, args.OldString, args.NewString)
+	case "MultiEdit":
+		fmt.Fprintf(&diff, "--- a/%s\n+++ b/%s\n", args.FilePath, args.FilePath)
+		for _, edit := range args.Edits {
+			diff.WriteString("@@\n")
+			writeDiffLines(&diff, edit.OldString, edit.NewString)
+		}
+	case "Write":
+		fmt.Fprintf(&diff, "--- /dev/null\n+++ b/%s\n", args.FilePath)
+		writeDiffLines(&diff, "", args.Content)
+	default:
+		return args.FilePath, ""
+	}
+	return args.FilePath, diff.String()
+}
+
+// writeDiffLines writes before as removed lines followed by after as added lines
+func writeDiffLines(diff *strings.Builder, before, after string) {
+	for _, line := range splitLines(before) {
+		diff.WriteString("-" + line + "\n")
+	}
+	for _, line := range splitLines(after) {
+		diff.WriteString("+" + line + "\n")
+	}
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Markdown renders the export as a readable document
+func (e *SessionExport) Markdown() string {
+	var md strings.Builder
+
+	title := e.Title
+	if title == "" {
+		title = "Claude session"
+	}
+	fmt.Fprintf(&md, "# %s\n\n", title)
+	fmt.Fprintf(&md, "- **Session:** `%s`\n", e.SessionID)
+	fmt.Fprintf(&md, "- **User:** `%s`\n", e.UserID)
+	fmt.Fprintf(&md, "- **Started:** %s\n", e.CreatedAt.UTC().Format("2006-01-02 15:04 MST"))
+
+	lastKind := ""
+	for _, entry := range e.Entries {
+		switch entry.Kind {
+		case EntryPrompt:
+			fmt.Fprintf(&md, "\n## Prompt\n\n%s\n", entry.Text)
+		case EntryResponse:
+			if lastKind != EntryResponse {
+				md.WriteString("\n## Claude\n")
+			}
+			fmt.Fprintf(&md, "\n%s\n", entry.Text)
+		case EntryToolCall:
+			fmt.Fprintf(&md, "\n**Tool:** `%s`", entry.Tool)
+			if entry.FilePath != "" {
+				fmt.Fprintf(&md, " `%s`", entry.FilePath)
+			}
+			md.WriteString("\n\n")
+			if entry.Diff != "" {
+				md.WriteString(codeBlock("diff", entry.Diff))
+			} else if len(entry.Input) > 0 {
+				md.WriteString(codeBlock("json", string(entry.Input)))
+			}
+		case EntryToolResult:
+			label := "Result"
+			if entry.IsError {
+				label = "Error"
+			}
+			text := entry.Text
+			if len(text) > maxExportToolOutput {
+				text = text[:maxExportToolOutput] + "\n… (truncated)"
+			}
+			fmt.Fprintf(&md, "\n<details><summary>%s</summary>\n\n%s</details>\n", label, codeBlock("", text))
+		case EntryTurnEnd:
+			md.WriteString("\n---\n")
+			if entry.IsError {
+				md.WriteString("\n_Turn ended with an error_\n")
+			} else if entry.CostUSD > 0 {
+				fmt.Fprintf(&md, "\n_Turn cost $%.4f_\n", entry.CostUSD)
+			}
+		}
+		lastKind = entry.Kind
+	}
+	return md.String()
+}
+
+// codeBlock fences text, using a longer fence when the text contains one
+func codeBlock(lang, text string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + strings.TrimSuffix(text, "\n") + "\n" + fence + "\n"
+}
+
+// SessionExportHandler serves a session's transcript as a download
+type SessionExportHandler struct {
+	cs       *ClaudeService
+	sessions *session.SessionManager
+	config   config.AppConfig
+}
+
+// NewSessionExportHandler creates the handler for GET /api/sessions/{id}/export?format=markdown|json
+func NewSessionExportHandler(cs *ClaudeService, d deps.Deps) *SessionExportHandler {
+	return &SessionExportHandler{
+		cs:       cs,
+		sessions: d.Session,
+		config:   d.Config,
+	}
+}
+
+func (h *SessionExportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	format, err := ParseExportFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dbSession, _, ok := authorizeSession(h.cs, h.sessions, h.config, w, r)
+	if !ok {
+		return
+	}
+
+	data, err := renderExport(dbSession, format)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to export session: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", format.Filename(dbSession.SessionID)))
+	w.Write(data)
+}
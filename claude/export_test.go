@@ -0,0 +1,122 @@
+package claude
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestExportService(t *testing.T) *ClaudeService {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ClaudeSession{}))
+
+	require.NoError(t, db.Create(&models.ClaudeSession{
+		Model:     models.Model{ID: "model-1", CreatedAt: time.Date(2026, 1, 2, 15, 4, 0, 0, time.UTC)},
+		SessionID: "s1",
+		UserID:    "u1",
+		Title:     "Fix the parser",
+		Messages:  models.JSONField[interface{}]{Data: []interface{}{}},
+	}).Error)
+
+	service := NewService(Config{})
+	service.transcripts = NewTranscriptStore(db)
+	return &ClaudeService{service: service, db: db}
+}
+
+// transcriptTurn is one prompt where Claude edits a file and answers
+func transcriptTurn() []Message {
+	return []Message{
+		{Type: "user", Message: json.RawMessage(`{"role":"user","content":[{"type":"text","text":"Rename foo to bar"}]}`)},
+		{Type: "assistant", Message: json.RawMessage(`{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Edit","input":{"file_path":"main.go","old_string":"foo()\n","new_string":"bar()\n"}}]}`)},
+		{Type: "user", Message: json.RawMessage(`{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"File updated"}]}`)},
+		{Type: "assistant", Message: json.RawMessage(`{"role":"assistant","content":[{"type":"text","text":"Renamed foo to bar."}]}`)},
+		{Type: "result", Result: "Renamed foo to bar.", TotalCostUSD: 0.0125},
+	}
+}
+
+func TestTranscriptStoreAppends(t *testing.T) {
+	cs := newTestExportService(t)
+	turn := transcriptTurn()
+
+	require.NoError(t, cs.service.transcripts.Append("s1", turn[:2]))
+	require.NoError(t, cs.service.transcripts.Append("s1", turn[2:]))
+	// Sessions without a database record are skipped
+	require.NoError(t, cs.service.transcripts.Append("worklet", turn))
+
+	var session models.ClaudeSession
+	require.NoError(t, cs.db.Where("session_id = ?", "s1").First(&session).Error)
+	assert.Len(t, session.Messages.Data, len(turn))
+}
+
+func TestExportSessionJSON(t *testing.T) {
+	cs := newTestExportService(t)
+	require.NoError(t, cs.service.transcripts.Append("s1", transcriptTurn()))
+
+	data, err := cs.ExportSession("s1", ExportJSON)
+	require.NoError(t, err)
+
+	var export SessionExport
+	require.NoError(t, json.Unmarshal(data, &export))
+	assert.Equal(t, "s1", export.SessionID)
+	assert.Equal(t, "Fix the parser", export.Title)
+
+	var kinds []string
+	for _, entry := range export.Entries {
+		kinds = append(kinds, entry.Kind)
+	}
+	assert.Equal(t, []string{EntryPrompt, EntryToolCall, EntryToolResult, EntryResponse, EntryTurnEnd}, kinds)
+
+	edit := export.Entries[1]
+	assert.Equal(t, "Edit", edit.Tool)
+	assert.Equal(t, "main.go", edit.FilePath)
+	assert.Equal(t, "--- a/main.go\n+++ b/main.go\n-foo()\n+bar()\n", edit.Diff)
+	assert.Equal(t, "File updated", export.Entries[2].Text)
+	assert.Equal(t, 0.0125, export.Entries[4].CostUSD)
+}
+
+func TestExportSessionMarkdown(t *testing.T) {
+	cs := newTestExportService(t)
+	require.NoError(t, cs.service.transcripts.Append("s1", transcriptTurn()))
+
+	data, err := cs.ExportSession("s1", ExportMarkdown)
+	require.NoError(t, err)
+	md := string(data)
+
+	assert.Contains(t, md, "# Fix the parser\n")
+	assert.Contains(t, md, "- **Started:** 2026-01-02 15:04 UTC\n")
+	assert.Contains(t, md, "## Prompt\n\nRename foo to bar\n")
+	assert.Contains(t, md, "**Tool:** `Edit` `main.go`\n\n```diff\n--- a/main.go\n+++ b/main.go\n-foo()\n+bar()\n```\n")
+	assert.Contains(t, md, "<details><summary>Result</summary>\n\n```\nFile updated\n```\n</details>\n")
+	assert.Contains(t, md, "## Claude\n\nRenamed foo to bar.\n")
+	assert.Contains(t, md, "_Turn cost $0.0125_")
+}
+
+func TestExportSessionNotFound(t *testing.T) {
+	cs := newTestExportService(t)
+	_, err := cs.ExportSession("missing", ExportMarkdown)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestParseExportFormat(t *testing.T) {
+	for input, want := range map[string]ExportFormat{"": ExportMarkdown, "md": ExportMarkdown, "Markdown": ExportMarkdown, "json": ExportJSON} {
+		format, err := ParseExportFormat(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, format, input)
+	}
+	_, err := ParseExportFormat("pdf")
+	assert.Error(t, err)
+
+	assert.Equal(t, "claude-session-s1.md", ExportMarkdown.Filename("s1"))
+	assert.Equal(t, "claude-session-s1.json", ExportJSON.Filename("s1"))
+}
+
+func TestCodeBlockUsesLongerFence(t *testing.T) {
+	assert.Equal(t, "````md\n```go\nx\n```\n````\n", codeBlock("md", "```go\nx\n```"))
+}
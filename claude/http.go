@@ -373,6 +373,34 @@ func (cs *ClaudeService) HandleWebSocket(conn *websocket.Conn, userID string) {
 				continue
 			}
 
+		case "continue":
+			// Lift a budget pause so the session takes prompts again
+			if sessionID == "" {
+				errorMsg := WSMessage{
+					Type:      "error",
+					Payload:   json.RawMessage(`{"error": "No active session"}`),
+					Timestamp: time.Now().UnixMilli(),
+				}
+				conn.WriteJSON(errorMsg)
+				continue
+			}
+			if err := cs.ContinueSession(sessionID); err != nil {
+				errorMsg := WSMessage{
+					Type:      "error",
+					Payload:   json.RawMessage(fmt.Sprintf(`{"error": "Failed to continue session: %v"}`, err)),
+					Timestamp: time.Now().UnixMilli(),
+				}
+				conn.WriteJSON(errorMsg)
+				continue
+			}
+
+			continueMsg := WSMessage{
+				Type:      "message",
+				Payload:   json.RawMessage(`{"type": "system", "subtype": "budget_continued", "message": "Session budget reset"}`),
+				Timestamp: time.Now().UnixMilli(),
+			}
+			conn.WriteJSON(continueMsg)
+
 		case "stop":
 			// Stop the active session
 			if activeProcess != nil && sessionID != "" {
@@ -532,8 +560,30 @@ func (cs *ClaudeService) HandleWebSocket(conn *websocket.Conn, userID string) {
 // forwardClaudeMessages forwards messages from Claude process to WebSocket
 func (cs *ClaudeService) forwardClaudeMessages(conn *websocket.Conn, process *Process) {
 	messageChan := cs.ReceiveMessages(process)
+	errorChan := cs.Errors(process)
+	output := cs.Output(OutputHTTP)
+
+	for {
+		var message Message
+		select {
+		case sessionErr := <-errorChan:
+			payload, _ := json.Marshal(map[string]string{
+				"error":  sessionErr.UserMessage(),
+				"kind":   errorKindLabel(sessionErr.Kind),
+				"detail": sessionErr.Detail,
+			})
+			if err := conn.WriteJSON(WSMessage{Type: "error", Payload: payload, Timestamp: time.Now().UnixMilli()}); err != nil {
+				slog.Error("Failed to send WebSocket message", "error", err)
+				return
+			}
+			continue
+		case msg, ok := <-messageChan:
+			if !ok {
+				return
+			}
+			message = output.Apply(msg)
+		}
 
-	for message := range messageChan {
 		// Convert Claude message to WebSocket message
 		claudeMsg := ClaudeMessage{
 			Type:      message.Type,
@@ -559,7 +609,7 @@ func (cs *ClaudeService) forwardClaudeMessages(conn *websocket.Conn, process *Pr
 
 		if err := conn.WriteJSON(wsMsg); err != nil {
 			slog.Error("Failed to send WebSocket message", "error", err)
-			break
+			return
 		}
 	}
 }
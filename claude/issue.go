@@ -0,0 +1,90 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/breadchris/flow/models"
+)
+
+// issueInstructions asks Claude to turn a conversation into a GitHub issue
+const issueInstructions = `Turn the conversation below between a user and an AI coding assistant into a GitHub issue for the team to pick up. Reply with only a JSON object with these fields:
+- "title": a short issue title, under 80 characters
+- "summary": what the problem or request is and what was learned about it, in a few sentences of Markdown
+- "repro_steps": the steps that reproduce the problem, as an array of strings; empty when there's nothing to reproduce
+- "suggested_fix": the fix the conversation points to, in Markdown, or "" when there isn't one`
+
+// IssueDraft is a GitHub issue written from a session's conversation
+type IssueDraft struct {
+	Title        string   `json:"title"`
+	Summary      string   `json:"summary"`
+	ReproSteps   []string `json:"repro_steps"`
+	SuggestedFix string   `json:"suggested_fix"`
+}
+
+// DraftIssue asks Claude to write a GitHub issue from a session's conversation
+func (cs *ClaudeService) DraftIssue(ctx context.Context, sessionID string) (*IssueDraft, error) {
+	var dbSession models.ClaudeSession
+	if err := cs.db.Where("session_id = ?", sessionID).First(&dbSession).Error; err != nil {
+		return nil, fmt.Errorf("failed to find session: %w", err)
+	}
+	export, err := newSessionExport(&dbSession)
+	if err != nil {
+		return nil, err
+	}
+	if len(export.Entries) == 0 {
+		return nil, fmt.Errorf("failed to draft issue: the session has no conversation yet")
+	}
+
+	var metadata map[string]interface{}
+	if dbSession.Metadata != nil {
+		metadata = dbSession.Metadata.Data
+	}
+	prompt := issueInstructions + "\n\n" + conversationSince(metadata, export.Entries, summaryInputChars)
+	reply, err := cs.Query(ctx, prompt, WithUser(dbSession.UserID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to draft issue: %w", err)
+	}
+	return parseIssueDraft(reply)
+}
+
+// parseIssueDraft reads Claude's JSON reply, which may be wrapped in a code fence
+func parseIssueDraft(reply string) (*IssueDraft, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("failed to draft issue: Claude's reply has no JSON object")
+	}
+	var draft IssueDraft
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &draft); err != nil {
+		return nil, fmt.Errorf("failed to parse issue draft: %w", err)
+	}
+	draft.Title = strings.TrimSpace(draft.Title)
+	if draft.Title == "" {
+		return nil, fmt.Errorf("failed to draft issue: Claude returned no title")
+	}
+	return &draft, nil
+}
+
+// Markdown renders the issue's body, leaving out sections with nothing in them
+func (d *IssueDraft) Markdown() string {
+	var body strings.Builder
+	fmt.Fprintf(&body, "## Summary\n\n%s\n", strings.TrimSpace(d.Summary))
+	var steps []string
+	for _, step := range d.ReproSteps {
+		if step = strings.TrimSpace(step); step != "" {
+			steps = append(steps, step)
+		}
+	}
+	if len(steps) > 0 {
+		body.WriteString("\n## Steps to Reproduce\n\n")
+		for i, step := range steps {
+			fmt.Fprintf(&body, "%d. %s\n", i+1, step)
+		}
+	}
+	if fix := strings.TrimSpace(d.SuggestedFix); fix != "" {
+		fmt.Fprintf(&body, "\n## Suggested Fix\n\n%s\n", fix)
+	}
+	return body.String()
+}
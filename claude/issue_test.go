@@ -0,0 +1,47 @@
+package claude
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIssueDraft(t *testing.T) {
+	reply := "Here's the issue:\n```json\n" +
+		`{"title":" Login fails with long passwords ","summary":"Passwords over 72 bytes are cut off by bcrypt.","repro_steps":["Sign up with a 100 character password","Log in with it"],"suggested_fix":"Reject passwords over 72 bytes."}` +
+		"\n```"
+	draft, err := parseIssueDraft(reply)
+	require.NoError(t, err)
+	assert.Equal(t, "Login fails with long passwords", draft.Title)
+	assert.Equal(t, []string{"Sign up with a 100 character password", "Log in with it"}, draft.ReproSteps)
+
+	_, err = parseIssueDraft("I couldn't write an issue.")
+	assert.Error(t, err)
+	_, err = parseIssueDraft(`{"title":"","summary":"No title"}`)
+	assert.Error(t, err)
+}
+
+func TestIssueDraftMarkdown(t *testing.T) {
+	draft := &IssueDraft{
+		Summary:      "Passwords over 72 bytes are cut off.",
+		ReproSteps:   []string{"Sign up", " ", "Log in"},
+		SuggestedFix: "Reject long passwords.",
+	}
+	assert.Equal(t, "## Summary\n\nPasswords over 72 bytes are cut off.\n"+
+		"\n## Steps to Reproduce\n\n1. Sign up\n2. Log in\n"+
+		"\n## Suggested Fix\n\nReject long passwords.\n", draft.Markdown())
+
+	draft = &IssueDraft{Summary: "Add dark mode."}
+	assert.Equal(t, "## Summary\n\nAdd dark mode.\n", draft.Markdown(), "empty sections are left out")
+}
+
+func TestDraftIssueNeedsConversation(t *testing.T) {
+	cs := newTestExportService(t)
+	_, err := cs.DraftIssue(context.Background(), "s1")
+	assert.ErrorContains(t, err, "no conversation")
+
+	_, err = cs.DraftIssue(context.Background(), "missing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,327 @@
+package claude
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/metrics"
+)
+
+// Reasons a session directory is archived, used as metric labels
+const (
+	archiveReasonExpired    = "expired"
+	archiveReasonTotalQuota = "total_quota"
+)
+
+// janitor keeps session directories within their disk quotas, archiving
+// expired ones and deleting old archives
+type janitor struct {
+	config config.ClaudeStorageConfig
+	root   string                   // Directory holding one directory per session
+	active func() map[string]func() // Directories of running sessions, each with a function that stops its process
+}
+
+func newJanitor(config config.ClaudeStorageConfig, root string, active func() map[string]func()) *janitor {
+	return &janitor{config: config, root: root, active: active}
+}
+
+// sessionDirUsage is the disk use of one session directory
+type sessionDirUsage struct {
+	name     string
+	path     string
+	bytes    int64
+	modified time.Time // Newest change to anything in the directory
+	running  bool
+}
+
+// RunJanitor sweeps session directories every storage interval until ctx is
+// cancelled. It returns immediately when disabled.
+func (cs *ClaudeService) RunJanitor(ctx context.Context) {
+	if cs.janitor == nil || cs.janitor.config.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(cs.janitor.config.Interval)
+	defer ticker.Stop()
+
+	slog.Info("Claude session janitor started",
+		"interval", cs.janitor.config.Interval,
+		"session_quota_mb", cs.janitor.config.SessionQuotaMB,
+		"total_quota_mb", cs.janitor.config.TotalQuotaMB,
+		"expire_after", cs.janitor.config.ExpireAfter)
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Claude session janitor stopped")
+			return
+		case <-ticker.C:
+			if err := cs.janitor.sweep(time.Now()); err != nil {
+				slog.Error("Claude session janitor sweep failed", "error", err)
+			}
+		}
+	}
+}
+
+// runningSessionDirs returns the directories of running processes, each with
+// a function that hibernates the process using it
+func (cs *ClaudeService) runningSessionDirs() map[string]func() {
+	cs.service.mu.RLock()
+	defer cs.service.mu.RUnlock()
+
+	dirs := make(map[string]func())
+	for key, process := range cs.service.sessions {
+		for _, dir := range process.dirs {
+			dirs[dir.Path] = func() { cs.hibernate(key, process) }
+		}
+	}
+	return dirs
+}
+
+// sweep enforces the quotas, archives expired directories, and deletes old archives
+func (j *janitor) sweep(now time.Time) error {
+	dirs, err := j.usage()
+	if err != nil {
+		return err
+	}
+	var stops map[string]func()
+	if j.active != nil {
+		stops = j.active()
+	}
+
+	var total int64
+	for i := range dirs {
+		_, dirs[i].running = stops[dirs[i].path]
+		total += dirs[i].bytes
+	}
+
+	// A running session can't be archived, so one over its quota is stopped
+	// instead; it can be resumed, and is stopped again while still over
+	if quota := j.config.SessionQuotaMB * megabyte; quota > 0 {
+		for _, dir := range dirs {
+			if dir.bytes <= quota {
+				continue
+			}
+			metrics.ClaudeSessionQuotaExceededTotal.Inc()
+			slog.Warn("Claude session directory is over its quota",
+				"session_id", dir.name,
+				"bytes", dir.bytes,
+				"quota_mb", j.config.SessionQuotaMB,
+				"running", dir.running,
+				"action", "session_quota_exceeded",
+			)
+			if dir.running {
+				stops[dir.path]()
+			}
+		}
+	}
+
+	// Least recently changed directories go first, both when expiring them and
+	// when making room under the total quota
+	sort.Slice(dirs, func(a, b int) bool { return dirs[a].modified.Before(dirs[b].modified) })
+	quota := j.config.TotalQuotaMB * megabyte
+	for _, dir := range dirs {
+		if dir.running {
+			continue
+		}
+		reason := ""
+		switch {
+		case j.config.ExpireAfter > 0 && now.Sub(dir.modified) > j.config.ExpireAfter:
+			reason = archiveReasonExpired
+		case quota > 0 && total > quota:
+			reason = archiveReasonTotalQuota
+		default:
+			continue
+		}
+
+		if err := j.archive(dir, now); err != nil {
+			slog.Error("Failed to archive Claude session directory", "session_id", dir.name, "error", err)
+			continue
+		}
+		total -= dir.bytes
+		metrics.ClaudeSessionDirsArchivedTotal.WithLabelValues(reason).Inc()
+		slog.Info("Archived Claude session directory",
+			"session_id", dir.name,
+			"bytes", dir.bytes,
+			"reason", reason,
+			"action", "session_dir_archived",
+		)
+	}
+	metrics.ClaudeSessionStorageBytes.Set(float64(total))
+
+	return j.deleteOldArchives(now)
+}
+
+// usage measures each session directory under the root
+func (j *janitor) usage() ([]sessionDirUsage, error) {
+	entries, err := os.ReadDir(j.root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list session directories: %w", err)
+	}
+	root, err := filepath.Abs(j.root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve session directories: %w", err)
+	}
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		root = real
+	}
+
+	var dirs []sessionDirUsage
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := sessionDirUsage{name: entry.Name(), path: filepath.Join(root, entry.Name())}
+		// Symlinks, such as a warm session's link to its uploads, aren't followed
+		err := filepath.WalkDir(dir.path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil // Files removed mid-walk don't stop the sweep
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			if info.Mode().IsRegular() {
+				dir.bytes += info.Size()
+			}
+			if info.ModTime().After(dir.modified) {
+				dir.modified = info.ModTime()
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure session directory %s: %w", entry.Name(), err)
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// archive writes a session directory to the archive directory as a .tar.gz
+// and removes it. Without an archive directory it's only removed.
+func (j *janitor) archive(dir sessionDirUsage, now time.Time) error {
+	if j.config.ArchiveDir != "" {
+		if err := os.MkdirAll(j.config.ArchiveDir, 0755); err != nil {
+			return fmt.Errorf("failed to create archive directory: %w", err)
+		}
+		name := fmt.Sprintf("%s-%s.tar.gz", dir.name, now.UTC().Format("20060102T150405Z"))
+		if err := writeArchive(filepath.Join(j.config.ArchiveDir, name), dir.path); err != nil {
+			return err
+		}
+	}
+	if err := os.RemoveAll(dir.path); err != nil {
+		return fmt.Errorf("failed to remove session directory: %w", err)
+	}
+	return nil
+}
+
+// writeArchive writes the contents of dir to a gzipped tarball at path. The
+// tarball is written under a temporary name so a failed archive leaves nothing behind.
+func writeArchive(path, dir string) error {
+	tmp := path + ".partial"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer os.Remove(tmp)
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", dir, err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to save archive: %w", err)
+	}
+	return nil
+}
+
+// deleteOldArchives removes archives older than the archive TTL
+func (j *janitor) deleteOldArchives(now time.Time) error {
+	if j.config.ArchiveDir == "" || j.config.ArchiveTTL <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(j.config.ArchiveDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to list session archives: %w", err)
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".tar.gz") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) <= j.config.ArchiveTTL {
+			continue
+		}
+		if err := os.Remove(filepath.Join(j.config.ArchiveDir, entry.Name())); err != nil {
+			slog.Error("Failed to delete session archive", "archive", entry.Name(), "error", err)
+			continue
+		}
+		slog.Info("Deleted expired session archive", "archive", entry.Name(), "action", "session_archive_deleted")
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package claude
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSessionDir creates a session directory holding one file of size bytes last changed at modified
+func writeSessionDir(t *testing.T, root, name string, size int, modified time.Time) string {
+	dir := filepath.Join(root, name)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	file := filepath.Join(dir, "output.txt")
+	require.NoError(t, os.WriteFile(file, make([]byte, size), 0644))
+	require.NoError(t, os.Chtimes(file, modified, modified))
+	require.NoError(t, os.Chtimes(dir, modified, modified))
+	return dir
+}
+
+func TestJanitorArchivesExpiredSessions(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	archives := t.TempDir()
+	now := time.Now()
+
+	expired := writeSessionDir(t, root, "old", 10, now.Add(-48*time.Hour))
+	running := writeSessionDir(t, root, "running", 10, now.Add(-48*time.Hour))
+	recent := writeSessionDir(t, root, "recent", 10, now.Add(-time.Hour))
+
+	j := newJanitor(config.ClaudeStorageConfig{ExpireAfter: 24 * time.Hour, ArchiveDir: archives}, root,
+		func() map[string]func() { return map[string]func(){running: func() {}} })
+	require.NoError(t, j.sweep(now))
+
+	assert.NoDirExists(t, expired)
+	assert.DirExists(t, running)
+	assert.DirExists(t, recent)
+
+	entries, err := os.ReadDir(archives)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.True(t, strings.HasPrefix(entries[0].Name(), "old-"))
+
+	file, err := os.Open(filepath.Join(archives, entries[0].Name()))
+	require.NoError(t, err)
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	header, err := tar.NewReader(gz).Next()
+	require.NoError(t, err)
+	assert.Equal(t, "output.txt", header.Name)
+	assert.Equal(t, int64(10), header.Size)
+}
+
+func TestJanitorEnforcesTotalQuotaOldestFirst(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	oldest := writeSessionDir(t, root, "a", megabyte, now.Add(-3*time.Hour))
+	middle := writeSessionDir(t, root, "b", megabyte, now.Add(-2*time.Hour))
+	newest := writeSessionDir(t, root, "c", megabyte, now.Add(-time.Hour))
+
+	// No archive directory, so directories over the quota are deleted
+	j := newJanitor(config.ClaudeStorageConfig{TotalQuotaMB: 2}, root, nil)
+	require.NoError(t, j.sweep(now))
+
+	assert.NoDirExists(t, oldest)
+	assert.DirExists(t, middle)
+	assert.DirExists(t, newest)
+}
+
+func TestJanitorStopsRunningSessionOverQuota(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	big := writeSessionDir(t, root, "big", 2*megabyte, time.Now())
+	small := writeSessionDir(t, root, "small", 10, time.Now())
+
+	var stopped []string
+	j := newJanitor(config.ClaudeStorageConfig{SessionQuotaMB: 1}, root, func() map[string]func() {
+		return map[string]func(){
+			big:   func() { stopped = append(stopped, "big") },
+			small: func() { stopped = append(stopped, "small") },
+		}
+	})
+	require.NoError(t, j.sweep(time.Now()))
+
+	assert.Equal(t, []string{"big"}, stopped)
+	assert.DirExists(t, big)
+}
+
+func TestJanitorDeletesOldArchives(t *testing.T) {
+	archives := t.TempDir()
+	now := time.Now()
+	old := filepath.Join(archives, "old-20240101T000000Z.tar.gz")
+	fresh := filepath.Join(archives, "fresh-20240201T000000Z.tar.gz")
+	other := filepath.Join(archives, "notes.txt")
+	for _, path := range []string{old, fresh, other} {
+		require.NoError(t, os.WriteFile(path, nil, 0644))
+		require.NoError(t, os.Chtimes(path, now.Add(-60*24*time.Hour), now.Add(-60*24*time.Hour)))
+	}
+	require.NoError(t, os.Chtimes(fresh, now, now))
+
+	j := newJanitor(config.ClaudeStorageConfig{ArchiveDir: archives, ArchiveTTL: 30 * 24 * time.Hour}, filepath.Join(archives, "missing"), nil)
+	require.NoError(t, j.sweep(now))
+
+	assert.NoFileExists(t, old)
+	assert.FileExists(t, fresh)
+	assert.FileExists(t, other)
+}
@@ -0,0 +1,237 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job already finished")
+)
+
+// Jobs runs one-shot Claude tasks in the background, for automation that has
+// no Slack thread or WebSocket reading the session. Each job gets its own
+// process, which is stopped once the prompt's turn ends, and its status and
+// result are kept in the database.
+type Jobs struct {
+	service *Service
+	db      *gorm.DB
+	events  *events.Bus
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc // Jobs still queued or running, by ID
+}
+
+// NewJobs creates a job runner on service that stores jobs in db. Jobs left
+// queued or running by a previous server are marked failed, since their
+// processes are gone.
+func NewJobs(service *Service, db *gorm.DB, bus *events.Bus) *Jobs {
+	j := &Jobs{
+		service: service,
+		db:      db,
+		events:  bus,
+		cancels: make(map[string]context.CancelFunc),
+	}
+
+	if db == nil {
+		return j
+	}
+	err := db.Model(&models.ClaudeJob{}).
+		Where("status IN ?", []string{JobQueued, JobRunning}).
+		Updates(map[string]interface{}{"status": JobFailed, "error": "interrupted by a server restart"}).Error
+	if err != nil {
+		slog.Warn("Failed to mark interrupted Claude jobs", "error", err)
+	}
+	return j
+}
+
+// SubmitJob queues prompt to run in workspace and returns the job's ID
+// without waiting for it. opts apply to the job's session as they would to
+// CreateSessionWithOptions; WithUser also records who owns the job.
+func (j *Jobs) SubmitJob(prompt, workspace string, opts ...SessionOption) (string, error) {
+	if prompt == "" {
+		return "", fmt.Errorf("job prompt cannot be empty")
+	}
+	if _, err := resolveDirectory(workspace); err != nil {
+		return "", err
+	}
+
+	job := &models.ClaudeJob{
+		Model: models.Model{
+			ID:        uuid.NewString(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		},
+		UserID:    newSessionOptions(opts).userID,
+		Prompt:    prompt,
+		Workspace: workspace,
+		Status:    JobQueued,
+	}
+	if err := j.db.Create(job).Error; err != nil {
+		return "", fmt.Errorf("failed to save job: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j.mu.Lock()
+	j.cancels[job.ID] = cancel
+	j.mu.Unlock()
+
+	slog.Info("Claude job submitted",
+		"job_id", job.ID,
+		"user_id", job.UserID,
+		"workspace", workspace,
+		"action", "job_submitted",
+	)
+	go j.run(ctx, job, opts)
+	return job.ID, nil
+}
+
+// GetJob returns a job's current status and, once it has finished, its result
+func (j *Jobs) GetJob(jobID string) (*models.ClaudeJob, error) {
+	var job models.ClaudeJob
+	if err := j.db.Where("id = ?", jobID).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("job %s: %w", jobID, ErrJobNotFound)
+		}
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	return &job, nil
+}
+
+// CancelJob stops a queued or running job. The job is marked cancelled once
+// its process has stopped.
+func (j *Jobs) CancelJob(jobID string) error {
+	j.mu.Lock()
+	cancel, running := j.cancels[jobID]
+	j.mu.Unlock()
+	if running {
+		cancel()
+		return nil
+	}
+
+	if _, err := j.GetJob(jobID); err != nil {
+		return err
+	}
+	return fmt.Errorf("job %s: %w", jobID, ErrJobFinished)
+}
+
+// run starts the job's process, sends its prompt, and records how the turn ended
+func (j *Jobs) run(ctx context.Context, job *models.ClaudeJob, opts []SessionOption) {
+	defer func() {
+		j.mu.Lock()
+		delete(j.cancels, job.ID)
+		j.mu.Unlock()
+	}()
+
+	// Waiting for a process slot can't be interrupted, so a job cancelled
+	// while queued is stopped as soon as it starts
+	process, err := j.service.CreateSessionWithOptions(job.Workspace, opts...)
+	if err != nil {
+		j.finish(job, JobFailed, Message{}, fmt.Errorf("failed to start Claude: %w", err))
+		return
+	}
+	defer j.service.StopSession(process.sessionID)
+	process.flowSessionID.Store(&job.ID)
+	process.userID.Store(&job.UserID)
+
+	if ctx.Err() != nil {
+		j.finish(job, JobCancelled, Message{}, nil)
+		return
+	}
+
+	now := time.Now()
+	job.Status = JobRunning
+	job.SessionID = process.sessionID
+	job.StartedAt = &now
+	if err := j.db.Save(job).Error; err != nil {
+		slog.Warn("Failed to mark Claude job running", "job_id", job.ID, "error", err)
+	}
+
+	if err := j.service.SendMessage(process, job.Prompt); err != nil {
+		j.finish(job, JobFailed, Message{}, fmt.Errorf("failed to send prompt: %w", err))
+		return
+	}
+
+	result, err := j.awaitResult(ctx, process)
+	switch {
+	case ctx.Err() != nil:
+		j.finish(job, JobCancelled, result, nil)
+	case err != nil:
+		j.finish(job, JobFailed, result, err)
+	case result.IsError:
+		j.finish(job, JobFailed, result, errors.New(result.Result))
+	default:
+		j.finish(job, JobSucceeded, result, nil)
+	}
+}
+
+// awaitResult reads the process's output until its turn ends
+func (j *Jobs) awaitResult(ctx context.Context, process *Process) (Message, error) {
+	output := j.service.ReceiveMessages(process)
+	for {
+		select {
+		case msg, ok := <-output:
+			if !ok {
+				select {
+				case sessionErr := <-j.service.Errors(process):
+					return Message{}, sessionErr
+				default:
+					return Message{}, fmt.Errorf("claude exited before finishing the job")
+				}
+			}
+			if msg.Type == "result" {
+				return msg, nil
+			}
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		}
+	}
+}
+
+// finish records a job's outcome and publishes job.finished
+func (j *Jobs) finish(job *models.ClaudeJob, status string, result Message, err error) {
+	now := time.Now()
+	job.Status = status
+	job.FinishedAt = &now
+	job.Result = result.Result
+	job.CostUSD = result.TotalCostUSD
+	if err != nil {
+		job.Error = err.Error()
+	}
+	if saveErr := j.db.Save(job).Error; saveErr != nil {
+		slog.Error("Failed to save Claude job result", "job_id", job.ID, "error", saveErr)
+	}
+
+	slog.Info("Claude job finished",
+		"job_id", job.ID,
+		"status", status,
+		"error", err,
+		"action", "job_finished",
+	)
+	events.Publish(j.events, events.TopicJobFinished, events.JobFinished{
+		JobID:  job.ID,
+		UserID: job.UserID,
+		Status: status,
+		Result: job.Result,
+		Error:  job.Error,
+	})
+}
@@ -0,0 +1,91 @@
+package claude
+
+import (
+	"testing"
+
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestJobs(t *testing.T, bus *events.Bus) *Jobs {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ClaudeJob{}))
+	return NewJobs(NewService(Config{}), db, bus)
+}
+
+func TestSubmitJobValidatesInput(t *testing.T) {
+	jobs := newTestJobs(t, nil)
+
+	_, err := jobs.SubmitJob("", t.TempDir())
+	assert.Error(t, err)
+	_, err = jobs.SubmitJob("fix the build", "/does/not/exist")
+	assert.ErrorIs(t, err, ErrInvalidDirectory)
+}
+
+func TestGetAndCancelUnknownJob(t *testing.T) {
+	jobs := newTestJobs(t, nil)
+
+	_, err := jobs.GetJob("missing")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+	assert.ErrorIs(t, jobs.CancelJob("missing"), ErrJobNotFound)
+}
+
+func TestFinishRecordsResultAndPublishes(t *testing.T) {
+	bus := events.New()
+	finished, unsubscribe := events.Channel(bus, events.TopicJobFinished)
+	defer unsubscribe()
+	jobs := newTestJobs(t, bus)
+
+	job := &models.ClaudeJob{Model: models.Model{ID: "job-1"}, UserID: "u1", Prompt: "summarize", Workspace: "/tmp", Status: JobRunning}
+	require.NoError(t, jobs.db.Create(job).Error)
+	jobs.finish(job, JobSucceeded, Message{Type: "result", Result: "all done", TotalCostUSD: 0.25}, nil)
+
+	saved, err := jobs.GetJob("job-1")
+	require.NoError(t, err)
+	assert.Equal(t, JobSucceeded, saved.Status)
+	assert.Equal(t, "all done", saved.Result)
+	assert.Equal(t, 0.25, saved.CostUSD)
+	assert.NotNil(t, saved.FinishedAt)
+	assert.Equal(t, events.JobFinished{JobID: "job-1", UserID: "u1", Status: JobSucceeded, Result: "all done"}, <-finished)
+
+	assert.ErrorIs(t, jobs.CancelJob("job-1"), ErrJobFinished)
+}
+
+func TestNewJobsFailsInterruptedJobs(t *testing.T) {
+	jobs := newTestJobs(t, nil)
+	require.NoError(t, jobs.db.Create(&models.ClaudeJob{Model: models.Model{ID: "running"}, Prompt: "p", Workspace: "/tmp", Status: JobRunning}).Error)
+	require.NoError(t, jobs.db.Create(&models.ClaudeJob{Model: models.Model{ID: "done"}, Prompt: "p", Workspace: "/tmp", Status: JobSucceeded}).Error)
+
+	NewJobs(jobs.service, jobs.db, nil)
+
+	running, err := jobs.GetJob("running")
+	require.NoError(t, err)
+	assert.Equal(t, JobFailed, running.Status)
+	done, err := jobs.GetJob("done")
+	require.NoError(t, err)
+	assert.Equal(t, JobSucceeded, done.Status)
+}
+
+func TestAwaitResult(t *testing.T) {
+	jobs := newTestJobs(t, nil)
+	process := newIdleProcess("cli-1")
+	process.outputChan = make(chan Message, 2)
+	process.errorChan = make(chan *SessionError, 1)
+
+	process.outputChan <- Message{Type: "assistant"}
+	process.outputChan <- Message{Type: "result", Result: "done"}
+	result, err := jobs.awaitResult(process.ctx, process)
+	require.NoError(t, err)
+	assert.Equal(t, "done", result.Result)
+
+	// A process that exits mid-turn fails the job with the error it reported
+	process.reportError(&SessionError{Kind: ErrCrash, SessionID: "cli-1"})
+	close(process.outputChan)
+	_, err = jobs.awaitResult(process.ctx, process)
+	assert.ErrorIs(t, err, ErrCrash)
+}
@@ -0,0 +1,78 @@
+package claude
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/breadchris/flow/metrics"
+	"github.com/google/uuid"
+)
+
+// MessageSubtypeModelFallback is the subtype of the system message sent on a
+// process's output channel when a rate-limited turn is retried on the
+// fallback model. Text holds the model it switched to.
+const MessageSubtypeModelFallback = "model_fallback"
+
+// isRateLimitError reports whether a failed turn's result is a rate-limit or overload error from the API
+func isRateLimitError(result string) bool {
+	result = strings.ToLower(result)
+	for _, marker := range []string{"rate limit", "rate_limit", "429", "overloaded"} {
+		if strings.Contains(result, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// fallBack switches a rate-limited process to the fallback model and resends
+// the prompt that failed. It reports false, leaving the error for the caller,
+// when there is no other model to fall back to.
+func (s *Service) fallBack(process *Process, result Message) bool {
+	fallback := s.config.FallbackModel
+	if result.Type != "result" || !result.IsError || fallback == "" || !isRateLimitError(result.Result) {
+		return false
+	}
+	if process.Model() == fallback {
+		return false
+	}
+	prompt := process.lastPrompt.Load()
+	if prompt == nil {
+		return false
+	}
+
+	request := controlRequest{
+		Type:      "control_request",
+		RequestID: uuid.NewString(),
+		retry:     prompt,
+	}
+	request.Request.Subtype = "set_model"
+	request.Request.Model = fallback
+
+	// This runs on the stdout goroutine, which must not wait on stdin
+	select {
+	case process.controlChan <- request:
+	default:
+		return false
+	}
+
+	primary := process.Model()
+	process.model.Store(&fallback)
+	metrics.ClaudeModelFallbacksTotal.Inc()
+	slog.Warn("Claude is rate limited, retrying on fallback model",
+		"correlation_id", process.correlationID,
+		"session_id", process.FlowSessionID(),
+		"model", primary,
+		"fallback_model", fallback,
+		"error", result.Result,
+		"action", "model_fallback",
+	)
+	return true
+}
+
+// Model returns the model the process is using; empty means the Claude CLI's default
+func (p *Process) Model() string {
+	if model := p.model.Load(); model != nil {
+		return *model
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package claude
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelSessionOption(t *testing.T) {
+	config := Config{Tools: []string{"Read"}, Model: "sonnet"}
+	assert.Equal(t, []string{"--allowedTools", "Read", "--model", "sonnet"}, newSessionOptions(nil).cliArgs(config, nil))
+
+	opts := newSessionOptions([]SessionOption{WithModel("opus")})
+	assert.False(t, opts.isDefault())
+	assert.Equal(t, []string{"--allowedTools", "Read", "--model", "opus"}, opts.cliArgs(config, nil))
+}
+
+func TestIsRateLimitError(t *testing.T) {
+	assert.True(t, isRateLimitError("API Error: 429 Too Many Requests"))
+	assert.True(t, isRateLimitError(`{"type":"error","error":{"type":"rate_limit_error"}}`))
+	assert.True(t, isRateLimitError("API Error: Overloaded"))
+	assert.False(t, isRateLimitError("file not found"))
+}
+
+func TestFallBackRetriesPromptOnFallbackModel(t *testing.T) {
+	s := NewService(Config{Model: "opus", FallbackModel: "haiku"})
+	process := newIdleProcess("cli-1")
+	primary := "opus"
+	process.model.Store(&primary)
+	require.NoError(t, s.SendMessage(process, "fix the tests"))
+	<-process.inputChan
+
+	rateLimited := Message{Type: "result", IsError: true, Result: "API Error: 429 rate limit exceeded"}
+	require.True(t, s.fallBack(process, rateLimited))
+	assert.Equal(t, "haiku", process.Model())
+
+	request := <-process.controlChan
+	assert.Equal(t, "set_model", request.Request.Subtype)
+	assert.Equal(t, "haiku", request.Request.Model)
+	require.NotNil(t, request.retry)
+	assert.Equal(t, "fix the tests", request.retry.Message.Content[0].Text)
+
+	// Already on the fallback model, so the error goes to the caller
+	assert.False(t, s.fallBack(process, rateLimited))
+}
+
+func TestFallBackIgnoresOtherErrors(t *testing.T) {
+	s := NewService(Config{FallbackModel: "haiku"})
+	process := newIdleProcess("cli-1")
+	require.NoError(t, s.SendMessage(process, "fix the tests"))
+	<-process.inputChan
+
+	assert.False(t, s.fallBack(process, Message{Type: "result", IsError: true, Result: "tool failed"}))
+	assert.False(t, s.fallBack(process, Message{Type: "result", Result: "rate limit"}))
+	assert.False(t, NewService(Config{}).fallBack(process, Message{Type: "result", IsError: true, Result: "rate limit"}))
+	assert.Empty(t, process.controlChan)
+}
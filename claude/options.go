@@ -0,0 +1,255 @@
+package claude
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/breadchris/flow/config"
+)
+
+// ReadOnlyTools can inspect a working directory without changing it
+var ReadOnlyTools = []string{"Read", "Grep", "Glob", "LS"}
+
+// writeTools modify files or run commands
+var writeTools = []string{"Write", "Edit", "MultiEdit", "NotebookEdit", "Bash"}
+
+// SessionOptions customizes a single Claude session
+type SessionOptions struct {
+	AllowedTools    []string                   // Replaces Config.Tools when set
+	DisallowedTools []string                   // Tools the session may never use
+	SystemPrompt    string                     // Appended to Claude's default system prompt
+	Model           string                     // Replaces Config.Model when set
+	Budget          *config.ClaudeBudgetConfig // Replaces Config.Budget when set
+
+	userID      string             // Whose process quota the session counts against
+	onQueued    func(position int) // Told the session's place in line while it waits for a slot
+	slot        func()             // Slot already held for the session, which skips the queue
+	budgetSpent *sessionBudget     // Spend carried over from a process the session's new one replaces
+	dirs        []Directory        // Directories a Query works in, or a session works in besides its own
+	sessionDir  string             // Directory a session works in instead of a new one of its own
+}
+
+// SessionOption sets a field of SessionOptions
+type SessionOption func(*SessionOptions)
+
+// WithAllowedTools limits a session to the given tools instead of the service defaults
+func WithAllowedTools(tools ...string) SessionOption {
+	return func(o *SessionOptions) {
+		o.AllowedTools = tools
+	}
+}
+
+// WithDisallowedTools blocks the given tools for a session
+func WithDisallowedTools(tools ...string) SessionOption {
+	return func(o *SessionOptions) {
+		o.DisallowedTools = append(o.DisallowedTools, tools...)
+	}
+}
+
+// WithSystemPrompt adds instructions to the session's system prompt
+func WithSystemPrompt(prompt string) SessionOption {
+	return func(o *SessionOptions) {
+		o.SystemPrompt = prompt
+	}
+}
+
+// WithModel runs a session on model instead of the service's default
+func WithModel(model string) SessionOption {
+	return func(o *SessionOptions) {
+		o.Model = model
+	}
+}
+
+// WithBudget pauses the session when it reaches the given limits instead of the service's default
+func WithBudget(budget config.ClaudeBudgetConfig) SessionOption {
+	return func(o *SessionOptions) {
+		o.Budget = &budget
+	}
+}
+
+// ReadOnly restricts a session to tools that cannot modify files or run commands
+func ReadOnly() SessionOption {
+	return func(o *SessionOptions) {
+		o.AllowedTools = append([]string(nil), ReadOnlyTools...)
+		o.DisallowedTools = append(o.DisallowedTools, writeTools...)
+	}
+}
+
+// WithUser counts the session's process against userID's share of the scheduler
+func WithUser(userID string) SessionOption {
+	return func(o *SessionOptions) {
+		o.userID = userID
+	}
+}
+
+// WithQueueNotifier calls notify with the session's place in line while it waits
+// for a free Claude process
+func WithQueueNotifier(notify func(position int)) SessionOption {
+	return func(o *SessionOptions) {
+		o.onQueued = notify
+	}
+}
+
+// WithDirectories lets a Query work in dirs, the first of which is its working
+// directory. A session created with CreateSessionWithPersistence gets them
+// alongside its own session and upload directories.
+func WithDirectories(dirs ...Directory) SessionOption {
+	return func(o *SessionOptions) {
+		o.dirs = dirs
+	}
+}
+
+// withSlot starts the session in a slot the caller already holds
+func withSlot(release func()) SessionOption {
+	return func(o *SessionOptions) {
+		o.slot = release
+	}
+}
+
+// withBudgetSpent keeps counting the session's spend from an earlier process
+func withBudgetSpent(budget *sessionBudget) SessionOption {
+	return func(o *SessionOptions) {
+		o.budgetSpent = budget
+	}
+}
+
+// WithSessionDir runs a session created with CreateSessionWithPersistence in
+// dir instead of a new directory of its own, so sessions can share one. The
+// directory is created if needed and isn't removed or archived.
+func WithSessionDir(dir string) SessionOption {
+	return func(o *SessionOptions) {
+		o.sessionDir = dir
+	}
+}
+
+func newSessionOptions(opts []SessionOption) SessionOptions {
+	var o SessionOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// isDefault reports whether the options leave the service's defaults unchanged
+func (o SessionOptions) isDefault() bool {
+	return len(o.AllowedTools) == 0 && len(o.DisallowedTools) == 0 && o.SystemPrompt == "" && o.Model == "" && o.Budget == nil && len(o.dirs) == 0 && o.sessionDir == ""
+}
+
+// sessionBudget returns the budget a new process for the session counts against
+func (o SessionOptions) sessionBudget(config Config) *sessionBudget {
+	if o.budgetSpent != nil {
+		return o.budgetSpent
+	}
+	if o.Budget != nil {
+		return newSessionBudget(*o.Budget)
+	}
+	return newSessionBudget(config.Budget)
+}
+
+// model returns the model the session runs on, which is empty for the CLI's default
+func (o SessionOptions) model(config Config) string {
+	if o.Model != "" {
+		return o.Model
+	}
+	return config.Model
+}
+
+// cliArgs returns the CLI flags that apply the options over the service config
+// and keep Claude's editing tools out of read-only directories
+func (o SessionOptions) cliArgs(config Config, dirs []Directory) []string {
+	args := o.toolArgs(config.Tools, readOnlyRules(dirs), len(config.Approval.Patterns) > 0)
+	if model := o.model(config); model != "" {
+		args = append(args, "--model", model)
+	}
+	if o.SystemPrompt != "" {
+		args = append(args, "--append-system-prompt", o.SystemPrompt)
+	}
+	return args
+}
+
+// toolArgs returns the CLI flags for the session's tool permissions, with
+// extra rules added to the disallowed list. With approvals on, Bash calls are
+// checked by the service instead of allowed outright.
+func (o SessionOptions) toolArgs(defaultTools []string, rules []string, approvals bool) []string {
+	allowed := defaultTools
+	if len(o.AllowedTools) > 0 {
+		allowed = o.AllowedTools
+	}
+	var promptArgs []string
+	if approvals {
+		allowed, promptArgs = approvalArgs(allowed)
+	}
+
+	args := []string{"--allowedTools", strings.Join(allowed, ",")}
+	disallowed := append(append([]string(nil), o.DisallowedTools...), rules...)
+	if len(disallowed) > 0 {
+		args = append(args, "--disallowedTools", strings.Join(disallowed, ","))
+	}
+	args = append(args, promptArgs...)
+	return args
+}
+
+// metadata returns the options to persist with a session so resuming it keeps the same permissions and prompt
+func (o SessionOptions) metadata() map[string]interface{} {
+	m := map[string]interface{}{}
+	if len(o.AllowedTools) > 0 {
+		m["allowed_tools"] = o.AllowedTools
+	}
+	if len(o.DisallowedTools) > 0 {
+		m["disallowed_tools"] = o.DisallowedTools
+	}
+	if o.SystemPrompt != "" {
+		m["system_prompt"] = o.SystemPrompt
+	}
+	if o.Model != "" {
+		m["model"] = o.Model
+	}
+	if o.Budget != nil {
+		m["budget"] = o.Budget
+	}
+	if len(o.dirs) > 0 {
+		m["extra_dirs"] = o.dirs
+	}
+	return m
+}
+
+// sessionOptionsFromMetadata restores options persisted by metadata
+func sessionOptionsFromMetadata(metadata map[string]interface{}) SessionOptions {
+	options := SessionOptions{
+		AllowedTools:    stringSlice(metadata["allowed_tools"]),
+		DisallowedTools: stringSlice(metadata["disallowed_tools"]),
+	}
+	options.SystemPrompt, _ = metadata["system_prompt"].(string)
+	options.Model, _ = metadata["model"].(string)
+	if raw, ok := metadata["budget"]; ok {
+		// Decoded JSON holds the budget as a generic map, so convert it back through JSON
+		var budget config.ClaudeBudgetConfig
+		if data, err := json.Marshal(raw); err == nil && json.Unmarshal(data, &budget) == nil {
+			options.Budget = &budget
+		}
+	}
+	if raw, ok := metadata["extra_dirs"]; ok {
+		var dirs []Directory
+		if data, err := json.Marshal(raw); err == nil && json.Unmarshal(data, &dirs) == nil {
+			options.dirs = dirs
+		}
+	}
+	return options
+}
+
+// stringSlice converts a JSON-decoded array to strings
+func stringSlice(v interface{}) []string {
+	switch values := v.(type) {
+	case []string:
+		return values
+	case []interface{}:
+		out := make([]string, 0, len(values))
+		for _, value := range values {
+			if s, ok := value.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package claude
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionOptionsToolArgs(t *testing.T) {
+	defaults := []string{"Read", "Write", "Bash"}
+
+	assert.Equal(t, []string{"--allowedTools", "Read,Write,Bash"},
+		newSessionOptions(nil).toolArgs(defaults, nil, false))
+
+	opts := newSessionOptions([]SessionOption{
+		WithAllowedTools("Read", "Edit"),
+		WithDisallowedTools("Bash"),
+	})
+	assert.Equal(t, []string{"--allowedTools", "Read,Edit", "--disallowedTools", "Bash"},
+		opts.toolArgs(defaults, nil, false))
+}
+
+func TestReadOnlySessionOptions(t *testing.T) {
+	opts := newSessionOptions([]SessionOption{ReadOnly()})
+	assert.False(t, opts.isDefault())
+	assert.Equal(t, ReadOnlyTools, opts.AllowedTools)
+	assert.Contains(t, opts.DisallowedTools, "Write")
+	assert.Contains(t, opts.DisallowedTools, "Bash")
+
+	// Callers can't change the shared read-only list through a session's options
+	opts.AllowedTools[0] = "Write"
+	assert.Equal(t, "Read", ReadOnlyTools[0])
+}
+
+func TestSessionOptionsSurviveMetadataRoundTrip(t *testing.T) {
+	opts := newSessionOptions([]SessionOption{
+		ReadOnly(),
+		WithSystemPrompt("Act as a reviewer."),
+		WithModel("opus"),
+		WithBudget(config.ClaudeBudgetConfig{MaxTurns: 10, MaxDuration: time.Hour}),
+		WithDirectories(ReadWriteDir("/srv/team-repo")),
+	})
+
+	data, err := json.Marshal(opts.metadata())
+	require.NoError(t, err)
+	var metadata map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &metadata))
+
+	assert.Equal(t, opts, sessionOptionsFromMetadata(metadata))
+	assert.True(t, sessionOptionsFromMetadata(map[string]interface{}{}).isDefault())
+}
+
+func TestSystemPromptSessionOption(t *testing.T) {
+	opts := newSessionOptions([]SessionOption{WithSystemPrompt("Act as a reviewer.")})
+	assert.False(t, opts.isDefault())
+	assert.Equal(t, []string{"--allowedTools", "Read", "--append-system-prompt", "Act as a reviewer."},
+		opts.cliArgs(Config{Tools: []string{"Read"}}, nil))
+	assert.Equal(t, []string{"--allowedTools", "Read"}, newSessionOptions(nil).cliArgs(Config{Tools: []string{"Read"}}, nil))
+}
+
+func TestPersona(t *testing.T) {
+	cs := &ClaudeService{personas: map[string]string{"sre": "Act as an SRE.", "reviewer": "Act as a reviewer."}}
+
+	opt, err := cs.Persona("Reviewer")
+	require.NoError(t, err)
+	assert.Equal(t, "Act as a reviewer.", newSessionOptions([]SessionOption{opt}).SystemPrompt)
+
+	_, err = cs.Persona("dba")
+	assert.ErrorIs(t, err, ErrUnknownPersona)
+	assert.Equal(t, []string{"reviewer", "sre"}, cs.Personas())
+}
+
+func TestSessionDirOption(t *testing.T) {
+	opts := newSessionOptions([]SessionOption{WithSessionDir("/srv/channel-workspace")})
+	assert.False(t, opts.isDefault(), "sessions sharing a directory can't take a warm process")
+	assert.Equal(t, "/srv/channel-workspace", opts.sessionDir)
+}
@@ -0,0 +1,238 @@
+package claude
+
+import (
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/breadchris/flow/config"
+)
+
+// Consumers of Claude's output, each with its own post-processors
+const (
+	OutputSlack = "slack"
+	OutputHTTP  = "http"
+)
+
+// OutputProcessor rewrites text Claude wrote before a consumer shows it
+type OutputProcessor func(text string) string
+
+// outputProcessorFactory builds a named processor from the output config.
+// Processors that work on any fragment of text are also run on partial text deltas.
+type outputProcessorFactory struct {
+	build   func(config.ClaudeOutputConfig) OutputProcessor
+	partial bool
+}
+
+// outputProcessors are the processors that can be named in the output config
+var outputProcessors = map[string]outputProcessorFactory{
+	"redact_secrets": {build: func(config.ClaudeOutputConfig) OutputProcessor { return RedactSecrets }, partial: true},
+	"profanity":      {build: profanityFilter, partial: true},
+	"markdown":       {build: func(config.ClaudeOutputConfig) OutputProcessor { return NormalizeMarkdown }},
+	"truncate":       {build: truncator},
+}
+
+// RegisterOutputProcessor makes a processor available under name in the
+// output config. It isn't run on partial text. Call it before the service starts.
+func RegisterOutputProcessor(name string, build func(config.ClaudeOutputConfig) OutputProcessor) {
+	outputProcessors[name] = outputProcessorFactory{build: build}
+}
+
+// OutputPipeline runs a chain of post-processors over the text in Claude's
+// messages. A nil pipeline leaves messages unchanged.
+type OutputPipeline struct {
+	processors []OutputProcessor
+	partial    []OutputProcessor // The subset safe to run on partial text deltas
+}
+
+// NewOutputPipeline builds a pipeline from processor names, skipping unknown ones
+func NewOutputPipeline(names []string, cfg config.ClaudeOutputConfig) *OutputPipeline {
+	p := &OutputPipeline{}
+	for _, name := range names {
+		factory, ok := outputProcessors[name]
+		if !ok {
+			slog.Warn("Ignoring unknown Claude output processor", "processor", name)
+			continue
+		}
+		processor := factory.build(cfg)
+		p.processors = append(p.processors, processor)
+		if factory.partial {
+			p.partial = append(p.partial, processor)
+		}
+	}
+	return p
+}
+
+// Text runs every processor over text, such as a consumer's accumulated partial text
+func (p *OutputPipeline) Text(text string) string {
+	if p == nil {
+		return text
+	}
+	for _, processor := range p.processors {
+		text = processor(text)
+	}
+	return text
+}
+
+// Apply runs the pipeline over a message's result, its text and tool result
+// content blocks, and partial text. Only processors safe on fragments run on
+// partial text, and a secret split across two deltas isn't caught.
+func (p *OutputPipeline) Apply(msg Message) Message {
+	if p == nil || len(p.processors) == 0 {
+		return msg
+	}
+	if msg.Type == MessageTypePartialText {
+		for _, processor := range p.partial {
+			msg.Text = processor(msg.Text)
+		}
+		return msg
+	}
+	if msg.Result != "" {
+		msg.Result = p.Text(msg.Result)
+	}
+	if len(msg.Message) > 0 {
+		msg.Message = p.content(msg.Message)
+	}
+	return msg
+}
+
+// content runs the pipeline over the content blocks of an assistant or user
+// message, keeping every other field as it was. Anything that isn't a message
+// with content blocks is returned unchanged.
+func (p *OutputPipeline) content(raw json.RawMessage) json.RawMessage {
+	var message map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &message); err != nil || message["content"] == nil {
+		return raw
+	}
+	message["content"] = p.blocks(message["content"])
+	updated, err := json.Marshal(message)
+	if err != nil {
+		return raw
+	}
+	return updated
+}
+
+// blocks runs the pipeline over the text in a list of content blocks
+func (p *OutputPipeline) blocks(raw json.RawMessage) json.RawMessage {
+	var blocks []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return raw
+	}
+	for _, block := range blocks {
+		var blockType string
+		json.Unmarshal(block["type"], &blockType)
+		switch blockType {
+		case "text":
+			p.field(block, "text")
+		case "tool_result":
+			// Tool output is either a string or a list of text blocks
+			if !p.field(block, "content") && block["content"] != nil {
+				block["content"] = p.blocks(block["content"])
+			}
+		}
+	}
+	updated, err := json.Marshal(blocks)
+	if err != nil {
+		return raw
+	}
+	return updated
+}
+
+// field runs the pipeline over a string field of a block, reporting false if it isn't a string
+func (p *OutputPipeline) field(block map[string]json.RawMessage, key string) bool {
+	var text string
+	if err := json.Unmarshal(block[key], &text); err != nil {
+		return false
+	}
+	encoded, err := json.Marshal(p.Text(text))
+	if err != nil {
+		return false
+	}
+	block[key] = encoded
+	return true
+}
+
+// secretPatterns match credentials that shouldn't leave the server
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),
+	regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{22,}\b`),
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`),
+	regexp.MustCompile(`\bsk-(ant-)?[A-Za-z0-9_-]{20,}`),
+}
+
+// secretAssignment matches values assigned to keys that look like credentials, as in .env files
+var secretAssignment = regexp.MustCompile(`(?i)\b([A-Z0-9_]*(password|passwd|secret|api_?key|access_?token|auth_?token)[A-Z0-9_]*)(\s*[:=]\s*)["']?[^\s"']{8,}["']?`)
+
+const redacted = "[REDACTED]"
+
+// RedactSecrets replaces API keys, tokens, private keys, and credential
+// assignments with [REDACTED]
+func RedactSecrets(text string) string {
+	for _, re := range secretPatterns {
+		text = re.ReplaceAllString(text, redacted)
+	}
+	return secretAssignment.ReplaceAllString(text, "${1}${3}"+redacted)
+}
+
+// defaultProfanity is masked when the output config doesn't list words
+var defaultProfanity = []string{"fuck", "fucking", "shit", "bitch", "asshole", "bastard", "cunt", "dick"}
+
+// profanityFilter masks whole listed words, ignoring case, keeping their first letter
+func profanityFilter(cfg config.ClaudeOutputConfig) OutputProcessor {
+	words := cfg.Profanity
+	if len(words) == 0 {
+		words = defaultProfanity
+	}
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = regexp.QuoteMeta(word)
+	}
+	re := regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)
+	return func(text string) string {
+		return re.ReplaceAllStringFunc(text, func(word string) string {
+			first, size := utf8.DecodeRuneInString(word)
+			return string(first) + strings.Repeat("*", utf8.RuneCountInString(word[size:]))
+		})
+	}
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// NormalizeMarkdown converts line endings to \n, strips trailing whitespace
+// outside code blocks, collapses runs of blank lines, and closes an
+// unterminated code block
+func NormalizeMarkdown(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+	inCode := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			lines[i] = strings.TrimRight(line, " \t")
+			continue
+		}
+		if !inCode {
+			lines[i] = strings.TrimRight(line, " \t")
+		}
+	}
+	text = strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+	if inCode {
+		text += "\n```"
+	}
+	return text
+}
+
+// truncator cuts text to the configured number of characters, marking the cut
+func truncator(cfg config.ClaudeOutputConfig) OutputProcessor {
+	return func(text string) string {
+		if cfg.MaxLength <= 0 || utf8.RuneCountInString(text) <= cfg.MaxLength {
+			return text
+		}
+		runes := []rune(text)
+		return string(runes[:cfg.MaxLength]) + "\n…(truncated)"
+	}
+}
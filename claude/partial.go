@@ -0,0 +1,85 @@
+package claude
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// MessageTypePartialText is the type of messages carrying a fragment of
+// assistant text while Claude is still writing it. The complete text follows
+// in the usual assistant message.
+const MessageTypePartialText = "partial_text"
+
+// streamEvent is the subset of an API streaming event needed to extract text deltas
+type streamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+}
+
+// partialText converts a stream_event message carrying a text delta into a
+// PartialText message. Other stream events report false.
+func partialText(msg Message) (Message, bool) {
+	var event streamEvent
+	if err := json.Unmarshal(msg.Event, &event); err != nil {
+		return Message{}, false
+	}
+	if event.Type != "content_block_delta" || event.Delta.Type != "text_delta" || event.Delta.Text == "" {
+		return Message{}, false
+	}
+	return Message{
+		Type:      MessageTypePartialText,
+		SessionID: msg.SessionID,
+		ParentID:  msg.ParentID,
+		Text:      event.Delta.Text,
+	}, true
+}
+
+// PartialTextBuffer coalesces PartialText deltas so consumers can redraw
+// incremental output at a steady rate instead of once per delta
+type PartialTextBuffer struct {
+	interval  time.Duration
+	text      strings.Builder
+	lastFlush time.Time
+	pending   bool
+}
+
+// NewPartialTextBuffer creates a buffer that flushes at most once per interval
+func NewPartialTextBuffer(interval time.Duration) *PartialTextBuffer {
+	return &PartialTextBuffer{interval: interval}
+}
+
+// Add appends a delta. It returns the text so far and true when at least
+// interval has passed since the last flush.
+func (b *PartialTextBuffer) Add(delta string) (string, bool) {
+	b.text.WriteString(delta)
+	b.pending = true
+	if time.Since(b.lastFlush) < b.interval {
+		return "", false
+	}
+	return b.Flush()
+}
+
+// Flush returns the text so far and true if deltas arrived since the last flush
+func (b *PartialTextBuffer) Flush() (string, bool) {
+	if !b.pending {
+		return "", false
+	}
+	b.pending = false
+	b.lastFlush = time.Now()
+	return b.text.String(), true
+}
+
+// Len returns the number of bytes buffered since the last reset
+func (b *PartialTextBuffer) Len() int {
+	return b.text.Len()
+}
+
+// Reset clears the buffer once the complete message has been received
+func (b *PartialTextBuffer) Reset() {
+	b.text.Reset()
+	b.pending = false
+}
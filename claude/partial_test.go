@@ -0,0 +1,71 @@
+package claude
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartialTextFromStreamEvent(t *testing.T) {
+	line := `{"type":"stream_event","session_id":"s1","event":{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}}`
+	var msg Message
+	require.NoError(t, json.Unmarshal([]byte(line), &msg))
+
+	partial, ok := partialText(msg)
+	require.True(t, ok)
+	assert.Equal(t, MessageTypePartialText, partial.Type)
+	assert.Equal(t, "s1", partial.SessionID)
+	assert.Equal(t, "Hel", partial.Text)
+}
+
+func TestPartialTextIgnoresOtherStreamEvents(t *testing.T) {
+	for _, event := range []string{
+		`{"type":"message_start","message":{}}`,
+		`{"type":"content_block_delta","delta":{"type":"input_json_delta","partial_json":"{\"pa"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`not json`,
+	} {
+		_, ok := partialText(Message{Type: "stream_event", Event: json.RawMessage(event)})
+		assert.False(t, ok, event)
+	}
+}
+
+func TestPartialTextBufferCoalesces(t *testing.T) {
+	buf := NewPartialTextBuffer(time.Hour)
+
+	// The first delta is shown right away
+	text, ok := buf.Add("Hello")
+	require.True(t, ok)
+	assert.Equal(t, "Hello", text)
+
+	// Later deltas wait for the interval
+	_, ok = buf.Add(", ")
+	assert.False(t, ok)
+	_, ok = buf.Add("world")
+	assert.False(t, ok)
+
+	text, ok = buf.Flush()
+	require.True(t, ok)
+	assert.Equal(t, "Hello, world", text)
+
+	_, ok = buf.Flush()
+	assert.False(t, ok)
+
+	buf.Reset()
+	assert.Equal(t, 0, buf.Len())
+}
+
+func TestPartialTextBufferFlushesAfterInterval(t *testing.T) {
+	buf := NewPartialTextBuffer(10 * time.Millisecond)
+	buf.Add("a")
+	_, ok := buf.Add("b")
+	assert.False(t, ok)
+
+	time.Sleep(15 * time.Millisecond)
+	text, ok := buf.Add("c")
+	require.True(t, ok)
+	assert.Equal(t, "abc", text)
+}
@@ -0,0 +1,26 @@
+package claude
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ErrUnknownPersona is returned for a persona that isn't in the Claude configuration
+var ErrUnknownPersona = errors.New("unknown persona")
+
+// Persona returns the option that gives a session the named persona's system prompt
+func (cs *ClaudeService) Persona(name string) (SessionOption, error) {
+	prompt, ok := cs.personas[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownPersona, name)
+	}
+	return WithSystemPrompt(prompt), nil
+}
+
+// Personas returns the names of the configured personas in alphabetical order
+func (cs *ClaudeService) Personas() []string {
+	return slices.Sorted(maps.Keys(cs.personas))
+}
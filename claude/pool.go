@@ -0,0 +1,239 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/metrics"
+	"github.com/google/uuid"
+)
+
+// warmSession is a Claude process started ahead of time for a session that does not exist yet
+type warmSession struct {
+	sessionID  string
+	sessionDir string
+	process    *Process
+	warmedAt   time.Time
+}
+
+// alive reports whether the warm process is still running
+func (w *warmSession) alive() bool {
+	return w.process != nil && w.process.ctx.Err() == nil
+}
+
+// Pool keeps pre-warmed Claude processes so new sessions skip CLI startup.
+// A warm process is bound to its session directory when it starts, so each
+// one is handed out once and the pool replaces it in the background.
+type Pool struct {
+	size    int
+	maxIdle time.Duration
+	warm    func() (*warmSession, error)
+	discard func(*warmSession)
+
+	mu     sync.Mutex
+	idle   []*warmSession
+	closed bool
+	refill chan struct{}
+}
+
+func newPool(cfg config.ClaudePoolConfig, warm func() (*warmSession, error), discard func(*warmSession)) *Pool {
+	return &Pool{
+		size:    cfg.Size,
+		maxIdle: cfg.MaxIdle,
+		warm:    warm,
+		discard: discard,
+		refill:  make(chan struct{}, 1),
+	}
+}
+
+// Run keeps the pool filled until ctx is cancelled, then stops every idle process
+func (p *Pool) Run(ctx context.Context) {
+	interval := time.Minute
+	if p.maxIdle > 0 && p.maxIdle/2 < interval {
+		interval = p.maxIdle / 2
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	slog.Info("Claude process pool started", "size", p.size, "max_idle", p.maxIdle)
+
+	for {
+		p.recycle()
+		p.fill(ctx)
+
+		select {
+		case <-ctx.Done():
+			p.close()
+			return
+		case <-p.refill:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Get takes a warm process from the pool, if one is ready
+func (p *Pool) Get() (*warmSession, bool) {
+	if p == nil {
+		return nil, false
+	}
+
+	p.mu.Lock()
+	var w *warmSession
+	var stale []*warmSession
+	for len(p.idle) > 0 {
+		next := p.idle[0]
+		p.idle = p.idle[1:]
+		if next.alive() {
+			w = next
+			break
+		}
+		stale = append(stale, next)
+	}
+	metrics.ClaudePoolIdle.Set(float64(len(p.idle)))
+	p.mu.Unlock()
+
+	for _, s := range stale {
+		p.discard(s)
+	}
+
+	select {
+	case p.refill <- struct{}{}:
+	default:
+	}
+
+	if w == nil {
+		metrics.ClaudePoolCheckoutsTotal.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+	metrics.ClaudePoolCheckoutsTotal.WithLabelValues("hit").Inc()
+	return w, true
+}
+
+// fill starts processes one at a time until the pool is full
+func (p *Pool) fill(ctx context.Context) {
+	for ctx.Err() == nil {
+		p.mu.Lock()
+		full := p.closed || len(p.idle) >= p.size
+		p.mu.Unlock()
+		if full {
+			return
+		}
+
+		w, err := p.warm()
+		if err != nil {
+			slog.Warn("Failed to warm Claude process, retrying later", "error", err)
+			return
+		}
+
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			p.discard(w)
+			return
+		}
+		p.idle = append(p.idle, w)
+		metrics.ClaudePoolIdle.Set(float64(len(p.idle)))
+		p.mu.Unlock()
+	}
+}
+
+// recycle stops idle processes that exited or have waited longer than maxIdle
+func (p *Pool) recycle() {
+	p.mu.Lock()
+	var expired []*warmSession
+	kept := p.idle[:0]
+	for _, w := range p.idle {
+		if !w.alive() || (p.maxIdle > 0 && time.Since(w.warmedAt) > p.maxIdle) {
+			expired = append(expired, w)
+			continue
+		}
+		kept = append(kept, w)
+	}
+	p.idle = kept
+	metrics.ClaudePoolIdle.Set(float64(len(p.idle)))
+	p.mu.Unlock()
+
+	for _, w := range expired {
+		slog.Debug("Recycling idle Claude process", "session_id", w.sessionID)
+		p.discard(w)
+	}
+}
+
+func (p *Pool) close() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	metrics.ClaudePoolIdle.Set(0)
+	p.mu.Unlock()
+
+	for _, w := range idle {
+		p.discard(w)
+	}
+	slog.Info("Claude process pool stopped", "stopped_processes", len(idle))
+}
+
+// RunPool keeps the configured number of Claude processes warm for new sessions
+// until ctx is cancelled. It returns immediately when the pool is disabled.
+func (cs *ClaudeService) RunPool(ctx context.Context) {
+	if cs.pool == nil {
+		return
+	}
+	cs.pool.Run(ctx)
+}
+
+// warmSession prepares a session directory with the default CLAUDE.md and starts Claude in it
+func (cs *ClaudeService) warmSession() (*warmSession, error) {
+	sessionID := uuid.New().String()
+	sessionDir := filepath.Join("./data", "session", sessionID)
+	if err := os.MkdirAll(sessionDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	if err := cs.createClaudeMDFromConfig(filepath.Join(sessionDir, "CLAUDE.md"), ""); err != nil {
+		slog.Warn("Failed to create CLAUDE.md in warm session directory",
+			"session_id", sessionID,
+			"error", err)
+	}
+
+	// Idle warm processes don't count against the scheduler until they are handed out
+	process, err := cs.service.startSession([]Directory{ReadWriteDir(sessionDir)}, SessionOptions{})
+	if err != nil {
+		os.RemoveAll(sessionDir)
+		return nil, fmt.Errorf("failed to create Claude process: %w", err)
+	}
+
+	return &warmSession{
+		sessionID:  sessionID,
+		sessionDir: sessionDir,
+		process:    process,
+		warmedAt:   time.Now(),
+	}, nil
+}
+
+// discardWarmSession stops a warm process that was never handed out and removes its directory
+func (cs *ClaudeService) discardWarmSession(w *warmSession) {
+	cs.service.StopSession(w.process.sessionID)
+	if err := os.RemoveAll(w.sessionDir); err != nil {
+		slog.Warn("Failed to remove warm session directory", "session_dir", w.sessionDir, "error", err)
+	}
+}
+
+// linkUploadDir exposes a thread's upload directory inside a warm session's directory,
+// since the process was started before the thread was known
+func linkUploadDir(sessionDir, uploadDir string) error {
+	target, err := filepath.Abs(uploadDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve upload directory: %w", err)
+	}
+	if err := os.Symlink(target, filepath.Join(sessionDir, "uploads")); err != nil {
+		return fmt.Errorf("failed to link upload directory: %w", err)
+	}
+	return nil
+}
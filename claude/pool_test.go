@@ -0,0 +1,142 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWarmer stands in for starting and stopping Claude processes
+type fakeWarmer struct {
+	mu        sync.Mutex
+	started   int
+	discarded []string
+	fail      bool
+}
+
+func (f *fakeWarmer) warm() (*warmSession, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		return nil, errors.New("claude not available")
+	}
+	f.started++
+	ctx, cancel := context.WithCancel(context.Background())
+	return &warmSession{
+		sessionID: fmt.Sprintf("s%d", f.started),
+		process:   &Process{ctx: ctx, cancel: cancel},
+		warmedAt:  time.Now(),
+	}, nil
+}
+
+func (f *fakeWarmer) discard(w *warmSession) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.process.cancel()
+	f.discarded = append(f.discarded, w.sessionID)
+}
+
+func (f *fakeWarmer) discardedIDs() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.discarded...)
+}
+
+func TestPoolFillAndGet(t *testing.T) {
+	f := &fakeWarmer{}
+	p := newPool(config.ClaudePoolConfig{Size: 2}, f.warm, f.discard)
+	p.fill(context.Background())
+	require.Len(t, p.idle, 2)
+
+	w, ok := p.Get()
+	require.True(t, ok)
+	assert.Equal(t, "s1", w.sessionID)
+	assert.Len(t, p.idle, 1)
+
+	// Taking a process asks the pool to start a replacement
+	select {
+	case <-p.refill:
+	default:
+		t.Fatal("expected a refill signal")
+	}
+
+	p.fill(context.Background())
+	assert.Len(t, p.idle, 2)
+	assert.Equal(t, 3, f.started)
+}
+
+func TestPoolGetSkipsExitedProcesses(t *testing.T) {
+	f := &fakeWarmer{}
+	p := newPool(config.ClaudePoolConfig{Size: 2}, f.warm, f.discard)
+	p.fill(context.Background())
+	p.idle[0].process.cancel()
+
+	w, ok := p.Get()
+	require.True(t, ok)
+	assert.Equal(t, "s2", w.sessionID)
+	assert.Equal(t, []string{"s1"}, f.discardedIDs())
+
+	_, ok = p.Get()
+	assert.False(t, ok)
+}
+
+func TestPoolRecyclesIdleProcesses(t *testing.T) {
+	f := &fakeWarmer{}
+	p := newPool(config.ClaudePoolConfig{Size: 2, MaxIdle: time.Minute}, f.warm, f.discard)
+	p.fill(context.Background())
+	p.idle[1].warmedAt = time.Now().Add(-2 * time.Minute)
+
+	p.recycle()
+	require.Len(t, p.idle, 1)
+	assert.Equal(t, "s1", p.idle[0].sessionID)
+	assert.Equal(t, []string{"s2"}, f.discardedIDs())
+}
+
+func TestPoolFillStopsOnError(t *testing.T) {
+	f := &fakeWarmer{fail: true}
+	p := newPool(config.ClaudePoolConfig{Size: 2}, f.warm, f.discard)
+	p.fill(context.Background())
+	assert.Empty(t, p.idle)
+}
+
+func TestPoolRunStopsIdleProcessesOnCancel(t *testing.T) {
+	f := &fakeWarmer{}
+	p := newPool(config.ClaudePoolConfig{Size: 2}, f.warm, f.discard)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return len(p.idle) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("pool did not stop")
+	}
+	assert.ElementsMatch(t, []string{"s1", "s2"}, f.discardedIDs())
+
+	_, ok := p.Get()
+	assert.False(t, ok)
+}
+
+func TestNilPoolGet(t *testing.T) {
+	var p *Pool
+	_, ok := p.Get()
+	assert.False(t, ok)
+}
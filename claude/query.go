@@ -0,0 +1,132 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Query runs prompt once through the Claude CLI in print mode and returns the
+// final result. No Process is kept, so it suits one-off questions such as
+// writing a commit message. It waits for a scheduler slot like a session does.
+//
+// A query may only use the tools its options allow, and runs in an empty
+// temporary directory unless given directories with WithDirectories.
+func (s *Service) Query(ctx context.Context, prompt string, opts ...SessionOption) (string, error) {
+	if strings.TrimSpace(prompt) == "" {
+		return "", fmt.Errorf("prompt is required")
+	}
+	options := newSessionOptions(opts)
+
+	release := options.slot
+	if release == nil {
+		var err error
+		if release, err = s.scheduler.Acquire(options.userID, options.onQueued); err != nil {
+			return "", err
+		}
+	}
+	defer release()
+
+	dirs := options.dirs
+	if len(dirs) == 0 {
+		tmp, err := os.MkdirTemp("", "claude-query-")
+		if err != nil {
+			return "", fmt.Errorf("failed to create query directory: %w", err)
+		}
+		defer os.RemoveAll(tmp)
+		dirs = []Directory{ReadWriteDir(tmp)}
+	}
+	dirs, err := resolveDirectories(dirs)
+	if err != nil {
+		return "", err
+	}
+
+	correlationID := uuid.New().String()
+	startTime := time.Now()
+	cmd, err := sandboxCommand(ctx, s.config.Sandbox, dirs[0].Path, dirs, options.queryArgs(s.config, dirs))
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare Claude sandbox: %w", err)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = strings.NewReader(prompt)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	slog.Info("Running Claude query",
+		"correlation_id", correlationID,
+		"user_id", options.userID,
+		"prompt_length", len(prompt),
+		"action", "claude_query_start",
+	)
+	runErr := cmd.Run()
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+
+	result, err := queryResult(stdout.Bytes(), stderr.String(), runErr)
+	if err != nil {
+		slog.Warn("Claude query failed",
+			"correlation_id", correlationID,
+			"duration_ms", time.Since(startTime).Milliseconds(),
+			"error", err,
+			"action", "claude_query_failed",
+		)
+		return "", err
+	}
+	if err := s.usage.Record(result.SessionID, options.userID, result); err != nil {
+		slog.Warn("Failed to record Claude query usage", "correlation_id", correlationID, "error", err)
+	}
+
+	slog.Info("Claude query finished",
+		"correlation_id", correlationID,
+		"duration_ms", time.Since(startTime).Milliseconds(),
+		"cost_usd", result.TotalCostUSD,
+		"action", "claude_query_finished",
+	)
+	return result.Result, nil
+}
+
+// queryArgs returns the CLI arguments for a print mode query. Unlike a
+// session, a query gets no tools unless the options allow some.
+func (o SessionOptions) queryArgs(config Config, dirs []Directory) []string {
+	args := []string{"--print", "--output-format", "json"}
+	if len(o.AllowedTools) > 0 {
+		args = append(args, o.toolArgs(nil, readOnlyRules(dirs), false)...)
+	} else {
+		args = append(args, "--disallowedTools", strings.Join(writeTools, ","))
+	}
+	if model := o.model(config); model != "" {
+		args = append(args, "--model", model)
+	}
+	if o.SystemPrompt != "" {
+		args = append(args, "--append-system-prompt", o.SystemPrompt)
+	}
+	return append(args, directoryArgs(dirs)...)
+}
+
+// queryResult reads the result message a print mode run writes to stdout,
+// reporting a failed run or an error result as a SessionError
+func queryResult(stdout []byte, stderr string, runErr error) (Message, error) {
+	var result Message
+	if err := json.Unmarshal(bytes.TrimSpace(stdout), &result); err != nil || result.Type != "result" {
+		detail := strings.TrimSpace(stderr)
+		if detail == "" && runErr != nil {
+			detail = runErr.Error()
+		}
+		if detail == "" {
+			detail = "no result in Claude output"
+		}
+		return Message{}, &SessionError{Kind: classifyError(detail), Detail: detail}
+	}
+	if sessionErrors := messageErrors(result); len(sessionErrors) > 0 {
+		return Message{}, sessionErrors[0]
+	}
+	return result, nil
+}
@@ -0,0 +1,54 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryArgs(t *testing.T) {
+	dirs := []Directory{ReadWriteDir("/work")}
+	config := Config{Tools: []string{"Read", "Write", "Bash"}, Model: "sonnet"}
+
+	// The service's default tools don't apply to queries
+	assert.Equal(t, []string{
+		"--print", "--output-format", "json",
+		"--disallowedTools", "Write,Edit,MultiEdit,NotebookEdit,Bash",
+		"--model", "sonnet",
+		"--add-dir", "/work",
+	}, newSessionOptions(nil).queryArgs(config, dirs))
+
+	assert.Equal(t, []string{
+		"--print", "--output-format", "json",
+		"--allowedTools", "Read,Grep",
+		"--model", "haiku",
+		"--append-system-prompt", "Be brief.",
+		"--add-dir", "/work",
+	}, newSessionOptions([]SessionOption{WithAllowedTools("Read", "Grep"), WithModel("haiku"), WithSystemPrompt("Be brief.")}).queryArgs(config, dirs))
+}
+
+func TestQueryResult(t *testing.T) {
+	result, err := queryResult([]byte(`{"type":"result","subtype":"success","result":"Fix typo in README","session_id":"cli-1","total_cost_usd":0.002}`+"\n"), "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Fix typo in README", result.Result)
+	assert.Equal(t, 0.002, result.TotalCostUSD)
+
+	_, err = queryResult([]byte(`{"type":"result","is_error":true,"result":"API Error: 429 rate_limit_error"}`), "", errors.New("exit status 1"))
+	assert.ErrorIs(t, err, ErrRateLimited)
+
+	_, err = queryResult(nil, "Invalid API key · Please run /login\n", errors.New("exit status 1"))
+	assert.ErrorIs(t, err, ErrAuth)
+
+	_, err = queryResult(nil, "", errors.New("exit status 1"))
+	var sessionErr *SessionError
+	require.ErrorAs(t, err, &sessionErr)
+	assert.Equal(t, "exit status 1", sessionErr.Detail)
+}
+
+func TestQueryRequiresPrompt(t *testing.T) {
+	_, err := NewService(Config{}).Query(context.Background(), "  ")
+	assert.Error(t, err)
+}
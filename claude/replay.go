@@ -0,0 +1,159 @@
+package claude
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/breadchris/flow/metrics"
+	"github.com/breadchris/flow/models"
+)
+
+// ErrResumeFailed is returned when the Claude CLI exits instead of resuming a
+// conversation, typically because its saved state is gone
+var ErrResumeFailed = errors.New("claude could not resume the conversation")
+
+const (
+	// A resumed CLI still running after this long is taken to have loaded its conversation
+	resumeGracePeriod = 3 * time.Second
+
+	replayMaxChars   = 12000 // Longest transcript summary replayed into a new conversation
+	replayEntryChars = 800   // Longest prompt, reply, or tool call kept in the summary
+)
+
+// replayPrompt condenses a session's saved transcript into context for a new
+// conversation, keeping the most recent entries that fit. It returns "" when
+// there is nothing to replay.
+func replayPrompt(session *models.ClaudeSession) string {
+	export, err := newSessionExport(session)
+	if err != nil {
+		return ""
+	}
+
+	lines := transcriptLines(export.Entries)
+	if len(lines) == 0 {
+		return ""
+	}
+	start := recentLines(lines, replayMaxChars)
+
+	var prompt strings.Builder
+	prompt.WriteString("This conversation continues an earlier one whose saved state was lost. Use this summary of it as context, and answer the message after it.\n\n<previous_conversation>\n")
+	if start > 0 {
+		fmt.Fprintf(&prompt, "(%d earlier entries left out)\n", start)
+	}
+	prompt.WriteString(strings.Join(lines[start:], "\n"))
+	prompt.WriteString("\n</previous_conversation>\n\n")
+	return prompt.String()
+}
+
+// RestorePrompt carries an ended session's conversation into a new session:
+// its summary and the turns after it when it was summarized, or else the
+// most recent entries of its saved transcript. It returns "" when there is
+// nothing to carry over.
+func (cs *ClaudeService) RestorePrompt(sessionID string) (string, error) {
+	var dbSession models.ClaudeSession
+	if err := cs.db.Where("session_id = ?", sessionID).First(&dbSession).Error; err != nil {
+		return "", fmt.Errorf("failed to find session: %w", err)
+	}
+	if prompt := summaryPrompt(&dbSession); prompt != "" {
+		return prompt, nil
+	}
+	return replayPrompt(&dbSession), nil
+}
+
+// transcriptLines writes a transcript's prompts, replies, and tool calls as
+// one line each, clipping long ones
+func transcriptLines(entries []TranscriptEntry) []string {
+	var lines []string
+	for _, entry := range entries {
+		switch entry.Kind {
+		case EntryPrompt:
+			lines = append(lines, "User: "+clip(entry.Text, replayEntryChars))
+		case EntryResponse:
+			lines = append(lines, "Claude: "+clip(entry.Text, replayEntryChars))
+		case EntryToolCall:
+			lines = append(lines, "Claude used "+clip(toolCallSummary(entry), replayEntryChars))
+		}
+	}
+	return lines
+}
+
+// recentLines returns the index of the first of the most recent lines that
+// fit in maxChars
+func recentLines(lines []string, maxChars int) int {
+	start, size := len(lines), 0
+	for start > 0 && size+len(lines[start-1]) < maxChars {
+		start--
+		size += len(lines[start]) + 1
+	}
+	return start
+}
+
+// toolCallSummary describes a tool call by its command or file
+func toolCallSummary(entry TranscriptEntry) string {
+	var input struct {
+		Command  string `json:"command"`
+		FilePath string `json:"file_path"`
+		Pattern  string `json:"pattern"`
+	}
+	json.Unmarshal(entry.Input, &input)
+	switch {
+	case input.Command != "":
+		return fmt.Sprintf("%s: %s", entry.Tool, input.Command)
+	case input.FilePath != "":
+		return fmt.Sprintf("%s on %s", entry.Tool, input.FilePath)
+	case input.Pattern != "":
+		return fmt.Sprintf("%s for %s", entry.Tool, input.Pattern)
+	}
+	return entry.Tool
+}
+
+// clip shortens text to at most n characters, marking the cut
+func clip(text string, n int) string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	return string([]rune(text)[:n]) + "…"
+}
+
+// replaySession starts a new conversation for a session the CLI couldn't
+// resume. Its stored summary, or else its saved transcript condensed, is sent
+// ahead of the next prompt.
+func (cs *ClaudeService) replaySession(dbSession *models.ClaudeSession, dirs []Directory, options SessionOptions, resumeErr error) (*Process, error) {
+	replay := summaryPrompt(dbSession)
+	if replay == "" {
+		replay = replayPrompt(dbSession)
+	}
+	if replay == "" {
+		return nil, resumeErr
+	}
+
+	process, err := cs.service.startSession(usableDirectories(dirs, dbSession.SessionID), options)
+	if err != nil {
+		return nil, fmt.Errorf("%w, and starting a new conversation failed: %v", resumeErr, err)
+	}
+	process.replay.Store(&replay)
+	process.replayed.Store(true)
+	metrics.ClaudeSessionReplaysTotal.Inc()
+
+	slog.Warn("Replaying transcript into a new Claude conversation",
+		"correlation_id", process.correlationID,
+		"session_id", dbSession.SessionID,
+		"claude_session_id", process.sessionID,
+		"summary_length", len(replay),
+		"resume_error", resumeErr,
+		"action", "session_replayed",
+	)
+	return process, nil
+}
+
+// Replayed reports whether the process started a new conversation from a
+// summary of the session's transcript because the CLI couldn't resume it
+func (p *Process) Replayed() bool {
+	return p.replayed.Load()
+}
@@ -0,0 +1,72 @@
+package claude
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/breadchris/flow/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// transcriptSession builds a session whose saved transcript holds messages
+func transcriptSession(t *testing.T, messages ...string) *models.ClaudeSession {
+	var data []interface{}
+	for _, msg := range messages {
+		var decoded interface{}
+		require.NoError(t, json.Unmarshal([]byte(msg), &decoded))
+		data = append(data, decoded)
+	}
+	return &models.ClaudeSession{SessionID: "flow-1", Messages: models.JSONField[interface{}]{Data: data}}
+}
+
+func TestReplayPrompt(t *testing.T) {
+	session := transcriptSession(t,
+		`{"type":"user","message":{"role":"user","content":[{"type":"text","text":"Why is the build failing?"}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"Let me check."},{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"go build ./..."}}]}}`,
+		`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"t1","content":"undefined: foo"}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","id":"t2","name":"Edit","input":{"file_path":"main.go","old_string":"foo","new_string":"bar"}}]}}`,
+		`{"type":"result","result":"Fixed it.","total_cost_usd":0.1}`,
+	)
+
+	prompt := replayPrompt(session)
+	assert.Contains(t, prompt, "<previous_conversation>\nUser: Why is the build failing?\nClaude: Let me check.\nClaude used Bash: go build ./...\nClaude used Edit on main.go\n</previous_conversation>")
+	assert.NotContains(t, prompt, "undefined: foo")
+
+	assert.Empty(t, replayPrompt(transcriptSession(t)))
+}
+
+func TestReplayPromptKeepsRecentEntries(t *testing.T) {
+	var messages []string
+	for i := 0; i < 40; i++ {
+		text := strings.Repeat("x", 1000)
+		messages = append(messages, `{"type":"user","message":{"role":"user","content":[{"type":"text","text":"`+text+`"}]}}`)
+	}
+	messages = append(messages, `{"type":"user","message":{"role":"user","content":[{"type":"text","text":"latest"}]}}`)
+
+	prompt := replayPrompt(transcriptSession(t, messages...))
+	assert.Less(t, len(prompt), replayMaxChars+500)
+	assert.Contains(t, prompt, "earlier entries left out")
+	assert.Contains(t, prompt, "User: latest\n</previous_conversation>")
+	assert.Contains(t, prompt, "x…\n")
+}
+
+func TestSendMessageReplaysSummaryOnce(t *testing.T) {
+	s := NewService(Config{})
+	process := newIdleProcess("cli-1")
+	summary := "<previous_conversation>\nUser: hi\n</previous_conversation>\n\n"
+	process.replay.Store(&summary)
+
+	require.NoError(t, s.SendMessage(process, "what next?"))
+	first := <-process.inputChan
+	assert.Equal(t, summary+"what next?", first.Message.Content[0].Text)
+
+	// The transcript keeps what the user wrote
+	saved := process.transcript.take()
+	require.Len(t, saved, 1)
+	assert.NotContains(t, string(saved[0].Message), "previous_conversation")
+
+	require.NoError(t, s.SendMessage(process, "and then?"))
+	assert.Equal(t, "and then?", (<-process.inputChan).Message.Content[0].Text)
+}
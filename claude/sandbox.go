@@ -0,0 +1,184 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+
+	"github.com/breadchris/flow/config"
+)
+
+// Sandbox modes for config.ClaudeSandboxConfig.Mode
+const (
+	SandboxNone     = ""
+	SandboxSystemd  = "systemd"
+	SandboxFirejail = "firejail"
+	SandboxDocker   = "docker"
+)
+
+const megabyte = 1024 * 1024
+
+// sandboxCommand returns the command that runs the Claude CLI with args under
+// the configured sandbox. workDir is the CLI's working directory, if any, and
+// dirs are the directories the session works in.
+func sandboxCommand(ctx context.Context, sandbox config.ClaudeSandboxConfig, workDir string, dirs []Directory, args []string) (*exec.Cmd, error) {
+	argv, err := sandboxArgv(sandbox, workDir, dirs)
+	if err != nil {
+		return nil, err
+	}
+	argv = append(argv, "claude")
+	argv = append(argv, args...)
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = workDir
+
+	// runuser and systemd-run keep the server's environment, so point the CLI
+	// at the sandbox user's home for its settings and credentials
+	if sandbox.User != "" && sandbox.Mode != SandboxDocker {
+		u, err := user.Lookup(sandbox.User)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up sandbox user: %w", err)
+		}
+		cmd.Env = append(os.Environ(), "HOME="+u.HomeDir, "USER="+u.Username)
+	}
+	return cmd, nil
+}
+
+// sandboxArgv returns the command line that the claude command and its
+// arguments are appended to; it's empty when nothing needs wrapping
+func sandboxArgv(sandbox config.ClaudeSandboxConfig, workDir string, dirs []Directory) ([]string, error) {
+	switch sandbox.Mode {
+	case SandboxNone:
+		return append(runuserArgs(sandbox), prlimitArgs(sandbox, false)...), nil
+	case SandboxSystemd:
+		return append(systemdArgs(sandbox), prlimitArgs(sandbox, true)...), nil
+	case SandboxFirejail:
+		return append(runuserArgs(sandbox), firejailArgs(sandbox)...), nil
+	case SandboxDocker:
+		if sandbox.Image == "" {
+			return nil, fmt.Errorf("docker sandbox requires an image")
+		}
+		return dockerArgs(sandbox, workDir, dirs), nil
+	}
+	return nil, fmt.Errorf("unknown sandbox mode %q", sandbox.Mode)
+}
+
+// runuserArgs switches to the sandbox user, if one is set
+func runuserArgs(sandbox config.ClaudeSandboxConfig) []string {
+	if sandbox.User == "" {
+		return nil
+	}
+	return []string{"runuser", "-u", sandbox.User, "--"}
+}
+
+// prlimitArgs applies the limits as ulimits. Under cgroups, memory and
+// process counts are left to the cgroup, which covers the whole process tree.
+func prlimitArgs(sandbox config.ClaudeSandboxConfig, cgroups bool) []string {
+	var limits []string
+	if sandbox.CPUTime > 0 {
+		limits = append(limits, "--cpu="+strconv.Itoa(int(sandbox.CPUTime.Seconds())))
+	}
+	if sandbox.MaxOpenFiles > 0 {
+		limits = append(limits, "--nofile="+strconv.Itoa(sandbox.MaxOpenFiles))
+	}
+	if sandbox.MaxFileSizeMB > 0 {
+		limits = append(limits, "--fsize="+strconv.Itoa(sandbox.MaxFileSizeMB*megabyte))
+	}
+	if !cgroups && sandbox.MaxProcesses > 0 {
+		limits = append(limits, "--nproc="+strconv.Itoa(sandbox.MaxProcesses))
+	}
+	if !cgroups && sandbox.MemoryMB > 0 {
+		limits = append(limits, "--as="+strconv.Itoa(sandbox.MemoryMB*megabyte))
+	}
+	if len(limits) == 0 {
+		return nil
+	}
+	return append(append([]string{"prlimit"}, limits...), "--")
+}
+
+// systemdArgs runs the CLI in a transient scope whose cgroup carries the memory, CPU, and task limits
+func systemdArgs(sandbox config.ClaudeSandboxConfig) []string {
+	args := []string{"systemd-run", "--scope", "--quiet", "--collect"}
+	if sandbox.User != "" {
+		args = append(args, "--uid="+sandbox.User)
+	}
+	if sandbox.MemoryMB > 0 {
+		args = append(args, "-p", fmt.Sprintf("MemoryMax=%dM", sandbox.MemoryMB))
+	}
+	if sandbox.CPUs > 0 {
+		args = append(args, "-p", fmt.Sprintf("CPUQuota=%d%%", int(sandbox.CPUs*100)))
+	}
+	if sandbox.MaxProcesses > 0 {
+		args = append(args, "-p", "TasksMax="+strconv.Itoa(sandbox.MaxProcesses))
+	}
+	args = append(args, sandbox.Args...)
+	return append(args, "--")
+}
+
+// firejailArgs runs the CLI under firejail with the limits as rlimits
+func firejailArgs(sandbox config.ClaudeSandboxConfig) []string {
+	args := []string{"firejail", "--quiet"}
+	if sandbox.CPUTime > 0 {
+		args = append(args, "--rlimit-cpu="+strconv.Itoa(int(sandbox.CPUTime.Seconds())))
+	}
+	if sandbox.MaxOpenFiles > 0 {
+		args = append(args, "--rlimit-nofile="+strconv.Itoa(sandbox.MaxOpenFiles))
+	}
+	if sandbox.MaxFileSizeMB > 0 {
+		args = append(args, "--rlimit-fsize="+strconv.Itoa(sandbox.MaxFileSizeMB*megabyte))
+	}
+	if sandbox.MaxProcesses > 0 {
+		args = append(args, "--rlimit-nproc="+strconv.Itoa(sandbox.MaxProcesses))
+	}
+	if sandbox.MemoryMB > 0 {
+		args = append(args, "--rlimit-as="+strconv.Itoa(sandbox.MemoryMB*megabyte))
+	}
+	return append(args, sandbox.Args...)
+}
+
+// dockerArgs runs the CLI in a throwaway container with the session's
+// directories mounted at the same paths, so --add-dir and file paths in
+// Claude's output still make sense on the host. Read-only directories are
+// mounted read-only, which also keeps Bash from writing to them.
+func dockerArgs(sandbox config.ClaudeSandboxConfig, workDir string, dirs []Directory) []string {
+	args := []string{"docker", "run", "--rm", "--interactive", "--init"}
+	if sandbox.User != "" {
+		args = append(args, "--user", sandbox.User)
+	}
+	if sandbox.MemoryMB > 0 {
+		args = append(args, "--memory", strconv.Itoa(sandbox.MemoryMB)+"m")
+	}
+	if sandbox.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(sandbox.CPUs, 'f', -1, 64))
+	}
+	if sandbox.MaxProcesses > 0 {
+		args = append(args, "--pids-limit", strconv.Itoa(sandbox.MaxProcesses))
+	}
+	if sandbox.CPUTime > 0 {
+		args = append(args, "--ulimit", "cpu="+strconv.Itoa(int(sandbox.CPUTime.Seconds())))
+	}
+	if sandbox.MaxOpenFiles > 0 {
+		args = append(args, "--ulimit", fmt.Sprintf("nofile=%d:%d", sandbox.MaxOpenFiles, sandbox.MaxOpenFiles))
+	}
+	if sandbox.MaxFileSizeMB > 0 {
+		args = append(args, "--ulimit", "fsize="+strconv.Itoa(sandbox.MaxFileSizeMB*megabyte))
+	}
+	for _, dir := range dirs {
+		if dir.Path == "" {
+			continue
+		}
+		volume := dir.Path + ":" + dir.Path
+		if dir.readOnly() {
+			volume += ":ro"
+		}
+		args = append(args, "--volume", volume)
+	}
+	if workDir != "" {
+		args = append(args, "--workdir", workDir)
+	}
+	args = append(args, sandbox.Args...)
+	return append(args, sandbox.Image)
+}
@@ -0,0 +1,89 @@
+package claude
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandboxCommandRunsCLIDirectlyByDefault(t *testing.T) {
+	cmd, err := sandboxCommand(context.Background(), config.ClaudeSandboxConfig{}, "/repo", []Directory{ReadWriteDir("/repo")}, []string{"--print"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"claude", "--print"}, cmd.Args)
+	assert.Equal(t, "/repo", cmd.Dir)
+	assert.Nil(t, cmd.Env)
+}
+
+func TestSandboxArgvUlimits(t *testing.T) {
+	argv, err := sandboxArgv(config.ClaudeSandboxConfig{
+		User:         "claude",
+		MemoryMB:     2048,
+		CPUTime:      time.Hour,
+		MaxProcesses: 64,
+	}, "/repo", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"runuser", "-u", "claude", "--",
+		"prlimit", "--cpu=3600", "--nproc=64", "--as=2147483648", "--",
+	}, argv)
+}
+
+func TestSandboxArgvSystemdUsesCgroupLimits(t *testing.T) {
+	argv, err := sandboxArgv(config.ClaudeSandboxConfig{
+		Mode:         SandboxSystemd,
+		User:         "claude",
+		MemoryMB:     2048,
+		CPUs:         1.5,
+		MaxProcesses: 64,
+		MaxOpenFiles: 1024,
+	}, "/repo", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"systemd-run", "--scope", "--quiet", "--collect", "--uid=claude",
+		"-p", "MemoryMax=2048M", "-p", "CPUQuota=150%", "-p", "TasksMax=64", "--",
+		"prlimit", "--nofile=1024", "--",
+	}, argv)
+}
+
+func TestSandboxArgvFirejail(t *testing.T) {
+	argv, err := sandboxArgv(config.ClaudeSandboxConfig{
+		Mode:         SandboxFirejail,
+		MaxProcesses: 64,
+		Args:         []string{"--private-tmp"},
+	}, "/repo", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"firejail", "--quiet", "--rlimit-nproc=64", "--private-tmp"}, argv)
+}
+
+func TestSandboxArgvDockerMountsSessionDirs(t *testing.T) {
+	sandbox := config.ClaudeSandboxConfig{
+		Mode:     SandboxDocker,
+		Image:    "claude-cli",
+		User:     "1000",
+		MemoryMB: 2048,
+		CPUs:     2,
+		Args:     []string{"-e", "ANTHROPIC_API_KEY"},
+	}
+	argv, err := sandboxArgv(sandbox, "/repo", []Directory{ReadWriteDir("/repo"), {}, ReadOnlyDir("/uploads"), {Path: "/shared"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"docker", "run", "--rm", "--interactive", "--init",
+		"--user", "1000", "--memory", "2048m", "--cpus", "2",
+		"--volume", "/repo:/repo", "--volume", "/uploads:/uploads:ro", "--volume", "/shared:/shared",
+		"--workdir", "/repo",
+		"-e", "ANTHROPIC_API_KEY", "claude-cli",
+	}, argv)
+
+	sandbox.Image = ""
+	_, err = sandboxArgv(sandbox, "/repo", nil)
+	assert.Error(t, err)
+}
+
+func TestSandboxArgvUnknownMode(t *testing.T) {
+	_, err := sandboxArgv(config.ClaudeSandboxConfig{Mode: "jail"}, "/repo", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,178 @@
+package claude
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/metrics"
+)
+
+// ErrQueueTimeout is returned when a session waits longer than the queue timeout for a process slot
+var ErrQueueTimeout = errors.New("timed out waiting for a free Claude process")
+
+// Scheduler caps how many Claude processes run at once, overall and per user.
+// Requests over the cap wait in a queue. When a slot frees up it goes to the
+// waiting user with the fewest running processes, oldest request first, so
+// one busy user can't starve the rest.
+type Scheduler struct {
+	maxProcesses int
+	maxPerUser   int
+	queueTimeout time.Duration
+
+	mu      sync.Mutex
+	running int
+	perUser map[string]int
+	queue   []*queuedRequest
+}
+
+// queuedRequest is a caller waiting in Acquire
+type queuedRequest struct {
+	userID   string
+	position int
+	moved    chan int      // Latest position, reported by the waiting goroutine
+	granted  chan struct{} // Closed when the request gets a slot
+}
+
+func newScheduler(cfg config.ClaudeSchedulerConfig) *Scheduler {
+	return &Scheduler{
+		maxProcesses: cfg.MaxProcesses,
+		maxPerUser:   cfg.MaxPerUser,
+		queueTimeout: cfg.QueueTimeout,
+		perUser:      make(map[string]int),
+	}
+}
+
+// Acquire waits for a process slot for userID and returns the function that
+// frees it. While waiting, onQueued (if not nil) is called with the request's
+// 1-based place in line whenever it changes. Requests without a user only
+// count toward the overall cap.
+func (s *Scheduler) Acquire(userID string, onQueued func(position int)) (release func(), err error) {
+	if s == nil {
+		return func() {}, nil
+	}
+
+	s.mu.Lock()
+	// Waiting requests are granted as soon as they can run, so anyone still
+	// queued is blocked and there's no one to cut in front of
+	if s.canRun(userID) {
+		s.start(userID)
+		s.mu.Unlock()
+		return s.releaseFunc(userID), nil
+	}
+	request := &queuedRequest{
+		userID:  userID,
+		moved:   make(chan int, 1),
+		granted: make(chan struct{}),
+	}
+	s.queue = append(s.queue, request)
+	s.updatePositions()
+	s.mu.Unlock()
+
+	queuedAt := time.Now()
+	defer func() {
+		metrics.ClaudeQueueWaitDuration.Observe(time.Since(queuedAt).Seconds())
+	}()
+
+	var timeout <-chan time.Time
+	if s.queueTimeout > 0 {
+		timer := time.NewTimer(s.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		select {
+		case <-request.granted:
+			return s.releaseFunc(userID), nil
+		case position := <-request.moved:
+			if onQueued != nil {
+				onQueued(position)
+			}
+		case <-timeout:
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			select {
+			case <-request.granted:
+				return s.releaseFunc(userID), nil
+			default:
+			}
+			s.queue = slices.DeleteFunc(s.queue, func(r *queuedRequest) bool { return r == request })
+			s.updatePositions()
+			return nil, ErrQueueTimeout
+		}
+	}
+}
+
+// canRun reports whether userID may start a process now
+func (s *Scheduler) canRun(userID string) bool {
+	if s.maxProcesses > 0 && s.running >= s.maxProcesses {
+		return false
+	}
+	return userID == "" || s.maxPerUser <= 0 || s.perUser[userID] < s.maxPerUser
+}
+
+func (s *Scheduler) start(userID string) {
+	s.running++
+	s.perUser[userID]++
+}
+
+// releaseFunc returns the function that frees a slot held by userID; calls after the first do nothing
+func (s *Scheduler) releaseFunc(userID string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.running--
+			if s.perUser[userID]--; s.perUser[userID] <= 0 {
+				delete(s.perUser, userID)
+			}
+			s.dispatch()
+		})
+	}
+}
+
+// dispatch grants slots to waiting requests for as long as any can run,
+// preferring users with the fewest running processes
+func (s *Scheduler) dispatch() {
+	for {
+		next := -1
+		for i, request := range s.queue {
+			if !s.canRun(request.userID) {
+				continue
+			}
+			if next < 0 || s.perUser[request.userID] < s.perUser[s.queue[next].userID] {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+
+		request := s.queue[next]
+		s.queue = slices.Delete(s.queue, next, next+1)
+		s.start(request.userID)
+		close(request.granted)
+	}
+	s.updatePositions()
+}
+
+// updatePositions tells each waiting request its place in line if it has changed
+func (s *Scheduler) updatePositions() {
+	for i, request := range s.queue {
+		if request.position == i+1 {
+			continue
+		}
+		request.position = i + 1
+		// Only the latest position matters to the waiting goroutine
+		select {
+		case <-request.moved:
+		default:
+		}
+		request.moved <- request.position
+	}
+	metrics.ClaudeQueueLength.Set(float64(len(s.queue)))
+}
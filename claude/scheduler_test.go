@@ -0,0 +1,162 @@
+package claude
+
+import (
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// acquireAsync calls Acquire in the background and reports the result on the returned channel
+func acquireAsync(s *Scheduler, userID string, onQueued func(int)) <-chan func() {
+	granted := make(chan func(), 1)
+	go func() {
+		release, err := s.Acquire(userID, onQueued)
+		if err == nil {
+			granted <- release
+		}
+		close(granted)
+	}()
+	return granted
+}
+
+// waitQueued blocks until n requests are waiting in the scheduler
+func waitQueued(t *testing.T, s *Scheduler, n int) {
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.queue) == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestSchedulerGlobalCap(t *testing.T) {
+	s := newScheduler(config.ClaudeSchedulerConfig{MaxProcesses: 1})
+
+	release, err := s.Acquire("u1", nil)
+	require.NoError(t, err)
+
+	waiting := acquireAsync(s, "u2", nil)
+	waitQueued(t, s, 1)
+	select {
+	case <-waiting:
+		t.Fatal("second request started over the global cap")
+	default:
+	}
+
+	release()
+	select {
+	case next := <-waiting:
+		require.NotNil(t, next)
+		next()
+	case <-time.After(time.Second):
+		t.Fatal("queued request was not started after a slot freed up")
+	}
+	assert.Equal(t, 0, s.running)
+}
+
+func TestSchedulerPerUserCap(t *testing.T) {
+	s := newScheduler(config.ClaudeSchedulerConfig{MaxProcesses: 10, MaxPerUser: 1})
+
+	_, err := s.Acquire("u1", nil)
+	require.NoError(t, err)
+	// Another user and requests without a user are unaffected
+	_, err = s.Acquire("u2", nil)
+	require.NoError(t, err)
+	_, err = s.Acquire("", nil)
+	require.NoError(t, err)
+	_, err = s.Acquire("", nil)
+	require.NoError(t, err)
+
+	acquireAsync(s, "u1", nil)
+	waitQueued(t, s, 1)
+}
+
+func TestSchedulerPrefersUsersWithFewestProcesses(t *testing.T) {
+	s := newScheduler(config.ClaudeSchedulerConfig{MaxProcesses: 4})
+
+	var releases []func()
+	for _, userID := range []string{"busy", "busy", "busy", "light"} {
+		release, err := s.Acquire(userID, nil)
+		require.NoError(t, err)
+		releases = append(releases, release)
+	}
+
+	busy := acquireAsync(s, "busy", nil)
+	waitQueued(t, s, 1)
+	light := acquireAsync(s, "light", nil)
+	waitQueued(t, s, 2)
+
+	// "light" queued later but runs fewer processes, so it goes first
+	releases[0]()
+	select {
+	case <-light:
+	case <-time.After(time.Second):
+		t.Fatal("request from the user with fewer processes was not started")
+	}
+	select {
+	case <-busy:
+		t.Fatal("busy user's request started before the lighter user's")
+	default:
+	}
+}
+
+func TestSchedulerReportsQueuePosition(t *testing.T) {
+	s := newScheduler(config.ClaudeSchedulerConfig{MaxProcesses: 1})
+	release, err := s.Acquire("u1", nil)
+	require.NoError(t, err)
+
+	acquireAsync(s, "u2", nil)
+	waitQueued(t, s, 1)
+
+	positions := make(chan int, 10)
+	waiting := acquireAsync(s, "u3", func(position int) { positions <- position })
+	waitQueued(t, s, 2)
+	assert.Equal(t, 2, <-positions)
+
+	// The first waiter takes the freed slot and u3 moves up
+	release()
+	assert.Equal(t, 1, <-positions)
+	select {
+	case <-waiting:
+		t.Fatal("u3 started while the only slot was taken")
+	default:
+	}
+}
+
+func TestSchedulerQueueTimeout(t *testing.T) {
+	s := newScheduler(config.ClaudeSchedulerConfig{MaxProcesses: 1, QueueTimeout: 20 * time.Millisecond})
+	_, err := s.Acquire("u1", nil)
+	require.NoError(t, err)
+
+	_, err = s.Acquire("u2", nil)
+	assert.ErrorIs(t, err, ErrQueueTimeout)
+	assert.Empty(t, s.queue)
+}
+
+func TestSchedulerReleaseIsIdempotent(t *testing.T) {
+	s := newScheduler(config.ClaudeSchedulerConfig{MaxProcesses: 2})
+	first, err := s.Acquire("u1", nil)
+	require.NoError(t, err)
+	_, err = s.Acquire("u1", nil)
+	require.NoError(t, err)
+
+	first()
+	first()
+	assert.Equal(t, 1, s.running)
+	assert.Equal(t, map[string]int{"u1": 1}, s.perUser)
+}
+
+func TestStopProcessReleasesSlot(t *testing.T) {
+	s := NewService(Config{Scheduler: config.ClaudeSchedulerConfig{MaxProcesses: 1}})
+	release, err := s.scheduler.Acquire("u1", nil)
+	require.NoError(t, err)
+
+	process := newIdleProcess("p1")
+	process.holdSlot(release)
+	s.stopProcess(process.sessionID, process, time.Now())
+
+	assert.Equal(t, 0, s.scheduler.running)
+	assert.Nil(t, process.takeSlot())
+}
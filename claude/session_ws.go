@@ -0,0 +1,336 @@
+package claude
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/breadchris/flow/code"
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/deps"
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/models"
+	"github.com/breadchris/flow/session"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+const (
+	wsWriteWait     = 10 * time.Second
+	wsPongWait      = 60 * time.Second
+	wsPingInterval  = 30 * time.Second
+	wsMaxPromptSize = 64 * 1024
+	wsPromptTimeout = 5 * time.Minute
+	wsQueuedPrompts = 8 // Prompts a connection may have waiting for the session's turn
+)
+
+// componentExtensions are the files Claude's edits to are type-checked
+var componentExtensions = []string{".tsx", ".ts", ".jsx"}
+
+// typeCheckPayload is sent after Claude edits a component, with the type
+// errors tsc found in it
+type typeCheckPayload struct {
+	File        string                `json:"file"`
+	Diagnostics []code.TypeDiagnostic `json:"diagnostics"`
+}
+
+// SessionStreamHandler streams a session's Claude messages and tool events to
+// the browser over a WebSocket and forwards prompts sent by the client
+type SessionStreamHandler struct {
+	cs       *ClaudeService
+	sessions *session.SessionManager
+	config   config.AppConfig
+	upgrader websocket.Upgrader
+	types    *code.TypeChecker // Set when component edits are type-checked
+}
+
+// NewSessionStreamHandler creates the handler for GET /api/sessions/{id}/ws
+func NewSessionStreamHandler(cs *ClaudeService, d deps.Deps) *SessionStreamHandler {
+	h := &SessionStreamHandler{
+		cs:       cs,
+		sessions: d.Session,
+		config:   d.Config,
+	}
+	h.upgrader = websocket.Upgrader{CheckOrigin: h.checkOrigin}
+	if d.Config.Code.TypeCheck {
+		h.types = code.NewTypeChecker(d.Config.Code)
+	}
+	return h
+}
+
+func (h *SessionStreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	dbSession, userID, ok := authorizeSession(h.cs, h.sessions, h.config, w, r)
+	if !ok {
+		return
+	}
+	sessionID := dbSession.SessionID
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.Error("Failed to upgrade session WebSocket", "session_id", sessionID, "error", err)
+		return
+	}
+
+	slog.Info("Session WebSocket connected", "session_id", sessionID, "user_id", userID)
+	h.stream(conn, dbSession)
+	slog.Info("Session WebSocket disconnected", "session_id", sessionID, "user_id", userID)
+}
+
+// authorizeSession loads the session named by the {id} route variable and
+// checks that the signed-in user owns it or is an admin. It writes an error
+// response and reports false when the request may not access the session.
+func authorizeSession(cs *ClaudeService, sessions *session.SessionManager, cfg config.AppConfig, w http.ResponseWriter, r *http.Request) (*models.ClaudeSession, string, bool) {
+	sessionID := mux.Vars(r)["id"]
+	if sessionID == "" {
+		http.Error(w, "Session ID required", http.StatusBadRequest)
+		return nil, "", false
+	}
+
+	if sessions == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, "", false
+	}
+	userID, err := sessions.UserIDFromRequest(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, "", false
+	}
+
+	var dbSession models.ClaudeSession
+	if err := cs.db.Where("session_id = ?", sessionID).First(&dbSession).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return nil, "", false
+		}
+		http.Error(w, fmt.Sprintf("Failed to get session: %v", err), http.StatusInternalServerError)
+		return nil, "", false
+	}
+	if dbSession.UserID != userID && !cfg.IsAdmin(userID) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, "", false
+	}
+	return &dbSession, userID, true
+}
+
+// checkOrigin allows same-host browsers and origins permitted by the CORS configuration
+func (h *SessionStreamHandler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return true
+	}
+	return slices.Contains(h.config.CORS.AllowedOrigins, origin) ||
+		slices.Contains(h.config.CORS.AllowedOrigins, "*")
+}
+
+// stream relays session.message events for the session until the client disconnects
+func (h *SessionStreamHandler) stream(conn *websocket.Conn, dbSession *models.ClaudeSession) {
+	defer conn.Close()
+
+	messages, unsubscribe := events.Channel(h.cs.events, events.TopicSessionMessage)
+	defer unsubscribe()
+	output := h.cs.Output(OutputHTTP)
+
+	var writeMu sync.Mutex
+	send := func(msgType string, payload any) error {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s payload: %w", msgType, err)
+		}
+
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		return conn.WriteJSON(WSMessage{Type: msgType, Payload: data, Timestamp: time.Now().UnixMilli()})
+	}
+
+	if err := send("connection", map[string]string{"status": "connected", "session_id": dbSession.SessionID}); err != nil {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.readPrompts(conn, dbSession, send)
+	}()
+
+	// Tool calls that edit components, by ID, until their results arrive
+	edits := make(map[string]string)
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+
+		case msg := <-messages:
+			if msg.SessionID != dbSession.SessionID {
+				continue
+			}
+			if err := send("message", applyOutput(output, msg)); err != nil {
+				slog.Debug("Failed to write session message", "session_id", dbSession.SessionID, "error", err)
+				return
+			}
+			if h.types != nil {
+				h.checkEdits(msg, edits, send)
+			}
+
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// checkEdits type-checks the components Claude edits once the edits are
+// made, and sends the client what tsc found
+func (h *SessionStreamHandler) checkEdits(msg events.SessionMessage, edits map[string]string, send func(string, any) error) {
+	for _, event := range ParseToolEvents(Message{Type: msg.Type, Message: msg.Message}) {
+		switch event.Type {
+		case ToolCall:
+			if event.Edit != nil && filepath.IsAbs(event.Edit.FilePath) && slices.Contains(componentExtensions, filepath.Ext(event.Edit.FilePath)) {
+				edits[event.ToolUseID] = event.Edit.FilePath
+			}
+		case ToolResult:
+			path, ok := edits[event.ToolUseID]
+			if !ok {
+				continue
+			}
+			delete(edits, event.ToolUseID)
+			if event.IsError {
+				continue
+			}
+			go func() {
+				diagnostics, err := h.types.Check(path, nil)
+				if err != nil {
+					slog.Warn("Failed to type-check edited component", "session_id", msg.SessionID, "path", path, "error", err)
+					return
+				}
+				send("typecheck", typeCheckPayload{File: path, Diagnostics: diagnostics})
+			}()
+		}
+	}
+}
+
+// applyOutput runs an output pipeline over a session.message event
+func applyOutput(output *OutputPipeline, event events.SessionMessage) events.SessionMessage {
+	msg := output.Apply(Message{Type: event.Type, Message: event.Message, Result: event.Result, Text: event.Text})
+	event.Message, event.Result, event.Text = msg.Message, msg.Result, msg.Text
+	return event
+}
+
+// readPrompts handles client messages until the connection fails or closes
+func (h *SessionStreamHandler) readPrompts(conn *websocket.Conn, dbSession *models.ClaudeSession, send func(string, any) error) {
+	conn.SetReadLimit(wsMaxPromptSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	// Prompts wait for the session's turn off the read loop, which keeps answering pings
+	prompts := make(chan string, wsQueuedPrompts)
+	defer close(prompts)
+	go h.runPrompts(dbSession, prompts, send)
+
+	for {
+		var wsMsg WSMessage
+		if err := conn.ReadJSON(&wsMsg); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				slog.Debug("Session WebSocket read failed", "session_id", dbSession.SessionID, "error", err)
+			}
+			return
+		}
+
+		switch wsMsg.Type {
+		case "prompt":
+			var promptData struct {
+				Prompt string `json:"prompt"`
+			}
+			if err := json.Unmarshal(wsMsg.Payload, &promptData); err != nil || promptData.Prompt == "" {
+				send("error", map[string]string{"error": "prompt is required"})
+				continue
+			}
+			select {
+			case prompts <- promptData.Prompt:
+			default:
+				send("error", map[string]string{"error": "too many prompts are waiting for Claude"})
+			}
+
+		default:
+			send("error", map[string]string{"error": fmt.Sprintf("unknown message type %q", wsMsg.Type)})
+		}
+	}
+}
+
+// runPrompts sends the client's prompts to Claude one turn at a time, in the
+// order they arrived
+func (h *SessionStreamHandler) runPrompts(dbSession *models.ClaudeSession, prompts <-chan string, send func(string, any) error) {
+	for prompt := range prompts {
+		if err := h.sendPrompt(dbSession, prompt); err != nil {
+			send("error", map[string]string{"error": err.Error()})
+		}
+	}
+}
+
+// sendPrompt takes the session's turn, delivers prompt to its Claude process,
+// resuming it if needed, and holds the turn until Claude has answered.
+// Responses reach the client through the event bus.
+func (h *SessionStreamHandler) sendPrompt(dbSession *models.ClaudeSession, prompt string) error {
+	turn := h.cs.TurnLock(dbSession.SessionID)
+	turn.Lock()
+	defer turn.Unlock()
+
+	process, exists := h.cs.GetProcess(dbSession.SessionID)
+	if !exists {
+		resumed, err := h.cs.ResumeSession(dbSession.SessionID, dbSession.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to resume session: %w", err)
+		}
+		process = resumed
+	}
+
+	if err := h.cs.SendMessage(process, prompt); err != nil {
+		return fmt.Errorf("failed to send prompt: %w", err)
+	}
+	if err := h.cs.UpdateSessionActivity(dbSession.SessionID); err != nil {
+		slog.Warn("Failed to update session activity", "session_id", dbSession.SessionID, "error", err)
+	}
+
+	h.awaitResult(process)
+	return nil
+}
+
+// awaitResult waits for Claude to finish its turn. The client is sent the
+// turn's messages from the event bus; the output channel is only drained
+// here, since the process blocks until it is read and a result left in it
+// would end the next holder's turn early.
+func (h *SessionStreamHandler) awaitResult(process *Process) {
+	timeout := time.NewTimer(wsPromptTimeout)
+	defer timeout.Stop()
+
+	output := h.cs.ReceiveMessages(process)
+	for {
+		select {
+		case msg, ok := <-output:
+			if !ok || msg.Type == "result" {
+				return
+			}
+		case <-timeout.C:
+			slog.Warn("Timed out waiting for Claude response", "session_id", process.FlowSessionID())
+			return
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package claude
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/deps"
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/models"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSessionStreamCheckOrigin(t *testing.T) {
+	cfg := config.AppConfig{CORS: config.CORSConfig{AllowedOrigins: []string{"http://localhost:5173"}}}
+	h := NewSessionStreamHandler(&ClaudeService{}, deps.Deps{Config: cfg})
+
+	tests := []struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{"no origin", "", true},
+		{"same host", "https://flow.example.com", true},
+		{"allowed origin", "http://localhost:5173", true},
+		{"other origin", "https://evil.example.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "https://flow.example.com/api/sessions/s1/ws", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			assert.Equal(t, tt.want, h.checkOrigin(req))
+		})
+	}
+}
+
+func TestSessionStreamRequiresLogin(t *testing.T) {
+	h := NewSessionStreamHandler(&ClaudeService{}, deps.Deps{})
+
+	router := mux.NewRouter()
+	router.Handle("/api/sessions/{id}/ws", h)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/sessions/s1/ws", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestSessionStreamTypeChecksEditedComponents(t *testing.T) {
+	dir := t.TempDir()
+	component := filepath.Join(dir, "App.tsx")
+	assert.NoError(t, os.WriteFile(component, []byte("const n: string = 1;\n"), 0644))
+	tsc := filepath.Join(dir, "tsc")
+	assert.NoError(t, os.WriteFile(tsc, []byte("#!/bin/sh\necho \"App.tsx(1,7): error TS2322: Type 'number' is not assignable to type 'string'.\"\nexit 2\n"), 0755))
+
+	cfg := config.AppConfig{Code: config.CodeConfig{TypeCheck: true, TSCCommand: tsc}}
+	h := NewSessionStreamHandler(&ClaudeService{}, deps.Deps{Config: cfg})
+	sent := make(chan typeCheckPayload, 1)
+	send := func(msgType string, payload any) error {
+		if msgType == "typecheck" {
+			sent <- payload.(typeCheckPayload)
+		}
+		return nil
+	}
+
+	input, _ := json.Marshal(map[string]string{"file_path": component, "old_string": "a", "new_string": "b"})
+	call, _ := json.Marshal(map[string]any{"content": []map[string]any{
+		{"type": "tool_use", "id": "toolu_1", "name": "Edit", "input": json.RawMessage(input)},
+	}})
+	result, _ := json.Marshal(map[string]any{"content": []map[string]any{
+		{"type": "tool_result", "tool_use_id": "toolu_1", "content": "edited"},
+	}})
+
+	edits := make(map[string]string)
+	h.checkEdits(events.SessionMessage{SessionID: "s1", Type: "assistant", Message: call}, edits, send)
+	h.checkEdits(events.SessionMessage{SessionID: "s1", Type: "user", Message: result}, edits, send)
+
+	select {
+	case payload := <-sent:
+		assert.Equal(t, component, payload.File)
+		if assert.Len(t, payload.Diagnostics, 1) {
+			assert.Equal(t, "TS2322", payload.Diagnostics[0].Code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("edited component wasn't type-checked")
+	}
+	assert.Empty(t, edits)
+}
+
+func TestSessionPromptWaitsForTurn(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	s := NewService(Config{})
+	process := newIdleProcess("flow-1")
+	process.outputChan = make(chan Message, 10)
+	s.sessions[process.sessionID] = process
+	cs := &ClaudeService{service: s, db: db}
+	h := NewSessionStreamHandler(cs, deps.Deps{})
+
+	// Another client, such as the Slack bot, is mid-turn
+	turn := cs.TurnLock("flow-1")
+	turn.Lock()
+
+	prompts := make(chan string, 1)
+	prompts <- "hello"
+	close(prompts)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.runPrompts(&models.ClaudeSession{SessionID: "flow-1"}, prompts, func(string, any) error { return nil })
+	}()
+
+	select {
+	case <-process.inputChan:
+		t.Fatal("prompt was sent during another client's turn")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	turn.Unlock()
+	select {
+	case input := <-process.inputChan:
+		assert.Equal(t, "hello", input.Message.Content[0].Text)
+	case <-time.After(5 * time.Second):
+		t.Fatal("prompt wasn't sent once the turn was free")
+	}
+	assert.False(t, turn.TryLock(), "turn released before Claude answered")
+
+	process.outputChan <- Message{Type: "assistant"}
+	process.outputChan <- Message{Type: "result"}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("turn didn't end at Claude's result")
+	}
+	assert.True(t, turn.TryLock())
+	assert.Empty(t, process.outputChan)
+}
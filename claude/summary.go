@@ -0,0 +1,199 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/breadchris/flow/models"
+)
+
+// summaryInputChars is the most transcript sent to Claude to be summarized
+const summaryInputChars = 60000
+
+// summarizeInstructions asks Claude to condense a conversation for a new one to continue from
+const summarizeInstructions = `Summarize the conversation below between a user and an AI coding assistant. A new conversation will continue from your summary alone, so keep everything it needs: the user's goals, decisions made and why, files and commands involved, the current state of the work, and open questions. Write only the summary.`
+
+// SummarizeSession condenses a session's conversation once it has gone
+// afterTurns turns since it was last summarized. The summary is stored in the
+// session's metadata, and the next time the session is resumed it starts a
+// new conversation from the summary instead of resuming the long one. An idle
+// process is hibernated so that happens with the next prompt. It reports
+// whether the session was summarized.
+func (cs *ClaudeService) SummarizeSession(ctx context.Context, sessionID string, afterTurns int) (bool, error) {
+	if afterTurns <= 0 {
+		return false, nil
+	}
+
+	var dbSession models.ClaudeSession
+	if err := cs.db.Where("session_id = ?", sessionID).First(&dbSession).Error; err != nil {
+		return false, fmt.Errorf("failed to find session: %w", err)
+	}
+	if dbSession.Metadata == nil {
+		return false, nil
+	}
+	export, err := newSessionExport(&dbSession)
+	if err != nil {
+		return false, err
+	}
+
+	turns := countTurns(export.Entries)
+	summarizedTurns := metadataInt(dbSession.Metadata.Data, "summary_turns")
+	if turns-summarizedTurns < afterTurns {
+		return false, nil
+	}
+
+	prompt := summarizeInstructions + "\n\n" + conversationSince(dbSession.Metadata.Data, export.Entries, summaryInputChars)
+	summary, err := cs.Query(ctx, prompt, WithUser(dbSession.UserID))
+	if err != nil {
+		return false, fmt.Errorf("failed to summarize session: %w", err)
+	}
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return false, fmt.Errorf("failed to summarize session: Claude returned an empty summary")
+	}
+
+	// Metadata may have changed while Claude was writing the summary
+	if err := cs.db.Where("session_id = ?", sessionID).First(&dbSession).Error; err != nil {
+		return false, fmt.Errorf("failed to find session: %w", err)
+	}
+	metadata := dbSession.Metadata.Data
+	metadata["summary"] = summary
+	metadata["summary_turns"] = turns
+	metadata["summarized_at"] = time.Now().Format(time.RFC3339)
+	metadata["summary_pending"] = true
+	if err := cs.db.Save(&dbSession).Error; err != nil {
+		return false, fmt.Errorf("failed to save session summary: %w", err)
+	}
+
+	slog.Info("Summarized long Claude session",
+		"session_id", sessionID,
+		"turns", turns,
+		"summary_length", len(summary),
+		"hibernated", cs.hibernateIdle(sessionID),
+		"action", "session_summarized",
+	)
+	return true, nil
+}
+
+// countTurns counts a transcript's turns by their prompts
+func countTurns(entries []TranscriptEntry) int {
+	turns := 0
+	for _, entry := range entries {
+		if entry.Kind == EntryPrompt {
+			turns++
+		}
+	}
+	return turns
+}
+
+// entriesAfterTurn returns the entries of the turns after the first n
+func entriesAfterTurn(entries []TranscriptEntry, n int) []TranscriptEntry {
+	turns := 0
+	for i, entry := range entries {
+		if entry.Kind != EntryPrompt {
+			continue
+		}
+		if turns == n {
+			return entries[i:]
+		}
+		turns++
+	}
+	return nil
+}
+
+// conversationSince writes the session's previous summary, if it has one, and
+// the most recent of the turns after it that fit in maxChars
+func conversationSince(metadata map[string]interface{}, entries []TranscriptEntry, maxChars int) string {
+	var conversation strings.Builder
+	if summary, _ := metadata["summary"].(string); summary != "" {
+		fmt.Fprintf(&conversation, "<conversation_summary>\n%s\n</conversation_summary>\n\n", summary)
+	}
+
+	lines := transcriptLines(entriesAfterTurn(entries, metadataInt(metadata, "summary_turns")))
+	start := recentLines(lines, maxChars)
+	conversation.WriteString("<conversation>\n")
+	if start > 0 {
+		fmt.Fprintf(&conversation, "(%d earlier entries left out)\n", start)
+	}
+	conversation.WriteString(strings.Join(lines[start:], "\n"))
+	conversation.WriteString("\n</conversation>\n")
+	return conversation.String()
+}
+
+// summaryPrompt seeds a new conversation with a session's summary and any
+// turns since it was written. It returns "" when the session has no summary.
+func summaryPrompt(session *models.ClaudeSession) string {
+	if session.Metadata == nil {
+		return ""
+	}
+	if summary, _ := session.Metadata.Data["summary"].(string); summary == "" {
+		return ""
+	}
+	export, err := newSessionExport(session)
+	if err != nil {
+		return ""
+	}
+	return "This conversation continues an earlier one that grew too long and was summarized. Use the summary and the messages after it as context, and answer the message that follows.\n\n" +
+		conversationSince(session.Metadata.Data, export.Entries, replayMaxChars) + "\n"
+}
+
+// startFromSummary starts a new conversation for a session from its summary
+// instead of resuming its long one
+func (cs *ClaudeService) startFromSummary(dbSession *models.ClaudeSession, dirs []Directory, options SessionOptions) (*Process, error) {
+	prompt := summaryPrompt(dbSession)
+	process, err := cs.service.startSession(usableDirectories(dirs, dbSession.SessionID), options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start Claude from the session summary: %w", err)
+	}
+	process.replay.Store(&prompt)
+	process.summarized.Store(true)
+
+	slog.Info("Continuing Claude session from its summary",
+		"correlation_id", process.correlationID,
+		"session_id", dbSession.SessionID,
+		"claude_session_id", process.sessionID,
+		"action", "session_summary_resumed",
+	)
+	return process, nil
+}
+
+// Summarized reports whether the process started a new conversation from the
+// session's summary because the session grew long
+func (p *Process) Summarized() bool {
+	return p.summarized.Load()
+}
+
+// hibernateIdle stops a session's process unless it is in a turn, so the next
+// prompt resumes it. It reports whether the process was stopped.
+func (cs *ClaudeService) hibernateIdle(sessionID string) bool {
+	cs.service.mu.RLock()
+	var key string
+	var process *Process
+	for k, p := range cs.service.sessions {
+		if k == sessionID || p.FlowSessionID() == sessionID {
+			key, process = k, p
+			break
+		}
+	}
+	cs.service.mu.RUnlock()
+
+	if process == nil || process.inTurn.Load() {
+		return false
+	}
+	cs.hibernate(key, process)
+	return true
+}
+
+// metadataInt reads a number stored in session metadata, which JSON decodes as a float64
+func metadataInt(metadata map[string]interface{}, key string) int {
+	switch n := metadata[key].(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
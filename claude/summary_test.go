@@ -0,0 +1,101 @@
+package claude
+
+import (
+	"context"
+	"testing"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/deps"
+	"github.com/breadchris/flow/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// threeTurns is a transcript of three prompts and their replies
+var threeTurns = []string{
+	`{"type":"user","message":{"role":"user","content":[{"type":"text","text":"Add a login page"}]}}`,
+	`{"type":"assistant","message":{"content":[{"type":"text","text":"Added login.go."}]}}`,
+	`{"type":"user","message":{"role":"user","content":[{"type":"text","text":"Now add logout"}]}}`,
+	`{"type":"assistant","message":{"content":[{"type":"text","text":"Added logout."}]}}`,
+	`{"type":"user","message":{"role":"user","content":[{"type":"text","text":"Write tests"}]}}`,
+	`{"type":"assistant","message":{"content":[{"type":"text","text":"Tests pass."}]}}`,
+}
+
+func TestEntriesAfterTurn(t *testing.T) {
+	export, err := newSessionExport(transcriptSession(t, threeTurns...))
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, countTurns(export.Entries))
+	assert.Equal(t, export.Entries, entriesAfterTurn(export.Entries, 0))
+	after := entriesAfterTurn(export.Entries, 2)
+	require.Len(t, after, 2)
+	assert.Equal(t, "Write tests", after[0].Text)
+	assert.Empty(t, entriesAfterTurn(export.Entries, 3))
+}
+
+func TestSummaryPrompt(t *testing.T) {
+	session := transcriptSession(t, threeTurns...)
+	assert.Empty(t, summaryPrompt(session), "a session without a summary has nothing to seed")
+
+	session.Metadata = models.MakeJSONField(map[string]interface{}{
+		"summary":       "The user is building authentication; login and logout are done.",
+		"summary_turns": float64(2),
+	})
+	prompt := summaryPrompt(session)
+	assert.Contains(t, prompt, "<conversation_summary>\nThe user is building authentication; login and logout are done.\n</conversation_summary>")
+	assert.Contains(t, prompt, "<conversation>\nUser: Write tests\nClaude: Tests pass.\n</conversation>")
+	assert.NotContains(t, prompt, "Add a login page", "turns covered by the summary are left out")
+}
+
+func TestSummarizeSessionWaitsForEnoughTurns(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ClaudeSession{}))
+	service := NewClaudeService(deps.Deps{DB: db, Config: config.AppConfig{}})
+
+	session := transcriptSession(t, threeTurns...)
+	session.ID = "session-model-id"
+	session.UserID = "U123"
+	session.Metadata = models.MakeJSONField(map[string]interface{}{"summary_turns": float64(1)})
+	require.NoError(t, db.Create(session).Error)
+
+	// Two turns since the last summary isn't enough when summarizing every three
+	summarized, err := service.SummarizeSession(context.Background(), session.SessionID, 3)
+	require.NoError(t, err)
+	assert.False(t, summarized)
+
+	summarized, err = service.SummarizeSession(context.Background(), session.SessionID, 0)
+	require.NoError(t, err)
+	assert.False(t, summarized, "summarizing is off without a turn limit")
+}
+
+func TestRestorePrompt(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ClaudeSession{}))
+	service := NewClaudeService(deps.Deps{DB: db, Config: config.AppConfig{}})
+
+	session := transcriptSession(t, threeTurns...)
+	session.ID = "session-model-id"
+	session.UserID = "U123"
+	require.NoError(t, db.Create(session).Error)
+
+	prompt, err := service.RestorePrompt(session.SessionID)
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "<previous_conversation>\nUser: Add a login page", "a session without a summary is restored from its transcript")
+
+	session.Metadata = models.MakeJSONField(map[string]interface{}{
+		"summary":       "Login and logout are done.",
+		"summary_turns": float64(2),
+	})
+	require.NoError(t, db.Save(session).Error)
+	prompt, err = service.RestorePrompt(session.SessionID)
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "<conversation_summary>\nLogin and logout are done.\n</conversation_summary>")
+	assert.NotContains(t, prompt, "Add a login page")
+
+	_, err = service.RestorePrompt("missing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,199 @@
+package claude
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/metrics"
+)
+
+// MessageSubtypeSessionRestarted is the subtype of the system message the
+// supervisor sends on a restarted process's output channel
+const MessageSubtypeSessionRestarted = "session_restarted"
+
+// Reasons the supervisor restarts a process
+const (
+	restartReasonExited = "exited"
+	restartReasonHung   = "hung"
+)
+
+// unhealthyProcess is a running session the supervisor should replace
+type unhealthyProcess struct {
+	key     string // Key of the process in Service.sessions
+	process *Process
+	reason  string
+}
+
+// unhealthyProcesses checks every active session and returns the processes
+// that have exited, or whose turn has produced no output for hungTimeout
+func (s *Service) unhealthyProcesses(hungTimeout time.Duration) []unhealthyProcess {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var unhealthy []unhealthyProcess
+	for key, process := range s.sessions {
+		switch {
+		case !process.validateProcessHealth():
+			unhealthy = append(unhealthy, unhealthyProcess{key: key, process: process, reason: restartReasonExited})
+		case hungTimeout > 0 && process.inTurn.Load() && !process.approvals.waiting() && process.sinceHeartbeat() > hungTimeout:
+			unhealthy = append(unhealthy, unhealthyProcess{key: key, process: process, reason: restartReasonHung})
+		}
+	}
+	return unhealthy
+}
+
+// idleProcesses returns the processes, by key in Service.sessions, whose session
+// has had no prompt or output for idleTimeout. Processes that don't serve a flow
+// session, such as warm pool processes, are skipped since they can't be resumed.
+func (s *Service) idleProcesses(idleTimeout time.Duration) map[string]*Process {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	idle := map[string]*Process{}
+	for key, process := range s.sessions {
+		if process.flowSessionID.Load() == nil || process.inTurn.Load() {
+			continue
+		}
+		if process.sinceHeartbeat() > idleTimeout {
+			idle[key] = process
+		}
+	}
+	return idle
+}
+
+// detach removes process from the active sessions, reporting false if it was
+// already stopped or replaced
+func (s *Service) detach(key string, process *Process) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions[key] != process {
+		return false
+	}
+	delete(s.sessions, key)
+	metrics.ClaudeSessionsActive.Set(float64(len(s.sessions)))
+	return true
+}
+
+// RunSupervisor health-checks running Claude processes, restarts crashed or hung
+// ones, and hibernates idle ones until ctx is cancelled. It returns immediately
+// when disabled.
+func (cs *ClaudeService) RunSupervisor(ctx context.Context) {
+	if cs.supervisor.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(cs.supervisor.Interval)
+	defer ticker.Stop()
+
+	slog.Info("Claude process supervisor started",
+		"interval", cs.supervisor.Interval,
+		"hung_timeout", cs.supervisor.HungTimeout,
+		"idle_timeout", cs.supervisor.IdleTimeout)
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Claude process supervisor stopped")
+			return
+		case <-ticker.C:
+			for _, unhealthy := range cs.service.unhealthyProcesses(cs.supervisor.HungTimeout) {
+				cs.restart(unhealthy)
+			}
+			if cs.supervisor.IdleTimeout > 0 {
+				for key, process := range cs.service.idleProcesses(cs.supervisor.IdleTimeout) {
+					cs.hibernate(key, process)
+				}
+			}
+		}
+	}
+}
+
+// restart stops an unhealthy process and resumes its conversation in a new one.
+// Processes without a persisted flow session, such as worklet sessions, are only stopped.
+func (cs *ClaudeService) restart(unhealthy unhealthyProcess) {
+	old := unhealthy.process
+	if !cs.service.detach(unhealthy.key, old) {
+		return
+	}
+
+	sessionID := old.FlowSessionID()
+	slog.Warn("Restarting unhealthy Claude process",
+		"correlation_id", old.correlationID,
+		"session_id", sessionID,
+		"reason", unhealthy.reason,
+		"since_output_ms", old.sinceHeartbeat().Milliseconds(),
+		"action", "session_restart_start",
+	)
+	// The replacement takes over the old process's slot instead of queueing behind other sessions
+	slot := old.takeSlot()
+	cs.service.stopProcess(unhealthy.key, old, time.Now())
+
+	if old.flowSessionID.Load() == nil {
+		if slot != nil {
+			slot()
+		}
+		slog.Info("Stopped unhealthy Claude process with no session to resume",
+			"correlation_id", old.correlationID,
+			"session_id", sessionID,
+			"action", "session_restart_skipped",
+		)
+		return
+	}
+
+	opts := []SessionOption{withBudgetSpent(old.budget)}
+	if slot != nil {
+		opts = append(opts, withSlot(slot))
+	}
+	process, err := cs.ResumeSession(sessionID, old.UserID(), opts...)
+	if err != nil {
+		if slot != nil {
+			slot()
+		}
+		slog.Error("Failed to restart Claude process",
+			"correlation_id", old.correlationID,
+			"session_id", sessionID,
+			"error", err,
+			"action", "session_restart_failed",
+		)
+		return
+	}
+	old.replacedBy.Store(process)
+	process.tools.inherit(&old.tools)
+	metrics.ClaudeSessionRestartsTotal.WithLabelValues(unhealthy.reason).Inc()
+
+	select {
+	case process.outputChan <- Message{Type: "system", Subtype: MessageSubtypeSessionRestarted, SessionID: sessionID}:
+	default:
+	}
+	events.Publish(cs.events, events.TopicSessionRestarted, events.SessionRestarted{
+		SessionID: sessionID,
+		UserID:    old.UserID(),
+		Reason:    unhealthy.reason,
+	})
+
+	slog.Info("Restarted Claude process",
+		"correlation_id", process.correlationID,
+		"session_id", sessionID,
+		"reason", unhealthy.reason,
+		"action", "session_restarted",
+	)
+}
+
+// hibernate stops an idle session's process. The session stays active in the
+// database, so the next prompt resumes it with --resume.
+func (cs *ClaudeService) hibernate(key string, process *Process) {
+	if !cs.service.detach(key, process) {
+		return
+	}
+	cs.service.stopProcess(key, process, time.Now())
+	metrics.ClaudeSessionsHibernatedTotal.Inc()
+
+	slog.Info("Hibernated idle Claude process",
+		"correlation_id", process.correlationID,
+		"session_id", process.FlowSessionID(),
+		"idle_ms", process.sinceHeartbeat().Milliseconds(),
+		"action", "session_hibernated",
+	)
+}
@@ -0,0 +1,119 @@
+package claude
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRunningProcess returns an idle process backed by a real, long-lived command
+func newRunningProcess(t *testing.T, sessionID string) *Process {
+	cmd := exec.Command("sleep", "60")
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	process := newIdleProcess(sessionID)
+	process.cmd = cmd
+	process.heartbeat()
+	return process
+}
+
+func TestUnhealthyProcesses(t *testing.T) {
+	s := NewService(Config{})
+
+	healthy := newRunningProcess(t, "healthy")
+	busy := newRunningProcess(t, "busy")
+	busy.inTurn.Store(true)
+	hung := newRunningProcess(t, "hung")
+	hung.inTurn.Store(true)
+	hung.lastHeartbeat.Store(time.Now().Add(-time.Hour).UnixNano())
+	exited := newRunningProcess(t, "exited")
+	exited.exited.Store(true)
+
+	for _, process := range []*Process{healthy, busy, hung, exited} {
+		s.sessions[process.sessionID] = process
+	}
+
+	reasons := map[string]string{}
+	for _, unhealthy := range s.unhealthyProcesses(10 * time.Minute) {
+		reasons[unhealthy.key] = unhealthy.reason
+	}
+	assert.Equal(t, map[string]string{"hung": restartReasonHung, "exited": restartReasonExited}, reasons)
+}
+
+func TestRestartStopsProcessWithoutFlowSession(t *testing.T) {
+	cs := &ClaudeService{service: NewService(Config{}), supervisor: config.ClaudeSupervisorConfig{}}
+	process := newIdleProcess("worklet")
+	cs.service.sessions[process.sessionID] = process
+
+	cs.restart(unhealthyProcess{key: process.sessionID, process: process, reason: restartReasonExited})
+
+	_, exists := cs.service.getProcess("worklet")
+	assert.False(t, exists)
+	assert.Error(t, process.ctx.Err())
+	assert.Nil(t, process.replacedBy.Load())
+
+	// A restart racing StopSession leaves the session alone
+	assert.False(t, cs.service.detach(process.sessionID, process))
+}
+
+func TestSendMessageFollowsRestart(t *testing.T) {
+	s := NewService(Config{})
+	old := newIdleProcess("old")
+	old.cancel()
+	replacement := newIdleProcess("new")
+	old.replacedBy.Store(replacement)
+
+	require.NoError(t, s.SendMessage(old, "hello again"))
+	assert.Len(t, replacement.inputChan, 1)
+	assert.True(t, replacement.inTurn.Load())
+	assert.Equal(t, (<-chan Message)(replacement.outputChan), s.ReceiveMessages(old))
+}
+
+func TestIdleProcesses(t *testing.T) {
+	s := NewService(Config{})
+	flowSessionID := "flow-1"
+
+	active := newIdleProcess("active")
+	active.flowSessionID.Store(&flowSessionID)
+	active.heartbeat()
+	idle := newIdleProcess("idle")
+	idle.flowSessionID.Store(&flowSessionID)
+	idle.lastHeartbeat.Store(time.Now().Add(-time.Hour).UnixNano())
+	busy := newIdleProcess("busy")
+	busy.flowSessionID.Store(&flowSessionID)
+	busy.lastHeartbeat.Store(time.Now().Add(-time.Hour).UnixNano())
+	busy.inTurn.Store(true)
+	warm := newIdleProcess("warm")
+	warm.lastHeartbeat.Store(time.Now().Add(-time.Hour).UnixNano())
+
+	for _, process := range []*Process{active, idle, busy, warm} {
+		s.sessions[process.sessionID] = process
+	}
+
+	assert.Equal(t, map[string]*Process{"idle": idle}, s.idleProcesses(30*time.Minute))
+}
+
+func TestHibernateStopsProcessAndFreesSlot(t *testing.T) {
+	cs := &ClaudeService{service: NewService(Config{Scheduler: config.ClaudeSchedulerConfig{MaxProcesses: 1}})}
+	release, err := cs.service.scheduler.Acquire("u1", nil)
+	require.NoError(t, err)
+
+	process := newIdleProcess("hibernating")
+	process.holdSlot(release)
+	cs.service.sessions[process.sessionID] = process
+
+	cs.hibernate(process.sessionID, process)
+
+	_, exists := cs.service.getProcess("hibernating")
+	assert.False(t, exists)
+	assert.Error(t, process.ctx.Err())
+	assert.Equal(t, 0, cs.service.scheduler.running)
+}
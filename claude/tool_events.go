@@ -0,0 +1,216 @@
+package claude
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ToolEventType says whether a ToolEvent is a tool call or its result
+type ToolEventType string
+
+const (
+	ToolCall   ToolEventType = "tool_call"
+	ToolResult ToolEventType = "tool_result"
+)
+
+// ToolEvent is a tool call Claude made or the result the tool returned, with
+// the arguments of common tools already decoded
+type ToolEvent struct {
+	Type      ToolEventType
+	ToolUseID string
+	Tool      string          // Name of the called tool; set on results when the call was seen
+	Input     json.RawMessage // Raw arguments of a call
+	Edit      *EditFile       // Set on Edit, MultiEdit, and Write calls
+	Bash      *BashCommand    // Set on Bash calls
+	Read      *ReadFile       // Set on Read calls
+	Output    string          // Text of a result
+	IsError   bool            // Whether a result reports a failure
+}
+
+// EditFile is a change made by the Edit, MultiEdit, or Write tool
+type EditFile struct {
+	FilePath string
+	Diff     string // Unified-style diff of the change
+}
+
+// BashCommand is a shell command run by the Bash tool
+type BashCommand struct {
+	Command     string `json:"command"`
+	Description string `json:"description"`
+}
+
+// ReadFile is a file read by the Read tool
+type ReadFile struct {
+	FilePath string `json:"file_path"`
+	Offset   int    `json:"offset"`
+	Limit    int    `json:"limit"`
+}
+
+// ParseToolEvents returns the tool calls and results in a message's content.
+// Results don't name their tool; Process.OnToolUse fills it in from the call.
+func ParseToolEvents(msg Message) []ToolEvent {
+	if msg.Type != "user" && msg.Type != "assistant" {
+		return nil
+	}
+
+	var body struct {
+		Content []contentBlock `json:"content"`
+	}
+	// Prompts with plain string content have no tool blocks
+	if err := json.Unmarshal(msg.Message, &body); err != nil {
+		return nil
+	}
+
+	var toolEvents []ToolEvent
+	for _, block := range body.Content {
+		switch block.Type {
+		case "tool_use":
+			toolEvents = append(toolEvents, newToolCall(block))
+		case "tool_result":
+			toolEvents = append(toolEvents, ToolEvent{
+				Type:      ToolResult,
+				ToolUseID: block.ToolUseID,
+				Output:    toolResultText(block.Content),
+				IsError:   block.IsError,
+			})
+		}
+	}
+	return toolEvents
+}
+
+// newToolCall decodes the arguments of a tool_use block for the tools that have typed fields
+func newToolCall(block contentBlock) ToolEvent {
+	call := ToolEvent{Type: ToolCall, ToolUseID: block.ID, Tool: block.Name, Input: block.Input}
+	switch block.Name {
+	case "Edit", "MultiEdit", "Write":
+		if filePath, diff := toolDiff(block.Name, block.Input); filePath != "" {
+			call.Edit = &EditFile{FilePath: filePath, Diff: diff}
+		}
+	case "Bash":
+		var bash BashCommand
+		if err := json.Unmarshal(block.Input, &bash); err == nil {
+			call.Bash = &bash
+		}
+	case "Read":
+		var read ReadFile
+		if err := json.Unmarshal(block.Input, &read); err == nil {
+			call.Read = &read
+		}
+	}
+	return call
+}
+
+// Summary describes the event in a line, such as "Edit main.go" or "Bash: go test ./..."
+func (e ToolEvent) Summary() string {
+	if e.Type == ToolResult {
+		status := "finished"
+		if e.IsError {
+			status = "failed"
+		}
+		if e.Tool == "" {
+			return "Tool " + status
+		}
+		return e.Tool + " " + status
+	}
+
+	switch {
+	case e.Edit != nil:
+		return e.Tool + " " + e.Edit.FilePath
+	case e.Read != nil:
+		return "Read " + e.Read.FilePath
+	case e.Bash != nil:
+		command, _, _ := strings.Cut(e.Bash.Command, "\n")
+		return "Bash: " + command
+	}
+	return e.Tool
+}
+
+// toolHookIDs numbers OnToolUse registrations across processes so a
+// registration can be found again after a supervisor restart
+var toolHookIDs atomic.Int64
+
+// toolHooks holds a process's OnToolUse callbacks
+type toolHooks struct {
+	mu       sync.Mutex
+	handlers map[int64]func(ToolEvent)
+	calls    map[string]string // Tool name by tool use ID, for naming results
+}
+
+func (h *toolHooks) add(id int64, fn func(ToolEvent)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.handlers == nil {
+		h.handlers = map[int64]func(ToolEvent){}
+	}
+	h.handlers[id] = fn
+}
+
+func (h *toolHooks) remove(id int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.handlers, id)
+}
+
+// inherit registers the callbacks of a process this one replaces
+func (h *toolHooks) inherit(old *toolHooks) {
+	old.mu.Lock()
+	handlers := maps.Clone(old.handlers)
+	old.mu.Unlock()
+	for id, fn := range handlers {
+		h.add(id, fn)
+	}
+}
+
+// dispatch calls the registered callbacks with the tool events in msg
+func (h *toolHooks) dispatch(msg Message) {
+	h.mu.Lock()
+	if len(h.handlers) == 0 {
+		h.mu.Unlock()
+		return
+	}
+	// Call in registration order, outside the lock so callbacks may unregister themselves
+	ids := slices.Sorted(maps.Keys(h.handlers))
+	handlers := make([]func(ToolEvent), len(ids))
+	for i, id := range ids {
+		handlers[i] = h.handlers[id]
+	}
+
+	toolEvents := ParseToolEvents(msg)
+	for i, event := range toolEvents {
+		switch event.Type {
+		case ToolCall:
+			if h.calls == nil {
+				h.calls = map[string]string{}
+			}
+			h.calls[event.ToolUseID] = event.Tool
+		case ToolResult:
+			toolEvents[i].Tool = h.calls[event.ToolUseID]
+			delete(h.calls, event.ToolUseID)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, event := range toolEvents {
+		for _, fn := range handlers {
+			fn(event)
+		}
+	}
+}
+
+// OnToolUse calls fn with each tool call Claude makes and each tool result, in
+// order, until the returned function is called. The callback carries over to
+// the replacement if the supervisor restarts the process. fn runs on the
+// goroutine reading Claude's output, so it should return quickly.
+func (p *Process) OnToolUse(fn func(ToolEvent)) (remove func()) {
+	id := toolHookIDs.Add(1)
+	p.current().tools.add(id, fn)
+	return func() {
+		for q := p; q != nil; q = q.replacedBy.Load() {
+			q.tools.remove(id)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package claude
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseToolEvents(t *testing.T) {
+	msg := Message{Type: "assistant", Message: json.RawMessage(`{"role":"assistant","content":[
+		{"type":"text","text":"Let me look."},
+		{"type":"tool_use","id":"t1","name":"Read","input":{"file_path":"main.go","limit":50}},
+		{"type":"tool_use","id":"t2","name":"Bash","input":{"command":"go test ./...\necho done","description":"Run tests"}},
+		{"type":"tool_use","id":"t3","name":"Edit","input":{"file_path":"main.go","old_string":"a\n","new_string":"b\n"}},
+		{"type":"tool_use","id":"t4","name":"Grep","input":{"pattern":"TODO"}}
+	]}`)}
+
+	calls := ParseToolEvents(msg)
+	require.Len(t, calls, 4)
+
+	assert.Equal(t, &ReadFile{FilePath: "main.go", Limit: 50}, calls[0].Read)
+	assert.Equal(t, "Read main.go", calls[0].Summary())
+	assert.Equal(t, &BashCommand{Command: "go test ./...\necho done", Description: "Run tests"}, calls[1].Bash)
+	assert.Equal(t, "Bash: go test ./...", calls[1].Summary())
+	assert.Equal(t, &EditFile{FilePath: "main.go", Diff: "--- a/main.go\n+++ b/main.go\n-a\n+b\n"}, calls[2].Edit)
+	assert.Equal(t, "Edit main.go", calls[2].Summary())
+	assert.Equal(t, "Grep", calls[3].Summary())
+	assert.JSONEq(t, `{"pattern":"TODO"}`, string(calls[3].Input))
+
+	assert.Nil(t, ParseToolEvents(Message{Type: "user", Message: json.RawMessage(`{"role":"user","content":"hi"}`)}))
+}
+
+func TestOnToolUseNamesResults(t *testing.T) {
+	process := newIdleProcess("p1")
+	var got []ToolEvent
+	remove := process.OnToolUse(func(event ToolEvent) { got = append(got, event) })
+
+	process.tools.dispatch(Message{Type: "assistant", Message: json.RawMessage(`{"content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"ls"}}]}`)})
+	process.tools.dispatch(Message{Type: "user", Message: json.RawMessage(`{"content":[{"type":"tool_result","tool_use_id":"t1","content":"main.go","is_error":true}]}`)})
+
+	require.Len(t, got, 2)
+	assert.Equal(t, ToolCall, got[0].Type)
+	assert.Equal(t, ToolResult, got[1].Type)
+	assert.Equal(t, "Bash", got[1].Tool)
+	assert.Equal(t, "main.go", got[1].Output)
+	assert.Equal(t, "Bash failed", got[1].Summary())
+
+	remove()
+	process.tools.dispatch(Message{Type: "assistant", Message: json.RawMessage(`{"content":[{"type":"tool_use","id":"t2","name":"Read","input":{}}]}`)})
+	assert.Len(t, got, 2)
+}
+
+func TestOnToolUseFollowsRestart(t *testing.T) {
+	old := newIdleProcess("old")
+	var calls int
+	remove := old.OnToolUse(func(ToolEvent) { calls++ })
+
+	replacement := newIdleProcess("new")
+	old.replacedBy.Store(replacement)
+	replacement.tools.inherit(&old.tools)
+
+	toolUse := Message{Type: "assistant", Message: json.RawMessage(`{"content":[{"type":"tool_use","id":"t1","name":"Read","input":{}}]}`)}
+	replacement.tools.dispatch(toolUse)
+	assert.Equal(t, 1, calls)
+
+	remove()
+	replacement.tools.dispatch(toolUse)
+	assert.Equal(t, 1, calls)
+}
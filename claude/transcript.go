@@ -0,0 +1,80 @@
+package claude
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/breadchris/flow/models"
+	"gorm.io/gorm"
+)
+
+// transcriptBuffer collects the messages of a turn until it finishes
+type transcriptBuffer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (b *transcriptBuffer) add(msg Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = append(b.messages, msg)
+}
+
+// take returns the buffered messages and empties the buffer
+func (b *transcriptBuffer) take() []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	messages := b.messages
+	b.messages = nil
+	return messages
+}
+
+// TranscriptStore appends the prompts and replies of each turn to the
+// session's ClaudeSession.Messages
+type TranscriptStore struct {
+	db *gorm.DB
+}
+
+// NewTranscriptStore creates a store that saves transcripts in db
+func NewTranscriptStore(db *gorm.DB) *TranscriptStore {
+	return &TranscriptStore{db: db}
+}
+
+// Append adds messages to the end of a session's transcript. It is a no-op on
+// a nil store and for sessions that are not persisted, such as worklet sessions.
+func (t *TranscriptStore) Append(sessionID string, messages []Message) error {
+	if t == nil || t.db == nil || len(messages) == 0 {
+		return nil
+	}
+
+	return t.db.Transaction(func(tx *gorm.DB) error {
+		var session models.ClaudeSession
+		if err := tx.Select("id", "messages").Where("session_id = ?", sessionID).First(&session).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return fmt.Errorf("failed to load transcript: %w", err)
+		}
+
+		transcript, _ := session.Messages.Data.([]interface{})
+		for _, msg := range messages {
+			transcript = append(transcript, msg)
+		}
+		if err := tx.Model(&session).Update("messages", models.JSONField[interface{}]{Data: transcript}).Error; err != nil {
+			return fmt.Errorf("failed to save transcript: %w", err)
+		}
+		return nil
+	})
+}
+
+// storeTranscript saves the messages of the process's finished turn
+func (s *Service) storeTranscript(process *Process) {
+	if err := s.transcripts.Append(process.FlowSessionID(), process.transcript.take()); err != nil {
+		slog.Warn("Failed to store Claude transcript",
+			"correlation_id", process.correlationID,
+			"error", err,
+		)
+	}
+}
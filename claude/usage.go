@@ -0,0 +1,178 @@
+package claude
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/breadchris/flow/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Usage is the token usage the Claude CLI reports on assistant and result messages
+type Usage struct {
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+}
+
+// UsageSummary is the accumulated usage for a session or user
+type UsageSummary struct {
+	Turns                    int64   `json:"turns"`
+	InputTokens              int64   `json:"input_tokens"`
+	OutputTokens             int64   `json:"output_tokens"`
+	CacheCreationInputTokens int64   `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64   `json:"cache_read_input_tokens"`
+	CostUSD                  float64 `json:"cost_usd"`
+}
+
+// UsageScope narrows usage to one user's turns or to a set of sessions. The
+// zero scope covers every turn.
+type UsageScope struct {
+	UserID     string
+	SessionIDs []string // Sessions whose turns count; nil counts every session
+}
+
+// UserUsage is the accumulated usage of one user
+type UserUsage struct {
+	UserID string `json:"user_id"`
+	UsageSummary
+}
+
+// SessionUsage is the accumulated usage of one session
+type SessionUsage struct {
+	SessionID string `json:"session_id"`
+	UserID    string `json:"user_id"`
+	UsageSummary
+}
+
+// usageColumns sums usage records into a UsageSummary
+const usageColumns = `COUNT(*) AS turns,
+	COALESCE(SUM(input_tokens), 0) AS input_tokens,
+	COALESCE(SUM(output_tokens), 0) AS output_tokens,
+	COALESCE(SUM(cache_creation_input_tokens), 0) AS cache_creation_input_tokens,
+	COALESCE(SUM(cache_read_input_tokens), 0) AS cache_read_input_tokens,
+	COALESCE(SUM(cost_usd), 0) AS cost_usd`
+
+// UsageTracker persists the usage of each completed Claude turn so it can be
+// billed back per session or per user
+type UsageTracker struct {
+	db *gorm.DB
+}
+
+// NewUsageTracker creates a tracker that stores usage in db
+func NewUsageTracker(db *gorm.DB) *UsageTracker {
+	return &UsageTracker{db: db}
+}
+
+// Record stores the usage reported by a turn's result message. It is a no-op
+// on a nil tracker or when the message carries no usage.
+func (t *UsageTracker) Record(sessionID, userID string, msg Message) error {
+	if t == nil || t.db == nil || msg.Usage == nil {
+		return nil
+	}
+
+	record := &models.ClaudeUsage{
+		Model: models.Model{
+			ID:        uuid.NewString(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		},
+		SessionID:                sessionID,
+		UserID:                   userID,
+		InputTokens:              msg.Usage.InputTokens,
+		OutputTokens:             msg.Usage.OutputTokens,
+		CacheCreationInputTokens: msg.Usage.CacheCreationInputTokens,
+		CacheReadInputTokens:     msg.Usage.CacheReadInputTokens,
+		CostUSD:                  msg.TotalCostUSD,
+	}
+	if err := t.db.Create(record).Error; err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	return nil
+}
+
+// GetUsage returns the total usage of a session
+func (t *UsageTracker) GetUsage(sessionID string) (*UsageSummary, error) {
+	summary, err := t.sum(t.db.Where("session_id = ?", sessionID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session usage: %w", err)
+	}
+	return summary, nil
+}
+
+// GetUserUsage returns the total usage of a user's sessions since the given time
+func (t *UsageTracker) GetUserUsage(userID string, since time.Time) (*UsageSummary, error) {
+	summary, err := t.sum(t.db.Where("user_id = ? AND created_at >= ?", userID, since))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user usage: %w", err)
+	}
+	return summary, nil
+}
+
+// GetScopedUsage returns the total usage in a scope since the given time
+func (t *UsageTracker) GetScopedUsage(scope UsageScope, since time.Time) (*UsageSummary, error) {
+	summary, err := t.sum(t.scoped(scope, since))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+	return summary, nil
+}
+
+// GetUsageByUser returns the usage of each user in a scope since the given
+// time, most expensive first
+func (t *UsageTracker) GetUsageByUser(scope UsageScope, since time.Time) ([]UserUsage, error) {
+	var users []UserUsage
+	err := t.scoped(scope, since).
+		Select("user_id, " + usageColumns).
+		Group("user_id").
+		Order("cost_usd DESC").
+		Scan(&users).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage by user: %w", err)
+	}
+	return users, nil
+}
+
+// GetTopSessions returns the most expensive sessions in a scope since the
+// given time, at most limit of them
+func (t *UsageTracker) GetTopSessions(scope UsageScope, since time.Time, limit int) ([]SessionUsage, error) {
+	var sessions []SessionUsage
+	err := t.scoped(scope, since).
+		Select("session_id, MAX(user_id) AS user_id, " + usageColumns).
+		Group("session_id").
+		Order("cost_usd DESC").
+		Limit(limit).
+		Scan(&sessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// scoped selects the usage records in a scope since the given time
+func (t *UsageTracker) scoped(scope UsageScope, since time.Time) *gorm.DB {
+	query := t.db.Model(&models.ClaudeUsage{}).Where("created_at >= ?", since)
+	if scope.UserID != "" {
+		query = query.Where("user_id = ?", scope.UserID)
+	}
+	if scope.SessionIDs != nil {
+		if len(scope.SessionIDs) == 0 {
+			return query.Where("1 = 0")
+		}
+		query = query.Where("session_id IN ?", scope.SessionIDs)
+	}
+	return query
+}
+
+func (t *UsageTracker) sum(query *gorm.DB) (*UsageSummary, error) {
+	var summary UsageSummary
+	err := query.Model(&models.ClaudeUsage{}).
+		Select(usageColumns).
+		Scan(&summary).Error
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
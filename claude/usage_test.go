@@ -0,0 +1,108 @@
+package claude
+
+import (
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestUsageTracker(t *testing.T) *UsageTracker {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ClaudeUsage{}))
+	return NewUsageTracker(db)
+}
+
+func resultMessage(input, output int64, cost float64) Message {
+	return Message{
+		Type:         "result",
+		Usage:        &Usage{InputTokens: input, OutputTokens: output, CacheReadInputTokens: 10},
+		TotalCostUSD: cost,
+	}
+}
+
+func TestUsageTrackerAccumulatesPerSession(t *testing.T) {
+	tracker := newTestUsageTracker(t)
+
+	require.NoError(t, tracker.Record("s1", "u1", resultMessage(100, 20, 0.01)))
+	require.NoError(t, tracker.Record("s1", "u1", resultMessage(50, 5, 0.02)))
+	require.NoError(t, tracker.Record("s2", "u1", resultMessage(1000, 200, 0.5)))
+
+	usage, err := tracker.GetUsage("s1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), usage.Turns)
+	assert.Equal(t, int64(150), usage.InputTokens)
+	assert.Equal(t, int64(25), usage.OutputTokens)
+	assert.Equal(t, int64(20), usage.CacheReadInputTokens)
+	assert.InDelta(t, 0.03, usage.CostUSD, 1e-9)
+}
+
+func TestUsageTrackerUserUsageSince(t *testing.T) {
+	tracker := newTestUsageTracker(t)
+
+	require.NoError(t, tracker.Record("s1", "u1", resultMessage(100, 20, 0.01)))
+	require.NoError(t, tracker.Record("s2", "u1", resultMessage(300, 40, 0.03)))
+	require.NoError(t, tracker.Record("s3", "u2", resultMessage(999, 999, 9)))
+	require.NoError(t, tracker.db.Model(&models.ClaudeUsage{}).
+		Where("session_id = ?", "s1").
+		Update("created_at", time.Now().Add(-48*time.Hour)).Error)
+
+	usage, err := tracker.GetUserUsage("u1", time.Now().Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), usage.Turns)
+	assert.Equal(t, int64(300), usage.InputTokens)
+
+	usage, err = tracker.GetUserUsage("u1", time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), usage.Turns)
+	assert.Equal(t, int64(400), usage.InputTokens)
+}
+
+func TestUsageTrackerIgnoresMessagesWithoutUsage(t *testing.T) {
+	tracker := newTestUsageTracker(t)
+	require.NoError(t, tracker.Record("s1", "u1", Message{Type: "result"}))
+
+	usage, err := tracker.GetUsage("s1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), usage.Turns)
+
+	var nilTracker *UsageTracker
+	assert.NoError(t, nilTracker.Record("s1", "u1", resultMessage(1, 1, 0)))
+}
+
+func TestUsageTrackerBreakdowns(t *testing.T) {
+	tracker := newTestUsageTracker(t)
+
+	require.NoError(t, tracker.Record("s1", "u1", resultMessage(100, 20, 0.01)))
+	require.NoError(t, tracker.Record("s1", "u1", resultMessage(100, 20, 0.02)))
+	require.NoError(t, tracker.Record("s2", "u1", resultMessage(300, 40, 0.50)))
+	require.NoError(t, tracker.Record("s3", "u2", resultMessage(999, 999, 0.20)))
+
+	users, err := tracker.GetUsageByUser(UsageScope{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	assert.Equal(t, "u1", users[0].UserID)
+	assert.Equal(t, int64(3), users[0].Turns)
+	assert.InDelta(t, 0.53, users[0].CostUSD, 1e-9)
+
+	sessions, err := tracker.GetTopSessions(UsageScope{}, time.Time{}, 2)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "s2", sessions[0].SessionID)
+	assert.Equal(t, "s3", sessions[1].SessionID)
+	assert.Equal(t, "u2", sessions[1].UserID)
+
+	usage, err := tracker.GetScopedUsage(UsageScope{SessionIDs: []string{"s1", "s3"}}, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), usage.Turns)
+	assert.Equal(t, int64(1199), usage.InputTokens)
+
+	usage, err = tracker.GetScopedUsage(UsageScope{UserID: "u1", SessionIDs: []string{}}, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), usage.Turns)
+}
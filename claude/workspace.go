@@ -0,0 +1,165 @@
+package claude
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AccessMode says whether Claude may change the files in a session directory
+type AccessMode string
+
+const (
+	AccessReadWrite AccessMode = "rw"
+	AccessReadOnly  AccessMode = "ro"
+)
+
+// ErrInvalidDirectory is returned when a session directory is missing, not a
+// directory, or a symlink that leads outside its parent
+var ErrInvalidDirectory = errors.New("invalid session directory")
+
+// Directory is a directory a Claude session can use
+type Directory struct {
+	Path   string
+	Access AccessMode
+}
+
+// ReadWriteDir is a directory Claude may change
+func ReadWriteDir(path string) Directory {
+	return Directory{Path: path, Access: AccessReadWrite}
+}
+
+// ReadOnlyDir is a directory Claude may read but not edit
+func ReadOnlyDir(path string) Directory {
+	return Directory{Path: path, Access: AccessReadOnly}
+}
+
+// readWriteDirs treats every path as writable, for callers that don't set access modes
+func readWriteDirs(paths []string) []Directory {
+	dirs := make([]Directory, len(paths))
+	for i, path := range paths {
+		dirs[i] = ReadWriteDir(path)
+	}
+	return dirs
+}
+
+// readOnly reports whether Claude may only read the directory; directories without a mode are writable
+func (d Directory) readOnly() bool {
+	return d.Access == AccessReadOnly
+}
+
+// resolveDirectories checks a session's directories and returns them with
+// absolute, symlink-free paths. Empty paths after the first are dropped.
+func resolveDirectories(dirs []Directory) ([]Directory, error) {
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("at least one directory must be provided for Claude session isolation")
+	}
+	if dirs[0].Path == "" {
+		return nil, fmt.Errorf("session directory (first directory) cannot be empty")
+	}
+
+	resolved := make([]Directory, 0, len(dirs))
+	for _, dir := range dirs {
+		if dir.Path == "" {
+			continue
+		}
+		switch dir.Access {
+		case "", AccessReadWrite, AccessReadOnly:
+		default:
+			return nil, fmt.Errorf("%w: %s has unknown access mode %q", ErrInvalidDirectory, dir.Path, dir.Access)
+		}
+
+		path, err := resolveDirectory(dir.Path)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, Directory{Path: path, Access: dir.Access})
+	}
+	return resolved, nil
+}
+
+// usableDirectories resolves the directories a resumed session had, leaving
+// out any that are now missing or invalid
+func usableDirectories(dirs []Directory, sessionID string) []Directory {
+	var usable []Directory
+	for _, dir := range dirs {
+		if dir.Path == "" {
+			continue
+		}
+		path, err := resolveDirectory(dir.Path)
+		if err != nil {
+			slog.Warn("Leaving directory out of resumed Claude session",
+				"session_id", sessionID,
+				"dir", dir.Path,
+				"error", err,
+				"action", "session_dir_skipped",
+			)
+			continue
+		}
+		usable = append(usable, Directory{Path: path, Access: dir.Access})
+	}
+	return usable
+}
+
+// resolveDirectory returns the real path of an existing directory, rejecting
+// a symlink that points outside the directory containing it
+func resolveDirectory(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve session directory %s: %w", path, err)
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("%w: %s does not exist", ErrInvalidDirectory, path)
+		}
+		return "", fmt.Errorf("%w: %s is not accessible (%v)", ErrInvalidDirectory, path, err)
+	}
+
+	info, err := os.Stat(real)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s is not accessible (%v)", ErrInvalidDirectory, path, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%w: %s is not a directory", ErrInvalidDirectory, path)
+	}
+
+	parent, err := filepath.EvalSymlinks(filepath.Dir(abs))
+	if err != nil {
+		return "", fmt.Errorf("%w: parent of %s is not accessible (%v)", ErrInvalidDirectory, path, err)
+	}
+	if rel, err := filepath.Rel(parent, real); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s links outside %s", ErrInvalidDirectory, path, parent)
+	}
+	return real, nil
+}
+
+// directoryArgs gives the CLI access to each directory
+func directoryArgs(dirs []Directory) []string {
+	var args []string
+	for _, dir := range dirs {
+		args = append(args, "--add-dir", dir.Path)
+	}
+	return args
+}
+
+// readOnlyRules are permission rules that stop Claude's file-editing tools
+// from changing read-only directories. Bash can still write to them unless
+// the sandbox mounts them read-only.
+func readOnlyRules(dirs []Directory) []string {
+	var rules []string
+	for _, dir := range dirs {
+		if !dir.readOnly() {
+			continue
+		}
+		// A leading // makes the rule's path absolute
+		pattern := "/" + filepath.ToSlash(dir.Path) + "/**"
+		for _, tool := range []string{"Edit", "MultiEdit", "Write", "NotebookEdit"} {
+			rules = append(rules, tool+"("+pattern+")")
+		}
+	}
+	return rules
+}
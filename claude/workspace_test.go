@@ -0,0 +1,80 @@
+package claude
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveDirectories(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	session := filepath.Join(root, "session")
+	uploads := filepath.Join(root, "uploads")
+	require.NoError(t, os.Mkdir(session, 0755))
+	require.NoError(t, os.Mkdir(uploads, 0755))
+
+	dirs, err := resolveDirectories([]Directory{ReadWriteDir(session), {}, ReadOnlyDir(uploads)})
+	require.NoError(t, err)
+	assert.Equal(t, []Directory{ReadWriteDir(session), ReadOnlyDir(uploads)}, dirs)
+
+	_, err = resolveDirectories(nil)
+	assert.Error(t, err)
+	_, err = resolveDirectories([]Directory{{}, ReadWriteDir(session)})
+	assert.Error(t, err)
+	_, err = resolveDirectories([]Directory{{Path: session, Access: "wx"}})
+	assert.ErrorIs(t, err, ErrInvalidDirectory)
+	_, err = resolveDirectories([]Directory{ReadWriteDir(session), ReadOnlyDir(filepath.Join(root, "missing"))})
+	assert.ErrorIs(t, err, ErrInvalidDirectory)
+
+	file := filepath.Join(root, "notes.txt")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+	_, err = resolveDirectories([]Directory{ReadWriteDir(file)})
+	assert.ErrorIs(t, err, ErrInvalidDirectory)
+}
+
+func TestResolveDirectoriesRejectsSymlinkEscape(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	outside, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	session := filepath.Join(root, "session")
+	require.NoError(t, os.Mkdir(session, 0755))
+
+	// A link to a sibling stays inside its parent
+	inside := filepath.Join(root, "current")
+	require.NoError(t, os.Symlink(session, inside))
+	dirs, err := resolveDirectories([]Directory{ReadWriteDir(inside)})
+	require.NoError(t, err)
+	assert.Equal(t, session, dirs[0].Path)
+
+	escape := filepath.Join(session, "etc")
+	require.NoError(t, os.Symlink(outside, escape))
+	_, err = resolveDirectories([]Directory{ReadWriteDir(session), ReadOnlyDir(escape)})
+	assert.ErrorIs(t, err, ErrInvalidDirectory)
+}
+
+func TestUsableDirectoriesSkipsMissing(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+
+	dirs := usableDirectories([]Directory{ReadWriteDir(root), ReadOnlyDir(filepath.Join(root, "gone"))}, "session-1")
+	assert.Equal(t, []Directory{ReadWriteDir(root)}, dirs)
+}
+
+func TestReadOnlyDirsBlockEditingTools(t *testing.T) {
+	dirs := []Directory{ReadWriteDir("/data/session/1"), ReadOnlyDir("/data/slack-uploads/1")}
+	opts := newSessionOptions([]SessionOption{WithDisallowedTools("Bash")})
+
+	assert.Equal(t, []string{
+		"--allowedTools", "Read",
+		"--disallowedTools", "Bash,Edit(//data/slack-uploads/1/**),MultiEdit(//data/slack-uploads/1/**)," +
+			"Write(//data/slack-uploads/1/**),NotebookEdit(//data/slack-uploads/1/**)",
+	}, opts.cliArgs(Config{Tools: []string{"Read"}}, dirs))
+	assert.Equal(t, []string{"Bash"}, opts.DisallowedTools, "rules aren't persisted with the session's options")
+
+	assert.Equal(t, []string{"--add-dir", "/data/session/1", "--add-dir", "/data/slack-uploads/1"}, directoryArgs(dirs))
+}
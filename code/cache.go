@@ -0,0 +1,399 @@
+package code
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/metrics"
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// rebuildParam is the query parameter that skips the build cache, such as
+// ?rebuild=1, for when a build needs to be redone by hand
+const rebuildParam = "rebuild"
+
+// BuildCache describes a cached build: where its output is kept on disk and
+// the files it was built from
+type BuildCache struct {
+	BuiltAt    time.Time         `json:"builtAt"`
+	SourcePath string            `json:"sourcePath"`
+	BuildPath  string            `json:"buildPath"`
+	MapPath    string            `json:"mapPath,omitempty"`   // Its source map, for builds that link one
+	CSSPath    string            `json:"cssPath,omitempty"`   // The CSS it imports, bundled
+	Hash       string            `json:"hash"`                // Of the output, which is its ETag
+	Key        string            `json:"key"`                 // Of the source and build options
+	Inputs     map[string]string `json:"inputs"`              // Each file the build read to the hash of its contents
+	Externals  []string          `json:"externals,omitempty"` // The imports left for the page to resolve
+	Graph      *ImportGraph      `json:"graph,omitempty"`     // What it imports, from its entry on
+}
+
+// cachedBuild is a build's output and what it was built from, kept in memory
+type cachedBuild struct {
+	BuildCache
+	output    []byte
+	sourceMap []byte
+	css       []byte
+	lastUsed  time.Time
+}
+
+// buildResult is the outcome of a build, either done now or cached
+type buildResult struct {
+	Output     []byte
+	SourceMap  []byte // Set for builds with an external or linked source map
+	CSS        []byte // The CSS the source imports, bundled, for builds with an output path
+	Hash       string
+	Inputs     map[string]string // The files the build read to the hashes of their contents
+	Externals  []string          // The imports left for the page to resolve
+	Graph      *ImportGraph      // What the source imports; nil for builds cached without one
+	Errors     []api.Message
+	Cached     bool
+	Overloaded bool // The build queue was full, so it wasn't built
+}
+
+// buildCache keeps esbuild output keyed by a hash of the source file's
+// contents and the build options, in memory and on disk. A build is reused
+// only while every file it read, the files it imports included, still has
+// the contents it was built from.
+type buildCache struct {
+	mu           sync.Mutex
+	dir          string // Empty keeps builds in memory only
+	maxEntries   int
+	entries      map[string]*cachedBuild
+	dependencies *dependencyResolver
+	queue        *buildQueue
+}
+
+func newBuildCache(cfg config.CodeConfig) *buildCache {
+	if cfg.CacheDir != "" {
+		if err := os.MkdirAll(cfg.CacheDir, 0755); err != nil {
+			slog.Warn("Failed to create code build cache directory; builds are cached in memory only", "error", err, "dir", cfg.CacheDir)
+			cfg.CacheDir = ""
+		}
+	}
+	maxEntries := cfg.CacheEntries
+	if maxEntries <= 0 {
+		maxEntries = 256
+	}
+	return &buildCache{
+		dir:          cfg.CacheDir,
+		maxEntries:   maxEntries,
+		entries:      make(map[string]*cachedBuild),
+		dependencies: newDependencyResolver(cfg),
+		queue:        newBuildQueue(cfg),
+	}
+}
+
+// Build builds the source at srcPath with opts, returning a cached build when
+// there's one still good unless rebuild is set. Builds with errors aren't
+// cached, so they're tried again on the next request. The source's npm
+// imports are resolved the way its directory is configured to first. Builds
+// run on the build queue's workers; one it turns away has Overloaded set.
+func (c *buildCache) Build(handler, srcPath string, source []byte, opts api.BuildOptions, rebuild bool) buildResult {
+	if err := applyDirConfig(srcPath, &opts); err != nil {
+		return buildResult{Errors: []api.Message{{Text: err.Error()}}}
+	}
+	if err := c.dependencies.apply(srcPath, &opts); err != nil {
+		return buildResult{Errors: []api.Message{{Text: err.Error()}}}
+	}
+	key := buildKey(srcPath, source, opts)
+	if !rebuild {
+		if build := c.get(key); build != nil {
+			metrics.CodeBuildCacheTotal.WithLabelValues(handler, "hit").Inc()
+			return buildResult{Output: build.output, SourceMap: build.sourceMap, CSS: build.css, Hash: build.Hash, Inputs: build.Inputs, Externals: build.Externals, Graph: build.Graph, Cached: true}
+		}
+	}
+
+	// Requests for the same build while it's running share it
+	result, shared := c.queue.do(handler, key, func() buildResult {
+		return c.build(handler, srcPath, key, opts)
+	})
+	if shared {
+		metrics.CodeBuildCacheTotal.WithLabelValues(handler, "coalesced").Inc()
+	} else if !result.Overloaded {
+		metrics.CodeBuildCacheTotal.WithLabelValues(handler, "miss").Inc()
+	}
+	return result
+}
+
+// build runs esbuild and caches its output when it succeeds
+func (c *buildCache) build(handler, srcPath, key string, opts api.BuildOptions) buildResult {
+	opts.Metafile = true
+	buildStart := time.Now()
+	result := api.Build(opts)
+	metrics.ObserveCodeBuild(handler, buildStart, len(result.Errors) > 0)
+	if len(result.Errors) > 0 {
+		return buildResult{Errors: result.Errors}
+	}
+	var output, sourceMap, css []byte
+	for _, file := range result.OutputFiles {
+		switch {
+		case strings.HasSuffix(file.Path, ".map"):
+			sourceMap = file.Contents
+		case strings.HasSuffix(file.Path, ".css"):
+			css = file.Contents
+		default:
+			output = file.Contents
+		}
+	}
+	if output == nil {
+		return buildResult{}
+	}
+
+	sum := sha256.Sum256(output)
+	build := &cachedBuild{
+		BuildCache: BuildCache{
+			BuiltAt:    time.Now(),
+			SourcePath: srcPath,
+			Hash:       hex.EncodeToString(sum[:]),
+			Key:        key,
+			Inputs:     buildInputs(result.Metafile),
+			Externals:  buildExternals(result.Metafile),
+			Graph:      buildImportGraph(srcPath, result.Metafile),
+		},
+		output:    output,
+		sourceMap: sourceMap,
+		css:       css,
+	}
+	c.put(build)
+	return buildResult{Output: output, SourceMap: sourceMap, CSS: css, Hash: build.Hash, Inputs: build.Inputs, Externals: build.Externals, Graph: build.Graph}
+}
+
+// get returns the build for key from memory or disk, if the files it was
+// built from haven't changed since
+func (c *buildCache) get(key string) *cachedBuild {
+	c.mu.Lock()
+	build, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok {
+		build = c.load(key)
+		if build == nil {
+			return nil
+		}
+	}
+	if !inputsUnchanged(build.Inputs) {
+		c.remove(key)
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	build.lastUsed = time.Now()
+	c.entries[key] = build
+	c.evict()
+	return build
+}
+
+// put keeps a build in memory and writes it to disk
+func (c *buildCache) put(build *cachedBuild) {
+	if c.dir != "" {
+		build.BuildPath = filepath.Join(c.dir, build.Key+".js")
+		if build.sourceMap != nil {
+			build.MapPath = build.BuildPath + ".map"
+		}
+		if build.css != nil {
+			build.CSSPath = filepath.Join(c.dir, build.Key+".css")
+		}
+		if err := c.save(build); err != nil {
+			slog.Warn("Failed to write code build to cache", "error", err, "path", build.SourcePath)
+			build.BuildPath, build.MapPath, build.CSSPath = "", "", ""
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	build.lastUsed = time.Now()
+	c.entries[build.Key] = build
+	c.evict()
+}
+
+// evict drops the least recently used builds from memory while there are
+// too many. They stay on disk. c.mu must be held.
+func (c *buildCache) evict() {
+	for len(c.entries) > c.maxEntries {
+		var oldest string
+		for key, build := range c.entries {
+			if oldest == "" || build.lastUsed.Before(c.entries[oldest].lastUsed) {
+				oldest = key
+			}
+		}
+		delete(c.entries, oldest)
+	}
+}
+
+// remove forgets a build that's out of date, in memory and on disk
+func (c *buildCache) remove(key string) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+	if c.dir != "" {
+		os.Remove(filepath.Join(c.dir, key+".js"))
+		os.Remove(filepath.Join(c.dir, key+".js.map"))
+		os.Remove(filepath.Join(c.dir, key+".css"))
+		os.Remove(filepath.Join(c.dir, key+".json"))
+	}
+}
+
+// save writes a build's output, source map, and CSS, then its description,
+// so a description is never read without its output
+func (c *buildCache) save(build *cachedBuild) error {
+	if err := os.WriteFile(build.BuildPath, build.output, 0644); err != nil {
+		return fmt.Errorf("failed to write build output: %w", err)
+	}
+	if build.MapPath != "" {
+		if err := os.WriteFile(build.MapPath, build.sourceMap, 0644); err != nil {
+			return fmt.Errorf("failed to write source map: %w", err)
+		}
+	}
+	if build.CSSPath != "" {
+		if err := os.WriteFile(build.CSSPath, build.css, 0644); err != nil {
+			return fmt.Errorf("failed to write build CSS: %w", err)
+		}
+	}
+	data, err := json.Marshal(build.BuildCache)
+	if err != nil {
+		return fmt.Errorf("failed to encode build: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(c.dir, build.Key+".json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write build: %w", err)
+	}
+	return nil
+}
+
+// load reads the build for key from disk, or returns nil when there isn't one
+func (c *buildCache) load(key string) *cachedBuild {
+	if c.dir == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(c.dir, key+".json"))
+	if err != nil {
+		return nil
+	}
+	var build cachedBuild
+	if err := json.Unmarshal(data, &build.BuildCache); err != nil {
+		return nil
+	}
+	if build.output, err = os.ReadFile(build.BuildPath); err != nil {
+		return nil
+	}
+	if build.MapPath != "" {
+		if build.sourceMap, err = os.ReadFile(build.MapPath); err != nil {
+			return nil
+		}
+	}
+	if build.CSSPath != "" {
+		if build.css, err = os.ReadFile(build.CSSPath); err != nil {
+			return nil
+		}
+	}
+	return &build
+}
+
+// buildKey hashes the source file's path and contents with the build
+// options, leaving out the source itself, which is in them as stdin
+func buildKey(srcPath string, source []byte, opts api.BuildOptions) string {
+	opts.Stdin = nil
+	options, _ := json.Marshal(opts)
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%s\x00", srcPath, options)
+	hash.Write(source)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// buildInputs returns the hashes of the files on disk a build read, from its
+// metafile. The source itself, read from stdin, is already in the key.
+func buildInputs(metafile string) map[string]string {
+	var meta struct {
+		Inputs map[string]json.RawMessage `json:"inputs"`
+	}
+	inputs := make(map[string]string)
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return inputs
+	}
+	for path := range meta.Inputs {
+		if strings.HasPrefix(path, "<") {
+			continue
+		}
+		if hash, ok := fileHash(path); ok {
+			inputs[path] = hash
+		}
+	}
+	return inputs
+}
+
+// buildExternals returns the imports a build left external, from its metafile
+func buildExternals(metafile string) []string {
+	var meta struct {
+		Outputs map[string]struct {
+			Imports []struct {
+				Path     string `json:"path"`
+				External bool   `json:"external"`
+			} `json:"imports"`
+		} `json:"outputs"`
+	}
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return nil
+	}
+	var externals []string
+	for _, output := range meta.Outputs {
+		for _, imp := range output.Imports {
+			if imp.External && !slices.Contains(externals, imp.Path) {
+				externals = append(externals, imp.Path)
+			}
+		}
+	}
+	sort.Strings(externals)
+	return externals
+}
+
+// inputsUnchanged reports whether every file still hashes to what it was built from
+func inputsUnchanged(inputs map[string]string) bool {
+	for path, want := range inputs {
+		if got, ok := fileHash(path); !ok || got != want {
+			return false
+		}
+	}
+	return true
+}
+
+func fileHash(path string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), true
+}
+
+// notModified sets the response's ETag and reports whether the request's
+// If-None-Match already has it, in which case a 304 has been sent
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	etag = `"` + etag + `"`
+	w.Header().Set("ETag", etag)
+	for _, match := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		match = strings.TrimPrefix(strings.TrimSpace(match), "W/")
+		if match == etag || match == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// pageETag is the ETag of a page of a built component: the build and what
+// else is on the page, such as the component it renders
+func pageETag(buildHash string, parts ...string) string {
+	sum := sha256.Sum256([]byte(buildHash + "\x00" + strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
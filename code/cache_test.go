@@ -0,0 +1,151 @@
+package code
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/breadchris/flow/config"
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// writeModule writes an entry file that imports dep.ts, which exports message
+func writeModule(t *testing.T, dir, message string) (string, []byte) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "dep.ts"), []byte(`export const message = "`+message+`";`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	source := []byte(`import { message } from "./dep"; console.log(message);`)
+	srcPath := filepath.Join(dir, "entry.ts")
+	if err := os.WriteFile(srcPath, source, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return srcPath, source
+}
+
+func testBuildOptions(srcPath string, source []byte) api.BuildOptions {
+	return api.BuildOptions{
+		Stdin: &api.StdinOptions{
+			Contents:   string(source),
+			ResolveDir: filepath.Dir(srcPath),
+			Sourcefile: filepath.Base(srcPath),
+			Loader:     api.LoaderTS,
+		},
+		Format:   api.FormatESModule,
+		Bundle:   true,
+		Write:    false,
+		LogLevel: api.LogLevelSilent,
+	}
+}
+
+func TestBuildCacheReusesBuildsUntilAnInputChanges(t *testing.T) {
+	dir := t.TempDir()
+	builds := newBuildCache(config.CodeConfig{CacheDir: filepath.Join(dir, "cache")})
+	srcPath, source := writeModule(t, dir, "hello")
+
+	first := builds.Build("module", srcPath, source, testBuildOptions(srcPath, source), false)
+	if len(first.Errors) > 0 || first.Cached || !strings.Contains(string(first.Output), "hello") {
+		t.Fatalf("first build = %+v; want a fresh build of the module", first)
+	}
+	second := builds.Build("module", srcPath, source, testBuildOptions(srcPath, source), false)
+	if !second.Cached || second.Hash != first.Hash {
+		t.Errorf("second build cached = %v, hash = %q; want the first build", second.Cached, second.Hash)
+	}
+	if rebuilt := builds.Build("module", srcPath, source, testBuildOptions(srcPath, source), true); rebuilt.Cached {
+		t.Error("rebuild used the cache")
+	}
+
+	// Editing an import invalidates the build even though the entry is the same
+	writeModule(t, dir, "goodbye")
+	third := builds.Build("module", srcPath, source, testBuildOptions(srcPath, source), false)
+	if third.Cached || !strings.Contains(string(third.Output), "goodbye") {
+		t.Errorf("build after editing dep.ts cached = %v; want a new build with the new message", third.Cached)
+	}
+	if third.Hash == first.Hash {
+		t.Error("a different output has the same hash")
+	}
+
+	// Other build options are built separately
+	opts := testBuildOptions(srcPath, source)
+	opts.Format = api.FormatCommonJS
+	if build := builds.Build("module", srcPath, source, opts, false); build.Cached {
+		t.Error("a build with other options was served from the cache")
+	}
+}
+
+func TestBuildCacheIsKeptOnDisk(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.CodeConfig{CacheDir: filepath.Join(dir, "cache")}
+	srcPath, source := writeModule(t, dir, "hello")
+
+	first := newBuildCache(cfg).Build("module", srcPath, source, testBuildOptions(srcPath, source), false)
+	restarted := newBuildCache(cfg).Build("module", srcPath, source, testBuildOptions(srcPath, source), false)
+	if !restarted.Cached || restarted.Hash != first.Hash || string(restarted.Output) != string(first.Output) {
+		t.Errorf("build after a restart cached = %v; want the build from disk", restarted.Cached)
+	}
+}
+
+func TestBuildCacheSkipsFailedBuilds(t *testing.T) {
+	dir := t.TempDir()
+	builds := newBuildCache(config.CodeConfig{})
+	srcPath := filepath.Join(dir, "broken.ts")
+	source := []byte(`import { missing } from "./missing"; console.log(missing);`)
+
+	for i := 0; i < 2; i++ {
+		build := builds.Build("module", srcPath, source, testBuildOptions(srcPath, source), false)
+		if len(build.Errors) == 0 || build.Cached {
+			t.Errorf("build %d errors = %d, cached = %v; want a failed build that isn't cached", i, len(build.Errors), build.Cached)
+		}
+	}
+}
+
+func TestBuildCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	builds := newBuildCache(config.CodeConfig{CacheEntries: 1})
+	srcPath, source := writeModule(t, dir, "hello")
+	otherPath := filepath.Join(dir, "other.ts")
+	other := []byte(`console.log("other");`)
+
+	builds.Build("module", srcPath, source, testBuildOptions(srcPath, source), false)
+	builds.Build("module", otherPath, other, testBuildOptions(otherPath, other), false)
+	if len(builds.entries) != 1 {
+		t.Fatalf("%d builds in memory; want 1", len(builds.entries))
+	}
+	if build := builds.Build("module", srcPath, source, testBuildOptions(srcPath, source), false); build.Cached {
+		t.Error("the evicted build was served without a disk cache")
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{"", false},
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"old", "abc"`, true},
+		{"*", true},
+		{`"old"`, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/module/App.tsx", nil)
+		if tt.ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", tt.ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		got := notModified(w, r, "abc")
+		if got != tt.want {
+			t.Errorf("notModified(If-None-Match: %s) = %v; want %v", tt.ifNoneMatch, got, tt.want)
+		}
+		if etag := w.Header().Get("ETag"); etag != `"abc"` {
+			t.Errorf("ETag = %s; want \"abc\"", etag)
+		}
+		if got && w.Code != http.StatusNotModified {
+			t.Errorf("status = %d; want 304", w.Code)
+		}
+	}
+}
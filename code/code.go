@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -14,13 +15,6 @@ import (
 	"github.com/evanw/esbuild/pkg/api"
 )
 
-type BuildCache struct {
-	BuiltAt    time.Time `json:"builtAt"`
-	SourcePath string    `json:"sourcePath"`
-	BuildPath  string    `json:"buildPath"`
-	Hash       string    `json:"hash"`
-}
-
 type FileInfo struct {
 	Name         string    `json:"name"`
 	Path         string    `json:"path"`
@@ -37,19 +31,64 @@ type SaveFileRequest struct {
 
 func New(d deps.Deps) *http.ServeMux {
 	m := http.NewServeMux()
+	builds := newBuildCache(d.Config.Code)
+	styles := newStylesheets(d.Config.Code)
+	reloads := newReloadHub(builds)
+	types := NewTypeChecker(d.Config.Code)
+	files := newFileManager(d)
+	goRuns := newGoRunner(d)
+	snapshots := newSnapshotter(d)
 
 	m.HandleFunc("/render/", func(w http.ResponseWriter, r *http.Request) {
-		handleRenderComponent(d)(w, r)
+		handleRenderComponent(d, builds, styles, types)(w, r)
 	})
 
 	m.HandleFunc("/module/", func(w http.ResponseWriter, r *http.Request) {
-		handleServeModule(w, r)
+		handleServeModule(builds, w, r)
 	})
 
 	m.HandleFunc("/page/", func(w http.ResponseWriter, r *http.Request) {
-		handlePageComponent(d)(w, r)
+		handlePageComponent(d, builds)(w, r)
+	})
+
+	m.HandleFunc("/stacktrace", func(w http.ResponseWriter, r *http.Request) {
+		handleStackTrace(builds, w, r)
+	})
+
+	m.HandleFunc("/typecheck/", func(w http.ResponseWriter, r *http.Request) {
+		handleTypeCheck(builds, types, w, r)
+	})
+
+	m.HandleFunc("/graph/", func(w http.ResponseWriter, r *http.Request) {
+		handleImportGraph(builds, w, r)
+	})
+
+	m.HandleFunc("/reload/", func(w http.ResponseWriter, r *http.Request) {
+		handleReload(reloads, w, r)
 	})
 
+	m.HandleFunc("GET /files", files.handleList)
+	m.HandleFunc("GET /files/content", files.handleRead)
+	m.HandleFunc("POST /files", files.handleCreate)
+	m.HandleFunc("PUT /files", files.handleSave)
+	m.HandleFunc("POST /files/rename", files.handleRename)
+	m.HandleFunc("POST /files/move", files.handleMove)
+	m.HandleFunc("DELETE /files", files.handleDelete)
+
+	m.HandleFunc("GET /files/diagnostics", func(w http.ResponseWriter, r *http.Request) {
+		handleDiagnostics(files, builds, w, r)
+	})
+
+	m.HandleFunc("GET /edit/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		handleEditor(files, w, r)
+	})
+
+	m.HandleFunc("POST /go/run", goRuns.handleRun)
+
+	m.HandleFunc("POST /snapshot/{path...}", snapshots.handleTake)
+	m.HandleFunc("GET /snapshot/{path...}", snapshots.handleHistory)
+	m.HandleFunc("GET /snapshots/{key}/{file}", snapshots.handleFile)
+
 	return m
 }
 
@@ -127,7 +166,7 @@ func buildDirectoryListing(baseDir, relativePath string, maxDepth int) ([]FileIn
 }
 
 // handleRenderComponent builds and renders a React component in a simple HTML page
-func handleRenderComponent(d deps.Deps) func(w http.ResponseWriter, r *http.Request) {
+func handleRenderComponent(d deps.Deps, builds *buildCache, styles *stylesheets, types *TypeChecker) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -171,7 +210,7 @@ func handleRenderComponent(d deps.Deps) func(w http.ResponseWriter, r *http.Requ
 		}
 
 		// Build with esbuild to get the compiled JavaScript
-		result := api.Build(api.BuildOptions{
+		result := builds.Build("render", srcPath, sourceCode, api.BuildOptions{
 			Stdin: &api.StdinOptions{
 				Contents:   string(sourceCode),
 				ResolveDir: filepath.Dir(srcPath),
@@ -188,6 +227,7 @@ func handleRenderComponent(d deps.Deps) func(w http.ResponseWriter, r *http.Requ
 			Format:          api.FormatESModule,
 			Bundle:          true,
 			Write:           false,
+			Outfile:         srcPath, // Lets it import CSS, which is bundled for the page's stylesheet
 			TreeShaking:     api.TreeShakingTrue,
 			Target:          api.ESNext,
 			JSX:             api.JSXAutomatic,
@@ -212,13 +252,18 @@ func handleRenderComponent(d deps.Deps) func(w http.ResponseWriter, r *http.Requ
 				"isolatedModules": true
 			}
 		}`,
-		})
+		}, r.URL.Query().Has(rebuildParam))
+
+		if result.Overloaded {
+			writeBuildOverloaded(w, result)
+			return
+		}
 
 		// Check for build errors
 		if len(result.Errors) > 0 {
 			errorMessages := make([]string, len(result.Errors))
 			for i, err := range result.Errors {
-				errorMessages[i] = fmt.Sprintf("%s:%d:%d: %s", err.Location.File, err.Location.Line, err.Location.Column, err.Text)
+				errorMessages[i] = buildErrorMessage(err)
 			}
 
 			// Use the BuildErrorPage helper
@@ -228,13 +273,46 @@ func handleRenderComponent(d deps.Deps) func(w http.ResponseWriter, r *http.Requ
 		}
 
 		// Verify build succeeded
-		if len(result.OutputFiles) == 0 {
+		if len(result.Output) == 0 {
 			http.Error(w, "No output generated from build", http.StatusInternalServerError)
 			return
 		}
 
+		// esbuild doesn't check types, so tsc does when it's turned on
+		if d.Config.Code.TypeCheck {
+			diagnostics, err := types.Check(srcPath, result.Inputs)
+			if err != nil {
+				slog.Warn("Type check failed", "path", componentPath, "error", err)
+			} else if len(diagnostics) > 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				TypeErrorPage(componentPath, diagnostics).RenderPage(w, r)
+				return
+			}
+		}
+
+		// The CSS the component imports, through Tailwind or PostCSS when its
+		// directory uses them
+		stylesheet, err := styles.Build(srcPath, result)
+		if err != nil {
+			slog.Error("Stylesheet failed", "path", componentPath, "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+			BuildErrorPage(componentPath, []string{err.Error()}).RenderPage(w, r)
+			return
+		}
+
+		// The packages the component loads from the CDN are in the page's
+		// import map
+		packages := builds.dependencies.importMap(srcPath, result.Externals, reactImports(d.Config))
+		if dirConfig, err := loadDirConfig(srcPath); err == nil && dirConfig != nil {
+			maps.Copy(packages, dirConfig.Imports)
+		}
+		if notModified(w, r, pageETag(result.Hash, componentName, fmt.Sprint(packages), stylesheet)) {
+			return
+		}
+
 		// Generate the HTML page using Go HTML format
-		page := ReactComponentPage(d.Config, componentName,
+		page := ReactComponentPage(d.Config, componentName, packages,
+			ComponentStylesheet(stylesheet),
 			ComponentLoader(componentPath, componentName, true),
 		)
 
@@ -244,7 +322,7 @@ func handleRenderComponent(d deps.Deps) func(w http.ResponseWriter, r *http.Requ
 }
 
 // handleServeModule builds and serves a React component as an ES module
-func handleServeModule(w http.ResponseWriter, r *http.Request) {
+func handleServeModule(builds *buildCache, w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -257,6 +335,9 @@ func handleServeModule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// <module>.map is the module's source map
+	componentPath, sourceMap := strings.CutSuffix(componentPath, ".map")
+
 	// Validate and sanitize the path
 	cleanPath := filepath.Clean(componentPath)
 	if strings.Contains(cleanPath, "..") {
@@ -280,6 +361,69 @@ func handleServeModule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Build with esbuild to get the compiled JavaScript as ES module
+	result := builds.Build("module", srcPath, sourceCode, moduleBuildOptions(srcPath, sourceCode), r.URL.Query().Has(rebuildParam))
+
+	if result.Overloaded {
+		writeBuildOverloaded(w, result)
+		return
+	}
+
+	// Check for build errors
+	if len(result.Errors) > 0 {
+		//errorMessages := make([]string, len(result.Errors))
+		//for i, er := range result.Errors {
+		//	errorMessages[i] = fmt.Sprintf("%s:%d:%d: %s", er.Location.File, er.Location.Line, er.Location.Column, er.Text)
+		//}
+
+		errorResponse := map[string]interface{}{
+			"error":   "Build failed",
+			"details": fmt.Sprintf("%+v", result.Errors),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(errorResponse)
+		return
+	}
+
+	// Get the compiled JavaScript
+	if len(result.Output) == 0 {
+		http.Error(w, "No output generated from build", http.StatusInternalServerError)
+		return
+	}
+
+	if sourceMap {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		if notModified(w, r, result.Hash+".map") {
+			return
+		}
+		w.Write(result.SourceMap)
+		return
+	}
+
+	// Return the ES module code. Browsers check their copy is current with
+	// its ETag, so edits show up on the next load.
+	w.Header().Set("Content-Type", "application/javascript")
+	w.Header().Set("Cache-Control", "no-cache")
+	if notModified(w, r, result.Hash) {
+		return
+	}
+	w.Write(result.Output)
+}
+
+// buildErrorMessage formats an esbuild error with where it is, when it's
+// about a file
+func buildErrorMessage(msg api.Message) string {
+	if msg.Location == nil {
+		return msg.Text
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", msg.Location.File, msg.Location.Line, msg.Location.Column, msg.Text)
+}
+
+// moduleBuildOptions builds a component as an ES module with a linked source
+// map, which is served next to it
+func moduleBuildOptions(srcPath string, sourceCode []byte) api.BuildOptions {
 	var loader api.Loader
 	switch filepath.Ext(srcPath) {
 	case ".js":
@@ -292,8 +436,7 @@ func handleServeModule(w http.ResponseWriter, r *http.Request) {
 		loader = api.LoaderTSX
 	}
 
-	// Build with esbuild to get the compiled JavaScript as ES module
-	result := api.Build(api.BuildOptions{
+	return api.BuildOptions{
 		Stdin: &api.StdinOptions{
 			Contents:   string(sourceCode),
 			ResolveDir: filepath.Dir(srcPath),
@@ -310,7 +453,8 @@ func handleServeModule(w http.ResponseWriter, r *http.Request) {
 		Format:          api.FormatESModule,
 		Bundle:          true,
 		Write:           false,
-		Sourcemap:       api.SourceMapInline,
+		Sourcemap:       api.SourceMapLinked,
+		Outfile:         srcPath, // Names the map after the module, as <module>.map
 		TreeShaking:     api.TreeShakingTrue,
 		Target:          api.ESNext,
 		JSX:             api.JSXAutomatic,
@@ -335,41 +479,11 @@ func handleServeModule(w http.ResponseWriter, r *http.Request) {
 				"isolatedModules": true
 			}
 		}`,
-	})
-
-	// Check for build errors
-	if len(result.Errors) > 0 {
-		//errorMessages := make([]string, len(result.Errors))
-		//for i, er := range result.Errors {
-		//	errorMessages[i] = fmt.Sprintf("%s:%d:%d: %s", er.Location.File, er.Location.Line, er.Location.Column, er.Text)
-		//}
-
-		errorResponse := map[string]interface{}{
-			"error":   "Build failed",
-			"details": fmt.Sprintf("%+v", result.Errors),
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(errorResponse)
-		return
 	}
-
-	// Get the compiled JavaScript
-	if len(result.OutputFiles) == 0 {
-		http.Error(w, "No output generated from build", http.StatusInternalServerError)
-		return
-	}
-
-	compiledJS := string(result.OutputFiles[0].Contents)
-
-	// Return the ES module code
-	w.Header().Set("Content-Type", "application/javascript")
-	w.Header().Set("Cache-Control", "no-cache") // Prevent caching during development
-	w.Write([]byte(compiledJS))
 }
 
 // handlePageComponent builds and renders a React component as CommonJS in a complete HTML page
-func handlePageComponent(d deps.Deps) func(w http.ResponseWriter, r *http.Request) {
+func handlePageComponent(d deps.Deps, builds *buildCache) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -427,7 +541,7 @@ func handlePageComponent(d deps.Deps) func(w http.ResponseWriter, r *http.Reques
 		}
 
 		// Build with esbuild to get the compiled JavaScript as CommonJS bundle
-		result := api.Build(api.BuildOptions{
+		result := builds.Build("page", srcPath, sourceCode, api.BuildOptions{
 			Stdin: &api.StdinOptions{
 				Contents:   string(sourceCode),
 				ResolveDir: filepath.Dir(srcPath),
@@ -444,6 +558,7 @@ func handlePageComponent(d deps.Deps) func(w http.ResponseWriter, r *http.Reques
 			Format:          api.FormatCommonJS, // Use CommonJS format
 			Bundle:          true,
 			Write:           false,
+			Sourcemap:       api.SourceMapInline, // The script is inlined in the page
 			TreeShaking:     api.TreeShakingTrue,
 			Target:          api.ES2020, // More compatible target
 			JSX:             api.JSXAutomatic,
@@ -468,13 +583,18 @@ func handlePageComponent(d deps.Deps) func(w http.ResponseWriter, r *http.Reques
 				"isolatedModules": true
 			}
 		}`,
-		})
+		}, r.URL.Query().Has(rebuildParam))
+
+		if result.Overloaded {
+			writeBuildOverloaded(w, result)
+			return
+		}
 
 		// Check for build errors
 		if len(result.Errors) > 0 {
 			errorMessages := make([]string, len(result.Errors))
 			for i, err := range result.Errors {
-				errorMessages[i] = fmt.Sprintf("%s:%d:%d: %s", err.Location.File, err.Location.Line, err.Location.Column, err.Text)
+				errorMessages[i] = buildErrorMessage(err)
 			}
 
 			slog.Error("Build failed", "path", componentPath, "errors", errorMessages)
@@ -486,13 +606,16 @@ func handlePageComponent(d deps.Deps) func(w http.ResponseWriter, r *http.Reques
 		}
 
 		// Verify build succeeded
-		if len(result.OutputFiles) == 0 {
+		if len(result.Output) == 0 {
 			http.Error(w, "No output generated from build", http.StatusInternalServerError)
 			return
 		}
+		if notModified(w, r, pageETag(result.Hash, componentName)) {
+			return
+		}
 
 		// Get the compiled JavaScript
-		compiledJS := string(result.OutputFiles[0].Contents)
+		compiledJS := string(result.Output)
 
 		// Generate the HTML page using CommonJS format
 		page := CommonJSComponentPage(d.Config, componentName, compiledJS)
@@ -0,0 +1,274 @@
+package code
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// How a directory's npm imports resolve
+const (
+	// DependenciesBundle bundles them from whatever node_modules the tree has
+	DependenciesBundle = "bundle"
+	// DependenciesESM leaves them out of builds and loads them from the CDN
+	// through the page's import map
+	DependenciesESM = "esm"
+	// DependenciesNPM installs the project's package.json into its
+	// node_modules and bundles them from there
+	DependenciesNPM = "npm"
+)
+
+// installStampFile records what a project's node_modules was installed from
+const installStampFile = ".flow-install"
+
+// sharedPackages are loaded by every page, so packages from the CDN use
+// them rather than their own copies
+var sharedPackages = []string{"react", "react-dom"}
+
+// packageJSON is the part of a package.json dependencies are resolved from
+type packageJSON struct {
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+}
+
+// dependencyResolver resolves the npm imports of the components in each
+// directory the way it's configured to
+type dependencyResolver struct {
+	cfg config.CodeConfig
+
+	mu       sync.Mutex
+	installs map[string]*sync.Mutex // By project directory
+
+	// install runs the npm install in dir; nil runs cfg.NPMCommand
+	install func(ctx context.Context, dir string) error
+}
+
+func newDependencyResolver(cfg config.CodeConfig) *dependencyResolver {
+	return &dependencyResolver{cfg: cfg, installs: make(map[string]*sync.Mutex)}
+}
+
+// mode returns how the npm imports of the component at srcPath resolve: the
+// setting of the closest directory configured, or else the default
+func (d *dependencyResolver) mode(srcPath string) string {
+	mode, longest := d.cfg.Dependencies, -1
+	path := filepath.Clean(srcPath)
+	for dir, dirMode := range d.cfg.DependencyDirs {
+		dir = filepath.Clean(dir)
+		if dir != "." && path != dir && !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			continue
+		}
+		if len(dir) > longest {
+			mode, longest = dirMode, len(dir)
+		}
+	}
+	if mode == "" {
+		return DependenciesBundle
+	}
+	return mode
+}
+
+// apply sets up a build of the component at srcPath to resolve its npm
+// imports, installing them first for "npm" directories
+func (d *dependencyResolver) apply(srcPath string, opts *api.BuildOptions) error {
+	switch mode := d.mode(srcPath); mode {
+	case DependenciesBundle:
+		return nil
+	case DependenciesESM:
+		// Only ES modules can import from the CDN; other formats bundle
+		// what they can as before
+		if opts.Format == api.FormatESModule {
+			opts.Packages = api.PackagesExternal
+		}
+		return nil
+	case DependenciesNPM:
+		dir, _, ok := findProject(srcPath)
+		if !ok {
+			return nil
+		}
+		return d.ensureInstalled(dir)
+	default:
+		return fmt.Errorf("unknown dependency resolution %q for %s", mode, srcPath)
+	}
+}
+
+// ensureInstalled installs a project's dependencies into its node_modules
+// unless they're already installed from its current package.json
+func (d *dependencyResolver) ensureInstalled(dir string) error {
+	d.mu.Lock()
+	lock, ok := d.installs[dir]
+	if !ok {
+		lock = &sync.Mutex{}
+		d.installs[dir] = lock
+	}
+	d.mu.Unlock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	stamp, err := installStamp(dir)
+	if err != nil {
+		return err
+	}
+	stampPath := filepath.Join(dir, "node_modules", installStampFile)
+	if installed, err := os.ReadFile(stampPath); err == nil && string(installed) == stamp {
+		return nil
+	}
+
+	timeout := d.cfg.InstallTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	install := d.install
+	if install == nil {
+		install = d.runNPM
+	}
+	if err := install(ctx, dir); err != nil {
+		return fmt.Errorf("failed to install dependencies in %s: %w", dir, err)
+	}
+
+	// The install may have written package-lock.json, so what it installed
+	// from is stamped after
+	if stamp, err = installStamp(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(stampPath), 0755); err != nil {
+		return fmt.Errorf("failed to record dependency install: %w", err)
+	}
+	if err := os.WriteFile(stampPath, []byte(stamp), 0644); err != nil {
+		return fmt.Errorf("failed to record dependency install: %w", err)
+	}
+	slog.Info("Installed component dependencies", "dir", dir, "duration", time.Since(start), "action", "code_npm_install")
+	return nil
+}
+
+// runNPM runs npm install in dir. Package scripts aren't run, since
+// components' package.json files aren't trusted.
+func (d *dependencyResolver) runNPM(ctx context.Context, dir string) error {
+	command := d.cfg.NPMCommand
+	if command == "" {
+		command = "npm"
+	}
+	cmd := exec.CommandContext(ctx, command, "install", "--no-audit", "--no-fund", "--ignore-scripts")
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, clipOutput(output))
+	}
+	return nil
+}
+
+// clipOutput returns the end of a command's output, where its errors are
+func clipOutput(output []byte) string {
+	const limit = 2000
+	text := strings.TrimSpace(string(output))
+	if len(text) > limit {
+		text = "..." + text[len(text)-limit:]
+	}
+	return text
+}
+
+// installStamp hashes what a project's dependencies are installed from
+func installStamp(dir string) (string, error) {
+	hash := sha256.New()
+	for _, name := range []string{"package.json", "package-lock.json"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00", name, len(data))
+		hash.Write(data)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// findProject returns the closest directory to srcPath with a package.json,
+// and what it depends on
+func findProject(srcPath string) (string, packageJSON, bool) {
+	dir := filepath.Dir(filepath.Clean(srcPath))
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+		if err == nil {
+			var pkg packageJSON
+			if err := json.Unmarshal(data, &pkg); err != nil {
+				slog.Warn("Failed to parse package.json", "error", err, "dir", dir)
+			}
+			return dir, pkg, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", packageJSON{}, false
+		}
+		dir = parent
+	}
+}
+
+// importMap returns the import map entries that load a component's external
+// npm imports from the CDN, at the versions its package.json asks for.
+// Imports the page already maps, URLs, and paths are left out.
+func (d *dependencyResolver) importMap(srcPath string, externals []string, mapped map[string]string) map[string]string {
+	imports := make(map[string]string)
+	if d.mode(srcPath) != DependenciesESM {
+		return imports
+	}
+	_, pkg, _ := findProject(srcPath)
+	cdnURL := strings.TrimSuffix(d.cfg.CDNURL, "/")
+	if cdnURL == "" {
+		cdnURL = "https://esm.sh"
+	}
+
+	for _, specifier := range externals {
+		if _, ok := mapped[specifier]; ok || !isPackageImport(specifier) {
+			continue
+		}
+		name, subpath := splitPackageImport(specifier)
+		version := pkg.Dependencies[name]
+		if version == "" {
+			version = pkg.DevDependencies[name]
+		}
+		url := cdnURL + "/" + name
+		if version != "" {
+			url += "@" + version
+		}
+		url += subpath
+		if !slices.Contains(sharedPackages, name) {
+			url += "?external=" + strings.Join(sharedPackages, ",")
+		}
+		imports[specifier] = url
+	}
+	return imports
+}
+
+// isPackageImport reports whether an import specifier names an npm package,
+// rather than a path or URL
+func isPackageImport(specifier string) bool {
+	if specifier == "" || strings.HasPrefix(specifier, ".") || strings.HasPrefix(specifier, "/") {
+		return false
+	}
+	return !strings.Contains(specifier, ":")
+}
+
+// splitPackageImport splits a specifier such as "@scope/pkg/sub" into its
+// package, "@scope/pkg", and the path within it, "/sub"
+func splitPackageImport(specifier string) (string, string) {
+	parts := strings.SplitN(specifier, "/", 3)
+	if strings.HasPrefix(specifier, "@") && len(parts) > 1 {
+		name := parts[0] + "/" + parts[1]
+		return name, strings.TrimPrefix(specifier, name)
+	}
+	return parts[0], strings.TrimPrefix(specifier, parts[0])
+}
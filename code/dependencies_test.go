@@ -0,0 +1,160 @@
+package code
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/breadchris/flow/config"
+)
+
+// writeProject writes a package.json and an App.tsx that imports the given
+// specifiers, returning App.tsx's path and source
+func writeProject(t *testing.T, dir, packageJSON string, imports ...string) (string, []byte) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(packageJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var source strings.Builder
+	for i, specifier := range imports {
+		source.WriteString("import * as dep" + string(rune('a'+i)) + " from \"" + specifier + "\";\n")
+	}
+	source.WriteString("export default function App() {\n  return <div>{[")
+	for i := range imports {
+		source.WriteString("String(dep" + string(rune('a'+i)) + "), ")
+	}
+	source.WriteString("]}</div>;\n}\n")
+
+	srcPath := filepath.Join(dir, "App.tsx")
+	if err := os.WriteFile(srcPath, []byte(source.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return srcPath, []byte(source.String())
+}
+
+func TestDependencyMode(t *testing.T) {
+	resolver := newDependencyResolver(config.CodeConfig{
+		Dependencies: DependenciesESM,
+		DependencyDirs: map[string]string{
+			"data/apps":        DependenciesNPM,
+			"data/apps/legacy": DependenciesBundle,
+		},
+	})
+	tests := []struct {
+		srcPath string
+		want    string
+	}{
+		{"data/session/abc/App.tsx", DependenciesESM},
+		{"data/apps/todo/App.tsx", DependenciesNPM},
+		{"./data/apps/legacy/App.tsx", DependenciesBundle},
+		{"data/applications/App.tsx", DependenciesESM},
+	}
+	for _, tt := range tests {
+		if got := resolver.mode(tt.srcPath); got != tt.want {
+			t.Errorf("mode(%q) = %q; want %q", tt.srcPath, got, tt.want)
+		}
+	}
+	if got := newDependencyResolver(config.CodeConfig{}).mode("App.tsx"); got != DependenciesBundle {
+		t.Errorf("mode without configuration = %q; want bundle", got)
+	}
+}
+
+func TestESMDependenciesLoadFromTheCDN(t *testing.T) {
+	dir := t.TempDir()
+	srcPath, source := writeProject(t, dir, `{"dependencies": {"date-fns": "^3.6.0", "@tanstack/react-query": "5.0.0"}}`,
+		"date-fns", "date-fns/locale", "@tanstack/react-query", "nanoid", "./App.tsx")
+	builds := newBuildCache(config.CodeConfig{Dependencies: DependenciesESM, CDNURL: "https://cdn.example.com/"})
+
+	result := builds.Build("module", srcPath, source, moduleBuildOptions(srcPath, source), false)
+	if len(result.Errors) > 0 {
+		t.Fatalf("build failed: %+v", result.Errors)
+	}
+	want := "@tanstack/react-query,date-fns,date-fns/locale,nanoid,react/jsx-runtime"
+	if got := strings.Join(result.Externals, ","); got != want {
+		t.Errorf("externals = %s; want %s", got, want)
+	}
+
+	imports := builds.dependencies.importMap(srcPath, result.Externals, reactImports(config.AppConfig{}))
+	wantImports := map[string]string{
+		"date-fns":              "https://cdn.example.com/date-fns@^3.6.0?external=react,react-dom",
+		"date-fns/locale":       "https://cdn.example.com/date-fns@^3.6.0/locale?external=react,react-dom",
+		"@tanstack/react-query": "https://cdn.example.com/@tanstack/react-query@5.0.0?external=react,react-dom",
+		"nanoid":                "https://cdn.example.com/nanoid?external=react,react-dom",
+	}
+	if len(imports) != len(wantImports) {
+		t.Errorf("import map = %v; want %v", imports, wantImports)
+	}
+	for specifier, url := range wantImports {
+		if imports[specifier] != url {
+			t.Errorf("import map[%s] = %q; want %q", specifier, imports[specifier], url)
+		}
+	}
+}
+
+func TestNPMDependenciesInstallOnce(t *testing.T) {
+	dir := t.TempDir()
+	srcPath, source := writeProject(t, dir, `{"dependencies": {"greeting": "1.0.0"}}`, "greeting")
+	builds := newBuildCache(config.CodeConfig{Dependencies: DependenciesNPM})
+	installs := 0
+	builds.dependencies.install = func(ctx context.Context, dir string) error {
+		installs++
+		pkgDir := filepath.Join(dir, "node_modules", "greeting")
+		if err := os.MkdirAll(pkgDir, 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(pkgDir, "index.js"), []byte(`export const hello = "installed greeting";`), 0644)
+	}
+
+	for i := 0; i < 2; i++ {
+		result := builds.Build("module", srcPath, source, moduleBuildOptions(srcPath, source), true)
+		if len(result.Errors) > 0 {
+			t.Fatalf("build %d failed: %+v", i, result.Errors)
+		}
+		if !strings.Contains(string(result.Output), "installed greeting") {
+			t.Errorf("build %d didn't bundle the installed package", i)
+		}
+	}
+	if installs != 1 {
+		t.Errorf("%d installs for an unchanged package.json; want 1", installs)
+	}
+
+	writeProject(t, dir, `{"dependencies": {"greeting": "1.1.0"}}`, "greeting")
+	builds.Build("module", srcPath, source, moduleBuildOptions(srcPath, source), false)
+	if installs != 2 {
+		t.Errorf("%d installs after package.json changed; want 2", installs)
+	}
+}
+
+func TestNPMInstallFailureFailsTheBuild(t *testing.T) {
+	dir := t.TempDir()
+	srcPath, source := writeProject(t, dir, `{"dependencies": {"greeting": "1.0.0"}}`, "greeting")
+	builds := newBuildCache(config.CodeConfig{Dependencies: DependenciesNPM})
+	builds.dependencies.install = func(ctx context.Context, dir string) error {
+		return errors.New("404 Not Found - greeting")
+	}
+
+	result := builds.Build("module", srcPath, source, moduleBuildOptions(srcPath, source), false)
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Text, "404 Not Found") {
+		t.Errorf("errors = %+v; want the install's error", result.Errors)
+	}
+}
+
+func TestSplitPackageImport(t *testing.T) {
+	tests := []struct {
+		specifier, name, subpath string
+	}{
+		{"lodash", "lodash", ""},
+		{"lodash/debounce", "lodash", "/debounce"},
+		{"@scope/pkg", "@scope/pkg", ""},
+		{"@scope/pkg/sub/path", "@scope/pkg", "/sub/path"},
+	}
+	for _, tt := range tests {
+		name, subpath := splitPackageImport(tt.specifier)
+		if name != tt.name || subpath != tt.subpath {
+			t.Errorf("splitPackageImport(%q) = %q, %q; want %q, %q", tt.specifier, name, subpath, tt.name, tt.subpath)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package code
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// dirConfigFiles are the names of a directory's build configuration, in the
+// order they're looked for
+var dirConfigFiles = []string{"coderunner.json", "flow.json"}
+
+// loaders are the esbuild loaders a DirConfig can map extensions to
+var loaders = map[string]api.Loader{
+	"base64":  api.LoaderBase64,
+	"binary":  api.LoaderBinary,
+	"copy":    api.LoaderCopy,
+	"css":     api.LoaderCSS,
+	"dataurl": api.LoaderDataURL,
+	"default": api.LoaderDefault,
+	"empty":   api.LoaderEmpty,
+	"file":    api.LoaderFile,
+	"js":      api.LoaderJS,
+	"json":    api.LoaderJSON,
+	"jsx":     api.LoaderJSX,
+	"text":    api.LoaderText,
+	"ts":      api.LoaderTS,
+	"tsx":     api.LoaderTSX,
+}
+
+// DirConfig is the coderunner.json or flow.json of a directory, merged over
+// the build options of the components in it and its subdirectories
+type DirConfig struct {
+	Externals []string          `json:"externals"` // Imports left for the page to resolve, as well as the defaults
+	Imports   map[string]string `json:"imports"`   // Import map entries of /render/ pages, such as for Externals
+	JSX       *JSXConfig        `json:"jsx"`
+	Alias     map[string]string `json:"alias"`  // Imports to what they resolve to; "./" paths are relative to the config
+	Define    map[string]string `json:"define"` // Identifiers to the JavaScript they're replaced with
+	Env       map[string]string `json:"env"`    // Values of process.env.<name> and import.meta.env.<name>
+	Loader    map[string]string `json:"loader"` // File extensions to esbuild loaders, such as ".svg": "text"
+
+	dir string // Where the config is
+}
+
+// JSXConfig changes how a directory's JSX is compiled
+type JSXConfig struct {
+	Runtime      string `json:"runtime"` // "automatic" or "classic"
+	ImportSource string `json:"importSource"`
+	Factory      string `json:"factory"`
+	Fragment     string `json:"fragment"`
+}
+
+// loadDirConfig returns the build configuration closest to srcPath, if
+// there is one
+func loadDirConfig(srcPath string) (*DirConfig, error) {
+	dir := filepath.Dir(filepath.Clean(srcPath))
+	for {
+		for _, name := range dirConfigFiles {
+			path := filepath.Join(dir, name)
+			data, err := os.ReadFile(path)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			cfg := &DirConfig{dir: dir}
+			if err := json.Unmarshal(data, cfg); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+			return cfg, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// applyDirConfig merges the build configuration closest to srcPath over a
+// build's options
+func applyDirConfig(srcPath string, opts *api.BuildOptions) error {
+	cfg, err := loadDirConfig(srcPath)
+	if err != nil || cfg == nil {
+		return err
+	}
+	return cfg.apply(opts)
+}
+
+// apply merges the configuration over a build's options, leaving the maps
+// and slices they were given unchanged
+func (c *DirConfig) apply(opts *api.BuildOptions) error {
+	for _, external := range c.Externals {
+		if !slices.Contains(opts.External, external) {
+			opts.External = append(slices.Clip(opts.External), external)
+		}
+	}
+
+	if len(c.Loader) > 0 {
+		opts.Loader = maps.Clone(opts.Loader)
+		if opts.Loader == nil {
+			opts.Loader = make(map[string]api.Loader)
+		}
+		for ext, name := range c.Loader {
+			loader, ok := loaders[name]
+			if !ok {
+				return fmt.Errorf("unknown loader %q for %s in %s", name, ext, c.dir)
+			}
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			opts.Loader[ext] = loader
+		}
+	}
+
+	if len(c.Alias) > 0 {
+		opts.Alias = maps.Clone(opts.Alias)
+		if opts.Alias == nil {
+			opts.Alias = make(map[string]string)
+		}
+		for from, to := range c.Alias {
+			// esbuild resolves relative aliases from its working directory
+			if strings.HasPrefix(to, "./") || strings.HasPrefix(to, "../") {
+				abs, err := filepath.Abs(filepath.Join(c.dir, to))
+				if err != nil {
+					return fmt.Errorf("failed to resolve alias %s: %w", from, err)
+				}
+				to = abs
+			}
+			opts.Alias[from] = to
+		}
+	}
+
+	if len(c.Define) > 0 || len(c.Env) > 0 {
+		opts.Define = maps.Clone(opts.Define)
+		if opts.Define == nil {
+			opts.Define = make(map[string]string)
+		}
+		maps.Copy(opts.Define, c.Define)
+		for name, value := range c.Env {
+			quoted, _ := json.Marshal(value)
+			opts.Define["process.env."+name] = string(quoted)
+			opts.Define["import.meta.env."+name] = string(quoted)
+		}
+	}
+
+	if c.JSX != nil {
+		return c.JSX.apply(opts)
+	}
+	return nil
+}
+
+// apply sets a build's JSX options, and the tsconfig's too, since esbuild
+// takes its JSX settings from the tsconfig when the options are the defaults
+func (j *JSXConfig) apply(opts *api.BuildOptions) error {
+	tsconfig := map[string]any{}
+	if opts.TsconfigRaw != "" {
+		if err := json.Unmarshal([]byte(opts.TsconfigRaw), &tsconfig); err != nil {
+			return fmt.Errorf("failed to parse tsconfig: %w", err)
+		}
+	}
+	compilerOptions, _ := tsconfig["compilerOptions"].(map[string]any)
+	if compilerOptions == nil {
+		compilerOptions = map[string]any{}
+		tsconfig["compilerOptions"] = compilerOptions
+	}
+
+	switch j.Runtime {
+	case "":
+	case "automatic":
+		opts.JSX = api.JSXAutomatic
+		compilerOptions["jsx"] = "react-jsx"
+	case "classic":
+		opts.JSX = api.JSXTransform
+		compilerOptions["jsx"] = "react"
+	default:
+		return fmt.Errorf("unknown JSX runtime %q", j.Runtime)
+	}
+	if j.ImportSource != "" {
+		opts.JSXImportSource = j.ImportSource
+		compilerOptions["jsxImportSource"] = j.ImportSource
+	}
+	if j.Factory != "" {
+		opts.JSXFactory = j.Factory
+		compilerOptions["jsxFactory"] = j.Factory
+	}
+	if j.Fragment != "" {
+		opts.JSXFragment = j.Fragment
+		compilerOptions["jsxFragmentFactory"] = j.Fragment
+	}
+
+	raw, err := json.Marshal(tsconfig)
+	if err != nil {
+		return fmt.Errorf("failed to write tsconfig: %w", err)
+	}
+	opts.TsconfigRaw = string(raw)
+	return nil
+}
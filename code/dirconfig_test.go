@@ -0,0 +1,109 @@
+package code
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/breadchris/flow/config"
+)
+
+// writeFiles writes files, by path relative to dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDirConfigMergesOverBuildOptions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"coderunner.json": `{
+			"externals": ["lodash"],
+			"jsx": {"runtime": "classic", "factory": "h", "fragment": "Fragment"},
+			"alias": {"@lib": "./src/lib"},
+			"env": {"API_URL": "https://api.example.com"},
+			"define": {"DEBUG": "false"},
+			"loader": {".svg": "text"}
+		}`,
+		"src/lib/greet.ts": `export const greet = (name: string) => "hi " + name;`,
+		"app/icon.svg":     `<svg id="icon"></svg>`,
+		"app/App.tsx": `import { greet } from "@lib/greet";
+import debounce from "lodash";
+import icon from "./icon.svg";
+export default function App() {
+  if (DEBUG) console.log(debounce);
+  return <div data-icon={icon}>{greet(process.env.API_URL)}</div>;
+}
+`,
+	})
+	srcPath := filepath.Join(dir, "app", "App.tsx")
+	source, _ := os.ReadFile(srcPath)
+
+	result := newBuildCache(config.CodeConfig{}).Build("module", srcPath, source, moduleBuildOptions(srcPath, source), false)
+	if len(result.Errors) > 0 {
+		t.Fatalf("build failed: %+v", result.Errors)
+	}
+	output := string(result.Output)
+	for _, want := range []string{`import "lodash"`, `h("div"`, `if (false)`, `"https://api.example.com"`, `<svg id="icon"></svg>`} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output doesn't contain %s:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "jsx-runtime") {
+		t.Errorf("output uses the automatic JSX runtime:\n%s", output)
+	}
+}
+
+func TestDirConfigIsTheClosest(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"flow.json":             `{"imports": {"lodash": "https://cdn.example.com/lodash"}}`,
+		"apps/coderunner.json":  `{"imports": {"lodash": "https://cdn.example.com/lodash-es"}}`,
+		"apps/todo/App.tsx":     "export default 1;",
+		"components/Button.tsx": "export default 1;",
+	})
+
+	tests := []struct {
+		srcPath string
+		want    string
+	}{
+		{"apps/todo/App.tsx", "https://cdn.example.com/lodash-es"},
+		{"components/Button.tsx", "https://cdn.example.com/lodash"},
+	}
+	for _, tt := range tests {
+		cfg, err := loadDirConfig(filepath.Join(dir, tt.srcPath))
+		if err != nil || cfg == nil {
+			t.Fatalf("loadDirConfig(%s) = %v, %v", tt.srcPath, cfg, err)
+		}
+		if got := cfg.Imports["lodash"]; got != tt.want {
+			t.Errorf("lodash for %s = %s; want %s", tt.srcPath, got, tt.want)
+		}
+	}
+}
+
+func TestDirConfigErrorsFailTheBuild(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"flow.json": `{"loader": {".svg": "svgr"}}`,
+		"App.tsx":   "export default 1;",
+	})
+	srcPath := filepath.Join(dir, "App.tsx")
+	source := []byte("export default 1;")
+
+	result := newBuildCache(config.CodeConfig{}).Build("module", srcPath, source, moduleBuildOptions(srcPath, source), false)
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Text, `unknown loader "svgr"`) {
+		t.Errorf("errors = %+v; want the unknown loader", result.Errors)
+	}
+	if got := buildErrorMessage(result.Errors[0]); got != result.Errors[0].Text {
+		t.Errorf("message = %q; want the error without a location", got)
+	}
+}
@@ -0,0 +1,353 @@
+package code
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	. "github.com/breadchris/share/html"
+)
+
+// monacoURL is where the editor page loads Monaco from
+const monacoURL = "https://cdn.jsdelivr.net/npm/monaco-editor@0.52.2"
+
+// previewExtensions are the files the editor can preview with /render/
+var previewExtensions = []string{".tsx", ".jsx", ".ts", ".js"}
+
+// BuildDiagnostic is an esbuild error, located in a file relative to the
+// user's root when it's in it
+type BuildDiagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`   // 1-based
+	Column   int    `json:"column"` // 0-based, in bytes
+	Length   int    `json:"length"`
+	LineText string `json:"lineText,omitempty"`
+	Text     string `json:"text"`
+}
+
+// BuildDiagnosticsResponse is the result of building a file for the editor
+type BuildDiagnosticsResponse struct {
+	Errors []BuildDiagnostic `json:"errors"`
+}
+
+// editorConfig is what the editor page's script is given
+type editorConfig struct {
+	Path           string `json:"path"`
+	ContentURL     string `json:"contentURL"`
+	SaveURL        string `json:"saveURL"`
+	DiagnosticsURL string `json:"diagnosticsURL"`
+	PreviewURL     string `json:"previewURL,omitempty"`
+	MonacoURL      string `json:"monacoURL"`
+}
+
+// handleEditor serves a Monaco editor for a file in the user's root, which
+// saves through the file API and previews components with /render/
+func handleEditor(files *fileManager, w http.ResponseWriter, r *http.Request) {
+	_, root, ok := files.root(w, r)
+	if !ok {
+		return
+	}
+	relPath := strings.Trim(r.PathValue("path"), "/")
+	path, err := resolve(root, relPath)
+	if err != nil || path == root {
+		writeFileError(w, "open editor", errInvalidPath)
+		return
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		http.Error(w, "Path is a directory", http.StatusBadRequest)
+		return
+	}
+
+	query := "?path=" + url.QueryEscape(relPath)
+	cfg := editorConfig{
+		Path:           relPath,
+		ContentURL:     "/code/files/content" + query,
+		SaveURL:        "/code/files",
+		DiagnosticsURL: "/code/files/diagnostics" + query,
+		MonacoURL:      monacoURL,
+	}
+	if previewPath, ok := previewPath(path); ok {
+		cfg.PreviewURL = "/code/render/" + previewPath
+	}
+	EditorPage(cfg).RenderPage(w, r)
+}
+
+// previewPath returns the path /render/ serves a file at, relative to the
+// working directory, if it can preview it
+func previewPath(path string) (string, bool) {
+	if !slices.Contains(previewExtensions, filepath.Ext(path)) {
+		return "", false
+	}
+	if filepath.IsAbs(path) {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", false
+		}
+		if path, err = filepath.Rel(cwd, path); err != nil || !within(".", path) {
+			return "", false
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path)), true
+}
+
+// handleDiagnostics builds a file in the user's root as /module/ does and
+// returns esbuild's errors, for the editor to mark
+func handleDiagnostics(files *fileManager, builds *buildCache, w http.ResponseWriter, r *http.Request) {
+	_, root, ok := files.root(w, r)
+	if !ok {
+		return
+	}
+	srcPath, err := resolve(root, r.URL.Query().Get("path"))
+	if err != nil || srcPath == root {
+		writeFileError(w, "build file", errInvalidPath)
+		return
+	}
+	sourceCode, err := os.ReadFile(srcPath)
+	if err != nil {
+		writeFileError(w, "read file", err)
+		return
+	}
+
+	result := builds.Build("module", srcPath, sourceCode, moduleBuildOptions(srcPath, sourceCode), false)
+	if result.Overloaded {
+		writeBuildOverloaded(w, result)
+		return
+	}
+	resp := BuildDiagnosticsResponse{Errors: make([]BuildDiagnostic, 0, len(result.Errors))}
+	for _, msg := range result.Errors {
+		diagnostic := BuildDiagnostic{Text: msg.Text}
+		if loc := msg.Location; loc != nil {
+			diagnostic.File = diagnosticFile(root, srcPath, loc.File)
+			diagnostic.Line = loc.Line
+			diagnostic.Column = loc.Column
+			diagnostic.Length = loc.Length
+			diagnostic.LineText = loc.LineText
+		}
+		resp.Errors = append(resp.Errors, diagnostic)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// diagnosticFile returns the path of the file an error is in relative to the
+// user's root, given the name esbuild gives it. The built file is named by
+// its base name, since it's built from stdin.
+func diagnosticFile(root, srcPath, file string) string {
+	if file == "" {
+		return ""
+	}
+	path := filepath.FromSlash(file)
+	if file == filepath.Base(srcPath) {
+		path = srcPath
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return file
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil || !within(absRoot, absPath) {
+		return file
+	}
+	rel, err := filepath.Rel(absRoot, absPath)
+	if err != nil {
+		return file
+	}
+	return filepath.ToSlash(rel)
+}
+
+// EditorPage creates the editor page for a file: Monaco beside a preview of
+// the component, with build errors over the preview
+func EditorPage(cfg editorConfig) *Node {
+	cfgJSON, _ := json.Marshal(cfg)
+	return Html(
+		Head(
+			Meta(Charset("UTF-8")),
+			Meta(Name("viewport"), Content("width=device-width, initial-scale=1.0")),
+			Title(T("Edit - "+cfg.Path)),
+			Link(Rel("stylesheet"), Href(cfg.MonacoURL+"/min/vs/editor/editor.main.css")),
+			EditorStyles(),
+		),
+		Body(
+			Div(Id("toolbar"),
+				Div(Class("path"), T(cfg.Path)),
+				Div(Id("status")),
+			),
+			Div(Id("panes"),
+				Div(Id("editor")),
+				Div(Id("preview"),
+					Div(Id("overlay")),
+				),
+			),
+			Script(Src(cfg.MonacoURL+"/min/vs/loader.js")),
+			Script(Raw(fmt.Sprintf("const config = %s;\n%s", cfgJSON, editorScript))),
+		),
+	)
+}
+
+// EditorStyles returns CSS styles for the editor page
+func EditorStyles() *Node {
+	return Style(Raw(`
+        body { margin: 0; height: 100vh; display: flex; flex-direction: column; font-family: system-ui, -apple-system, sans-serif; background: #1e1e1e; color: #ddd; }
+        #toolbar { display: flex; align-items: center; gap: 12px; padding: 6px 12px; background: #252526; border-bottom: 1px solid #333; font-size: 13px; }
+        #toolbar .path { font-family: monospace; flex: 1; }
+        #toolbar button { background: #0e639c; color: #fff; border: 0; padding: 4px 12px; border-radius: 3px; cursor: pointer; }
+        #status.error { color: #f48771; }
+        #panes { flex: 1; display: flex; min-height: 0; }
+        #editor { flex: 1; min-width: 0; }
+        #preview { flex: 1; position: relative; background: #fff; border-left: 1px solid #333; }
+        #preview iframe { width: 100%; height: 100%; border: 0; }
+        #overlay { display: none; position: absolute; inset: 0; overflow: auto; padding: 16px; background: rgba(30, 0, 0, 0.92); color: #fecaca; font-family: monospace; font-size: 13px; }
+        #overlay.visible { display: block; }
+        #overlay .error-item { margin-bottom: 12px; white-space: pre-wrap; cursor: pointer; }
+        #overlay .location { color: #f48771; }
+    `))
+}
+
+// editorScript runs the editor page. It saves with Ctrl/Cmd+S or the save
+// button, then builds the file and marks its errors, leaving the preview to
+// reload itself once the build succeeds.
+const editorScript = `
+    const statusText = document.getElementById('status');
+    const overlay = document.getElementById('overlay');
+    const preview = document.getElementById('preview');
+    let frame;
+    let broken = false;
+
+    function setStatus(text, isError) {
+        statusText.textContent = text;
+        statusText.className = isError ? 'error' : '';
+    }
+
+    if (config.previewURL) {
+        frame = document.createElement('iframe');
+        frame.src = config.previewURL;
+        preview.insertBefore(frame, overlay);
+    } else {
+        preview.style.display = 'none';
+    }
+
+    require.config({ paths: { vs: config.monacoURL + '/min/vs' } });
+    require(['vs/editor/editor.main'], async () => {
+        const ts = monaco.languages.typescript;
+        ts.typescriptDefaults.setCompilerOptions({
+            jsx: ts.JsxEmit.ReactJSX,
+            target: ts.ScriptTarget.ESNext,
+            module: ts.ModuleKind.ESNext,
+            moduleResolution: ts.ModuleResolutionKind.NodeJs,
+            allowNonTsExtensions: true,
+            esModuleInterop: true,
+        });
+        // Imports can't be resolved in the browser; esbuild reports the errors
+        // that matter when the file is saved
+        ts.typescriptDefaults.setDiagnosticsOptions({ noSemanticValidation: true });
+
+        let content = '';
+        const response = await fetch(config.contentURL);
+        if (response.ok) {
+            content = await response.text();
+        } else if (response.status !== 404) {
+            setStatus('Failed to load: ' + await response.text(), true);
+            return;
+        }
+
+        const model = monaco.editor.createModel(content, undefined, monaco.Uri.file(config.path));
+        const editor = monaco.editor.create(document.getElementById('editor'), {
+            model,
+            theme: 'vs-dark',
+            automaticLayout: true,
+            minimap: { enabled: false },
+        });
+        let saved = model.getAlternativeVersionId();
+        model.onDidChangeContent(() => {
+            setStatus(model.getAlternativeVersionId() === saved ? '' : 'Unsaved changes');
+        });
+        window.addEventListener('beforeunload', (event) => {
+            if (model.getAlternativeVersionId() !== saved) {
+                event.preventDefault();
+            }
+        });
+
+        async function save() {
+            const version = model.getAlternativeVersionId();
+            setStatus('Saving...');
+            const response = await fetch(config.saveURL, {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ path: config.path, content: model.getValue() }),
+            });
+            if (!response.ok) {
+                setStatus('Failed to save: ' + await response.text(), true);
+                return;
+            }
+            saved = version;
+            setStatus('Saved');
+            if (config.previewURL) {
+                await checkBuild();
+            }
+        }
+
+        // Build the saved file and show its errors in the editor and over the
+        // preview. A preview that showed a build error is loaded again once
+        // the build succeeds; otherwise it reloads itself.
+        async function checkBuild() {
+            const response = await fetch(config.diagnosticsURL);
+            if (!response.ok) {
+                setStatus('Failed to build: ' + await response.text(), true);
+                return;
+            }
+            const { errors } = await response.json();
+            monaco.editor.setModelMarkers(model, 'esbuild', errors
+                .filter((error) => error.file === config.path && error.line > 0)
+                .map((error) => ({
+                    severity: monaco.MarkerSeverity.Error,
+                    message: error.text,
+                    startLineNumber: error.line,
+                    startColumn: error.column + 1,
+                    endLineNumber: error.line,
+                    endColumn: error.column + 1 + Math.max(error.length, 1),
+                })));
+
+            overlay.replaceChildren(...errors.map((error) => {
+                const item = document.createElement('div');
+                item.className = 'error-item';
+                const location = document.createElement('div');
+                location.className = 'location';
+                location.textContent = error.file ? error.file + ':' + error.line + ':' + error.column : 'Build error';
+                const text = document.createElement('div');
+                text.textContent = error.text + (error.lineText ? '\n\n    ' + error.lineText : '');
+                item.append(location, text);
+                if (error.file === config.path && error.line > 0) {
+                    item.onclick = () => {
+                        editor.revealLineInCenter(error.line);
+                        editor.setPosition({ lineNumber: error.line, column: error.column + 1 });
+                        editor.focus();
+                    };
+                }
+                return item;
+            }));
+            overlay.classList.toggle('visible', errors.length > 0);
+            if (errors.length > 0) {
+                setStatus('Saved with ' + errors.length + ' build error' + (errors.length === 1 ? '' : 's'), true);
+            } else if (broken) {
+                frame.src = config.previewURL;
+            }
+            broken = errors.length > 0;
+        }
+
+        const button = document.createElement('button');
+        button.textContent = 'Save';
+        button.onclick = save;
+        document.getElementById('toolbar').append(button);
+        editor.addCommand(monaco.KeyMod.CtrlCmd | monaco.KeyCode.KeyS, save);
+
+        if (config.previewURL && response.ok) {
+            await checkBuild();
+        }
+    });
+`
@@ -0,0 +1,83 @@
+package code
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/breadchris/flow/config"
+)
+
+func TestDiagnosticsLocateErrorsInTheUsersRoot(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "users", "alice")
+	if err := os.MkdirAll(filepath.Join(root, "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	sources := map[string]string{
+		"App.tsx":      "import { greet } from \"./lib/greet\";\nexport default function App() {\n  return <div>{greet(}</div>;\n}\n",
+		"lib/greet.ts": "export function greet() { return \"hi\" }\n",
+		"Broken.tsx":   "import { greet } from \"./lib/bad\";\nexport default greet;\n",
+		"lib/bad.ts":   "export const greet = ;\n",
+	}
+	for name, content := range sources {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, builds := testFileManager(dir), newBuildCache(config.CodeConfig{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDiagnostics(files, builds, w, r)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path, file string
+		line       int
+	}{
+		{"App.tsx", "App.tsx", 3},
+		{"Broken.tsx", "lib/bad.ts", 1},
+	}
+	for _, tt := range tests {
+		status, body := fileRequest(t, server, "alice", "GET", "/files/diagnostics?path="+tt.path, "")
+		var resp BuildDiagnosticsResponse
+		if err := json.Unmarshal([]byte(body), &resp); status != http.StatusOK || err != nil {
+			t.Fatalf("diagnostics for %s = %d %s", tt.path, status, body)
+		}
+		if len(resp.Errors) == 0 || resp.Errors[0].File != tt.file || resp.Errors[0].Line != tt.line {
+			t.Errorf("diagnostics for %s = %+v; want an error at %s:%d", tt.path, resp.Errors, tt.file, tt.line)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "App.tsx"), []byte("export default function App() {\n  return <div />;\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, body := fileRequest(t, server, "alice", "GET", "/files/diagnostics?path=App.tsx", ""); body != "{\"errors\":[]}\n" {
+		t.Errorf("diagnostics for a fixed file = %s; want no errors", body)
+	}
+}
+
+func TestPreviewPath(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"data/users/alice/App.tsx", "data/users/alice/App.tsx", true},
+		{filepath.Join(cwd, "data", "App.jsx"), "data/App.jsx", true},
+		{"data/users/alice/notes.md", "", false},
+		{filepath.Join(filepath.Dir(cwd), "App.tsx"), "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := previewPath(tt.path); got != tt.want || ok != tt.ok {
+			t.Errorf("previewPath(%q) = %q, %v; want %q, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
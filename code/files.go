@@ -0,0 +1,432 @@
+package code
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/breadchris/flow/deps"
+)
+
+// maxFileBody is the largest request the file API reads, content included
+const maxFileBody = 10 << 20
+
+// maxListingDepth is the deepest a listing recurses into subdirectories
+const maxListingDepth = 5
+
+// errInvalidPath is returned for paths that would leave a user's root
+var errInvalidPath = errors.New("invalid path")
+
+// RenameFileRequest renames a file or directory within its directory
+type RenameFileRequest struct {
+	Path string `json:"path"`
+	Name string `json:"name"`
+}
+
+// MoveFileRequest moves a file or directory to another path
+type MoveFileRequest struct {
+	Path string `json:"path"`
+	To   string `json:"to"`
+}
+
+// fileManager serves the files of the share directory. Each user works in
+// their own root under users/, and admins in the whole share directory.
+type fileManager struct {
+	dir string
+
+	// userID returns who made a request, or an error when nobody signed in
+	userID  func(r *http.Request) (string, error)
+	isAdmin func(userID string) bool
+}
+
+func newFileManager(d deps.Deps) *fileManager {
+	dir := d.Config.ShareDir
+	if dir == "" {
+		dir = d.Dir
+	}
+	if dir == "" {
+		dir = "data"
+	}
+	return &fileManager{dir: dir, userID: sessionUserID(d), isAdmin: d.Config.IsAdmin}
+}
+
+// sessionUserID returns the function that finds who signed in to make a request
+func sessionUserID(d deps.Deps) func(r *http.Request) (string, error) {
+	return func(r *http.Request) (string, error) {
+		if d.Session == nil {
+			return "", fmt.Errorf("no session manager")
+		}
+		return d.Session.UserIDFromRequest(r)
+	}
+}
+
+// root returns the directory the user who made a request works in, creating
+// it if needed. It writes the error response when there isn't one.
+func (f *fileManager) root(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	userID, err := f.userID(r)
+	if err != nil || userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", "", false
+	}
+
+	root := filepath.Clean(f.dir)
+	if !f.isAdmin(userID) {
+		if userID == "." || userID == ".." || strings.ContainsAny(userID, `/\`) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return "", "", false
+		}
+		root = filepath.Join(f.dir, "users", userID)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create user directory: %v", err), http.StatusInternalServerError)
+		return "", "", false
+	}
+	return userID, root, true
+}
+
+// resolve returns where a slash-separated path relative to root is on disk.
+// Paths with ".." and paths through symlinks that lead out of root are
+// rejected.
+func resolve(root, relPath string) (string, error) {
+	relPath = strings.Trim(filepath.FromSlash(relPath), string(filepath.Separator))
+	for _, part := range strings.Split(relPath, string(filepath.Separator)) {
+		if part == ".." {
+			return "", errInvalidPath
+		}
+	}
+	path := filepath.Join(root, relPath)
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve root: %w", err)
+	}
+	// The closest part of the path that exists is where a symlink would
+	// have taken it
+	existing := path
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			real, err := filepath.EvalSymlinks(existing)
+			if err != nil || !within(realRoot, real) {
+				return "", errInvalidPath
+			}
+			return path, nil
+		}
+		if existing == root {
+			return path, nil
+		}
+		existing = filepath.Dir(existing)
+	}
+}
+
+// within reports whether path is dir or inside it
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// fileInfo describes the file at path for the listing of root
+func fileInfo(root, path string) (FileInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return FileInfo{
+		Name:         info.Name(),
+		Path:         filepath.ToSlash(rel),
+		IsDir:        info.IsDir(),
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+	}, nil
+}
+
+// writeFileError responds to a failed file operation with the status its
+// error calls for
+func writeFileError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, errInvalidPath):
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+	case errors.Is(err, os.ErrNotExist):
+		http.Error(w, "File not found", http.StatusNotFound)
+	case errors.Is(err, os.ErrExist):
+		http.Error(w, "File already exists", http.StatusConflict)
+	default:
+		http.Error(w, fmt.Sprintf("Failed to %s: %v", action, err), http.StatusInternalServerError)
+	}
+}
+
+func writeFileInfo(w http.ResponseWriter, status int, info FileInfo) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(info)
+}
+
+// decodeFileRequest reads a JSON request body into v
+func decodeFileRequest(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFileBody)).Decode(v); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// handleList lists a directory, and its subdirectories down to ?depth=
+func (f *fileManager) handleList(w http.ResponseWriter, r *http.Request) {
+	_, root, ok := f.root(w, r)
+	if !ok {
+		return
+	}
+	relPath := strings.Trim(r.URL.Query().Get("path"), "/")
+	dir, err := resolve(root, relPath)
+	if err != nil {
+		writeFileError(w, "list files", err)
+		return
+	}
+	if info, err := os.Stat(dir); err != nil {
+		writeFileError(w, "list files", err)
+		return
+	} else if !info.IsDir() {
+		http.Error(w, "Path is not a directory", http.StatusBadRequest)
+		return
+	}
+
+	depth := 0
+	if depthStr := r.URL.Query().Get("depth"); depthStr != "" {
+		if depth, err = strconv.Atoi(depthStr); err != nil || depth < 0 {
+			http.Error(w, "Invalid depth", http.StatusBadRequest)
+			return
+		}
+		depth = min(depth, maxListingDepth)
+	}
+
+	files, err := buildDirectoryListing(root, relPath, depth)
+	if err != nil {
+		writeFileError(w, "list files", err)
+		return
+	}
+	if files == nil {
+		files = []FileInfo{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(files)
+}
+
+// handleRead serves the content of a file
+func (f *fileManager) handleRead(w http.ResponseWriter, r *http.Request) {
+	_, root, ok := f.root(w, r)
+	if !ok {
+		return
+	}
+	path, err := resolve(root, r.URL.Query().Get("path"))
+	if err != nil {
+		writeFileError(w, "read file", err)
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		writeFileError(w, "read file", err)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		writeFileError(w, "read file", err)
+		return
+	}
+	if info.IsDir() {
+		http.Error(w, "Path is a directory", http.StatusBadRequest)
+		return
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+// handleCreate creates a file, and the directories it's in, failing if it
+// already exists
+func (f *fileManager) handleCreate(w http.ResponseWriter, r *http.Request) {
+	f.writeFile(w, r, true)
+}
+
+// handleSave writes a file, creating it if it doesn't exist
+func (f *fileManager) handleSave(w http.ResponseWriter, r *http.Request) {
+	f.writeFile(w, r, false)
+}
+
+func (f *fileManager) writeFile(w http.ResponseWriter, r *http.Request, create bool) {
+	userID, root, ok := f.root(w, r)
+	if !ok {
+		return
+	}
+	var req SaveFileRequest
+	if !decodeFileRequest(w, r, &req) {
+		return
+	}
+	path, err := resolve(root, req.Path)
+	if err != nil || path == root {
+		writeFileError(w, "save file", errInvalidPath)
+		return
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		http.Error(w, "Path is a directory", http.StatusConflict)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		writeFileError(w, "create directory", err)
+		return
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if create {
+		flags |= os.O_EXCL
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		writeFileError(w, "save file", err)
+		return
+	}
+	_, err = file.WriteString(req.Content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		writeFileError(w, "save file", err)
+		return
+	}
+
+	info, err := fileInfo(root, path)
+	if err != nil {
+		writeFileError(w, "save file", err)
+		return
+	}
+	slog.Info("Saved file", "user_id", userID, "path", info.Path, "size", info.Size, "action", "code_file_save")
+	status := http.StatusOK
+	if create {
+		status = http.StatusCreated
+	}
+	writeFileInfo(w, status, info)
+}
+
+// handleRename renames a file or directory within its directory
+func (f *fileManager) handleRename(w http.ResponseWriter, r *http.Request) {
+	userID, root, ok := f.root(w, r)
+	if !ok {
+		return
+	}
+	var req RenameFileRequest
+	if !decodeFileRequest(w, r, &req) {
+		return
+	}
+	if req.Name == "" || req.Name == "." || req.Name == ".." || strings.ContainsAny(req.Name, `/\`) {
+		http.Error(w, "Invalid name", http.StatusBadRequest)
+		return
+	}
+	dir := filepath.ToSlash(filepath.Dir(filepath.FromSlash(strings.Trim(req.Path, "/"))))
+	f.moveFile(w, userID, root, req.Path, dir+"/"+req.Name)
+}
+
+// handleMove moves a file or directory to another path, creating the
+// directories it's moved into
+func (f *fileManager) handleMove(w http.ResponseWriter, r *http.Request) {
+	userID, root, ok := f.root(w, r)
+	if !ok {
+		return
+	}
+	var req MoveFileRequest
+	if !decodeFileRequest(w, r, &req) {
+		return
+	}
+	f.moveFile(w, userID, root, req.Path, req.To)
+}
+
+func (f *fileManager) moveFile(w http.ResponseWriter, userID, root, from, to string) {
+	fromPath, err := resolve(root, from)
+	if err != nil || fromPath == root {
+		writeFileError(w, "move file", errInvalidPath)
+		return
+	}
+	toPath, err := resolve(root, to)
+	if err != nil || toPath == root {
+		writeFileError(w, "move file", errInvalidPath)
+		return
+	}
+	if fromPath == toPath {
+		// Renaming a file to its own name leaves it where it is
+		info, err := fileInfo(root, fromPath)
+		if err != nil {
+			writeFileError(w, "move file", err)
+			return
+		}
+		writeFileInfo(w, http.StatusOK, info)
+		return
+	}
+	if within(fromPath, toPath) {
+		http.Error(w, "Cannot move a directory into itself", http.StatusBadRequest)
+		return
+	}
+	if _, err := os.Lstat(fromPath); err != nil {
+		writeFileError(w, "move file", err)
+		return
+	}
+	if _, err := os.Lstat(toPath); err == nil {
+		writeFileError(w, "move file", os.ErrExist)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(toPath), 0755); err != nil {
+		writeFileError(w, "create directory", err)
+		return
+	}
+	if err := os.Rename(fromPath, toPath); err != nil {
+		writeFileError(w, "move file", err)
+		return
+	}
+
+	info, err := fileInfo(root, toPath)
+	if err != nil {
+		writeFileError(w, "move file", err)
+		return
+	}
+	slog.Info("Moved file", "user_id", userID, "from", strings.Trim(from, "/"), "to", info.Path, "action", "code_file_move")
+	writeFileInfo(w, http.StatusOK, info)
+}
+
+// handleDelete deletes a file, or a directory when it's empty or
+// ?recursive=true
+func (f *fileManager) handleDelete(w http.ResponseWriter, r *http.Request) {
+	userID, root, ok := f.root(w, r)
+	if !ok {
+		return
+	}
+	relPath := r.URL.Query().Get("path")
+	path, err := resolve(root, relPath)
+	if err != nil || path == root {
+		writeFileError(w, "delete file", errInvalidPath)
+		return
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		writeFileError(w, "delete file", err)
+		return
+	}
+
+	if info.IsDir() && r.URL.Query().Get("recursive") == "true" {
+		err = os.RemoveAll(path)
+	} else if err = os.Remove(path); err != nil && info.IsDir() {
+		http.Error(w, "Directory is not empty", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeFileError(w, "delete file", err)
+		return
+	}
+
+	slog.Info("Deleted file", "user_id", userID, "path", strings.Trim(relPath, "/"), "action", "code_file_delete")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,178 @@
+package code
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testFileManager returns a fileManager for dir where requests are made by
+// the user in their X-User-ID header, and "admin" is an admin
+func testFileManager(dir string) *fileManager {
+	return &fileManager{
+		dir: dir,
+		userID: func(r *http.Request) (string, error) {
+			if userID := r.Header.Get("X-User-ID"); userID != "" {
+				return userID, nil
+			}
+			return "", errors.New("not signed in")
+		},
+		isAdmin: func(userID string) bool { return userID == "admin" },
+	}
+}
+
+// newTestFiles serves the file API of a testFileManager for dir
+func newTestFiles(t *testing.T, dir string) *httptest.Server {
+	t.Helper()
+	files := testFileManager(dir)
+	m := http.NewServeMux()
+	m.HandleFunc("GET /files", files.handleList)
+	m.HandleFunc("GET /files/content", files.handleRead)
+	m.HandleFunc("POST /files", files.handleCreate)
+	m.HandleFunc("PUT /files", files.handleSave)
+	m.HandleFunc("POST /files/rename", files.handleRename)
+	m.HandleFunc("POST /files/move", files.handleMove)
+	m.HandleFunc("DELETE /files", files.handleDelete)
+	server := httptest.NewServer(m)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// fileRequest makes a request to the file API as userID, returning its
+// status and body
+func fileRequest(t *testing.T, server *httptest.Server, userID, method, path, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != "" {
+		req.Header.Set("X-User-ID", userID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+func TestFileManagement(t *testing.T) {
+	dir := t.TempDir()
+	server := newTestFiles(t, dir)
+
+	if status, body := fileRequest(t, server, "alice", "POST", "/files", `{"path": "app/App.tsx", "content": "export default 1;"}`); status != http.StatusCreated {
+		t.Fatalf("create = %d %s; want 201", status, body)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "users", "alice", "app", "App.tsx")); err != nil {
+		t.Errorf("file wasn't created in alice's root: %v", err)
+	}
+	if status, _ := fileRequest(t, server, "alice", "POST", "/files", `{"path": "app/App.tsx", "content": ""}`); status != http.StatusConflict {
+		t.Errorf("creating an existing file = %d; want 409", status)
+	}
+	if status, body := fileRequest(t, server, "alice", "PUT", "/files", `{"path": "app/App.tsx", "content": "export default 2;"}`); status != http.StatusOK {
+		t.Errorf("save = %d %s; want 200", status, body)
+	}
+	if status, body := fileRequest(t, server, "alice", "GET", "/files/content?path=app/App.tsx", ""); status != http.StatusOK || body != "export default 2;" {
+		t.Errorf("read = %d %q; want the saved content", status, body)
+	}
+
+	if status, body := fileRequest(t, server, "alice", "POST", "/files/rename", `{"path": "app/App.tsx", "name": "Main.tsx"}`); status != http.StatusOK || !strings.Contains(body, `"path":"app/Main.tsx"`) {
+		t.Errorf("rename = %d %s; want app/Main.tsx", status, body)
+	}
+	if status, body := fileRequest(t, server, "alice", "POST", "/files/rename", `{"path": "app/Main.tsx", "name": "Main.tsx"}`); status != http.StatusOK || !strings.Contains(body, `"path":"app/Main.tsx"`) {
+		t.Errorf("rename to the same name = %d %s; want app/Main.tsx unchanged", status, body)
+	}
+	if status, body := fileRequest(t, server, "alice", "POST", "/files/move", `{"path": "app", "to": "app"}`); status != http.StatusOK {
+		t.Errorf("moving a directory to itself = %d %s; want 200", status, body)
+	}
+	if status, _ := fileRequest(t, server, "alice", "POST", "/files/move", `{"path": "missing.txt", "to": "missing.txt"}`); status != http.StatusNotFound {
+		t.Errorf("moving a missing file to itself = %d; want 404", status)
+	}
+	if status, body := fileRequest(t, server, "alice", "POST", "/files/move", `{"path": "app", "to": "apps/todo"}`); status != http.StatusOK {
+		t.Errorf("move = %d %s; want 200", status, body)
+	}
+
+	status, body := fileRequest(t, server, "alice", "GET", "/files?depth=2", "")
+	var files []FileInfo
+	if err := json.Unmarshal([]byte(body), &files); status != http.StatusOK || err != nil {
+		t.Fatalf("list = %d %s; want files", status, body)
+	}
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	if got := strings.Join(paths, ","); got != "apps,apps/todo,apps/todo/Main.tsx" {
+		t.Errorf("listing = %s; want the moved file", got)
+	}
+
+	if status, _ := fileRequest(t, server, "alice", "DELETE", "/files?path=apps", ""); status != http.StatusConflict {
+		t.Errorf("deleting a directory that isn't empty = %d; want 409", status)
+	}
+	if status, _ := fileRequest(t, server, "alice", "DELETE", "/files?path=apps&recursive=true", ""); status != http.StatusNoContent {
+		t.Errorf("recursive delete = %d; want 204", status)
+	}
+	if status, _ := fileRequest(t, server, "alice", "GET", "/files/content?path=apps/todo/Main.tsx", ""); status != http.StatusNotFound {
+		t.Errorf("reading a deleted file = %d; want 404", status)
+	}
+}
+
+func TestFileManagementStaysInTheUsersRoot(t *testing.T) {
+	dir := t.TempDir()
+	server := newTestFiles(t, dir)
+	if err := os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fileRequest(t, server, "bob", "PUT", "/files", `{"path": "notes.txt", "content": "bob's notes"}`)
+	if err := os.Symlink(dir, filepath.Join(dir, "users", "bob", "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, method, path, body string
+		want                     int
+	}{
+		{"unauthenticated", "GET", "/files", "", http.StatusUnauthorized},
+		{"parent directory", "GET", "/files/content?path=../../secret.txt", "", http.StatusBadRequest},
+		{"another user's file", "GET", "/files/content?path=../bob/notes.txt", "", http.StatusBadRequest},
+		{"write outside", "PUT", "/files", `{"path": "../../evil.txt", "content": "x"}`, http.StatusBadRequest},
+		{"move outside", "POST", "/files/move", `{"path": "a.txt", "to": "../../a.txt"}`, http.StatusBadRequest},
+		{"rename with a path", "POST", "/files/rename", `{"path": "a.txt", "name": "../a.txt"}`, http.StatusBadRequest},
+		{"delete the root", "DELETE", "/files?path=", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		userID := "alice"
+		if tt.name == "unauthenticated" {
+			userID = ""
+		}
+		if status, body := fileRequest(t, server, userID, tt.method, tt.path, tt.body); status != tt.want {
+			t.Errorf("%s: %s %s = %d %s; want %d", tt.name, tt.method, tt.path, status, body, tt.want)
+		}
+	}
+
+	// A symlink out of the root can't be followed
+	if status, _ := fileRequest(t, server, "bob", "GET", "/files/content?path=escape/secret.txt", ""); status != http.StatusBadRequest {
+		t.Errorf("reading through a symlink out of the root = %d; want 400", status)
+	}
+	if status, _ := fileRequest(t, server, "bob", "PUT", "/files", `{"path": "escape/new.txt", "content": "x"}`); status != http.StatusBadRequest {
+		t.Errorf("writing through a symlink out of the root = %d; want 400", status)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.txt")); err == nil {
+		t.Error("a file was written through the symlink")
+	}
+
+	// Admins work in the whole share directory
+	if status, body := fileRequest(t, server, "admin", "GET", "/files/content?path=users/bob/notes.txt", ""); status != http.StatusOK || body != "bob's notes" {
+		t.Errorf("admin read = %d %q; want bob's notes", status, body)
+	}
+}
@@ -0,0 +1,291 @@
+package code
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/deps"
+)
+
+// maxGoSource is the largest Go snippet /go/run builds
+const maxGoSource = 256 << 10
+
+// maxGoOutput is the most output of a snippet's build and run that's kept
+const maxGoOutput = 1 << 20
+
+// goModule is the go.mod of the module a snippet is built in. Only the
+// standard library is available, since nothing is required.
+const goModule = "module snippet\n\ngo 1.21\n"
+
+// goEnvPassthrough are the server's environment variables the go command
+// keeps; the rest, secrets included, aren't passed to a snippet
+var goEnvPassthrough = []string{"PATH", "HOME", "XDG_CACHE_HOME", "GOROOT", "GOPATH", "GOCACHE", "GOMODCACHE"}
+
+// The output streams of a Go snippet
+const (
+	GoStreamBuild  = "build" // The compiler's output
+	GoStreamStdout = "stdout"
+	GoStreamStderr = "stderr"
+)
+
+// GoRunRequest is a Go program to build and run
+type GoRunRequest struct {
+	Source string   `json:"source"` // The main package, in one file
+	Stdin  string   `json:"stdin"`
+	Args   []string `json:"args"`
+}
+
+// GoRunResult is how a Go snippet's build and run ended
+type GoRunResult struct {
+	ExitCode    int    `json:"exitCode"`
+	BuildFailed bool   `json:"buildFailed,omitempty"`
+	TimedOut    bool   `json:"timedOut,omitempty"`
+	Truncated   bool   `json:"truncated,omitempty"` // Whether output past maxGoOutput was dropped
+	DurationMS  int64  `json:"durationMs"`
+	Error       string `json:"error,omitempty"` // Why the snippet couldn't be built or run
+}
+
+// goOutput is a snippet's output to the stream it's written to
+type goOutput struct {
+	Data string `json:"data"`
+}
+
+// RunGo builds source as the main package of a throwaway module and runs
+// it with the configured timeout, without network access unless the config
+// allows it. Output is passed to output, one call at a time, as it's written.
+func RunGo(ctx context.Context, cfg config.CodeConfig, req GoRunRequest, output func(stream string, data []byte)) GoRunResult {
+	start := time.Now()
+	timeout := cfg.GoTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	out := &goOutputLimit{output: output, remaining: maxGoOutput}
+	result := runGo(ctx, cfg, req, out)
+	result.Truncated = out.truncated
+	result.DurationMS = time.Since(start).Milliseconds()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.TimedOut = true
+		result.Error = fmt.Sprintf("timed out after %s", timeout)
+	}
+	return result
+}
+
+func runGo(ctx context.Context, cfg config.CodeConfig, req GoRunRequest, out *goOutputLimit) GoRunResult {
+	dir, err := os.MkdirTemp("", "flow-go-*")
+	if err != nil {
+		return GoRunResult{ExitCode: -1, Error: fmt.Sprintf("failed to create module: %v", err)}
+	}
+	defer os.RemoveAll(dir)
+	// The sandbox user runs the binary from here
+	if err := os.Chmod(dir, 0755); err != nil {
+		return GoRunResult{ExitCode: -1, Error: fmt.Sprintf("failed to create module: %v", err)}
+	}
+	// go ignores a go.mod in its temporary directory, so the build and the
+	// snippet are given their own
+	tmpDir := filepath.Join(dir, "tmp")
+	if err := os.Mkdir(tmpDir, 0777); err != nil {
+		return GoRunResult{ExitCode: -1, Error: fmt.Sprintf("failed to create module: %v", err)}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goModule), 0644); err != nil {
+		return GoRunResult{ExitCode: -1, Error: fmt.Sprintf("failed to write go.mod: %v", err)}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(req.Source), 0644); err != nil {
+		return GoRunResult{ExitCode: -1, Error: fmt.Sprintf("failed to write main.go: %v", err)}
+	}
+
+	goCommand := cfg.GoCommand
+	if goCommand == "" {
+		goCommand = "go"
+	}
+	build := exec.CommandContext(ctx, goCommand, "build", "-o", "snippet", ".")
+	build.Dir = dir
+	build.Env = goEnv(tmpDir, cfg.GoNetwork)
+	build.Stdout = out.stream(GoStreamBuild)
+	build.Stderr = build.Stdout
+	build.WaitDelay = time.Second
+	killGroupOnCancel(build)
+	if err := build.Run(); err != nil {
+		return goExit(err, true)
+	}
+
+	argv := append(goRunArgv(cfg), filepath.Join(dir, "snippet"))
+	run := exec.CommandContext(ctx, argv[0], append(argv[1:], req.Args...)...)
+	run.Dir = dir
+	run.Env = []string{"HOME=" + tmpDir, "TMPDIR=" + tmpDir}
+	run.Stdin = strings.NewReader(req.Stdin)
+	run.Stdout = out.stream(GoStreamStdout)
+	run.Stderr = out.stream(GoStreamStderr)
+	run.WaitDelay = time.Second
+	killGroupOnCancel(run)
+	if err := run.Run(); err != nil {
+		return goExit(err, false)
+	}
+	return GoRunResult{}
+}
+
+// killGroupOnCancel starts cmd in a process group of its own and kills the
+// whole group when its context is done. Killing only cmd would leave behind
+// what it started, such as the snippet runuser and unshare fork.
+func killGroupOnCancel(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// goRunArgv returns the command line a snippet's binary is appended to: as
+// the sandbox user, if there is one, and in a network namespace of its own
+// unless it may use the network
+func goRunArgv(cfg config.CodeConfig) []string {
+	var argv []string
+	if cfg.GoUser != "" {
+		argv = append(argv, "runuser", "-u", cfg.GoUser, "--")
+	}
+	if !cfg.GoNetwork {
+		argv = append(argv, "unshare", "--net", "--map-root-user", "--")
+	}
+	return argv
+}
+
+// goEnv returns the environment the go command builds a snippet in
+func goEnv(tmpDir string, network bool) []string {
+	var env []string
+	for _, name := range goEnvPassthrough {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	env = append(env, "TMPDIR="+tmpDir, "GOTOOLCHAIN=local", "CGO_ENABLED=0", "GOFLAGS=-mod=mod")
+	if !network {
+		env = append(env, "GOPROXY=off")
+	}
+	return env
+}
+
+// goExit returns the result of a build or run that failed with err
+func goExit(err error, building bool) GoRunResult {
+	result := GoRunResult{ExitCode: -1, BuildFailed: building}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode()
+	} else {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// goOutputLimit passes a snippet's output on, one write at a time, until
+// maxGoOutput bytes have been written
+type goOutputLimit struct {
+	mu        sync.Mutex
+	output    func(stream string, data []byte)
+	remaining int
+	truncated bool
+}
+
+func (l *goOutputLimit) stream(name string) io.Writer {
+	return goOutputStream{limit: l, name: name}
+}
+
+type goOutputStream struct {
+	limit *goOutputLimit
+	name  string
+}
+
+func (s goOutputStream) Write(p []byte) (int, error) {
+	l := s.limit
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	data := p
+	if len(data) > l.remaining {
+		data = data[:l.remaining]
+		l.truncated = true
+	}
+	if len(data) > 0 {
+		l.remaining -= len(data)
+		l.output(s.name, data)
+	}
+	return len(p), nil
+}
+
+// goRunner serves /go/run to signed-in users. Without a sandbox user
+// snippets run as the server's user, so only admins may run them.
+type goRunner struct {
+	cfg     config.CodeConfig
+	userID  func(r *http.Request) (string, error)
+	isAdmin func(userID string) bool
+}
+
+func newGoRunner(d deps.Deps) *goRunner {
+	return &goRunner{cfg: d.Config.Code, userID: sessionUserID(d), isAdmin: d.Config.IsAdmin}
+}
+
+// handleRun builds and runs a Go snippet, streaming its build output, stdout,
+// and stderr as server-sent events named after them, then an exit event
+// with its GoRunResult
+func (g *goRunner) handleRun(w http.ResponseWriter, r *http.Request) {
+	userID, err := g.userID(r)
+	if err != nil || userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if g.cfg.GoUser == "" && !g.isAdmin(userID) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var req GoRunRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGoSource+64<<10)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Source) == "" {
+		http.Error(w, "Source is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Source) > maxGoSource {
+		http.Error(w, "Source is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// The server's write timeout would otherwise cut off a long run
+	rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	send := func(event string, data any) {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+		rc.Flush()
+	}
+
+	// A client that goes away stops the run
+	result := RunGo(r.Context(), g.cfg, req, func(stream string, data []byte) {
+		send(stream, goOutput{Data: string(data)})
+	})
+	send("exit", result)
+
+	slog.Info("Ran Go snippet", "user_id", userID, "exit_code", result.ExitCode, "build_failed", result.BuildFailed,
+		"timed_out", result.TimedOut, "duration_ms", result.DurationMS, "action", "code_go_run")
+}
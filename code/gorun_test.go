@@ -0,0 +1,173 @@
+package code
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+)
+
+// runGoSnippet runs source, returning its output by stream
+func runGoSnippet(t *testing.T, cfg config.CodeConfig, req GoRunRequest) (GoRunResult, map[string]string) {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go isn't installed")
+	}
+	output := make(map[string]string)
+	result := RunGo(context.Background(), cfg, req, func(stream string, data []byte) {
+		output[stream] += string(data)
+	})
+	return result, output
+}
+
+func TestRunGo(t *testing.T) {
+	source := `package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	input, _ := io.ReadAll(os.Stdin)
+	fmt.Printf("hello %s from %s\n", input, os.Args[1])
+	fmt.Fprintln(os.Stderr, "done")
+	os.Exit(3)
+}
+`
+	result, output := runGoSnippet(t, config.CodeConfig{GoNetwork: true}, GoRunRequest{Source: source, Stdin: "stdin", Args: []string{"args"}})
+	if result.ExitCode != 3 || result.BuildFailed || result.Error != "" {
+		t.Errorf("result = %+v; want exit code 3", result)
+	}
+	if output[GoStreamStdout] != "hello stdin from args\n" || output[GoStreamStderr] != "done\n" {
+		t.Errorf("output = %q", output)
+	}
+}
+
+func TestRunGoReportsBuildErrors(t *testing.T) {
+	result, output := runGoSnippet(t, config.CodeConfig{GoNetwork: true}, GoRunRequest{Source: "package main\n\nfunc main() { undefinedFunc() }\n"})
+	if !result.BuildFailed || result.ExitCode == 0 {
+		t.Errorf("result = %+v; want a failed build", result)
+	}
+	if !strings.Contains(output[GoStreamBuild], "undefined: undefinedFunc") {
+		t.Errorf("build output = %q; want the compiler's error", output[GoStreamBuild])
+	}
+}
+
+func TestRunGoTimesOut(t *testing.T) {
+	source := "package main\n\nimport \"time\"\n\nfunc main() { time.Sleep(time.Hour) }\n"
+	start := time.Now()
+	result, _ := runGoSnippet(t, config.CodeConfig{GoNetwork: true, GoTimeout: 5 * time.Second}, GoRunRequest{Source: source})
+	if !result.TimedOut || result.ExitCode == 0 {
+		t.Errorf("result = %+v; want a timeout", result)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("run took %s after timing out", elapsed)
+	}
+}
+
+func TestKillGroupOnCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	// The shell stands in for runuser: it forks the process that keeps running
+	cmd := exec.CommandContext(ctx, "sh", "-c", "sleep 30 & echo $!; wait")
+	var out strings.Builder
+	cmd.Stdout = &out
+	cmd.WaitDelay = time.Second
+	killGroupOnCancel(cmd)
+	if err := cmd.Run(); err == nil {
+		t.Fatal("Run() succeeded; want it killed at the timeout")
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(out.String()))
+	if err != nil {
+		t.Fatalf("failed to read the child's pid from %q: %v", out.String(), err)
+	}
+	// The child is killed, though it may be left a zombie until it's reaped
+	deadline := time.Now().Add(time.Second)
+	for running(pid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("process %d the command started outlived the timeout", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// running reports whether the process is alive and not a zombie
+func running(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return syscall.Kill(pid, 0) == nil
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestRunGoHasNoNetwork(t *testing.T) {
+	if err := exec.Command("unshare", "--net", "--map-root-user", "--", "true").Run(); err != nil {
+		t.Skipf("network namespaces aren't available: %v", err)
+	}
+	source := `package main
+
+import (
+	"fmt"
+	"net"
+)
+
+func main() {
+	interfaces, _ := net.Interfaces()
+	for _, iface := range interfaces {
+		fmt.Println(iface.Name)
+	}
+}
+`
+	result, output := runGoSnippet(t, config.CodeConfig{}, GoRunRequest{Source: source})
+	if result.ExitCode != 0 || output[GoStreamStdout] != "lo\n" {
+		t.Errorf("result = %+v, output = %q; want only the loopback interface", result, output)
+	}
+}
+
+func TestGoRunRequiresAnAdminWithoutASandboxUser(t *testing.T) {
+	runner := &goRunner{cfg: config.CodeConfig{GoNetwork: true}, userID: testFileManager("").userID, isAdmin: func(userID string) bool { return userID == "admin" }}
+	body := `{"source": "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"hi\") }\n"}`
+
+	tests := []struct {
+		userID string
+		want   int
+	}{
+		{"", http.StatusUnauthorized},
+		{"alice", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/go/run", strings.NewReader(body))
+		if tt.userID != "" {
+			req.Header.Set("X-User-ID", tt.userID)
+		}
+		rec := httptest.NewRecorder()
+		runner.handleRun(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("run as %q = %d; want %d", tt.userID, rec.Code, tt.want)
+		}
+	}
+
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go isn't installed")
+	}
+	req := httptest.NewRequest("POST", "/go/run", strings.NewReader(body))
+	req.Header.Set("X-User-ID", "admin")
+	rec := httptest.NewRecorder()
+	runner.handleRun(rec, req)
+	if !strings.Contains(rec.Body.String(), "event: stdout\ndata: {\"data\":\"hi\\n\"}\n\n") || !strings.Contains(rec.Body.String(), "event: exit\ndata: {\"exitCode\":0,") {
+		t.Errorf("admin run = %d %s; want its stdout and exit events", rec.Code, rec.Body.String())
+	}
+}
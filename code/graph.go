@@ -0,0 +1,250 @@
+package code
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ImportGraph is what a component's module build imports: the files it read,
+// from its entry on, and the npm packages they use
+type ImportGraph struct {
+	Entry    string         `json:"entry"`
+	Files    []GraphFile    `json:"files"`    // The entry first, then by path
+	Packages []GraphPackage `json:"packages"` // By name
+}
+
+// GraphFile is a file in a component's build and what it imports
+type GraphFile struct {
+	Path       string   `json:"path"` // Relative to the server's working directory, as /render/ paths are
+	Bytes      int      `json:"bytes"`
+	Imports    []string `json:"imports"`    // Files it imports
+	Packages   []string `json:"packages"`   // Packages it imports
+	ImportedBy []string `json:"importedBy"` // Files that import it
+}
+
+// GraphPackage is an npm package a component uses, bundled from node_modules
+// or left external for the page to load
+type GraphPackage struct {
+	Name       string   `json:"name"`
+	External   bool     `json:"external"`
+	ImportedBy []string `json:"importedBy"` // Files that import it
+}
+
+// ImportGraphResponse is a component's import graph and, when a file in it
+// was asked about, every file that imports it directly or through others
+type ImportGraphResponse struct {
+	*ImportGraph
+	File       string   `json:"file,omitempty"`
+	Dependents []string `json:"dependents,omitempty"`
+}
+
+// buildImportGraph returns the import graph of a build of srcPath from its
+// metafile. Files in node_modules are counted as the packages they're in.
+func buildImportGraph(srcPath, metafile string) *ImportGraph {
+	var meta struct {
+		Inputs map[string]struct {
+			Bytes   int `json:"bytes"`
+			Imports []struct {
+				Path     string `json:"path"`
+				External bool   `json:"external"`
+			} `json:"imports"`
+		} `json:"inputs"`
+	}
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return nil
+	}
+
+	entry := filepath.ToSlash(filepath.Clean(srcPath))
+	graphPath := func(path string) string {
+		// The source is built from stdin
+		if path == "<stdin>" {
+			return entry
+		}
+		// esbuild's paths are relative to the working directory, so they're
+		// made absolute like the entry's when it is
+		if filepath.IsAbs(srcPath) {
+			if abs, err := filepath.Abs(path); err == nil {
+				return filepath.ToSlash(abs)
+			}
+		}
+		return path
+	}
+
+	files := make(map[string]*GraphFile)
+	packages := make(map[string]*GraphPackage)
+	for inputPath, input := range meta.Inputs {
+		path := graphPath(inputPath)
+		if nodeModulesPackage(path) != "" {
+			continue
+		}
+		file := fileNode(files, path)
+		file.Bytes = input.Bytes
+		for _, imp := range input.Imports {
+			var name string
+			switch {
+			case imp.External:
+				name, _ = splitPackageImport(imp.Path)
+				if !isPackageImport(imp.Path) {
+					name = imp.Path // Such as a URL
+				}
+			case nodeModulesPackage(imp.Path) != "":
+				name = nodeModulesPackage(imp.Path)
+			default:
+				imported := fileNode(files, graphPath(imp.Path))
+				file.Imports = appendUnique(file.Imports, imported.Path)
+				imported.ImportedBy = appendUnique(imported.ImportedBy, path)
+				continue
+			}
+			pkg, ok := packages[name]
+			if !ok {
+				pkg = &GraphPackage{Name: name, External: imp.External, ImportedBy: []string{}}
+				packages[name] = pkg
+			}
+			file.Packages = appendUnique(file.Packages, name)
+			pkg.ImportedBy = appendUnique(pkg.ImportedBy, path)
+		}
+	}
+
+	graph := &ImportGraph{Entry: entry, Files: []GraphFile{}, Packages: []GraphPackage{}}
+	for _, file := range files {
+		sort.Strings(file.Imports)
+		sort.Strings(file.Packages)
+		sort.Strings(file.ImportedBy)
+		graph.Files = append(graph.Files, *file)
+	}
+	sort.Slice(graph.Files, func(i, j int) bool {
+		a, b := graph.Files[i].Path, graph.Files[j].Path
+		if (a == entry) != (b == entry) {
+			return a == entry
+		}
+		return a < b
+	})
+	for _, pkg := range packages {
+		sort.Strings(pkg.ImportedBy)
+		graph.Packages = append(graph.Packages, *pkg)
+	}
+	sort.Slice(graph.Packages, func(i, j int) bool { return graph.Packages[i].Name < graph.Packages[j].Name })
+	return graph
+}
+
+// Dependents returns the files that import path, directly or through other
+// files, sorted; these are what an edit to it can break
+func (g *ImportGraph) Dependents(path string) []string {
+	importedBy := make(map[string][]string, len(g.Files))
+	for _, file := range g.Files {
+		importedBy[file.Path] = file.ImportedBy
+	}
+	seen := map[string]bool{path: true}
+	queue := []string{path}
+	dependents := []string{}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, importer := range importedBy[next] {
+			if !seen[importer] {
+				seen[importer] = true
+				dependents = append(dependents, importer)
+				queue = append(queue, importer)
+			}
+		}
+	}
+	sort.Strings(dependents)
+	return dependents
+}
+
+// fileNode returns the graph's file at path, adding it if it isn't there
+func fileNode(files map[string]*GraphFile, path string) *GraphFile {
+	file, ok := files[path]
+	if !ok {
+		file = &GraphFile{Path: path, Imports: []string{}, Packages: []string{}, ImportedBy: []string{}}
+		files[path] = file
+	}
+	return file
+}
+
+func appendUnique(values []string, value string) []string {
+	if slices.Contains(values, value) {
+		return values
+	}
+	return append(values, value)
+}
+
+// nodeModulesPackage returns the package a file in node_modules is part of,
+// or "" for files outside node_modules
+func nodeModulesPackage(path string) string {
+	i := strings.LastIndex(path, "node_modules/")
+	if i < 0 {
+		return ""
+	}
+	name, _ := splitPackageImport(path[i+len("node_modules/"):])
+	return name
+}
+
+// handleImportGraph returns the import graph of a component's module build.
+// With ?file=, it also lists the files in it that import that file.
+func handleImportGraph(builds *buildCache, w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract path from URL
+	componentPath := strings.TrimPrefix(r.URL.Path, "/graph/")
+	if componentPath == "" {
+		http.Error(w, "Component path is required", http.StatusBadRequest)
+		return
+	}
+
+	// Validate and sanitize the path
+	cleanPath := filepath.Clean(componentPath)
+	if strings.Contains(cleanPath, "..") {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+	srcPath := filepath.Join("./", cleanPath)
+
+	sourceCode, err := os.ReadFile(srcPath)
+	if os.IsNotExist(err) {
+		http.Error(w, "Source file not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read source file: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	result := builds.Build("module", srcPath, sourceCode, moduleBuildOptions(srcPath, sourceCode), r.URL.Query().Has(rebuildParam))
+	// Builds cached before graphs were kept don't have one
+	if result.Graph == nil && len(result.Errors) == 0 {
+		result = builds.Build("module", srcPath, sourceCode, moduleBuildOptions(srcPath, sourceCode), true)
+	}
+	if result.Overloaded {
+		writeBuildOverloaded(w, result)
+		return
+	}
+	if len(result.Errors) > 0 {
+		errorMessages := make([]string, len(result.Errors))
+		for i, err := range result.Errors {
+			errorMessages[i] = buildErrorMessage(err)
+		}
+		http.Error(w, "Build failed:\n"+strings.Join(errorMessages, "\n"), http.StatusBadRequest)
+		return
+	}
+	if result.Graph == nil {
+		http.Error(w, "No import graph generated from build", http.StatusInternalServerError)
+		return
+	}
+
+	response := ImportGraphResponse{ImportGraph: result.Graph}
+	if file := r.URL.Query().Get("file"); file != "" {
+		response.File = filepath.ToSlash(filepath.Clean(file))
+		response.Dependents = result.Graph.Dependents(response.File)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,122 @@
+package code
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/breadchris/flow/config"
+)
+
+// writeGraphModule writes App.tsx, which imports Button.tsx and theme.ts,
+// Button.tsx importing theme.ts too, along with React and a package in
+// node_modules. Modules are served relative to the working directory, so
+// it's written under it.
+func writeGraphModule(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp(".", "graph")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	dir = filepath.Clean(dir)
+
+	writeFiles(t, dir, map[string]string{
+		"App.tsx":                             "import { Button } from \"./Button\";\nimport { color } from \"./theme\";\n\nexport default function App() {\n  return <Button color={color} />;\n}\n",
+		"Button.tsx":                          "import { useState } from \"react\";\nimport pad from \"@acme/pad\";\nimport { color } from \"./theme\";\n\nexport function Button(props: { color: string }) {\n  const [n] = useState(0);\n  return <button style={{ color }}>{pad(String(n))}</button>;\n}\n",
+		"theme.ts":                            "export const color = \"red\";\n",
+		"node_modules/@acme/pad/package.json": `{"name": "@acme/pad", "main": "index.js"}`,
+		"node_modules/@acme/pad/index.js":     "module.exports = function pad(s) { return ' ' + s; };\n",
+	})
+	return dir
+}
+
+func TestImportGraph(t *testing.T) {
+	dir := writeGraphModule(t)
+	builds := newBuildCache(config.CodeConfig{})
+	app, button, theme := dir+"/App.tsx", dir+"/Button.tsx", dir+"/theme.ts"
+
+	w := httptest.NewRecorder()
+	handleImportGraph(builds, w, httptest.NewRequest("GET", "/graph/"+app+"?file="+theme, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d %s; want 200", w.Code, w.Body.String())
+	}
+	var graph ImportGraphResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &graph); err != nil {
+		t.Fatal(err)
+	}
+
+	if graph.Entry != app {
+		t.Errorf("entry = %q; want %q", graph.Entry, app)
+	}
+	files := make(map[string]GraphFile)
+	var order []string
+	for _, file := range graph.Files {
+		files[file.Path] = file
+		order = append(order, file.Path)
+	}
+	if want := []string{app, button, theme}; !reflect.DeepEqual(order, want) {
+		t.Errorf("files = %q; want %q", order, want)
+	}
+	if got := files[app].Imports; !reflect.DeepEqual(got, []string{button, theme}) {
+		t.Errorf("App.tsx imports %q; want Button.tsx and theme.ts", got)
+	}
+	if got := files[theme].ImportedBy; !reflect.DeepEqual(got, []string{app, button}) {
+		t.Errorf("theme.ts is imported by %q; want App.tsx and Button.tsx", got)
+	}
+	if got := files[button].Packages; !reflect.DeepEqual(got, []string{"@acme/pad", "react"}) {
+		t.Errorf("Button.tsx uses packages %q; want @acme/pad and react", got)
+	}
+	if files[theme].Bytes == 0 {
+		t.Error("theme.ts has no size")
+	}
+
+	wantPackages := []GraphPackage{
+		{Name: "@acme/pad", External: false, ImportedBy: []string{button}},
+		{Name: "react", External: true, ImportedBy: []string{app, button}}, // App.tsx's JSX imports react/jsx-runtime
+	}
+	if !reflect.DeepEqual(graph.Packages, wantPackages) {
+		t.Errorf("packages = %+v; want %+v", graph.Packages, wantPackages)
+	}
+	if graph.File != theme || !reflect.DeepEqual(graph.Dependents, []string{app, button}) {
+		t.Errorf("dependents of %q = %q; want App.tsx and Button.tsx", graph.File, graph.Dependents)
+	}
+
+	// A cached build has the graph too
+	source, err := os.ReadFile(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached := builds.Build("module", app, source, moduleBuildOptions(app, source), false)
+	if !cached.Cached || cached.Graph == nil || len(cached.Graph.Files) != 3 {
+		t.Errorf("cached build graph = %+v; want the graph of the first build", cached.Graph)
+	}
+}
+
+func TestImportGraphDependentsAreTransitive(t *testing.T) {
+	graph := &ImportGraph{Files: []GraphFile{
+		{Path: "App.tsx"},
+		{Path: "Page.tsx", ImportedBy: []string{"App.tsx"}},
+		{Path: "Card.tsx", ImportedBy: []string{"Page.tsx", "Sidebar.tsx"}},
+		{Path: "Sidebar.tsx", ImportedBy: []string{"App.tsx"}},
+		{Path: "util.ts", ImportedBy: []string{"Card.tsx"}},
+	}}
+	if got, want := graph.Dependents("util.ts"), []string{"App.tsx", "Card.tsx", "Page.tsx", "Sidebar.tsx"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Dependents(util.ts) = %q; want %q", got, want)
+	}
+	if got := graph.Dependents("App.tsx"); len(got) != 0 {
+		t.Errorf("Dependents(App.tsx) = %q; want none", got)
+	}
+}
+
+func TestImportGraphRejectsPathsOutsideTheWorkingDirectory(t *testing.T) {
+	w := httptest.NewRecorder()
+	handleImportGraph(newBuildCache(config.CodeConfig{}), w, httptest.NewRequest("GET", "/graph/../main.go", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d; want 400", w.Code)
+	}
+}
@@ -1,25 +1,37 @@
 package code
 
 import (
+	"encoding/json"
+	"strings"
+
 	"github.com/breadchris/flow/config"
 	. "github.com/breadchris/share/html"
 )
 
-// ReactImportMap returns a script tag with React import mappings
-func ReactImportMap(c config.AppConfig) *Node {
-	return Script(Type("importmap"), Raw(`
-    {
-        "imports": {
-            "react": "https://esm.sh/react@18",
-            "react-dom": "https://esm.sh/react-dom@18",
-            "react-dom/client": "https://esm.sh/react-dom@18/client",
-            "react/jsx-runtime": "https://esm.sh/react@18/jsx-runtime",
-			"supabase-kv": "`+c.ExternalURL+`/code/module/flow/supabase-kv.ts",
-			"@connectrpc/connect-web": "https://esm.sh/@connectrpc/connect-web",
-			"@connectrpc/connect": "https://esm.sh/@connectrpc/connect"
-        }
-    }
-    `))
+// ReactImportMap returns a script tag with React import mappings, plus the
+// npm packages a component loads from the CDN
+func ReactImportMap(c config.AppConfig, packages map[string]string) *Node {
+	imports := reactImports(c)
+	for specifier, url := range packages {
+		if _, ok := imports[specifier]; !ok {
+			imports[specifier] = url
+		}
+	}
+	importMap, _ := json.MarshalIndent(map[string]any{"imports": imports}, "    ", "    ")
+	return Script(Type("importmap"), Raw(string(importMap)))
+}
+
+// reactImports are the imports every component page maps
+func reactImports(c config.AppConfig) map[string]string {
+	return map[string]string{
+		"react":                   "https://esm.sh/react@18",
+		"react-dom":               "https://esm.sh/react-dom@18",
+		"react-dom/client":        "https://esm.sh/react-dom@18/client",
+		"react/jsx-runtime":       "https://esm.sh/react@18/jsx-runtime",
+		"supabase-kv":             c.ExternalURL + "/code/module/flow/supabase-kv.ts",
+		"@connectrpc/connect-web": "https://esm.sh/@connectrpc/connect-web",
+		"@connectrpc/connect":     "https://esm.sh/@connectrpc/connect",
+	}
 }
 
 // ComponentPageLayout creates the standard layout for component pages
@@ -76,51 +88,90 @@ func ComponentRuntimeStyles() *Node {
     `))
 }
 
+// ComponentStylesheet returns a style tag with a component's stylesheet, or
+// nothing when it has none
+func ComponentStylesheet(css string) *Node {
+	if strings.TrimSpace(css) == "" {
+		return Nil()
+	}
+	return Style(Raw(css))
+}
+
 // ComponentLoader creates the JavaScript module loader for a component
 func ComponentLoader(componentPath, componentName string, useModuleEndpoint bool) *Node {
 	var jsCode string
 
 	if useModuleEndpoint {
 		importPath := "/code/module/" + componentPath
+		reloadPath := "/code/reload/" + componentPath
 		jsCode = `
-        try {
-            // Import the compiled component module from the /module/ endpoint
-            const componentModule = await import('` + importPath + `');
-            
-            // Import React and ReactDOM
-            const React = await import('react');
-            const ReactDOM = await import('react-dom/client');
-            
-            // Try to get the component to render
-            let ComponentToRender;
-            
-            // First try the specified component name
-            if (componentModule.` + componentName + `) {
-                console.log('Rendering component:', componentModule.` + componentName + `);
-                ComponentToRender = componentModule.` + componentName + `;
-            }
-            // Then try default export
-            else if (componentModule.default) {
-                console.log('Rendering default component:', componentModule.default);
-                ComponentToRender = componentModule.default;
-            }
-            else {
-                throw new Error('No component found. Make sure to export a component named "` + componentName + `" or a default export.');
+        let root;
+
+        async function renderComponent(version) {
+            try {
+                // Import the compiled component module from the /module/ endpoint,
+                // under a new URL after a reload so the browser fetches it again
+                const componentModule = await import('` + importPath + `' + (version ? '?v=' + version : ''));
+                
+                // Import React and ReactDOM
+                const React = await import('react');
+                const ReactDOM = await import('react-dom/client');
+                
+                // Try to get the component to render
+                let ComponentToRender;
+                
+                // First try the specified component name
+                if (componentModule.` + componentName + `) {
+                    console.log('Rendering component:', componentModule.` + componentName + `);
+                    ComponentToRender = componentModule.` + componentName + `;
+                }
+                // Then try default export
+                else if (componentModule.default) {
+                    console.log('Rendering default component:', componentModule.default);
+                    ComponentToRender = componentModule.default;
+                }
+                else {
+                    throw new Error('No component found. Make sure to export a component named "` + componentName + `" or a default export.');
+                }
+                
+                // Render the component, replacing the last one on a reload
+                if (!root) {
+                    document.getElementById('root').innerHTML = '';
+                    root = ReactDOM.createRoot(document.getElementById('root'));
+                }
+                root.render(React.createElement(ComponentToRender));
+                
+            } catch (error) {
+                console.error('Runtime Error:', error);
+                if (root) {
+                    root.unmount();
+                    root = undefined;
+                }
+                document.getElementById('root').innerHTML = 
+                    '<div class="error">' +
+                    '<h3>Runtime Error:</h3>' +
+                    '<pre>' + error.message + '</pre>' +
+                    '<pre>' + (error.stack || '') + '</pre>' +
+                    '</div>';
             }
-            
-            // Render the component
-            const root = ReactDOM.createRoot(document.getElementById('root'));
-            root.render(React.createElement(ComponentToRender));
-            
-        } catch (error) {
-            console.error('Runtime Error:', error);
-            document.getElementById('root').innerHTML = 
-                '<div class="error">' +
-                '<h3>Runtime Error:</h3>' +
-                '<pre>' + error.message + '</pre>' +
-                '<pre>' + (error.stack || '') + '</pre>' +
-                '</div>';
-        }`
+        }
+
+        // Import the component again whenever it or a file it imports changes
+        function watchComponent() {
+            const protocol = location.protocol === 'https:' ? 'wss:' : 'ws:';
+            const socket = new WebSocket(protocol + '//' + location.host + '` + reloadPath + `');
+            socket.onmessage = (event) => {
+                const message = JSON.parse(event.data);
+                if (message.type === 'reload') {
+                    console.log('Reloading component, changed:', message.changed);
+                    renderComponent(Date.now());
+                }
+            };
+            socket.onclose = () => setTimeout(watchComponent, 2000);
+        }
+
+        await renderComponent();
+        watchComponent();`
 	}
 
 	return Script(Type("module"), Raw(jsCode))
@@ -227,13 +278,30 @@ func BuildErrorPage(componentPath string, errorMessages []string) *Node {
 	)
 }
 
+// TypeErrorPage creates a complete error page for components tsc found
+// errors in
+func TypeErrorPage(componentPath string, diagnostics []TypeDiagnostic) *Node {
+	errorMessages := make([]string, len(diagnostics))
+	for i, diagnostic := range diagnostics {
+		errorMessages[i] = diagnostic.String()
+	}
+	return ComponentPageLayout("Type Error",
+		ComponentErrorStyles(),
+		ErrorDisplay(
+			"Type Error",
+			"Type-checking "+componentPath+" failed",
+			errorMessages,
+		),
+	)
+}
+
 // ReactComponentPage creates a page that renders a React component
-func ReactComponentPage(c config.AppConfig, componentName string, additionalHeadNodes ...*Node) *Node {
+func ReactComponentPage(c config.AppConfig, componentName string, packages map[string]string, additionalHeadNodes ...*Node) *Node {
 	headNodes := []*Node{
 		Meta(Charset("UTF-8")),
 		Meta(Name("viewport"), Content("width=device-width, initial-scale=1.0")),
 		Title(T("React Component - " + componentName)),
-		ReactImportMap(c),
+		ReactImportMap(c, packages),
 		Link(Rel("stylesheet"), Type("text/css"), Href("https://cdn.jsdelivr.net/npm/daisyui@5")),
 		Script(Src("https://cdn.jsdelivr.net/npm/@tailwindcss/browser@4")),
 		ComponentRuntimeStyles(),
@@ -0,0 +1,144 @@
+package code
+
+import (
+	"errors"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/metrics"
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// buildRetryAfter is how many seconds a client turned away by a full build
+// queue is told to wait before trying again
+const buildRetryAfter = "1"
+
+var (
+	// errBuildQueueFull is returned when as many builds as the queue holds
+	// are already waiting for a worker
+	errBuildQueueFull = errors.New("too many builds are waiting; try again shortly")
+
+	// errBuildQueueTimeout is returned when a build waits longer than the
+	// queue timeout for a worker
+	errBuildQueueTimeout = errors.New("timed out waiting for a free build worker; try again shortly")
+)
+
+// buildQueue caps how many esbuild builds run at once. Builds over the cap
+// wait for a worker, up to a limit, and requests for a build that's already
+// running wait for it rather than building it again.
+type buildQueue struct {
+	workers    chan struct{} // Holds a value for each running build
+	maxWaiting int           // 0 is no limit
+	timeout    time.Duration // 0 waits as long as it takes
+
+	mu      sync.Mutex
+	waiting int
+	calls   map[string]*buildCall // Running builds by key
+}
+
+// buildCall is a build that's running, for the requests waiting on it
+type buildCall struct {
+	done   chan struct{} // Closed once result is set
+	result buildResult
+}
+
+func newBuildQueue(cfg config.CodeConfig) *buildQueue {
+	workers := cfg.BuildWorkers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	return &buildQueue{
+		workers:    make(chan struct{}, workers),
+		maxWaiting: max(cfg.BuildQueue, 0),
+		timeout:    max(cfg.BuildQueueTimeout, 0),
+		calls:      make(map[string]*buildCall),
+	}
+}
+
+// do runs build on a worker, unless a build of the same key is already
+// running, in which case it returns that build's result once it's done.
+// shared reports whether the result came from another request's build. A
+// build the queue turns away has Overloaded set.
+func (q *buildQueue) do(handler, key string, build func() buildResult) (result buildResult, shared bool) {
+	q.mu.Lock()
+	if call, ok := q.calls[key]; ok {
+		q.mu.Unlock()
+		<-call.done
+		return call.result, true
+	}
+	call := &buildCall{done: make(chan struct{})}
+	q.calls[key] = call
+	q.mu.Unlock()
+
+	defer func() {
+		q.mu.Lock()
+		delete(q.calls, key)
+		q.mu.Unlock()
+		close(call.done)
+	}()
+
+	release, err := q.acquire(handler)
+	if err != nil {
+		call.result = buildResult{Errors: []api.Message{{Text: err.Error()}}, Overloaded: true}
+		return call.result, false
+	}
+	defer release()
+	call.result = build()
+	return call.result, false
+}
+
+// acquire waits for a free worker and returns the function that frees it
+func (q *buildQueue) acquire(handler string) (func(), error) {
+	queuedAt := time.Now()
+	select {
+	case q.workers <- struct{}{}:
+		metrics.CodeBuildQueueWaitDuration.WithLabelValues(handler).Observe(0)
+		return q.release, nil
+	default:
+	}
+
+	q.mu.Lock()
+	if q.maxWaiting > 0 && q.waiting >= q.maxWaiting {
+		q.mu.Unlock()
+		metrics.CodeBuildsRejectedTotal.WithLabelValues(handler, "queue_full").Inc()
+		return nil, errBuildQueueFull
+	}
+	q.waiting++
+	metrics.CodeBuildQueueLength.Set(float64(q.waiting))
+	q.mu.Unlock()
+
+	defer func() {
+		q.mu.Lock()
+		q.waiting--
+		metrics.CodeBuildQueueLength.Set(float64(q.waiting))
+		q.mu.Unlock()
+		metrics.CodeBuildQueueWaitDuration.WithLabelValues(handler).Observe(time.Since(queuedAt).Seconds())
+	}()
+
+	var timeout <-chan time.Time
+	if q.timeout > 0 {
+		timer := time.NewTimer(q.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case q.workers <- struct{}{}:
+		return q.release, nil
+	case <-timeout:
+		metrics.CodeBuildsRejectedTotal.WithLabelValues(handler, "queue_timeout").Inc()
+		return nil, errBuildQueueTimeout
+	}
+}
+
+func (q *buildQueue) release() {
+	<-q.workers
+}
+
+// writeBuildOverloaded turns a request away because the build queue is full
+func writeBuildOverloaded(w http.ResponseWriter, result buildResult) {
+	w.Header().Set("Retry-After", buildRetryAfter)
+	http.Error(w, result.Errors[0].Text, http.StatusTooManyRequests)
+}
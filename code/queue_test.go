@@ -0,0 +1,145 @@
+package code
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+)
+
+// startBuild runs a build of key on q that blocks until release is closed,
+// returning once it has a worker
+func startBuild(t *testing.T, q *buildQueue, key string, release <-chan struct{}) <-chan buildResult {
+	t.Helper()
+	started := make(chan struct{})
+	done := make(chan buildResult, 1)
+	go func() {
+		result, _ := q.do("module", key, func() buildResult {
+			close(started)
+			<-release
+			return buildResult{Hash: key}
+		})
+		done <- result
+	}()
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("build didn't start")
+	}
+	return done
+}
+
+// waitWaiting blocks until n builds are waiting for a worker
+func waitWaiting(t *testing.T, q *buildQueue, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		q.mu.Lock()
+		waiting := q.waiting
+		q.mu.Unlock()
+		if waiting == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d builds waiting; want %d", waiting, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBuildQueueCoalescesRunningBuilds(t *testing.T) {
+	q := newBuildQueue(config.CodeConfig{BuildWorkers: 1})
+	var builds atomic.Int32
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	var wg sync.WaitGroup
+	var shared atomic.Int32
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, wasShared := q.do("module", "App.tsx", func() buildResult {
+				builds.Add(1)
+				close(started)
+				<-release
+				return buildResult{Hash: "abc"}
+			})
+			if result.Hash != "abc" {
+				t.Errorf("result hash = %q; want the build's", result.Hash)
+			}
+			if wasShared {
+				shared.Add(1)
+			}
+		}()
+	}
+	<-started
+	// Give the other requests time to find the running build
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if builds.Load() != 1 || shared.Load() != 4 {
+		t.Errorf("built %d times with %d shared results; want 1 build shared by 4", builds.Load(), shared.Load())
+	}
+	if result, wasShared := q.do("module", "App.tsx", func() buildResult { return buildResult{Hash: "def"} }); wasShared || result.Hash != "def" {
+		t.Error("a finished build was shared")
+	}
+}
+
+func TestBuildQueueTurnsAwayBuildsOverTheLimit(t *testing.T) {
+	q := newBuildQueue(config.CodeConfig{BuildWorkers: 1, BuildQueue: 1})
+	release := make(chan struct{})
+	running := startBuild(t, q, "a", release)
+
+	queued := make(chan buildResult, 1)
+	go func() {
+		result, _ := q.do("module", "b", func() buildResult { return buildResult{Hash: "b"} })
+		queued <- result
+	}()
+	waitWaiting(t, q, 1)
+
+	if result, _ := q.do("module", "c", func() buildResult { return buildResult{Hash: "c"} }); !result.Overloaded {
+		t.Errorf("build over the queue's limit = %+v; want it turned away", result)
+	}
+
+	close(release)
+	if result := <-running; result.Hash != "a" {
+		t.Errorf("running build = %+v", result)
+	}
+	if result := <-queued; result.Overloaded || result.Hash != "b" {
+		t.Errorf("queued build = %+v; want it built once a worker was free", result)
+	}
+}
+
+func TestBuildQueueTimesOut(t *testing.T) {
+	q := newBuildQueue(config.CodeConfig{BuildWorkers: 1, BuildQueueTimeout: 10 * time.Millisecond})
+	release := make(chan struct{})
+	defer close(release)
+	startBuild(t, q, "a", release)
+
+	result, _ := q.do("module", "b", func() buildResult { return buildResult{Hash: "b"} })
+	if !result.Overloaded || result.Errors[0].Text != errBuildQueueTimeout.Error() {
+		t.Errorf("build = %+v; want it turned away after the timeout", result)
+	}
+}
+
+func TestServeModuleTurnsAwayBuildsWhenOverloaded(t *testing.T) {
+	builds := newBuildCache(config.CodeConfig{BuildWorkers: 1, BuildQueue: 1})
+	release := make(chan struct{})
+	defer close(release)
+	startBuild(t, builds.queue, "running", release)
+	builds.queue.mu.Lock()
+	builds.queue.waiting = 1
+	builds.queue.mu.Unlock()
+
+	w := httptest.NewRecorder()
+	handleServeModule(builds, w, httptest.NewRequest("GET", "/module/queue.go", nil))
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("status = %d, Retry-After = %q; want 429 with a Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+}
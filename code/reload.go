@@ -0,0 +1,240 @@
+package code
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// reloadInterval is how often the files of components open in a browser are
+// checked for changes
+const reloadInterval = 500 * time.Millisecond
+
+// reloadUpgrader accepts pages from the same host only, the default
+var reloadUpgrader = websocket.Upgrader{}
+
+// reloadMessage tells a page its component changed and should be imported again
+type reloadMessage struct {
+	Type    string   `json:"type"` // "reload"
+	Path    string   `json:"path"`
+	Changed []string `json:"changed"`
+}
+
+// fileStamp is what a file looked like when it was last checked; the zero
+// stamp is a file that doesn't exist
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// componentWatch is a component open in browsers and the files it's built from
+type componentWatch struct {
+	files   map[string]fileStamp
+	clients map[chan reloadMessage]struct{}
+}
+
+// reloadHub watches the files of the components /render/ pages have open,
+// the files they import included, and tells the pages when one changes.
+// Files are polled only while a page is connected.
+type reloadHub struct {
+	mu       sync.Mutex
+	builds   *buildCache
+	interval time.Duration
+	watches  map[string]*componentWatch // By source path
+	running  bool
+}
+
+func newReloadHub(builds *buildCache) *reloadHub {
+	return &reloadHub{builds: builds, interval: reloadInterval, watches: make(map[string]*componentWatch)}
+}
+
+// subscribe watches the component at srcPath for a page, returning the
+// channel its reloads come on and a func that stops them
+func (h *reloadHub) subscribe(srcPath string) (<-chan reloadMessage, func()) {
+	files := h.componentFiles(srcPath, nil)
+	reloads := make(chan reloadMessage, 1)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	watch, ok := h.watches[srcPath]
+	if !ok {
+		watch = &componentWatch{files: files, clients: make(map[chan reloadMessage]struct{})}
+		h.watches[srcPath] = watch
+	}
+	watch.clients[reloads] = struct{}{}
+	if !h.running {
+		h.running = true
+		go h.run()
+	}
+
+	return reloads, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(watch.clients, reloads)
+		if len(watch.clients) == 0 && h.watches[srcPath] == watch {
+			delete(h.watches, srcPath)
+		}
+	}
+}
+
+// run checks the watched files until no page is connected
+func (h *reloadHub) run() {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.mu.Lock()
+		if len(h.watches) == 0 {
+			h.running = false
+			h.mu.Unlock()
+			return
+		}
+		h.mu.Unlock()
+		h.check()
+	}
+}
+
+// check tells the pages of each component that has a changed file to
+// reload, and starts watching what it's built from now
+func (h *reloadHub) check() {
+	h.mu.Lock()
+	watched := make(map[string]map[string]fileStamp, len(h.watches))
+	for srcPath, watch := range h.watches {
+		watched[srcPath] = watch.files
+	}
+	h.mu.Unlock()
+
+	for srcPath, files := range watched {
+		changed := changedFiles(files)
+		if len(changed) == 0 {
+			continue
+		}
+		// Building again picks up new imports and has the module ready
+		// for the pages' requests
+		files = h.componentFiles(srcPath, files)
+
+		h.mu.Lock()
+		watch, ok := h.watches[srcPath]
+		if !ok {
+			h.mu.Unlock()
+			continue
+		}
+		watch.files = files
+		for reloads := range watch.clients {
+			select {
+			case reloads <- reloadMessage{Type: "reload", Path: srcPath, Changed: changed}:
+			default: // A reload is already waiting
+			}
+		}
+		pages := len(watch.clients)
+		h.mu.Unlock()
+
+		slog.Info("Reloading pages of changed component", "path", srcPath, "changed", changed, "pages", pages, "action", "code_reload")
+	}
+}
+
+// componentFiles stamps the component at srcPath and the files its module
+// build read. While it doesn't build, such as in the middle of an edit, the
+// files of its last build are kept too.
+func (h *reloadHub) componentFiles(srcPath string, previous map[string]fileStamp) map[string]fileStamp {
+	var paths []string
+	if source, err := os.ReadFile(srcPath); err == nil {
+		result := h.builds.Build("module", srcPath, source, moduleBuildOptions(srcPath, source), false)
+		if len(result.Errors) == 0 {
+			previous = nil
+		}
+		// The component itself is one of its inputs
+		for path := range result.Inputs {
+			paths = append(paths, path)
+		}
+	}
+	for path := range previous {
+		paths = append(paths, path)
+	}
+	if len(paths) == 0 {
+		paths = append(paths, srcPath)
+	}
+
+	files := make(map[string]fileStamp, len(paths))
+	for _, path := range paths {
+		files[path] = stampFile(path)
+	}
+	return files
+}
+
+// changedFiles returns the files that no longer have their stamps, sorted
+func changedFiles(files map[string]fileStamp) []string {
+	var changed []string
+	for path, stamp := range files {
+		if stampFile(path) != stamp {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func stampFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+// handleReload is the WebSocket a /render/ page keeps open to hear when its
+// component changes, so it can import the module again
+func handleReload(hub *reloadHub, w http.ResponseWriter, r *http.Request) {
+	componentPath := strings.TrimPrefix(r.URL.Path, "/reload/")
+	if componentPath == "" {
+		http.Error(w, "Component path is required", http.StatusBadRequest)
+		return
+	}
+	cleanPath := filepath.Clean(componentPath)
+	if strings.Contains(cleanPath, "..") {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+	srcPath := filepath.Join("./", cleanPath)
+	if _, err := os.Stat(srcPath); os.IsNotExist(err) {
+		http.Error(w, "Source file not found", http.StatusNotFound)
+		return
+	}
+
+	conn, err := reloadUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reloads, unsubscribe := hub.subscribe(srcPath)
+	defer unsubscribe()
+
+	// Pages don't send anything; reading notices when they go away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case msg := <-reloads:
+			if err := conn.WriteJSON(msg); err != nil {
+				return
+			}
+		}
+	}
+}
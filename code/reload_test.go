@@ -0,0 +1,118 @@
+package code
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/gorilla/websocket"
+)
+
+func newTestReloadHub() *reloadHub {
+	hub := newReloadHub(newBuildCache(config.CodeConfig{}))
+	hub.interval = 10 * time.Millisecond
+	return hub
+}
+
+func TestReloadHubNotifiesWhenAnImportChanges(t *testing.T) {
+	dir := t.TempDir()
+	srcPath, _ := writeModule(t, dir, "hello")
+	hub := newTestReloadHub()
+
+	reloads, unsubscribe := hub.subscribe(srcPath)
+	if err := os.WriteFile(filepath.Join(dir, "dep.ts"), []byte(`export const message = "goodbye";`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-reloads:
+		if msg.Type != "reload" || msg.Path != srcPath || len(msg.Changed) != 1 || filepath.Base(msg.Changed[0]) != "dep.ts" {
+			t.Errorf("reload = %+v; want dep.ts changed", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload after editing an import")
+	}
+
+	unsubscribe()
+	if len(hub.watches) != 0 {
+		t.Errorf("%d components still watched after the last page left", len(hub.watches))
+	}
+}
+
+func TestReloadHubKeepsWatchingThroughBrokenEdits(t *testing.T) {
+	dir := t.TempDir()
+	srcPath, source := writeModule(t, dir, "hello")
+	hub := newTestReloadHub()
+	reloads, unsubscribe := hub.subscribe(srcPath)
+	defer unsubscribe()
+
+	// The entry stops building, then dep.ts is edited while it's broken
+	broken := append(append([]byte{}, source...), []byte(" export const = ;")...)
+	if err := os.WriteFile(srcPath, broken, 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitForReload(t, reloads)
+	if err := os.WriteFile(filepath.Join(dir, "dep.ts"), []byte(`export const message = "fixed up";`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitForReload(t, reloads)
+}
+
+func waitForReload(t *testing.T, reloads <-chan reloadMessage) {
+	t.Helper()
+	select {
+	case <-reloads:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload after an edit")
+	}
+}
+
+func TestHandleReload(t *testing.T) {
+	dir := writeThrowingModule(t)
+	hub := newTestReloadHub()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleReload(hub, w, r)
+	}))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/reload/"
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL+dir+"/Missing.tsx", nil); err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("dialing a missing component: %v; want 404", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+dir+"/App.tsx", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Wait for the page to be watched before editing
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		hub.mu.Lock()
+		watched := len(hub.watches)
+		hub.mu.Unlock()
+		if watched == 1 {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("the page's component isn't watched")
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "lib.ts"), []byte("export function fail(): never {\n  throw new Error(\"bang\");\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg reloadMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("no reload message: %v", err)
+	}
+	if msg.Type != "reload" || msg.Path != filepath.Join(dir, "App.tsx") {
+		t.Errorf("reload = %+v; want App.tsx", msg)
+	}
+}
@@ -0,0 +1,651 @@
+package code
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/deps"
+	"github.com/chromedp/chromedp"
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"golang.org/x/net/html"
+)
+
+// snapshotTimeout is the longest Chrome may take to load, render, and capture
+// a component
+const snapshotTimeout = time.Minute
+
+// snapshotRenderWait is how long a component is waited for to render before
+// it's captured anyway, such as when it renders nothing
+const snapshotRenderWait = 10 * time.Second
+
+// snapshotSettle is how long a rendered component is given to load its images
+// and fonts and run its effects
+const snapshotSettle = 500 * time.Millisecond
+
+// maxSnapshots is how many snapshots of each component are kept
+const maxSnapshots = 20
+
+// maxDOMChanges is the most changed lines of a DOM a diff reports
+const maxDOMChanges = 500
+
+// pixelTolerance is how far apart, out of 255, a channel of two pixels may be
+// and still count as the same, so antialiasing isn't a change
+const pixelTolerance = 8
+
+// The viewport a component is rendered in unless the request sets one
+const (
+	defaultSnapshotWidth  = 1280
+	defaultSnapshotHeight = 800
+	maxSnapshotSize       = 4096
+)
+
+// componentRendered is true once a /render/ page has mounted its component or
+// shown an error in its place
+const componentRendered = `(() => {
+	const root = document.getElementById('root');
+	return !root || root.childNodes.length > 0;
+})()`
+
+// snapshotFile matches the files kept for a snapshot: its screenshot, its
+// DOM, and the screenshot's diff from the snapshot before it
+var snapshotFile = regexp.MustCompile(`^[0-9a-f]{64}(\.png|\.html|\.diff\.png)$`)
+
+// snapshotKey matches the directories components' snapshots are kept in
+var snapshotKey = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// voidElements are the HTML elements without an end tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// Snapshot is a component rendered in headless Chrome: a screenshot and its
+// DOM, kept by the hash of both
+type Snapshot struct {
+	Hash      string    `json:"hash"`
+	Path      string    `json:"path"`
+	Component string    `json:"component"`
+	Width     int       `json:"width"` // Of the viewport; the screenshot is of the whole page
+	Height    int       `json:"height"`
+	TakenAt   time.Time `json:"takenAt"`
+	ImageURL  string    `json:"imageUrl"`
+	DOMURL    string    `json:"domUrl"`
+}
+
+// SnapshotDiff is how a snapshot differs from the one taken before it
+type SnapshotDiff struct {
+	Previous      string      `json:"previous"` // The hash of the snapshot before
+	Changed       bool        `json:"changed"`
+	ChangedPixels int         `json:"changedPixels"`
+	PixelRatio    float64     `json:"pixelRatio"` // Of the pixels of the larger screenshot
+	SizeChanged   bool        `json:"sizeChanged"`
+	DiffURL       string      `json:"diffUrl,omitempty"` // The screenshot faded, with changed pixels in red
+	DOMChanges    []DOMChange `json:"domChanges"`
+	DOMTruncated  bool        `json:"domTruncated,omitempty"` // Whether changes past maxDOMChanges were dropped
+}
+
+// DOMChange is a line of a DOM, one tag or text node per line, that was
+// added or removed
+type DOMChange struct {
+	Op   string `json:"op"` // "added" or "removed"
+	Line string `json:"line"`
+}
+
+// SnapshotResponse is a snapshot just taken and how it differs from the
+// component's last one
+type SnapshotResponse struct {
+	Snapshot Snapshot      `json:"snapshot"`
+	Diff     *SnapshotDiff `json:"diff,omitempty"` // Nil for a component's first snapshot
+}
+
+// SnapshotHistoryResponse is the snapshots kept of a component, oldest first
+type SnapshotHistoryResponse struct {
+	Snapshots []Snapshot `json:"snapshots"`
+}
+
+// snapshotter renders components headlessly for signed-in users and keeps
+// their last snapshots, so an edit can be checked for visual changes
+type snapshotter struct {
+	dir        string // Empty turns snapshots off
+	chromePath string
+	baseURL    string // Where Chrome loads /code/render/ pages from
+	userID     func(r *http.Request) (string, error)
+
+	// ignoreCertErrors lets Chrome load this server over TLS on loopback,
+	// where its certificate's names don't match
+	ignoreCertErrors bool
+
+	mu sync.Mutex // Guards the histories on disk
+
+	// capture renders pageURL in a viewport of the size given and returns a
+	// PNG screenshot and the DOM; nil captures it with Chrome
+	capture func(ctx context.Context, pageURL string, width, height int) ([]byte, string, error)
+}
+
+func newSnapshotter(d deps.Deps) *snapshotter {
+	s := &snapshotter{dir: d.Config.Code.SnapshotDir, chromePath: d.Config.Code.ChromePath, userID: sessionUserID(d)}
+	s.baseURL, s.ignoreCertErrors = snapshotBaseURL(d.Config)
+	return s
+}
+
+// snapshotBaseURL returns where Chrome loads components from: the configured
+// base URL, or else this server on loopback, and whether that's this server
+// over TLS. It's never taken from a request, so clients can't point Chrome at
+// other hosts.
+func snapshotBaseURL(cfg config.AppConfig) (string, bool) {
+	if cfg.Code.SnapshotBaseURL != "" {
+		return strings.TrimSuffix(cfg.Code.SnapshotBaseURL, "/"), false
+	}
+	_, port, err := net.SplitHostPort(cfg.Server.Addr)
+	if err != nil || port == "" {
+		port = "80"
+		if cfg.Server.TLS.Enabled() {
+			port = "443"
+		}
+	}
+	scheme := "http"
+	if cfg.Server.TLS.Enabled() {
+		scheme = "https"
+	}
+	return scheme + "://" + net.JoinHostPort("127.0.0.1", port), cfg.Server.TLS.Enabled()
+}
+
+// handleTake renders a component from /render/, ?component= naming its
+// export, in a viewport of ?width= by ?height=, and diffs it against the
+// component's last snapshot
+func (s *snapshotter) handleTake(w http.ResponseWriter, r *http.Request) {
+	userID, componentPath, componentName, ok := s.component(w, r)
+	if !ok {
+		return
+	}
+	width, err := snapshotSize(r.URL.Query().Get("width"), defaultSnapshotWidth)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid width: %v", err), http.StatusBadRequest)
+		return
+	}
+	height, err := snapshotSize(r.URL.Query().Get("height"), defaultSnapshotHeight)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid height: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Chrome loads the component from this server, the way a browser would
+	pageURL := s.baseURL + (&url.URL{
+		Path:     "/code/render/" + componentPath,
+		RawQuery: url.Values{"component": {componentName}}.Encode(),
+	}).String()
+
+	ctx, cancel := context.WithTimeout(r.Context(), snapshotTimeout)
+	defer cancel()
+	capture := s.capture
+	if capture == nil {
+		capture = s.chrome
+	}
+	start := time.Now()
+	screenshot, dom, err := capture(ctx, pageURL, width, height)
+	if err != nil {
+		slog.Error("Snapshot failed", "path", componentPath, "error", err)
+		http.Error(w, fmt.Sprintf("Failed to render component: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response, err := s.record(componentPath, componentName, width, height, screenshot, dom)
+	if err != nil {
+		slog.Error("Failed to save snapshot", "path", componentPath, "error", err)
+		http.Error(w, fmt.Sprintf("Failed to save snapshot: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	changed := response.Diff != nil && response.Diff.Changed
+	slog.Info("Took component snapshot", "user_id", userID, "path", componentPath, "component", componentName,
+		"hash", response.Snapshot.Hash, "changed", changed, "duration", time.Since(start), "action", "code_snapshot")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleHistory lists the snapshots kept of a component
+func (s *snapshotter) handleHistory(w http.ResponseWriter, r *http.Request) {
+	_, componentPath, componentName, ok := s.component(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	history, err := s.history(filepath.Join(s.dir, componentKey(componentPath, componentName)))
+	s.mu.Unlock()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read snapshots: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SnapshotHistoryResponse{Snapshots: history})
+}
+
+// handleFile serves a snapshot's screenshot, diff, or DOM. DOMs are served as
+// text, so a captured page can't run in this origin.
+func (s *snapshotter) handleFile(w http.ResponseWriter, r *http.Request) {
+	if userID, err := s.userID(r); err != nil || userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	key, name := r.PathValue("key"), r.PathValue("file")
+	if s.dir == "" || !snapshotKey.MatchString(key) || !snapshotFile.MatchString(name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(filepath.Join(s.dir, key, name))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read snapshot: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if strings.HasSuffix(name, ".html") {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// Snapshots are kept by the hash of their contents, so they never change
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// component authenticates a request for a component's snapshots and returns
+// who made it, the component's path, and the name of its export
+func (s *snapshotter) component(w http.ResponseWriter, r *http.Request) (string, string, string, bool) {
+	userID, err := s.userID(r)
+	if err != nil || userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", "", "", false
+	}
+	if s.dir == "" {
+		http.Error(w, "Snapshots are turned off", http.StatusNotFound)
+		return "", "", "", false
+	}
+
+	// Validate and sanitize the path
+	componentPath := r.PathValue("path")
+	cleanPath := filepath.Clean(componentPath)
+	if componentPath == "" || strings.Contains(cleanPath, "..") || filepath.IsAbs(cleanPath) {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return "", "", "", false
+	}
+	if _, err := os.Stat(filepath.Join("./", cleanPath)); os.IsNotExist(err) {
+		http.Error(w, "Source file not found", http.StatusNotFound)
+		return "", "", "", false
+	}
+
+	componentName := r.URL.Query().Get("component")
+	if componentName == "" {
+		componentName = "App" // Default to App component, as /render/ does
+	}
+	return userID, filepath.ToSlash(cleanPath), componentName, true
+}
+
+// chrome renders pageURL in headless Chrome and captures the whole page and
+// its DOM once the component has rendered
+func (s *snapshotter) chrome(ctx context.Context, pageURL string, width, height int) ([]byte, string, error) {
+	opts := append(chromedp.DefaultExecAllocatorOptions[:], chromedp.WindowSize(width, height))
+	if s.chromePath != "" {
+		opts = append(opts, chromedp.ExecPath(s.chromePath))
+	}
+	// Chrome's sandbox can't start as root, such as in a container
+	if os.Geteuid() == 0 {
+		opts = append(opts, chromedp.NoSandbox)
+	}
+	if s.ignoreCertErrors {
+		opts = append(opts, chromedp.IgnoreCertErrors)
+	}
+	allocCtx, cancel := chromedp.NewExecAllocator(ctx, opts...)
+	defer cancel()
+	browserCtx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+
+	if err := chromedp.Run(browserCtx,
+		chromedp.EmulateViewport(int64(width), int64(height)),
+		chromedp.Navigate(pageURL),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+	); err != nil {
+		return nil, "", fmt.Errorf("failed to load %s: %w", pageURL, err)
+	}
+	err := chromedp.Run(browserCtx, chromedp.Poll(componentRendered, nil, chromedp.WithPollingTimeout(snapshotRenderWait)))
+	if err != nil && !errors.Is(err, chromedp.ErrPollingTimeout) {
+		return nil, "", fmt.Errorf("failed to wait for component: %w", err)
+	}
+
+	var screenshot []byte
+	var dom string
+	if err := chromedp.Run(browserCtx,
+		chromedp.Sleep(snapshotSettle),
+		chromedp.FullScreenshot(&screenshot, 100), // PNG
+		chromedp.OuterHTML("html", &dom, chromedp.ByQuery),
+	); err != nil {
+		return nil, "", fmt.Errorf("failed to capture %s: %w", pageURL, err)
+	}
+	return screenshot, dom, nil
+}
+
+// record keeps a snapshot of a component and diffs it against the
+// component's last one. A snapshot the same as the last isn't kept again.
+func (s *snapshotter) record(componentPath, componentName string, width, height int, screenshot []byte, dom string) (SnapshotResponse, error) {
+	key := componentKey(componentPath, componentName)
+	dir := filepath.Join(s.dir, key)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return SnapshotResponse{}, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	hash := sha256.New()
+	hash.Write(screenshot)
+	hash.Write([]byte{0})
+	hash.Write([]byte(dom))
+	sum := hex.EncodeToString(hash.Sum(nil))
+	snapshot := Snapshot{
+		Hash:      sum,
+		Path:      componentPath,
+		Component: componentName,
+		Width:     width,
+		Height:    height,
+		TakenAt:   time.Now(),
+		ImageURL:  snapshotURL(key, sum+".png"),
+		DOMURL:    snapshotURL(key, sum+".html"),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	history, err := s.history(dir)
+	if err != nil {
+		return SnapshotResponse{}, err
+	}
+	if len(history) > 0 && history[len(history)-1].Hash == sum {
+		last := history[len(history)-1]
+		return SnapshotResponse{Snapshot: last, Diff: &SnapshotDiff{Previous: sum, DOMChanges: []DOMChange{}}}, nil
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, sum+".png"), screenshot, 0644); err != nil {
+		return SnapshotResponse{}, fmt.Errorf("failed to write screenshot: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, sum+".html"), []byte(dom), 0644); err != nil {
+		return SnapshotResponse{}, fmt.Errorf("failed to write DOM: %w", err)
+	}
+
+	response := SnapshotResponse{Snapshot: snapshot}
+	if len(history) > 0 {
+		diff, err := diffSnapshot(dir, history[len(history)-1], snapshot, screenshot, dom)
+		if err != nil {
+			return SnapshotResponse{}, err
+		}
+		response.Diff = diff
+	}
+
+	history = append(history, snapshot)
+	if len(history) > maxSnapshots {
+		dropped := history[:len(history)-maxSnapshots]
+		history = history[len(history)-maxSnapshots:]
+		removeSnapshots(dir, dropped, history)
+	}
+	if err := s.saveHistory(dir, history); err != nil {
+		return SnapshotResponse{}, err
+	}
+	return response, nil
+}
+
+// history returns the snapshots kept in dir, oldest first
+func (s *snapshotter) history(dir string) ([]Snapshot, error) {
+	history := []Snapshot{}
+	data, err := os.ReadFile(filepath.Join(dir, "history.json"))
+	if os.IsNotExist(err) {
+		return history, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot history: %w", err)
+	}
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot history: %w", err)
+	}
+	return history, nil
+}
+
+func (s *snapshotter) saveHistory(dir string, history []Snapshot) error {
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot history: %w", err)
+	}
+	tmp := filepath.Join(dir, "history.json.tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot history: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, "history.json")); err != nil {
+		return fmt.Errorf("failed to write snapshot history: %w", err)
+	}
+	return nil
+}
+
+// removeSnapshots deletes the files of dropped snapshots that none of the
+// kept ones share
+func removeSnapshots(dir string, dropped, kept []Snapshot) {
+	for _, snapshot := range dropped {
+		shared := false
+		for _, k := range kept {
+			shared = shared || k.Hash == snapshot.Hash
+		}
+		if shared {
+			continue
+		}
+		for _, ext := range []string{".png", ".html", ".diff.png"} {
+			if err := os.Remove(filepath.Join(dir, snapshot.Hash+ext)); err != nil && !os.IsNotExist(err) {
+				slog.Warn("Failed to remove snapshot", "dir", dir, "hash", snapshot.Hash, "error", err)
+			}
+		}
+	}
+}
+
+// diffSnapshot compares a snapshot's screenshot and DOM with the one before
+// it, writing an image of the changed pixels when there are any
+func diffSnapshot(dir string, previous, snapshot Snapshot, screenshot []byte, dom string) (*SnapshotDiff, error) {
+	diff := &SnapshotDiff{Previous: previous.Hash, DOMChanges: []DOMChange{}}
+
+	before, err := readPNG(filepath.Join(dir, previous.Hash+".png"))
+	if err != nil {
+		return nil, err
+	}
+	after, err := png.Decode(bytes.NewReader(screenshot))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode screenshot: %w", err)
+	}
+	changed, total, highlighted := diffImages(before, after)
+	diff.ChangedPixels = changed
+	diff.SizeChanged = before.Bounds().Size() != after.Bounds().Size()
+	if total > 0 {
+		diff.PixelRatio = float64(changed) / float64(total)
+	}
+	if changed > 0 {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, highlighted); err != nil {
+			return nil, fmt.Errorf("failed to encode diff: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, snapshot.Hash+".diff.png"), buf.Bytes(), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write diff: %w", err)
+		}
+		diff.DiffURL = snapshotURL(filepath.Base(dir), snapshot.Hash+".diff.png")
+	}
+
+	previousDOM, err := os.ReadFile(filepath.Join(dir, previous.Hash+".html"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DOM: %w", err)
+	}
+	diff.DOMChanges, diff.DOMTruncated = diffDOM(string(previousDOM), dom)
+
+	diff.Changed = changed > 0 || diff.SizeChanged || len(diff.DOMChanges) > 0
+	return diff, nil
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read screenshot: %w", err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode screenshot: %w", err)
+	}
+	return img, nil
+}
+
+// diffImages counts the pixels that differ between two images, those only
+// one of them covers included, out of the pixels of both. It returns after
+// faded, with the changed pixels in red.
+func diffImages(before, after image.Image) (int, int, *image.NRGBA) {
+	a, b := toNRGBA(before), toNRGBA(after)
+	width := max(a.Rect.Dx(), b.Rect.Dx())
+	height := max(a.Rect.Dy(), b.Rect.Dy())
+	highlighted := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	changed := 0
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := image.Pt(x, y)
+			inA, inB := p.In(a.Rect), p.In(b.Rect)
+			if inA && inB && samePixel(a.Pix[a.PixOffset(x, y):], b.Pix[b.PixOffset(x, y):]) {
+				c := b.NRGBAAt(x, y)
+				gray := (299*uint32(c.R) + 587*uint32(c.G) + 114*uint32(c.B)) / 1000
+				faded := uint8(255 - (255-gray)/4)
+				highlighted.SetNRGBA(x, y, color.NRGBA{R: faded, G: faded, B: faded, A: 255})
+				continue
+			}
+			changed++
+			highlighted.SetNRGBA(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	return changed, width * height, highlighted
+}
+
+// samePixel reports whether two NRGBA pixels are within pixelTolerance
+func samePixel(a, b []uint8) bool {
+	for i := 0; i < 4; i++ {
+		d := int(a[i]) - int(b[i])
+		if d < -pixelTolerance || d > pixelTolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// toNRGBA returns img as NRGBA with its bounds at the origin
+func toNRGBA(img image.Image) *image.NRGBA {
+	bounds := img.Bounds()
+	if n, ok := img.(*image.NRGBA); ok && bounds.Min == (image.Point{}) {
+		return n
+	}
+	n := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(n, n.Rect, img, bounds.Min, draw.Src)
+	return n
+}
+
+// diffDOM returns the lines of a DOM, one tag or text node per line, that
+// were removed or added, and whether there were more than maxDOMChanges
+func diffDOM(before, after string) ([]DOMChange, bool) {
+	dmp := diffmatchpatch.New()
+	a, b, lines := dmp.DiffLinesToChars(strings.Join(domLines(before), "\n")+"\n", strings.Join(domLines(after), "\n")+"\n")
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(a, b, false), lines)
+
+	changes := []DOMChange{}
+	for _, d := range diffs {
+		op := ""
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			op = "added"
+		case diffmatchpatch.DiffDelete:
+			op = "removed"
+		default:
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(d.Text, "\n"), "\n") {
+			if len(changes) == maxDOMChanges {
+				return changes, true
+			}
+			changes = append(changes, DOMChange{Op: op, Line: line})
+		}
+	}
+	return changes, false
+}
+
+// domLines splits a DOM into a line for each tag and each line of text,
+// indented by depth, so diffs show what changed rather than the whole document
+func domLines(dom string) []string {
+	var lines []string
+	depth := 0
+	tokenizer := html.NewTokenizer(strings.NewReader(dom))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return lines
+		case html.StartTagToken:
+			token := tokenizer.Token()
+			lines = append(lines, strings.Repeat("  ", depth)+token.String())
+			if !voidElements[token.Data] {
+				depth++
+			}
+		case html.EndTagToken:
+			token := tokenizer.Token()
+			depth = max(depth-1, 0)
+			lines = append(lines, strings.Repeat("  ", depth)+token.String())
+		case html.SelfClosingTagToken, html.DoctypeToken:
+			lines = append(lines, strings.Repeat("  ", depth)+tokenizer.Token().String())
+		case html.TextToken:
+			for _, text := range strings.Split(string(tokenizer.Text()), "\n") {
+				if text = strings.TrimSpace(text); text != "" {
+					lines = append(lines, strings.Repeat("  ", depth)+text)
+				}
+			}
+		}
+	}
+}
+
+// componentKey names the directory a component's snapshots are kept in
+func componentKey(componentPath, componentName string) string {
+	sum := sha256.Sum256([]byte(componentPath + "\x00" + componentName))
+	return hex.EncodeToString(sum[:8])
+}
+
+func snapshotURL(key, name string) string {
+	return "/code/snapshots/" + key + "/" + name
+}
+
+// snapshotSize parses a viewport dimension, which defaults to def
+func snapshotSize(value string, def int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if size <= 0 || size > maxSnapshotSize {
+		return 0, fmt.Errorf("must be between 1 and %d", maxSnapshotSize)
+	}
+	return size, nil
+}
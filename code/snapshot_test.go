@@ -0,0 +1,289 @@
+package code
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/breadchris/flow/config"
+)
+
+// fakeScreenshot returns a white PNG with a black square of size at its
+// top left, and the DOM of a page showing text
+func fakeScreenshot(t *testing.T, size int, text string) ([]byte, string) {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			c := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+			if x < size && y < size {
+				c = color.NRGBA{A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), `<html><head></head><body><div id="root"><div class="card"><p>` + text + `</p></div></div></body></html>`
+}
+
+// newTestSnapshots serves the snapshot API for a snapshotter keeping
+// snapshots in dir, whose pages render as capture returns
+func newTestSnapshots(t *testing.T, dir string, capture func() ([]byte, string)) (*httptest.Server, *[]string) {
+	t.Helper()
+	var pages []string
+	snapshots := &snapshotter{
+		dir:     dir,
+		baseURL: "http://127.0.0.1:8082",
+		userID:  testFileManager("").userID,
+		capture: func(ctx context.Context, pageURL string, width, height int) ([]byte, string, error) {
+			pages = append(pages, fmt.Sprintf("%s %dx%d", pageURL, width, height))
+			screenshot, dom := capture()
+			return screenshot, dom, nil
+		},
+	}
+	m := http.NewServeMux()
+	m.HandleFunc("POST /snapshot/{path...}", snapshots.handleTake)
+	m.HandleFunc("GET /snapshot/{path...}", snapshots.handleHistory)
+	m.HandleFunc("GET /snapshots/{key}/{file}", snapshots.handleFile)
+	server := httptest.NewServer(m)
+	t.Cleanup(server.Close)
+	return server, &pages
+}
+
+func takeSnapshot(t *testing.T, server *httptest.Server, query string) SnapshotResponse {
+	t.Helper()
+	status, body := fileRequest(t, server, "alice", "POST", "/snapshot/snapshot.go"+query, "")
+	if status != http.StatusOK {
+		t.Fatalf("snapshot = %d %s; want 200", status, body)
+	}
+	var response SnapshotResponse
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestSnapshotDiffsAgainstThePreviousSnapshot(t *testing.T) {
+	size, text := 0, "Hello"
+	server, pages := newTestSnapshots(t, t.TempDir(), func() ([]byte, string) {
+		screenshot, dom := fakeScreenshot(t, size, text)
+		return screenshot, dom
+	})
+
+	if status, _ := fileRequest(t, server, "", "POST", "/snapshot/snapshot.go", ""); status != http.StatusUnauthorized {
+		t.Errorf("snapshot signed out = %d; want 401", status)
+	}
+	if status, _ := fileRequest(t, server, "alice", "POST", "/snapshot/missing.tsx", ""); status != http.StatusNotFound {
+		t.Errorf("snapshot of a missing component = %d; want 404", status)
+	}
+	if status, _ := fileRequest(t, server, "alice", "POST", "/snapshot/snapshot.go?width=0", ""); status != http.StatusBadRequest {
+		t.Errorf("snapshot with a width of 0 = %d; want 400", status)
+	}
+
+	first := takeSnapshot(t, server, "?component=Card&width=640&height=480")
+	if first.Diff != nil {
+		t.Errorf("first snapshot has a diff: %+v", first.Diff)
+	}
+	if want := "http://127.0.0.1:8082/code/render/snapshot.go?component=Card 640x480"; len(*pages) != 1 || (*pages)[0] != want {
+		t.Errorf("rendered %q; want %q", *pages, want)
+	}
+
+	same := takeSnapshot(t, server, "?component=Card")
+	if same.Diff == nil || same.Diff.Changed || same.Snapshot.Hash != first.Snapshot.Hash {
+		t.Errorf("unchanged snapshot = %+v, diff %+v; want the first one, unchanged", same.Snapshot, same.Diff)
+	}
+
+	size, text = 10, "Goodbye"
+	changed := takeSnapshot(t, server, "?component=Card")
+	diff := changed.Diff
+	if diff == nil || !diff.Changed || diff.Previous != first.Snapshot.Hash {
+		t.Fatalf("diff = %+v; want a change from %s", diff, first.Snapshot.Hash)
+	}
+	if diff.ChangedPixels != 100 || diff.SizeChanged {
+		t.Errorf("changed pixels = %d, size changed %v; want 100 of the same size", diff.ChangedPixels, diff.SizeChanged)
+	}
+	wantDOM := []DOMChange{{Op: "removed", Line: "          Hello"}, {Op: "added", Line: "          Goodbye"}}
+	if fmt.Sprint(diff.DOMChanges) != fmt.Sprint(wantDOM) {
+		t.Errorf("DOM changes = %q; want %q", diff.DOMChanges, wantDOM)
+	}
+
+	status, body := fileRequest(t, server, "alice", "GET", "/snapshot/snapshot.go?component=Card", "")
+	var history SnapshotHistoryResponse
+	if err := json.Unmarshal([]byte(body), &history); status != http.StatusOK || err != nil {
+		t.Fatalf("history = %d %s", status, body)
+	}
+	if len(history.Snapshots) != 2 || history.Snapshots[1].Hash != changed.Snapshot.Hash {
+		t.Errorf("history = %+v; want the first and changed snapshots", history.Snapshots)
+	}
+
+	// The files are served without the /code prefix the server is mounted at
+	status, body = fileRequest(t, server, "alice", "GET", strings.TrimPrefix(diff.DiffURL, "/code"), "")
+	if status != http.StatusOK {
+		t.Fatalf("diff image = %d", status)
+	}
+	img, err := png.Decode(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, b, _ := img.At(5, 5).RGBA(); r>>8 != 255 || g != 0 || b != 0 {
+		t.Errorf("changed pixel in diff = %d,%d,%d; want red", r>>8, g>>8, b>>8)
+	}
+
+	req, _ := http.NewRequest("GET", server.URL+strings.TrimPrefix(changed.Snapshot.DOMURL, "/code"), nil)
+	req.Header.Set("X-User-ID", "alice")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("DOM content type = %q; want text/plain", contentType)
+	}
+	if status, _ := fileRequest(t, server, "alice", "GET", "/snapshots/0123456789abcdef/history.json", ""); status != http.StatusNotFound {
+		t.Errorf("history file = %d; want 404", status)
+	}
+}
+
+func TestSnapshotIgnoresTheRequestHost(t *testing.T) {
+	server, pages := newTestSnapshots(t, t.TempDir(), func() ([]byte, string) {
+		return fakeScreenshot(t, 0, "")
+	})
+
+	req, _ := http.NewRequest("POST", server.URL+"/snapshot/snapshot.go", nil)
+	req.Host = "169.254.169.254"
+	req.Header.Set("X-User-ID", "alice")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("snapshot = %d; want 200", resp.StatusCode)
+	}
+	if len(*pages) != 1 || !strings.HasPrefix((*pages)[0], "http://127.0.0.1:8082/code/render/") {
+		t.Errorf("rendered %q; want a page on this server whatever the Host header", *pages)
+	}
+}
+
+func TestSnapshotBaseURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.AppConfig
+		want     string
+		insecure bool
+	}{
+		{"loopback", config.AppConfig{Server: config.ServerConfig{Addr: ":8082"}}, "http://127.0.0.1:8082", false},
+		{"any interface", config.AppConfig{Server: config.ServerConfig{Addr: "0.0.0.0:9000"}}, "http://127.0.0.1:9000", false},
+		{"tls", config.AppConfig{Server: config.ServerConfig{Addr: ":443", TLS: config.TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"}}}, "https://127.0.0.1:443", true},
+		{"configured", config.AppConfig{Code: config.CodeConfig{SnapshotBaseURL: "http://render.internal:8082/"}}, "http://render.internal:8082", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, insecure := snapshotBaseURL(tt.cfg)
+			if got != tt.want || insecure != tt.insecure {
+				t.Errorf("snapshotBaseURL = %q, %v; want %q, %v", got, insecure, tt.want, tt.insecure)
+			}
+		})
+	}
+}
+
+func TestSnapshotsAreTrimmed(t *testing.T) {
+	dir := t.TempDir()
+	n := 0
+	server, _ := newTestSnapshots(t, dir, func() ([]byte, string) {
+		n++
+		screenshot, dom := fakeScreenshot(t, n, "")
+		return screenshot, dom
+	})
+
+	first := takeSnapshot(t, server, "")
+	for i := 0; i < maxSnapshots; i++ {
+		takeSnapshot(t, server, "")
+	}
+
+	keyDir := filepath.Join(dir, componentKey("snapshot.go", "App"))
+	history, err := (&snapshotter{}).history(keyDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != maxSnapshots {
+		t.Errorf("kept %d snapshots; want %d", len(history), maxSnapshots)
+	}
+	if _, err := os.Stat(filepath.Join(keyDir, first.Snapshot.Hash+".png")); !os.IsNotExist(err) {
+		t.Errorf("oldest snapshot wasn't removed: %v", err)
+	}
+}
+
+func TestDiffImagesCountsPixelsOutsideTheSmallerImage(t *testing.T) {
+	small := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	large := image.NewNRGBA(image.Rect(0, 0, 4, 6))
+	changed, total, _ := diffImages(small, large)
+	if changed != 8 || total != 24 {
+		t.Errorf("diffImages = %d of %d; want 8 of 24", changed, total)
+	}
+}
+
+func TestDOMLines(t *testing.T) {
+	got := domLines(`<div class="a"><img src="x.png"><span>one
+	two</span><br/></div>`)
+	want := []string{`<div class="a">`, `  <img src="x.png">`, `  <span>`, `    one`, `    two`, `  </span>`, `  <br/>`, `</div>`}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("domLines = %q; want %q", got, want)
+	}
+}
+
+// testChrome returns the Chrome in CODE_CHROME_PATH or on PATH, skipping the
+// test without one
+func testChrome(t *testing.T) string {
+	t.Helper()
+	if path := os.Getenv("CODE_CHROME_PATH"); path != "" {
+		return path
+	}
+	for _, name := range []string{"google-chrome", "chromium", "chromium-browser", "chrome", "headless-shell"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	t.Skip("Chrome isn't installed")
+	return ""
+}
+
+func TestChromeCapture(t *testing.T) {
+	chromePath := testChrome(t)
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><div id="root"></div><script>
+			setTimeout(() => { document.getElementById('root').innerHTML = '<h1>Rendered</h1>'; }, 100);
+		</script></body></html>`)
+	}))
+	defer page.Close()
+
+	s := &snapshotter{chromePath: chromePath}
+	screenshot, dom, err := s.chrome(context.Background(), page.URL, 320, 240)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(screenshot))
+	if err != nil {
+		t.Fatalf("screenshot isn't a PNG: %v", err)
+	}
+	if img.Bounds().Dx() != 320 {
+		t.Errorf("screenshot width = %d; want 320", img.Bounds().Dx())
+	}
+	if !strings.Contains(dom, "<h1>Rendered</h1>") {
+		t.Errorf("DOM was captured before the component rendered: %s", dom)
+	}
+}
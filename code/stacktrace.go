@@ -0,0 +1,165 @@
+package code
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-sourcemap/sourcemap"
+)
+
+// maxStackTraceBody is the largest stack trace read
+const maxStackTraceBody = 1 << 20
+
+var (
+	// chromeFrame matches "at fn (url:line:col)" and "at url:line:col"
+	chromeFrame = regexp.MustCompile(`^(\s*at (?:.*? \()?)(\S+?):(\d+):(\d+)(\)?\s*)$`)
+	// firefoxFrame matches "fn@url:line:col", the format of Firefox and Safari
+	firefoxFrame = regexp.MustCompile(`^(\s*[^@\s]*@)(\S+?):(\d+):(\d+)(\s*)$`)
+)
+
+// StackTraceRequest is a stack trace from a browser, as in Error.stack
+type StackTraceRequest struct {
+	Stack string `json:"stack"`
+}
+
+// StackTraceResponse is a stack trace with the frames of compiled modules
+// pointed back at their source
+type StackTraceResponse struct {
+	Stack  string       `json:"stack"`
+	Frames []StackFrame `json:"frames"`
+}
+
+// StackFrame is a frame of a stack trace in a compiled module. Lines and
+// columns are 1-based, as browsers report them.
+type StackFrame struct {
+	URL            string `json:"url"`
+	Line           int    `json:"line"`
+	Column         int    `json:"column"`
+	Mapped         bool   `json:"mapped"`
+	Source         string `json:"source,omitempty"`
+	OriginalLine   int    `json:"originalLine,omitempty"`
+	OriginalColumn int    `json:"originalColumn,omitempty"`
+	Name           string `json:"name,omitempty"`
+	Error          string `json:"error,omitempty"` // Why the frame couldn't be mapped
+}
+
+// handleStackTrace maps a browser stack trace through the source maps of the
+// modules it passes through, so errors point at lines of TSX
+func handleStackTrace(builds *buildCache, w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req StackTraceRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxStackTraceBody)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Stack) == "" {
+		http.Error(w, "Stack is required", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mapStackTrace(builds, req.Stack))
+}
+
+// mapStackTrace rewrites the frames of a stack trace that are in modules
+// served from /module/ to their original files, lines, and columns. Other
+// lines are left as they are.
+func mapStackTrace(builds *buildCache, stack string) StackTraceResponse {
+	consumers := make(map[string]*moduleSourceMap)
+	response := StackTraceResponse{Frames: []StackFrame{}}
+
+	lines := strings.Split(stack, "\n")
+	for i, line := range lines {
+		match := chromeFrame.FindStringSubmatch(line)
+		if match == nil {
+			match = firefoxFrame.FindStringSubmatch(line)
+		}
+		if match == nil {
+			continue
+		}
+		srcPath, ok := moduleSourcePath(match[2])
+		if !ok {
+			continue
+		}
+
+		frame := StackFrame{URL: match[2]}
+		frame.Line, _ = strconv.Atoi(match[3])
+		frame.Column, _ = strconv.Atoi(match[4])
+
+		consumer, ok := consumers[srcPath]
+		if !ok {
+			consumer = loadModuleSourceMap(builds, srcPath)
+			consumers[srcPath] = consumer
+		}
+		if consumer.err != nil {
+			frame.Error = consumer.err.Error()
+		} else if source, name, line, column, ok := consumer.Source(frame.Line, frame.Column-1); ok {
+			frame.Mapped = true
+			frame.Source = filepath.Join(filepath.Dir(srcPath), source)
+			frame.OriginalLine = line
+			frame.OriginalColumn = column + 1
+			frame.Name = name
+			lines[i] = fmt.Sprintf("%s%s:%d:%d%s", match[1], frame.Source, frame.OriginalLine, frame.OriginalColumn, match[5])
+		} else {
+			frame.Error = "no mapping for this position"
+		}
+		response.Frames = append(response.Frames, frame)
+	}
+
+	response.Stack = strings.Join(lines, "\n")
+	return response
+}
+
+// moduleSourceMap is the parsed source map of a module, or why it couldn't be had
+type moduleSourceMap struct {
+	*sourcemap.Consumer
+	err error
+}
+
+// loadModuleSourceMap builds the module at srcPath as /module/ serves it,
+// which is usually cached, and parses its source map
+func loadModuleSourceMap(builds *buildCache, srcPath string) *moduleSourceMap {
+	sourceCode, err := os.ReadFile(srcPath)
+	if err != nil {
+		return &moduleSourceMap{err: fmt.Errorf("failed to read source file: %w", err)}
+	}
+	result := builds.Build("module", srcPath, sourceCode, moduleBuildOptions(srcPath, sourceCode), false)
+	if len(result.Errors) > 0 {
+		return &moduleSourceMap{err: fmt.Errorf("failed to build module: %s", result.Errors[0].Text)}
+	}
+	consumer, err := sourcemap.Parse("", result.SourceMap)
+	if err != nil {
+		return &moduleSourceMap{err: fmt.Errorf("failed to parse source map: %w", err)}
+	}
+	return &moduleSourceMap{Consumer: consumer}
+}
+
+// moduleSourcePath returns the source file of a module's URL, such as
+// https://host/code/module/data/App.tsx, if it is one
+func moduleSourcePath(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	_, componentPath, ok := strings.Cut(u.Path, "/module/")
+	if !ok || componentPath == "" {
+		return "", false
+	}
+	cleanPath := filepath.Clean(componentPath)
+	if strings.Contains(cleanPath, "..") {
+		return "", false
+	}
+	return filepath.Join("./", cleanPath), true
+}
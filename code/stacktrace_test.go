@@ -0,0 +1,145 @@
+package code
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/breadchris/flow/config"
+)
+
+// writeThrowingModule writes App.tsx, which calls a function in lib.ts that
+// throws. Modules are served relative to the working directory, so it's
+// written under it.
+func writeThrowingModule(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp(".", "stacktrace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	lib := "export function fail(): never {\n  const reason: string = \"boom\";\n  throw new Error(reason);\n}\n"
+	app := "import { fail } from \"./lib\";\n\nexport default function App() {\n  return <div onClick={() => fail()}>Hi</div>;\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "lib.ts"), []byte(lib), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "App.tsx"), []byte(app), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// generatedPosition returns the 1-based line and column of text in output
+func generatedPosition(t *testing.T, output []byte, text string) (int, int) {
+	t.Helper()
+	for i, line := range strings.Split(string(output), "\n") {
+		if column := strings.Index(line, text); column >= 0 {
+			return i + 1, column + 1
+		}
+	}
+	t.Fatalf("%q isn't in the build:\n%s", text, output)
+	return 0, 0
+}
+
+func TestServeModuleSourceMap(t *testing.T) {
+	dir := writeThrowingModule(t)
+	builds := newBuildCache(config.CodeConfig{})
+
+	w := httptest.NewRecorder()
+	handleServeModule(builds, w, httptest.NewRequest("GET", "/module/"+dir+"/App.tsx", nil))
+	if !strings.Contains(w.Body.String(), "//# sourceMappingURL=App.tsx.map") {
+		t.Errorf("module doesn't link its source map:\n%s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handleServeModule(builds, w, httptest.NewRequest("GET", "/module/"+dir+"/App.tsx.map", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %s; want application/json", ct)
+	}
+	var sourceMap struct {
+		Sources        []string `json:"sources"`
+		SourcesContent []string `json:"sourcesContent"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &sourceMap); err != nil {
+		t.Fatalf("source map isn't JSON: %v", err)
+	}
+	if strings.Join(sourceMap.Sources, ",") != "lib.ts,App.tsx" || len(sourceMap.SourcesContent) != 2 {
+		t.Errorf("source map sources = %v; want lib.ts and App.tsx with their contents", sourceMap.Sources)
+	}
+}
+
+func TestMapStackTrace(t *testing.T) {
+	dir := writeThrowingModule(t)
+	builds := newBuildCache(config.CodeConfig{})
+	srcPath := filepath.Join(dir, "App.tsx")
+	source, _ := os.ReadFile(srcPath)
+	build := builds.Build("module", srcPath, source, moduleBuildOptions(srcPath, source), false)
+	line, column := generatedPosition(t, build.Output, "throw new Error(reason)")
+
+	moduleURL := "http://localhost:8080/code/module/" + dir + "/App.tsx"
+	stack := strings.Join([]string{
+		"Error: boom",
+		fmt.Sprintf("    at fail (%s:%d:%d)", moduleURL, line, column),
+		"    at https://esm.sh/react-dom@18/client.js:1:2",
+		fmt.Sprintf("fail@%s:%d:%d", moduleURL, line, column),
+		fmt.Sprintf("    at %s?rebuild=1:%d:%d", moduleURL, line, column),
+	}, "\n")
+	got := mapStackTrace(builds, stack)
+
+	want := filepath.Join(dir, "lib.ts") + ":3:3"
+	wantStack := strings.Join([]string{
+		"Error: boom",
+		"    at fail (" + want + ")",
+		"    at https://esm.sh/react-dom@18/client.js:1:2",
+		"fail@" + want,
+		"    at " + want,
+	}, "\n")
+	if got.Stack != wantStack {
+		t.Errorf("mapped stack =\n%s\nwant\n%s", got.Stack, wantStack)
+	}
+	if len(got.Frames) != 3 {
+		t.Fatalf("%d frames; want the 3 in the module", len(got.Frames))
+	}
+	frame := got.Frames[0]
+	if !frame.Mapped || frame.Line != line || frame.Column != column || frame.OriginalLine != 3 || frame.OriginalColumn != 3 {
+		t.Errorf("frame = %+v; want %d:%d mapped to lib.ts:3:3", frame, line, column)
+	}
+}
+
+func TestMapStackTraceReportsUnmappedFrames(t *testing.T) {
+	builds := newBuildCache(config.CodeConfig{})
+	stack := "Error: boom\n    at App (http://localhost:8080/code/module/missing/App.tsx:1:1)"
+	got := mapStackTrace(builds, stack)
+
+	if got.Stack != stack {
+		t.Errorf("mapped stack = %q; want it unchanged", got.Stack)
+	}
+	if len(got.Frames) != 1 || got.Frames[0].Mapped || got.Frames[0].Error == "" {
+		t.Errorf("frames = %+v; want one unmapped frame with an error", got.Frames)
+	}
+}
+
+func TestModuleSourcePath(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+		ok   bool
+	}{
+		{"http://localhost:8080/code/module/data/App.tsx", "data/App.tsx", true},
+		{"/code/module/data/App.tsx?rebuild=1", "data/App.tsx", true},
+		{"http://localhost:8080/code/module/../secrets.ts", "", false},
+		{"https://esm.sh/react@18", "", false},
+		{"http://localhost:8080/code/module/", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := moduleSourcePath(tt.url)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("moduleSourcePath(%q) = %q, %v; want %q, %v", tt.url, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package code
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/breadchris/flow/config"
+)
+
+// stylesheetTimeout is the longest Tailwind or PostCSS may take for a page
+const stylesheetTimeout = time.Minute
+
+// The tools a component's CSS is processed with
+const (
+	styleToolNone     = ""
+	styleToolTailwind = "tailwind"
+	styleToolPostCSS  = "postcss"
+)
+
+// Config files that turn on a component directory's CSS processing
+var (
+	tailwindConfigs = []string{"tailwind.config.js", "tailwind.config.cjs", "tailwind.config.mjs", "tailwind.config.ts"}
+	postcssConfigs  = []string{"postcss.config.js", "postcss.config.cjs", "postcss.config.mjs", ".postcssrc", ".postcssrc.json"}
+)
+
+// tailwindDirective matches the CSS that only means something to Tailwind
+var tailwindDirective = regexp.MustCompile(`@tailwind\s|@apply\s|@import\s+["']tailwindcss`)
+
+// defaultTailwindCSS is what Tailwind builds from when a component has a
+// tailwind.config but imports no CSS
+const defaultTailwindCSS = "@tailwind base;\n@tailwind components;\n@tailwind utilities;\n"
+
+// stylesheet is a component's processed CSS and what it was processed from
+type stylesheet struct {
+	key string
+	css string
+}
+
+// stylesheets processes the CSS of /render/ components with Tailwind or
+// PostCSS when their directory has a config for one, or their CSS has
+// Tailwind directives, and keeps the last stylesheet of each component
+type stylesheets struct {
+	cfg config.CodeConfig
+
+	mu      sync.Mutex
+	entries map[string]stylesheet // By source path
+
+	// run runs a command in dir with stdin and returns its output; nil runs it
+	run func(ctx context.Context, dir string, command []string, stdin []byte) ([]byte, error)
+}
+
+func newStylesheets(cfg config.CodeConfig) *stylesheets {
+	return &stylesheets{cfg: cfg, entries: make(map[string]stylesheet)}
+}
+
+// Build returns the stylesheet for the page of a built component. The CSS
+// it imports is returned as esbuild bundled it unless it needs Tailwind or
+// PostCSS, which are run on it only when it or a file it's built from changed.
+func (s *stylesheets) Build(srcPath string, result buildResult) (string, error) {
+	dir := filepath.Dir(srcPath)
+	tool, configPath := styleTool(dir, result.CSS)
+	if tool == styleToolNone {
+		return string(result.CSS), nil
+	}
+
+	input := result.CSS
+	if len(bytes.TrimSpace(input)) == 0 {
+		if tool != styleToolTailwind {
+			return "", nil
+		}
+		input = []byte(defaultTailwindCSS)
+	}
+	content := contentFiles(result.Inputs)
+	key := stylesheetKey(tool, configPath, input, result.Inputs)
+
+	s.mu.Lock()
+	cached, ok := s.entries[srcPath]
+	s.mu.Unlock()
+	if ok && cached.key == key {
+		return cached.css, nil
+	}
+
+	start := time.Now()
+	css, err := s.process(dir, tool, configPath, input, content)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.entries[srcPath] = stylesheet{key: key, css: css}
+	s.mu.Unlock()
+
+	slog.Info("Processed component stylesheet", "path", srcPath, "tool", tool, "duration", time.Since(start), "action", "code_stylesheet")
+	return css, nil
+}
+
+// process runs Tailwind or PostCSS on a component's CSS
+func (s *stylesheets) process(dir, tool, configPath string, input []byte, content []string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), stylesheetTimeout)
+	defer cancel()
+	run := s.run
+	if run == nil {
+		run = runStyleCommand
+	}
+
+	var command []string
+	var stdin []byte
+	switch tool {
+	case styleToolTailwind:
+		// The Tailwind CLI reads its input from a file
+		inputFile, err := os.CreateTemp("", "flow-tailwind-*.css")
+		if err != nil {
+			return "", fmt.Errorf("failed to create Tailwind input: %w", err)
+		}
+		defer os.Remove(inputFile.Name())
+		_, err = inputFile.Write(input)
+		inputFile.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to write Tailwind input: %w", err)
+		}
+
+		command = append(strings.Fields(s.tailwindCommand()), "--input", inputFile.Name(), "--minify")
+		if len(content) > 0 {
+			command = append(command, "--content", strings.Join(content, ","))
+		}
+		if configPath != "" {
+			command = append(command, "--config", filepath.Base(configPath))
+		}
+	case styleToolPostCSS:
+		command = append(strings.Fields(s.postcssCommand()), "--config", ".")
+		stdin = input
+	}
+
+	output, err := run(ctx, dir, command, stdin)
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s: %w", tool, err)
+	}
+	return string(output), nil
+}
+
+func (s *stylesheets) tailwindCommand() string {
+	if s.cfg.TailwindCommand != "" {
+		return s.cfg.TailwindCommand
+	}
+	return "npx tailwindcss"
+}
+
+func (s *stylesheets) postcssCommand() string {
+	if s.cfg.PostCSSCommand != "" {
+		return s.cfg.PostCSSCommand
+	}
+	return "npx postcss"
+}
+
+// runStyleCommand runs a CSS tool, returning what it writes to stdout or
+// the end of what it writes to stderr when it fails
+func runStyleCommand(ctx context.Context, dir string, command []string, stdin []byte) ([]byte, error) {
+	if len(command) == 0 {
+		return nil, fmt.Errorf("no command configured")
+	}
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, clipOutput(stderr.Bytes()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// styleTool returns what processes the CSS of the components in dir: PostCSS
+// or Tailwind when dir has a config for one, or else Tailwind when the CSS
+// has its directives
+func styleTool(dir string, css []byte) (string, string) {
+	if path, ok := findConfig(dir, postcssConfigs); ok {
+		return styleToolPostCSS, path
+	}
+	if path, ok := findConfig(dir, tailwindConfigs); ok {
+		return styleToolTailwind, path
+	}
+	if tailwindDirective.Match(css) {
+		return styleToolTailwind, ""
+	}
+	return styleToolNone, ""
+}
+
+func findConfig(dir string, names []string) (string, bool) {
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// contentFiles returns the files a build read that Tailwind looks for class
+// names in, as absolute paths since the CLI runs in the component's directory
+func contentFiles(inputs map[string]string) []string {
+	var content []string
+	for path := range inputs {
+		if strings.HasSuffix(path, ".css") || strings.Contains(path, "node_modules") {
+			continue
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			content = append(content, abs)
+		}
+	}
+	sort.Strings(content)
+	return content
+}
+
+// stylesheetKey hashes what a stylesheet is processed from: the tool and its
+// config, the CSS, and the files class names come from
+func stylesheetKey(tool, configPath string, css []byte, inputs map[string]string) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%s\x00", tool, configPath)
+	if configPath != "" {
+		if data, err := os.ReadFile(configPath); err == nil {
+			hash.Write(data)
+		}
+	}
+	hash.Write([]byte{0})
+	hash.Write(css)
+
+	paths := make([]string, 0, len(inputs))
+	for path := range inputs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(hash, "\x00%s=%s", path, inputs[path])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
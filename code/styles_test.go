@@ -0,0 +1,164 @@
+package code
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/breadchris/flow/config"
+)
+
+// styleRun is a command a fake CSS tool was run with
+type styleRun struct {
+	dir     string
+	command []string
+	input   string // What it was given on stdin or with --input
+}
+
+// fakeStyleTool records the commands it's run with and returns css
+func fakeStyleTool(styles *stylesheets, css string) *[]styleRun {
+	var runs []styleRun
+	styles.run = func(ctx context.Context, dir string, command []string, stdin []byte) ([]byte, error) {
+		run := styleRun{dir: dir, command: command, input: string(stdin)}
+		if i := slices.Index(command, "--input"); i >= 0 {
+			data, err := os.ReadFile(command[i+1])
+			if err != nil {
+				return nil, err
+			}
+			run.input = string(data)
+		}
+		runs = append(runs, run)
+		return []byte(css), nil
+	}
+	return &runs
+}
+
+// writeStyledComponent writes an App.tsx with a class name that imports
+// app.css when css isn't empty, and builds it
+func writeStyledComponent(t *testing.T, dir, className, css string) buildResult {
+	t.Helper()
+	source := "export default function App() {\n  return <div className=\"" + className + "\">Hi</div>;\n}\n"
+	if css != "" {
+		if err := os.WriteFile(filepath.Join(dir, "app.css"), []byte(css), 0644); err != nil {
+			t.Fatal(err)
+		}
+		source = "import \"./app.css\";\n" + source
+	}
+	srcPath := filepath.Join(dir, "App.tsx")
+	if err := os.WriteFile(srcPath, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result := newBuildCache(config.CodeConfig{}).Build("render", srcPath, []byte(source), moduleBuildOptions(srcPath, []byte(source)), false)
+	if len(result.Errors) > 0 {
+		t.Fatalf("build failed: %+v", result.Errors)
+	}
+	return result
+}
+
+func TestStylesheetWithoutTailwindIsTheImportedCSS(t *testing.T) {
+	dir := t.TempDir()
+	result := writeStyledComponent(t, dir, "title", ".title { color: red; }")
+	styles := newStylesheets(config.CodeConfig{})
+	runs := fakeStyleTool(styles, "")
+
+	css, err := styles.Build(filepath.Join(dir, "App.tsx"), result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(css, "color: red") {
+		t.Errorf("stylesheet = %q; want app.css", css)
+	}
+	if len(*runs) != 0 {
+		t.Errorf("ran %v for plain CSS", *runs)
+	}
+}
+
+func TestStylesheetRunsTailwindOnDirectives(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "App.tsx")
+	result := writeStyledComponent(t, dir, "p-4", "@tailwind utilities;\n.btn { @apply px-4; }\n")
+	styles := newStylesheets(config.CodeConfig{TailwindCommand: "tailwindcss"})
+	runs := fakeStyleTool(styles, ".p-4{padding:1rem}")
+
+	for i := 0; i < 2; i++ {
+		css, err := styles.Build(srcPath, result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if css != ".p-4{padding:1rem}" {
+			t.Errorf("stylesheet = %q; want Tailwind's output", css)
+		}
+	}
+	if len(*runs) != 1 {
+		t.Fatalf("Tailwind ran %d times for an unchanged component; want 1", len(*runs))
+	}
+	run := (*runs)[0]
+	if run.command[0] != "tailwindcss" || run.dir != dir || !strings.Contains(run.input, "@tailwind utilities") {
+		t.Errorf("ran %v in %s with %q; want tailwindcss on app.css", run.command, run.dir, run.input)
+	}
+	content := run.command[slices.Index(run.command, "--content")+1]
+	if abs, _ := filepath.Abs(srcPath); !strings.Contains(content, abs) {
+		t.Errorf("content = %s; want App.tsx", content)
+	}
+
+	// A new class name in the component needs a new stylesheet
+	result = writeStyledComponent(t, dir, "p-8", "@tailwind utilities;\n.btn { @apply px-4; }\n")
+	if _, err := styles.Build(srcPath, result); err != nil {
+		t.Fatal(err)
+	}
+	if len(*runs) != 2 {
+		t.Errorf("Tailwind ran %d times after the component changed; want 2", len(*runs))
+	}
+}
+
+func TestStylesheetUsesTheDirectoryConfig(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "App.tsx")
+	result := writeStyledComponent(t, dir, "p-4", "")
+	if err := os.WriteFile(filepath.Join(dir, "tailwind.config.js"), []byte("module.exports = {};"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	styles := newStylesheets(config.CodeConfig{})
+	runs := fakeStyleTool(styles, ".p-4{padding:1rem}")
+
+	if _, err := styles.Build(srcPath, result); err != nil {
+		t.Fatal(err)
+	}
+	run := (*runs)[0]
+	if strings.Join(run.command[:2], " ") != "npx tailwindcss" || run.input != defaultTailwindCSS {
+		t.Errorf("ran %v with %q; want npx tailwindcss with its default directives", run.command, run.input)
+	}
+	if i := slices.Index(run.command, "--config"); i < 0 || run.command[i+1] != "tailwind.config.js" {
+		t.Errorf("command = %v; want the directory's tailwind.config.js", run.command)
+	}
+
+	// A PostCSS config takes over, and is given the CSS on stdin
+	result = writeStyledComponent(t, dir, "p-4", ".title { color: red; }")
+	if err := os.WriteFile(filepath.Join(dir, "postcss.config.js"), []byte("module.exports = {};"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := styles.Build(srcPath, result); err != nil {
+		t.Fatal(err)
+	}
+	run = (*runs)[1]
+	if strings.Join(run.command, " ") != "npx postcss --config ." || !strings.Contains(run.input, "color: red") {
+		t.Errorf("ran %v with %q; want npx postcss on app.css", run.command, run.input)
+	}
+}
+
+func TestStylesheetReportsToolErrors(t *testing.T) {
+	dir := t.TempDir()
+	result := writeStyledComponent(t, dir, "p-4", "@tailwind utilities;")
+	styles := newStylesheets(config.CodeConfig{})
+	styles.run = func(ctx context.Context, dir string, command []string, stdin []byte) ([]byte, error) {
+		return nil, errors.New("exit status 1: CssSyntaxError")
+	}
+
+	if _, err := styles.Build(filepath.Join(dir, "App.tsx"), result); err == nil || !strings.Contains(err.Error(), "CssSyntaxError") {
+		t.Errorf("error = %v; want Tailwind's error", err)
+	}
+}
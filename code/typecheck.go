@@ -0,0 +1,297 @@
+package code
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/breadchris/flow/config"
+)
+
+// typeCheckTimeout is the longest tsc may take to check a component
+const typeCheckTimeout = time.Minute
+
+// defaultTSCFlags check a component without a tsconfig.json the way esbuild
+// builds it
+var defaultTSCFlags = []string{
+	"--jsx", "react-jsx",
+	"--target", "esnext",
+	"--module", "esnext",
+	"--moduleResolution", "bundler",
+	"--allowJs",
+	"--esModuleInterop",
+	"--skipLibCheck",
+	"--isolatedModules",
+	"--allowImportingTsExtensions",
+}
+
+// ignoredTypeErrors are the tsc errors about imports it can't find types
+// for, which esbuild resolves or the page loads from the CDN
+var ignoredTypeErrors = []string{"TS2307", "TS2875", "TS7016"}
+
+// tscDiagnostic matches a line of tsc --pretty false output, such as
+// "App.tsx(3,7): error TS2322: Type 'number' is not assignable to type 'string'."
+var tscDiagnostic = regexp.MustCompile(`^(.+?)\((\d+),(\d+)\): (error|warning|message) (TS\d+): (.*)$`)
+
+// TypeDiagnostic is an error tsc found in a component or a file it imports
+type TypeDiagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`   // 1-based
+	Column   int    `json:"column"` // 1-based
+	Code     string `json:"code"`   // Such as "TS2322"
+	Category string `json:"category"`
+	Message  string `json:"message"`
+}
+
+func (d TypeDiagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s %s: %s", d.File, d.Line, d.Column, d.Category, d.Code, d.Message)
+}
+
+// TypeCheckResponse is the result of type-checking a component
+type TypeCheckResponse struct {
+	Diagnostics []TypeDiagnostic `json:"diagnostics"`
+}
+
+// typeCheck is a component's last diagnostics and what they were found in
+type typeCheck struct {
+	key         string
+	diagnostics []TypeDiagnostic
+}
+
+// TypeChecker runs tsc on components, since esbuild strips their types
+// without checking them, and keeps the last diagnostics of each component
+type TypeChecker struct {
+	cfg config.CodeConfig
+
+	mu      sync.Mutex
+	locks   map[string]*sync.Mutex // By source path
+	entries map[string]typeCheck   // By source path
+
+	// run runs a command in dir and returns its output; nil runs it
+	run func(ctx context.Context, dir string, command []string) ([]byte, error)
+}
+
+func NewTypeChecker(cfg config.CodeConfig) *TypeChecker {
+	return &TypeChecker{cfg: cfg, locks: make(map[string]*sync.Mutex), entries: make(map[string]typeCheck)}
+}
+
+// Check type-checks the component at srcPath with the tsconfig.json in its
+// directory, or else the way esbuild builds it. inputs are the files a build
+// of it read, when there's been one; it's checked again only when it or one
+// of them changes.
+func (t *TypeChecker) Check(srcPath string, inputs map[string]string) ([]TypeDiagnostic, error) {
+	t.mu.Lock()
+	lock, ok := t.locks[srcPath]
+	if !ok {
+		lock = &sync.Mutex{}
+		t.locks[srcPath] = lock
+	}
+	t.mu.Unlock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	dir := filepath.Dir(srcPath)
+	configPath, _ := findConfig(dir, []string{"tsconfig.json"})
+	key, err := typeCheckKey(srcPath, configPath, inputs)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	cached, ok := t.entries[srcPath]
+	t.mu.Unlock()
+	if ok && cached.key == key {
+		return cached.diagnostics, nil
+	}
+
+	start := time.Now()
+	diagnostics, err := t.tsc(dir, filepath.Base(srcPath), configPath)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	t.entries[srcPath] = typeCheck{key: key, diagnostics: diagnostics}
+	t.mu.Unlock()
+
+	slog.Info("Type-checked component", "path", srcPath, "diagnostics", len(diagnostics), "duration", time.Since(start), "action", "code_typecheck")
+	return diagnostics, nil
+}
+
+// tsc runs tsc in dir on a component, or on the project of its tsconfig.json
+func (t *TypeChecker) tsc(dir, file, configPath string) ([]TypeDiagnostic, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), typeCheckTimeout)
+	defer cancel()
+	run := t.run
+	if run == nil {
+		run = runTSC
+	}
+
+	command := append(strings.Fields(t.tscCommand()), "--noEmit", "--pretty", "false")
+	if configPath != "" {
+		command = append(command, "--project", filepath.Base(configPath))
+	} else {
+		command = append(append(command, defaultTSCFlags...), file)
+	}
+
+	// tsc fails when it finds errors, so it only failed to run when none
+	// were reported
+	output, err := run(ctx, dir, command)
+	diagnostics, reported := parseTSCOutput(dir, output)
+	if err != nil && reported == 0 {
+		return nil, fmt.Errorf("failed to run tsc: %w", err)
+	}
+	return diagnostics, nil
+}
+
+func (t *TypeChecker) tscCommand() string {
+	if t.cfg.TSCCommand != "" {
+		return t.cfg.TSCCommand
+	}
+	return "npx tsc"
+}
+
+// runTSC runs tsc, returning the diagnostics it writes to stdout, and the end
+// of stderr with its error when it fails
+func runTSC(ctx context.Context, dir string, command []string) ([]byte, error) {
+	if len(command) == 0 {
+		return nil, fmt.Errorf("no command configured")
+	}
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.Bytes(), fmt.Errorf("%w: %s", err, clipOutput(stderr.Bytes()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// parseTSCOutput returns the diagnostics in tsc output, with their files
+// joined to the directory tsc ran in, and how many tsc reported, ignored
+// ones included. Lines that continue a message are added to it.
+func parseTSCOutput(dir string, output []byte) ([]TypeDiagnostic, int) {
+	diagnostics := []TypeDiagnostic{}
+	reported := 0
+	ignored := false
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimRight(line, "\r")
+		match := tscDiagnostic.FindStringSubmatch(line)
+		if match == nil {
+			if strings.HasPrefix(line, " ") && !ignored && len(diagnostics) > 0 {
+				last := &diagnostics[len(diagnostics)-1]
+				last.Message += "\n" + strings.TrimSpace(line)
+			}
+			continue
+		}
+
+		reported++
+		ignored = slices.Contains(ignoredTypeErrors, match[5])
+		if ignored {
+			continue
+		}
+		file := match[1]
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		lineNum, _ := strconv.Atoi(match[2])
+		column, _ := strconv.Atoi(match[3])
+		diagnostics = append(diagnostics, TypeDiagnostic{
+			File:     filepath.ToSlash(file),
+			Line:     lineNum,
+			Column:   column,
+			Code:     match[5],
+			Category: match[4],
+			Message:  match[6],
+		})
+	}
+	return diagnostics, reported
+}
+
+// typeCheckKey hashes what a component is type-checked from: it, its
+// tsconfig.json, and the files a build of it read
+func typeCheckKey(srcPath, configPath string, inputs map[string]string) (string, error) {
+	hash := sha256.New()
+	for _, path := range []string{srcPath, configPath} {
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00", path, len(data))
+		hash.Write(data)
+	}
+
+	paths := make([]string, 0, len(inputs))
+	for path := range inputs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(hash, "\x00%s=%s", path, inputs[path])
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// handleTypeCheck type-checks a component and returns tsc's diagnostics
+func handleTypeCheck(builds *buildCache, types *TypeChecker, w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract path from URL
+	componentPath := strings.TrimPrefix(r.URL.Path, "/typecheck/")
+	if componentPath == "" {
+		http.Error(w, "Component path is required", http.StatusBadRequest)
+		return
+	}
+
+	// Validate and sanitize the path
+	cleanPath := filepath.Clean(componentPath)
+	if strings.Contains(cleanPath, "..") {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+	srcPath := filepath.Join("./", cleanPath)
+
+	sourceCode, err := os.ReadFile(srcPath)
+	if os.IsNotExist(err) {
+		http.Error(w, "Source file not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read source file: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// A build of the component says which files to check it again after
+	var inputs map[string]string
+	if result := builds.Build("module", srcPath, sourceCode, moduleBuildOptions(srcPath, sourceCode), false); len(result.Errors) == 0 {
+		inputs = result.Inputs
+	}
+	diagnostics, err := types.Check(srcPath, inputs)
+	if err != nil {
+		slog.Error("Type check failed", "path", componentPath, "error", err)
+		http.Error(w, fmt.Sprintf("Failed to type-check component: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TypeCheckResponse{Diagnostics: diagnostics})
+}
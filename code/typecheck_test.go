@@ -0,0 +1,101 @@
+package code
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/breadchris/flow/config"
+)
+
+// fakeTSC records the commands a TypeChecker runs and returns output
+func fakeTSC(types *TypeChecker, output string, err error) *[][]string {
+	var runs [][]string
+	types.run = func(ctx context.Context, dir string, command []string) ([]byte, error) {
+		runs = append(runs, command)
+		return []byte(output), err
+	}
+	return &runs
+}
+
+func TestTypeCheckReportsTSCErrors(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "App.tsx")
+	if err := os.WriteFile(srcPath, []byte("const n: string = 1;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	types := NewTypeChecker(config.CodeConfig{TSCCommand: "tsc"})
+	output := "App.tsx(1,7): error TS2322: Type 'number' is not assignable to type 'string'.\n" +
+		"App.tsx(2,1): error TS2307: Cannot find module 'date-fns' or its corresponding type declarations.\n" +
+		"lib/greet.ts(4,3): error TS2345: Argument of type 'string' is not assignable to parameter of type 'number'.\n" +
+		"  Type 'string' is not assignable to type 'number'.\n"
+	runs := fakeTSC(types, output, errors.New("exit status 2"))
+
+	for i := 0; i < 2; i++ {
+		diagnostics, err := types.Check(srcPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(diagnostics) != 2 {
+			t.Fatalf("diagnostics = %+v; want the two errors that aren't about missing modules", diagnostics)
+		}
+		want := TypeDiagnostic{File: filepath.ToSlash(srcPath), Line: 1, Column: 7, Code: "TS2322", Category: "error", Message: "Type 'number' is not assignable to type 'string'."}
+		if diagnostics[0] != want {
+			t.Errorf("diagnostic = %+v; want %+v", diagnostics[0], want)
+		}
+		if got := diagnostics[1]; got.File != filepath.ToSlash(filepath.Join(dir, "lib", "greet.ts")) || !strings.HasSuffix(got.Message, "\nType 'string' is not assignable to type 'number'.") {
+			t.Errorf("diagnostic = %+v; want lib/greet.ts with its continued message", got)
+		}
+	}
+	if len(*runs) != 1 {
+		t.Fatalf("tsc ran %d times for an unchanged component; want 1", len(*runs))
+	}
+	if command := (*runs)[0]; command[0] != "tsc" || !slices.Contains(command, "--noEmit") || command[len(command)-1] != "App.tsx" {
+		t.Errorf("command = %v; want tsc --noEmit on App.tsx", command)
+	}
+
+	// A change to a file the component imports checks it again
+	types.Check(srcPath, map[string]string{"lib/greet.ts": "changed"})
+	if len(*runs) != 2 {
+		t.Errorf("tsc ran %d times after an import changed; want 2", len(*runs))
+	}
+}
+
+func TestTypeCheckUsesTheDirectoryTSConfig(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "App.tsx")
+	if err := os.WriteFile(srcPath, []byte("export default 1;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tsconfig.json"), []byte(`{"compilerOptions": {"strict": true}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	types := NewTypeChecker(config.CodeConfig{})
+	runs := fakeTSC(types, "", nil)
+
+	diagnostics, err := types.Check(srcPath, nil)
+	if err != nil || len(diagnostics) != 0 {
+		t.Fatalf("Check = %+v, %v; want no diagnostics", diagnostics, err)
+	}
+	if got := strings.Join((*runs)[0], " "); got != "npx tsc --noEmit --pretty false --project tsconfig.json" {
+		t.Errorf("command = %s; want the project of tsconfig.json", got)
+	}
+}
+
+func TestTypeCheckFailsWhenTSCDoesntRun(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "App.tsx")
+	if err := os.WriteFile(srcPath, []byte("export default 1;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	types := NewTypeChecker(config.CodeConfig{})
+	fakeTSC(types, "", errors.New("exec: \"npx\": executable file not found in $PATH"))
+
+	if _, err := types.Check(srcPath, nil); err == nil || !strings.Contains(err.Error(), "executable file not found") {
+		t.Errorf("error = %v; want tsc's failure", err)
+	}
+}
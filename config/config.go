@@ -41,19 +41,27 @@ type ProxyConfig struct {
 }
 
 type SlackBotConfig struct {
-	Enabled              bool          `json:"enabled"`
-	SlackAppID           string        `json:"app_id"`
-	SlackClientID        string        `json:"client_id"`
-	SlackClientSecret    string        `json:"client_secret"`
-	SlackSigningSecret   string        `json:"signing_secret"`
-	SlackToken           string        `json:"token"`
-	BotToken             string        `json:"bot_token"`
-	SessionTimeout       time.Duration `json:"session_timeout"`
-	MaxSessions          int           `json:"max_sessions"`
-	WorkingDirectory     string        `json:"working_directory"`
-	Debug                bool          `json:"debug"`
-	ChannelWhitelist     []string      `json:"channel_whitelist"`
-	
+	Enabled                bool              `json:"enabled"`
+	SlackAppID             string            `json:"app_id"`
+	SlackClientID          string            `json:"client_id"`
+	SlackClientSecret      string            `json:"client_secret"`
+	SlackSigningSecret     string            `json:"signing_secret"`
+	SlackToken             string            `json:"token"`
+	BotToken               string            `json:"bot_token"`
+	SessionTimeout         time.Duration     `json:"session_timeout"`
+	MaxSessions            int               `json:"max_sessions"`
+	WorkingDirectory       string            `json:"working_directory"`
+	Debug                  bool              `json:"debug"`
+	ChannelWhitelist       []string          `json:"channel_whitelist"`
+	ChannelDenylist        []string          `json:"channel_denylist"`         // Channel patterns the bot never works in, even when whitelisted
+	ReadOnlyChannels       []string          `json:"read_only_channels"`       // Channel ID patterns whose sessions can't write files or run commands
+	Admins                 []string          `json:"admins"`                   // Slack user IDs with the admin role, which no grant can take away
+	Roles                  map[string]string `json:"roles"`                    // Slack user ID to admin, developer, or read_only
+	DefaultRole            string            `json:"default_role"`             // Role of users without one; "none" limits the bot to users given a role
+	SummarizeAfterTurns    int               `json:"summarize_after_turns"`    // Turns after which a thread's conversation is summarized and continued from the summary; 0 disables
+	IssueRepo              string            `json:"issue_repo"`               // GitHub repository /flow issue files issues in, as owner/name, unless the channel sets one
+	MentionContextMessages int               `json:"mention_context_messages"` // Earlier messages of a thread Claude is mentioned in given to its new session; 0 gives none
+
 	// Ideation settings
 	IdeationEnabled      bool          `json:"ideation_enabled"`
 	IdeationTimeout      time.Duration `json:"ideation_timeout"`
@@ -62,15 +70,129 @@ type SlackBotConfig struct {
 }
 
 type ClaudeConfig struct {
-	Debug    bool     `json:"debug"`
-	DebugDir string   `json:"debug_dir"`
-	Tools    []string `json:"tools"`
+	Debug         bool                   `json:"debug"`
+	DebugDir      string                 `json:"debug_dir"`
+	Tools         []string               `json:"tools"`
+	Model         string                 `json:"model"`          // Model passed to the CLI with --model; empty uses the CLI's default
+	FallbackModel string                 `json:"fallback_model"` // Model to retry on when the API rate limits a turn; empty disables fallback
+	Pool          ClaudePoolConfig       `json:"pool"`
+	Supervisor    ClaudeSupervisorConfig `json:"supervisor"`
+	Scheduler     ClaudeSchedulerConfig  `json:"scheduler"`
+	Sandbox       ClaudeSandboxConfig    `json:"sandbox"`
+	Budget        ClaudeBudgetConfig     `json:"budget"`
+	Approval      ClaudeApprovalConfig   `json:"approval"`
+	Storage       ClaudeStorageConfig    `json:"storage"`
+	Output        ClaudeOutputConfig     `json:"output"`
+	Personas      map[string]string      `json:"personas"` // System prompts by lowercase persona name
+}
+
+// ClaudePoolConfig controls the pool of pre-warmed Claude CLI processes
+type ClaudePoolConfig struct {
+	Size    int           `json:"size"`     // Idle processes to keep warm; 0 disables the pool
+	MaxIdle time.Duration `json:"max_idle"` // Idle processes older than this are recycled
+}
+
+// ClaudeSupervisorConfig controls health checks of running Claude CLI processes
+type ClaudeSupervisorConfig struct {
+	Interval    time.Duration `json:"interval"`     // Time between health checks; 0 disables the supervisor
+	HungTimeout time.Duration `json:"hung_timeout"` // A turn with no output for this long is restarted
+	IdleTimeout time.Duration `json:"idle_timeout"` // A session idle for this long is hibernated; 0 keeps processes running
+}
+
+// ClaudeSchedulerConfig caps how many Claude CLI processes run at once
+type ClaudeSchedulerConfig struct {
+	MaxProcesses int           `json:"max_processes"` // Running processes across all users; 0 is unlimited
+	MaxPerUser   int           `json:"max_per_user"`  // Running processes per user; 0 is unlimited
+	QueueTimeout time.Duration `json:"queue_timeout"` // How long a session waits for a slot; 0 waits forever
+}
+
+// ClaudeBudgetConfig caps how much work a Claude session does before it pauses
+// until the user continues it. Zero values are unlimited.
+type ClaudeBudgetConfig struct {
+	MaxTokens   int64         `json:"max_tokens"`   // Input and output tokens, not counting cache reads
+	MaxTurns    int           `json:"max_turns"`    // Prompts answered
+	MaxDuration time.Duration `json:"max_duration"` // Wall-clock time since the session started or was continued
+}
+
+// ClaudeApprovalConfig holds Bash commands matching a pattern until a user approves them
+type ClaudeApprovalConfig struct {
+	Patterns []string      `json:"patterns"` // Regular expressions matched against the command; empty disables approvals
+	Timeout  time.Duration `json:"timeout"`  // A command nobody answers within this long is denied
+}
+
+// ClaudeStorageConfig limits the disk used by session directories under data/session
+type ClaudeStorageConfig struct {
+	Interval       time.Duration `json:"interval"`         // Time between sweeps; 0 disables the janitor
+	SessionQuotaMB int64         `json:"session_quota_mb"` // Largest a session directory may grow; 0 is unlimited
+	TotalQuotaMB   int64         `json:"total_quota_mb"`   // Space all session directories may use; 0 is unlimited
+	ExpireAfter    time.Duration `json:"expire_after"`     // Directories unchanged this long are archived; 0 keeps them
+	ArchiveDir     string        `json:"archive_dir"`      // Where archived directories are kept as .tar.gz; empty deletes them instead
+	ArchiveTTL     time.Duration `json:"archive_ttl"`      // Archives older than this are deleted; 0 keeps them
+}
+
+// ClaudeOutputConfig names the post-processors each consumer runs on Claude's
+// output, in order: redact_secrets, profanity, markdown, and truncate
+type ClaudeOutputConfig struct {
+	Slack     []string `json:"slack"`      // Run by the Slack bot
+	HTTP      []string `json:"http"`       // Run by the WebSocket and RPC APIs
+	MaxLength int      `json:"max_length"` // Characters kept by truncate
+	Profanity []string `json:"profanity"`  // Words masked by profanity; empty uses a built-in list
+}
+
+// ClaudeSandboxConfig limits what Claude CLI processes, and the commands Claude runs, may use.
+// Zero limits are unlimited.
+type ClaudeSandboxConfig struct {
+	Mode          string        `json:"mode"`             // "" runs the CLI directly; "systemd", "firejail", or "docker" wraps it
+	User          string        `json:"user"`             // Unprivileged user to run the CLI as; empty keeps the server's user
+	Image         string        `json:"image"`            // Container image with the Claude CLI, for docker mode
+	Args          []string      `json:"args"`             // Extra flags for systemd-run, firejail, or docker run
+	MemoryMB      int           `json:"memory_mb"`        // Memory limit; an address-space ulimit outside systemd and docker
+	CPUs          float64       `json:"cpus"`             // CPU quota in cores; systemd and docker only
+	CPUTime       time.Duration `json:"cpu_time"`         // Total CPU time per process
+	MaxProcesses  int           `json:"max_processes"`    // Processes and threads the CLI and its commands may run
+	MaxOpenFiles  int           `json:"max_open_files"`   // Open file descriptors per process
+	MaxFileSizeMB int           `json:"max_file_size_mb"` // Largest file a process may write
 }
 
 type WorkletConfig struct {
-	BaseDir       string        `json:"base_dir"`
-	CleanupMaxAge time.Duration `json:"cleanup_max_age"`
-	MaxConcurrent int           `json:"max_concurrent"`
+	BaseDir        string                `json:"base_dir"`
+	CleanupMaxAge  time.Duration         `json:"cleanup_max_age"`
+	MaxConcurrent  int                   `json:"max_concurrent"`
+	PreviewDomain  string                `json:"preview_domain"`   // Worklets are served at {id}.<domain> when set
+	PreviewAccess  string                `json:"preview_access"`   // Access new worklets' previews get: "token" or "public"
+	CloneDepth     int                   `json:"clone_depth"`      // Commits of history new worklets clone unless they ask for another depth; 0 for all
+	PRPollInterval time.Duration         `json:"pr_poll_interval"` // How often open pull requests are checked for merges, closes, and CI results; 0 to stop
+	TestTimeout    time.Duration         `json:"test_timeout"`     // How long worklets' tests may run before they fail
+	IdleTimeout    time.Duration         `json:"idle_timeout"`     // How long a worklet may go without requests before it's put to sleep; 0 to keep them running
+	PortRangeStart int                   `json:"port_range_start"` // Host ports worklets' containers are published on
+	PortRangeEnd   int                   `json:"port_range_end"`
+	Artifacts      ArtifactStorageConfig `json:"artifacts"`      // Where worklets' build artifacts are published
+	CacheEnabled   bool                  `json:"cache_enabled"`  // Whether worklets share dependency cache volumes
+	CacheMaxSize   int64                 `json:"cache_max_size"` // Bytes the cache volumes may use in all before the largest are cleared
+	Webhook        WorkletWebhookConfig  `json:"webhook"`        // GitHub webhooks that start preview worklets
+}
+
+// WorkletWebhookConfig is which GitHub repositories' pushes and pull requests
+// start and update worklets through /api/worklet/hooks/github
+type WorkletWebhookConfig struct {
+	Secret string   `json:"secret"`  // Signs deliveries; webhooks are turned away when empty
+	Repos  []string `json:"repos"`   // Repositories, as owner/name, whose events are acted on
+	Forks  []string `json:"forks"`   // Forks, as owner/name, whose pull requests are deployed too; other forks' are ignored
+	UserID string   `json:"user_id"` // Owner of the worklets webhooks start
+}
+
+// ArtifactStorageConfig is the S3-compatible bucket worklets' artifacts are
+// uploaded to: Amazon S3, or Google Cloud Storage with HMAC keys
+type ArtifactStorageConfig struct {
+	Bucket          string        `json:"bucket"`   // Artifacts aren't published when empty
+	Endpoint        string        `json:"endpoint"` // Amazon S3 when empty; https://storage.googleapis.com for GCS
+	Region          string        `json:"region"`
+	AccessKeyID     string        `json:"access_key_id"`
+	SecretAccessKey string        `json:"secret_access_key"`
+	Prefix          string        `json:"prefix"`      // Start of every artifact's key
+	PublicURL       string        `json:"public_url"`  // Base of links to a public bucket; links are presigned when empty
+	LinkExpiry      time.Duration `json:"link_expiry"` // How long presigned links work, at most 7 days
+	MaxSize         int64         `json:"max_size"`    // Largest artifact uploaded, in bytes
 }
 
 type GitConfig struct {
@@ -78,6 +200,93 @@ type GitConfig struct {
 	BaseDir string `json:"base_dir"`
 }
 
+type MetricsConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Path     string `json:"path"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type DebugConfig struct {
+	Enabled     bool   `json:"enabled"`
+	Token       string `json:"token"`
+	HeapDumpDir string `json:"heap_dump_dir"`
+}
+
+type LoggingConfig struct {
+	Level     string            `json:"level"`
+	Format    string            `json:"format"`
+	Output    string            `json:"output"`
+	AddSource bool              `json:"add_source"`
+	Modules   map[string]string `json:"modules"`
+}
+
+type TLSConfig struct {
+	CertFile         string   `json:"cert_file"`
+	KeyFile          string   `json:"key_file"`
+	AutocertDomains  []string `json:"autocert_domains"`
+	AutocertCacheDir string   `json:"autocert_cache_dir"`
+	AutocertEmail    string   `json:"autocert_email"`
+	RedirectHTTP     bool     `json:"redirect_http"`
+	HTTPAddr         string   `json:"http_addr"`
+}
+
+type ServerConfig struct {
+	Addr              string        `json:"addr"`
+	ReadTimeout       time.Duration `json:"read_timeout"`
+	ReadHeaderTimeout time.Duration `json:"read_header_timeout"`
+	WriteTimeout      time.Duration `json:"write_timeout"`
+	IdleTimeout       time.Duration `json:"idle_timeout"`
+	ShutdownTimeout   time.Duration `json:"shutdown_timeout"` // How long in-flight work may run after SIGTERM
+	TLS               TLSConfig     `json:"tls"`
+}
+
+// RateLimitRule allows Requests per Window for each client; zero requests disables the rule
+type RateLimitRule struct {
+	Requests int           `json:"requests"`
+	Window   time.Duration `json:"window"`
+}
+
+type RateLimitConfig struct {
+	Enabled bool          `json:"enabled"`
+	Global  RateLimitRule `json:"global"`  // All API routes
+	Build   RateLimitRule `json:"build"`   // Code runner esbuild endpoints
+	Worklet RateLimitRule `json:"worklet"` // Worklet APIs, excluding the app proxy
+}
+
+type CORSConfig struct {
+	AllowedOrigins   []string      `json:"allowed_origins"` // Exact origins, or "*" for any origin
+	AllowedMethods   []string      `json:"allowed_methods"`
+	AllowedHeaders   []string      `json:"allowed_headers"`
+	ExposedHeaders   []string      `json:"exposed_headers"`
+	AllowCredentials bool          `json:"allow_credentials"`
+	MaxAge           time.Duration `json:"max_age"`
+}
+
+type CodeConfig struct {
+	CacheDir          string            `json:"cache_dir"`           // Where builds are kept between restarts; empty keeps them in memory only
+	CacheEntries      int               `json:"cache_entries"`       // Most builds kept in memory
+	BuildWorkers      int               `json:"build_workers"`       // Most esbuild builds run at once; 0 is one per CPU
+	BuildQueue        int               `json:"build_queue"`         // Most builds waiting for a worker before more are turned away with 429; 0 is no limit
+	BuildQueueTimeout time.Duration     `json:"build_queue_timeout"` // Longest a build waits for a worker before it's turned away with 429
+	Dependencies      string            `json:"dependencies"`        // How npm imports resolve: "bundle", "esm", or "npm"
+	DependencyDirs    map[string]string `json:"dependency_dirs"`     // Directory to how its npm imports resolve, overriding Dependencies
+	CDNURL            string            `json:"cdn_url"`             // Where "esm" imports are loaded from
+	NPMCommand        string            `json:"npm_command"`         // Installs "npm" dependencies
+	InstallTimeout    time.Duration     `json:"install_timeout"`     // Longest an npm install may take
+	TailwindCommand   string            `json:"tailwind_command"`    // Builds the CSS of components with a tailwind.config or Tailwind directives
+	PostCSSCommand    string            `json:"postcss_command"`     // Processes the CSS of components with a postcss.config
+	TypeCheck         bool              `json:"typecheck"`           // Whether /render/ pages and Claude sessions' component edits are type-checked with tsc
+	TSCCommand        string            `json:"tsc_command"`         // Type-checks components
+	GoCommand         string            `json:"go_command"`          // Builds the Go snippets run by /go/run
+	GoTimeout         time.Duration     `json:"go_timeout"`          // Longest a Go snippet may take to build and run
+	GoNetwork         bool              `json:"go_network"`          // Whether Go snippets may use the network
+	GoUser            string            `json:"go_user"`             // Unprivileged user Go snippets run as; without one only admins may run them
+	SnapshotDir       string            `json:"snapshot_dir"`        // Where /snapshot/ keeps component screenshots and DOM snapshots
+	ChromePath        string            `json:"chrome_path"`         // Chrome or Chromium binary that renders snapshots; empty looks for one on PATH
+	SnapshotBaseURL   string            `json:"snapshot_base_url"`   // Where Chrome loads /code/render/ pages from for snapshots; empty is this server on loopback
+}
+
 type AppConfig struct {
 	OpenAIKey          string        `json:"openai_key"`
 	SMTP               SMTPConfig    `json:"smtp"`
@@ -100,10 +309,17 @@ type AppConfig struct {
 	ClaudeDebug        bool          `json:"claude_debug"`
 
 	// New configuration sections
-	SlackBot SlackBotConfig `json:"slack_bot"`
-	Claude   ClaudeConfig   `json:"claude"`
-	Worklet  WorkletConfig  `json:"worklet"`
-	Git      GitConfig      `json:"git"`
+	SlackBot  SlackBotConfig  `json:"slack_bot"`
+	Claude    ClaudeConfig    `json:"claude"`
+	Worklet   WorkletConfig   `json:"worklet"`
+	Git       GitConfig       `json:"git"`
+	Metrics   MetricsConfig   `json:"metrics"`
+	Debug     DebugConfig     `json:"debug"`
+	Logging   LoggingConfig   `json:"logging"`
+	Server    ServerConfig    `json:"server"`
+	RateLimit RateLimitConfig `json:"rate_limit"`
+	CORS      CORSConfig      `json:"cors"`
+	Code      CodeConfig      `json:"code"`
 }
 
 func LoadConfig() AppConfig {
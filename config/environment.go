@@ -11,35 +11,167 @@ import (
 func setConfigDefaults(config *AppConfig) {
 	// SlackBot defaults
 	config.SlackBot = SlackBotConfig{
-		Enabled:             false,
-		SessionTimeout:      30 * time.Minute,
-		MaxSessions:         10,
-		WorkingDirectory:    "/tmp/slackbot",
-		Debug:               true,
-		IdeationEnabled:     true,
-		IdeationTimeout:     2 * time.Hour,
-		MaxIdeationSessions: 20,
-		AutoExpandThreshold: 2,
+		Enabled:                false,
+		SessionTimeout:         30 * time.Minute,
+		MaxSessions:            10,
+		WorkingDirectory:       "/tmp/slackbot",
+		Debug:                  true,
+		IdeationEnabled:        true,
+		IdeationTimeout:        2 * time.Hour,
+		MaxIdeationSessions:    20,
+		AutoExpandThreshold:    2,
+		DefaultRole:            "developer",
+		SummarizeAfterTurns:    40,
+		MentionContextMessages: 20,
 	}
 
 	// Claude defaults
 	config.Claude = ClaudeConfig{
-		Debug:    true,
-		DebugDir: "/tmp/claude",
-		Tools:    []string{"Read", "Write", "Bash"},
+		Debug:         true,
+		DebugDir:      "/tmp/claude",
+		Tools:         []string{"Read", "Write", "Bash"},
+		FallbackModel: "haiku",
+		Pool: ClaudePoolConfig{
+			Size:    0,
+			MaxIdle: 30 * time.Minute,
+		},
+		Supervisor: ClaudeSupervisorConfig{
+			Interval:    30 * time.Second,
+			HungTimeout: 10 * time.Minute,
+			IdleTimeout: 30 * time.Minute,
+		},
+		Scheduler: ClaudeSchedulerConfig{
+			MaxProcesses: 20,
+			MaxPerUser:   5,
+			QueueTimeout: 10 * time.Minute,
+		},
+		Approval: ClaudeApprovalConfig{
+			Patterns: []string{
+				`\brm\s+-[a-zA-Z]*([rR][a-zA-Z]*f|f[a-zA-Z]*[rR])`,
+				`\b(curl|wget)\b[^|]*\|\s*(sudo\s+)?(ba|z)?sh\b`,
+				`\bgit\s+push\b.*(\s--force\b|\s--force-with-lease\b|\s-f\b)`,
+			},
+			Timeout: 10 * time.Minute,
+		},
+		Storage: ClaudeStorageConfig{
+			Interval:       time.Hour,
+			SessionQuotaMB: 1024,
+			ExpireAfter:    7 * 24 * time.Hour,
+			ArchiveDir:     "./data/session-archive",
+			ArchiveTTL:     30 * 24 * time.Hour,
+		},
+		Output: ClaudeOutputConfig{
+			Slack:     []string{"redact_secrets", "markdown"},
+			HTTP:      []string{"redact_secrets"},
+			MaxLength: 4000,
+		},
+		Personas: map[string]string{
+			"reviewer": "You are acting as a code reviewer. Read the relevant code before commenting, point out bugs, risky changes, and missing tests, and suggest concrete fixes. Don't modify files unless asked to.",
+			"sre":      "You are acting as a site reliability engineer. Focus on reliability, observability, and operational risk: failure modes, timeouts, resource limits, logging, metrics, and safe rollout. Prefer small, reversible changes.",
+		},
 	}
 
 	// Worklet defaults
 	config.Worklet = WorkletConfig{
-		BaseDir:       "/tmp/worklet-repos",
-		CleanupMaxAge: 24 * time.Hour,
-		MaxConcurrent: 5,
+		BaseDir:        "/tmp/worklet-repos",
+		CleanupMaxAge:  24 * time.Hour,
+		MaxConcurrent:  5,
+		PreviewAccess:  "token",
+		PRPollInterval: 2 * time.Minute,
+		TestTimeout:    10 * time.Minute,
+		IdleTimeout:    30 * time.Minute,
+		PortRangeStart: 20000,
+		PortRangeEnd:   20999,
+		Artifacts: ArtifactStorageConfig{
+			Region:     "us-east-1",
+			Prefix:     "worklets/",
+			LinkExpiry: 7 * 24 * time.Hour,
+			MaxSize:    100 << 20,
+		},
+		CacheEnabled: true,
+		CacheMaxSize: 10 << 30,
+		Webhook: WorkletWebhookConfig{
+			UserID: "github",
+		},
 	}
 
 	// Git defaults
 	config.Git = GitConfig{
 		BaseDir: "/tmp/git-repos",
 	}
+
+	// Metrics defaults
+	config.Metrics = MetricsConfig{
+		Enabled: true,
+		Path:    "/metrics",
+	}
+
+	// Debug endpoint defaults
+	config.Debug = DebugConfig{
+		Enabled:     false,
+		HeapDumpDir: "/tmp/flow-heapdumps",
+	}
+
+	// Logging defaults
+	config.Logging = LoggingConfig{
+		Level:  "info",
+		Format: "text",
+		Output: "stderr",
+	}
+
+	// Server defaults; WriteTimeout stays disabled so WebSocket and
+	// streaming responses are not cut off
+	config.Server = ServerConfig{
+		Addr:              ":8082",
+		ReadTimeout:       30 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		ShutdownTimeout:   30 * time.Second,
+		TLS: TLSConfig{
+			AutocertCacheDir: "data/autocert",
+			HTTPAddr:         ":80",
+		},
+	}
+
+	// Rate limit defaults, per user or client IP
+	config.RateLimit = RateLimitConfig{
+		Enabled: true,
+		Global:  RateLimitRule{Requests: 600, Window: time.Minute},
+		Build:   RateLimitRule{Requests: 120, Window: time.Minute},
+		Worklet: RateLimitRule{Requests: 30, Window: time.Minute},
+	}
+
+	// CORS defaults; no origins are allowed until configured
+	config.CORS = CORSConfig{
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{
+			"Accept", "Authorization", "Content-Type", "X-Request-ID", "X-User-ID",
+			"Connect-Protocol-Version", "Connect-Timeout-Ms", "Grpc-Timeout", "X-Grpc-Web", "X-User-Agent",
+		},
+		ExposedHeaders: []string{
+			"X-Request-ID", "Retry-After",
+			"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin",
+		},
+		MaxAge: 10 * time.Minute,
+	}
+
+	// Code runner build cache defaults
+	config.Code = CodeConfig{
+		CacheDir:          "data/code-cache",
+		CacheEntries:      256,
+		BuildQueue:        64,
+		BuildQueueTimeout: 10 * time.Second,
+		Dependencies:      "bundle",
+		CDNURL:            "https://esm.sh",
+		NPMCommand:        "npm",
+		InstallTimeout:    5 * time.Minute,
+		TailwindCommand:   "npx tailwindcss",
+		PostCSSCommand:    "npx postcss",
+		TSCCommand:        "npx tsc",
+		GoCommand:         "go",
+		GoTimeout:         30 * time.Second,
+		SnapshotDir:       "data/code-snapshots",
+	}
 }
 
 // applyEnvOverrides applies environment variable overrides to the configuration
@@ -96,6 +228,34 @@ func applyEnvOverrides(config *AppConfig) {
 	if workingDir := os.Getenv("SLACKBOT_WORKING_DIRECTORY"); workingDir != "" {
 		config.SlackBot.WorkingDirectory = workingDir
 	}
+	if denylist := os.Getenv("SLACK_BOT_CHANNEL_DENYLIST"); denylist != "" {
+		config.SlackBot.ChannelDenylist = parseCommaSeparated(denylist)
+	}
+	if issueRepo := os.Getenv("SLACK_BOT_ISSUE_REPO"); issueRepo != "" {
+		config.SlackBot.IssueRepo = issueRepo
+	}
+	if readOnlyChannels := os.Getenv("SLACK_BOT_READ_ONLY_CHANNELS"); readOnlyChannels != "" {
+		config.SlackBot.ReadOnlyChannels = parseCommaSeparated(readOnlyChannels)
+	}
+	if admins := os.Getenv("SLACK_BOT_ADMINS"); admins != "" {
+		config.SlackBot.Admins = parseCommaSeparated(admins)
+	}
+	if roles := os.Getenv("SLACK_BOT_ROLES"); roles != "" {
+		config.SlackBot.Roles = parseKeyValuePairs(roles)
+	}
+	if defaultRole := os.Getenv("SLACK_BOT_DEFAULT_ROLE"); defaultRole != "" {
+		config.SlackBot.DefaultRole = defaultRole
+	}
+	if summarizeAfterTurnsStr := os.Getenv("SLACK_BOT_SUMMARIZE_AFTER_TURNS"); summarizeAfterTurnsStr != "" {
+		if summarizeAfterTurns, err := strconv.Atoi(summarizeAfterTurnsStr); err == nil {
+			config.SlackBot.SummarizeAfterTurns = summarizeAfterTurns
+		}
+	}
+	if mentionContextMessagesStr := os.Getenv("SLACK_BOT_MENTION_CONTEXT_MESSAGES"); mentionContextMessagesStr != "" {
+		if mentionContextMessages, err := strconv.Atoi(mentionContextMessagesStr); err == nil {
+			config.SlackBot.MentionContextMessages = mentionContextMessages
+		}
+	}
 
 	// Claude environment variables
 	if debugStr := os.Getenv("CLAUDE_DEBUG"); debugStr != "" {
@@ -108,6 +268,138 @@ func applyEnvOverrides(config *AppConfig) {
 		// Split comma-separated tools
 		config.Claude.Tools = parseCommaSeparated(tools)
 	}
+	if model := os.Getenv("CLAUDE_MODEL"); model != "" {
+		config.Claude.Model = model
+	}
+	if fallbackModel := os.Getenv("CLAUDE_FALLBACK_MODEL"); fallbackModel != "" {
+		config.Claude.FallbackModel = fallbackModel
+	}
+	if poolSizeStr := os.Getenv("CLAUDE_POOL_SIZE"); poolSizeStr != "" {
+		if poolSize, err := strconv.Atoi(poolSizeStr); err == nil {
+			config.Claude.Pool.Size = poolSize
+		}
+	}
+	if maxIdleStr := os.Getenv("CLAUDE_POOL_MAX_IDLE"); maxIdleStr != "" {
+		if maxIdle, err := time.ParseDuration(maxIdleStr); err == nil {
+			config.Claude.Pool.MaxIdle = maxIdle
+		}
+	}
+	if intervalStr := os.Getenv("CLAUDE_HEALTH_CHECK_INTERVAL"); intervalStr != "" {
+		if interval, err := time.ParseDuration(intervalStr); err == nil {
+			config.Claude.Supervisor.Interval = interval
+		}
+	}
+	if hungTimeoutStr := os.Getenv("CLAUDE_HUNG_TIMEOUT"); hungTimeoutStr != "" {
+		if hungTimeout, err := time.ParseDuration(hungTimeoutStr); err == nil {
+			config.Claude.Supervisor.HungTimeout = hungTimeout
+		}
+	}
+	if idleTimeoutStr := os.Getenv("CLAUDE_IDLE_TIMEOUT"); idleTimeoutStr != "" {
+		if idleTimeout, err := time.ParseDuration(idleTimeoutStr); err == nil {
+			config.Claude.Supervisor.IdleTimeout = idleTimeout
+		}
+	}
+	if maxProcessesStr := os.Getenv("CLAUDE_MAX_PROCESSES"); maxProcessesStr != "" {
+		if maxProcesses, err := strconv.Atoi(maxProcessesStr); err == nil {
+			config.Claude.Scheduler.MaxProcesses = maxProcesses
+		}
+	}
+	if maxPerUserStr := os.Getenv("CLAUDE_MAX_PROCESSES_PER_USER"); maxPerUserStr != "" {
+		if maxPerUser, err := strconv.Atoi(maxPerUserStr); err == nil {
+			config.Claude.Scheduler.MaxPerUser = maxPerUser
+		}
+	}
+	if queueTimeoutStr := os.Getenv("CLAUDE_QUEUE_TIMEOUT"); queueTimeoutStr != "" {
+		if queueTimeout, err := time.ParseDuration(queueTimeoutStr); err == nil {
+			config.Claude.Scheduler.QueueTimeout = queueTimeout
+		}
+	}
+	if maxTokensStr := os.Getenv("CLAUDE_BUDGET_MAX_TOKENS"); maxTokensStr != "" {
+		if maxTokens, err := strconv.ParseInt(maxTokensStr, 10, 64); err == nil {
+			config.Claude.Budget.MaxTokens = maxTokens
+		}
+	}
+	if maxTurnsStr := os.Getenv("CLAUDE_BUDGET_MAX_TURNS"); maxTurnsStr != "" {
+		if maxTurns, err := strconv.Atoi(maxTurnsStr); err == nil {
+			config.Claude.Budget.MaxTurns = maxTurns
+		}
+	}
+	if maxDurationStr := os.Getenv("CLAUDE_BUDGET_MAX_DURATION"); maxDurationStr != "" {
+		if maxDuration, err := time.ParseDuration(maxDurationStr); err == nil {
+			config.Claude.Budget.MaxDuration = maxDuration
+		}
+	}
+	if sandboxMode := os.Getenv("CLAUDE_SANDBOX_MODE"); sandboxMode != "" {
+		config.Claude.Sandbox.Mode = sandboxMode
+	}
+	if sandboxUser := os.Getenv("CLAUDE_SANDBOX_USER"); sandboxUser != "" {
+		config.Claude.Sandbox.User = sandboxUser
+	}
+	if sandboxImage := os.Getenv("CLAUDE_SANDBOX_IMAGE"); sandboxImage != "" {
+		config.Claude.Sandbox.Image = sandboxImage
+	}
+	if memoryStr := os.Getenv("CLAUDE_SANDBOX_MEMORY_MB"); memoryStr != "" {
+		if memory, err := strconv.Atoi(memoryStr); err == nil {
+			config.Claude.Sandbox.MemoryMB = memory
+		}
+	}
+	if cpusStr := os.Getenv("CLAUDE_SANDBOX_CPUS"); cpusStr != "" {
+		if cpus, err := strconv.ParseFloat(cpusStr, 64); err == nil {
+			config.Claude.Sandbox.CPUs = cpus
+		}
+	}
+	if maxProcessesStr := os.Getenv("CLAUDE_SANDBOX_MAX_PROCESSES"); maxProcessesStr != "" {
+		if maxProcesses, err := strconv.Atoi(maxProcessesStr); err == nil {
+			config.Claude.Sandbox.MaxProcesses = maxProcesses
+		}
+	}
+	if approvalPatterns := os.Getenv("CLAUDE_APPROVAL_PATTERNS"); approvalPatterns != "" {
+		config.Claude.Approval.Patterns = parseCommaSeparated(approvalPatterns)
+	}
+	if approvalTimeoutStr := os.Getenv("CLAUDE_APPROVAL_TIMEOUT"); approvalTimeoutStr != "" {
+		if approvalTimeout, err := time.ParseDuration(approvalTimeoutStr); err == nil {
+			config.Claude.Approval.Timeout = approvalTimeout
+		}
+	}
+	if storageIntervalStr := os.Getenv("CLAUDE_STORAGE_INTERVAL"); storageIntervalStr != "" {
+		if storageInterval, err := time.ParseDuration(storageIntervalStr); err == nil {
+			config.Claude.Storage.Interval = storageInterval
+		}
+	}
+	if sessionQuotaStr := os.Getenv("CLAUDE_SESSION_QUOTA_MB"); sessionQuotaStr != "" {
+		if sessionQuota, err := strconv.ParseInt(sessionQuotaStr, 10, 64); err == nil {
+			config.Claude.Storage.SessionQuotaMB = sessionQuota
+		}
+	}
+	if totalQuotaStr := os.Getenv("CLAUDE_TOTAL_QUOTA_MB"); totalQuotaStr != "" {
+		if totalQuota, err := strconv.ParseInt(totalQuotaStr, 10, 64); err == nil {
+			config.Claude.Storage.TotalQuotaMB = totalQuota
+		}
+	}
+	if expireAfterStr := os.Getenv("CLAUDE_SESSION_EXPIRE_AFTER"); expireAfterStr != "" {
+		if expireAfter, err := time.ParseDuration(expireAfterStr); err == nil {
+			config.Claude.Storage.ExpireAfter = expireAfter
+		}
+	}
+	if archiveDir := os.Getenv("CLAUDE_SESSION_ARCHIVE_DIR"); archiveDir != "" {
+		config.Claude.Storage.ArchiveDir = archiveDir
+	}
+	if archiveTTLStr := os.Getenv("CLAUDE_SESSION_ARCHIVE_TTL"); archiveTTLStr != "" {
+		if archiveTTL, err := time.ParseDuration(archiveTTLStr); err == nil {
+			config.Claude.Storage.ArchiveTTL = archiveTTL
+		}
+	}
+	if slackOutput := os.Getenv("CLAUDE_OUTPUT_SLACK"); slackOutput != "" {
+		config.Claude.Output.Slack = parseCommaSeparated(slackOutput)
+	}
+	if httpOutput := os.Getenv("CLAUDE_OUTPUT_HTTP"); httpOutput != "" {
+		config.Claude.Output.HTTP = parseCommaSeparated(httpOutput)
+	}
+	if maxLengthStr := os.Getenv("CLAUDE_OUTPUT_MAX_LENGTH"); maxLengthStr != "" {
+		if maxLength, err := strconv.Atoi(maxLengthStr); err == nil {
+			config.Claude.Output.MaxLength = maxLength
+		}
+	}
 
 	// Worklet environment variables
 	if baseDir := os.Getenv("WORKLET_BASE_DIR"); baseDir != "" {
@@ -123,6 +415,88 @@ func applyEnvOverrides(config *AppConfig) {
 			config.Worklet.MaxConcurrent = maxConcurrent
 		}
 	}
+	if previewDomain := os.Getenv("WORKLET_PREVIEW_DOMAIN"); previewDomain != "" {
+		config.Worklet.PreviewDomain = previewDomain
+	}
+	if previewAccess := os.Getenv("WORKLET_PREVIEW_ACCESS"); previewAccess != "" {
+		config.Worklet.PreviewAccess = previewAccess
+	}
+	if prPollStr := os.Getenv("WORKLET_PR_POLL_INTERVAL"); prPollStr != "" {
+		if prPoll, err := time.ParseDuration(prPollStr); err == nil {
+			config.Worklet.PRPollInterval = prPoll
+		}
+	}
+	if testTimeoutStr := os.Getenv("WORKLET_TEST_TIMEOUT"); testTimeoutStr != "" {
+		if testTimeout, err := time.ParseDuration(testTimeoutStr); err == nil {
+			config.Worklet.TestTimeout = testTimeout
+		}
+	}
+	if idleTimeoutStr := os.Getenv("WORKLET_IDLE_TIMEOUT"); idleTimeoutStr != "" {
+		if idleTimeout, err := time.ParseDuration(idleTimeoutStr); err == nil {
+			config.Worklet.IdleTimeout = idleTimeout
+		}
+	}
+	if portRange := os.Getenv("WORKLET_PORT_RANGE"); portRange != "" {
+		if start, end, ok := parsePortRange(portRange); ok {
+			config.Worklet.PortRangeStart, config.Worklet.PortRangeEnd = start, end
+		}
+	}
+	if cloneDepthStr := os.Getenv("WORKLET_CLONE_DEPTH"); cloneDepthStr != "" {
+		if cloneDepth, err := strconv.Atoi(cloneDepthStr); err == nil && cloneDepth >= 0 {
+			config.Worklet.CloneDepth = cloneDepth
+		}
+	}
+	if bucket := os.Getenv("WORKLET_ARTIFACT_BUCKET"); bucket != "" {
+		config.Worklet.Artifacts.Bucket = bucket
+	}
+	if endpoint := os.Getenv("WORKLET_ARTIFACT_ENDPOINT"); endpoint != "" {
+		config.Worklet.Artifacts.Endpoint = endpoint
+	}
+	if region := os.Getenv("WORKLET_ARTIFACT_REGION"); region != "" {
+		config.Worklet.Artifacts.Region = region
+	}
+	if prefix := os.Getenv("WORKLET_ARTIFACT_PREFIX"); prefix != "" {
+		config.Worklet.Artifacts.Prefix = prefix
+	}
+	if publicURL := os.Getenv("WORKLET_ARTIFACT_PUBLIC_URL"); publicURL != "" {
+		config.Worklet.Artifacts.PublicURL = publicURL
+	}
+	if expiryStr := os.Getenv("WORKLET_ARTIFACT_LINK_EXPIRY"); expiryStr != "" {
+		if expiry, err := time.ParseDuration(expiryStr); err == nil {
+			config.Worklet.Artifacts.LinkExpiry = expiry
+		}
+	}
+	if maxSizeStr := os.Getenv("WORKLET_ARTIFACT_MAX_SIZE"); maxSizeStr != "" {
+		if maxSize, err := strconv.ParseInt(maxSizeStr, 10, 64); err == nil {
+			config.Worklet.Artifacts.MaxSize = maxSize
+		}
+	}
+	if cacheEnabled := os.Getenv("WORKLET_CACHE_ENABLED"); cacheEnabled != "" {
+		config.Worklet.CacheEnabled = cacheEnabled == "true" || cacheEnabled == "1"
+	}
+	if cacheMaxSizeStr := os.Getenv("WORKLET_CACHE_MAX_SIZE"); cacheMaxSizeStr != "" {
+		if cacheMaxSize, err := strconv.ParseInt(cacheMaxSizeStr, 10, 64); err == nil {
+			config.Worklet.CacheMaxSize = cacheMaxSize
+		}
+	}
+	if webhookSecret := os.Getenv("WORKLET_WEBHOOK_SECRET"); webhookSecret != "" {
+		config.Worklet.Webhook.Secret = webhookSecret
+	}
+	if webhookRepos := os.Getenv("WORKLET_WEBHOOK_REPOS"); webhookRepos != "" {
+		config.Worklet.Webhook.Repos = parseCommaSeparated(webhookRepos)
+	}
+	if webhookForks := os.Getenv("WORKLET_WEBHOOK_FORKS"); webhookForks != "" {
+		config.Worklet.Webhook.Forks = parseCommaSeparated(webhookForks)
+	}
+	if webhookUser := os.Getenv("WORKLET_WEBHOOK_USER"); webhookUser != "" {
+		config.Worklet.Webhook.UserID = webhookUser
+	}
+	if accessKey := os.Getenv("AWS_ACCESS_KEY_ID"); accessKey != "" {
+		config.Worklet.Artifacts.AccessKeyID = accessKey
+	}
+	if secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY"); secretKey != "" {
+		config.Worklet.Artifacts.SecretAccessKey = secretKey
+	}
 
 	// Git environment variables
 	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
@@ -131,6 +505,254 @@ func applyEnvOverrides(config *AppConfig) {
 	if baseDir := os.Getenv("GIT_BASE_DIR"); baseDir != "" {
 		config.Git.BaseDir = baseDir
 	}
+
+	// Metrics environment variables
+	if enabled := os.Getenv("METRICS_ENABLED"); enabled != "" {
+		config.Metrics.Enabled = enabled == "true" || enabled == "1"
+	}
+	if path := os.Getenv("METRICS_PATH"); path != "" {
+		config.Metrics.Path = path
+	}
+	if username := os.Getenv("METRICS_USERNAME"); username != "" {
+		config.Metrics.Username = username
+	}
+	if password := os.Getenv("METRICS_PASSWORD"); password != "" {
+		config.Metrics.Password = password
+	}
+
+	// Debug endpoint environment variables
+	if enabled := os.Getenv("DEBUG_ENDPOINTS_ENABLED"); enabled != "" {
+		config.Debug.Enabled = enabled == "true" || enabled == "1"
+	}
+	if token := os.Getenv("DEBUG_TOKEN"); token != "" {
+		config.Debug.Token = token
+	}
+	if heapDumpDir := os.Getenv("DEBUG_HEAP_DUMP_DIR"); heapDumpDir != "" {
+		config.Debug.HeapDumpDir = heapDumpDir
+	}
+
+	// Logging environment variables
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		config.Logging.Level = level
+	}
+	if format := os.Getenv("LOG_FORMAT"); format != "" {
+		config.Logging.Format = format
+	}
+	if output := os.Getenv("LOG_OUTPUT"); output != "" {
+		config.Logging.Output = output
+	}
+	if addSource := os.Getenv("LOG_ADD_SOURCE"); addSource != "" {
+		config.Logging.AddSource = addSource == "true" || addSource == "1"
+	}
+	if modules := os.Getenv("LOG_MODULES"); modules != "" {
+		// Comma-separated module=level pairs, e.g. "claude=debug,slackbot=warn"
+		config.Logging.Modules = parseKeyValuePairs(modules)
+	}
+
+	// Server environment variables
+	if addr := os.Getenv("SERVER_ADDR"); addr != "" {
+		config.Server.Addr = addr
+	}
+	if readTimeoutStr := os.Getenv("SERVER_READ_TIMEOUT"); readTimeoutStr != "" {
+		if readTimeout, err := time.ParseDuration(readTimeoutStr); err == nil {
+			config.Server.ReadTimeout = readTimeout
+		}
+	}
+	if readHeaderTimeoutStr := os.Getenv("SERVER_READ_HEADER_TIMEOUT"); readHeaderTimeoutStr != "" {
+		if readHeaderTimeout, err := time.ParseDuration(readHeaderTimeoutStr); err == nil {
+			config.Server.ReadHeaderTimeout = readHeaderTimeout
+		}
+	}
+	if writeTimeoutStr := os.Getenv("SERVER_WRITE_TIMEOUT"); writeTimeoutStr != "" {
+		if writeTimeout, err := time.ParseDuration(writeTimeoutStr); err == nil {
+			config.Server.WriteTimeout = writeTimeout
+		}
+	}
+	if idleTimeoutStr := os.Getenv("SERVER_IDLE_TIMEOUT"); idleTimeoutStr != "" {
+		if idleTimeout, err := time.ParseDuration(idleTimeoutStr); err == nil {
+			config.Server.IdleTimeout = idleTimeout
+		}
+	}
+	if shutdownTimeoutStr := os.Getenv("SERVER_SHUTDOWN_TIMEOUT"); shutdownTimeoutStr != "" {
+		if shutdownTimeout, err := time.ParseDuration(shutdownTimeoutStr); err == nil {
+			config.Server.ShutdownTimeout = shutdownTimeout
+		}
+	}
+	if certFile := os.Getenv("TLS_CERT_FILE"); certFile != "" {
+		config.Server.TLS.CertFile = certFile
+	}
+	if keyFile := os.Getenv("TLS_KEY_FILE"); keyFile != "" {
+		config.Server.TLS.KeyFile = keyFile
+	}
+	if domains := os.Getenv("TLS_AUTOCERT_DOMAINS"); domains != "" {
+		config.Server.TLS.AutocertDomains = parseCommaSeparated(domains)
+	}
+	if cacheDir := os.Getenv("TLS_AUTOCERT_CACHE_DIR"); cacheDir != "" {
+		config.Server.TLS.AutocertCacheDir = cacheDir
+	}
+	if email := os.Getenv("TLS_AUTOCERT_EMAIL"); email != "" {
+		config.Server.TLS.AutocertEmail = email
+	}
+	if redirect := os.Getenv("TLS_REDIRECT_HTTP"); redirect != "" {
+		config.Server.TLS.RedirectHTTP = redirect == "true" || redirect == "1"
+	}
+	if httpAddr := os.Getenv("TLS_HTTP_ADDR"); httpAddr != "" {
+		config.Server.TLS.HTTPAddr = httpAddr
+	}
+
+	// Rate limit environment variables; rules use the form "requests/window", e.g. "60/1m"
+	if enabled := os.Getenv("RATE_LIMIT_ENABLED"); enabled != "" {
+		config.RateLimit.Enabled = enabled == "true" || enabled == "1"
+	}
+	if rule, ok := parseRateLimitRule(os.Getenv("RATE_LIMIT_GLOBAL")); ok {
+		config.RateLimit.Global = rule
+	}
+	if rule, ok := parseRateLimitRule(os.Getenv("RATE_LIMIT_BUILD")); ok {
+		config.RateLimit.Build = rule
+	}
+	if rule, ok := parseRateLimitRule(os.Getenv("RATE_LIMIT_WORKLET")); ok {
+		config.RateLimit.Worklet = rule
+	}
+
+	// CORS environment variables
+	if origins := os.Getenv("CORS_ALLOWED_ORIGINS"); origins != "" {
+		config.CORS.AllowedOrigins = parseCommaSeparated(origins)
+	}
+	if headers := os.Getenv("CORS_ALLOWED_HEADERS"); headers != "" {
+		config.CORS.AllowedHeaders = parseCommaSeparated(headers)
+	}
+	if credentials := os.Getenv("CORS_ALLOW_CREDENTIALS"); credentials != "" {
+		config.CORS.AllowCredentials = credentials == "true" || credentials == "1"
+	}
+	if maxAgeStr := os.Getenv("CORS_MAX_AGE"); maxAgeStr != "" {
+		if maxAge, err := time.ParseDuration(maxAgeStr); err == nil {
+			config.CORS.MaxAge = maxAge
+		}
+	}
+
+	// Code runner environment variables
+	if cacheDir := os.Getenv("CODE_CACHE_DIR"); cacheDir != "" {
+		config.Code.CacheDir = cacheDir
+	}
+	if entriesStr := os.Getenv("CODE_CACHE_ENTRIES"); entriesStr != "" {
+		if entries, err := strconv.Atoi(entriesStr); err == nil {
+			config.Code.CacheEntries = entries
+		}
+	}
+	if workersStr := os.Getenv("CODE_BUILD_WORKERS"); workersStr != "" {
+		if workers, err := strconv.Atoi(workersStr); err == nil {
+			config.Code.BuildWorkers = workers
+		}
+	}
+	if queueStr := os.Getenv("CODE_BUILD_QUEUE"); queueStr != "" {
+		if queue, err := strconv.Atoi(queueStr); err == nil {
+			config.Code.BuildQueue = queue
+		}
+	}
+	if timeoutStr := os.Getenv("CODE_BUILD_QUEUE_TIMEOUT"); timeoutStr != "" {
+		if timeout, err := time.ParseDuration(timeoutStr); err == nil {
+			config.Code.BuildQueueTimeout = timeout
+		}
+	}
+	if dependencies := os.Getenv("CODE_DEPENDENCIES"); dependencies != "" {
+		config.Code.Dependencies = dependencies
+	}
+	if dirs := os.Getenv("CODE_DEPENDENCY_DIRS"); dirs != "" {
+		config.Code.DependencyDirs = parseKeyValuePairs(dirs)
+	}
+	if cdnURL := os.Getenv("CODE_CDN_URL"); cdnURL != "" {
+		config.Code.CDNURL = cdnURL
+	}
+	if npmCommand := os.Getenv("CODE_NPM_COMMAND"); npmCommand != "" {
+		config.Code.NPMCommand = npmCommand
+	}
+	if timeoutStr := os.Getenv("CODE_INSTALL_TIMEOUT"); timeoutStr != "" {
+		if timeout, err := time.ParseDuration(timeoutStr); err == nil {
+			config.Code.InstallTimeout = timeout
+		}
+	}
+	if tailwindCommand := os.Getenv("CODE_TAILWIND_COMMAND"); tailwindCommand != "" {
+		config.Code.TailwindCommand = tailwindCommand
+	}
+	if postcssCommand := os.Getenv("CODE_POSTCSS_COMMAND"); postcssCommand != "" {
+		config.Code.PostCSSCommand = postcssCommand
+	}
+	if typeCheckStr := os.Getenv("CODE_TYPECHECK"); typeCheckStr != "" {
+		config.Code.TypeCheck = typeCheckStr == "true" || typeCheckStr == "1"
+	}
+	if tscCommand := os.Getenv("CODE_TSC_COMMAND"); tscCommand != "" {
+		config.Code.TSCCommand = tscCommand
+	}
+	if goCommand := os.Getenv("CODE_GO_COMMAND"); goCommand != "" {
+		config.Code.GoCommand = goCommand
+	}
+	if timeoutStr := os.Getenv("CODE_GO_TIMEOUT"); timeoutStr != "" {
+		if timeout, err := time.ParseDuration(timeoutStr); err == nil {
+			config.Code.GoTimeout = timeout
+		}
+	}
+	if networkStr := os.Getenv("CODE_GO_NETWORK"); networkStr != "" {
+		config.Code.GoNetwork = networkStr == "true" || networkStr == "1"
+	}
+	if goUser := os.Getenv("CODE_GO_USER"); goUser != "" {
+		config.Code.GoUser = goUser
+	}
+	if snapshotDir := os.Getenv("CODE_SNAPSHOT_DIR"); snapshotDir != "" {
+		config.Code.SnapshotDir = snapshotDir
+	}
+	if chromePath := os.Getenv("CODE_CHROME_PATH"); chromePath != "" {
+		config.Code.ChromePath = chromePath
+	}
+	if snapshotBaseURL := os.Getenv("CODE_SNAPSHOT_BASE_URL"); snapshotBaseURL != "" {
+		config.Code.SnapshotBaseURL = snapshotBaseURL
+	}
+}
+
+// parseRateLimitRule parses a "requests/window" rule such as "60/1m"
+func parseRateLimitRule(s string) (RateLimitRule, bool) {
+	requestsStr, windowStr, ok := strings.Cut(s, "/")
+	if !ok {
+		return RateLimitRule{}, false
+	}
+	requests, err := strconv.Atoi(strings.TrimSpace(requestsStr))
+	if err != nil {
+		return RateLimitRule{}, false
+	}
+	window, err := time.ParseDuration(strings.TrimSpace(windowStr))
+	if err != nil || window <= 0 {
+		return RateLimitRule{}, false
+	}
+	return RateLimitRule{Requests: requests, Window: window}, true
+}
+
+// parsePortRange parses a "start-end" range of ports such as "20000-20999"
+func parsePortRange(s string) (int, int, bool) {
+	startStr, endStr, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	start, err := strconv.Atoi(strings.TrimSpace(startStr))
+	if err != nil {
+		return 0, 0, false
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(endStr))
+	if err != nil || start < 1 || end > 65535 || start > end {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// parseKeyValuePairs parses comma-separated key=value pairs into a map
+func parseKeyValuePairs(s string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range parseCommaSeparated(s) {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		result[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return result
 }
 
 // parseCommaSeparated splits a comma-separated string into a slice of strings
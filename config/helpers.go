@@ -27,4 +27,49 @@ func (c *AppConfig) GetGitConfig() *GitConfig {
 	return &c.Git
 }
 
+// GetMetricsConfig returns the Metrics configuration
+func (c *AppConfig) GetMetricsConfig() *MetricsConfig {
+	return &c.Metrics
+}
+
+// GetDebugConfig returns the debug endpoint configuration
+func (c *AppConfig) GetDebugConfig() *DebugConfig {
+	return &c.Debug
+}
+
+// GetLoggingConfig returns the logging configuration
+func (c *AppConfig) GetLoggingConfig() *LoggingConfig {
+	return &c.Logging
+}
+
+// GetServerConfig returns the HTTP server configuration
+func (c *AppConfig) GetServerConfig() *ServerConfig {
+	return &c.Server
+}
+
+// GetRateLimitConfig returns the HTTP rate limit configuration
+func (c *AppConfig) GetRateLimitConfig() *RateLimitConfig {
+	return &c.RateLimit
+}
+
+// GetCORSConfig returns the CORS configuration for the APIs
+func (c *AppConfig) GetCORSConfig() *CORSConfig {
+	return &c.CORS
+}
+
+// Enabled returns true if a certificate pair or autocert domains are configured
+func (t *TLSConfig) Enabled() bool {
+	return (t.CertFile != "" && t.KeyFile != "") || len(t.AutocertDomains) > 0
+}
+
+// IsAdmin returns true if the user ID is listed in the admins configuration
+func (c *AppConfig) IsAdmin(userID string) bool {
+	for _, admin := range c.Admins {
+		if admin == userID {
+			return true
+		}
+	}
+	return false
+}
+
 // SlackBotConfig helper methods
@@ -0,0 +1,47 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronRejectsBadExpressions(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded; want an error", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Wednesday, January 15th 2025 at 10:30
+	now := time.Date(2025, time.January, 15, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, time.January, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, time.January, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * 1", time.Date(2025, time.January, 20, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2025, time.January, 19, 9, 0, 0, 0, time.UTC)},
+		{"0 9-17/4 * * 1-5", time.Date(2025, time.January, 15, 13, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 8 29 2 *", time.Date(2028, time.February, 29, 8, 30, 0, 0, time.UTC)},
+		// With both day fields restricted, either one matches
+		{"0 12 1 * 5", time.Date(2025, time.January, 17, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		cron, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q) = %v", tt.expr, err)
+		}
+		if got := cron.Next(now); !got.Equal(tt.want) {
+			t.Errorf("Next(%q) = %s; want %s", tt.expr, got, tt.want)
+		}
+	}
+
+	cron, _ := Parse("0 0 30 2 *")
+	if got := cron.Next(now); !got.IsZero() {
+		t.Errorf("Next() of February 30th = %s; want no time", got)
+	}
+}
@@ -303,12 +303,18 @@ func NewClaudeDB(dsn string) *gorm.DB {
 
 	if err := db.AutoMigrate(
 		&models.ClaudeSession{},
+		&models.ClaudeUsage{},
+		&models.ClaudeJob{},
 		// New Slack persistence models
 		&models.SlackSession{},
 		&models.ThreadContext{},
 		&models.SlackUserActivity{},
 		&models.SlackIdeationSession{},
 		&models.SlackFileUpload{},
+		&models.SlackChannelConfig{},
+		&models.SlackUserRole{},
+		&models.SlackSchedule{},
+		&models.AuditEvent{},
 		&models.SessionKVStore{},
 	); err != nil {
 		log.Fatalf("Failed to migrate db: %v", err)
@@ -2,7 +2,9 @@ package deps
 
 import (
 	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/events"
 	"github.com/breadchris/flow/session"
+	"github.com/breadchris/flow/shutdown"
 	"github.com/sashabaranov/go-openai"
 	"gorm.io/gorm"
 )
@@ -13,4 +15,6 @@ type Deps struct {
 	Config  config.AppConfig
 	Session *session.SessionManager
 	AI      *openai.Client
+	Drainer *shutdown.Drainer
+	Events  *events.Bus
 }
\ No newline at end of file
@@ -3,7 +3,9 @@ package deps
 import (
 	"github.com/breadchris/flow/config"
 	"github.com/breadchris/flow/db"
+	"github.com/breadchris/flow/events"
 	"github.com/breadchris/flow/session"
+	"github.com/breadchris/flow/shutdown"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -44,5 +46,7 @@ func (f *DepsFactory) CreateDeps() Deps {
 		Config:  f.config,
 		Session: sessionManager,
 		AI:      aiClient,
+		Drainer: shutdown.NewDrainer(),
+		Events:  events.New(),
 	}
 }
@@ -0,0 +1,148 @@
+package diagnostics
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	rpprof "runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/breadchris/flow/deps"
+)
+
+// RuntimeStats is a snapshot of runtime state returned by /debug/runtime
+type RuntimeStats struct {
+	Goroutines   int    `json:"goroutines"`
+	HeapAlloc    uint64 `json:"heap_alloc_bytes"`
+	HeapInuse    uint64 `json:"heap_inuse_bytes"`
+	HeapObjects  uint64 `json:"heap_objects"`
+	Sys          uint64 `json:"sys_bytes"`
+	NumGC        uint32 `json:"num_gc"`
+	LastGC       string `json:"last_gc,omitempty"`
+	PauseTotalNs uint64 `json:"pause_total_ns"`
+	GoVersion    string `json:"go_version"`
+}
+
+// New returns a ServeMux with pprof and runtime debug routes rooted at /debug/,
+// gated by the debug token or an admin session
+func New(d deps.Deps) http.Handler {
+	m := http.NewServeMux()
+
+	m.HandleFunc("/debug/pprof/", pprof.Index)
+	m.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	m.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	m.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	m.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	m.HandleFunc("GET /debug/goroutines", handleGoroutines)
+	m.HandleFunc("GET /debug/runtime", handleRuntime)
+	m.HandleFunc("POST /debug/gc", handleGC)
+	m.HandleFunc("POST /debug/heapdump", handleHeapDump(d.Config.Debug.HeapDumpDir))
+
+	return Protect(d, m)
+}
+
+// Protect gates a handler behind the debug token or an admin session, like
+// the routes New serves
+func Protect(d deps.Deps, next http.Handler) http.Handler {
+	var handler http.Handler = requireAdmin(d, next)
+	if d.Session != nil {
+		handler = d.Session.LoadAndSave(handler)
+	}
+	return handler
+}
+
+// requireAdmin allows requests bearing the configured debug token or from a logged in admin
+func requireAdmin(d deps.Deps, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := d.Config.Debug.Token; token != "" {
+			bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		if d.Session != nil {
+			if userID, err := d.Session.GetUserID(r.Context()); err == nil && d.Config.IsAdmin(userID) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		slog.Warn("Rejected unauthenticated debug request", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// handleGoroutines writes a full stack dump of every goroutine
+func handleGoroutines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err := rpprof.Lookup("goroutine").WriteTo(w, 2); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to write goroutine dump: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// handleRuntime returns memory and scheduler statistics as JSON
+func handleRuntime(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := RuntimeStats{
+		Goroutines:   runtime.NumGoroutine(),
+		HeapAlloc:    mem.HeapAlloc,
+		HeapInuse:    mem.HeapInuse,
+		HeapObjects:  mem.HeapObjects,
+		Sys:          mem.Sys,
+		NumGC:        mem.NumGC,
+		PauseTotalNs: mem.PauseTotalNs,
+		GoVersion:    runtime.Version(),
+	}
+	if mem.LastGC > 0 {
+		stats.LastGC = time.Unix(0, int64(mem.LastGC)).Format(time.RFC3339)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// handleGC forces a garbage collection and returns freed memory to the OS
+func handleGC(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	debug.FreeOSMemory()
+
+	slog.Info("Forced garbage collection via debug endpoint", "duration_ms", time.Since(start).Milliseconds())
+	handleRuntime(w, r)
+}
+
+// handleHeapDump writes a full heap dump to dir and returns the file path
+func handleHeapDump(dir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to create heap dump directory: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("heapdump-%s.bin", time.Now().Format("20060102-150405")))
+		f, err := os.Create(path)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to create heap dump file: %v", err), http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+
+		debug.WriteHeapDump(f.Fd())
+		slog.Info("Wrote heap dump via debug endpoint", "path", path)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"path": path})
+	}
+}
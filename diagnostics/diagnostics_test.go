@@ -0,0 +1,71 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/deps"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHandler(token string) http.Handler {
+	return New(deps.Deps{
+		Config: config.AppConfig{
+			Debug: config.DebugConfig{Enabled: true, Token: token},
+		},
+	})
+}
+
+func TestDebugRequiresToken(t *testing.T) {
+	handler := newTestHandler("s3cret")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/runtime", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/runtime", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestDebugRejectsEmptyToken(t *testing.T) {
+	handler := newTestHandler("")
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/runtime", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestDebugRuntime(t *testing.T) {
+	handler := newTestHandler("s3cret")
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/runtime", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var stats RuntimeStats
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&stats))
+	assert.Greater(t, stats.Goroutines, 0)
+	assert.NotEmpty(t, stats.GoVersion)
+}
+
+func TestDebugGoroutines(t *testing.T) {
+	handler := newTestHandler("s3cret")
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/goroutines", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "goroutine")
+}
@@ -0,0 +1,15 @@
+package events
+
+import "log/slog"
+
+// Audit logs session starts, worklet status changes, and pull requests until
+// the returned function is called. Per-message session traffic and worklet
+// log lines are skipped.
+func Audit(b *Bus) (unsubscribe func()) {
+	return b.SubscribeAll(func(e Event) {
+		if e.Topic == TopicSessionMessage.Name || e.Topic == TopicWorkletLog.Name {
+			return
+		}
+		slog.Info("Audit event", "topic", e.Topic, "payload", e.Payload)
+	})
+}
@@ -0,0 +1,311 @@
+package events
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/breadchris/flow/metrics"
+)
+
+// bufferSize is how many events a subscriber may fall behind before new ones are dropped
+const bufferSize = 256
+
+// Topic names a stream of events whose payloads have type T
+type Topic[T any] struct {
+	Name string
+}
+
+// Topics published by claude, worklet, and slackbot
+var (
+	TopicSessionStarted   = Topic[SessionStarted]{Name: "session.started"}
+	TopicSessionMessage   = Topic[SessionMessage]{Name: "session.message"}
+	TopicSessionRestarted = Topic[SessionRestarted]{Name: "session.restarted"}
+	TopicWorkletStatus    = Topic[WorkletStatus]{Name: "worklet.status"}
+	TopicWorkletLog       = Topic[WorkletLog]{Name: "worklet.log"}
+	TopicWorkletEvent     = Topic[WorkletEvent]{Name: "worklet.event"}
+	TopicPRCreated        = Topic[PRCreated]{Name: "pr.created"}
+	TopicPRStatus         = Topic[PRStatus]{Name: "pr.status"}
+	TopicArtifacts        = Topic[ArtifactsPublished]{Name: "worklet.artifacts"}
+	TopicScheduleRun      = Topic[ScheduleRunFinished]{Name: "worklet.schedule_run"}
+	TopicJobFinished      = Topic[JobFinished]{Name: "job.finished"}
+	TopicApprovalRequired = Topic[ApprovalRequired]{Name: "approval.required"}
+)
+
+// SessionStarted is published when a Claude session is created or resumed
+type SessionStarted struct {
+	SessionID  string `json:"session_id"`
+	UserID     string `json:"user_id"`
+	ThreadTS   string `json:"thread_ts,omitempty"`
+	ChannelID  string `json:"channel_id,omitempty"`
+	WorkingDir string `json:"working_dir,omitempty"`
+	Resumed    bool   `json:"resumed"`
+}
+
+// SessionMessage is published for every message a Claude session sends or receives
+type SessionMessage struct {
+	SessionID string          `json:"session_id"`
+	Type      string          `json:"type"`
+	Subtype   string          `json:"subtype,omitempty"`
+	Message   json.RawMessage `json:"message,omitempty"`
+	Result    string          `json:"result,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+	Text      string          `json:"text,omitempty"` // Text delta of a partial_text message
+}
+
+// SessionRestarted is published when the supervisor replaces a crashed or hung
+// Claude process with one resumed from the same conversation
+type SessionRestarted struct {
+	SessionID string `json:"session_id"`
+	UserID    string `json:"user_id"`
+	Reason    string `json:"reason"`
+}
+
+// WorkletStatus is published when a worklet changes status
+type WorkletStatus struct {
+	WorkletID string `json:"worklet_id"`
+	UserID    string `json:"user_id"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	WebURL    string `json:"web_url,omitempty"`
+}
+
+// WorkletLog is published for each line a worklet's Docker build or
+// container writes while it is deployed
+type WorkletLog struct {
+	WorkletID string `json:"worklet_id"`
+	Stage     string `json:"stage"` // "build", "deploy", "runtime", or "test"
+	Line      string `json:"line"`
+}
+
+// WorkletEventType is a step in a worklet's lifecycle
+type WorkletEventType string
+
+const (
+	WorkletCreated       WorkletEventType = "created"        // Saved; its first build is about to start
+	WorkletBuilding      WorkletEventType = "building"       // Cloning and building, first or again
+	WorkletDeployed      WorkletEventType = "deployed"       // Its container is running a new build
+	WorkletPromptApplied WorkletEventType = "prompt_applied" // A prompt finished changing its code
+	WorkletError         WorkletEventType = "error"          // A build, deploy, or prompt failed
+	WorkletStopped       WorkletEventType = "stopped"        // Its container was stopped
+	WorkletTestsPassed   WorkletEventType = "tests_passed"   // Its tests passed in its container
+	WorkletTestsFailed   WorkletEventType = "tests_failed"   // Its tests failed or couldn't run
+	WorkletSlept         WorkletEventType = "slept"          // Its container was stopped for being idle
+	WorkletWoke          WorkletEventType = "woke"           // A request started its container again after it slept
+)
+
+// WorkletEvent is published at each step of a worklet's lifecycle. Unlike
+// WorkletStatus it isn't sent for every status change, such as health checks
+// failing, only for the steps above.
+type WorkletEvent struct {
+	WorkletID string           `json:"worklet_id"`
+	UserID    string           `json:"user_id"`
+	Type      WorkletEventType `json:"type"`
+	Error     string           `json:"error,omitempty"`     // Set for error events
+	WebURL    string           `json:"web_url,omitempty"`   // Set for deployed events
+	PromptID  string           `json:"prompt_id,omitempty"` // Set for prompt_applied events, and error events for a prompt
+}
+
+// PRCreated is published when a pull request is opened for a worklet's changes
+type PRCreated struct {
+	WorkletID string `json:"worklet_id"`
+	UserID    string `json:"user_id"`
+	Repo      string `json:"repo"`
+	Branch    string `json:"branch"`
+	Title     string `json:"title"`
+	URL       string `json:"url,omitempty"`
+	Number    int    `json:"number,omitempty"`
+}
+
+// ArtifactsPublished is published when a worklet's artifacts are uploaded
+// after a prompt
+type ArtifactsPublished struct {
+	WorkletID string     `json:"worklet_id"`
+	UserID    string     `json:"user_id"`
+	PromptID  string     `json:"prompt_id"`
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+// ScheduleRunFinished is published when a scheduled prompt to a worklet
+// finishes, whether or not it succeeded
+type ScheduleRunFinished struct {
+	ScheduleID   string `json:"schedule_id"`
+	RunID        string `json:"run_id"`
+	WorkletID    string `json:"worklet_id"`
+	UserID       string `json:"user_id"`
+	Cron         string `json:"cron"`
+	Prompt       string `json:"prompt"`
+	Status       string `json:"status"` // "succeeded" or "failed"
+	Error        string `json:"error,omitempty"`
+	FilesChanged int    `json:"files_changed"`
+	PRURL        string `json:"pr_url,omitempty"` // Set when the run opened a pull request
+}
+
+// Artifact is a file a worklet published and a link that downloads it
+type Artifact struct {
+	Path string `json:"path"`
+	URL  string `json:"url"`
+	Size int64  `json:"size"`
+}
+
+// PRStatus is published when a worklet's pull request is merged or closed,
+// or its CI checks change
+type PRStatus struct {
+	WorkletID      string `json:"worklet_id"`
+	UserID         string `json:"user_id"`
+	URL            string `json:"url"`
+	Number         int    `json:"number"`
+	State          string `json:"state"`            // "open", "merged", or "closed"
+	Checks         string `json:"checks,omitempty"` // "pending", "passed", "failed", or empty without checks
+	PreviousState  string `json:"previous_state"`
+	PreviousChecks string `json:"previous_checks,omitempty"`
+}
+
+// JobFinished is published when a background Claude job succeeds, fails, or is cancelled
+type JobFinished struct {
+	JobID  string `json:"job_id"`
+	UserID string `json:"user_id"`
+	Status string `json:"status"`
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ApprovalRequired is published when a Claude session holds a Bash command until a user approves it
+type ApprovalRequired struct {
+	SessionID string `json:"session_id"`
+	UserID    string `json:"user_id"`
+	ToolUseID string `json:"tool_use_id"`
+	ToolName  string `json:"tool_name"`
+	Command   string `json:"command"`
+}
+
+// Event is a published payload along with its topic name
+type Event struct {
+	Topic   string    `json:"topic"`
+	Time    time.Time `json:"time"`
+	Payload any       `json:"payload"`
+}
+
+// Bus is an in-process publish/subscribe hub. Publishing never blocks: each
+// subscriber has its own buffer and events are dropped when it fills up.
+type Bus struct {
+	mu     sync.RWMutex
+	nextID uint64
+	subs   map[uint64]*subscriber
+}
+
+type subscriber struct {
+	topic string // Empty for all topics
+	ch    chan Event
+	done  chan struct{}
+}
+
+// New creates an empty bus
+func New() *Bus {
+	return &Bus{subs: make(map[uint64]*subscriber)}
+}
+
+// Publish sends payload to every subscriber of topic. It is a no-op on a nil bus.
+func Publish[T any](b *Bus, topic Topic[T], payload T) {
+	if b == nil {
+		return
+	}
+	b.publish(Event{Topic: topic.Name, Time: time.Now(), Payload: payload})
+}
+
+// Subscribe calls fn on a dedicated goroutine for each event published to topic
+// until the returned function is called
+func Subscribe[T any](b *Bus, topic Topic[T], fn func(T)) (unsubscribe func()) {
+	if b == nil {
+		return func() {}
+	}
+	return b.subscribe(topic.Name, func(e Event) {
+		if payload, ok := e.Payload.(T); ok {
+			fn(payload)
+		}
+	})
+}
+
+// Channel subscribes to topic and delivers its payloads on the returned
+// channel until unsubscribe is called
+func Channel[T any](b *Bus, topic Topic[T]) (<-chan T, func()) {
+	out := make(chan T, bufferSize)
+	done := make(chan struct{})
+	unsubscribe := Subscribe(b, topic, func(payload T) {
+		select {
+		case out <- payload:
+		case <-done:
+		}
+	})
+
+	var once sync.Once
+	return out, func() {
+		once.Do(func() {
+			close(done)
+			unsubscribe()
+		})
+	}
+}
+
+// SubscribeAll calls fn for every event on every topic until the returned function is called
+func (b *Bus) SubscribeAll(fn func(Event)) (unsubscribe func()) {
+	if b == nil {
+		return func() {}
+	}
+	return b.subscribe("", fn)
+}
+
+func (b *Bus) subscribe(topic string, fn func(Event)) func() {
+	sub := &subscriber{
+		topic: topic,
+		ch:    make(chan Event, bufferSize),
+		done:  make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	b.nextID++
+	id := b.nextID
+	b.subs[id] = sub
+	b.mu.Unlock()
+
+	go func() {
+		for {
+			select {
+			case e := <-sub.ch:
+				fn(e)
+			case <-sub.done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, id)
+			b.mu.Unlock()
+			close(sub.done)
+		})
+	}
+}
+
+func (b *Bus) publish(e Event) {
+	metrics.EventsPublishedTotal.WithLabelValues(e.Topic).Inc()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, sub := range b.subs {
+		if sub.topic != "" && sub.topic != e.Topic {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			metrics.EventsDroppedTotal.WithLabelValues(e.Topic).Inc()
+			slog.Warn("Dropped event for slow subscriber", "topic", e.Topic)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeReceivesTypedPayloads(t *testing.T) {
+	bus := New()
+
+	received := make(chan WorkletStatus, 1)
+	unsubscribe := Subscribe(bus, TopicWorkletStatus, func(s WorkletStatus) {
+		received <- s
+	})
+	defer unsubscribe()
+
+	Publish(bus, TopicWorkletStatus, WorkletStatus{WorkletID: "w1", Status: "running"})
+
+	select {
+	case s := <-received:
+		assert.Equal(t, "w1", s.WorkletID)
+		assert.Equal(t, "running", s.Status)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+}
+
+func TestSubscribeFiltersByTopic(t *testing.T) {
+	bus := New()
+
+	statuses, unsubscribe := Channel(bus, TopicWorkletStatus)
+	defer unsubscribe()
+
+	Publish(bus, TopicPRCreated, PRCreated{WorkletID: "w1"})
+	Publish(bus, TopicWorkletStatus, WorkletStatus{WorkletID: "w2"})
+
+	select {
+	case s := <-statuses:
+		assert.Equal(t, "w2", s.WorkletID)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+}
+
+func TestSubscribeAll(t *testing.T) {
+	bus := New()
+
+	received := make(chan Event, 2)
+	unsubscribe := bus.SubscribeAll(func(e Event) { received <- e })
+	defer unsubscribe()
+
+	Publish(bus, TopicSessionStarted, SessionStarted{SessionID: "s1"})
+	Publish(bus, TopicPRCreated, PRCreated{WorkletID: "w1"})
+
+	var topics []string
+	for i := 0; i < 2; i++ {
+		select {
+		case e := <-received:
+			topics = append(topics, e.Topic)
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+	}
+	assert.ElementsMatch(t, []string{"session.started", "pr.created"}, topics)
+}
+
+func TestUnsubscribeStopsDelivery(t *testing.T) {
+	bus := New()
+
+	messages, unsubscribe := Channel(bus, TopicSessionMessage)
+	unsubscribe()
+	unsubscribe()
+
+	Publish(bus, TopicSessionMessage, SessionMessage{SessionID: "s1"})
+
+	select {
+	case <-messages:
+		t.Fatal("received event after unsubscribing")
+	case <-time.After(20 * time.Millisecond):
+	}
+	assert.Empty(t, bus.subs)
+}
+
+func TestPublishDoesNotBlockOnSlowSubscriber(t *testing.T) {
+	bus := New()
+
+	block := make(chan struct{})
+	unsubscribe := Subscribe(bus, TopicSessionMessage, func(SessionMessage) { <-block })
+	defer unsubscribe()
+	defer close(block)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < bufferSize*2; i++ {
+			Publish(bus, TopicSessionMessage, SessionMessage{SessionID: "s1"})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publish blocked on a slow subscriber")
+	}
+}
+
+func TestNilBus(t *testing.T) {
+	var bus *Bus
+	require.NotPanics(t, func() {
+		Publish(bus, TopicWorkletStatus, WorkletStatus{})
+		Subscribe(bus, TopicWorkletStatus, func(WorkletStatus) {})()
+		bus.SubscribeAll(func(Event) {})()
+	})
+}
@@ -0,0 +1,11 @@
+package events
+
+import "github.com/breadchris/flow/metrics"
+
+// CountWorkletEvents counts worklet lifecycle events by type until the
+// returned function is called
+func CountWorkletEvents(b *Bus) (unsubscribe func()) {
+	return Subscribe(b, TopicWorkletEvent, func(e WorkletEvent) {
+		metrics.WorkletEventsTotal.WithLabelValues(string(e.Type)).Inc()
+	})
+}
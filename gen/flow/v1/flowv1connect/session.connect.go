@@ -0,0 +1,235 @@
+// Code generated by protoc-gen-connect-go. DO NOT EDIT.
+//
+// Source: flow/v1/session.proto
+
+package flowv1connect
+
+import (
+	connect "connectrpc.com/connect"
+	context "context"
+	errors "errors"
+	v1 "github.com/breadchris/flow/gen/flow/v1"
+	http "net/http"
+	strings "strings"
+)
+
+// This is a compile-time assertion to ensure that this generated file and the connect package are
+// compatible. If you get a compiler error that this constant is not defined, this code was
+// generated with a version of connect newer than the one compiled into your binary. You can fix the
+// problem by either regenerating this code with an older version of connect or updating the connect
+// version compiled into your binary.
+const _ = connect.IsAtLeastVersion1_13_0
+
+const (
+	// SessionServiceName is the fully-qualified name of the SessionService service.
+	SessionServiceName = "flow.v1.SessionService"
+)
+
+// These constants are the fully-qualified names of the RPCs defined in this package. They're
+// exposed at runtime as Spec.Procedure and as the final two segments of the HTTP route.
+//
+// Note that these are different from the fully-qualified method names used by
+// google.golang.org/protobuf/reflect/protoreflect. To convert from these constants to
+// reflection-formatted method names, remove the leading slash and convert the remaining slash to a
+// period.
+const (
+	// SessionServiceListSessionsProcedure is the fully-qualified name of the SessionService's
+	// ListSessions RPC.
+	SessionServiceListSessionsProcedure = "/flow.v1.SessionService/ListSessions"
+	// SessionServiceGetSessionProcedure is the fully-qualified name of the SessionService's GetSession
+	// RPC.
+	SessionServiceGetSessionProcedure = "/flow.v1.SessionService/GetSession"
+	// SessionServiceCreateSessionProcedure is the fully-qualified name of the SessionService's
+	// CreateSession RPC.
+	SessionServiceCreateSessionProcedure = "/flow.v1.SessionService/CreateSession"
+	// SessionServiceSendPromptProcedure is the fully-qualified name of the SessionService's SendPrompt
+	// RPC.
+	SessionServiceSendPromptProcedure = "/flow.v1.SessionService/SendPrompt"
+	// SessionServiceStopSessionProcedure is the fully-qualified name of the SessionService's
+	// StopSession RPC.
+	SessionServiceStopSessionProcedure = "/flow.v1.SessionService/StopSession"
+)
+
+// SessionServiceClient is a client for the flow.v1.SessionService service.
+type SessionServiceClient interface {
+	// ListSessions returns the caller's sessions
+	ListSessions(context.Context, *connect.Request[v1.ListSessionsRequest]) (*connect.Response[v1.ListSessionsResponse], error)
+	// GetSession returns a single session owned by the caller
+	GetSession(context.Context, *connect.Request[v1.GetSessionRequest]) (*connect.Response[v1.GetSessionResponse], error)
+	// CreateSession starts a new Claude session
+	CreateSession(context.Context, *connect.Request[v1.CreateSessionRequest]) (*connect.Response[v1.CreateSessionResponse], error)
+	// SendPrompt sends a prompt and streams Claude's messages until the turn completes
+	SendPrompt(context.Context, *connect.Request[v1.SendPromptRequest]) (*connect.ServerStreamForClient[v1.SessionEvent], error)
+	// StopSession stops the session's Claude process
+	StopSession(context.Context, *connect.Request[v1.StopSessionRequest]) (*connect.Response[v1.StopSessionResponse], error)
+}
+
+// NewSessionServiceClient constructs a client for the flow.v1.SessionService service. By default,
+// it uses the Connect protocol with the binary Protobuf Codec, asks for gzipped responses, and
+// sends uncompressed requests. To use the gRPC or gRPC-Web protocols, supply the connect.WithGRPC()
+// or connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewSessionServiceClient(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) SessionServiceClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	sessionServiceMethods := v1.File_flow_v1_session_proto.Services().ByName("SessionService").Methods()
+	return &sessionServiceClient{
+		listSessions: connect.NewClient[v1.ListSessionsRequest, v1.ListSessionsResponse](
+			httpClient,
+			baseURL+SessionServiceListSessionsProcedure,
+			connect.WithSchema(sessionServiceMethods.ByName("ListSessions")),
+			connect.WithClientOptions(opts...),
+		),
+		getSession: connect.NewClient[v1.GetSessionRequest, v1.GetSessionResponse](
+			httpClient,
+			baseURL+SessionServiceGetSessionProcedure,
+			connect.WithSchema(sessionServiceMethods.ByName("GetSession")),
+			connect.WithClientOptions(opts...),
+		),
+		createSession: connect.NewClient[v1.CreateSessionRequest, v1.CreateSessionResponse](
+			httpClient,
+			baseURL+SessionServiceCreateSessionProcedure,
+			connect.WithSchema(sessionServiceMethods.ByName("CreateSession")),
+			connect.WithClientOptions(opts...),
+		),
+		sendPrompt: connect.NewClient[v1.SendPromptRequest, v1.SessionEvent](
+			httpClient,
+			baseURL+SessionServiceSendPromptProcedure,
+			connect.WithSchema(sessionServiceMethods.ByName("SendPrompt")),
+			connect.WithClientOptions(opts...),
+		),
+		stopSession: connect.NewClient[v1.StopSessionRequest, v1.StopSessionResponse](
+			httpClient,
+			baseURL+SessionServiceStopSessionProcedure,
+			connect.WithSchema(sessionServiceMethods.ByName("StopSession")),
+			connect.WithClientOptions(opts...),
+		),
+	}
+}
+
+// sessionServiceClient implements SessionServiceClient.
+type sessionServiceClient struct {
+	listSessions  *connect.Client[v1.ListSessionsRequest, v1.ListSessionsResponse]
+	getSession    *connect.Client[v1.GetSessionRequest, v1.GetSessionResponse]
+	createSession *connect.Client[v1.CreateSessionRequest, v1.CreateSessionResponse]
+	sendPrompt    *connect.Client[v1.SendPromptRequest, v1.SessionEvent]
+	stopSession   *connect.Client[v1.StopSessionRequest, v1.StopSessionResponse]
+}
+
+// ListSessions calls flow.v1.SessionService.ListSessions.
+func (c *sessionServiceClient) ListSessions(ctx context.Context, req *connect.Request[v1.ListSessionsRequest]) (*connect.Response[v1.ListSessionsResponse], error) {
+	return c.listSessions.CallUnary(ctx, req)
+}
+
+// GetSession calls flow.v1.SessionService.GetSession.
+func (c *sessionServiceClient) GetSession(ctx context.Context, req *connect.Request[v1.GetSessionRequest]) (*connect.Response[v1.GetSessionResponse], error) {
+	return c.getSession.CallUnary(ctx, req)
+}
+
+// CreateSession calls flow.v1.SessionService.CreateSession.
+func (c *sessionServiceClient) CreateSession(ctx context.Context, req *connect.Request[v1.CreateSessionRequest]) (*connect.Response[v1.CreateSessionResponse], error) {
+	return c.createSession.CallUnary(ctx, req)
+}
+
+// SendPrompt calls flow.v1.SessionService.SendPrompt.
+func (c *sessionServiceClient) SendPrompt(ctx context.Context, req *connect.Request[v1.SendPromptRequest]) (*connect.ServerStreamForClient[v1.SessionEvent], error) {
+	return c.sendPrompt.CallServerStream(ctx, req)
+}
+
+// StopSession calls flow.v1.SessionService.StopSession.
+func (c *sessionServiceClient) StopSession(ctx context.Context, req *connect.Request[v1.StopSessionRequest]) (*connect.Response[v1.StopSessionResponse], error) {
+	return c.stopSession.CallUnary(ctx, req)
+}
+
+// SessionServiceHandler is an implementation of the flow.v1.SessionService service.
+type SessionServiceHandler interface {
+	// ListSessions returns the caller's sessions
+	ListSessions(context.Context, *connect.Request[v1.ListSessionsRequest]) (*connect.Response[v1.ListSessionsResponse], error)
+	// GetSession returns a single session owned by the caller
+	GetSession(context.Context, *connect.Request[v1.GetSessionRequest]) (*connect.Response[v1.GetSessionResponse], error)
+	// CreateSession starts a new Claude session
+	CreateSession(context.Context, *connect.Request[v1.CreateSessionRequest]) (*connect.Response[v1.CreateSessionResponse], error)
+	// SendPrompt sends a prompt and streams Claude's messages until the turn completes
+	SendPrompt(context.Context, *connect.Request[v1.SendPromptRequest], *connect.ServerStream[v1.SessionEvent]) error
+	// StopSession stops the session's Claude process
+	StopSession(context.Context, *connect.Request[v1.StopSessionRequest]) (*connect.Response[v1.StopSessionResponse], error)
+}
+
+// NewSessionServiceHandler builds an HTTP handler from the service implementation. It returns the
+// path on which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewSessionServiceHandler(svc SessionServiceHandler, opts ...connect.HandlerOption) (string, http.Handler) {
+	sessionServiceMethods := v1.File_flow_v1_session_proto.Services().ByName("SessionService").Methods()
+	sessionServiceListSessionsHandler := connect.NewUnaryHandler(
+		SessionServiceListSessionsProcedure,
+		svc.ListSessions,
+		connect.WithSchema(sessionServiceMethods.ByName("ListSessions")),
+		connect.WithHandlerOptions(opts...),
+	)
+	sessionServiceGetSessionHandler := connect.NewUnaryHandler(
+		SessionServiceGetSessionProcedure,
+		svc.GetSession,
+		connect.WithSchema(sessionServiceMethods.ByName("GetSession")),
+		connect.WithHandlerOptions(opts...),
+	)
+	sessionServiceCreateSessionHandler := connect.NewUnaryHandler(
+		SessionServiceCreateSessionProcedure,
+		svc.CreateSession,
+		connect.WithSchema(sessionServiceMethods.ByName("CreateSession")),
+		connect.WithHandlerOptions(opts...),
+	)
+	sessionServiceSendPromptHandler := connect.NewServerStreamHandler(
+		SessionServiceSendPromptProcedure,
+		svc.SendPrompt,
+		connect.WithSchema(sessionServiceMethods.ByName("SendPrompt")),
+		connect.WithHandlerOptions(opts...),
+	)
+	sessionServiceStopSessionHandler := connect.NewUnaryHandler(
+		SessionServiceStopSessionProcedure,
+		svc.StopSession,
+		connect.WithSchema(sessionServiceMethods.ByName("StopSession")),
+		connect.WithHandlerOptions(opts...),
+	)
+	return "/flow.v1.SessionService/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case SessionServiceListSessionsProcedure:
+			sessionServiceListSessionsHandler.ServeHTTP(w, r)
+		case SessionServiceGetSessionProcedure:
+			sessionServiceGetSessionHandler.ServeHTTP(w, r)
+		case SessionServiceCreateSessionProcedure:
+			sessionServiceCreateSessionHandler.ServeHTTP(w, r)
+		case SessionServiceSendPromptProcedure:
+			sessionServiceSendPromptHandler.ServeHTTP(w, r)
+		case SessionServiceStopSessionProcedure:
+			sessionServiceStopSessionHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// UnimplementedSessionServiceHandler returns CodeUnimplemented from all methods.
+type UnimplementedSessionServiceHandler struct{}
+
+func (UnimplementedSessionServiceHandler) ListSessions(context.Context, *connect.Request[v1.ListSessionsRequest]) (*connect.Response[v1.ListSessionsResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("flow.v1.SessionService.ListSessions is not implemented"))
+}
+
+func (UnimplementedSessionServiceHandler) GetSession(context.Context, *connect.Request[v1.GetSessionRequest]) (*connect.Response[v1.GetSessionResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("flow.v1.SessionService.GetSession is not implemented"))
+}
+
+func (UnimplementedSessionServiceHandler) CreateSession(context.Context, *connect.Request[v1.CreateSessionRequest]) (*connect.Response[v1.CreateSessionResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("flow.v1.SessionService.CreateSession is not implemented"))
+}
+
+func (UnimplementedSessionServiceHandler) SendPrompt(context.Context, *connect.Request[v1.SendPromptRequest], *connect.ServerStream[v1.SessionEvent]) error {
+	return connect.NewError(connect.CodeUnimplemented, errors.New("flow.v1.SessionService.SendPrompt is not implemented"))
+}
+
+func (UnimplementedSessionServiceHandler) StopSession(context.Context, *connect.Request[v1.StopSessionRequest]) (*connect.Response[v1.StopSessionResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("flow.v1.SessionService.StopSession is not implemented"))
+}
@@ -0,0 +1,109 @@
+// Code generated by protoc-gen-connect-go. DO NOT EDIT.
+//
+// Source: flow/v1/transcript.proto
+
+package flowv1connect
+
+import (
+	connect "connectrpc.com/connect"
+	context "context"
+	errors "errors"
+	v1 "github.com/breadchris/flow/gen/flow/v1"
+	http "net/http"
+	strings "strings"
+)
+
+// This is a compile-time assertion to ensure that this generated file and the connect package are
+// compatible. If you get a compiler error that this constant is not defined, this code was
+// generated with a version of connect newer than the one compiled into your binary. You can fix the
+// problem by either regenerating this code with an older version of connect or updating the connect
+// version compiled into your binary.
+const _ = connect.IsAtLeastVersion1_13_0
+
+const (
+	// TranscriptServiceName is the fully-qualified name of the TranscriptService service.
+	TranscriptServiceName = "flow.v1.TranscriptService"
+)
+
+// These constants are the fully-qualified names of the RPCs defined in this package. They're
+// exposed at runtime as Spec.Procedure and as the final two segments of the HTTP route.
+//
+// Note that these are different from the fully-qualified method names used by
+// google.golang.org/protobuf/reflect/protoreflect. To convert from these constants to
+// reflection-formatted method names, remove the leading slash and convert the remaining slash to a
+// period.
+const (
+	// TranscriptServiceGetTranscriptProcedure is the fully-qualified name of the TranscriptService's
+	// GetTranscript RPC.
+	TranscriptServiceGetTranscriptProcedure = "/flow.v1.TranscriptService/GetTranscript"
+)
+
+// TranscriptServiceClient is a client for the flow.v1.TranscriptService service.
+type TranscriptServiceClient interface {
+	GetTranscript(context.Context, *connect.Request[v1.GetTranscriptRequest]) (*connect.Response[v1.GetTranscriptResponse], error)
+}
+
+// NewTranscriptServiceClient constructs a client for the flow.v1.TranscriptService service. By
+// default, it uses the Connect protocol with the binary Protobuf Codec, asks for gzipped responses,
+// and sends uncompressed requests. To use the gRPC or gRPC-Web protocols, supply the
+// connect.WithGRPC() or connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewTranscriptServiceClient(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) TranscriptServiceClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	transcriptServiceMethods := v1.File_flow_v1_transcript_proto.Services().ByName("TranscriptService").Methods()
+	return &transcriptServiceClient{
+		getTranscript: connect.NewClient[v1.GetTranscriptRequest, v1.GetTranscriptResponse](
+			httpClient,
+			baseURL+TranscriptServiceGetTranscriptProcedure,
+			connect.WithSchema(transcriptServiceMethods.ByName("GetTranscript")),
+			connect.WithClientOptions(opts...),
+		),
+	}
+}
+
+// transcriptServiceClient implements TranscriptServiceClient.
+type transcriptServiceClient struct {
+	getTranscript *connect.Client[v1.GetTranscriptRequest, v1.GetTranscriptResponse]
+}
+
+// GetTranscript calls flow.v1.TranscriptService.GetTranscript.
+func (c *transcriptServiceClient) GetTranscript(ctx context.Context, req *connect.Request[v1.GetTranscriptRequest]) (*connect.Response[v1.GetTranscriptResponse], error) {
+	return c.getTranscript.CallUnary(ctx, req)
+}
+
+// TranscriptServiceHandler is an implementation of the flow.v1.TranscriptService service.
+type TranscriptServiceHandler interface {
+	GetTranscript(context.Context, *connect.Request[v1.GetTranscriptRequest]) (*connect.Response[v1.GetTranscriptResponse], error)
+}
+
+// NewTranscriptServiceHandler builds an HTTP handler from the service implementation. It returns
+// the path on which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewTranscriptServiceHandler(svc TranscriptServiceHandler, opts ...connect.HandlerOption) (string, http.Handler) {
+	transcriptServiceMethods := v1.File_flow_v1_transcript_proto.Services().ByName("TranscriptService").Methods()
+	transcriptServiceGetTranscriptHandler := connect.NewUnaryHandler(
+		TranscriptServiceGetTranscriptProcedure,
+		svc.GetTranscript,
+		connect.WithSchema(transcriptServiceMethods.ByName("GetTranscript")),
+		connect.WithHandlerOptions(opts...),
+	)
+	return "/flow.v1.TranscriptService/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case TranscriptServiceGetTranscriptProcedure:
+			transcriptServiceGetTranscriptHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// UnimplementedTranscriptServiceHandler returns CodeUnimplemented from all methods.
+type UnimplementedTranscriptServiceHandler struct{}
+
+func (UnimplementedTranscriptServiceHandler) GetTranscript(context.Context, *connect.Request[v1.GetTranscriptRequest]) (*connect.Response[v1.GetTranscriptResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("flow.v1.TranscriptService.GetTranscript is not implemented"))
+}
@@ -0,0 +1,312 @@
+// Code generated by protoc-gen-connect-go. DO NOT EDIT.
+//
+// Source: flow/v1/worklet.proto
+
+package flowv1connect
+
+import (
+	connect "connectrpc.com/connect"
+	context "context"
+	errors "errors"
+	v1 "github.com/breadchris/flow/gen/flow/v1"
+	http "net/http"
+	strings "strings"
+)
+
+// This is a compile-time assertion to ensure that this generated file and the connect package are
+// compatible. If you get a compiler error that this constant is not defined, this code was
+// generated with a version of connect newer than the one compiled into your binary. You can fix the
+// problem by either regenerating this code with an older version of connect or updating the connect
+// version compiled into your binary.
+const _ = connect.IsAtLeastVersion1_13_0
+
+const (
+	// WorkletServiceName is the fully-qualified name of the WorkletService service.
+	WorkletServiceName = "flow.v1.WorkletService"
+)
+
+// These constants are the fully-qualified names of the RPCs defined in this package. They're
+// exposed at runtime as Spec.Procedure and as the final two segments of the HTTP route.
+//
+// Note that these are different from the fully-qualified method names used by
+// google.golang.org/protobuf/reflect/protoreflect. To convert from these constants to
+// reflection-formatted method names, remove the leading slash and convert the remaining slash to a
+// period.
+const (
+	// WorkletServiceCreateWorkletProcedure is the fully-qualified name of the WorkletService's
+	// CreateWorklet RPC.
+	WorkletServiceCreateWorkletProcedure = "/flow.v1.WorkletService/CreateWorklet"
+	// WorkletServiceGetWorkletProcedure is the fully-qualified name of the WorkletService's GetWorklet
+	// RPC.
+	WorkletServiceGetWorkletProcedure = "/flow.v1.WorkletService/GetWorklet"
+	// WorkletServiceListWorkletsProcedure is the fully-qualified name of the WorkletService's
+	// ListWorklets RPC.
+	WorkletServiceListWorkletsProcedure = "/flow.v1.WorkletService/ListWorklets"
+	// WorkletServiceDeleteWorkletProcedure is the fully-qualified name of the WorkletService's
+	// DeleteWorklet RPC.
+	WorkletServiceDeleteWorkletProcedure = "/flow.v1.WorkletService/DeleteWorklet"
+	// WorkletServiceStopWorkletProcedure is the fully-qualified name of the WorkletService's
+	// StopWorklet RPC.
+	WorkletServiceStopWorkletProcedure = "/flow.v1.WorkletService/StopWorklet"
+	// WorkletServiceRestartWorkletProcedure is the fully-qualified name of the WorkletService's
+	// RestartWorklet RPC.
+	WorkletServiceRestartWorkletProcedure = "/flow.v1.WorkletService/RestartWorklet"
+	// WorkletServiceSendWorkletPromptProcedure is the fully-qualified name of the WorkletService's
+	// SendWorkletPrompt RPC.
+	WorkletServiceSendWorkletPromptProcedure = "/flow.v1.WorkletService/SendWorkletPrompt"
+	// WorkletServiceGetWorkletLogsProcedure is the fully-qualified name of the WorkletService's
+	// GetWorkletLogs RPC.
+	WorkletServiceGetWorkletLogsProcedure = "/flow.v1.WorkletService/GetWorkletLogs"
+)
+
+// WorkletServiceClient is a client for the flow.v1.WorkletService service.
+type WorkletServiceClient interface {
+	CreateWorklet(context.Context, *connect.Request[v1.CreateWorkletRequest]) (*connect.Response[v1.CreateWorkletResponse], error)
+	GetWorklet(context.Context, *connect.Request[v1.GetWorkletRequest]) (*connect.Response[v1.GetWorkletResponse], error)
+	ListWorklets(context.Context, *connect.Request[v1.ListWorkletsRequest]) (*connect.Response[v1.ListWorkletsResponse], error)
+	DeleteWorklet(context.Context, *connect.Request[v1.DeleteWorkletRequest]) (*connect.Response[v1.DeleteWorkletResponse], error)
+	StopWorklet(context.Context, *connect.Request[v1.StopWorkletRequest]) (*connect.Response[v1.StopWorkletResponse], error)
+	RestartWorklet(context.Context, *connect.Request[v1.RestartWorkletRequest]) (*connect.Response[v1.RestartWorkletResponse], error)
+	SendWorkletPrompt(context.Context, *connect.Request[v1.SendWorkletPromptRequest]) (*connect.Response[v1.SendWorkletPromptResponse], error)
+	GetWorkletLogs(context.Context, *connect.Request[v1.GetWorkletLogsRequest]) (*connect.Response[v1.GetWorkletLogsResponse], error)
+}
+
+// NewWorkletServiceClient constructs a client for the flow.v1.WorkletService service. By default,
+// it uses the Connect protocol with the binary Protobuf Codec, asks for gzipped responses, and
+// sends uncompressed requests. To use the gRPC or gRPC-Web protocols, supply the connect.WithGRPC()
+// or connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewWorkletServiceClient(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) WorkletServiceClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	workletServiceMethods := v1.File_flow_v1_worklet_proto.Services().ByName("WorkletService").Methods()
+	return &workletServiceClient{
+		createWorklet: connect.NewClient[v1.CreateWorkletRequest, v1.CreateWorkletResponse](
+			httpClient,
+			baseURL+WorkletServiceCreateWorkletProcedure,
+			connect.WithSchema(workletServiceMethods.ByName("CreateWorklet")),
+			connect.WithClientOptions(opts...),
+		),
+		getWorklet: connect.NewClient[v1.GetWorkletRequest, v1.GetWorkletResponse](
+			httpClient,
+			baseURL+WorkletServiceGetWorkletProcedure,
+			connect.WithSchema(workletServiceMethods.ByName("GetWorklet")),
+			connect.WithClientOptions(opts...),
+		),
+		listWorklets: connect.NewClient[v1.ListWorkletsRequest, v1.ListWorkletsResponse](
+			httpClient,
+			baseURL+WorkletServiceListWorkletsProcedure,
+			connect.WithSchema(workletServiceMethods.ByName("ListWorklets")),
+			connect.WithClientOptions(opts...),
+		),
+		deleteWorklet: connect.NewClient[v1.DeleteWorkletRequest, v1.DeleteWorkletResponse](
+			httpClient,
+			baseURL+WorkletServiceDeleteWorkletProcedure,
+			connect.WithSchema(workletServiceMethods.ByName("DeleteWorklet")),
+			connect.WithClientOptions(opts...),
+		),
+		stopWorklet: connect.NewClient[v1.StopWorkletRequest, v1.StopWorkletResponse](
+			httpClient,
+			baseURL+WorkletServiceStopWorkletProcedure,
+			connect.WithSchema(workletServiceMethods.ByName("StopWorklet")),
+			connect.WithClientOptions(opts...),
+		),
+		restartWorklet: connect.NewClient[v1.RestartWorkletRequest, v1.RestartWorkletResponse](
+			httpClient,
+			baseURL+WorkletServiceRestartWorkletProcedure,
+			connect.WithSchema(workletServiceMethods.ByName("RestartWorklet")),
+			connect.WithClientOptions(opts...),
+		),
+		sendWorkletPrompt: connect.NewClient[v1.SendWorkletPromptRequest, v1.SendWorkletPromptResponse](
+			httpClient,
+			baseURL+WorkletServiceSendWorkletPromptProcedure,
+			connect.WithSchema(workletServiceMethods.ByName("SendWorkletPrompt")),
+			connect.WithClientOptions(opts...),
+		),
+		getWorkletLogs: connect.NewClient[v1.GetWorkletLogsRequest, v1.GetWorkletLogsResponse](
+			httpClient,
+			baseURL+WorkletServiceGetWorkletLogsProcedure,
+			connect.WithSchema(workletServiceMethods.ByName("GetWorkletLogs")),
+			connect.WithClientOptions(opts...),
+		),
+	}
+}
+
+// workletServiceClient implements WorkletServiceClient.
+type workletServiceClient struct {
+	createWorklet     *connect.Client[v1.CreateWorkletRequest, v1.CreateWorkletResponse]
+	getWorklet        *connect.Client[v1.GetWorkletRequest, v1.GetWorkletResponse]
+	listWorklets      *connect.Client[v1.ListWorkletsRequest, v1.ListWorkletsResponse]
+	deleteWorklet     *connect.Client[v1.DeleteWorkletRequest, v1.DeleteWorkletResponse]
+	stopWorklet       *connect.Client[v1.StopWorkletRequest, v1.StopWorkletResponse]
+	restartWorklet    *connect.Client[v1.RestartWorkletRequest, v1.RestartWorkletResponse]
+	sendWorkletPrompt *connect.Client[v1.SendWorkletPromptRequest, v1.SendWorkletPromptResponse]
+	getWorkletLogs    *connect.Client[v1.GetWorkletLogsRequest, v1.GetWorkletLogsResponse]
+}
+
+// CreateWorklet calls flow.v1.WorkletService.CreateWorklet.
+func (c *workletServiceClient) CreateWorklet(ctx context.Context, req *connect.Request[v1.CreateWorkletRequest]) (*connect.Response[v1.CreateWorkletResponse], error) {
+	return c.createWorklet.CallUnary(ctx, req)
+}
+
+// GetWorklet calls flow.v1.WorkletService.GetWorklet.
+func (c *workletServiceClient) GetWorklet(ctx context.Context, req *connect.Request[v1.GetWorkletRequest]) (*connect.Response[v1.GetWorkletResponse], error) {
+	return c.getWorklet.CallUnary(ctx, req)
+}
+
+// ListWorklets calls flow.v1.WorkletService.ListWorklets.
+func (c *workletServiceClient) ListWorklets(ctx context.Context, req *connect.Request[v1.ListWorkletsRequest]) (*connect.Response[v1.ListWorkletsResponse], error) {
+	return c.listWorklets.CallUnary(ctx, req)
+}
+
+// DeleteWorklet calls flow.v1.WorkletService.DeleteWorklet.
+func (c *workletServiceClient) DeleteWorklet(ctx context.Context, req *connect.Request[v1.DeleteWorkletRequest]) (*connect.Response[v1.DeleteWorkletResponse], error) {
+	return c.deleteWorklet.CallUnary(ctx, req)
+}
+
+// StopWorklet calls flow.v1.WorkletService.StopWorklet.
+func (c *workletServiceClient) StopWorklet(ctx context.Context, req *connect.Request[v1.StopWorkletRequest]) (*connect.Response[v1.StopWorkletResponse], error) {
+	return c.stopWorklet.CallUnary(ctx, req)
+}
+
+// RestartWorklet calls flow.v1.WorkletService.RestartWorklet.
+func (c *workletServiceClient) RestartWorklet(ctx context.Context, req *connect.Request[v1.RestartWorkletRequest]) (*connect.Response[v1.RestartWorkletResponse], error) {
+	return c.restartWorklet.CallUnary(ctx, req)
+}
+
+// SendWorkletPrompt calls flow.v1.WorkletService.SendWorkletPrompt.
+func (c *workletServiceClient) SendWorkletPrompt(ctx context.Context, req *connect.Request[v1.SendWorkletPromptRequest]) (*connect.Response[v1.SendWorkletPromptResponse], error) {
+	return c.sendWorkletPrompt.CallUnary(ctx, req)
+}
+
+// GetWorkletLogs calls flow.v1.WorkletService.GetWorkletLogs.
+func (c *workletServiceClient) GetWorkletLogs(ctx context.Context, req *connect.Request[v1.GetWorkletLogsRequest]) (*connect.Response[v1.GetWorkletLogsResponse], error) {
+	return c.getWorkletLogs.CallUnary(ctx, req)
+}
+
+// WorkletServiceHandler is an implementation of the flow.v1.WorkletService service.
+type WorkletServiceHandler interface {
+	CreateWorklet(context.Context, *connect.Request[v1.CreateWorkletRequest]) (*connect.Response[v1.CreateWorkletResponse], error)
+	GetWorklet(context.Context, *connect.Request[v1.GetWorkletRequest]) (*connect.Response[v1.GetWorkletResponse], error)
+	ListWorklets(context.Context, *connect.Request[v1.ListWorkletsRequest]) (*connect.Response[v1.ListWorkletsResponse], error)
+	DeleteWorklet(context.Context, *connect.Request[v1.DeleteWorkletRequest]) (*connect.Response[v1.DeleteWorkletResponse], error)
+	StopWorklet(context.Context, *connect.Request[v1.StopWorkletRequest]) (*connect.Response[v1.StopWorkletResponse], error)
+	RestartWorklet(context.Context, *connect.Request[v1.RestartWorkletRequest]) (*connect.Response[v1.RestartWorkletResponse], error)
+	SendWorkletPrompt(context.Context, *connect.Request[v1.SendWorkletPromptRequest]) (*connect.Response[v1.SendWorkletPromptResponse], error)
+	GetWorkletLogs(context.Context, *connect.Request[v1.GetWorkletLogsRequest]) (*connect.Response[v1.GetWorkletLogsResponse], error)
+}
+
+// NewWorkletServiceHandler builds an HTTP handler from the service implementation. It returns the
+// path on which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewWorkletServiceHandler(svc WorkletServiceHandler, opts ...connect.HandlerOption) (string, http.Handler) {
+	workletServiceMethods := v1.File_flow_v1_worklet_proto.Services().ByName("WorkletService").Methods()
+	workletServiceCreateWorkletHandler := connect.NewUnaryHandler(
+		WorkletServiceCreateWorkletProcedure,
+		svc.CreateWorklet,
+		connect.WithSchema(workletServiceMethods.ByName("CreateWorklet")),
+		connect.WithHandlerOptions(opts...),
+	)
+	workletServiceGetWorkletHandler := connect.NewUnaryHandler(
+		WorkletServiceGetWorkletProcedure,
+		svc.GetWorklet,
+		connect.WithSchema(workletServiceMethods.ByName("GetWorklet")),
+		connect.WithHandlerOptions(opts...),
+	)
+	workletServiceListWorkletsHandler := connect.NewUnaryHandler(
+		WorkletServiceListWorkletsProcedure,
+		svc.ListWorklets,
+		connect.WithSchema(workletServiceMethods.ByName("ListWorklets")),
+		connect.WithHandlerOptions(opts...),
+	)
+	workletServiceDeleteWorkletHandler := connect.NewUnaryHandler(
+		WorkletServiceDeleteWorkletProcedure,
+		svc.DeleteWorklet,
+		connect.WithSchema(workletServiceMethods.ByName("DeleteWorklet")),
+		connect.WithHandlerOptions(opts...),
+	)
+	workletServiceStopWorkletHandler := connect.NewUnaryHandler(
+		WorkletServiceStopWorkletProcedure,
+		svc.StopWorklet,
+		connect.WithSchema(workletServiceMethods.ByName("StopWorklet")),
+		connect.WithHandlerOptions(opts...),
+	)
+	workletServiceRestartWorkletHandler := connect.NewUnaryHandler(
+		WorkletServiceRestartWorkletProcedure,
+		svc.RestartWorklet,
+		connect.WithSchema(workletServiceMethods.ByName("RestartWorklet")),
+		connect.WithHandlerOptions(opts...),
+	)
+	workletServiceSendWorkletPromptHandler := connect.NewUnaryHandler(
+		WorkletServiceSendWorkletPromptProcedure,
+		svc.SendWorkletPrompt,
+		connect.WithSchema(workletServiceMethods.ByName("SendWorkletPrompt")),
+		connect.WithHandlerOptions(opts...),
+	)
+	workletServiceGetWorkletLogsHandler := connect.NewUnaryHandler(
+		WorkletServiceGetWorkletLogsProcedure,
+		svc.GetWorkletLogs,
+		connect.WithSchema(workletServiceMethods.ByName("GetWorkletLogs")),
+		connect.WithHandlerOptions(opts...),
+	)
+	return "/flow.v1.WorkletService/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case WorkletServiceCreateWorkletProcedure:
+			workletServiceCreateWorkletHandler.ServeHTTP(w, r)
+		case WorkletServiceGetWorkletProcedure:
+			workletServiceGetWorkletHandler.ServeHTTP(w, r)
+		case WorkletServiceListWorkletsProcedure:
+			workletServiceListWorkletsHandler.ServeHTTP(w, r)
+		case WorkletServiceDeleteWorkletProcedure:
+			workletServiceDeleteWorkletHandler.ServeHTTP(w, r)
+		case WorkletServiceStopWorkletProcedure:
+			workletServiceStopWorkletHandler.ServeHTTP(w, r)
+		case WorkletServiceRestartWorkletProcedure:
+			workletServiceRestartWorkletHandler.ServeHTTP(w, r)
+		case WorkletServiceSendWorkletPromptProcedure:
+			workletServiceSendWorkletPromptHandler.ServeHTTP(w, r)
+		case WorkletServiceGetWorkletLogsProcedure:
+			workletServiceGetWorkletLogsHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// UnimplementedWorkletServiceHandler returns CodeUnimplemented from all methods.
+type UnimplementedWorkletServiceHandler struct{}
+
+func (UnimplementedWorkletServiceHandler) CreateWorklet(context.Context, *connect.Request[v1.CreateWorkletRequest]) (*connect.Response[v1.CreateWorkletResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("flow.v1.WorkletService.CreateWorklet is not implemented"))
+}
+
+func (UnimplementedWorkletServiceHandler) GetWorklet(context.Context, *connect.Request[v1.GetWorkletRequest]) (*connect.Response[v1.GetWorkletResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("flow.v1.WorkletService.GetWorklet is not implemented"))
+}
+
+func (UnimplementedWorkletServiceHandler) ListWorklets(context.Context, *connect.Request[v1.ListWorkletsRequest]) (*connect.Response[v1.ListWorkletsResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("flow.v1.WorkletService.ListWorklets is not implemented"))
+}
+
+func (UnimplementedWorkletServiceHandler) DeleteWorklet(context.Context, *connect.Request[v1.DeleteWorkletRequest]) (*connect.Response[v1.DeleteWorkletResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("flow.v1.WorkletService.DeleteWorklet is not implemented"))
+}
+
+func (UnimplementedWorkletServiceHandler) StopWorklet(context.Context, *connect.Request[v1.StopWorkletRequest]) (*connect.Response[v1.StopWorkletResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("flow.v1.WorkletService.StopWorklet is not implemented"))
+}
+
+func (UnimplementedWorkletServiceHandler) RestartWorklet(context.Context, *connect.Request[v1.RestartWorkletRequest]) (*connect.Response[v1.RestartWorkletResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("flow.v1.WorkletService.RestartWorklet is not implemented"))
+}
+
+func (UnimplementedWorkletServiceHandler) SendWorkletPrompt(context.Context, *connect.Request[v1.SendWorkletPromptRequest]) (*connect.Response[v1.SendWorkletPromptResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("flow.v1.WorkletService.SendWorkletPrompt is not implemented"))
+}
+
+func (UnimplementedWorkletServiceHandler) GetWorkletLogs(context.Context, *connect.Request[v1.GetWorkletLogsRequest]) (*connect.Response[v1.GetWorkletLogsResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("flow.v1.WorkletService.GetWorkletLogs is not implemented"))
+}
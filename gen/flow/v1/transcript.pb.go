@@ -0,0 +1,197 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: flow/v1/transcript.proto
+
+package flowv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetTranscriptRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTranscriptRequest) Reset() {
+	*x = GetTranscriptRequest{}
+	mi := &file_flow_v1_transcript_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTranscriptRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTranscriptRequest) ProtoMessage() {}
+
+func (x *GetTranscriptRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_flow_v1_transcript_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTranscriptRequest.ProtoReflect.Descriptor instead.
+func (*GetTranscriptRequest) Descriptor() ([]byte, []int) {
+	return file_flow_v1_transcript_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetTranscriptRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+type GetTranscriptResponse struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	SessionId string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Title     string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	// messages are the stored Claude messages in their original JSON shape
+	Messages      []*structpb.Value `protobuf:"bytes,3,rep,name=messages,proto3" json:"messages,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTranscriptResponse) Reset() {
+	*x = GetTranscriptResponse{}
+	mi := &file_flow_v1_transcript_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTranscriptResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTranscriptResponse) ProtoMessage() {}
+
+func (x *GetTranscriptResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_flow_v1_transcript_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTranscriptResponse.ProtoReflect.Descriptor instead.
+func (*GetTranscriptResponse) Descriptor() ([]byte, []int) {
+	return file_flow_v1_transcript_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetTranscriptResponse) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *GetTranscriptResponse) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *GetTranscriptResponse) GetMessages() []*structpb.Value {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+var File_flow_v1_transcript_proto protoreflect.FileDescriptor
+
+const file_flow_v1_transcript_proto_rawDesc = "" +
+	"\n" +
+	"\x18flow/v1/transcript.proto\x12\aflow.v1\x1a\x1cgoogle/protobuf/struct.proto\"5\n" +
+	"\x14GetTranscriptRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\"\x80\x01\n" +
+	"\x15GetTranscriptResponse\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x122\n" +
+	"\bmessages\x18\x03 \x03(\v2\x16.google.protobuf.ValueR\bmessages2c\n" +
+	"\x11TranscriptService\x12N\n" +
+	"\rGetTranscript\x12\x1d.flow.v1.GetTranscriptRequest\x1a\x1e.flow.v1.GetTranscriptResponseB/Z-github.com/breadchris/flow/gen/flow/v1;flowv1b\x06proto3"
+
+var (
+	file_flow_v1_transcript_proto_rawDescOnce sync.Once
+	file_flow_v1_transcript_proto_rawDescData []byte
+)
+
+func file_flow_v1_transcript_proto_rawDescGZIP() []byte {
+	file_flow_v1_transcript_proto_rawDescOnce.Do(func() {
+		file_flow_v1_transcript_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_flow_v1_transcript_proto_rawDesc), len(file_flow_v1_transcript_proto_rawDesc)))
+	})
+	return file_flow_v1_transcript_proto_rawDescData
+}
+
+var file_flow_v1_transcript_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_flow_v1_transcript_proto_goTypes = []any{
+	(*GetTranscriptRequest)(nil),  // 0: flow.v1.GetTranscriptRequest
+	(*GetTranscriptResponse)(nil), // 1: flow.v1.GetTranscriptResponse
+	(*structpb.Value)(nil),        // 2: google.protobuf.Value
+}
+var file_flow_v1_transcript_proto_depIdxs = []int32{
+	2, // 0: flow.v1.GetTranscriptResponse.messages:type_name -> google.protobuf.Value
+	0, // 1: flow.v1.TranscriptService.GetTranscript:input_type -> flow.v1.GetTranscriptRequest
+	1, // 2: flow.v1.TranscriptService.GetTranscript:output_type -> flow.v1.GetTranscriptResponse
+	2, // [2:3] is the sub-list for method output_type
+	1, // [1:2] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_flow_v1_transcript_proto_init() }
+func file_flow_v1_transcript_proto_init() {
+	if File_flow_v1_transcript_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_flow_v1_transcript_proto_rawDesc), len(file_flow_v1_transcript_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_flow_v1_transcript_proto_goTypes,
+		DependencyIndexes: file_flow_v1_transcript_proto_depIdxs,
+		MessageInfos:      file_flow_v1_transcript_proto_msgTypes,
+	}.Build()
+	File_flow_v1_transcript_proto = out.File
+	file_flow_v1_transcript_proto_goTypes = nil
+	file_flow_v1_transcript_proto_depIdxs = nil
+}
@@ -0,0 +1,1088 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: flow/v1/worklet.proto
+
+package flowv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Worklet struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Description   string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	Status        string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	GitRepo       string                 `protobuf:"bytes,5,opt,name=git_repo,json=gitRepo,proto3" json:"git_repo,omitempty"`
+	Branch        string                 `protobuf:"bytes,6,opt,name=branch,proto3" json:"branch,omitempty"`
+	WebUrl        string                 `protobuf:"bytes,7,opt,name=web_url,json=webUrl,proto3" json:"web_url,omitempty"`
+	Port          int32                  `protobuf:"varint,8,opt,name=port,proto3" json:"port,omitempty"`
+	Environment   map[string]string      `protobuf:"bytes,9,rep,name=environment,proto3" json:"environment,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	LastPrompt    string                 `protobuf:"bytes,10,opt,name=last_prompt,json=lastPrompt,proto3" json:"last_prompt,omitempty"`
+	LastError     string                 `protobuf:"bytes,11,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Worklet) Reset() {
+	*x = Worklet{}
+	mi := &file_flow_v1_worklet_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Worklet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Worklet) ProtoMessage() {}
+
+func (x *Worklet) ProtoReflect() protoreflect.Message {
+	mi := &file_flow_v1_worklet_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Worklet.ProtoReflect.Descriptor instead.
+func (*Worklet) Descriptor() ([]byte, []int) {
+	return file_flow_v1_worklet_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Worklet) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Worklet) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Worklet) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Worklet) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Worklet) GetGitRepo() string {
+	if x != nil {
+		return x.GitRepo
+	}
+	return ""
+}
+
+func (x *Worklet) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+func (x *Worklet) GetWebUrl() string {
+	if x != nil {
+		return x.WebUrl
+	}
+	return ""
+}
+
+func (x *Worklet) GetPort() int32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+func (x *Worklet) GetEnvironment() map[string]string {
+	if x != nil {
+		return x.Environment
+	}
+	return nil
+}
+
+func (x *Worklet) GetLastPrompt() string {
+	if x != nil {
+		return x.LastPrompt
+	}
+	return ""
+}
+
+func (x *Worklet) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+func (x *Worklet) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Worklet) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type CreateWorkletRequest struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Name        string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Description string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	GitRepo     string                 `protobuf:"bytes,3,opt,name=git_repo,json=gitRepo,proto3" json:"git_repo,omitempty"`
+	Branch      string                 `protobuf:"bytes,4,opt,name=branch,proto3" json:"branch,omitempty"`
+	BasePrompt  string                 `protobuf:"bytes,5,opt,name=base_prompt,json=basePrompt,proto3" json:"base_prompt,omitempty"`
+	Environment map[string]string      `protobuf:"bytes,6,rep,name=environment,proto3" json:"environment,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Claude tools the worklet's sessions may use; empty keeps the defaults
+	AllowedTools    []string `protobuf:"bytes,7,rep,name=allowed_tools,json=allowedTools,proto3" json:"allowed_tools,omitempty"`
+	DisallowedTools []string `protobuf:"bytes,8,rep,name=disallowed_tools,json=disallowedTools,proto3" json:"disallowed_tools,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *CreateWorkletRequest) Reset() {
+	*x = CreateWorkletRequest{}
+	mi := &file_flow_v1_worklet_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateWorkletRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateWorkletRequest) ProtoMessage() {}
+
+func (x *CreateWorkletRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_flow_v1_worklet_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateWorkletRequest.ProtoReflect.Descriptor instead.
+func (*CreateWorkletRequest) Descriptor() ([]byte, []int) {
+	return file_flow_v1_worklet_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateWorkletRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CreateWorkletRequest) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *CreateWorkletRequest) GetGitRepo() string {
+	if x != nil {
+		return x.GitRepo
+	}
+	return ""
+}
+
+func (x *CreateWorkletRequest) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+func (x *CreateWorkletRequest) GetBasePrompt() string {
+	if x != nil {
+		return x.BasePrompt
+	}
+	return ""
+}
+
+func (x *CreateWorkletRequest) GetEnvironment() map[string]string {
+	if x != nil {
+		return x.Environment
+	}
+	return nil
+}
+
+func (x *CreateWorkletRequest) GetAllowedTools() []string {
+	if x != nil {
+		return x.AllowedTools
+	}
+	return nil
+}
+
+func (x *CreateWorkletRequest) GetDisallowedTools() []string {
+	if x != nil {
+		return x.DisallowedTools
+	}
+	return nil
+}
+
+type CreateWorkletResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Worklet       *Worklet               `protobuf:"bytes,1,opt,name=worklet,proto3" json:"worklet,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateWorkletResponse) Reset() {
+	*x = CreateWorkletResponse{}
+	mi := &file_flow_v1_worklet_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateWorkletResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateWorkletResponse) ProtoMessage() {}
+
+func (x *CreateWorkletResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_flow_v1_worklet_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateWorkletResponse.ProtoReflect.Descriptor instead.
+func (*CreateWorkletResponse) Descriptor() ([]byte, []int) {
+	return file_flow_v1_worklet_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CreateWorkletResponse) GetWorklet() *Worklet {
+	if x != nil {
+		return x.Worklet
+	}
+	return nil
+}
+
+type GetWorkletRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetWorkletRequest) Reset() {
+	*x = GetWorkletRequest{}
+	mi := &file_flow_v1_worklet_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetWorkletRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetWorkletRequest) ProtoMessage() {}
+
+func (x *GetWorkletRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_flow_v1_worklet_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetWorkletRequest.ProtoReflect.Descriptor instead.
+func (*GetWorkletRequest) Descriptor() ([]byte, []int) {
+	return file_flow_v1_worklet_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetWorkletRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type GetWorkletResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Worklet       *Worklet               `protobuf:"bytes,1,opt,name=worklet,proto3" json:"worklet,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetWorkletResponse) Reset() {
+	*x = GetWorkletResponse{}
+	mi := &file_flow_v1_worklet_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetWorkletResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetWorkletResponse) ProtoMessage() {}
+
+func (x *GetWorkletResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_flow_v1_worklet_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetWorkletResponse.ProtoReflect.Descriptor instead.
+func (*GetWorkletResponse) Descriptor() ([]byte, []int) {
+	return file_flow_v1_worklet_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetWorkletResponse) GetWorklet() *Worklet {
+	if x != nil {
+		return x.Worklet
+	}
+	return nil
+}
+
+type ListWorkletsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListWorkletsRequest) Reset() {
+	*x = ListWorkletsRequest{}
+	mi := &file_flow_v1_worklet_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListWorkletsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListWorkletsRequest) ProtoMessage() {}
+
+func (x *ListWorkletsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_flow_v1_worklet_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListWorkletsRequest.ProtoReflect.Descriptor instead.
+func (*ListWorkletsRequest) Descriptor() ([]byte, []int) {
+	return file_flow_v1_worklet_proto_rawDescGZIP(), []int{5}
+}
+
+type ListWorkletsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Worklets      []*Worklet             `protobuf:"bytes,1,rep,name=worklets,proto3" json:"worklets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListWorkletsResponse) Reset() {
+	*x = ListWorkletsResponse{}
+	mi := &file_flow_v1_worklet_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListWorkletsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListWorkletsResponse) ProtoMessage() {}
+
+func (x *ListWorkletsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_flow_v1_worklet_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListWorkletsResponse.ProtoReflect.Descriptor instead.
+func (*ListWorkletsResponse) Descriptor() ([]byte, []int) {
+	return file_flow_v1_worklet_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListWorkletsResponse) GetWorklets() []*Worklet {
+	if x != nil {
+		return x.Worklets
+	}
+	return nil
+}
+
+type DeleteWorkletRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteWorkletRequest) Reset() {
+	*x = DeleteWorkletRequest{}
+	mi := &file_flow_v1_worklet_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteWorkletRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteWorkletRequest) ProtoMessage() {}
+
+func (x *DeleteWorkletRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_flow_v1_worklet_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteWorkletRequest.ProtoReflect.Descriptor instead.
+func (*DeleteWorkletRequest) Descriptor() ([]byte, []int) {
+	return file_flow_v1_worklet_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DeleteWorkletRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type DeleteWorkletResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteWorkletResponse) Reset() {
+	*x = DeleteWorkletResponse{}
+	mi := &file_flow_v1_worklet_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteWorkletResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteWorkletResponse) ProtoMessage() {}
+
+func (x *DeleteWorkletResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_flow_v1_worklet_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteWorkletResponse.ProtoReflect.Descriptor instead.
+func (*DeleteWorkletResponse) Descriptor() ([]byte, []int) {
+	return file_flow_v1_worklet_proto_rawDescGZIP(), []int{8}
+}
+
+type StopWorkletRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StopWorkletRequest) Reset() {
+	*x = StopWorkletRequest{}
+	mi := &file_flow_v1_worklet_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StopWorkletRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StopWorkletRequest) ProtoMessage() {}
+
+func (x *StopWorkletRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_flow_v1_worklet_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StopWorkletRequest.ProtoReflect.Descriptor instead.
+func (*StopWorkletRequest) Descriptor() ([]byte, []int) {
+	return file_flow_v1_worklet_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *StopWorkletRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type StopWorkletResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StopWorkletResponse) Reset() {
+	*x = StopWorkletResponse{}
+	mi := &file_flow_v1_worklet_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StopWorkletResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StopWorkletResponse) ProtoMessage() {}
+
+func (x *StopWorkletResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_flow_v1_worklet_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StopWorkletResponse.ProtoReflect.Descriptor instead.
+func (*StopWorkletResponse) Descriptor() ([]byte, []int) {
+	return file_flow_v1_worklet_proto_rawDescGZIP(), []int{10}
+}
+
+type RestartWorkletRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestartWorkletRequest) Reset() {
+	*x = RestartWorkletRequest{}
+	mi := &file_flow_v1_worklet_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestartWorkletRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestartWorkletRequest) ProtoMessage() {}
+
+func (x *RestartWorkletRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_flow_v1_worklet_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestartWorkletRequest.ProtoReflect.Descriptor instead.
+func (*RestartWorkletRequest) Descriptor() ([]byte, []int) {
+	return file_flow_v1_worklet_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *RestartWorkletRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type RestartWorkletResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestartWorkletResponse) Reset() {
+	*x = RestartWorkletResponse{}
+	mi := &file_flow_v1_worklet_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestartWorkletResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestartWorkletResponse) ProtoMessage() {}
+
+func (x *RestartWorkletResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_flow_v1_worklet_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestartWorkletResponse.ProtoReflect.Descriptor instead.
+func (*RestartWorkletResponse) Descriptor() ([]byte, []int) {
+	return file_flow_v1_worklet_proto_rawDescGZIP(), []int{12}
+}
+
+type SendWorkletPromptRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Prompt        string                 `protobuf:"bytes,2,opt,name=prompt,proto3" json:"prompt,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendWorkletPromptRequest) Reset() {
+	*x = SendWorkletPromptRequest{}
+	mi := &file_flow_v1_worklet_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendWorkletPromptRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendWorkletPromptRequest) ProtoMessage() {}
+
+func (x *SendWorkletPromptRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_flow_v1_worklet_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendWorkletPromptRequest.ProtoReflect.Descriptor instead.
+func (*SendWorkletPromptRequest) Descriptor() ([]byte, []int) {
+	return file_flow_v1_worklet_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *SendWorkletPromptRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *SendWorkletPromptRequest) GetPrompt() string {
+	if x != nil {
+		return x.Prompt
+	}
+	return ""
+}
+
+type SendWorkletPromptResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PromptId      string                 `protobuf:"bytes,1,opt,name=prompt_id,json=promptId,proto3" json:"prompt_id,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendWorkletPromptResponse) Reset() {
+	*x = SendWorkletPromptResponse{}
+	mi := &file_flow_v1_worklet_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendWorkletPromptResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendWorkletPromptResponse) ProtoMessage() {}
+
+func (x *SendWorkletPromptResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_flow_v1_worklet_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendWorkletPromptResponse.ProtoReflect.Descriptor instead.
+func (*SendWorkletPromptResponse) Descriptor() ([]byte, []int) {
+	return file_flow_v1_worklet_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *SendWorkletPromptResponse) GetPromptId() string {
+	if x != nil {
+		return x.PromptId
+	}
+	return ""
+}
+
+func (x *SendWorkletPromptResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type GetWorkletLogsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetWorkletLogsRequest) Reset() {
+	*x = GetWorkletLogsRequest{}
+	mi := &file_flow_v1_worklet_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetWorkletLogsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetWorkletLogsRequest) ProtoMessage() {}
+
+func (x *GetWorkletLogsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_flow_v1_worklet_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetWorkletLogsRequest.ProtoReflect.Descriptor instead.
+func (*GetWorkletLogsRequest) Descriptor() ([]byte, []int) {
+	return file_flow_v1_worklet_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *GetWorkletLogsRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type GetWorkletLogsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BuildLogs     string                 `protobuf:"bytes,1,opt,name=build_logs,json=buildLogs,proto3" json:"build_logs,omitempty"`
+	LastError     string                 `protobuf:"bytes,2,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetWorkletLogsResponse) Reset() {
+	*x = GetWorkletLogsResponse{}
+	mi := &file_flow_v1_worklet_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetWorkletLogsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetWorkletLogsResponse) ProtoMessage() {}
+
+func (x *GetWorkletLogsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_flow_v1_worklet_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetWorkletLogsResponse.ProtoReflect.Descriptor instead.
+func (*GetWorkletLogsResponse) Descriptor() ([]byte, []int) {
+	return file_flow_v1_worklet_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *GetWorkletLogsResponse) GetBuildLogs() string {
+	if x != nil {
+		return x.BuildLogs
+	}
+	return ""
+}
+
+func (x *GetWorkletLogsResponse) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+var File_flow_v1_worklet_proto protoreflect.FileDescriptor
+
+const file_flow_v1_worklet_proto_rawDesc = "" +
+	"\n" +
+	"\x15flow/v1/worklet.proto\x12\aflow.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x82\x04\n" +
+	"\aWorklet\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x16\n" +
+	"\x06status\x18\x04 \x01(\tR\x06status\x12\x19\n" +
+	"\bgit_repo\x18\x05 \x01(\tR\agitRepo\x12\x16\n" +
+	"\x06branch\x18\x06 \x01(\tR\x06branch\x12\x17\n" +
+	"\aweb_url\x18\a \x01(\tR\x06webUrl\x12\x12\n" +
+	"\x04port\x18\b \x01(\x05R\x04port\x12C\n" +
+	"\venvironment\x18\t \x03(\v2!.flow.v1.Worklet.EnvironmentEntryR\venvironment\x12\x1f\n" +
+	"\vlast_prompt\x18\n" +
+	" \x01(\tR\n" +
+	"lastPrompt\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\v \x01(\tR\tlastError\x129\n" +
+	"\n" +
+	"created_at\x18\f \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x1a>\n" +
+	"\x10EnvironmentEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x82\x03\n" +
+	"\x14CreateWorkletRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12 \n" +
+	"\vdescription\x18\x02 \x01(\tR\vdescription\x12\x19\n" +
+	"\bgit_repo\x18\x03 \x01(\tR\agitRepo\x12\x16\n" +
+	"\x06branch\x18\x04 \x01(\tR\x06branch\x12\x1f\n" +
+	"\vbase_prompt\x18\x05 \x01(\tR\n" +
+	"basePrompt\x12P\n" +
+	"\venvironment\x18\x06 \x03(\v2..flow.v1.CreateWorkletRequest.EnvironmentEntryR\venvironment\x12#\n" +
+	"\rallowed_tools\x18\a \x03(\tR\fallowedTools\x12)\n" +
+	"\x10disallowed_tools\x18\b \x03(\tR\x0fdisallowedTools\x1a>\n" +
+	"\x10EnvironmentEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"C\n" +
+	"\x15CreateWorkletResponse\x12*\n" +
+	"\aworklet\x18\x01 \x01(\v2\x10.flow.v1.WorkletR\aworklet\"#\n" +
+	"\x11GetWorkletRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"@\n" +
+	"\x12GetWorkletResponse\x12*\n" +
+	"\aworklet\x18\x01 \x01(\v2\x10.flow.v1.WorkletR\aworklet\"\x15\n" +
+	"\x13ListWorkletsRequest\"D\n" +
+	"\x14ListWorkletsResponse\x12,\n" +
+	"\bworklets\x18\x01 \x03(\v2\x10.flow.v1.WorkletR\bworklets\"&\n" +
+	"\x14DeleteWorkletRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x17\n" +
+	"\x15DeleteWorkletResponse\"$\n" +
+	"\x12StopWorkletRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x15\n" +
+	"\x13StopWorkletResponse\"'\n" +
+	"\x15RestartWorkletRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x18\n" +
+	"\x16RestartWorkletResponse\"B\n" +
+	"\x18SendWorkletPromptRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06prompt\x18\x02 \x01(\tR\x06prompt\"P\n" +
+	"\x19SendWorkletPromptResponse\x12\x1b\n" +
+	"\tprompt_id\x18\x01 \x01(\tR\bpromptId\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\"'\n" +
+	"\x15GetWorkletLogsRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"V\n" +
+	"\x16GetWorkletLogsResponse\x12\x1d\n" +
+	"\n" +
+	"build_logs\x18\x01 \x01(\tR\tbuildLogs\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\x02 \x01(\tR\tlastError2\x90\x05\n" +
+	"\x0eWorkletService\x12N\n" +
+	"\rCreateWorklet\x12\x1d.flow.v1.CreateWorkletRequest\x1a\x1e.flow.v1.CreateWorkletResponse\x12E\n" +
+	"\n" +
+	"GetWorklet\x12\x1a.flow.v1.GetWorkletRequest\x1a\x1b.flow.v1.GetWorkletResponse\x12K\n" +
+	"\fListWorklets\x12\x1c.flow.v1.ListWorkletsRequest\x1a\x1d.flow.v1.ListWorkletsResponse\x12N\n" +
+	"\rDeleteWorklet\x12\x1d.flow.v1.DeleteWorkletRequest\x1a\x1e.flow.v1.DeleteWorkletResponse\x12H\n" +
+	"\vStopWorklet\x12\x1b.flow.v1.StopWorkletRequest\x1a\x1c.flow.v1.StopWorkletResponse\x12Q\n" +
+	"\x0eRestartWorklet\x12\x1e.flow.v1.RestartWorkletRequest\x1a\x1f.flow.v1.RestartWorkletResponse\x12Z\n" +
+	"\x11SendWorkletPrompt\x12!.flow.v1.SendWorkletPromptRequest\x1a\".flow.v1.SendWorkletPromptResponse\x12Q\n" +
+	"\x0eGetWorkletLogs\x12\x1e.flow.v1.GetWorkletLogsRequest\x1a\x1f.flow.v1.GetWorkletLogsResponseB/Z-github.com/breadchris/flow/gen/flow/v1;flowv1b\x06proto3"
+
+var (
+	file_flow_v1_worklet_proto_rawDescOnce sync.Once
+	file_flow_v1_worklet_proto_rawDescData []byte
+)
+
+func file_flow_v1_worklet_proto_rawDescGZIP() []byte {
+	file_flow_v1_worklet_proto_rawDescOnce.Do(func() {
+		file_flow_v1_worklet_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_flow_v1_worklet_proto_rawDesc), len(file_flow_v1_worklet_proto_rawDesc)))
+	})
+	return file_flow_v1_worklet_proto_rawDescData
+}
+
+var file_flow_v1_worklet_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_flow_v1_worklet_proto_goTypes = []any{
+	(*Worklet)(nil),                   // 0: flow.v1.Worklet
+	(*CreateWorkletRequest)(nil),      // 1: flow.v1.CreateWorkletRequest
+	(*CreateWorkletResponse)(nil),     // 2: flow.v1.CreateWorkletResponse
+	(*GetWorkletRequest)(nil),         // 3: flow.v1.GetWorkletRequest
+	(*GetWorkletResponse)(nil),        // 4: flow.v1.GetWorkletResponse
+	(*ListWorkletsRequest)(nil),       // 5: flow.v1.ListWorkletsRequest
+	(*ListWorkletsResponse)(nil),      // 6: flow.v1.ListWorkletsResponse
+	(*DeleteWorkletRequest)(nil),      // 7: flow.v1.DeleteWorkletRequest
+	(*DeleteWorkletResponse)(nil),     // 8: flow.v1.DeleteWorkletResponse
+	(*StopWorkletRequest)(nil),        // 9: flow.v1.StopWorkletRequest
+	(*StopWorkletResponse)(nil),       // 10: flow.v1.StopWorkletResponse
+	(*RestartWorkletRequest)(nil),     // 11: flow.v1.RestartWorkletRequest
+	(*RestartWorkletResponse)(nil),    // 12: flow.v1.RestartWorkletResponse
+	(*SendWorkletPromptRequest)(nil),  // 13: flow.v1.SendWorkletPromptRequest
+	(*SendWorkletPromptResponse)(nil), // 14: flow.v1.SendWorkletPromptResponse
+	(*GetWorkletLogsRequest)(nil),     // 15: flow.v1.GetWorkletLogsRequest
+	(*GetWorkletLogsResponse)(nil),    // 16: flow.v1.GetWorkletLogsResponse
+	nil,                               // 17: flow.v1.Worklet.EnvironmentEntry
+	nil,                               // 18: flow.v1.CreateWorkletRequest.EnvironmentEntry
+	(*timestamppb.Timestamp)(nil),     // 19: google.protobuf.Timestamp
+}
+var file_flow_v1_worklet_proto_depIdxs = []int32{
+	17, // 0: flow.v1.Worklet.environment:type_name -> flow.v1.Worklet.EnvironmentEntry
+	19, // 1: flow.v1.Worklet.created_at:type_name -> google.protobuf.Timestamp
+	19, // 2: flow.v1.Worklet.updated_at:type_name -> google.protobuf.Timestamp
+	18, // 3: flow.v1.CreateWorkletRequest.environment:type_name -> flow.v1.CreateWorkletRequest.EnvironmentEntry
+	0,  // 4: flow.v1.CreateWorkletResponse.worklet:type_name -> flow.v1.Worklet
+	0,  // 5: flow.v1.GetWorkletResponse.worklet:type_name -> flow.v1.Worklet
+	0,  // 6: flow.v1.ListWorkletsResponse.worklets:type_name -> flow.v1.Worklet
+	1,  // 7: flow.v1.WorkletService.CreateWorklet:input_type -> flow.v1.CreateWorkletRequest
+	3,  // 8: flow.v1.WorkletService.GetWorklet:input_type -> flow.v1.GetWorkletRequest
+	5,  // 9: flow.v1.WorkletService.ListWorklets:input_type -> flow.v1.ListWorkletsRequest
+	7,  // 10: flow.v1.WorkletService.DeleteWorklet:input_type -> flow.v1.DeleteWorkletRequest
+	9,  // 11: flow.v1.WorkletService.StopWorklet:input_type -> flow.v1.StopWorkletRequest
+	11, // 12: flow.v1.WorkletService.RestartWorklet:input_type -> flow.v1.RestartWorkletRequest
+	13, // 13: flow.v1.WorkletService.SendWorkletPrompt:input_type -> flow.v1.SendWorkletPromptRequest
+	15, // 14: flow.v1.WorkletService.GetWorkletLogs:input_type -> flow.v1.GetWorkletLogsRequest
+	2,  // 15: flow.v1.WorkletService.CreateWorklet:output_type -> flow.v1.CreateWorkletResponse
+	4,  // 16: flow.v1.WorkletService.GetWorklet:output_type -> flow.v1.GetWorkletResponse
+	6,  // 17: flow.v1.WorkletService.ListWorklets:output_type -> flow.v1.ListWorkletsResponse
+	8,  // 18: flow.v1.WorkletService.DeleteWorklet:output_type -> flow.v1.DeleteWorkletResponse
+	10, // 19: flow.v1.WorkletService.StopWorklet:output_type -> flow.v1.StopWorkletResponse
+	12, // 20: flow.v1.WorkletService.RestartWorklet:output_type -> flow.v1.RestartWorkletResponse
+	14, // 21: flow.v1.WorkletService.SendWorkletPrompt:output_type -> flow.v1.SendWorkletPromptResponse
+	16, // 22: flow.v1.WorkletService.GetWorkletLogs:output_type -> flow.v1.GetWorkletLogsResponse
+	15, // [15:23] is the sub-list for method output_type
+	7,  // [7:15] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_flow_v1_worklet_proto_init() }
+func file_flow_v1_worklet_proto_init() {
+	if File_flow_v1_worklet_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_flow_v1_worklet_proto_rawDesc), len(file_flow_v1_worklet_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   19,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_flow_v1_worklet_proto_goTypes,
+		DependencyIndexes: file_flow_v1_worklet_proto_depIdxs,
+		MessageInfos:      file_flow_v1_worklet_proto_msgTypes,
+	}.Build()
+	File_flow_v1_worklet_proto = out.File
+	file_flow_v1_worklet_proto_goTypes = nil
+	file_flow_v1_worklet_proto_depIdxs = nil
+}
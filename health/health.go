@@ -0,0 +1,150 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check reports an error when a dependency is not usable
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of a single readiness check
+type CheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Response is the JSON body returned by the health endpoints
+type Response struct {
+	Status string                 `json:"status"`
+	Uptime string                 `json:"uptime"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// Checker runs registered dependency checks for the readiness endpoint
+type Checker struct {
+	mu        sync.RWMutex
+	checks    map[string]Check
+	timeout   time.Duration
+	startTime time.Time
+}
+
+// NewChecker creates a checker that bounds each readiness probe by timeout
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		checks:    make(map[string]Check),
+		timeout:   timeout,
+		startTime: time.Now(),
+	}
+}
+
+// Register adds a named readiness check
+func (c *Checker) Register(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// Run executes all checks concurrently and returns the per-check results
+func (c *Checker) Run(ctx context.Context) map[string]CheckResult {
+	c.mu.RLock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = make(map[string]CheckResult, len(checks))
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			start := time.Now()
+			result := CheckResult{Status: StatusOK}
+			if err := runCheck(ctx, check); err != nil {
+				result.Status = StatusUnavailable
+				result.Error = err.Error()
+			}
+			result.DurationMs = time.Since(start).Milliseconds()
+
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// runCheck returns the check error, or the context error if the check outlives the deadline
+func runCheck(ctx context.Context, check Check) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// LivenessHandler reports that the process is up and serving requests
+func (c *Checker) LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, http.StatusOK, Response{
+			Status: StatusOK,
+			Uptime: time.Since(c.startTime).Round(time.Second).String(),
+		})
+	}
+}
+
+// ReadinessHandler reports 503 with per-dependency detail when any check fails
+func (c *Checker) ReadinessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		results := c.Run(r.Context())
+
+		resp := Response{
+			Status: StatusOK,
+			Uptime: time.Since(c.startTime).Round(time.Second).String(),
+			Checks: results,
+		}
+		code := http.StatusOK
+		for _, result := range results {
+			if result.Status != StatusOK {
+				resp.Status = StatusUnavailable
+				code = http.StatusServiceUnavailable
+				break
+			}
+		}
+
+		writeResponse(w, code, resp)
+	}
+}
+
+func writeResponse(w http.ResponseWriter, code int, resp Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,69 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLivenessHandler(t *testing.T) {
+	checker := NewChecker(time.Second)
+	checker.Register("broken", func(ctx context.Context) error {
+		return errors.New("down")
+	})
+
+	rec := httptest.NewRecorder()
+	checker.LivenessHandler()(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var resp Response
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, StatusOK, resp.Status)
+	assert.Empty(t, resp.Checks)
+}
+
+func TestReadinessHandler(t *testing.T) {
+	checker := NewChecker(time.Second)
+	checker.Register("db", func(ctx context.Context) error {
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	checker.ReadinessHandler()(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	checker.Register("docker", func(ctx context.Context) error {
+		return errors.New("daemon not reachable")
+	})
+
+	rec = httptest.NewRecorder()
+	checker.ReadinessHandler()(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	var resp Response
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, StatusUnavailable, resp.Status)
+	assert.Equal(t, StatusOK, resp.Checks["db"].Status)
+	assert.Equal(t, StatusUnavailable, resp.Checks["docker"].Status)
+	assert.Equal(t, "daemon not reachable", resp.Checks["docker"].Error)
+}
+
+func TestReadinessCheckTimeout(t *testing.T) {
+	checker := NewChecker(50 * time.Millisecond)
+	checker.Register("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	results := checker.Run(context.Background())
+	assert.Equal(t, StatusUnavailable, results["slow"].Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), results["slow"].Error)
+}
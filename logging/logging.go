@@ -0,0 +1,198 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/breadchris/flow/config"
+)
+
+const modulePrefix = "github.com/breadchris/flow/"
+
+type contextKey string
+
+const (
+	correlationIDKey contextKey = "correlation_id"
+	sessionIDKey     contextKey = "session_id"
+	requestIDKey     contextKey = "request_id"
+)
+
+// WithCorrelationID returns a context whose log records include the correlation ID
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey, id)
+}
+
+// WithSessionID returns a context whose log records include the session ID
+func WithSessionID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, sessionIDKey, id)
+}
+
+// WithRequestID returns a context whose log records include the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID stored in the context, if any
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// Setup installs the configured handler as the default slog logger.
+// The returned closer releases the output file, if one was opened.
+func Setup(cfg config.LoggingConfig) (io.Closer, error) {
+	out, closer, err := openOutput(cfg.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+
+	modules := make(map[string]slog.Level, len(cfg.Modules))
+	for module, levelStr := range cfg.Modules {
+		moduleLevel, err := ParseLevel(levelStr)
+		if err != nil {
+			closer.Close()
+			return nil, fmt.Errorf("invalid level for module %s: %w", module, err)
+		}
+		modules[module] = moduleLevel
+	}
+
+	// The base handler accepts everything at or above the lowest configured
+	// level; the module handler applies the effective level per record.
+	minLevel := level
+	for _, moduleLevel := range modules {
+		if moduleLevel < minLevel {
+			minLevel = moduleLevel
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: minLevel, AddSource: cfg.AddSource}
+	var base slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		base = slog.NewTextHandler(out, opts)
+	case "json":
+		base = slog.NewJSONHandler(out, opts)
+	default:
+		closer.Close()
+		return nil, fmt.Errorf("unknown log format: %s", cfg.Format)
+	}
+
+	slog.SetDefault(slog.New(NewHandler(base, level, modules)))
+	return closer, nil
+}
+
+// ParseLevel converts a level name (debug, info, warn, error) to a slog level
+func ParseLevel(s string) (slog.Level, error) {
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: %w", s, err)
+	}
+	return level, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+func openOutput(output string) (io.Writer, io.Closer, error) {
+	switch output {
+	case "", "stderr":
+		return os.Stderr, nopCloser{}, nil
+	case "stdout":
+		return os.Stdout, nopCloser{}, nil
+	default:
+		f, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		return f, f, nil
+	}
+}
+
+// Handler filters records by the level configured for the calling module and
+// adds correlation, session, and request IDs found in the context
+type Handler struct {
+	next    slog.Handler
+	level   slog.Level
+	modules map[string]slog.Level
+}
+
+// NewHandler wraps next with per-module levels and context attributes
+func NewHandler(next slog.Handler, level slog.Level, modules map[string]slog.Level) *Handler {
+	return &Handler{
+		next:    next,
+		level:   level,
+		modules: modules,
+	}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < h.levelFor(r.PC) {
+		return nil
+	}
+
+	for _, key := range []contextKey{correlationIDKey, sessionIDKey, requestIDKey} {
+		if id, ok := ctx.Value(key).(string); ok && id != "" {
+			r.AddAttrs(slog.String(string(key), id))
+		}
+	}
+
+	return h.next.Handle(ctx, r)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{next: h.next.WithAttrs(attrs), level: h.level, modules: h.modules}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name), level: h.level, modules: h.modules}
+}
+
+// levelFor returns the effective level for the module that logged at pc
+func (h *Handler) levelFor(pc uintptr) slog.Level {
+	if len(h.modules) == 0 || pc == 0 {
+		return h.level
+	}
+
+	if level, ok := h.modules[moduleName(pc)]; ok {
+		return level
+	}
+	return h.level
+}
+
+// moduleName returns the top-level package within this repo for the function at pc
+func moduleName(pc uintptr) string {
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	fn := frame.Function
+	if strings.HasPrefix(fn, "main.") {
+		return "main"
+	}
+	if !strings.HasPrefix(fn, modulePrefix) {
+		return ""
+	}
+
+	fn = strings.TrimPrefix(fn, modulePrefix)
+	if i := strings.IndexAny(fn, "./"); i >= 0 {
+		fn = fn[:i]
+	}
+	return fn
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLogger(buf *bytes.Buffer, level slog.Level, modules map[string]slog.Level) *slog.Logger {
+	base := slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return slog.New(NewHandler(base, level, modules))
+}
+
+func TestModuleLevelOverride(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := newTestLogger(&buf, slog.LevelDebug, map[string]slog.Level{"logging": slog.LevelWarn})
+	logger.Info("suppressed")
+	logger.Warn("kept")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], "kept")
+
+	buf.Reset()
+	logger = newTestLogger(&buf, slog.LevelWarn, map[string]slog.Level{"logging": slog.LevelDebug})
+	logger.Debug("enabled by module override")
+	assert.Contains(t, buf.String(), "enabled by module override")
+}
+
+func TestContextIDs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newTestLogger(&buf, slog.LevelInfo, nil)
+
+	ctx := WithCorrelationID(context.Background(), "corr-1")
+	ctx = WithSessionID(ctx, "sess-1")
+	ctx = WithRequestID(ctx, "req-1")
+	logger.InfoContext(ctx, "hello")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "corr-1", record["correlation_id"])
+	assert.Equal(t, "sess-1", record["session_id"])
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, "req-1", RequestID(ctx))
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelInfo, level)
+
+	level, err = ParseLevel("debug")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, level)
+
+	_, err = ParseLevel("verbose")
+	assert.Error(t, err)
+}
@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -14,39 +18,140 @@ import (
 	"github.com/breadchris/flow/code"
 	"github.com/breadchris/flow/config"
 	"github.com/breadchris/flow/deps"
+	"github.com/breadchris/flow/diagnostics"
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/health"
+	"github.com/breadchris/flow/logging"
+	"github.com/breadchris/flow/metrics"
+	"github.com/breadchris/flow/middleware"
+	"github.com/breadchris/flow/rpc"
+	"github.com/breadchris/flow/server"
 	"github.com/breadchris/flow/slackbot"
 	"github.com/breadchris/flow/worklet"
 	"github.com/gorilla/mux"
 )
 
 func main() {
+	addr := flag.String("addr", "", "listen address (overrides server.addr)")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file (overrides server.tls.cert_file)")
+	tlsKey := flag.String("tls-key", "", "TLS key file (overrides server.tls.key_file)")
+	flag.Parse()
+
 	cfg := config.LoadConfig()
+	if *addr != "" {
+		cfg.Server.Addr = *addr
+	}
+	if *tlsCert != "" {
+		cfg.Server.TLS.CertFile = *tlsCert
+	}
+	if *tlsKey != "" {
+		cfg.Server.TLS.KeyFile = *tlsKey
+	}
+
+	logCloser, err := logging.Setup(cfg.Logging)
+	if err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	defer logCloser.Close()
+
 	dependencies := deps.NewDepsFactory(cfg).CreateDeps()
 
+	// Audit log of session, worklet, and pull request events
+	defer events.Audit(dependencies.Events)()
+	defer events.CountWorkletEvents(dependencies.Events)()
+
 	router := mux.NewRouter()
 
-	// Mount worklet API at /api/worklet
-	workletHandler := worklet.NewWorkletHandler(&dependencies)
+	// Expose Prometheus metrics
+	if cfg.Metrics.Enabled {
+		router.Handle(cfg.Metrics.Path, metrics.Handler(cfg.Metrics))
+	}
+
+	// Per-client rate limits: a global limit for every API route plus tighter
+	// limits on esbuild and Docker operations
+	clientKey := middleware.ClientKey(dependencies.Session)
+	buildLimit := middleware.RateLimitRule(cfg.RateLimit, "build", cfg.RateLimit.Build, clientKey)
+	workletLimit := middleware.RateLimitRule(cfg.RateLimit, "worklet", cfg.RateLimit.Worklet, clientKey)
+
+	// Request IDs, panic recovery, access logs, request metrics, and the global rate limit for API routes
+	apiMiddleware := middleware.Chain(
+		middleware.Default(),
+		middleware.RateLimitRule(cfg.RateLimit, "global", cfg.RateLimit.Global, clientKey),
+	)
+
+	// One worklet manager runs every worklet, whether it's driven by the API
+	// or the Slack bot, so they share its cache, prompt locks, and background loops
+	workletManager := worklet.NewManager(&dependencies)
+
+	// Mount worklet API at /api/worklet; the app proxy only counts toward the global limit
+	workletHandler := worklet.NewWorkletHandler(&dependencies, workletManager)
 	workletRouter := router.PathPrefix("/api/worklet").Subrouter()
+	workletRouter.Use(mux.MiddlewareFunc(apiMiddleware))
+	workletRouter.Use(mux.MiddlewareFunc(middleware.Unless(isWorkletProxy, workletLimit)))
 	workletHandler.RegisterRoutes(workletRouter)
 
 	// Mount code package at /code
 	codeHandler := code.New(dependencies)
-	router.PathPrefix("/code").Handler(http.StripPrefix("/code", codeHandler))
-
-	// Create HTTP server
-	net := ":8082"
-	server := &http.Server{
-		Addr:    net,
-		Handler: router,
-	}
+	router.PathPrefix("/code").Handler(middleware.Chain(apiMiddleware, buildLimit)(http.StripPrefix("/code", codeHandler)))
 
 	// Create and start slack bot
-	bot, err := slackbot.New(dependencies)
+	bot, err := slackbot.New(dependencies, workletManager)
 	if err != nil {
 		log.Fatalf("Failed to create slack bot: %v", err)
 	}
 
+	// Serve ConnectRPC/gRPC services for sessions, worklets, and transcripts
+	rpcHandler := rpc.New(bot.ClaudeService(), workletManager, dependencies.Session)
+	router.PathPrefix("/flow.v1.WorkletService/").Handler(middleware.Chain(apiMiddleware, workletLimit)(rpcHandler))
+	router.PathPrefix("/flow.v1.").Handler(apiMiddleware(rpcHandler))
+
+	// Stream Claude sessions to the browser and download their transcripts
+	sessionStream := claude.NewSessionStreamHandler(bot.ClaudeService(), dependencies)
+	router.Handle("/api/sessions/{id}/ws", apiMiddleware(sessionStream)).Methods("GET")
+	sessionExport := claude.NewSessionExportHandler(bot.ClaudeService(), dependencies)
+	router.Handle("/api/sessions/{id}/export", apiMiddleware(sessionExport)).Methods("GET")
+
+	// Export the Slack bot's audit log for admins
+	router.Handle(slackbot.AuditPath, diagnostics.Protect(dependencies, bot.AuditHandler())).Methods("GET")
+
+	// Liveness and readiness probes
+	checker := health.NewChecker(5 * time.Second)
+	checker.Register("db", func(ctx context.Context) error {
+		sqlDB, err := dependencies.DB.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+	checker.Register("slack", func(ctx context.Context) error {
+		if !bot.IsConnected() {
+			return errors.New("slack socket mode not connected")
+		}
+		return nil
+	})
+	checker.Register("docker", workletManager.PingDocker)
+	checker.Register("claude_cli", func(ctx context.Context) error {
+		return claude.CheckCLI()
+	})
+	router.HandleFunc("/healthz", checker.LivenessHandler()).Methods("GET")
+	router.HandleFunc("/readyz", checker.ReadinessHandler()).Methods("GET")
+
+	// Mount pprof, runtime, and Slack start failure debug endpoints for admins
+	if cfg.Debug.Enabled {
+		router.PathPrefix(slackbot.StartFailurePath).Handler(diagnostics.Protect(dependencies, bot.StartFailureHandler()))
+		router.PathPrefix("/debug/").Handler(diagnostics.New(dependencies))
+	}
+
+	// Allow configured browser origins to call the APIs; CORS wraps the router
+	// so preflight requests are answered before route method matching
+	cors := middleware.Unless(func(r *http.Request) bool { return !isAPIPath(r) }, middleware.CORS(cfg.CORS))
+
+	// Create HTTP server; worklet preview hosts go straight to the worklet's app
+	srv := server.New(cfg.Server, workletHandler.ServePreviews(cors(router)))
+	if cfg.Worklet.PreviewDomain != "" {
+		srv.AllowHosts(workletManager.PreviewHostPolicy)
+	}
+
 	// Setup graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -57,24 +162,46 @@ func main() {
 
 	go func() {
 		<-sigCh
-		slog.Info("Received shutdown signal")
-		cancel()
+		slog.Info("Received shutdown signal", "drain_timeout", cfg.Server.ShutdownTimeout)
 
-		// Shutdown HTTP server
-		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 		defer shutdownCancel()
-		if err := server.Shutdown(shutdownCtx); err != nil {
-			slog.Error("Failed to shutdown HTTP server", "error", err)
+
+		// Stop accepting HTTP requests while in-flight Claude streams and
+		// worklet builds finish; the Slack connection stays up so restart
+		// notices can still be posted
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				slog.Error("Failed to shutdown HTTP server", "error", err)
+			}
+		}()
+		if interrupted := dependencies.Drainer.Drain(shutdownCtx); len(interrupted) > 0 {
+			slog.Warn("Shutdown deadline reached with work still in flight", "interrupted", len(interrupted))
 		}
+		wg.Wait()
 
 		// Stop slack bot
+		cancel()
 		bot.Stop()
 	}()
 
+	// Follow worklets' pull requests until they're merged or closed
+	go workletManager.TrackPullRequests(ctx, cfg.Worklet.PRPollInterval)
+	// Check the health of worklets left running by the last server
+	workletManager.ResumeHealthChecks()
+	// Put worklets nobody's visiting to sleep until their next request
+	go workletManager.SleepIdleWorklets(ctx, cfg.Worklet.IdleTimeout)
+	// Keep the dependency caches worklets share from filling the disk
+	go workletManager.TrimDependencyCaches(ctx, cfg.Worklet.CacheMaxSize)
+	// Send worklets their scheduled prompts, such as nightly dependency updates
+	go workletManager.RunSchedules(ctx)
+
 	// Start HTTP server in background
 	go func() {
-		slog.Info("Starting HTTP server on " + net)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := srv.ListenAndServe(); err != nil {
 			log.Fatalf("Failed to start HTTP server: %v", err)
 		}
 	}()
@@ -85,3 +212,15 @@ func main() {
 		log.Fatalf("Failed to start slack bot: %v", err)
 	}
 }
+
+// isWorkletProxy reports whether r targets a worklet's proxied web app
+func isWorkletProxy(r *http.Request) bool {
+	return strings.Contains(r.URL.Path, "/proxy")
+}
+
+// isAPIPath reports whether r targets the REST, code runner, or RPC APIs
+func isAPIPath(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/") ||
+		strings.HasPrefix(r.URL.Path, "/code/") ||
+		strings.HasPrefix(r.URL.Path, "/flow.v1.")
+}
@@ -0,0 +1,366 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "flow"
+
+// Registry holds every collector exposed on the metrics endpoint
+var Registry = prometheus.NewRegistry()
+
+// Slack bot metrics
+var (
+	SlackEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "slackbot",
+		Name:      "events_total",
+		Help:      "Socket mode events received from Slack, by event type.",
+	}, []string{"type"})
+
+	SlackAPIErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "slackbot",
+		Name:      "api_errors_total",
+		Help:      "Failed Slack API calls, by method.",
+	}, []string{"method"})
+
+	SlackSessionsActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "slackbot",
+		Name:      "sessions_active",
+		Help:      "Slack threads with an active Claude session.",
+	})
+)
+
+// Claude metrics
+var (
+	ClaudeSessionsActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "claude",
+		Name:      "sessions_active",
+		Help:      "Running Claude CLI processes.",
+	})
+
+	ClaudeSessionStartsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "claude",
+		Name:      "session_starts_total",
+		Help:      "Claude session start attempts, by result.",
+	}, []string{"result"})
+
+	ClaudeSessionStartDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "claude",
+		Name:      "session_start_duration_seconds",
+		Help:      "Time from spawning the Claude CLI to receiving its init message.",
+		Buckets:   []float64{0.5, 1, 2, 3, 5, 8, 10, 15},
+	})
+
+	ClaudePoolIdle = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "claude",
+		Name:      "pool_idle",
+		Help:      "Pre-warmed Claude CLI processes waiting for a session.",
+	})
+
+	ClaudePoolCheckoutsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "claude",
+		Name:      "pool_checkouts_total",
+		Help:      "New sessions that asked the process pool for a warm process, by result (hit or miss).",
+	}, []string{"result"})
+
+	ClaudeSessionRestartsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "claude",
+		Name:      "session_restarts_total",
+		Help:      "Claude CLI processes restarted by the supervisor, by reason (exited or hung).",
+	}, []string{"reason"})
+
+	ClaudeSessionsHibernatedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "claude",
+		Name:      "sessions_hibernated_total",
+		Help:      "Idle Claude CLI processes stopped by the supervisor until their session is used again.",
+	})
+
+	ClaudeSessionReplaysTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "claude",
+		Name:      "session_replays_total",
+		Help:      "Sessions the CLI couldn't resume that were restarted from a summary of their saved transcript.",
+	})
+
+	ClaudeModelFallbacksTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "claude",
+		Name:      "model_fallbacks_total",
+		Help:      "Rate-limited Claude turns retried on the fallback model.",
+	})
+
+	ClaudeBudgetsExceededTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "claude",
+		Name:      "budgets_exceeded_total",
+		Help:      "Claude sessions paused for reaching a budget limit, by limit (tokens, turns, or duration).",
+	}, []string{"limit"})
+
+	ClaudeErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "claude",
+		Name:      "errors_total",
+		Help:      "Failures reported by Claude CLI processes, by kind (auth, rate_limited, tool_denied, crash, timeout, or unknown).",
+	}, []string{"kind"})
+
+	ClaudeSessionStorageBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "claude",
+		Name:      "session_storage_bytes",
+		Help:      "Disk used by Claude session directories at the last janitor sweep.",
+	})
+
+	ClaudeSessionDirsArchivedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "claude",
+		Name:      "session_dirs_archived_total",
+		Help:      "Claude session directories archived and removed, by reason (expired or total_quota).",
+	}, []string{"reason"})
+
+	ClaudeSessionQuotaExceededTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "claude",
+		Name:      "session_quota_exceeded_total",
+		Help:      "Janitor sweeps that found a Claude session directory over its quota.",
+	})
+
+	ClaudeApprovalsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "claude",
+		Name:      "approvals_total",
+		Help:      "Bash commands held for approval, by decision (approved, denied, or timeout).",
+	}, []string{"decision"})
+
+	ClaudeQueueLength = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "claude",
+		Name:      "queue_length",
+		Help:      "Sessions waiting for a Claude process slot.",
+	})
+
+	ClaudeQueueWaitDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "claude",
+		Name:      "queue_wait_duration_seconds",
+		Help:      "Time queued sessions waited for a Claude process slot.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600},
+	})
+)
+
+// Worklet metrics
+var (
+	WorkletBuildDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "worklet",
+		Name:      "build_duration_seconds",
+		Help:      "Docker build and run duration for worklets, by result.",
+		Buckets:   []float64{5, 10, 30, 60, 120, 300, 600},
+	}, []string{"result"})
+
+	WorkletStatusTransitionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "worklet",
+		Name:      "status_transitions_total",
+		Help:      "Worklet status changes, by new status.",
+	}, []string{"status"})
+
+	WorkletHealthRestartsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "worklet",
+		Name:      "health_restarts_total",
+		Help:      "Containers restarted because their worklet failed its health check, by result.",
+	}, []string{"result"})
+
+	WorkletEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "worklet",
+		Name:      "events_total",
+		Help:      "Worklet lifecycle events, by type: created, building, deployed, prompt_applied, error, stopped, tests_passed, or tests_failed.",
+	}, []string{"type"})
+
+	WorkletImageBuildsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "worklet",
+		Name:      "image_builds_total",
+		Help:      "Worklet image builds, by outcome: built, cached (an image of the same files was reused), or skipped (a prompt changed no files).",
+	}, []string{"outcome"})
+)
+
+// Code runner metrics
+var (
+	CodeBuildDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "coderunner",
+		Name:      "build_duration_seconds",
+		Help:      "esbuild duration for code runner requests, by handler and result.",
+		Buckets:   []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+	}, []string{"handler", "result"})
+
+	CodeBuildCacheTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "coderunner",
+		Name:      "build_cache_total",
+		Help:      "Code runner build cache lookups, by handler and result (hit, miss, or coalesced into a build already running).",
+	}, []string{"handler", "result"})
+
+	CodeBuildQueueLength = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "coderunner",
+		Name:      "build_queue_length",
+		Help:      "Code runner builds waiting for a free build worker.",
+	})
+
+	CodeBuildQueueWaitDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "coderunner",
+		Name:      "build_queue_wait_duration_seconds",
+		Help:      "Time code runner builds waited for a free build worker, by handler.",
+		Buckets:   []float64{0.001, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"handler"})
+
+	CodeBuildsRejectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "coderunner",
+		Name:      "builds_rejected_total",
+		Help:      "Code runner builds turned away with 429, by handler and reason (queue_full or queue_timeout).",
+	}, []string{"handler", "reason"})
+)
+
+// HTTP metrics
+var (
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency, by route template, method, and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	HTTPResponseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "response_size_bytes",
+		Help:      "HTTP response body size, by route template and method.",
+		Buckets:   prometheus.ExponentialBuckets(256, 4, 8),
+	}, []string{"route", "method"})
+
+	HTTPRateLimitedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected with 429, by rate limiter.",
+	}, []string{"limiter"})
+)
+
+// Event bus metrics
+var (
+	EventsPublishedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "events",
+		Name:      "published_total",
+		Help:      "Events published on the internal bus, by topic.",
+	}, []string{"topic"})
+
+	EventsDroppedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "events",
+		Name:      "dropped_total",
+		Help:      "Events dropped because a subscriber fell behind, by topic.",
+	}, []string{"topic"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		SlackEventsTotal,
+		SlackAPIErrorsTotal,
+		SlackSessionsActive,
+		ClaudeSessionsActive,
+		ClaudeSessionStartsTotal,
+		ClaudeSessionStartDuration,
+		ClaudePoolIdle,
+		ClaudePoolCheckoutsTotal,
+		ClaudeSessionRestartsTotal,
+		ClaudeSessionsHibernatedTotal,
+		ClaudeSessionReplaysTotal,
+		ClaudeModelFallbacksTotal,
+		ClaudeBudgetsExceededTotal,
+		ClaudeErrorsTotal,
+		ClaudeApprovalsTotal,
+		ClaudeSessionStorageBytes,
+		ClaudeSessionDirsArchivedTotal,
+		ClaudeSessionQuotaExceededTotal,
+		ClaudeQueueLength,
+		ClaudeQueueWaitDuration,
+		WorkletBuildDuration,
+		WorkletStatusTransitionsTotal,
+		WorkletHealthRestartsTotal,
+		WorkletImageBuildsTotal,
+		WorkletEventsTotal,
+		CodeBuildDuration,
+		CodeBuildCacheTotal,
+		CodeBuildQueueLength,
+		CodeBuildQueueWaitDuration,
+		CodeBuildsRejectedTotal,
+		HTTPRequestDuration,
+		HTTPResponseSize,
+		HTTPRateLimitedTotal,
+		EventsPublishedTotal,
+		EventsDroppedTotal,
+	)
+}
+
+// Result returns the result label value for an operation outcome
+func Result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// ObserveCodeBuild records the duration of a code runner build
+func ObserveCodeBuild(handler string, start time.Time, failed bool) {
+	result := "success"
+	if failed {
+		result = "error"
+	}
+	CodeBuildDuration.WithLabelValues(handler, result).Observe(time.Since(start).Seconds())
+}
+
+// Handler returns the metrics endpoint handler, protected by basic auth when credentials are configured
+func Handler(cfg config.MetricsConfig) http.Handler {
+	handler := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+	if cfg.Username == "" && cfg.Password == "" {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(username), []byte(cfg.Username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(cfg.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
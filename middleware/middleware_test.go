@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/breadchris/flow/logging"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDGenerated(t *testing.T) {
+	var ctxID string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctxID = logging.RequestID(r.Context())
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.NotEmpty(t, ctxID)
+	assert.Equal(t, ctxID, rec.Header().Get(RequestIDHeader))
+}
+
+func TestRequestIDPropagated(t *testing.T) {
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, "abc-123", rec.Header().Get(RequestIDHeader))
+}
+
+func TestRecover(t *testing.T) {
+	handler := Default()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get(RequestIDHeader))
+}
+
+func TestRecoverAfterHeaderWritten(t *testing.T) {
+	handler := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+}
+
+func TestResponseWriterTracksStatusAndSize(t *testing.T) {
+	var rw *responseWriter
+	handler := Chain(AccessLog, Metrics)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw = w.(*responseWriter)
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("hello"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusTeapot, rw.Status())
+	assert.Equal(t, int64(5), rw.bytes)
+}
+
+func TestRouteTemplate(t *testing.T) {
+	var route string
+	router := mux.NewRouter()
+	router.HandleFunc("/worklets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		route = routeTemplate(r)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/worklets/123", nil))
+	assert.Equal(t, "/worklets/{id}", route)
+
+	assert.Equal(t, "unmatched", routeTemplate(httptest.NewRequest(http.MethodGet, "/", nil)))
+}
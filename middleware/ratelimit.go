@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/metrics"
+	"github.com/breadchris/flow/session"
+)
+
+// KeyFunc identifies the client a request is counted against
+type KeyFunc func(r *http.Request) string
+
+// RateLimiter allows a fixed number of requests per sliding window for each client key
+type RateLimiter struct {
+	name      string
+	limit     int
+	window    time.Duration
+	mu        sync.Mutex
+	clients   map[string][]time.Time
+	lastSweep time.Time
+}
+
+// NewRateLimiter creates a limiter allowing limit requests per window. The name
+// labels rejected requests in metrics and logs.
+func NewRateLimiter(name string, limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		name:      name,
+		limit:     limit,
+		window:    window,
+		clients:   make(map[string][]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow records a request for key and reports whether it is within the limit.
+// When it is not, retryAfter is how long until the oldest request leaves the window.
+func (rl *RateLimiter) Allow(key string) (allowed bool, retryAfter time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-rl.window)
+
+	// Drop clients that have been idle for a full window
+	if now.Sub(rl.lastSweep) > rl.window {
+		for k, requests := range rl.clients {
+			if len(requests) == 0 || !requests[len(requests)-1].After(cutoff) {
+				delete(rl.clients, k)
+			}
+		}
+		rl.lastSweep = now
+	}
+
+	requests := rl.clients[key]
+	i := 0
+	for i < len(requests) && !requests[i].After(cutoff) {
+		i++
+	}
+	requests = requests[i:]
+
+	if len(requests) >= rl.limit {
+		rl.clients[key] = requests
+		return false, requests[0].Add(rl.window).Sub(now)
+	}
+
+	rl.clients[key] = append(requests, now)
+	return true, 0
+}
+
+// RateLimit rejects requests over the limiter's limit with 429 and a Retry-After header
+func RateLimit(rl *RateLimiter, key KeyFunc) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			k := key(r)
+			allowed, retryAfter := rl.Allow(k)
+			if !allowed {
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				if seconds < 1 {
+					seconds = 1
+				}
+				metrics.HTTPRateLimitedTotal.WithLabelValues(rl.name).Inc()
+				slog.WarnContext(r.Context(), "Rate limit exceeded",
+					"limiter", rl.name,
+					"client", k,
+					"path", r.URL.Path,
+					"retry_after", seconds)
+
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Unless applies m only to requests for which skip returns false
+func Unless(skip func(r *http.Request) bool, m Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		wrapped := m(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if skip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientIP returns the host part of the request's remote address
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ClientKey counts logged in users by user ID and everyone else by IP address
+func ClientKey(sessions *session.SessionManager) KeyFunc {
+	return func(r *http.Request) string {
+		if sessions != nil {
+			if userID, err := sessions.UserIDFromRequest(r); err == nil {
+				return "user:" + userID
+			}
+		}
+		return "ip:" + ClientIP(r)
+	}
+}
+
+// RateLimitRule returns a RateLimit middleware for rule, or a pass-through
+// middleware when rate limiting or the rule is disabled
+func RateLimitRule(cfg config.RateLimitConfig, name string, rule config.RateLimitRule, key KeyFunc) Middleware {
+	if !cfg.Enabled || rule.Requests <= 0 || rule.Window <= 0 {
+		return Chain()
+	}
+	return RateLimit(NewRateLimiter(name, rule.Requests, rule.Window), key)
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterAllowsWithinLimit(t *testing.T) {
+	rl := NewRateLimiter("test", 2, time.Minute)
+
+	allowed, _ := rl.Allow("a")
+	assert.True(t, allowed)
+	allowed, _ = rl.Allow("a")
+	assert.True(t, allowed)
+
+	allowed, retryAfter := rl.Allow("a")
+	assert.False(t, allowed)
+	assert.Greater(t, retryAfter, time.Duration(0))
+	assert.LessOrEqual(t, retryAfter, time.Minute)
+
+	// Other clients have their own budget
+	allowed, _ = rl.Allow("b")
+	assert.True(t, allowed)
+}
+
+func TestRateLimiterWindowExpires(t *testing.T) {
+	rl := NewRateLimiter("test", 1, 20*time.Millisecond)
+
+	allowed, _ := rl.Allow("a")
+	assert.True(t, allowed)
+	allowed, _ = rl.Allow("a")
+	assert.False(t, allowed)
+
+	time.Sleep(30 * time.Millisecond)
+	allowed, _ = rl.Allow("a")
+	assert.True(t, allowed)
+}
+
+func TestRateLimitResponds429(t *testing.T) {
+	handler := RateLimit(NewRateLimiter("test", 1, time.Minute), ClientKey(nil))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/render/app.tsx", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/render/app.tsx", nil))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+}
+
+func TestRateLimitRuleDisabled(t *testing.T) {
+	cfg := config.RateLimitConfig{Enabled: false}
+	handler := RateLimitRule(cfg, "test", config.RateLimitRule{Requests: 1, Window: time.Minute}, ClientKey(nil))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+}
+
+func TestUnless(t *testing.T) {
+	deny := Middleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		})
+	})
+	skipProxy := func(r *http.Request) bool { return r.URL.Path == "/proxy" }
+	handler := Unless(skipProxy, deny)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/proxy", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/worklets", nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.7:52100"
+	assert.Equal(t, "203.0.113.7", ClientIP(req))
+	assert.Equal(t, "ip:203.0.113.7", ClientKey(nil)(req))
+}
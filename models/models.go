@@ -446,6 +446,33 @@ type ClaudeSession struct {
 	Metadata  *JSONField[map[string]interface{}] `json:"metadata,omitempty"`
 }
 
+// ClaudeUsage records the tokens and cost of one completed Claude turn
+type ClaudeUsage struct {
+	Model
+	SessionID                string  `json:"session_id" gorm:"index;not null"`
+	UserID                   string  `json:"user_id" gorm:"index;not null"`
+	InputTokens              int64   `json:"input_tokens"`
+	OutputTokens             int64   `json:"output_tokens"`
+	CacheCreationInputTokens int64   `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64   `json:"cache_read_input_tokens"`
+	CostUSD                  float64 `json:"cost_usd"`
+}
+
+// ClaudeJob is a one-shot Claude task run in the background until it finishes
+type ClaudeJob struct {
+	Model
+	UserID     string     `json:"user_id" gorm:"index"`
+	Prompt     string     `json:"prompt" gorm:"not null"`
+	Workspace  string     `json:"workspace" gorm:"not null"`
+	Status     string     `json:"status" gorm:"index;not null"`
+	SessionID  string     `json:"session_id,omitempty"` // Claude CLI session that ran the job
+	Result     string     `json:"result,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	CostUSD    float64    `json:"cost_usd"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
 // SlackSession represents a Claude session tied to a Slack thread (database version)
 type SlackSession struct {
 	Model
@@ -526,6 +553,52 @@ type SlackFileUpload struct {
 	SessionID    string    `json:"session_id" gorm:"index"` // Associated Claude session ID if any
 }
 
+// SlackChannelConfig overrides the Slack bot's settings for one channel; empty fields keep the defaults
+type SlackChannelConfig struct {
+	Model
+	ChannelID       string              `json:"channel_id" gorm:"uniqueIndex;not null"`
+	WorkingDir      string              `json:"working_dir"`                    // Directory Claude sessions may work in besides their own
+	AllowedTools    JSONField[[]string] `json:"allowed_tools" gorm:"type:json"` // Replaces the Claude tools
+	ClaudeModel     string              `json:"model"`                          // Replaces the Claude model
+	SessionTimeout  time.Duration       `json:"session_timeout"`                // Replaces the Slack session timeout
+	WorkletsAllowed *bool               `json:"worklets_allowed,omitempty"`     // Whether /flow may start worklets
+	SharedWorkspace bool                `json:"shared_workspace"`               // Whether the channel's sessions share one persistent working directory
+	IssueRepo       string              `json:"issue_repo"`                     // GitHub repository /flow issue files issues in, as owner/name
+	UpdatedBy       string              `json:"updated_by"`                     // Slack user who last changed the settings
+}
+
+// SlackUserRole is a role an admin granted a Slack user with /flow admin grant
+type SlackUserRole struct {
+	Model
+	UserID    string `json:"user_id" gorm:"uniqueIndex;not null"`
+	Role      string `json:"role"`       // admin, developer, read_only, or none
+	GrantedBy string `json:"granted_by"` // Slack user who granted the role
+}
+
+// SlackSchedule is a prompt a Slack user scheduled with /flow schedule
+type SlackSchedule struct {
+	Model
+	ChannelID    string     `json:"channel_id" gorm:"index;not null"`
+	UserID       string     `json:"user_id" gorm:"index;not null"`
+	Cron         string     `json:"cron" gorm:"not null"` // Five-field cron expression in the server's time zone
+	Prompt       string     `json:"prompt" gorm:"type:text;not null"`
+	NextRunAt    time.Time  `json:"next_run_at" gorm:"index"`
+	LastRunAt    *time.Time `json:"last_run_at,omitempty"`
+	LastJobID    string     `json:"last_job_id,omitempty" gorm:"index"` // Job started by the most recent run
+	LastThreadTS string     `json:"last_thread_ts,omitempty"`           // Message announcing the most recent run, whose thread gets its result
+}
+
+// AuditEvent records something the Slack bot did for a user: a command, a
+// session starting or ending, a tool approval, a pull request, or a worklet deploy
+type AuditEvent struct {
+	Model
+	ActorID   string                            `json:"actor_id" gorm:"index;not null"` // Slack user who caused the action
+	ChannelID string                            `json:"channel_id" gorm:"index"`
+	ThreadTS  string                            `json:"thread_ts,omitempty"`
+	Action    string                            `json:"action" gorm:"index;not null"`
+	Payload   JSONField[map[string]interface{}] `json:"payload" gorm:"type:json"`
+}
+
 // Feature represents a feature in ideation sessions
 type Feature struct {
 	ID          string `json:"id"`
@@ -0,0 +1,63 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"connectrpc.com/connect"
+	"github.com/breadchris/flow/claude"
+	"github.com/breadchris/flow/gen/flow/v1/flowv1connect"
+	"github.com/breadchris/flow/session"
+	"github.com/breadchris/flow/shutdown"
+	"github.com/breadchris/flow/worklet"
+)
+
+// New returns a ServeMux serving the session, worklet, and transcript services
+// over the Connect, gRPC, and gRPC-Web protocols to users signed in to sessions
+func New(claudeService *claude.ClaudeService, workletManager *worklet.Manager, sessions *session.SessionManager) *http.ServeMux {
+	m := http.NewServeMux()
+	signedIn := sessionUser(sessions)
+
+	m.Handle(flowv1connect.NewSessionServiceHandler(NewSessionServer(claudeService, signedIn)))
+	m.Handle(flowv1connect.NewWorkletServiceHandler(NewWorkletServer(workletManager, signedIn)))
+	m.Handle(flowv1connect.NewTranscriptServiceHandler(NewTranscriptServer(claudeService, signedIn)))
+
+	return m
+}
+
+// signedIn finds who signed in to make an RPC from its headers, returning an
+// Unauthenticated error when nobody did
+type signedIn func(ctx context.Context, header http.Header) (string, error)
+
+// sessionUser finds callers from their session cookie, as the session
+// WebSocket does. Callers can't name themselves with a header.
+func sessionUser(sessions *session.SessionManager) signedIn {
+	return func(ctx context.Context, header http.Header) (string, error) {
+		if sessions == nil {
+			return "", connect.NewError(connect.CodeUnauthenticated, errors.New("sign in to use the API"))
+		}
+		r := (&http.Request{Header: header}).WithContext(ctx)
+		userID, err := sessions.UserIDFromRequest(r)
+		if err != nil || userID == "" {
+			return "", connect.NewError(connect.CodeUnauthenticated, errors.New("sign in to use the API"))
+		}
+		return userID, nil
+	}
+}
+
+// requireField returns an InvalidArgument error when value is empty
+func requireField(name, value string) error {
+	if value == "" {
+		return connect.NewError(connect.CodeInvalidArgument, errors.New(name+" is required"))
+	}
+	return nil
+}
+
+// managerError wraps err with code, or Unavailable when the server is shutting down
+func managerError(code connect.Code, err error) error {
+	if errors.Is(err, shutdown.ErrDraining) {
+		code = connect.CodeUnavailable
+	}
+	return connect.NewError(code, err)
+}
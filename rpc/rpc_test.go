@@ -0,0 +1,90 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/breadchris/flow/claude"
+	flowv1 "github.com/breadchris/flow/gen/flow/v1"
+	"github.com/breadchris/flow/gen/flow/v1/flowv1connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallersMustSignIn(t *testing.T) {
+	server := httptest.NewServer(New(nil, nil, nil))
+	defer server.Close()
+
+	// Naming a user in a header doesn't sign anyone in
+	asUser := func(req connect.AnyRequest) {
+		req.Header().Set("X-User-ID", "U123")
+	}
+
+	sessions := flowv1connect.NewSessionServiceClient(server.Client(), server.URL)
+	create := connect.NewRequest(&flowv1.CreateSessionRequest{})
+	asUser(create)
+	_, err := sessions.CreateSession(context.Background(), create)
+	assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+
+	prompt := connect.NewRequest(&flowv1.SendPromptRequest{SessionId: "s1", Prompt: "rm -rf /"})
+	asUser(prompt)
+	stream, err := sessions.SendPrompt(context.Background(), prompt)
+	require.NoError(t, err)
+	assert.False(t, stream.Receive())
+	assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(stream.Err()))
+
+	worklets := flowv1connect.NewWorkletServiceClient(server.Client(), server.URL)
+	list := connect.NewRequest(&flowv1.ListWorkletsRequest{})
+	asUser(list)
+	_, err = worklets.ListWorklets(context.Background(), list)
+	assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+
+	transcripts := flowv1connect.NewTranscriptServiceClient(server.Client(), server.URL)
+	_, err = transcripts.GetTranscript(context.Background(), connect.NewRequest(&flowv1.GetTranscriptRequest{SessionId: "s1"}))
+	assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+}
+
+func TestRequiredFieldsRejected(t *testing.T) {
+	server := httptest.NewServer(New(nil, nil, nil))
+	defer server.Close()
+
+	sessions := flowv1connect.NewSessionServiceClient(server.Client(), server.URL)
+	_, err := sessions.GetSession(context.Background(), connect.NewRequest(&flowv1.GetSessionRequest{}))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+
+	worklets := flowv1connect.NewWorkletServiceClient(server.Client(), server.URL)
+	_, err = worklets.CreateWorklet(context.Background(), connect.NewRequest(&flowv1.CreateWorkletRequest{Name: "demo"}))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+
+	transcripts := flowv1connect.NewTranscriptServiceClient(server.Client(), server.URL)
+	_, err = transcripts.GetTranscript(context.Background(), connect.NewRequest(&flowv1.GetTranscriptRequest{}))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+}
+
+func TestToSessionEvent(t *testing.T) {
+	event := toSessionEvent(claude.Message{
+		Type:      "assistant",
+		SessionID: "s1",
+		Message:   json.RawMessage(`{"content":[{"type":"text","text":"hi"}]}`),
+	})
+
+	assert.Equal(t, "assistant", event.Type)
+	assert.Equal(t, "s1", event.SessionId)
+	assert.JSONEq(t, `{"content":[{"type":"text","text":"hi"}]}`, event.MessageJson)
+
+	event = toSessionEvent(claude.Message{Type: "result", Result: "done"})
+	assert.Equal(t, "done", event.Result)
+	assert.Empty(t, event.MessageJson)
+}
+
+func TestSessionErrorCode(t *testing.T) {
+	assert.Equal(t, connect.CodeResourceExhausted, sessionErrorCode(&claude.SessionError{Kind: claude.ErrRateLimited}))
+	assert.Equal(t, connect.CodeAborted, sessionErrorCode(&claude.SessionError{Kind: claude.ErrCrash}))
+	assert.Equal(t, connect.CodeInternal, sessionErrorCode(&claude.SessionError{}))
+}
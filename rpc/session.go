@@ -0,0 +1,236 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/breadchris/flow/claude"
+	flowv1 "github.com/breadchris/flow/gen/flow/v1"
+	"github.com/breadchris/flow/models"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+// promptTimeout bounds how long SendPrompt waits for Claude to finish a turn
+const promptTimeout = 5 * time.Minute
+
+// SessionServer implements flowv1connect.SessionServiceHandler
+type SessionServer struct {
+	claudeService *claude.ClaudeService
+	signedIn      signedIn
+}
+
+// NewSessionServer creates a session service backed by claudeService for the
+// callers signedIn finds
+func NewSessionServer(claudeService *claude.ClaudeService, signedIn signedIn) *SessionServer {
+	return &SessionServer{claudeService: claudeService, signedIn: signedIn}
+}
+
+func (s *SessionServer) ListSessions(ctx context.Context, req *connect.Request[flowv1.ListSessionsRequest]) (*connect.Response[flowv1.ListSessionsResponse], error) {
+	uid, err := s.signedIn(ctx, req.Header())
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := s.claudeService.GetSessions(uid)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to list sessions: %w", err))
+	}
+
+	resp := &flowv1.ListSessionsResponse{}
+	for i := range sessions {
+		resp.Sessions = append(resp.Sessions, s.toProto(&sessions[i]))
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (s *SessionServer) GetSession(ctx context.Context, req *connect.Request[flowv1.GetSessionRequest]) (*connect.Response[flowv1.GetSessionResponse], error) {
+	if err := requireField("session_id", req.Msg.SessionId); err != nil {
+		return nil, err
+	}
+
+	uid, err := s.signedIn(ctx, req.Header())
+	if err != nil {
+		return nil, err
+	}
+	session, err := s.getSession(req.Msg.SessionId, uid)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(&flowv1.GetSessionResponse{Session: s.toProto(session)}), nil
+}
+
+func (s *SessionServer) CreateSession(ctx context.Context, req *connect.Request[flowv1.CreateSessionRequest]) (*connect.Response[flowv1.CreateSessionResponse], error) {
+	uid, err := s.signedIn(ctx, req.Header())
+	if err != nil {
+		return nil, err
+	}
+
+	_, info, err := s.claudeService.CreateSessionWithPersistenceAndConfig("", "", uid, "", req.Msg.ConfigId)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to create session: %w", err))
+	}
+
+	session, err := s.getSession(info.SessionID, uid)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(&flowv1.CreateSessionResponse{Session: s.toProto(session)}), nil
+}
+
+func (s *SessionServer) SendPrompt(ctx context.Context, req *connect.Request[flowv1.SendPromptRequest], stream *connect.ServerStream[flowv1.SessionEvent]) error {
+	if err := requireField("session_id", req.Msg.SessionId); err != nil {
+		return err
+	}
+	if err := requireField("prompt", req.Msg.Prompt); err != nil {
+		return err
+	}
+
+	uid, err := s.signedIn(ctx, req.Header())
+	if err != nil {
+		return err
+	}
+	if _, err := s.getSession(req.Msg.SessionId, uid); err != nil {
+		return err
+	}
+
+	// Wait out turns started from Slack or the browser, whose responses are theirs to read
+	turn := s.claudeService.TurnLock(req.Msg.SessionId)
+	turn.Lock()
+	defer turn.Unlock()
+
+	process, exists := s.claudeService.GetProcess(req.Msg.SessionId)
+	if !exists {
+		resumed, err := s.claudeService.ResumeSession(req.Msg.SessionId, uid)
+		if err != nil {
+			return connect.NewError(connect.CodeUnavailable, fmt.Errorf("failed to resume session: %w", err))
+		}
+		process = resumed
+	}
+
+	if err := s.claudeService.SendMessage(process, req.Msg.Prompt); err != nil {
+		if errors.Is(err, claude.ErrBudgetExceeded) {
+			return connect.NewError(connect.CodeFailedPrecondition, err)
+		}
+		return connect.NewError(connect.CodeInternal, fmt.Errorf("failed to send prompt: %w", err))
+	}
+	if err := s.claudeService.UpdateSessionActivity(req.Msg.SessionId); err != nil {
+		slog.Warn("Failed to update session activity", "session_id", req.Msg.SessionId, "error", err)
+	}
+
+	timeout := time.NewTimer(promptTimeout)
+	defer timeout.Stop()
+
+	messages := s.claudeService.ReceiveMessages(process)
+	sessionErrors := s.claudeService.Errors(process)
+	output := s.claudeService.Output(claude.OutputHTTP)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout.C:
+			return connect.NewError(connect.CodeDeadlineExceeded, errors.New("timed out waiting for Claude response"))
+		case sessionErr := <-sessionErrors:
+			// Claude carries on after a denied tool call, whose result is in the stream,
+			// and unrecognized errors end the turn with an error result if they matter
+			if sessionErr.Kind == nil || errors.Is(sessionErr, claude.ErrToolDenied) {
+				continue
+			}
+			return connect.NewError(sessionErrorCode(sessionErr), sessionErr)
+		case msg, ok := <-messages:
+			if !ok {
+				return connect.NewError(connect.CodeAborted, errors.New("claude session ended"))
+			}
+			if err := stream.Send(toSessionEvent(output.Apply(msg))); err != nil {
+				return err
+			}
+			if msg.Type == "result" {
+				return nil
+			}
+		}
+	}
+}
+
+func (s *SessionServer) StopSession(ctx context.Context, req *connect.Request[flowv1.StopSessionRequest]) (*connect.Response[flowv1.StopSessionResponse], error) {
+	if err := requireField("session_id", req.Msg.SessionId); err != nil {
+		return nil, err
+	}
+	uid, err := s.signedIn(ctx, req.Header())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.getSession(req.Msg.SessionId, uid); err != nil {
+		return nil, err
+	}
+
+	s.claudeService.StopSession(req.Msg.SessionId)
+	return connect.NewResponse(&flowv1.StopSessionResponse{}), nil
+}
+
+// getSession loads a session owned by uid, mapping a missing row to NotFound
+func (s *SessionServer) getSession(sessionID, uid string) (*models.ClaudeSession, error) {
+	session, err := s.claudeService.GetSession(sessionID, uid)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("session %s not found", sessionID))
+		}
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to get session: %w", err))
+	}
+	return session, nil
+}
+
+func (s *SessionServer) toProto(session *models.ClaudeSession) *flowv1.Session {
+	_, running := s.claudeService.GetProcess(session.SessionID)
+
+	pb := &flowv1.Session{
+		SessionId:      session.SessionID,
+		UserId:         session.UserID,
+		Title:          session.Title,
+		ProcessRunning: running,
+		CreatedAt:      timestamppb.New(session.CreatedAt),
+		UpdatedAt:      timestamppb.New(session.UpdatedAt),
+	}
+	if session.Metadata != nil {
+		metadata := session.Metadata.Data
+		pb.ThreadTs, _ = metadata["thread_ts"].(string)
+		pb.ChannelId, _ = metadata["channel_id"].(string)
+		pb.WorkingDir, _ = metadata["working_dir"].(string)
+		pb.Active, _ = metadata["active"].(bool)
+	}
+	return pb
+}
+
+// sessionErrorCode maps the kind of a Claude session failure to a Connect code
+func sessionErrorCode(err error) connect.Code {
+	switch {
+	case errors.Is(err, claude.ErrRateLimited):
+		return connect.CodeResourceExhausted
+	case errors.Is(err, claude.ErrTimeout):
+		return connect.CodeDeadlineExceeded
+	case errors.Is(err, claude.ErrCrash):
+		return connect.CodeAborted
+	case errors.Is(err, claude.ErrAuth):
+		return connect.CodeUnavailable
+	}
+	return connect.CodeInternal
+}
+
+func toSessionEvent(msg claude.Message) *flowv1.SessionEvent {
+	event := &flowv1.SessionEvent{
+		Type:      msg.Type,
+		Subtype:   msg.Subtype,
+		SessionId: msg.SessionID,
+		ParentId:  msg.ParentID,
+		Result:    msg.Result,
+		IsError:   msg.IsError,
+		Text:      msg.Text,
+	}
+	if len(msg.Message) > 0 && json.Valid(msg.Message) {
+		event.MessageJson = string(msg.Message)
+	}
+	return event
+}
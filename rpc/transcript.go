@@ -0,0 +1,58 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"connectrpc.com/connect"
+	"github.com/breadchris/flow/claude"
+	flowv1 "github.com/breadchris/flow/gen/flow/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+	"gorm.io/gorm"
+)
+
+// TranscriptServer implements flowv1connect.TranscriptServiceHandler
+type TranscriptServer struct {
+	claudeService *claude.ClaudeService
+	signedIn      signedIn
+}
+
+// NewTranscriptServer creates a transcript service backed by claudeService
+// for the callers signedIn finds
+func NewTranscriptServer(claudeService *claude.ClaudeService, signedIn signedIn) *TranscriptServer {
+	return &TranscriptServer{claudeService: claudeService, signedIn: signedIn}
+}
+
+func (s *TranscriptServer) GetTranscript(ctx context.Context, req *connect.Request[flowv1.GetTranscriptRequest]) (*connect.Response[flowv1.GetTranscriptResponse], error) {
+	if err := requireField("session_id", req.Msg.SessionId); err != nil {
+		return nil, err
+	}
+
+	uid, err := s.signedIn(ctx, req.Header())
+	if err != nil {
+		return nil, err
+	}
+	session, err := s.claudeService.GetSession(req.Msg.SessionId, uid)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("session %s not found", req.Msg.SessionId))
+		}
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to get session: %w", err))
+	}
+
+	resp := &flowv1.GetTranscriptResponse{
+		SessionId: session.SessionID,
+		Title:     session.Title,
+	}
+	if messages, ok := session.Messages.Data.([]interface{}); ok {
+		for _, message := range messages {
+			value, err := structpb.NewValue(message)
+			if err != nil {
+				return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to convert transcript message: %w", err))
+			}
+			resp.Messages = append(resp.Messages, value)
+		}
+	}
+	return connect.NewResponse(resp), nil
+}
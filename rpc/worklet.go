@@ -0,0 +1,198 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"connectrpc.com/connect"
+	flowv1 "github.com/breadchris/flow/gen/flow/v1"
+	"github.com/breadchris/flow/worklet"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// WorkletServer implements flowv1connect.WorkletServiceHandler
+type WorkletServer struct {
+	manager  *worklet.Manager
+	signedIn signedIn
+}
+
+// NewWorkletServer creates a worklet service backed by manager for the
+// callers signedIn finds
+func NewWorkletServer(manager *worklet.Manager, signedIn signedIn) *WorkletServer {
+	return &WorkletServer{manager: manager, signedIn: signedIn}
+}
+
+func (s *WorkletServer) CreateWorklet(ctx context.Context, req *connect.Request[flowv1.CreateWorkletRequest]) (*connect.Response[flowv1.CreateWorkletResponse], error) {
+	if err := requireField("name", req.Msg.Name); err != nil {
+		return nil, err
+	}
+	if err := requireField("git_repo", req.Msg.GitRepo); err != nil {
+		return nil, err
+	}
+
+	createReq := worklet.CreateWorkletRequest{
+		Name:        req.Msg.Name,
+		Description: req.Msg.Description,
+		GitRepo:     req.Msg.GitRepo,
+		Branch:      req.Msg.Branch,
+		BasePrompt:  req.Msg.BasePrompt,
+		Environment: req.Msg.Environment,
+
+		AllowedTools:    req.Msg.AllowedTools,
+		DisallowedTools: req.Msg.DisallowedTools,
+	}
+
+	uid, err := s.signedIn(ctx, req.Header())
+	if err != nil {
+		return nil, err
+	}
+	// Deployment continues in the background after the RPC returns
+	w, err := s.manager.CreateWorklet(context.WithoutCancel(ctx), createReq, uid)
+	if err != nil {
+		return nil, managerError(connect.CodeInternal, fmt.Errorf("failed to create worklet: %w", err))
+	}
+	return connect.NewResponse(&flowv1.CreateWorkletResponse{Worklet: toWorkletProto(w)}), nil
+}
+
+func (s *WorkletServer) GetWorklet(ctx context.Context, req *connect.Request[flowv1.GetWorkletRequest]) (*connect.Response[flowv1.GetWorkletResponse], error) {
+	uid, err := s.signedIn(ctx, req.Header())
+	if err != nil {
+		return nil, err
+	}
+	w, err := s.getWorklet(req.Msg.Id, uid)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(&flowv1.GetWorkletResponse{Worklet: toWorkletProto(w)}), nil
+}
+
+func (s *WorkletServer) ListWorklets(ctx context.Context, req *connect.Request[flowv1.ListWorkletsRequest]) (*connect.Response[flowv1.ListWorkletsResponse], error) {
+	uid, err := s.signedIn(ctx, req.Header())
+	if err != nil {
+		return nil, err
+	}
+	worklets, err := s.manager.ListWorklets(uid)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to list worklets: %w", err))
+	}
+
+	resp := &flowv1.ListWorkletsResponse{}
+	for _, w := range worklets {
+		resp.Worklets = append(resp.Worklets, toWorkletProto(w))
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (s *WorkletServer) DeleteWorklet(ctx context.Context, req *connect.Request[flowv1.DeleteWorkletRequest]) (*connect.Response[flowv1.DeleteWorkletResponse], error) {
+	uid, err := s.signedIn(ctx, req.Header())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.getWorklet(req.Msg.Id, uid); err != nil {
+		return nil, err
+	}
+	if err := s.manager.DeleteWorklet(req.Msg.Id); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to delete worklet: %w", err))
+	}
+	return connect.NewResponse(&flowv1.DeleteWorkletResponse{}), nil
+}
+
+func (s *WorkletServer) StopWorklet(ctx context.Context, req *connect.Request[flowv1.StopWorkletRequest]) (*connect.Response[flowv1.StopWorkletResponse], error) {
+	uid, err := s.signedIn(ctx, req.Header())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.getWorklet(req.Msg.Id, uid); err != nil {
+		return nil, err
+	}
+	if err := s.manager.StopWorklet(req.Msg.Id); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("failed to stop worklet: %w", err))
+	}
+	return connect.NewResponse(&flowv1.StopWorkletResponse{}), nil
+}
+
+func (s *WorkletServer) RestartWorklet(ctx context.Context, req *connect.Request[flowv1.RestartWorkletRequest]) (*connect.Response[flowv1.RestartWorkletResponse], error) {
+	uid, err := s.signedIn(ctx, req.Header())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.getWorklet(req.Msg.Id, uid); err != nil {
+		return nil, err
+	}
+	if err := s.manager.RestartWorklet(context.WithoutCancel(ctx), req.Msg.Id); err != nil {
+		return nil, managerError(connect.CodeInternal, fmt.Errorf("failed to restart worklet: %w", err))
+	}
+	return connect.NewResponse(&flowv1.RestartWorkletResponse{}), nil
+}
+
+func (s *WorkletServer) SendWorkletPrompt(ctx context.Context, req *connect.Request[flowv1.SendWorkletPromptRequest]) (*connect.Response[flowv1.SendWorkletPromptResponse], error) {
+	if err := requireField("prompt", req.Msg.Prompt); err != nil {
+		return nil, err
+	}
+	uid, err := s.signedIn(ctx, req.Header())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.getWorklet(req.Msg.Id, uid); err != nil {
+		return nil, err
+	}
+
+	prompt, err := s.manager.ProcessPrompt(context.WithoutCancel(ctx), req.Msg.Id, req.Msg.Prompt, uid)
+	if err != nil {
+		return nil, managerError(connect.CodeFailedPrecondition, fmt.Errorf("failed to process prompt: %w", err))
+	}
+	return connect.NewResponse(&flowv1.SendWorkletPromptResponse{
+		PromptId: prompt.ID,
+		Status:   prompt.Status,
+	}), nil
+}
+
+func (s *WorkletServer) GetWorkletLogs(ctx context.Context, req *connect.Request[flowv1.GetWorkletLogsRequest]) (*connect.Response[flowv1.GetWorkletLogsResponse], error) {
+	uid, err := s.signedIn(ctx, req.Header())
+	if err != nil {
+		return nil, err
+	}
+	w, err := s.getWorklet(req.Msg.Id, uid)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(&flowv1.GetWorkletLogsResponse{
+		BuildLogs: w.BuildLogs,
+		LastError: w.LastError,
+	}), nil
+}
+
+// getWorklet loads a worklet and checks that the caller owns it
+func (s *WorkletServer) getWorklet(id, uid string) (*worklet.Worklet, error) {
+	if err := requireField("id", id); err != nil {
+		return nil, err
+	}
+	w, err := s.manager.GetWorklet(id)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("worklet not found: %w", err))
+	}
+	if w.UserID != uid {
+		return nil, connect.NewError(connect.CodePermissionDenied, errors.New("worklet belongs to another user"))
+	}
+	return w, nil
+}
+
+func toWorkletProto(w *worklet.Worklet) *flowv1.Worklet {
+	resp := w.ToResponse()
+	return &flowv1.Worklet{
+		Id:          resp.ID,
+		Name:        resp.Name,
+		Description: resp.Description,
+		Status:      string(resp.Status),
+		GitRepo:     resp.GitRepo,
+		Branch:      resp.Branch,
+		WebUrl:      resp.WebURL,
+		Port:        int32(resp.Port),
+		Environment: resp.Environment,
+		LastPrompt:  resp.LastPrompt,
+		LastError:   resp.LastError,
+		CreatedAt:   timestamppb.New(resp.CreatedAt),
+		UpdatedAt:   timestamppb.New(resp.UpdatedAt),
+	}
+}
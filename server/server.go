@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/breadchris/flow/config"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Server runs the main HTTP listener, optionally with TLS and a plain HTTP
+// listener that redirects to HTTPS and answers ACME challenges
+type Server struct {
+	cfg      config.ServerConfig
+	http     *http.Server
+	redirect *http.Server
+	autocert *autocert.Manager
+	hosts    []autocert.HostPolicy // Policies for certificates beyond the configured domains
+}
+
+// New creates a server for handler using the listen address, timeouts, and TLS settings in cfg
+func New(cfg config.ServerConfig, handler http.Handler) *Server {
+	// Serve HTTP/2 over cleartext so gRPC clients work without TLS
+	if !cfg.TLS.Enabled() {
+		handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+
+	s := &Server{
+		cfg: cfg,
+		http: &http.Server{
+			Addr:              cfg.Addr,
+			Handler:           handler,
+			ReadTimeout:       cfg.ReadTimeout,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+		},
+	}
+
+	if len(cfg.TLS.AutocertDomains) > 0 {
+		s.autocert = &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: s.hostPolicy(autocert.HostWhitelist(cfg.TLS.AutocertDomains...)),
+			Cache:      autocert.DirCache(cfg.TLS.AutocertCacheDir),
+			Email:      cfg.TLS.AutocertEmail,
+		}
+		s.http.TLSConfig = s.autocert.TLSConfig()
+	}
+
+	// Autocert needs the HTTP listener for http-01 challenges even without redirects
+	if cfg.TLS.Enabled() && (cfg.TLS.RedirectHTTP || s.autocert != nil) {
+		var redirectHandler http.Handler = http.HandlerFunc(s.redirectToHTTPS)
+		if s.autocert != nil {
+			redirectHandler = s.autocert.HTTPHandler(redirectHandler)
+		}
+		s.redirect = &http.Server{
+			Addr:              cfg.TLS.HTTPAddr,
+			Handler:           redirectHandler,
+			ReadTimeout:       cfg.ReadTimeout,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+		}
+	}
+
+	return s
+}
+
+// AllowHosts lets autocert request certificates for hosts policy accepts, in
+// addition to the configured domains. Call it before ListenAndServe.
+func (s *Server) AllowHosts(policy autocert.HostPolicy) {
+	s.hosts = append(s.hosts, policy)
+}
+
+// hostPolicy accepts hosts the configured domains or any added policy accepts
+func (s *Server) hostPolicy(domains autocert.HostPolicy) autocert.HostPolicy {
+	return func(ctx context.Context, host string) error {
+		err := domains(ctx, host)
+		if err == nil {
+			return nil
+		}
+		for _, policy := range s.hosts {
+			if policy(ctx, host) == nil {
+				return nil
+			}
+		}
+		return err
+	}
+}
+
+// Addr returns the configured listen address
+func (s *Server) Addr() string {
+	return s.cfg.Addr
+}
+
+// ListenAndServe blocks serving requests until the server is shut down.
+// It returns nil after a graceful shutdown.
+func (s *Server) ListenAndServe() error {
+	if s.redirect != nil {
+		go func() {
+			slog.Info("Starting HTTP redirect server", "addr", s.redirect.Addr)
+			if err := s.redirect.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("HTTP redirect server failed", "error", err)
+			}
+		}()
+	}
+
+	var err error
+	switch {
+	case s.autocert != nil:
+		slog.Info("Starting HTTPS server with autocert", "addr", s.cfg.Addr, "domains", s.cfg.TLS.AutocertDomains)
+		err = s.http.ListenAndServeTLS("", "")
+	case s.cfg.TLS.Enabled():
+		slog.Info("Starting HTTPS server", "addr", s.cfg.Addr, "cert_file", s.cfg.TLS.CertFile)
+		err = s.http.ListenAndServeTLS(s.cfg.TLS.CertFile, s.cfg.TLS.KeyFile)
+	default:
+		slog.Info("Starting HTTP server", "addr", s.cfg.Addr)
+		err = s.http.ListenAndServe()
+	}
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve on %s: %w", s.cfg.Addr, err)
+	}
+	return nil
+}
+
+// Shutdown gracefully stops the main and redirect listeners
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.redirect != nil {
+		if err := s.redirect.Shutdown(ctx); err != nil {
+			slog.Error("Failed to shutdown HTTP redirect server", "error", err)
+		}
+	}
+	return s.http.Shutdown(ctx)
+}
+
+// redirectToHTTPS sends plain HTTP requests to the same host and path over HTTPS
+func (s *Server) redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if _, port, err := net.SplitHostPort(s.cfg.Addr); err == nil && port != "" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+
+	target := "https://" + host + r.URL.RequestURI()
+	http.Redirect(w, r, target, http.StatusMovedPermanently)
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAppliesTimeouts(t *testing.T) {
+	s := New(config.ServerConfig{
+		Addr:              ":9000",
+		ReadTimeout:       5 * time.Second,
+		ReadHeaderTimeout: 2 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       time.Minute,
+	}, http.NotFoundHandler())
+
+	assert.Equal(t, ":9000", s.Addr())
+	assert.Equal(t, 5*time.Second, s.http.ReadTimeout)
+	assert.Equal(t, 2*time.Second, s.http.ReadHeaderTimeout)
+	assert.Equal(t, 10*time.Second, s.http.WriteTimeout)
+	assert.Equal(t, time.Minute, s.http.IdleTimeout)
+	assert.Nil(t, s.redirect)
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	s := New(config.ServerConfig{
+		Addr: ":8443",
+		TLS: config.TLSConfig{
+			CertFile:     "cert.pem",
+			KeyFile:      "key.pem",
+			RedirectHTTP: true,
+			HTTPAddr:     ":8080",
+		},
+	}, http.NotFoundHandler())
+
+	if assert.NotNil(t, s.redirect) {
+		rec := httptest.NewRecorder()
+		s.redirect.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com:8080/code/render/app.tsx?x=1", nil))
+
+		assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+		assert.Equal(t, "https://example.com:8443/code/render/app.tsx?x=1", rec.Header().Get("Location"))
+	}
+}
+
+func TestAllowHostsExtendsAutocertDomains(t *testing.T) {
+	s := New(config.ServerConfig{
+		Addr: ":443",
+		TLS:  config.TLSConfig{AutocertDomains: []string{"flow.example.com"}, HTTPAddr: ":80"},
+	}, http.NotFoundHandler())
+	s.AllowHosts(func(_ context.Context, host string) error {
+		if host == "w1.worklets.example.com" {
+			return nil
+		}
+		return fmt.Errorf("unknown worklet")
+	})
+
+	ctx := context.Background()
+	assert.NoError(t, s.autocert.HostPolicy(ctx, "flow.example.com"))
+	assert.NoError(t, s.autocert.HostPolicy(ctx, "w1.worklets.example.com"))
+	assert.Error(t, s.autocert.HostPolicy(ctx, "w2.worklets.example.com"))
+}
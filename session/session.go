@@ -3,6 +3,7 @@ package session
 import (
 	"context"
 	"encoding/json"
+	"net/http"
 	"time"
 
 	"github.com/breadchris/share/scs"
@@ -74,6 +75,21 @@ func (s *SessionManager) GetUserID(ctx context.Context) (string, error) {
 	return userID, nil
 }
 
+// UserIDFromRequest loads the session from the request cookie and returns its
+// user. Unlike LoadAndSave it does not buffer the response, so it is safe for
+// streaming and WebSocket handlers.
+func (s *SessionManager) UserIDFromRequest(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(s.Cookie.Name)
+	if err != nil {
+		return "", UserLoginError
+	}
+	ctx, err := s.Load(r.Context(), cookie.Value)
+	if err != nil {
+		return "", err
+	}
+	return s.GetUserID(ctx)
+}
+
 func (s *SessionManager) SetUserID(ctx context.Context, id string) {
 	s.Put(ctx, UserIDCtxKey, id)
 }
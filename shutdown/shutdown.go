@@ -0,0 +1,122 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrDraining is returned when new work is rejected because the server is shutting down
+var ErrDraining = errors.New("server is shutting down")
+
+// Task describes a unit of in-flight work such as a Claude response stream or worklet build
+type Task struct {
+	Kind    string
+	ID      string
+	Started time.Time
+
+	// onInterrupt runs if the task is still active when the drain deadline passes
+	onInterrupt func()
+}
+
+// Drainer tracks in-flight work so shutdown can stop accepting new work and
+// wait for active work to finish
+type Drainer struct {
+	mu       sync.Mutex
+	draining bool
+	nextID   uint64
+	tasks    map[uint64]*Task
+	idle     chan struct{}
+}
+
+// NewDrainer creates an empty drainer that accepts work
+func NewDrainer() *Drainer {
+	return &Drainer{tasks: make(map[uint64]*Task)}
+}
+
+// Start registers a task and returns a function that marks it finished.
+// It returns ErrDraining once Drain has been called. onInterrupt may be nil.
+func (d *Drainer) Start(kind, id string, onInterrupt func()) (func(), error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.draining {
+		return nil, ErrDraining
+	}
+
+	d.nextID++
+	key := d.nextID
+	d.tasks[key] = &Task{Kind: kind, ID: id, Started: time.Now(), onInterrupt: onInterrupt}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { d.finish(key) })
+	}, nil
+}
+
+func (d *Drainer) finish(key uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.tasks, key)
+	if len(d.tasks) == 0 && d.idle != nil {
+		close(d.idle)
+		d.idle = nil
+	}
+}
+
+// Draining reports whether the drainer has stopped accepting new work
+func (d *Drainer) Draining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.draining
+}
+
+// Active returns a snapshot of the tasks still in flight
+func (d *Drainer) Active() []Task {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tasks := make([]Task, 0, len(d.tasks))
+	for _, t := range d.tasks {
+		tasks = append(tasks, *t)
+	}
+	return tasks
+}
+
+// Drain stops accepting new work and waits for active tasks to finish or for
+// ctx to be done. Tasks still running at the deadline have their interrupt
+// callbacks invoked and are returned.
+func (d *Drainer) Drain(ctx context.Context) []Task {
+	d.mu.Lock()
+	d.draining = true
+	if len(d.tasks) == 0 {
+		d.mu.Unlock()
+		return nil
+	}
+	idle := make(chan struct{})
+	d.idle = idle
+	slog.Info("Draining in-flight work", "tasks", len(d.tasks))
+	d.mu.Unlock()
+
+	select {
+	case <-idle:
+		slog.Info("All in-flight work finished")
+		return nil
+	case <-ctx.Done():
+	}
+
+	interrupted := d.Active()
+	for _, t := range interrupted {
+		slog.Warn("Interrupting in-flight work at shutdown deadline",
+			"kind", t.Kind,
+			"id", t.ID,
+			"running_for", time.Since(t.Started).Round(time.Second))
+		if t.onInterrupt != nil {
+			t.onInterrupt()
+		}
+	}
+	return interrupted
+}
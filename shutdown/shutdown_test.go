@@ -0,0 +1,66 @@
+package shutdown
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrainWaitsForActiveTasks(t *testing.T) {
+	d := NewDrainer()
+
+	done, err := d.Start("claude_stream", "thread-1", nil)
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.Empty(t, d.Drain(ctx))
+	assert.Empty(t, d.Active())
+}
+
+func TestDrainRejectsNewWork(t *testing.T) {
+	d := NewDrainer()
+	assert.Empty(t, d.Drain(context.Background()))
+	assert.True(t, d.Draining())
+
+	_, err := d.Start("worklet_build", "w1", nil)
+	assert.ErrorIs(t, err, ErrDraining)
+}
+
+func TestDrainInterruptsAtDeadline(t *testing.T) {
+	d := NewDrainer()
+
+	interrupted := false
+	_, err := d.Start("worklet_build", "w1", func() { interrupted = true })
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	remaining := d.Drain(ctx)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, "w1", remaining[0].ID)
+	assert.True(t, interrupted)
+}
+
+func TestDoneIsIdempotent(t *testing.T) {
+	d := NewDrainer()
+
+	done, err := d.Start("claude_stream", "a", nil)
+	require.NoError(t, err)
+	_, err = d.Start("claude_stream", "b", nil)
+	require.NoError(t, err)
+
+	done()
+	done()
+	assert.Len(t, d.Active(), 1)
+}
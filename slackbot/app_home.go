@@ -0,0 +1,137 @@
+package slackbot
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/breadchris/flow/claude"
+	"github.com/breadchris/flow/worklet"
+	"github.com/slack-go/slack"
+)
+
+// homeStopSessionActionID is the action ID of the App Home button that ends a session
+const homeStopSessionActionID = "home_session_stop"
+
+const (
+	appHomeSessionsLimit = 10 // Most active sessions listed in the App Home
+	appHomeWorkletsLimit = 5  // Most recent worklets listed in the App Home
+)
+
+// appHomeSession is a session as the App Home lists it
+type appHomeSession struct {
+	*SlackClaudeSession
+	Running bool // Whether its Claude process is up, rather than resumed on the next reply
+}
+
+// appHome is what a user's App Home tab shows
+type appHome struct {
+	Sessions []appHomeSession
+	Worklets []*worklet.Worklet
+	Usage    *claude.UsageSummary // This month's usage; nil when it couldn't be loaded
+}
+
+// publishAppHome renders a user's App Home tab from the database
+func (b *SlackBot) publishAppHome(userID string) {
+	home := appHome{}
+
+	sessions, err := b.sessionDB.GetUserActiveSessions(userID, appHomeSessionsLimit)
+	if err != nil {
+		slog.Error("Failed to load sessions for App Home", "user_id", userID, "error", err)
+	}
+	for _, session := range sessions {
+		_, running := b.claudeService.GetProcess(session.SessionID)
+		home.Sessions = append(home.Sessions, appHomeSession{SlackClaudeSession: session, Running: running})
+	}
+
+	if b.workletManager != nil {
+		worklets, err := b.workletManager.ListWorklets(userID)
+		if err != nil {
+			slog.Error("Failed to load worklets for App Home", "user_id", userID, "error", err)
+		}
+		slices.SortFunc(worklets, func(x, y *worklet.Worklet) int {
+			return y.CreatedAt.Compare(x.CreatedAt)
+		})
+		home.Worklets = worklets[:min(len(worklets), appHomeWorkletsLimit)]
+	}
+
+	if usage := b.claudeService.Usage(); usage != nil {
+		now := time.Now()
+		monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		if home.Usage, err = usage.GetUserUsage(userID, monthStart); err != nil {
+			slog.Error("Failed to load usage for App Home", "user_id", userID, "error", err)
+		}
+	}
+
+	view := slack.HomeTabViewRequest{
+		Type:   slack.VTHomeTab,
+		Blocks: slack.Blocks{BlockSet: appHomeBlocks(home)},
+	}
+	if _, err := b.client.PublishView(userID, view, ""); err != nil {
+		slog.Error("Failed to publish App Home", "user_id", userID, "error", err)
+	}
+}
+
+// appHomeBlocks lays out a user's sessions, worklets, and usage
+func appHomeBlocks(home appHome) []slack.Block {
+	markdown := func(text string) *slack.TextBlockObject {
+		return slack.NewTextBlockObject(slack.MarkdownType, text, false, false)
+	}
+	header := func(text string) slack.Block {
+		return slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, text, false, false))
+	}
+
+	blocks := []slack.Block{header("Claude sessions")}
+	if len(home.Sessions) == 0 {
+		blocks = append(blocks, slack.NewSectionBlock(markdown("_No active sessions. Start one with `/flow <prompt>`._"), nil, nil))
+	}
+	for _, session := range home.Sessions {
+		state := "idle"
+		if session.Running {
+			state = "running"
+		}
+		text := fmt.Sprintf("*<#%s>* · %s\n`%s` · last active %s",
+			session.ChannelID, state, session.SessionID, session.LastActivity.Format(time.DateTime))
+		stop := slack.NewButtonBlockElement(homeStopSessionActionID, session.ThreadTS,
+			slack.NewTextBlockObject(slack.PlainTextType, "Stop", false, false))
+		stop.Style = slack.StyleDanger
+		blocks = append(blocks, slack.NewSectionBlock(markdown(text), nil, slack.NewAccessory(stop)))
+	}
+
+	blocks = append(blocks, slack.NewDividerBlock(), header("Recent worklets"))
+	if len(home.Worklets) == 0 {
+		blocks = append(blocks, slack.NewSectionBlock(markdown("_No worklets yet. Start one with `/flow <repository URL> <prompt>`._"), nil, nil))
+	}
+	for _, w := range home.Worklets {
+		text := fmt.Sprintf("*%s* · %s", w.Name, w.Status)
+		if w.WebURL != "" {
+			text += fmt.Sprintf("\n<%s>", w.WebURL)
+		}
+		blocks = append(blocks, slack.NewSectionBlock(markdown(text), nil, nil))
+	}
+
+	blocks = append(blocks, slack.NewDividerBlock(), header("Usage this month"))
+	usage := "_Usage isn't available._"
+	if home.Usage != nil {
+		usage = fmt.Sprintf("%d turns · %d input and %d output tokens · $%.2f",
+			home.Usage.Turns, home.Usage.InputTokens, home.Usage.OutputTokens, home.Usage.CostUSD)
+	}
+	return append(blocks, slack.NewSectionBlock(markdown(usage), nil, nil))
+}
+
+// stopSessionFromHome ends one of a user's sessions from their App Home and
+// shows the App Home without it
+func (b *SlackBot) stopSessionFromHome(userID, threadTS string) {
+	session, exists := b.getSession(threadTS)
+	if exists && session.UserID == userID {
+		b.claudeService.StopSession(session.SessionID)
+		b.removeSession(threadTS)
+		b.audit(userID, session.ChannelID, threadTS, auditSessionEnded, map[string]interface{}{"session_id": session.SessionID})
+		if _, err := b.postMessage(session.ChannelID, threadTS,
+			fmt.Sprintf("⏹️ _<@%s> ended this Claude session._", userID)); err != nil {
+			slog.Error("Failed to post session stop notice", "error", err)
+		}
+	}
+	b.publishAppHome(userID)
+}
@@ -0,0 +1,65 @@
+package slackbot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/claude"
+	"github.com/breadchris/flow/worklet"
+	"github.com/slack-go/slack"
+)
+
+func TestAppHomeBlocks(t *testing.T) {
+	home := appHome{
+		Sessions: []appHomeSession{{
+			SlackClaudeSession: &SlackClaudeSession{
+				ThreadTS:     "123.456",
+				ChannelID:    "C0123",
+				SessionID:    "0b6c2d5e",
+				LastActivity: time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC),
+			},
+			Running: true,
+		}},
+		Worklets: []*worklet.Worklet{{Name: "api", Status: worklet.StatusRunning, WebURL: "https://api.example.com"}},
+		Usage:    &claude.UsageSummary{Turns: 12, InputTokens: 3400, OutputTokens: 560, CostUSD: 1.234},
+	}
+
+	blocks := appHomeBlocks(home)
+	var texts []string
+	var stop *slack.ButtonBlockElement
+	for _, block := range blocks {
+		if section, ok := block.(*slack.SectionBlock); ok {
+			texts = append(texts, section.Text.Text)
+			if section.Accessory != nil {
+				stop = section.Accessory.ButtonElement
+			}
+		}
+	}
+
+	want := []string{
+		"*<#C0123>* · running\n`0b6c2d5e` · last active 2025-03-04 05:06:07",
+		"*api* · running\n<https://api.example.com>",
+		"12 turns · 3400 input and 560 output tokens · $1.23",
+	}
+	if strings.Join(texts, "|") != strings.Join(want, "|") {
+		t.Errorf("appHomeBlocks() sections = %q; want %q", texts, want)
+	}
+	if stop == nil || stop.ActionID != homeStopSessionActionID || stop.Value != "123.456" {
+		t.Errorf("stop button = %+v", stop)
+	}
+}
+
+func TestAppHomeBlocksEmpty(t *testing.T) {
+	blocks := appHomeBlocks(appHome{})
+	var texts []string
+	for _, block := range blocks {
+		if section, ok := block.(*slack.SectionBlock); ok {
+			texts = append(texts, section.Text.Text)
+		}
+	}
+	if len(texts) != 3 || !strings.Contains(texts[0], "No active sessions") ||
+		!strings.Contains(texts[1], "No worklets") || !strings.Contains(texts[2], "isn't available") {
+		t.Errorf("appHomeBlocks() for a new user = %q", texts)
+	}
+}
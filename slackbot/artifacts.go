@@ -0,0 +1,153 @@
+package slackbot
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	artifactMaxBytes = 1 << 20 // Largest file Claude writes that is uploaded to the thread
+	artifactMaxFiles = 5       // Most files uploaded after a turn
+	artifactMaxScan  = 5000    // Most files looked at in a session directory
+)
+
+// artifactExtensions are the kinds of file Claude writes that are worth
+// sharing: patches, reports, data, and images
+var artifactExtensions = map[string]bool{
+	".patch": true, ".diff": true,
+	".md": true, ".txt": true, ".log": true, ".html": true, ".pdf": true,
+	".csv": true, ".json": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true, ".svg": true,
+}
+
+// artifactSkipDirs are directories whose files are never uploaded
+var artifactSkipDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+}
+
+// fileStamp is what a snapshot remembers about a file to tell whether it changed
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// artifactSnapshot records the shareable files in a session directory so the
+// ones Claude creates or changes during a turn can be found afterwards
+type artifactSnapshot struct {
+	dir   string
+	files map[string]fileStamp
+}
+
+// newArtifactSnapshot records the files in dir; an empty dir records nothing
+func newArtifactSnapshot(dir string) *artifactSnapshot {
+	return &artifactSnapshot{dir: dir, files: scanArtifacts(dir)}
+}
+
+// changed returns the paths, relative to the directory, of the shareable files
+// created or modified since the snapshot was taken, and takes a new snapshot
+func (s *artifactSnapshot) changed() []string {
+	current := scanArtifacts(s.dir)
+	var paths []string
+	for path, stamp := range current {
+		if previous, ok := s.files[path]; !ok || previous != stamp {
+			paths = append(paths, path)
+		}
+	}
+	s.files = current
+	slices.Sort(paths)
+	return paths
+}
+
+// scanArtifacts stamps the files in dir that could be uploaded. Hidden files
+// and directories, such as .git and .claude, are skipped.
+func scanArtifacts(dir string) map[string]fileStamp {
+	files := make(map[string]fileStamp)
+	if dir == "" {
+		return files
+	}
+	scanned := 0
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			if artifactSkipDirs[entry.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if scanned++; scanned > artifactMaxScan {
+			return filepath.SkipAll
+		}
+		if !entry.Type().IsRegular() || !artifactExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || info.Size() == 0 || info.Size() > artifactMaxBytes {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		files[rel] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return files
+}
+
+// postArtifacts uploads the files Claude created or changed in its session
+// directory during the turn to the session's thread
+func (b *SlackBot) postArtifacts(session *SlackClaudeSession, snapshot *artifactSnapshot) {
+	paths := snapshot.changed()
+	if len(paths) == 0 {
+		return
+	}
+	if len(paths) > artifactMaxFiles {
+		notice := fmt.Sprintf("📎 _Claude created or changed %d files; the first %d are attached._", len(paths), artifactMaxFiles)
+		if _, err := b.postMessage(session.ChannelID, session.ThreadTS, notice); err != nil {
+			slog.Error("Failed to post artifact notice", "error", err)
+		}
+		paths = paths[:artifactMaxFiles]
+	}
+
+	for _, path := range paths {
+		stamp := snapshot.files[path]
+		_, err := b.client.UploadFileV2(slack.UploadFileV2Parameters{
+			File:            filepath.Join(snapshot.dir, path),
+			FileSize:        int(stamp.size),
+			Filename:        filepath.Base(path),
+			Title:           path,
+			Channel:         session.ChannelID,
+			ThreadTimestamp: session.ThreadTS,
+		})
+		if err != nil {
+			slog.Error("Failed to upload Claude artifact",
+				"thread_ts", session.ThreadTS,
+				"path", path,
+				"error", err)
+			continue
+		}
+		slog.Info("Uploaded Claude artifact",
+			"thread_ts", session.ThreadTS,
+			"session_id", session.SessionID,
+			"path", path,
+			"size", stamp.size,
+			"action", "artifact_uploaded",
+		)
+	}
+}
@@ -0,0 +1,61 @@
+package slackbot
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArtifactSnapshotChanged(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "notes.md"), "old notes")
+	writeFile(t, filepath.Join(dir, "untouched.txt"), "same")
+
+	snapshot := newArtifactSnapshot(dir)
+	if changed := snapshot.changed(); len(changed) != 0 {
+		t.Fatalf("changed() before any writes = %v", changed)
+	}
+
+	writeFile(t, filepath.Join(dir, "notes.md"), "new notes, longer")
+	writeFile(t, filepath.Join(dir, "reports", "fix.patch"), "--- a\n+++ b\n")
+	writeFile(t, filepath.Join(dir, "main.go"), "package main")
+	writeFile(t, filepath.Join(dir, ".claude", "settings.json"), "{}")
+	writeFile(t, filepath.Join(dir, "node_modules", "pkg", "README.md"), "readme")
+	writeFile(t, filepath.Join(dir, "big.log"), strings.Repeat("x", artifactMaxBytes+1))
+
+	want := []string{"notes.md", filepath.Join("reports", "fix.patch")}
+	if changed := snapshot.changed(); strings.Join(changed, ",") != strings.Join(want, ",") {
+		t.Errorf("changed() = %v; want %v", changed, want)
+	}
+
+	// Each call compares against the previous one
+	if changed := snapshot.changed(); len(changed) != 0 {
+		t.Errorf("changed() with no new writes = %v", changed)
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(dir, "untouched.txt"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if changed := snapshot.changed(); strings.Join(changed, ",") != "untouched.txt" {
+		t.Errorf("changed() after a touch = %v; want [untouched.txt]", changed)
+	}
+}
+
+func TestArtifactSnapshotWithoutDir(t *testing.T) {
+	if changed := newArtifactSnapshot("").changed(); len(changed) != 0 {
+		t.Errorf("changed() without a directory = %v", changed)
+	}
+}
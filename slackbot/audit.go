@@ -0,0 +1,296 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuditPath is where admins export the audit log
+const AuditPath = "/api/slack/audit"
+
+// Actions recorded in the audit log
+const (
+	auditCommand         = "command"          // A slash command or /flow in a thread
+	auditSessionStarted  = "session_started"  // A Claude session started or resumed
+	auditSessionEnded    = "session_ended"    // A user ended a Claude session
+	auditToolApproved    = "tool_approved"    // A user approved a held command
+	auditToolDenied      = "tool_denied"      // A user denied a held command
+	auditPRCreated       = "pr_created"       // A pull request was opened for a worklet
+	auditWorkletDeployed = "worklet_deployed" // A worklet was created and deployed
+	auditIssueCreated    = "issue_created"    // A GitHub issue was filed from a thread
+)
+
+// auditActions are the actions /flow audit can filter by
+var auditActions = []string{auditCommand, auditSessionStarted, auditSessionEnded, auditToolApproved, auditToolDenied, auditPRCreated, auditWorkletDeployed, auditIssueCreated}
+
+const auditCommandHelp = "Usage: `/flow audit [<@user>] [action] [7d|30d]`, where action is one of " +
+	"`command`, `session_started`, `session_ended`, `tool_approved`, `tool_denied`, `pr_created`, `worklet_deployed`, or `issue_created`."
+
+const (
+	auditReplyLimit  = 25    // Most events /flow audit lists
+	auditExportLimit = 1000  // Events the export returns unless it asks for a limit
+	auditExportMax   = 10000 // Most events one export may return
+)
+
+// AuditFilter narrows the audit events a query returns; empty fields match everything
+type AuditFilter struct {
+	ActorID   string
+	ChannelID string
+	Action    string
+	Since     time.Time
+}
+
+// AuditStore keeps the audit log of what the bot did for its users
+type AuditStore struct {
+	db *gorm.DB
+}
+
+func NewAuditStore(db *gorm.DB) *AuditStore {
+	return &AuditStore{db: db}
+}
+
+// Record saves an audit event
+func (s *AuditStore) Record(event *models.AuditEvent) error {
+	event.ID = uuid.NewString()
+	if err := s.db.Create(event).Error; err != nil {
+		return fmt.Errorf("failed to save audit event: %w", err)
+	}
+	return nil
+}
+
+// List returns the most recent events matching filter, newest first
+func (s *AuditStore) List(filter AuditFilter, limit int) ([]models.AuditEvent, error) {
+	query := s.db.Order("created_at DESC").Limit(limit)
+	if filter.ActorID != "" {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.ChannelID != "" {
+		query = query.Where("channel_id = ?", filter.ChannelID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+
+	var auditEvents []models.AuditEvent
+	if err := query.Find(&auditEvents).Error; err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	return auditEvents, nil
+}
+
+// audit records an action taken for actorID. Failures are logged rather than
+// returned so they never stop the action itself.
+func (b *SlackBot) audit(actorID, channelID, threadTS, action string, payload map[string]interface{}) {
+	if b.auditLog == nil {
+		return
+	}
+	event := &models.AuditEvent{
+		ActorID:   actorID,
+		ChannelID: channelID,
+		ThreadTS:  threadTS,
+		Action:    action,
+		Payload:   *models.MakeJSONField(payload),
+	}
+	if err := b.auditLog.Record(event); err != nil {
+		slog.Error("Failed to record audit event", "audit_action", action, "actor_id", actorID, "error", err)
+	}
+}
+
+// auditSessionStarts records every Claude session that starts or resumes
+func (b *SlackBot) auditSessionStarts(ctx context.Context) {
+	started, unsubscribe := events.Channel(b.events, events.TopicSessionStarted)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case start := <-started:
+			channelID, threadTS := start.ChannelID, start.ThreadTS
+			if session, exists := b.sessionByID(start.SessionID); exists && channelID == "" {
+				channelID, threadTS = session.ChannelID, session.ThreadTS
+			}
+			b.audit(start.UserID, channelID, threadTS, auditSessionStarted, map[string]interface{}{
+				"session_id": start.SessionID,
+				"resumed":    start.Resumed,
+			})
+		}
+	}
+}
+
+// parseAuditArgs reads the filters of /flow audit: a user, an action, and a
+// period of days, which defaults to 7
+func parseAuditArgs(args []string) (filter AuditFilter, days int, ok bool) {
+	days = 7
+	seenPeriod := false
+	for _, arg := range args {
+		lower := strings.ToLower(arg)
+		switch {
+		case strings.HasPrefix(arg, "<@") && filter.ActorID == "":
+			userID, isUser := parseSlackUserID(arg)
+			if !isUser {
+				return AuditFilter{}, 0, false
+			}
+			filter.ActorID = userID
+		case slices.Contains(auditActions, lower) && filter.Action == "":
+			filter.Action = lower
+		case usagePeriods[lower] > 0 && !seenPeriod:
+			days, seenPeriod = usagePeriods[lower], true
+		default:
+			return AuditFilter{}, 0, false
+		}
+	}
+	return filter, days, true
+}
+
+// flowAuditReply runs /flow audit and returns the most recent events in the
+// channel. The audit log is for admins.
+func (b *SlackBot) flowAuditReply(userID, channelID string, args []string) string {
+	if b.userRole(userID) != roleAdmin {
+		return "Only Slack bot admins can see the audit log."
+	}
+	if b.auditLog == nil {
+		return "❌ The audit log isn't available."
+	}
+	filter, days, ok := parseAuditArgs(args)
+	if !ok {
+		return auditCommandHelp
+	}
+	filter.ChannelID = channelID
+	filter.Since = time.Now().AddDate(0, 0, -days)
+
+	auditEvents, err := b.auditLog.List(filter, auditReplyLimit)
+	if err != nil {
+		slog.Error("Failed to list audit events", "channel_id", channelID, "error", err)
+		return "❌ Failed to get the audit log. Please try again."
+	}
+	return formatAuditEvents(auditEvents, channelID, days)
+}
+
+// formatAuditEvents lays out audit events for Slack, one per line
+func formatAuditEvents(auditEvents []models.AuditEvent, channelID string, days int) string {
+	var reply strings.Builder
+	fmt.Fprintf(&reply, "*Audit log for <#%s>, last %d days*\n", channelID, days)
+	if len(auditEvents) == 0 {
+		reply.WriteString("Nothing was recorded.")
+		return reply.String()
+	}
+	for _, event := range auditEvents {
+		fmt.Fprintf(&reply, "• %s <@%s> `%s`", event.CreatedAt.Format("Jan 2 15:04"), event.ActorID, event.Action)
+		if detail := auditDetail(event.Payload.Data); detail != "" {
+			fmt.Fprintf(&reply, " %s", detail)
+		}
+		reply.WriteString("\n")
+	}
+	if len(auditEvents) == auditReplyLimit {
+		fmt.Fprintf(&reply, "_Showing the latest %d. Admins can export the rest from `%s`._", auditReplyLimit, AuditPath)
+	}
+	return strings.TrimSuffix(reply.String(), "\n")
+}
+
+// auditDetail summarizes an event's payload in its key=value pairs, sorted by key
+func auditDetail(payload map[string]interface{}) string {
+	keys := make([]string, 0, len(payload))
+	for key := range payload {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = fmt.Sprintf("%s=%s", key, clipBytes(fmt.Sprint(payload[key]), 80))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// AuditHandler exports the audit log as JSON, or as CSV with format=csv.
+// actor, channel, action, since (RFC 3339), and limit narrow the export.
+func (b *SlackBot) AuditHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if b.auditLog == nil {
+			http.Error(w, "Audit log not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		query := r.URL.Query()
+		filter := AuditFilter{
+			ActorID:   query.Get("actor"),
+			ChannelID: query.Get("channel"),
+			Action:    query.Get("action"),
+		}
+		if since := query.Get("since"); since != "" {
+			t, err := time.Parse(time.RFC3339, since)
+			if err != nil {
+				http.Error(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+			filter.Since = t
+		}
+		limit := auditExportLimit
+		if l := query.Get("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n <= 0 {
+				http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+				return
+			}
+			limit = min(n, auditExportMax)
+		}
+
+		auditEvents, err := b.auditLog.List(filter, limit)
+		if err != nil {
+			slog.Error("Failed to export audit log", "error", err)
+			http.Error(w, "Failed to export audit log", http.StatusInternalServerError)
+			return
+		}
+
+		if query.Get("format") == "csv" {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", `attachment; filename="audit.csv"`)
+			if err := writeAuditCSV(w, auditEvents); err != nil {
+				slog.Error("Failed to write audit log", "error", err)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(auditEvents); err != nil {
+			slog.Error("Failed to write audit log", "error", err)
+		}
+	})
+}
+
+// writeAuditCSV writes audit events as CSV with their payloads as JSON
+func writeAuditCSV(w io.Writer, auditEvents []models.AuditEvent) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"time", "actor_id", "channel_id", "thread_ts", "action", "payload"}); err != nil {
+		return err
+	}
+	for _, event := range auditEvents {
+		payload, err := json.Marshal(event.Payload.Data)
+		if err != nil {
+			return err
+		}
+		record := []string{event.CreatedAt.Format(time.RFC3339), event.ActorID, event.ChannelID, event.ThreadTS, event.Action, string(payload)}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,84 @@
+package slackbot
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestParseAuditArgs(t *testing.T) {
+	filter, days, ok := parseAuditArgs([]string{"<@U0123ABC|chris>", "PR_CREATED", "30d"})
+	if !ok || filter.ActorID != "U0123ABC" || filter.Action != auditPRCreated || days != 30 {
+		t.Errorf("parseAuditArgs() = %+v, %d, %v", filter, days, ok)
+	}
+	if _, days, ok := parseAuditArgs(nil); !ok || days != 7 {
+		t.Errorf("parseAuditArgs(nil) = %d, %v; want 7 days", days, ok)
+	}
+	for _, args := range [][]string{{"7d", "30d"}, {"deploys"}, {"<@nobody>"}} {
+		if _, _, ok := parseAuditArgs(args); ok {
+			t.Errorf("parseAuditArgs(%q) accepted bad arguments", args)
+		}
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.AuditEvent{}); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	bot := &SlackBot{
+		config:   &config.SlackBotConfig{Admins: []string{"UADMIN"}},
+		auditLog: NewAuditStore(db),
+	}
+	bot.audit("U1", "C1", "", auditCommand, map[string]interface{}{"command": "/flow", "text": "status"})
+	bot.audit("U2", "C1", "1.1", auditToolApproved, map[string]interface{}{"session_id": "s1", "tool_use_id": "toolu_1"})
+	bot.audit("U1", "C2", "2.2", auditSessionEnded, map[string]interface{}{"session_id": "s2"})
+
+	auditEvents, err := bot.auditLog.List(AuditFilter{ChannelID: "C1"}, 10)
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	if len(auditEvents) != 2 {
+		t.Fatalf("List(C1) returned %d events; want 2", len(auditEvents))
+	}
+	if auditEvents, _ := bot.auditLog.List(AuditFilter{ActorID: "U1", Action: auditSessionEnded}, 10); len(auditEvents) != 1 || auditEvents[0].ThreadTS != "2.2" {
+		t.Errorf("List(U1, session_ended) = %+v", auditEvents)
+	}
+
+	if got := bot.flowAuditReply("U1", "C1", nil); !strings.HasPrefix(got, "Only") {
+		t.Errorf("non-admin asking for the audit log got %q", got)
+	}
+	got := bot.flowAuditReply("UADMIN", "C1", []string{"tool_approved"})
+	if !strings.Contains(got, "<@U2> `tool_approved` session_id=s1 tool_use_id=toolu_1") || strings.Contains(got, "status") {
+		t.Errorf("flowAuditReply(tool_approved) = %q", got)
+	}
+
+	recorder := httptest.NewRecorder()
+	bot.AuditHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, AuditPath+"?format=csv&actor=U1", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("export returned %d", recorder.Code)
+	}
+	records, err := csv.NewReader(recorder.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("export isn't CSV: %v", err)
+	}
+	if len(records) != 3 || records[1][4] != auditSessionEnded || records[2][5] != `{"command":"/flow","text":"status"}` {
+		t.Errorf("export = %q", records)
+	}
+
+	recorder = httptest.NewRecorder()
+	bot.AuditHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, AuditPath+"?since=yesterday", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("export with a bad since returned %d", recorder.Code)
+	}
+}
@@ -0,0 +1,334 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/breadchris/flow/claude"
+	"github.com/breadchris/flow/metrics"
+	"github.com/breadchris/flow/worklet"
+	"github.com/slack-go/slack"
+)
+
+// Action IDs of the buttons posted when Claude finishes a response. Their value is the thread's timestamp.
+const (
+	continueTurnActionID = "claude_turn_continue"
+	stopSessionActionID  = "claude_session_stop"
+	createPRActionID     = "claude_create_pr"
+)
+
+// Action IDs of the buttons that expand and collapse a tool's output. Their
+// value is the output's heading and the output, separated by a newline.
+const (
+	expandToolOutputActionID   = "claude_tool_output_expand"
+	collapseToolOutputActionID = "claude_tool_output_collapse"
+)
+
+const (
+	sectionTextLimit = 3000 // Longest text Slack allows in a section block
+	buttonValueLimit = 2000 // Longest value Slack allows on a button
+	maxMessageBlocks = 50   // Most blocks Slack allows in a message
+	toolPreviewLines = 3    // Lines of tool output shown before it is expanded
+)
+
+// continuePrompt is sent to Claude when the Continue button is pressed
+const continuePrompt = "Please continue."
+
+var codeFenceRegex = regexp.MustCompile("(?s)```([\\w+#.-]*)\n(.*?)\n?```")
+
+// responseBlocks renders Claude's markdown as Block Kit: prose becomes
+// sections, and code blocks become their own sections labelled with their
+// language. It returns nil when the text needs more blocks than Slack allows,
+// in which case the formatted text is posted instead.
+func (b *SlackBot) responseBlocks(text string) []slack.Block {
+	content, truncated := b.truncateMessage(text)
+
+	var blocks []slack.Block
+	last := 0
+	for _, match := range codeFenceRegex.FindAllStringSubmatchIndex(content, -1) {
+		blocks = append(blocks, textSections(b.convertMarkdownToSlack(content[last:match[0]]))...)
+		if lang := content[match[2]:match[3]]; lang != "" {
+			blocks = append(blocks, slack.NewContextBlock("",
+				slack.NewTextBlockObject(slack.MarkdownType, "`"+lang+"`", false, false)))
+		}
+		blocks = append(blocks, codeSections(content[match[4]:match[5]])...)
+		last = match[1]
+	}
+	blocks = append(blocks, textSections(b.convertMarkdownToSlack(content[last:]))...)
+
+	if truncated {
+		blocks = append(blocks, slack.NewContextBlock("",
+			slack.NewTextBlockObject(slack.MarkdownType, "_...message truncated due to length limits_", false, false)))
+	}
+	if len(blocks) > maxMessageBlocks {
+		return nil
+	}
+	return blocks
+}
+
+// textSections splits mrkdwn text into as many sections as Slack needs to show it
+func textSections(text string) []slack.Block {
+	var blocks []slack.Block
+	for _, chunk := range chunkText(strings.TrimSpace(text), sectionTextLimit) {
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, chunk, false, false), nil, nil))
+	}
+	return blocks
+}
+
+// codeSections splits code into preformatted sections
+func codeSections(code string) []slack.Block {
+	var blocks []slack.Block
+	for _, chunk := range chunkText(strings.Trim(code, "\n"), sectionTextLimit-len("``````")) {
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, "```"+chunk+"```", false, false), nil, nil))
+	}
+	return blocks
+}
+
+// chunkText splits text into pieces of at most n bytes, breaking at newlines where it can
+func chunkText(text string, n int) []string {
+	var chunks []string
+	for len(text) > n {
+		cut := strings.LastIndex(text[:n], "\n")
+		if cut <= 0 {
+			cut = n
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+		}
+		chunks = append(chunks, text[:cut])
+		text = strings.TrimPrefix(text[cut:], "\n")
+	}
+	if strings.TrimSpace(text) != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
+
+// toolOutputHeading names the tool whose result an event is, such as "🔧 Bash finished"
+func toolOutputHeading(event claude.ToolEvent) string {
+	if event.IsError {
+		return "❌ " + event.Summary()
+	}
+	return "🔧 " + event.Summary()
+}
+
+// toolOutputBlocks renders a tool's output collapsed to its first few lines,
+// or expanded, with a button to switch between the two. The expanded output is
+// whatever fits in the button's value.
+func toolOutputBlocks(heading, output string, expanded bool) []slack.Block {
+	value := clipBytes(heading+"\n"+strings.TrimSpace(output), buttonValueLimit)
+	_, output, _ = strings.Cut(value, "\n")
+
+	lines := strings.Split(output, "\n")
+	shown := output
+	if !expanded && len(lines) > toolPreviewLines {
+		shown = strings.Join(lines[:toolPreviewLines], "\n") + "\n…"
+	}
+
+	blocks := []slack.Block{
+		slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, "*"+heading+"*", false, false)),
+	}
+	if shown != "" {
+		blocks = append(blocks, codeSections(shown)...)
+	}
+	if len(lines) <= toolPreviewLines {
+		return blocks
+	}
+
+	actionID, label := expandToolOutputActionID, fmt.Sprintf("Show output (%d lines)", len(lines))
+	if expanded {
+		actionID, label = collapseToolOutputActionID, "Hide output"
+	}
+	button := slack.NewButtonBlockElement(actionID, value,
+		slack.NewTextBlockObject(slack.PlainTextType, label, false, false))
+	return append(blocks, slack.NewActionBlock("", button))
+}
+
+// clipBytes shortens text to at most n bytes without splitting a character
+func clipBytes(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:n]
+}
+
+// sessionControlBlocks renders the buttons offered once Claude finishes a response
+func sessionControlBlocks(threadTS string, canCreatePR bool) []slack.Block {
+	text := "_Claude is done. Reply in this thread to keep going._"
+
+	continueButton := slack.NewButtonBlockElement(continueTurnActionID, threadTS,
+		slack.NewTextBlockObject(slack.PlainTextType, "Continue", false, false))
+	continueButton.Style = slack.StylePrimary
+	stop := slack.NewButtonBlockElement(stopSessionActionID, threadTS,
+		slack.NewTextBlockObject(slack.PlainTextType, "Stop session", false, false))
+	stop.Style = slack.StyleDanger
+	buttons := []slack.BlockElement{continueButton, stop}
+	if canCreatePR {
+		buttons = append(buttons, slack.NewButtonBlockElement(createPRActionID, threadTS,
+			slack.NewTextBlockObject(slack.PlainTextType, "Create PR", false, false)))
+	}
+
+	return []slack.Block{
+		slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, text, false, false)),
+		slack.NewActionBlock("", buttons...),
+	}
+}
+
+// postBlocks posts a Block Kit message to a thread, with text shown in notifications
+func (b *SlackBot) postBlocks(channel, threadTS, text string, blocks []slack.Block) (string, error) {
+	options := []slack.MsgOption{
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionAsUser(true),
+	}
+	if len(blocks) > 0 {
+		options = append(options, slack.MsgOptionBlocks(blocks...))
+	}
+	if threadTS != "" {
+		options = append(options, slack.MsgOptionTS(threadTS))
+	}
+
+	timestamp, err := b.outbox.send(channel, func() (string, error) {
+		_, timestamp, err := b.client.PostMessage(channel, options...)
+		return timestamp, err
+	})
+	if err != nil {
+		metrics.SlackAPIErrorsTotal.WithLabelValues("chat.postMessage").Inc()
+	}
+	return timestamp, err
+}
+
+// updateBlocks replaces a message's text and blocks
+func (b *SlackBot) updateBlocks(channel, timestamp, text string, blocks []slack.Block) error {
+	_, err := b.outbox.send(channel, func() (string, error) {
+		_, _, _, err := b.client.UpdateMessage(channel, timestamp,
+			slack.MsgOptionText(text, false),
+			slack.MsgOptionBlocks(blocks...),
+			slack.MsgOptionAsUser(true),
+		)
+		return "", err
+	})
+	if err != nil {
+		metrics.SlackAPIErrorsTotal.WithLabelValues("chat.update").Inc()
+	}
+	return err
+}
+
+// postResponse posts Claude's text to a session's thread, or replaces the
+// message at ts with it when one was already posted
+func (b *SlackBot) postResponse(session *SlackClaudeSession, ts, text string) error {
+	fallback := b.formatClaudeResponse(text)
+	blocks := b.responseBlocks(text)
+	if ts != "" {
+		return b.updateBlocks(session.ChannelID, ts, fallback, blocks)
+	}
+	_, err := b.postBlocks(session.ChannelID, session.ThreadTS, fallback, blocks)
+	return err
+}
+
+// postToolOutput posts a tool's result to a session's thread, rendered for
+// the call that produced it
+func (b *SlackBot) postToolOutput(session *SlackClaudeSession, call, result claude.ToolEvent) {
+	heading, blocks := toolResultBlocks(call, result)
+	if _, err := b.postBlocks(session.ChannelID, session.ThreadTS, heading, blocks); err != nil {
+		slog.Error("Failed to post tool output", "thread_ts", session.ThreadTS, "error", err)
+	}
+}
+
+// toggleToolOutput expands or collapses the tool output in the message whose
+// button was pressed, keeping the command that produced it
+func (b *SlackBot) toggleToolOutput(callback *slack.InteractionCallback, action *slack.BlockAction) {
+	heading, output, _ := strings.Cut(action.Value, "\n")
+	expanded := action.ActionID == expandToolOutputActionID
+	blocks := withCommandBlocks(toolOutputBlocks(heading, output, expanded), commandBlocks(callback.Message))
+	if err := b.updateBlocks(callback.Channel.ID, callback.Message.Timestamp, heading, blocks); err != nil {
+		slog.Error("Failed to toggle tool output", "error", err)
+	}
+}
+
+// postSessionControls offers buttons to continue, stop, or open a pull request
+// for a session once Claude finishes a response
+func (b *SlackBot) postSessionControls(session *SlackClaudeSession) {
+	canCreatePR := b.threadWorklet(session.UserID, session.ThreadTS) != nil
+	if _, err := b.postBlocks(session.ChannelID, session.ThreadTS, "Claude is done.",
+		sessionControlBlocks(session.ThreadTS, canCreatePR)); err != nil {
+		slog.Error("Failed to post session controls", "thread_ts", session.ThreadTS, "error", err)
+	}
+}
+
+// threadWorklet returns the worklet created from a thread, or nil if there isn't one
+func (b *SlackBot) threadWorklet(userID, threadTS string) *worklet.Worklet {
+	if b.workletManager == nil {
+		return nil
+	}
+	worklets, err := b.workletManager.ListWorklets(userID)
+	if err != nil {
+		slog.Error("Failed to list worklets", "user_id", userID, "error", err)
+		return nil
+	}
+	for _, w := range worklets {
+		if w.Environment != nil && w.Environment.Data["SLACK_THREAD_TS"] == threadTS {
+			return w
+		}
+	}
+	return nil
+}
+
+// resolveControls replaces the buttons of the message whose button was pressed with reply
+func (b *SlackBot) resolveControls(callback *slack.InteractionCallback, reply string) {
+	err := b.updateBlocks(callback.Channel.ID, callback.Message.Timestamp, reply, []slack.Block{
+		slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, reply, false, false)),
+	})
+	if err != nil {
+		slog.Error("Failed to update session controls", "error", err)
+	}
+}
+
+// continueClaudeTurn asks Claude to keep going from a Continue button press
+func (b *SlackBot) continueClaudeTurn(callback *slack.InteractionCallback, threadTS string) {
+	session, exists := b.getSession(threadTS)
+	if !exists {
+		b.resolveControls(callback, "There is no Claude session in this thread.")
+		return
+	}
+	b.resolveControls(callback, fmt.Sprintf("▶️ _<@%s> asked Claude to continue._", callback.User.ID))
+	b.sendToClaudeSession(session, continuePrompt)
+}
+
+// stopClaudeSession ends a thread's Claude session from a Stop session button press
+func (b *SlackBot) stopClaudeSession(callback *slack.InteractionCallback, threadTS string) {
+	session, exists := b.getSession(threadTS)
+	if !exists {
+		b.resolveControls(callback, "There is no Claude session in this thread.")
+		return
+	}
+	b.claudeService.StopSession(session.SessionID)
+	b.removeSession(threadTS)
+	b.audit(callback.User.ID, callback.Channel.ID, threadTS, auditSessionEnded, map[string]interface{}{"session_id": session.SessionID})
+	b.resolveControls(callback, fmt.Sprintf("⏹️ _<@%s> ended this Claude session._", callback.User.ID))
+}
+
+// createPullRequestForThread opens a pull request for the worklet created from
+// a thread from a Create PR button press
+func (b *SlackBot) createPullRequestForThread(callback *slack.InteractionCallback, threadTS string) {
+	session, exists := b.getSession(threadTS)
+	userID := callback.User.ID
+	if exists {
+		userID = session.UserID
+	}
+	workletObj := b.threadWorklet(userID, threadTS)
+	if workletObj == nil {
+		b.resolveControls(callback, "There is no worklet for this thread to open a pull request from.")
+		return
+	}
+	b.resolveControls(callback, fmt.Sprintf("🔄 _<@%s> is creating a pull request..._", callback.User.ID))
+	b.createPullRequestForWorklet(context.Background(), workletObj, callback.Channel.ID, threadTS, workletObj.BasePrompt, callback.User.ID)
+}
@@ -0,0 +1,105 @@
+package slackbot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+// blockTexts returns the text of each section and context block
+func blockTexts(blocks []slack.Block) []string {
+	var texts []string
+	for _, block := range blocks {
+		switch block := block.(type) {
+		case *slack.SectionBlock:
+			texts = append(texts, block.Text.Text)
+		case *slack.ContextBlock:
+			texts = append(texts, block.ContextElements.Elements[0].(*slack.TextBlockObject).Text)
+		}
+	}
+	return texts
+}
+
+func TestResponseBlocks(t *testing.T) {
+	bot := &SlackBot{}
+	text := "Run this:\n```go\nfmt.Println(\"hi\")\n```\nThen check the output."
+
+	got := blockTexts(bot.responseBlocks(text))
+	want := []string{"Run this:", "`go`", "```fmt.Println(\"hi\")```", "Then check the output."}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("responseBlocks() = %q; want %q", got, want)
+	}
+}
+
+func TestResponseBlocksSplitsLongCode(t *testing.T) {
+	bot := &SlackBot{}
+	code := strings.Repeat("line of code\n", 290)
+
+	blocks := bot.responseBlocks("```\n" + code + "```")
+	if len(blocks) != 2 {
+		t.Fatalf("responseBlocks() returned %d blocks; want 2", len(blocks))
+	}
+	for _, text := range blockTexts(blocks) {
+		if len(text) > sectionTextLimit || !strings.HasPrefix(text, "```line") || !strings.HasSuffix(text, "code```") {
+			t.Errorf("code section %q... isn't a fenced chunk within Slack's limit", text[:20])
+		}
+	}
+}
+
+func TestToolOutputBlocks(t *testing.T) {
+	output := "one\ntwo\nthree\nfour\nfive"
+
+	collapsed := toolOutputBlocks("🔧 Bash finished", output, false)
+	texts := blockTexts(collapsed)
+	if texts[1] != "```one\ntwo\nthree\n…```" {
+		t.Errorf("collapsed output = %q", texts[1])
+	}
+	button := collapsed[len(collapsed)-1].(*slack.ActionBlock).Elements.ElementSet[0].(*slack.ButtonBlockElement)
+	if button.ActionID != expandToolOutputActionID || button.Value != "🔧 Bash finished\n"+output {
+		t.Errorf("expand button = %q %q", button.ActionID, button.Value)
+	}
+
+	expanded := toolOutputBlocks("🔧 Bash finished", output, true)
+	if texts := blockTexts(expanded); texts[1] != "```"+output+"```" {
+		t.Errorf("expanded output = %q", texts[1])
+	}
+
+	// Short output has nothing to expand
+	short := toolOutputBlocks("🔧 Read finished", "package main", false)
+	if _, ok := short[len(short)-1].(*slack.ActionBlock); ok {
+		t.Error("short tool output has an expand button")
+	}
+}
+
+func TestToolOutputBlocksClipsValue(t *testing.T) {
+	blocks := toolOutputBlocks("🔧 Bash finished", strings.Repeat("é\n", 2000), true)
+	button := blocks[len(blocks)-1].(*slack.ActionBlock).Elements.ElementSet[0].(*slack.ButtonBlockElement)
+	if len(button.Value) > buttonValueLimit {
+		t.Errorf("button value is %d bytes; want at most %d", len(button.Value), buttonValueLimit)
+	}
+	if !strings.HasPrefix(button.Value, "🔧 Bash finished\né") {
+		t.Errorf("button value = %q...", button.Value[:30])
+	}
+}
+
+func TestSessionControlBlocks(t *testing.T) {
+	actionIDs := func(blocks []slack.Block) []string {
+		var ids []string
+		for _, element := range blocks[1].(*slack.ActionBlock).Elements.ElementSet {
+			button := element.(*slack.ButtonBlockElement)
+			if button.Value != "123.456" {
+				t.Errorf("button %s has value %q", button.ActionID, button.Value)
+			}
+			ids = append(ids, button.ActionID)
+		}
+		return ids
+	}
+
+	if ids := actionIDs(sessionControlBlocks("123.456", false)); strings.Join(ids, ",") != "claude_turn_continue,claude_session_stop" {
+		t.Errorf("controls without a worklet = %v", ids)
+	}
+	if ids := actionIDs(sessionControlBlocks("123.456", true)); strings.Join(ids, ",") != "claude_turn_continue,claude_session_stop,claude_create_pr" {
+		t.Errorf("controls with a worklet = %v", ids)
+	}
+}
@@ -0,0 +1,285 @@
+package slackbot
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// channelConfigKeys are the settings /flow config can change, in the order they're shown
+var channelConfigKeys = []string{"working_dir", "allowed_tools", "model", "session_timeout", "worklets", "shared_workspace", "issue_repo"}
+
+// ChannelConfigStore keeps per-channel overrides of the bot's settings
+type ChannelConfigStore struct {
+	db *gorm.DB
+}
+
+func NewChannelConfigStore(db *gorm.DB) *ChannelConfigStore {
+	return &ChannelConfigStore{db: db}
+}
+
+// Get returns a channel's overrides, or nil when it has none
+func (s *ChannelConfigStore) Get(channelID string) (*models.SlackChannelConfig, error) {
+	var cfg models.SlackChannelConfig
+	err := s.db.Where("channel_id = ?", channelID).First(&cfg).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// Set changes one of a channel's settings. An empty value removes the override.
+func (s *ChannelConfigStore) Set(channelID, key, value, userID string) error {
+	cfg, err := s.Get(channelID)
+	if err != nil {
+		return err
+	}
+	created := cfg == nil
+	if created {
+		cfg = &models.SlackChannelConfig{
+			Model:     models.Model{ID: uuid.NewString()},
+			ChannelID: channelID,
+		}
+	}
+	if err := applyChannelSetting(cfg, key, value); err != nil {
+		return err
+	}
+	cfg.UpdatedBy = userID
+
+	if created {
+		err = s.db.Create(cfg).Error
+	} else {
+		err = s.db.Save(cfg).Error
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save channel config: %w", err)
+	}
+	return nil
+}
+
+// Timeouts returns the session timeout of each channel that overrides it
+func (s *ChannelConfigStore) Timeouts() (map[string]time.Duration, error) {
+	var configs []models.SlackChannelConfig
+	if err := s.db.Where("session_timeout > 0").Find(&configs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get channel timeouts: %w", err)
+	}
+	timeouts := make(map[string]time.Duration, len(configs))
+	for _, cfg := range configs {
+		timeouts[cfg.ChannelID] = cfg.SessionTimeout
+	}
+	return timeouts, nil
+}
+
+// applyChannelSetting parses value into the setting named key
+func applyChannelSetting(cfg *models.SlackChannelConfig, key, value string) error {
+	value = strings.TrimSpace(value)
+	switch key {
+	case "working_dir":
+		if value != "" {
+			if info, err := os.Stat(value); err != nil || !info.IsDir() {
+				return fmt.Errorf("%s isn't a directory", value)
+			}
+		}
+		cfg.WorkingDir = value
+	case "allowed_tools":
+		cfg.AllowedTools.Data = parseCommaList(value)
+	case "model":
+		cfg.ClaudeModel = value
+	case "session_timeout":
+		if value == "" {
+			cfg.SessionTimeout = 0
+			return nil
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("%q isn't a duration such as 45m or 2h", value)
+		}
+		cfg.SessionTimeout = timeout
+	case "worklets":
+		if value == "" {
+			cfg.WorkletsAllowed = nil
+			return nil
+		}
+		allowed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q isn't true or false", value)
+		}
+		cfg.WorkletsAllowed = &allowed
+	case "shared_workspace":
+		if value == "" {
+			cfg.SharedWorkspace = false
+			return nil
+		}
+		shared, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q isn't true or false", value)
+		}
+		cfg.SharedWorkspace = shared
+	case "issue_repo":
+		if value != "" {
+			repo, ok := parseGitHubRepo(value)
+			if !ok {
+				return fmt.Errorf("%q isn't a GitHub repository such as owner/name", value)
+			}
+			value = repo
+		}
+		cfg.IssueRepo = value
+	default:
+		return fmt.Errorf("unknown setting %q; settings are %s", key, strings.Join(channelConfigKeys, ", "))
+	}
+	return nil
+}
+
+// parseCommaList splits a comma-separated list, dropping empty entries
+func parseCommaList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// channelSettings are the settings in effect for a channel
+type channelSettings struct {
+	WorkingDir      string
+	AllowedTools    []string
+	Model           string
+	SessionTimeout  time.Duration
+	WorkletsAllowed bool
+	SharedWorkspace bool   // Sessions work in the channel's workspace directory instead of their own
+	IssueRepo       string // GitHub repository /flow issue files issues in, as owner/name
+}
+
+// resolveChannelSettings applies a channel's overrides, which may be nil, to the bot's defaults
+func resolveChannelSettings(defaults *config.SlackBotConfig, override *models.SlackChannelConfig) channelSettings {
+	settings := channelSettings{
+		SessionTimeout:  defaults.SessionTimeout,
+		WorkletsAllowed: true,
+		IssueRepo:       defaults.IssueRepo,
+	}
+	if override == nil {
+		return settings
+	}
+	settings.WorkingDir = override.WorkingDir
+	settings.AllowedTools = override.AllowedTools.Data
+	settings.Model = override.ClaudeModel
+	if override.SessionTimeout > 0 {
+		settings.SessionTimeout = override.SessionTimeout
+	}
+	if override.WorkletsAllowed != nil {
+		settings.WorkletsAllowed = *override.WorkletsAllowed
+	}
+	settings.SharedWorkspace = override.SharedWorkspace
+	if override.IssueRepo != "" {
+		settings.IssueRepo = override.IssueRepo
+	}
+	return settings
+}
+
+// channelWorkspaceDir is the working directory shared by the sessions of a
+// channel with shared_workspace set. It outlives the sessions.
+func channelWorkspaceDir(channelID string) string {
+	path := filepath.Join("./data", "channel-workspaces", channelID)
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// channelSettings returns the settings in effect for a channel. If its
+// overrides can't be loaded, the defaults are used.
+func (b *SlackBot) channelSettings(channelID string) channelSettings {
+	var override *models.SlackChannelConfig
+	if b.channelConfig != nil {
+		var err error
+		if override, err = b.channelConfig.Get(channelID); err != nil {
+			slog.Error("Failed to load channel config", "channel_id", channelID, "error", err)
+		}
+	}
+	return resolveChannelSettings(b.config, override)
+}
+
+// flowConfigReply runs /flow config in a channel and returns the reply
+func (b *SlackBot) flowConfigReply(userID, channelID string, args []string) string {
+	if len(args) == 0 {
+		return b.describeChannelSettings(channelID)
+	}
+	action := strings.ToLower(args[0])
+	if (action != "set" || len(args) < 3) && (action != "unset" || len(args) != 2) {
+		return "Usage: `/flow config`, `/flow config set <setting> <value>`, or `/flow config unset <setting>`. Settings are " +
+			strings.Join(channelConfigKeys, ", ") + "."
+	}
+	if b.userRole(userID) != roleAdmin {
+		return "Only Slack bot admins can change channel settings."
+	}
+	if b.channelConfig == nil {
+		return "❌ Channel settings aren't available."
+	}
+
+	key, value := strings.ToLower(args[1]), ""
+	if action == "set" {
+		value = strings.Join(args[2:], " ")
+	}
+	if err := b.channelConfig.Set(channelID, key, value, userID); err != nil {
+		slog.Error("Failed to change channel config",
+			"channel_id", channelID,
+			"key", key,
+			"error", err)
+		return fmt.Sprintf("❌ Failed to change %s: %v", key, err)
+	}
+	slog.Info("Changed channel config",
+		"channel_id", channelID,
+		"user_id", userID,
+		"key", key,
+		"value", value,
+		"action", "channel_config_changed",
+	)
+	if value == "" {
+		return fmt.Sprintf("✅ Removed this channel's %s setting. New sessions use the default.", key)
+	}
+	return fmt.Sprintf("✅ Set this channel's %s to `%s`. New sessions use it.", key, value)
+}
+
+// describeChannelSettings lists the settings in effect for a channel
+func (b *SlackBot) describeChannelSettings(channelID string) string {
+	settings := b.channelSettings(channelID)
+	orDefault := func(s string) string {
+		if s == "" {
+			return "_default_"
+		}
+		return "`" + s + "`"
+	}
+
+	var reply strings.Builder
+	fmt.Fprintf(&reply, "*Settings for <#%s>*\n", channelID)
+	fmt.Fprintf(&reply, "• working_dir: %s\n", orDefault(settings.WorkingDir))
+	fmt.Fprintf(&reply, "• allowed_tools: %s\n", orDefault(strings.Join(settings.AllowedTools, ",")))
+	fmt.Fprintf(&reply, "• model: %s\n", orDefault(settings.Model))
+	fmt.Fprintf(&reply, "• session_timeout: `%s`\n", settings.SessionTimeout)
+	fmt.Fprintf(&reply, "• worklets: `%t`\n", settings.WorkletsAllowed)
+	fmt.Fprintf(&reply, "• shared_workspace: `%t`\n", settings.SharedWorkspace)
+	fmt.Fprintf(&reply, "• issue_repo: %s\n", orDefault(settings.IssueRepo))
+	if settings.SharedWorkspace {
+		fmt.Fprintf(&reply, "_Sessions here build on the files in `%s`._\n", channelWorkspaceDir(channelID))
+	}
+	if b.readOnlyChannels != nil && b.readOnlyChannels.IsAllowed(channelID) {
+		reply.WriteString("_This channel is read-only, so its sessions can only use read-only tools._\n")
+	}
+	reply.WriteString("Admins can change these with `/flow config set <setting> <value>`.")
+	return reply.String()
+}
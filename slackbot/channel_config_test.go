@@ -0,0 +1,83 @@
+package slackbot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/models"
+)
+
+func TestApplyChannelSetting(t *testing.T) {
+	cfg := &models.SlackChannelConfig{}
+	dir := t.TempDir()
+
+	for key, value := range map[string]string{
+		"working_dir":      dir,
+		"allowed_tools":    "Read, Grep,,Bash",
+		"model":            "opus",
+		"session_timeout":  "2h",
+		"worklets":         "false",
+		"shared_workspace": "true",
+		"issue_repo":       "https://github.com/breadchris/flow.git",
+	} {
+		if err := applyChannelSetting(cfg, key, value); err != nil {
+			t.Fatalf("applyChannelSetting(%s, %q) = %v", key, value, err)
+		}
+	}
+	if cfg.WorkingDir != dir || strings.Join(cfg.AllowedTools.Data, ",") != "Read,Grep,Bash" ||
+		cfg.ClaudeModel != "opus" || cfg.SessionTimeout != 2*time.Hour ||
+		cfg.WorkletsAllowed == nil || *cfg.WorkletsAllowed || !cfg.SharedWorkspace || cfg.IssueRepo != "breadchris/flow" {
+		t.Errorf("settings = %+v", cfg)
+	}
+
+	// An empty value removes the override
+	for _, key := range channelConfigKeys {
+		if err := applyChannelSetting(cfg, key, ""); err != nil {
+			t.Fatalf("unsetting %s: %v", key, err)
+		}
+	}
+	if cfg.WorkingDir != "" || len(cfg.AllowedTools.Data) != 0 || cfg.ClaudeModel != "" ||
+		cfg.SessionTimeout != 0 || cfg.WorkletsAllowed != nil || cfg.SharedWorkspace || cfg.IssueRepo != "" {
+		t.Errorf("unset settings = %+v", cfg)
+	}
+}
+
+func TestApplyChannelSettingRejectsBadValues(t *testing.T) {
+	for key, value := range map[string]string{
+		"working_dir":      "/does/not/exist",
+		"session_timeout":  "-5m",
+		"worklets":         "sometimes",
+		"shared_workspace": "maybe",
+		"issue_repo":       "not a repo",
+		"color":            "blue",
+	} {
+		if err := applyChannelSetting(&models.SlackChannelConfig{}, key, value); err == nil {
+			t.Errorf("applyChannelSetting(%s, %q) succeeded", key, value)
+		}
+	}
+}
+
+func TestResolveChannelSettings(t *testing.T) {
+	defaults := &config.SlackBotConfig{SessionTimeout: 30 * time.Minute, IssueRepo: "acme/issues"}
+
+	settings := resolveChannelSettings(defaults, nil)
+	if settings.SessionTimeout != 30*time.Minute || !settings.WorkletsAllowed || settings.Model != "" || settings.IssueRepo != "acme/issues" {
+		t.Errorf("settings without overrides = %+v", settings)
+	}
+
+	allowed := false
+	settings = resolveChannelSettings(defaults, &models.SlackChannelConfig{
+		AllowedTools:    models.JSONField[[]string]{Data: []string{"Read"}},
+		ClaudeModel:     "sonnet",
+		WorkletsAllowed: &allowed,
+		SharedWorkspace: true,
+		IssueRepo:       "acme/api",
+	})
+	if settings.SessionTimeout != 30*time.Minute || settings.WorkletsAllowed || settings.Model != "sonnet" || !settings.SharedWorkspace ||
+		settings.IssueRepo != "acme/api" ||
+		strings.Join(settings.AllowedTools, ",") != "Read" {
+		t.Errorf("settings with overrides = %+v", settings)
+	}
+}
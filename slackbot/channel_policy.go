@@ -0,0 +1,117 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// conversationsInfoURL is Slack's conversations.info method. It's called
+// directly because the client library leaves out the workspaces a channel
+// belongs to.
+const conversationsInfoURL = "https://slack.com/api/conversations.info"
+
+// slackChannelMention matches a channel mention such as <#C0123ABC|general>
+var slackChannelMention = regexp.MustCompile(`^<#([A-Z0-9]+)(?:\|[^>]*)?>$`)
+
+// parseSlackChannelID reads the channel ID of a channel mention
+func parseSlackChannelID(s string) (string, bool) {
+	match := slackChannelMention.FindStringSubmatch(s)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// lookupChannel resolves a channel's name and workspaces for name: and team:
+// whitelist patterns. A channel shared across an Enterprise Grid org belongs
+// to the workspace it was created in and each one it's shared with.
+func (b *SlackBot) lookupChannel(channelID string) (*ChannelInfo, error) {
+	req, err := http.NewRequest("GET", conversationsInfoURL+"?channel="+url.QueryEscape(channelID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create channel info request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+b.config.BotToken)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		OK      bool   `json:"ok"`
+		Error   string `json:"error"`
+		Channel struct {
+			Name          string   `json:"name"`
+			ContextTeamID string   `json:"context_team_id"`
+			SharedTeamIDs []string `json:"shared_team_ids"`
+		} `json:"channel"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode channel info: %w", err)
+	}
+	if !body.OK {
+		return nil, fmt.Errorf("failed to get channel info: %s", body.Error)
+	}
+
+	info := &ChannelInfo{Name: body.Channel.Name}
+	if body.Channel.ContextTeamID != "" {
+		info.TeamIDs = append(info.TeamIDs, body.Channel.ContextTeamID)
+	}
+	for _, teamID := range body.Channel.SharedTeamIDs {
+		if teamID != body.Channel.ContextTeamID {
+			info.TeamIDs = append(info.TeamIDs, teamID)
+		}
+	}
+	return info, nil
+}
+
+// describeChannelPolicy runs /flow admin channels, showing the whitelist and
+// deny list and what they decide for a channel, the current one by default
+func (b *SlackBot) describeChannelPolicy(channelID string, args []string) string {
+	if len(args) > 0 {
+		target, ok := parseSlackChannelID(args[0])
+		if !ok {
+			return fmt.Sprintf("%q isn't a Slack channel. Usage: `/flow admin channels [#channel]`.", args[0])
+		}
+		channelID = target
+	}
+
+	var reply strings.Builder
+	reply.WriteString("*Channel policy*\n")
+	if b.channelWhitelist.HasWhitelist() {
+		fmt.Fprintf(&reply, "Whitelist: %s\n", formatChannelPatterns(b.channelWhitelist.GetPatterns()))
+	} else {
+		reply.WriteString("Whitelist: none, so every channel not denied is allowed\n")
+	}
+	if deny := b.channelWhitelist.GetDenyPatterns(); len(deny) > 0 {
+		fmt.Fprintf(&reply, "Deny list: %s\n", formatChannelPatterns(deny))
+	} else {
+		reply.WriteString("Deny list: none\n")
+	}
+
+	decision := b.channelWhitelist.Check(channelID)
+	verdict := "🚫 denied"
+	if decision.Allowed {
+		verdict = "✅ allowed"
+	}
+	fmt.Fprintf(&reply, "<#%s> is %s: %s.", channelID, verdict, decision.Reason)
+	if info := decision.Info; info != nil {
+		fmt.Fprintf(&reply, "\nName `%s`", info.Name)
+		if len(info.TeamIDs) > 0 {
+			fmt.Fprintf(&reply, ", workspaces %s", formatChannelPatterns(info.TeamIDs))
+		}
+	}
+	return reply.String()
+}
+
+// formatChannelPatterns lists patterns as code, separated by commas
+func formatChannelPatterns(patterns []string) string {
+	return "`" + strings.Join(patterns, "`, `") + "`"
+}
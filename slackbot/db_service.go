@@ -60,6 +60,23 @@ func (s *SessionDBService) GetSession(threadTS string) (*SlackClaudeSession, err
 	return session, nil
 }
 
+// GetEndedSession returns the session a thread had before it was cleaned up
+// or removed, or nil when the thread has an active session or none at all
+func (s *SessionDBService) GetEndedSession(threadTS string) (*SlackClaudeSession, error) {
+	var dbSessions []models.SlackSession
+	err := s.db.Where("thread_ts = ? AND active = ? AND session_id <> ''", threadTS, false).
+		Order("last_activity DESC").
+		Limit(1).
+		Find(&dbSessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ended session: %w", err)
+	}
+	if len(dbSessions) == 0 {
+		return nil, nil
+	}
+	return toSlackClaudeSessions(dbSessions)[0], nil
+}
+
 // SetSession stores or updates a session in the database
 func (s *SessionDBService) SetSession(session *SlackClaudeSession) error {
 	// Check if session already exists
@@ -173,22 +190,39 @@ func (s *SessionDBService) RemoveSession(threadTS string) error {
 	return nil
 }
 
-// CleanupInactiveSessions removes sessions that have been inactive for longer than the specified timeout
-func (s *SessionDBService) CleanupInactiveSessions(timeout time.Duration) error {
-	cutoff := time.Now().Add(-timeout)
-	
-	result := s.db.Model(&models.SlackSession{}).
-		Where("last_activity < ? AND active = ?", cutoff, true).
-		Update("active", false)
-	
+// CleanupInactiveSessions removes sessions that have been inactive for longer than the specified timeout.
+// Sessions in the channels of channelTimeouts use their channel's timeout instead.
+func (s *SessionDBService) CleanupInactiveSessions(timeout time.Duration, channelTimeouts map[string]time.Duration) error {
+	query := s.db.Model(&models.SlackSession{}).
+		Where("last_activity < ? AND active = ?", time.Now().Add(-timeout), true)
+	if len(channelTimeouts) > 0 {
+		channels := make([]string, 0, len(channelTimeouts))
+		for channelID := range channelTimeouts {
+			channels = append(channels, channelID)
+		}
+		query = query.Where("channel_id NOT IN ?", channels)
+	}
+	result := query.Update("active", false)
 	if result.Error != nil {
 		return fmt.Errorf("failed to cleanup inactive sessions: %w", result.Error)
 	}
+	cleaned := result.RowsAffected
+
+	for channelID, channelTimeout := range channelTimeouts {
+		result := s.db.Model(&models.SlackSession{}).
+			Where("channel_id = ? AND last_activity < ? AND active = ?", channelID, time.Now().Add(-channelTimeout), true).
+			Update("active", false)
+		if result.Error != nil {
+			return fmt.Errorf("failed to cleanup inactive sessions in channel %s: %w", channelID, result.Error)
+		}
+		cleaned += result.RowsAffected
+	}
 
-	if result.RowsAffected > 0 {
+	if cleaned > 0 {
 		slog.Info("Cleaned up inactive sessions",
-			"count", result.RowsAffected,
-			"timeout", timeout)
+			"count", cleaned,
+			"timeout", timeout,
+			"channel_timeouts", len(channelTimeouts))
 	}
 
 	return nil
@@ -202,6 +236,38 @@ func (s *SessionDBService) GetAllActiveSessions() ([]*SlackClaudeSession, error)
 		return nil, fmt.Errorf("failed to get active sessions: %w", err)
 	}
 
+	return toSlackClaudeSessions(dbSessions), nil
+}
+
+// GetUserActiveSessions returns a user's most recently active sessions
+func (s *SessionDBService) GetUserActiveSessions(userID string, limit int) ([]*SlackClaudeSession, error) {
+	var dbSessions []models.SlackSession
+	err := s.db.Where("user_id = ? AND active = ?", userID, true).
+		Order("last_activity DESC").
+		Limit(limit).
+		Find(&dbSessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user sessions: %w", err)
+	}
+	return toSlackClaudeSessions(dbSessions), nil
+}
+
+// ChannelSessionIDs returns the IDs of the sessions started in a channel that
+// were active since the given time
+func (s *SessionDBService) ChannelSessionIDs(channelID string, since time.Time) ([]string, error) {
+	sessionIDs := []string{}
+	err := s.db.Model(&models.SlackSession{}).
+		Where("channel_id = ? AND last_activity >= ? AND session_id <> ''", channelID, since).
+		Distinct().
+		Pluck("session_id", &sessionIDs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel sessions: %w", err)
+	}
+	return sessionIDs, nil
+}
+
+// toSlackClaudeSessions converts database sessions to in-memory ones without processes
+func toSlackClaudeSessions(dbSessions []models.SlackSession) []*SlackClaudeSession {
 	sessions := make([]*SlackClaudeSession, len(dbSessions))
 	for i, dbSession := range dbSessions {
 		sessions[i] = &SlackClaudeSession{
@@ -218,8 +284,7 @@ func (s *SessionDBService) GetAllActiveSessions() ([]*SlackClaudeSession, error)
 			SessionInfo:  nil,
 		}
 	}
-
-	return sessions, nil
+	return sessions
 }
 
 // ContextDBService handles database operations for thread contexts
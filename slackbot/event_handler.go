@@ -165,7 +165,7 @@ func (h *AppMentionHandler) HandleEvent(ctx context.Context, evt socketmode.Even
 	}
 
 	// Route to existing app mention handling logic
-	h.Bot.handleAppMentionEvent(mentionEvent)
+	h.Bot.handleAppMentionEvent(mentionEvent, mentionFiles(eventsAPIEvent))
 	return nil
 }
 
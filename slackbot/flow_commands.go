@@ -0,0 +1,310 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/breadchris/flow/models"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// flowSubcommand is a /flow subcommand and its arguments
+type flowSubcommand struct {
+	name string
+	args []string
+	rest string // Text after the subcommand's name, as typed
+}
+
+// flowSubcommandArgs is the least and most arguments each /flow subcommand
+// takes; -1 means no limit
+var flowSubcommandArgs = map[string][2]int{
+	"new":      {1, -1},
+	"status":   {0, 0},
+	"stop":     {0, 1},
+	"resume":   {1, 1},
+	"sessions": {0, 0},
+	"help":     {0, 0},
+	"continue": {0, 0},
+	"export":   {0, 1},
+	"config":   {0, -1},
+	"admin":    {1, -1},
+	"schedule": {1, -1},
+	"usage":    {0, 2},
+	"audit":    {0, 3},
+	"issue":    {0, 0},
+	"template": {1, -1},
+	"sh":       {1, -1},
+}
+
+// flowSubcommandActions are the words that can start the arguments of
+// subcommands that take an action, so prompts that merely begin with the
+// subcommand's name, like "config files for nginx", stay prompts. A
+// schedule's arguments may also start with its quoted cron expression, and
+// an audit's with a user.
+var flowSubcommandActions = map[string][]string{
+	"config":   {"set", "unset"},
+	"admin":    {"roles", "grant", "revoke", "channels"},
+	"schedule": {"list", "delete"},
+	"usage":    {"me", "channel", "team", "7d", "30d"},
+	"audit":    append([]string{"7d", "30d"}, auditActions...),
+}
+
+// flowSessionsLimit is how many sessions /flow sessions lists
+const flowSessionsLimit = 10
+
+const flowHelpText = "*/flow commands*\n" +
+	"• `/flow <prompt>` starts a Claude session. Include a GitHub URL to start a worklet for that repository instead.\n" +
+	"• `/flow new <prompt>` starts a session even when the prompt begins with a command's name.\n" +
+	"• `/flow sessions` lists your recent sessions.\n" +
+	"• `/flow status` shows your running sessions and worklets.\n" +
+	"• `/flow stop` in a session's thread stops Claude's current response. `/flow stop <id>` ends a session.\n" +
+	"• `/flow resume <id>` restarts a stopped or idle session in its thread.\n" +
+	"• `/flow continue` in a session's thread continues a session paused by its budget.\n" +
+	"• `/flow export [json]` in a session's thread uploads its transcript.\n" +
+	"• `/flow issue` in a session's thread has Claude write a GitHub issue from the conversation and files it in the channel's `issue_repo`.\n" +
+	"• `/flow config` shows the channel's settings. Admins change them with `/flow config set <setting> <value>` and `/flow config unset <setting>`.\n" +
+	"• `/flow admin roles` lists users' roles for admins, who change them with `/flow admin grant <@user> <role>` and `/flow admin revoke <@user>`. `/flow admin channels [#channel]` shows which channels the bot works in.\n" +
+	"• `/flow schedule \"<cron>\" <prompt>` runs a prompt in this channel on a schedule, such as `\"0 9 * * 1\"` for Mondays at 9:00. `/flow schedule list` shows the channel's schedules and `/flow schedule delete <id>` removes one.\n" +
+	"• `/flow usage [me|channel|team] [7d|30d]` shows Claude's token use and cost, with a breakdown by user and the most expensive sessions. The team's usage is for admins.\n" +
+	"• `/flow audit [<@user>] [action] [7d|30d]` shows admins what the bot did in this channel: commands, sessions started and ended, tool approvals, pull requests, and worklet deploys.\n" +
+	"• `/flow template <name> <prompt>` starts a worklet from a team template: its repository, branch, and environment, with your prompt after its base prompt. `/flow template list` shows the templates.\n" +
+	"• `/flow sh <worklet> <command>` runs a command in a worklet's container and shows admins its output. The commands and their output are recorded.\n" +
+	"• `/flow help` shows this list."
+
+// parseFlowSubcommand recognizes a /flow subcommand. Text whose first word
+// isn't a subcommand, or that has the wrong number of arguments for it, is a
+// prompt for Claude.
+func parseFlowSubcommand(text string) (flowSubcommand, bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return flowSubcommand{}, false
+	}
+	name := strings.ToLower(fields[0])
+	limits, ok := flowSubcommandArgs[name]
+	args := fields[1:]
+	if !ok || len(args) < limits[0] || (limits[1] >= 0 && len(args) > limits[1]) {
+		return flowSubcommand{}, false
+	}
+	if actions, ok := flowSubcommandActions[name]; ok && len(args) > 0 && !slices.Contains(actions, strings.ToLower(args[0])) {
+		if (name != "schedule" || !startsWithQuote(args[0])) && (name != "audit" || !strings.HasPrefix(args[0], "<@")) {
+			return flowSubcommand{}, false
+		}
+	}
+	rest := strings.TrimSpace(strings.TrimSpace(text)[len(fields[0]):])
+	return flowSubcommand{name: name, args: args, rest: rest}, true
+}
+
+// flowSubcommandReply runs a subcommand typed in a channel, outside a session's
+// thread, and returns the reply. "new" isn't handled here; it starts a session
+// like a prompt.
+func (b *SlackBot) flowSubcommandReply(userID, channelID string, sub flowSubcommand) string {
+	switch sub.name {
+	case "config":
+		return b.flowConfigReply(userID, channelID, sub.args)
+	case "admin":
+		return b.flowAdminReply(userID, channelID, sub.args)
+	case "schedule":
+		return b.flowScheduleReply(userID, channelID, sub)
+	case "usage":
+		return b.flowUsageReply(userID, channelID, sub.args)
+	case "audit":
+		return b.flowAuditReply(userID, channelID, sub.args)
+	case "status":
+		return b.flowStatus(userID)
+	case "sessions":
+		return b.listFlowSessions(userID)
+	case "stop":
+		if len(sub.args) == 0 {
+			return "Reply `/flow stop` in a Claude session's thread to stop its current response, or use `/flow stop <id>` to end a session."
+		}
+		return b.stopSessionByID(userID, sub.args[0])
+	case "resume":
+		return b.resumeSessionByID(userID, sub.args[0])
+	case "continue":
+		return "Reply `/flow continue` in a Claude session's thread to continue it after it reaches its budget."
+	case "export":
+		return "Reply `/flow export` in a Claude session's thread to download its transcript, or `/flow export json` for JSON."
+	case "issue":
+		return "Reply `/flow issue` in a Claude session's thread to file a GitHub issue from its conversation."
+	case "template":
+		if strings.EqualFold(sub.args[0], "list") {
+			return b.listWorkletTemplates()
+		}
+		return "Start a worklet from a template with `/flow template <name> <prompt>` in a channel."
+	case "sh":
+		return b.flowShellReply(userID, sub.args)
+	}
+	return flowHelpText
+}
+
+// ackEphemeral answers a slash command with a reply only its sender sees
+func (b *SlackBot) ackEphemeral(evt *socketmode.Event, text string) {
+	response := map[string]interface{}{
+		"response_type": "ephemeral",
+		"text":          text,
+	}
+	payload, _ := json.Marshal(response)
+	b.socketMode.Ack(*evt.Request, payload)
+}
+
+// flowSessionState says whether a session is running, idle with its process
+// shut down, or stopped
+func (b *SlackBot) flowSessionState(session models.ClaudeSession) string {
+	if _, running := b.claudeService.GetProcess(session.SessionID); running {
+		return "running"
+	}
+	if session.Metadata != nil {
+		if active, _ := session.Metadata.Data["active"].(bool); active {
+			return "idle"
+		}
+	}
+	return "stopped"
+}
+
+// sessionThread returns the Slack channel and thread a session was started in
+func sessionThread(session *models.ClaudeSession) (channelID, threadTS string) {
+	if session.Metadata == nil {
+		return "", ""
+	}
+	channelID, _ = session.Metadata.Data["channel_id"].(string)
+	threadTS, _ = session.Metadata.Data["thread_ts"].(string)
+	return channelID, threadTS
+}
+
+// listFlowSessions describes a user's most recently used sessions
+func (b *SlackBot) listFlowSessions(userID string) string {
+	sessions, err := b.claudeService.GetSessions(userID)
+	if err != nil {
+		slog.Error("Failed to list Claude sessions", "user_id", userID, "error", err)
+		return "❌ Failed to list your sessions. Please try again."
+	}
+	if len(sessions) == 0 {
+		return "You don't have any Claude sessions yet. Start one with `/flow <prompt>`."
+	}
+	slices.SortFunc(sessions, func(a, b models.ClaudeSession) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+
+	var reply strings.Builder
+	fmt.Fprintf(&reply, "*Your Claude sessions* (%d)\n", len(sessions))
+	for _, session := range sessions[:min(len(sessions), flowSessionsLimit)] {
+		fmt.Fprintf(&reply, "• `%s` %s", session.SessionID, b.flowSessionState(session))
+		if channelID, _ := sessionThread(&session); channelID != "" {
+			fmt.Fprintf(&reply, " in <#%s>", channelID)
+		}
+		fmt.Fprintf(&reply, ", last used %s", session.UpdatedAt.Format(time.DateTime))
+		if session.Title != "" {
+			fmt.Fprintf(&reply, ": %s", session.Title)
+		}
+		reply.WriteString("\n")
+	}
+	if len(sessions) > flowSessionsLimit {
+		fmt.Fprintf(&reply, "_%d older sessions not shown._\n", len(sessions)-flowSessionsLimit)
+	}
+	reply.WriteString("Resume one with `/flow resume <id>` or end it with `/flow stop <id>`.")
+	return reply.String()
+}
+
+// flowStatus summarizes a user's sessions and worklets
+func (b *SlackBot) flowStatus(userID string) string {
+	sessions, err := b.claudeService.GetSessions(userID)
+	if err != nil {
+		slog.Error("Failed to list Claude sessions", "user_id", userID, "error", err)
+		return "❌ Failed to get your status. Please try again."
+	}
+	counts := map[string]int{}
+	for _, session := range sessions {
+		counts[b.flowSessionState(session)]++
+	}
+
+	var reply strings.Builder
+	fmt.Fprintf(&reply, "*Claude sessions:* %d running, %d idle, %d stopped\n",
+		counts["running"], counts["idle"], counts["stopped"])
+
+	if b.workletManager == nil {
+		return reply.String()
+	}
+	worklets, err := b.workletManager.ListWorklets(userID)
+	if err != nil {
+		slog.Error("Failed to list worklets", "user_id", userID, "error", err)
+		return reply.String()
+	}
+	fmt.Fprintf(&reply, "*Worklets:* %d\n", len(worklets))
+	for _, w := range worklets {
+		fmt.Fprintf(&reply, "• %s: %s", w.Name, w.Status)
+		if link := b.workletManager.PreviewLink(w); link != "" {
+			fmt.Fprintf(&reply, " <%s>", link)
+		}
+		reply.WriteString("\n")
+	}
+	return reply.String()
+}
+
+// stopSessionByID ends one of a user's sessions
+func (b *SlackBot) stopSessionByID(userID, sessionID string) string {
+	if _, err := b.claudeService.GetSession(sessionID, userID); err != nil {
+		return fmt.Sprintf("You don't have a session `%s`. See `/flow sessions` for your sessions.", sessionID)
+	}
+	b.claudeService.StopSession(sessionID)
+	channelID, threadTS := "", ""
+	if session, exists := b.sessionByID(sessionID); exists {
+		channelID, threadTS = session.ChannelID, session.ThreadTS
+		b.removeSession(session.ThreadTS)
+	}
+	b.audit(userID, channelID, threadTS, auditSessionEnded, map[string]interface{}{"session_id": sessionID})
+	return fmt.Sprintf("⏹️ Ended session `%s`.", sessionID)
+}
+
+// resumeSessionByID restarts one of a user's sessions in the thread it was
+// started in. The session resumes in the background, and the thread is told
+// when it's ready.
+func (b *SlackBot) resumeSessionByID(userID, sessionID string) string {
+	dbSession, err := b.claudeService.GetSession(sessionID, userID)
+	if err != nil {
+		return fmt.Sprintf("You don't have a session `%s`. See `/flow sessions` for your sessions.", sessionID)
+	}
+	channelID, threadTS := sessionThread(dbSession)
+	if channelID == "" || threadTS == "" {
+		return fmt.Sprintf("Session `%s` wasn't started in Slack, so it can't be resumed here.", sessionID)
+	}
+	if _, running := b.claudeService.GetProcess(sessionID); running {
+		return fmt.Sprintf("Session `%s` is already running in <#%s>.", sessionID, channelID)
+	}
+
+	go b.resumeSessionInThread(userID, channelID, threadTS, sessionID)
+	return fmt.Sprintf("▶️ Resuming session `%s` in its thread in <#%s>...", sessionID, channelID)
+}
+
+// resumeSessionInThread resumes a session and makes it the thread's session
+func (b *SlackBot) resumeSessionInThread(userID, channelID, threadTS, sessionID string) {
+	notice := b.newQueueNotice(channelID, threadTS)
+	process, err := b.claudeService.ResumeSession(sessionID, userID, notice.option())
+	notice.finish(err)
+	if err != nil {
+		slog.Error("Failed to resume Claude session", "session_id", sessionID, "thread_ts", threadTS, "error", err)
+		if _, err := b.postMessage(channelID, threadTS, "❌ Failed to resume this session. Please try again."); err != nil {
+			slog.Error("Failed to post resume failure", "error", err)
+		}
+		return
+	}
+
+	b.setSession(threadTS, &SlackClaudeSession{
+		ThreadTS:     threadTS,
+		ChannelID:    channelID,
+		UserID:       userID,
+		SessionID:    sessionID,
+		ProcessID:    process.GetCorrelationID(),
+		LastActivity: time.Now(),
+		Active:       true,
+		Resumed:      true,
+		Process:      process,
+	})
+	if _, err := b.postMessage(channelID, threadTS,
+		fmt.Sprintf("▶️ _<@%s> resumed this session. Mention me in this thread to continue._", userID)); err != nil {
+		slog.Error("Failed to post resume notice", "error", err)
+	}
+}
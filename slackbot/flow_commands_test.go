@@ -0,0 +1,80 @@
+package slackbot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFlowSubcommand(t *testing.T) {
+	tests := []struct {
+		text string
+		name string
+		args string
+		rest string
+		ok   bool
+	}{
+		{"status", "status", "", "", true},
+		{"Sessions", "sessions", "", "", true},
+		{"stop", "stop", "", "", true},
+		{"stop 0b6c2d5e", "stop", "0b6c2d5e", "0b6c2d5e", true},
+		{"resume 0b6c2d5e", "resume", "0b6c2d5e", "0b6c2d5e", true},
+		{"export json", "export", "json", "json", true},
+		{"new help me write a parser\nin Go", "new", "help,me,write,a,parser,in,Go", "help me write a parser\nin Go", true},
+		{"help", "help", "", "", true},
+		{"config", "config", "", "", true},
+		{"config set allowed_tools Read, Grep", "config", "set,allowed_tools,Read,,Grep", "set allowed_tools Read, Grep", true},
+
+		// Text that only starts like a subcommand is a prompt
+		{"resume", "", "", "", false},
+		{"new", "", "", "", false},
+		{"stop the server from crashing", "", "", "", false},
+		{"help me debug this Go code", "", "", "", false},
+		{"statusbar colors are wrong", "", "", "", false},
+		{"config files for nginx", "", "", "", false},
+		{"admin grant <@U0123ABC> developer", "admin", "grant,<@U0123ABC>,developer", "grant <@U0123ABC> developer", true},
+		{"admin channels <#C0123ABC|general>", "admin", "channels,<#C0123ABC|general>", "channels <#C0123ABC|general>", true},
+		{"admin", "", "", "", false},
+		{"admin panel needs a dark mode", "", "", "", false},
+		{`schedule "0 9 * * 1" Summarize last week's commits`, "schedule", `"0,9,*,*,1",Summarize,last,week's,commits`, `"0 9 * * 1" Summarize last week's commits`, true},
+		{"schedule list", "schedule", "list", "list", true},
+		{"schedule a meeting with the team", "", "", "", false},
+		{"usage", "usage", "", "", true},
+		{"usage channel 7d", "usage", "channel,7d", "channel 7d", true},
+		{"usage of goroutines in the worker pool", "", "", "", false},
+		{"audit", "audit", "", "", true},
+		{"audit <@U0123ABC> tool_approved 30d", "audit", "<@U0123ABC>,tool_approved,30d", "<@U0123ABC> tool_approved 30d", true},
+		{"audit the login handler for XSS", "", "", "", false},
+		{"issue", "issue", "", "", true},
+		{"issue with the login page", "", "", "", false},
+		{"template landing Add a pricing page", "template", "landing,Add,a,pricing,page", "landing Add a pricing page", true},
+		{"template list", "template", "list", "list", true},
+		{"template", "", "", "", false},
+		{"sh w1 ls -la /app", "sh", "w1,ls,-la,/app", "w1 ls -la /app", true},
+		{"sh w1", "sh", "w1", "w1", true},
+		{"sh", "", "", "", false},
+		{"", "", "", "", false},
+	}
+
+	for _, tt := range tests {
+		sub, ok := parseFlowSubcommand(tt.text)
+		if ok != tt.ok || sub.name != tt.name || strings.Join(sub.args, ",") != tt.args || sub.rest != tt.rest {
+			t.Errorf("parseFlowSubcommand(%q) = %q, %q, %q, %v; want %q, %q, %q, %v",
+				tt.text, sub.name, sub.args, sub.rest, ok, tt.name, tt.args, tt.rest, tt.ok)
+		}
+	}
+}
+
+func TestFlowSubcommandReplyOutsideThread(t *testing.T) {
+	bot := &SlackBot{}
+	for _, text := range []string{"stop", "continue", "export"} {
+		sub, _ := parseFlowSubcommand(text)
+		if reply := bot.flowSubcommandReply("U1", "C1", sub); !strings.Contains(reply, "thread") {
+			t.Errorf("/flow %s outside a thread replied %q", text, reply)
+		}
+	}
+
+	sub, _ := parseFlowSubcommand("help")
+	if reply := bot.flowSubcommandReply("U1", "C1", sub); reply != flowHelpText {
+		t.Errorf("/flow help replied %q", reply)
+	}
+}
@@ -3,6 +3,7 @@ package slackbot
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -15,6 +16,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/breadchris/flow/claude"
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/metrics"
 	"github.com/breadchris/flow/models"
 	"github.com/breadchris/flow/worklet"
 	"github.com/slack-go/slack"
@@ -27,6 +31,11 @@ import (
 func (b *SlackBot) handleSlashCommand(evt *socketmode.Event, cmd *slack.SlashCommand) {
 	defer b.socketMode.Ack(*evt.Request)
 
+	b.audit(cmd.UserID, cmd.ChannelID, "", auditCommand, map[string]interface{}{
+		"command": cmd.Command,
+		"text":    cmd.Text,
+	})
+
 	switch cmd.Command {
 	case "/flow":
 		b.handleFlowCommand(evt, cmd)
@@ -46,6 +55,65 @@ func (b *SlackBot) handleSlashCommand(evt *socketmode.Event, cmd *slack.SlashCom
 	}
 }
 
+// handleInteraction processes clicks on buttons in the bot's messages and
+// the "Ask Claude about this" message shortcut
+func (b *SlackBot) handleInteraction(evt *socketmode.Event, callback *slack.InteractionCallback) {
+	b.socketMode.Ack(*evt.Request)
+
+	if callback.Type == slack.InteractionTypeMessageAction && callback.CallbackID == askClaudeCallbackID {
+		if b.isChannelAllowed(callback.Channel.ID) {
+			go b.askClaudeAboutMessage(callback)
+		}
+		return
+	}
+
+	// App Home buttons aren't in a channel
+	if callback.Type == slack.InteractionTypeBlockActions && callback.View.Type == slack.VTHomeTab {
+		for _, action := range callback.ActionCallback.BlockActions {
+			if action.ActionID == homeStopSessionActionID {
+				go b.stopSessionFromHome(callback.User.ID, action.Value)
+			}
+		}
+		return
+	}
+
+	if callback.Type != slack.InteractionTypeBlockActions || !b.isChannelAllowed(callback.Channel.ID) {
+		return
+	}
+	for _, action := range callback.ActionCallback.BlockActions {
+		if problem := b.actionProblem(callback.User.ID, action); problem != "" {
+			b.denyUser(callback.Channel.ID, callback.Message.ThreadTimestamp, callback.User.ID, problem)
+			continue
+		}
+		switch action.ActionID {
+		case continueActionID:
+			go b.continueClaudeSession(callback.Channel.ID, action.Value)
+		case approveActionID, denyActionID:
+			go b.decideToolUse(callback, action)
+		case continueTurnActionID:
+			go b.continueClaudeTurn(callback, action.Value)
+		case stopSessionActionID:
+			go b.stopClaudeSession(callback, action.Value)
+		case createPRActionID:
+			go b.createPullRequestForThread(callback, action.Value)
+		case approvePRActionID, discardPRActionID:
+			go b.decidePullRequest(callback, action)
+		case cancelQueuedTurnActionID:
+			go b.cancelQueuedTurn(callback, action)
+		case expandToolOutputActionID, collapseToolOutputActionID:
+			go b.toggleToolOutput(callback, action)
+		case retryStartActionID:
+			go b.retryStart(callback, action)
+		case debugBundleActionID:
+			// The button only opens its link
+		default:
+			if b.config.Debug {
+				slog.Debug("Unhandled block action", "action_id", action.ActionID)
+			}
+		}
+	}
+}
+
 // handleFlowCommand processes /flow slash commands
 func (b *SlackBot) handleFlowCommand(evt *socketmode.Event, cmd *slack.SlashCommand) {
 	if b.config.Debug {
@@ -69,21 +137,78 @@ func (b *SlackBot) handleFlowCommand(evt *socketmode.Event, cmd *slack.SlashComm
 	// Validate that we have content to work with
 	content := strings.TrimSpace(cmd.Text)
 	if content == "" {
-		response := map[string]interface{}{
-			"response_type": "ephemeral",
-			"text":          "Please provide a prompt for Claude.\nExamples:\n• `/flow Help me debug this Go code`\n• `/flow https://github.com/user/repo.git Add dark mode support`",
-		}
-		payload, _ := json.Marshal(response)
-		b.socketMode.Ack(*evt.Request, payload)
+		b.ackEphemeral(evt, "Please provide a prompt for Claude.\nExamples:\n• `/flow Help me debug this Go code`\n• `/flow https://github.com/user/repo.git Add dark mode support`\n\nSee `/flow help` for the other commands.")
 		return
 	}
 
+	role := b.userRole(cmd.UserID)
+	if role == roleNone {
+		b.ackEphemeral(evt, notAuthorizedMessage)
+		return
+	}
+
+	// Subcommands are answered privately; /flow new <prompt> starts a session like any other
+	// prompt, and /flow template <name> <prompt> starts a worklet from a template
+	var template *worklet.WorkletTemplate
+	var templatePrompt string
+	if sub, ok := parseFlowSubcommand(content); ok {
+		switch {
+		case sub.name == "new":
+			content = sub.rest
+		case sub.name == "template" && !strings.EqualFold(sub.args[0], "list"):
+			var problem string
+			if template, problem = b.flowTemplate(sub.args[0]); problem != "" {
+				b.ackEphemeral(evt, problem)
+				return
+			}
+			templatePrompt = strings.TrimSpace(sub.rest[len(sub.args[0]):])
+		case sub.name == "sh" && len(sub.args) > 1 && role == roleAdmin:
+			command := strings.TrimSpace(sub.rest[len(sub.args[0]):])
+			b.ackEphemeral(evt, fmt.Sprintf("Running `%s` in worklet `%s`...", command, sub.args[0]))
+			go b.runWorkletShellCommand(cmd.UserID, cmd.ChannelID, sub.args[0], command)
+			return
+		default:
+			b.ackEphemeral(evt, b.flowSubcommandReply(cmd.UserID, cmd.ChannelID, sub))
+			return
+		}
+	}
+
+	// A leading --persona picks the system prompt for the new Claude session
+	request := content
+	var sessionOpts []claude.SessionOption
+	if persona, rest, ok := parsePersonaFlag(content); ok {
+		opt, problem := b.personaOption(persona, rest)
+		if repoURL, _ := b.parseFlowCommand(rest); problem == "" && repoURL != "" {
+			problem = "Personas apply to Claude sessions, not repository worklets."
+		}
+		if problem != "" {
+			b.ackEphemeral(evt, problem)
+			return
+		}
+		request = rest
+		sessionOpts = append(sessionOpts, opt)
+	}
+
 	// Parse the command to check for repository URL
-	repoURL, prompt := b.parseFlowCommand(content)
+	repoURL, prompt := b.parseFlowCommand(request)
+	if template != nil {
+		repoURL, prompt = template.GitRepo, templatePrompt
+	}
+	if repoURL != "" && !b.channelSettings(cmd.ChannelID).WorkletsAllowed {
+		b.ackEphemeral(evt, "Worklets aren't allowed in this channel. Start a Claude session without a repository URL instead.")
+		return
+	}
+	if repoURL != "" && !role.atLeast(roleDeveloper) {
+		b.ackEphemeral(evt, "🔒 Only developers can start worklets. Start a Claude session without a repository URL instead.")
+		return
+	}
+	sessionOpts = append(sessionOpts, role.sessionOptions()...)
 
 	// Send immediate response to acknowledge the command
 	var responseText string
-	if repoURL != "" {
+	if template != nil {
+		responseText = fmt.Sprintf("🚀 Creating worklet from the `%s` template...", template.Name)
+	} else if repoURL != "" {
 		responseText = "🚀 Creating worklet for repository..."
 	} else {
 		responseText = "🤖 Starting Claude session..."
@@ -116,12 +241,14 @@ func (b *SlackBot) handleFlowCommand(evt *socketmode.Event, cmd *slack.SlashComm
 			return
 		}
 
-		if repoURL != "" {
+		if template != nil {
+			b.handleTemplateWorkflow(cmd.UserID, cmd.ChannelID, threadTS, template.Name, prompt)
+		} else if repoURL != "" {
 			// Repository workflow - create worklet
 			b.handleRepositoryWorkflow(cmd.UserID, cmd.ChannelID, threadTS, repoURL, prompt)
 		} else {
 			// Simple prompt workflow - direct Claude session
-			b.handleSimpleWorkflow(cmd.UserID, cmd.ChannelID, threadTS, content)
+			b.handleSimpleWorkflow(cmd.UserID, cmd.ChannelID, threadTS, request, sessionOpts...)
 		}
 	}()
 }
@@ -137,7 +264,7 @@ func (b *SlackBot) handleEventsAPI(evt *socketmode.Event, eventsAPIEvent *slacke
 		case *slackevents.MessageEvent:
 			b.handleMessageEvent(ev)
 		case *slackevents.AppMentionEvent:
-			b.handleAppMentionEvent(ev)
+			b.handleAppMentionEvent(ev, mentionFiles(*eventsAPIEvent))
 		case *slackevents.ReactionAddedEvent:
 			b.handleReactionEvent(ev, false)
 		case *slackevents.ReactionRemovedEvent:
@@ -152,6 +279,10 @@ func (b *SlackBot) handleEventsAPI(evt *socketmode.Event, eventsAPIEvent *slacke
 			b.handleReactionEvent(reactionEvent, true)
 		case *slackevents.FileSharedEvent:
 			b.handleFileSharedEvent(ev)
+		case *slackevents.AppHomeOpenedEvent:
+			if ev.Tab == "home" {
+				go b.publishAppHome(ev.User)
+			}
 		}
 	default:
 		if b.config.Debug {
@@ -171,7 +302,8 @@ func (b *SlackBot) isBotMentioned(text string) bool {
 	return strings.Contains(text, mentionPattern)
 }
 
-// handleMessageEvent processes message events (including thread replies)
+// handleMessageEvent processes thread replies, saving files shared in Claude
+// session threads
 func (b *SlackBot) handleMessageEvent(ev *slackevents.MessageEvent) {
 	// Ignore messages from bots and our own messages
 	if ev.BotID != "" || ev.User == "" {
@@ -210,200 +342,50 @@ func (b *SlackBot) handleMessageEvent(ev *slackevents.MessageEvent) {
 		return
 	}
 
-	// Only process messages that mention the bot
-	if !b.isBotMentioned(ev.Text) {
-		if b.config.Debug {
-			slog.Debug("Ignoring thread message without bot mention",
-				"user_id", ev.User,
-				"thread_ts", ev.ThreadTimeStamp,
-				"text_preview", func() string {
-					if len(ev.Text) > 50 {
-						return ev.Text[:50] + "..."
-					}
-					return ev.Text
-				}())
+	// Files shared in a Claude session's thread are saved for Claude. Replies
+	// that mention the bot also arrive as app_mention events, and their files
+	// are saved by handleThreadMention.
+	if len(ev.Files) > 0 && !b.isBotMentioned(ev.Text) {
+		if session, exists := b.getSession(ev.ThreadTimeStamp); exists && b.isChannelAllowed(ev.Channel) && b.canUseSession(ev.User, session) {
+			go b.ingestThreadFiles(ev.Files, ev.User, ev.Channel, ev.ThreadTimeStamp, true)
 		}
 		return
 	}
 
-	if b.config.Debug {
-		slog.Debug("Processing thread message with bot mention",
+	if b.config.Debug && !b.isBotMentioned(ev.Text) {
+		slog.Debug("Ignoring thread message without bot mention",
 			"user_id", ev.User,
 			"thread_ts", ev.ThreadTimeStamp,
-			"text_length", len(ev.Text))
+			"text_preview", func() string {
+				if len(ev.Text) > 50 {
+					return ev.Text[:50] + "..."
+				}
+				return ev.Text
+			}())
 	}
+}
 
+// handleAppMentionEvent processes app mention events, with the files shared
+// with the mention
+func (b *SlackBot) handleAppMentionEvent(ev *slackevents.AppMentionEvent, files []slackevents.File) {
 	// Check if channel is allowed by whitelist
 	if !b.isChannelAllowed(ev.Channel) {
 		if b.config.Debug {
-			slog.Debug("Thread message rejected - channel not in whitelist",
+			slog.Debug("App mention rejected - channel not in whitelist",
 				"channel_id", ev.Channel,
-				"user_id", ev.User,
-				"thread_ts", ev.ThreadTimeStamp)
+				"user_id", ev.User)
 		}
 		// Silently ignore - no response to avoid revealing bot presence
 		return
 	}
 
-	// Check rate limiting before processing message
-	allowed, resetTime := b.rateLimiter.CheckRateLimit(ev.User)
-	if !allowed {
-		timeToReset := time.Until(resetTime)
-		minutes := int(timeToReset.Minutes())
-		seconds := int(timeToReset.Seconds()) % 60
-
-		var resetMessage string
-		if minutes > 0 {
-			resetMessage = fmt.Sprintf("⏱️ _Rate limit exceeded. Please wait %dm %ds before sending another message._", minutes, seconds)
-		} else {
-			resetMessage = fmt.Sprintf("⏱️ _Rate limit exceeded. Please wait %ds before sending another message._", seconds)
-		}
-
-		_, err := b.postMessage(ev.Channel, ev.ThreadTimeStamp, resetMessage)
-		if err != nil {
-			slog.Error("Failed to post rate limit message", "error", err)
-		}
-
-		if b.config.Debug {
-			slog.Debug("Message rate limited",
-				"user_id", ev.User,
-				"thread_ts", ev.ThreadTimeStamp,
-				"time_to_reset", timeToReset)
-		}
-		return
-	}
-
-	// Track user activity for context management
-	if b.contextManager != nil {
-		b.contextManager.TrackUserActivity(ev.User, ev.ThreadTimeStamp)
-
-		// Check if we should show context for returning users
-		if b.contextManager.ShouldShowContext(ev.User, ev.ThreadTimeStamp) {
-			go b.handleContextDisplay(ev.User, ev.Channel, ev.ThreadTimeStamp)
-		}
-	}
-
-	// Check if this is a /flow command in a thread
-	if strings.HasPrefix(strings.TrimSpace(ev.Text), "/flow") {
-		b.handleFlowInThreadMessage(ev)
-		return
-	}
-
-	// Check if this is a thread we're managing (Claude session)
-	session, exists := b.getSession(ev.ThreadTimeStamp)
-	if !exists {
-		// Check if this is an ideation thread
-		_, ideationExists := b.ideationManager.GetSession(ev.ThreadTimeStamp)
-		if ideationExists {
-			// This is an ideation thread but not a /flow command, ignore
-			return
-		}
-
-		// Try to resume/create a session before giving up
-		if b.config.Debug {
-			slog.Debug("No active session found in memory, attempting to resume",
-				"thread_ts", ev.ThreadTimeStamp,
-				"user_id", ev.User,
-				"channel_id", ev.Channel)
-		}
-
-		// Attempt to resume or create session (this checks database too)
-		resumedSession, err := b.resumeOrCreateSession(ev.User, ev.Channel, ev.ThreadTimeStamp)
-		if err != nil {
-			if b.config.Debug {
-				slog.Debug("Failed to resume session for thread message",
-					"thread_ts", ev.ThreadTimeStamp,
-					"error", err)
-			}
-			// Check if user is trying to continue a conversation in an old thread
-			_, err := b.postMessage(ev.Channel, ev.ThreadTimeStamp,
-				"💡 _No active Claude session in this thread. Use `/flow <your message>` to start a new conversation._")
-			if err != nil {
-				slog.Error("Failed to post no session message", "error", err)
-			}
-			return
-		}
-
-		// Successfully resumed/created session
-		session = resumedSession
-		if b.config.Debug {
-			slog.Debug("Successfully resumed session for thread message",
-				"thread_ts", ev.ThreadTimeStamp,
-				"session_id", session.SessionID,
-				"resumed", session.Resumed)
-		}
-
-		// Post a notification about resumption if this was an existing session
-		if session.Resumed {
-			_, err := b.postMessage(ev.Channel, ev.ThreadTimeStamp, "🔄 _Resuming Claude session..._")
-			if err != nil {
-				slog.Error("Failed to post resumption notification", "error", err)
-			}
-		}
-	}
-
-	// Validate and preprocess the message
-	processedText, err := b.preprocessMessage(ev.Text, ev.User)
-	if err != nil {
-		slog.Error("Failed to preprocess message", "error", err)
-		_, err := b.postMessage(ev.Channel, ev.ThreadTimeStamp,
-			"❌ _Unable to process your message. Please try rephrasing._")
-		if err != nil {
-			slog.Error("Failed to post preprocessing error message", "error", err)
-		}
+	role := b.userRole(ev.User)
+	if role == roleNone {
+		b.denyUser(ev.Channel, ev.ThreadTimeStamp, ev.User, notAuthorizedMessage)
 		return
 	}
-
-	// Check for empty or very short messages
-	if len(strings.TrimSpace(processedText)) < 2 {
-		_, err := b.postMessage(ev.Channel, ev.ThreadTimeStamp,
-			"🤔 _Your message seems a bit short. Could you provide more detail?_")
-		if err != nil {
-			slog.Error("Failed to post short message feedback", "error", err)
-		}
-		return
-	}
-
-	// Update session activity
-	b.updateSessionActivity(ev.ThreadTimeStamp)
-
-	if b.config.Debug {
-		slog.Debug("Handling thread reply",
-			"user_id", ev.User,
-			"channel_id", ev.Channel,
-			"thread_ts", ev.ThreadTimeStamp,
-			"original_text", ev.Text,
-			"processed_text", processedText,
-			"text_length", len(processedText))
-	}
-
-	// Check if Claude session is healthy before sending
-	if !session.Active {
-		slog.Warn("Attempted to send message to inactive session",
-			"session_id", session.SessionID,
-			"thread_ts", ev.ThreadTimeStamp)
-		_, err := b.postMessage(ev.Channel, ev.ThreadTimeStamp,
-			"⚠️ _Claude session is currently inactive. Use `/flow <your message>` to start a new conversation._")
-		if err != nil {
-			slog.Error("Failed to post inactive session message", "error", err)
-		}
-		return
-	}
-
-	// Send the processed message to Claude with typing indicator
-	b.sendToClaudeSessionWithTyping(session, processedText)
-}
-
-// handleAppMentionEvent processes app mention events
-func (b *SlackBot) handleAppMentionEvent(ev *slackevents.AppMentionEvent) {
-	// Check if channel is allowed by whitelist
-	if !b.isChannelAllowed(ev.Channel) {
-		if b.config.Debug {
-			slog.Debug("App mention rejected - channel not in whitelist",
-				"channel_id", ev.Channel,
-				"user_id", ev.User)
-		}
-		// Silently ignore - no response to avoid revealing bot presence
+	if ev.ThreadTimeStamp != "" && !b.canUseThread(ev.User, ev.ThreadTimeStamp) {
+		b.denyUser(ev.Channel, ev.ThreadTimeStamp, ev.User, sessionNotOwnedMessage)
 		return
 	}
 
@@ -445,48 +427,29 @@ func (b *SlackBot) handleAppMentionEvent(ev *slackevents.AppMentionEvent) {
 			"thread_ts", ev.ThreadTimeStamp)
 	}
 
-	// Handle app mention - reply in existing thread or create new one
-	go func() {
-		var threadTS string
-		var err error
+	// Mentions in a thread continue its session, or start one from the thread
+	if ev.ThreadTimeStamp != "" {
+		go b.handleThreadMention(ev, files, role, text)
+		return
+	}
 
-		if ev.ThreadTimeStamp != "" {
-			// We're in an existing thread - reply there
-			threadTS = ev.ThreadTimeStamp
-			if b.config.Debug {
-				slog.Debug("App mention in existing thread, replying in thread",
-					"thread_ts", threadTS,
-					"channel_id", ev.Channel)
-			}
-			_, _, err = b.client.PostMessage(ev.Channel,
-				slack.MsgOptionText("🤖 Starting Claude session...", false),
-				slack.MsgOptionTS(ev.ThreadTimeStamp),
-			)
-			if err != nil {
-				slog.Error("Failed to reply in thread for app mention", "error", err, "thread_ts", threadTS)
-				return
-			}
-		} else {
-			// Create new thread
-			if b.config.Debug {
-				slog.Debug("App mention in channel, creating new thread",
-					"channel_id", ev.Channel)
-			}
-			_, threadTS, err = b.client.PostMessage(ev.Channel,
-				slack.MsgOptionText("🤖 Starting Claude session...", false),
-				slack.MsgOptionAsUser(true),
-			)
-			if err != nil {
-				slog.Error("Failed to create thread for app mention", "error", err)
-				return
-			}
+	// Mentions in a channel start a new thread
+	go func() {
+		_, threadTS, err := b.client.PostMessage(ev.Channel,
+			slack.MsgOptionText("🤖 Starting Claude session...", false),
+			slack.MsgOptionAsUser(true),
+		)
+		if err != nil {
+			slog.Error("Failed to create thread for app mention", "error", err)
+			return
 		}
 
 		// Create Claude session
-		session, err := b.createClaudeSession(ev.User, ev.Channel, threadTS)
+		opts := role.sessionOptions()
+		session, err := b.createClaudeSession(ev.User, ev.Channel, threadTS, opts...)
 		if err != nil {
-			slog.Error("Failed to create Claude session for app mention", "error", err, "thread_ts", threadTS)
-			b.updateMessage(ev.Channel, threadTS, "❌ Failed to start Claude session. Please try again.")
+			b.updateMessage(ev.Channel, threadTS, startFailedMessage)
+			b.reportStartFailure(ev.User, ev.Channel, threadTS, text, opts, err)
 			return
 		}
 
@@ -497,10 +460,16 @@ func (b *SlackBot) handleAppMentionEvent(ev *slackevents.AppMentionEvent) {
 
 // updateMessage updates a Slack message
 func (b *SlackBot) updateMessage(channel, timestamp, text string) error {
-	_, _, _, err := b.client.UpdateMessage(channel, timestamp,
-		slack.MsgOptionText(text, false),
-		slack.MsgOptionAsUser(true),
-	)
+	_, err := b.outbox.send(channel, func() (string, error) {
+		_, _, _, err := b.client.UpdateMessage(channel, timestamp,
+			slack.MsgOptionText(text, false),
+			slack.MsgOptionAsUser(true),
+		)
+		return "", err
+	})
+	if err != nil {
+		metrics.SlackAPIErrorsTotal.WithLabelValues("chat.update").Inc()
+	}
 	return err
 }
 
@@ -515,7 +484,13 @@ func (b *SlackBot) postMessage(channel, threadTS, text string) (string, error) {
 		options = append(options, slack.MsgOptionTS(threadTS))
 	}
 
-	_, timestamp, err := b.client.PostMessage(channel, options...)
+	timestamp, err := b.outbox.send(channel, func() (string, error) {
+		_, timestamp, err := b.client.PostMessage(channel, options...)
+		return timestamp, err
+	})
+	if err != nil {
+		metrics.SlackAPIErrorsTotal.WithLabelValues("chat.postMessage").Inc()
+	}
 	return timestamp, err
 }
 
@@ -557,15 +532,15 @@ func (b *SlackBot) parseFlowCommand(content string) (repoURL, prompt string) {
 }
 
 // handleSimpleWorkflow handles direct Claude sessions without repositories
-func (b *SlackBot) handleSimpleWorkflow(userID, channelID, threadTS, prompt string) {
+func (b *SlackBot) handleSimpleWorkflow(userID, channelID, threadTS, prompt string, opts ...claude.SessionOption) {
 	// Check if there's an active ideation session we should use context from
 	enhancedPrompt := b.enhancePromptWithIdeationContext(userID, channelID, prompt)
 
 	// Create Claude session
-	session, err := b.createClaudeSession(userID, channelID, threadTS)
+	session, err := b.createClaudeSession(userID, channelID, threadTS, opts...)
 	if err != nil {
-		slog.Error("Failed to create Claude session", "error", err)
-		_ = b.updateMessage(channelID, threadTS, "❌ Failed to start Claude session. Please try again.")
+		_ = b.updateMessage(channelID, threadTS, startFailedMessage)
+		b.reportStartFailure(userID, channelID, threadTS, enhancedPrompt, opts, err)
 		return
 	}
 
@@ -575,27 +550,52 @@ func (b *SlackBot) handleSimpleWorkflow(userID, channelID, threadTS, prompt stri
 
 // handleRepositoryWorkflow handles worklet creation and repository-based workflows
 func (b *SlackBot) handleRepositoryWorkflow(userID, channelID, threadTS, repoURL, prompt string) {
-	ctx := context.Background()
-
-	// Update initial message to show progress
-	_ = b.updateMessage(channelID, threadTS, "🔄 Creating worklet...")
-
 	// Create worklet request
 	workletReq := worklet.CreateWorkletRequest{
 		Name:        fmt.Sprintf("Slack Flow - %s", b.extractRepoName(repoURL)),
 		Description: fmt.Sprintf("Created via Slack /flow command for user %s", userID),
 		GitRepo:     repoURL,
-		Branch:      "main", // Default to main branch
 		BasePrompt:  prompt,
-		Environment: map[string]string{
-			"SLACK_USER_ID":   userID,
-			"SLACK_CHANNEL":   channelID,
-			"SLACK_THREAD_TS": threadTS,
-		},
+		Environment: workletThreadEnvironment(userID, channelID, threadTS),
+	}
+
+	b.startWorklet(userID, channelID, threadTS, "", func(ctx context.Context) (*worklet.Worklet, error) {
+		return b.workletManager.CreateWorklet(ctx, workletReq, userID)
+	})
+}
+
+// handleTemplateWorkflow starts a worklet from a template, with a prompt
+// that follows the template's base prompt
+func (b *SlackBot) handleTemplateWorkflow(userID, channelID, threadTS, templateName, prompt string) {
+	b.startWorklet(userID, channelID, threadTS, templateName, func(ctx context.Context) (*worklet.Worklet, error) {
+		return b.workletManager.CreateFromTemplate(ctx, templateName, worklet.TemplateWorkletRequest{
+			Name:        fmt.Sprintf("Slack Flow - %s", templateName),
+			Prompt:      prompt,
+			Environment: workletThreadEnvironment(userID, channelID, threadTS),
+		}, userID)
+	})
+}
+
+// workletThreadEnvironment tells a worklet which Slack thread it was started
+// from, so its pull request updates can be posted there
+func workletThreadEnvironment(userID, channelID, threadTS string) map[string]string {
+	return map[string]string{
+		"SLACK_USER_ID":   userID,
+		"SLACK_CHANNEL":   channelID,
+		"SLACK_THREAD_TS": threadTS,
 	}
+}
+
+// startWorklet creates a worklet with create and follows its deployment in
+// the thread
+func (b *SlackBot) startWorklet(userID, channelID, threadTS, templateName string, create func(context.Context) (*worklet.Worklet, error)) {
+	ctx := context.Background()
+
+	// Update initial message to show progress
+	_ = b.updateMessage(channelID, threadTS, "🔄 Creating worklet...")
 
 	// Create worklet
-	workletObj, err := b.workletManager.CreateWorklet(ctx, workletReq, userID)
+	workletObj, err := create(ctx)
 	if err != nil {
 		slog.Error("Failed to create worklet", "error", err)
 		_ = b.updateMessage(channelID, threadTS,
@@ -603,13 +603,22 @@ func (b *SlackBot) handleRepositoryWorkflow(userID, channelID, threadTS, repoURL
 		return
 	}
 
+	details := map[string]interface{}{
+		"worklet_id": workletObj.ID,
+		"repo":       workletObj.GitRepo,
+	}
+	created := fmt.Sprintf("✅ Worklet created successfully!\n🆔 ID: `%s`\n🔗 Repository: %s\n", workletObj.ID, workletObj.GitRepo)
+	if templateName != "" {
+		details["template"] = templateName
+		created += fmt.Sprintf("📋 Template: `%s`\n", templateName)
+	}
+	b.audit(userID, channelID, threadTS, auditWorkletDeployed, details)
+
 	// Update message with worklet creation success
-	_ = b.updateMessage(channelID, threadTS,
-		fmt.Sprintf("✅ Worklet created successfully!\n🆔 ID: `%s`\n🔗 Repository: %s\n\n🔄 Building and deploying...",
-			workletObj.ID, repoURL))
+	_ = b.updateMessage(channelID, threadTS, created+"\n🔄 Building and deploying...")
 
 	// Start monitoring worklet status and update Slack accordingly
-	go b.monitorWorkletProgress(ctx, workletObj.ID, channelID, threadTS, repoURL, prompt)
+	go b.monitorWorkletProgress(ctx, workletObj.ID, channelID, threadTS, workletObj.GitRepo, workletObj.BasePrompt)
 }
 
 // extractRepoName extracts the repository name from a Git URL
@@ -632,14 +641,51 @@ func (b *SlackBot) extractRepoName(repoURL string) string {
 	return "unknown-repo"
 }
 
+// workletStatusPoll is how often a worklet's progress is re-read in case the
+// event bus dropped the lifecycle event that finished it
+const workletStatusPoll = 30 * time.Second
+
 // monitorWorkletProgress monitors worklet deployment and updates Slack with progress
 func (b *SlackBot) monitorWorkletProgress(ctx context.Context, workletID, channelID, threadTS, repoURL, prompt string) {
-	// Poll worklet status until it's running or failed
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
+	// Follow the worklet's lifecycle events until it's deployed, failed, or stopped
+	lifecycle, unsubscribe := events.Channel(b.events, events.TopicWorkletEvent)
+	defer unsubscribe()
+
+	// The bus drops events for slow subscribers, so the status is also polled
+	poll := time.NewTicker(workletStatusPoll)
+	defer poll.Stop()
+
+	// Its build and container output is posted to the thread in snippets
+	logs, unsubscribeLogs := events.Channel(b.events, events.TopicWorkletLog)
+	defer unsubscribeLogs()
+	var tail workletLogTail
+	logTicker := time.NewTicker(workletLogInterval)
+	defer logTicker.Stop()
 
 	timeout := time.After(10 * time.Minute) // 10 minute timeout
 
+	// finished re-reads the worklet, updates the thread when its status has
+	// changed, and reports whether deployment has finished
+	var status worklet.Status
+	finished := func() bool {
+		workletObj, err := b.workletManager.GetWorklet(workletID)
+		if err != nil {
+			slog.Error("Failed to get worklet status", "error", err)
+			return false
+		}
+		if workletObj.Status == status {
+			return false
+		}
+		status = workletObj.Status
+		b.postWorkletLog(channelID, threadTS, &tail)
+		return b.handleWorkletStatus(ctx, workletObj, channelID, threadTS, prompt)
+	}
+
+	// The build may have moved on before we subscribed, so start from the current status
+	if finished() {
+		return
+	}
+
 	for {
 		select {
 		case <-timeout:
@@ -650,46 +696,90 @@ func (b *SlackBot) monitorWorkletProgress(ctx context.Context, workletID, channe
 		case <-ctx.Done():
 			return
 
-		case <-ticker.C:
-			workletObj, err := b.workletManager.GetWorklet(workletID)
-			if err != nil {
-				slog.Error("Failed to get worklet status", "error", err)
-				continue
+		case log := <-logs:
+			if log.WorkletID == workletID {
+				tail.add(log)
 			}
 
-			switch workletObj.Status {
-			case worklet.StatusRunning:
-				// Worklet is ready, create PR and send link
-				_ = b.updateMessage(channelID, threadTS,
-					fmt.Sprintf("🎉 Worklet is running!\n🌐 Web URL: <%s>\n\n🔄 Creating pull request...",
-						workletObj.WebURL))
+		case <-logTicker.C:
+			b.postWorkletLog(channelID, threadTS, &tail)
 
-				// Create PR for the changes
-				b.createPullRequestForWorklet(ctx, workletObj, channelID, threadTS, prompt)
+		case event := <-lifecycle:
+			if event.WorkletID != workletID {
+				continue
+			}
+			if finished() {
 				return
+			}
 
-			case worklet.StatusError:
-				errorMsg := "❌ Worklet deployment failed"
-				if workletObj.LastError != "" {
-					errorMsg += fmt.Sprintf(": %s", workletObj.LastError)
-				}
-				_ = b.updateMessage(channelID, threadTS, errorMsg)
+		case <-poll.C:
+			if finished() {
 				return
-
-			case worklet.StatusBuilding:
-				_ = b.updateMessage(channelID, threadTS,
-					"🔨 Building Docker container...")
-
-			case worklet.StatusDeploying:
-				_ = b.updateMessage(channelID, threadTS,
-					"🚀 Deploying worklet...")
 			}
 		}
 	}
 }
 
-// createPullRequestForWorklet creates a pull request for the worklet changes and posts the PR link to Slack
-func (b *SlackBot) createPullRequestForWorklet(ctx context.Context, workletObj *worklet.Worklet, channelID, threadTS, prompt string) {
+// workletRevision describes the commit a worklet was deployed from, such as
+// "📌 `1a2b3c4` on `main`"
+func workletRevision(w *worklet.Worklet) string {
+	if w.CommitSHA == "" {
+		return ""
+	}
+	sha := w.CommitSHA
+	if len(sha) > 7 {
+		sha = sha[:7]
+	}
+	revision := fmt.Sprintf("📌 `%s`", sha)
+	switch {
+	case w.Ref != "" && !strings.HasPrefix(w.CommitSHA, strings.ToLower(w.Ref)):
+		revision += fmt.Sprintf(" at `%s`", w.Ref)
+	case w.Branch != "":
+		revision += fmt.Sprintf(" on `%s`", w.Branch)
+	}
+	return revision
+}
+
+// handleWorkletStatus updates the Slack progress message for the worklet's
+// current status and reports whether deployment has finished
+func (b *SlackBot) handleWorkletStatus(ctx context.Context, workletObj *worklet.Worklet, channelID, threadTS, prompt string) bool {
+	switch workletObj.Status {
+	case worklet.StatusRunning:
+		// Worklet is ready; its changes are only pushed once someone approves the PR
+		_ = b.updateMessage(channelID, threadTS,
+			fmt.Sprintf("🎉 Worklet is running!\n🌐 Web URL: <%s>\n%s\n\n⏳ Waiting for approval to create a pull request...",
+				b.workletManager.PreviewLink(workletObj), workletRevision(workletObj)))
+		b.postPullRequestApproval(channelID, threadTS, workletObj)
+		go b.watchWorkletHealth(b.ctx, workletObj.ID, channelID, threadTS)
+		return true
+
+	case worklet.StatusError:
+		errorMsg := "❌ Worklet deployment failed"
+		if workletObj.LastError != "" {
+			errorMsg += fmt.Sprintf(": %s", workletObj.LastError)
+		}
+		_ = b.updateMessage(channelID, threadTS, errorMsg)
+		b.uploadWorkletLog(channelID, threadTS, workletObj)
+		return true
+
+	case worklet.StatusStopped:
+		_ = b.updateMessage(channelID, threadTS, "⏹️ Worklet was stopped before it finished deploying.")
+		return true
+
+	case worklet.StatusBuilding:
+		_ = b.updateMessage(channelID, threadTS,
+			"🔨 Building Docker container...")
+
+	case worklet.StatusDeploying:
+		_ = b.updateMessage(channelID, threadTS,
+			"🚀 Deploying worklet...")
+	}
+	return false
+}
+
+// createPullRequestForWorklet creates a pull request for the worklet changes and posts the PR link to Slack.
+// approvedBy is the Slack user who approved it.
+func (b *SlackBot) createPullRequestForWorklet(ctx context.Context, workletObj *worklet.Worklet, channelID, threadTS, prompt, approvedBy string) {
 	// Generate branch name from prompt
 	branchName := b.generateBranchName(prompt)
 
@@ -699,37 +789,35 @@ func (b *SlackBot) createPullRequestForWorklet(ctx context.Context, workletObj *
 		prTitle = prTitle[:69] + "..."
 	}
 
-	prDescription := fmt.Sprintf(`## Changes Made by Claude
-
-**Original Prompt:** %s
-
-**Worklet ID:** %s
-**Repository:** %s
-**Web Preview:** %s
-
-This pull request contains changes generated by Claude in response to the above prompt.
-
-### Summary
-Claude has analyzed the codebase and applied the requested changes. Please review the modifications carefully before merging.
-
----
-*Generated via Slack /flow command*`, prompt, workletObj.ID, workletObj.GitRepo, workletObj.WebURL)
-
-	// Get worklet manager to access git operations through ClaudeClient
-	// We'll use the worklet's ClaudeClient which has git integration
-	claudeClient := &worklet.ClaudeClient{}
+	prDescription := pullRequestDescription(workletObj, prompt, b.approverName(approvedBy))
 
-	// Create PR using the worklet's repository path
-	err := claudeClient.CreatePR(ctx, fmt.Sprintf("/tmp/worklet-repos/%s", workletObj.ID), branchName, prTitle, prDescription)
+	// Create PR from the worklet's repository checkout
+	err := b.workletManager.CreatePR(ctx, workletObj, branchName, prTitle, prDescription, false)
+	if errors.Is(err, worklet.ErrTestsFailed) || errors.Is(err, worklet.ErrTestsRunning) {
+		slog.Info("Pull request held back by worklet tests", "error", err, "worklet_id", workletObj.ID)
+		_ = b.updateMessage(channelID, threadTS, testsBlockedMessage(workletObj))
+		return
+	}
 	if err != nil {
 		slog.Error("Failed to create PR for worklet", "error", err, "worklet_id", workletObj.ID)
 		_ = b.updateMessage(channelID, threadTS,
 			fmt.Sprintf("❌ Failed to create pull request: %s\n\n🌐 Worklet URL: <%s>",
-				err.Error(), workletObj.WebURL))
+				err.Error(), b.workletManager.PreviewLink(workletObj)))
 		return
 	}
 
+	b.audit(approvedBy, channelID, threadTS, auditPRCreated, map[string]interface{}{
+		"worklet_id": workletObj.ID,
+		"repo":       workletObj.GitRepo,
+		"branch":     branchName,
+		"title":      prTitle,
+	})
+
 	// Success! Update message with PR link
+	prLink := prTitle
+	if workletObj.PRURL != "" {
+		prLink = fmt.Sprintf("<%s|%s>", workletObj.PRURL, prTitle)
+	}
 	_ = b.updateMessage(channelID, threadTS,
 		fmt.Sprintf(`✅ **Pull Request Created Successfully!**
 
@@ -737,10 +825,50 @@ Claude has analyzed the codebase and applied the requested changes. Please revie
 🌐 **Worklet Preview:** <%s>
 📝 **PR Title:** %s
 
-The changes have been pushed to a new branch and a pull request has been created. You can review and merge the changes on GitHub.
+The changes have been pushed to a new branch and a pull request has been created. You can review and merge the changes on GitHub; this thread will hear when it's merged or closed and when its checks finish.
+
+---
+*Generated via Slack /flow command*`, workletObj.GitRepo, workletObj.WebURL, prLink))
+}
+
+// maxTestOutputLines is how much of a failed test run testsBlockedMessage shows
+const maxTestOutputLines = 20
+
+// testsBlockedMessage explains that a pull request wasn't opened because the
+// worklet's tests failed or haven't finished, ending with their last lines
+func testsBlockedMessage(workletObj *worklet.Worklet) string {
+	if workletObj.TestStatus == worklet.TestsRunning {
+		return "⏳ The worklet's tests are still running, so no pull request was opened yet."
+	}
+	text := "❌ The worklet's tests failed, so no pull request was opened. Send another prompt to fix them, or open it through the API with `force`."
+	lines := strings.Split(strings.TrimRight(workletObj.TestOutput, "\n"), "\n")
+	if len(lines) > maxTestOutputLines {
+		lines = lines[len(lines)-maxTestOutputLines:]
+	}
+	if output := strings.Join(lines, "\n"); output != "" {
+		text += "\n```\n" + output + "\n```"
+	}
+	return text
+}
+
+// pullRequestDescription writes the description of a worklet's pull request
+func pullRequestDescription(workletObj *worklet.Worklet, prompt, approver string) string {
+	return fmt.Sprintf(`## Changes Made by Claude
+
+**Original Prompt:** %s
+
+**Worklet ID:** %s
+**Repository:** %s
+**Web Preview:** %s
+**Approved By:** %s
+
+This pull request contains changes generated by Claude in response to the above prompt.
+
+### Summary
+Claude has analyzed the codebase and applied the requested changes. Please review the modifications carefully before merging.
 
 ---
-*Generated via Slack /flow command*`, workletObj.GitRepo, workletObj.WebURL, prTitle))
+*Generated via Slack /flow command*`, prompt, workletObj.ID, workletObj.GitRepo, workletObj.WebURL, approver)
 }
 
 // generateBranchName creates a git-safe branch name from the prompt
@@ -1030,12 +1158,12 @@ func (b *SlackBot) findRecentIdeationSession(userID, channelID string) *Ideation
 }
 
 // Alternative approach: Modify /flow to work within threads
-func (b *SlackBot) handleFlowInThread(userID, channelID, threadTS, prompt string) {
+func (b *SlackBot) handleFlowInThread(userID, channelID, threadTS, prompt string, opts ...claude.SessionOption) {
 	// Check if this thread has an ideation session
 	session, exists := b.ideationManager.GetSession(threadTS)
 	if !exists {
 		// No ideation session, proceed with normal flow
-		b.handleSimpleWorkflow(userID, channelID, threadTS, prompt)
+		b.handleSimpleWorkflow(userID, channelID, threadTS, prompt, opts...)
 		return
 	}
 
@@ -1044,7 +1172,7 @@ func (b *SlackBot) handleFlowInThread(userID, channelID, threadTS, prompt string
 	claudeContext, err := b.chatgptService.GenerateClaudeContext(ctx, session)
 	if err != nil {
 		slog.Error("Failed to generate Claude context from ideation session", "error", err)
-		b.handleSimpleWorkflow(userID, channelID, threadTS, prompt)
+		b.handleSimpleWorkflow(userID, channelID, threadTS, prompt, opts...)
 		return
 	}
 
@@ -1066,10 +1194,10 @@ The user has completed an ideation session in this thread. Use the context above
 	}
 
 	// Create Claude session with enhanced prompt
-	claudeSession, err := b.createClaudeSession(userID, channelID, threadTS)
+	claudeSession, err := b.createClaudeSession(userID, channelID, threadTS, opts...)
 	if err != nil {
-		slog.Error("Failed to create Claude session", "error", err)
-		_ = b.updateMessage(channelID, threadTS, "❌ Failed to start Claude session. Please try again.")
+		_ = b.updateMessage(channelID, threadTS, startFailedMessage)
+		b.reportStartFailure(userID, channelID, threadTS, enhancedPrompt, opts, err)
 		return
 	}
 
@@ -1209,6 +1337,11 @@ func (b *SlackBot) handleFlowInThreadMessage(ev *slackevents.MessageEvent) {
 		return
 	}
 
+	b.audit(ev.User, ev.Channel, ev.ThreadTimeStamp, auditCommand, map[string]interface{}{
+		"command": "/flow",
+		"text":    strings.TrimSpace(text[5:]),
+	})
+
 	// Remove "/flow" prefix and get the actual prompt
 	prompt := strings.TrimSpace(text[5:]) // Remove "/flow"
 	if prompt == "" {
@@ -1221,6 +1354,56 @@ func (b *SlackBot) handleFlowInThreadMessage(ev *slackevents.MessageEvent) {
 		return
 	}
 
+	sub, isSubcommand := parseFlowSubcommand(prompt)
+	if isSubcommand && sub.name == "template" && !strings.EqualFold(sub.args[0], "list") {
+		// Templates start worklets from a channel, so in a thread this is a prompt
+		isSubcommand = false
+	}
+
+	// Only those who may use the thread's session can stop, continue, or add to it
+	usesSession := !isSubcommand || sub.name == "new" || sub.name == "continue" || sub.name == "issue" || (sub.name == "stop" && len(sub.args) == 0)
+	if usesSession && !b.canUseThread(ev.User, ev.ThreadTimeStamp) {
+		b.denyUser(ev.Channel, ev.ThreadTimeStamp, ev.User, sessionNotOwnedMessage)
+		return
+	}
+
+	if isSubcommand {
+		switch {
+		case sub.name == "stop" && len(sub.args) == 0:
+			go b.stopClaudeTurn(ev.Channel, ev.ThreadTimeStamp)
+			return
+		case sub.name == "continue":
+			go b.continueClaudeSession(ev.Channel, ev.ThreadTimeStamp)
+			return
+		case sub.name == "export":
+			go b.exportClaudeSession(ev.Channel, ev.ThreadTimeStamp, strings.Join(sub.args, ""))
+			return
+		case sub.name == "issue":
+			go b.fileThreadIssue(ev.User, ev.Channel, ev.ThreadTimeStamp)
+			return
+		case sub.name == "new":
+			prompt = sub.rest
+		default:
+			if _, err := b.postMessage(ev.Channel, ev.ThreadTimeStamp, b.flowSubcommandReply(ev.User, ev.Channel, sub)); err != nil {
+				slog.Error("Failed to post flow subcommand reply", "error", err)
+			}
+			return
+		}
+	}
+
+	opts := b.userRole(ev.User).sessionOptions()
+	if persona, rest, ok := parsePersonaFlag(prompt); ok {
+		opt, problem := b.personaOption(persona, rest)
+		if problem != "" {
+			if _, err := b.postMessage(ev.Channel, ev.ThreadTimeStamp, problem); err != nil {
+				slog.Error("Failed to post persona error", "error", err)
+			}
+			return
+		}
+		prompt = rest
+		opts = append(opts, opt)
+	}
+
 	if b.config.Debug {
 		slog.Debug("Handling /flow in thread",
 			"user_id", ev.User,
@@ -1230,7 +1413,7 @@ func (b *SlackBot) handleFlowInThreadMessage(ev *slackevents.MessageEvent) {
 	}
 
 	// Use the thread-aware flow handler
-	go b.handleFlowInThread(ev.User, ev.Channel, ev.ThreadTimeStamp, prompt)
+	go b.handleFlowInThread(ev.User, ev.Channel, ev.ThreadTimeStamp, prompt, opts...)
 }
 
 // handleContextCommand processes /context slash commands for manual context summaries
@@ -1352,7 +1535,9 @@ func (b *SlackBot) handleContextCommand(evt *socketmode.Event, cmd *slack.SlashC
 	}()
 }
 
-// handleFileSharedEvent processes file upload events in threads
+// handleFileSharedEvent logs file_shared events. Files shared in a thread are
+// ingested from the message event, which, unlike this event, says which thread
+// they were shared in.
 func (b *SlackBot) handleFileSharedEvent(ev *slackevents.FileSharedEvent) {
 	if b.config.Debug {
 		slog.Debug("Received file shared event",
@@ -1360,78 +1545,64 @@ func (b *SlackBot) handleFileSharedEvent(ev *slackevents.FileSharedEvent) {
 			"user_id", ev.UserID,
 			"channel_id", ev.ChannelID)
 	}
+}
 
-	// Get file information from Slack API
-	file, _, _, err := b.client.GetFileInfo(ev.FileID, 0, 0)
-	if err != nil {
-		slog.Error("Failed to get file info", "error", err, "file_id", ev.FileID)
-		return
-	}
+// ingestThreadFiles downloads the supported files shared in a thread into its
+// upload directory and returns their paths. With notify, Claude is told about
+// each file as it arrives.
+func (b *SlackBot) ingestThreadFiles(files []slackevents.File, userID, channelID, threadTS string, notify bool) []string {
+	var paths []string
+	for _, shared := range files {
+		file, _, _, err := b.client.GetFileInfo(shared.ID, 0, 0)
+		if err != nil {
+			slog.Error("Failed to get file info", "error", err, "file_id", shared.ID)
+			continue
+		}
 
-	if b.config.Debug {
-		slog.Debug("File info retrieved",
-			"file_id", ev.FileID,
-			"name", file.Name,
-			"mimetype", file.Mimetype,
-			"size", file.Size,
-			"channels", file.Channels)
-	}
-
-	// Check if this file is shared in a thread we're managing
-	var threadTS string
-	for _, channelID := range file.Channels {
-		// We need to determine if this file was uploaded to a thread
-		// Unfortunately, FileSharedEvent doesn't directly provide thread info
-		// We'll check if there are any active sessions in this channel
-		if sessions, err := b.sessionDB.GetAllActiveSessions(); err == nil {
-			for _, session := range sessions {
-				if session.ChannelID == channelID {
-					threadTS = session.ThreadTS
-					break
-				}
+		if !b.isSupportedFile(file.Mimetype, int64(file.Size)) {
+			slog.Info("Unsupported file type uploaded",
+				"file_id", file.ID,
+				"mimetype", file.Mimetype,
+				"size", file.Size,
+				"thread_ts", threadTS)
+			if _, err := b.postMessage(channelID, threadTS, b.unsupportedFileMessage(file)); err != nil {
+				slog.Error("Failed to post unsupported file message", "error", err)
 			}
+			continue
 		}
-		if threadTS != "" {
-			break
-		}
-	}
 
-	// If we don't have a thread context, we can't process this file
-	if threadTS == "" {
-		if b.config.Debug {
-			slog.Debug("File not uploaded to managed thread, ignoring",
-				"file_id", ev.FileID,
-				"channels", file.Channels)
+		if path := b.downloadAndStoreFile(file, userID, channelID, threadTS, notify); path != "" {
+			paths = append(paths, path)
 		}
-		return
 	}
+	return paths
+}
 
-	// Check if file is a supported type
-	if !b.isSupportedFile(file.Mimetype, int64(file.Size)) {
-		slog.Info("Unsupported file type uploaded",
-			"file_id", ev.FileID,
-			"mimetype", file.Mimetype,
-			"size", file.Size,
-			"thread_ts", threadTS)
+// unsupportedFileMessage explains why a file can't be given to Claude
+func (b *SlackBot) unsupportedFileMessage(file *slack.File) string {
+	if b.getFileCategory(file.Mimetype) == "unsupported" {
+		return fmt.Sprintf("⚠️ Uploaded file '%s' has an unsupported format (%s). Supported formats: Images (PNG, JPG, GIF, WebP), Documents (PDF, TXT, MD, CSV), Code files (JS, PY, GO, HTML, CSS, JSON, XML, YAML), and Office files (DOCX, XLSX, PPTX)", file.Name, file.Mimetype)
+	}
+	maxSize := b.getMaxFileSize(file.Mimetype)
+	return fmt.Sprintf("⚠️ Uploaded file '%s' exceeds maximum size limit. File size: %d bytes, Maximum allowed: %d bytes", file.Name, file.Size, maxSize)
+}
 
-		// Notify user about unsupported file type or size
-		category := b.getFileCategory(file.Mimetype)
-		var message string
-		if category == "unsupported" {
-			message = fmt.Sprintf("⚠️ Uploaded file '%s' has an unsupported format (%s). Supported formats: Images (PNG, JPG, GIF, WebP), Documents (PDF, TXT, MD, CSV), Code files (JS, PY, GO, HTML, CSS, JSON, XML, YAML), and Office files (DOCX, XLSX, PPTX)", file.Name, file.Mimetype)
-		} else {
-			maxSize := b.getMaxFileSize(file.Mimetype)
-			message = fmt.Sprintf("⚠️ Uploaded file '%s' exceeds maximum size limit. File size: %d bytes, Maximum allowed: %d bytes", file.Name, file.Size, maxSize)
-		}
-		_, err := b.postMessage(ev.ChannelID, threadTS, message)
-		if err != nil {
-			slog.Error("Failed to post unsupported file message", "error", err)
-		}
-		return
+// uploadPath returns the absolute path of a file uploaded to a thread, which
+// is where Claude finds it
+func uploadPath(threadTS, filename string) string {
+	path := filepath.Join("./data", "slack-uploads", threadTS, filename)
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
 	}
+	return path
+}
 
-	// Download and store the file
-	go b.downloadAndStoreFile(file, ev.UserID, ev.ChannelID, threadTS)
+// uploadedFilesNote lists the files shared with a message for Claude
+func uploadedFilesNote(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	return "\n\nFiles uploaded with this message:\n- " + strings.Join(paths, "\n- ")
 }
 
 // isSupportedFile checks if the file type and size are supported
@@ -1580,8 +1751,10 @@ func (b *SlackBot) guessMimeTypeFromFilename(filename string) string {
 	return "application/octet-stream"
 }
 
-// downloadAndStoreFile downloads a file from Slack and stores it locally
-func (b *SlackBot) downloadAndStoreFile(file *slack.File, userID, channelID, threadTS string) {
+// downloadAndStoreFile downloads a file from Slack into its thread's upload
+// directory and returns its path, or "" after telling the thread it failed.
+// With notify, Claude is told about the file.
+func (b *SlackBot) downloadAndStoreFile(file *slack.File, userID, channelID, threadTS string, notify bool) string {
 	if b.config.Debug {
 		slog.Debug("Starting file download",
 			"file_id", file.ID,
@@ -1595,7 +1768,7 @@ func (b *SlackBot) downloadAndStoreFile(file *slack.File, userID, channelID, thr
 	if err := os.MkdirAll(uploadDir, 0755); err != nil {
 		slog.Error("Failed to create upload directory", "error", err, "dir", uploadDir)
 		b.postMessage(channelID, threadTS, "❌ Failed to create directory for file storage.")
-		return
+		return ""
 	}
 
 	// Generate unique filename with timestamp prefix
@@ -1612,7 +1785,7 @@ func (b *SlackBot) downloadAndStoreFile(file *slack.File, userID, channelID, thr
 	if downloadURL == "" {
 		slog.Error("No download URL available for file", "file_id", file.ID)
 		b.postMessage(channelID, threadTS, "❌ Unable to download file - no download URL available.")
-		return
+		return ""
 	}
 
 	// Create HTTP request with Slack token
@@ -1620,7 +1793,7 @@ func (b *SlackBot) downloadAndStoreFile(file *slack.File, userID, channelID, thr
 	if err != nil {
 		slog.Error("Failed to create download request", "error", err)
 		b.postMessage(channelID, threadTS, "❌ Failed to create download request.")
-		return
+		return ""
 	}
 
 	req.Header.Set("Authorization", "Bearer "+b.config.BotToken)
@@ -1631,14 +1804,14 @@ func (b *SlackBot) downloadAndStoreFile(file *slack.File, userID, channelID, thr
 	if err != nil {
 		slog.Error("Failed to download file", "error", err, "url", downloadURL)
 		b.postMessage(channelID, threadTS, "❌ Failed to download file from Slack.")
-		return
+		return ""
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		slog.Error("Download request failed", "status", resp.StatusCode, "url", downloadURL)
 		b.postMessage(channelID, threadTS, "❌ Download request failed.")
-		return
+		return ""
 	}
 
 	// Create local file
@@ -1646,7 +1819,7 @@ func (b *SlackBot) downloadAndStoreFile(file *slack.File, userID, channelID, thr
 	if err != nil {
 		slog.Error("Failed to create local file", "error", err, "path", filePath)
 		b.postMessage(channelID, threadTS, "❌ Failed to create local file.")
-		return
+		return ""
 	}
 	defer localFile.Close()
 
@@ -1655,7 +1828,7 @@ func (b *SlackBot) downloadAndStoreFile(file *slack.File, userID, channelID, thr
 	if err != nil {
 		slog.Error("Failed to write file content", "error", err, "path", filePath)
 		b.postMessage(channelID, threadTS, "❌ Failed to write file content.")
-		return
+		return ""
 	}
 
 	if b.config.Debug {
@@ -1673,7 +1846,9 @@ func (b *SlackBot) downloadAndStoreFile(file *slack.File, userID, channelID, thr
 	}
 
 	// Notify Claude about the new file
-	b.notifyClaudeAboutFile(filename, channelID, threadTS)
+	if notify {
+		b.notifyClaudeAboutFile(filename, channelID, threadTS)
+	}
 
 	// Send confirmation to user
 	category := b.getFileCategory(file.Mimetype)
@@ -1699,6 +1874,7 @@ func (b *SlackBot) downloadAndStoreFile(file *slack.File, userID, channelID, thr
 	if err != nil {
 		slog.Error("Failed to post success message", "error", err)
 	}
+	return uploadPath(threadTS, filename)
 }
 
 // notifyClaudeAboutFile sends a message to Claude about the newly uploaded file
@@ -1748,8 +1924,8 @@ func (b *SlackBot) notifyClaudeAboutFile(filename, channelID, threadTS string) {
 		analysisHint = "You can examine this file using your Read tool."
 	}
 
-	notificationMessage := fmt.Sprintf("A new %s has been uploaded to this conversation: %s. File path: ./data/slack-uploads/%s/%s\n\n%s",
-		fileTypeDescription, filename, threadTS, filename, analysisHint)
+	notificationMessage := fmt.Sprintf("A new %s has been uploaded to this conversation: %s. File path: %s\n\n%s",
+		fileTypeDescription, filename, uploadPath(threadTS, filename), analysisHint)
 
 	// Update session activity
 	b.updateSessionActivity(threadTS)
@@ -1762,7 +1938,7 @@ func (b *SlackBot) notifyClaudeAboutFile(filename, channelID, threadTS string) {
 			"session_id", session.SessionID,
 			"filename", filename,
 			"thread_ts", threadTS,
-			"file_path", fmt.Sprintf("./data/slack-uploads/%s/%s", threadTS, filename))
+			"file_path", uploadPath(threadTS, filename))
 	}
 }
 
@@ -0,0 +1,70 @@
+package slackbot
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/breadchris/flow/worklet"
+)
+
+func TestUploadPath(t *testing.T) {
+	path := uploadPath("1712345678.000100", "20240101_120000_notes.md")
+	if !filepath.IsAbs(path) {
+		t.Errorf("uploadPath() = %q; want an absolute path", path)
+	}
+	if want := filepath.Join("/data", "slack-uploads", "1712345678.000100", "20240101_120000_notes.md"); !strings.HasSuffix(path, want) {
+		t.Errorf("uploadPath() = %q; want it to end in %q", path, want)
+	}
+}
+
+func TestUploadedFilesNote(t *testing.T) {
+	if note := uploadedFilesNote(nil); note != "" {
+		t.Errorf("uploadedFilesNote(nil) = %q; want empty", note)
+	}
+
+	got := uploadedFilesNote([]string{"/data/slack-uploads/1.2/a.png", "/data/slack-uploads/1.2/b.go"})
+	want := "\n\nFiles uploaded with this message:\n- /data/slack-uploads/1.2/a.png\n- /data/slack-uploads/1.2/b.go"
+	if got != want {
+		t.Errorf("uploadedFilesNote() = %q; want %q", got, want)
+	}
+}
+
+func TestWorkletRevision(t *testing.T) {
+	const sha = "1a2b3c4d5e6f7a8b9c0d1a2b3c4d5e6f7a8b9c0d"
+	tests := []struct {
+		name    string
+		worklet worklet.Worklet
+		want    string
+	}{
+		{"not deployed", worklet.Worklet{Branch: "main"}, ""},
+		{"branch", worklet.Worklet{Branch: "main", CommitSHA: sha}, "📌 `1a2b3c4` on `main`"},
+		{"tag", worklet.Worklet{Branch: "main", Ref: "v1.2.0", CommitSHA: sha}, "📌 `1a2b3c4` at `v1.2.0`"},
+		{"pinned commit", worklet.Worklet{Branch: "main", Ref: "1a2b3c4d", CommitSHA: sha}, "📌 `1a2b3c4` on `main`"},
+	}
+	for _, tt := range tests {
+		if got := workletRevision(&tt.worklet); got != tt.want {
+			t.Errorf("%s: workletRevision() = %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTestsBlockedMessage(t *testing.T) {
+	var output strings.Builder
+	for i := 1; i <= 25; i++ {
+		fmt.Fprintf(&output, "line %d\n", i)
+	}
+	got := testsBlockedMessage(&worklet.Worklet{TestStatus: worklet.TestsFailed, TestOutput: output.String()})
+	if !strings.HasPrefix(got, "❌ The worklet's tests failed") {
+		t.Errorf("testsBlockedMessage() = %q; want it to say the tests failed", got)
+	}
+	if strings.Contains(got, "line 5\n") || !strings.Contains(got, "line 6\n") || !strings.HasSuffix(got, "line 25\n```") {
+		t.Errorf("testsBlockedMessage() = %q; want the last %d lines of output", got, maxTestOutputLines)
+	}
+
+	got = testsBlockedMessage(&worklet.Worklet{TestStatus: worklet.TestsRunning})
+	if !strings.Contains(got, "still running") {
+		t.Errorf("testsBlockedMessage() = %q; want it to say the tests are running", got)
+	}
+}
@@ -0,0 +1,144 @@
+package slackbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// githubAPIURL is where GitHub issues are created; tests point it elsewhere
+var githubAPIURL = "https://api.github.com"
+
+// githubRepoRegex matches a repository written as owner/name
+var githubRepoRegex = regexp.MustCompile(`^[\w.-]+/[\w.-]+$`)
+
+// issueDraftTimeout bounds how long Claude may take to write an issue
+const issueDraftTimeout = 3 * time.Minute
+
+// parseGitHubRepo reads a repository as owner/name or as its GitHub URL
+func parseGitHubRepo(s string) (string, bool) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "/")
+	if match := githubRemoteRegex.FindStringSubmatch(s); match != nil {
+		return match[1] + "/" + match[2], true
+	}
+	if githubRepoRegex.MatchString(s) {
+		return strings.TrimSuffix(s, ".git"), true
+	}
+	return "", false
+}
+
+// createGitHubIssue opens an issue in repo and returns its URL
+func createGitHubIssue(ctx context.Context, token, repo, title, body string) (string, error) {
+	payload, err := json.Marshal(map[string]string{"title": title, "body": body})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode issue: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", githubAPIURL+"/repos/"+repo+"/issues", bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create issue request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to create issue: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		HTMLURL string `json:"html_url"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode issue response: %w", err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("failed to create issue: GitHub returned %d: %s", resp.StatusCode, result.Message)
+	}
+	return result.HTMLURL, nil
+}
+
+// fileThreadIssue runs /flow issue in a thread: Claude writes a GitHub issue
+// from the thread's conversation, it's opened in the channel's issue
+// repository, and the thread gets its link
+func (b *SlackBot) fileThreadIssue(userID, channelID, threadTS string) {
+	reply := func(text string) {
+		if _, err := b.postMessage(channelID, threadTS, text); err != nil {
+			slog.Error("Failed to post issue reply", "error", err)
+		}
+	}
+
+	session, exists := b.getSession(threadTS)
+	if !exists {
+		reply("There is no Claude session in this thread to file an issue from.")
+		return
+	}
+	repo := b.channelSettings(channelID).IssueRepo
+	if repo == "" {
+		reply("No issue repository is set for this channel. Admins can set one with `/flow config set issue_repo <owner/name>`.")
+		return
+	}
+	token := ""
+	if b.appConfig != nil {
+		token = b.appConfig.Git.Token
+	}
+	if token == "" {
+		reply("❌ Issues can't be filed because no GitHub token is configured.")
+		return
+	}
+
+	progressTS, err := b.postMessage(channelID, threadTS, fmt.Sprintf("📝 _Writing a GitHub issue for `%s` from this thread..._", repo))
+	if err != nil {
+		slog.Error("Failed to post issue progress", "error", err)
+	}
+	finish := func(text string) {
+		if progressTS == "" {
+			reply(text)
+			return
+		}
+		if err := b.updateMessage(channelID, progressTS, text); err != nil {
+			slog.Error("Failed to update issue progress", "error", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(b.ctx, issueDraftTimeout)
+	defer cancel()
+	draft, err := b.claudeService.DraftIssue(ctx, session.SessionID)
+	if err != nil {
+		slog.Error("Failed to draft issue", "session_id", session.SessionID, "error", err)
+		finish("❌ Claude couldn't write an issue from this thread. Please try again.")
+		return
+	}
+
+	body := draft.Markdown() + fmt.Sprintf("\n---\n*Filed from Slack by %s with /flow issue*\n", b.approverName(userID))
+	issueURL, err := createGitHubIssue(ctx, token, repo, draft.Title, body)
+	if err != nil {
+		slog.Error("Failed to create GitHub issue", "repo", repo, "session_id", session.SessionID, "error", err)
+		finish(fmt.Sprintf("❌ Failed to open the issue in `%s`: %v", repo, err))
+		return
+	}
+
+	slog.Info("Filed GitHub issue from thread",
+		"session_id", session.SessionID,
+		"repo", repo,
+		"issue_url", issueURL,
+		"user_id", userID,
+		"action", "issue_created",
+	)
+	b.audit(userID, channelID, threadTS, auditIssueCreated, map[string]interface{}{
+		"session_id": session.SessionID,
+		"repo":       repo,
+		"url":        issueURL,
+		"title":      draft.Title,
+	})
+	finish(fmt.Sprintf("✅ Filed <%s|%s> in `%s`.", issueURL, draft.Title, repo))
+}
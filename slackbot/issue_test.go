@@ -0,0 +1,73 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseGitHubRepo(t *testing.T) {
+	tests := []struct {
+		input string
+		repo  string
+		ok    bool
+	}{
+		{"breadchris/flow", "breadchris/flow", true},
+		{"https://github.com/breadchris/flow", "breadchris/flow", true},
+		{"https://github.com/breadchris/flow.git/", "breadchris/flow", true},
+		{"git@github.com:breadchris/flow.git", "breadchris/flow", true},
+		{"flow", "", false},
+		{"breadchris/flow/issues", "", false},
+		{"not a repo", "", false},
+	}
+	for _, tt := range tests {
+		repo, ok := parseGitHubRepo(tt.input)
+		if repo != tt.repo || ok != tt.ok {
+			t.Errorf("parseGitHubRepo(%q) = %q, %v, expected %q, %v", tt.input, repo, ok, tt.repo, tt.ok)
+		}
+	}
+}
+
+func TestCreateGitHubIssue(t *testing.T) {
+	var gotPath, gotAuth string
+	var gotIssue map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&gotIssue); err != nil {
+			t.Errorf("failed to decode issue: %v", err)
+		}
+		if gotIssue["title"] == "forbidden" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message":"Resource not accessible by integration"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"html_url":"https://github.com/acme/api/issues/7"}`))
+	}))
+	defer server.Close()
+	original := githubAPIURL
+	githubAPIURL = server.URL
+	defer func() { githubAPIURL = original }()
+
+	url, err := createGitHubIssue(context.Background(), "token", "acme/api", "Login fails", "## Summary")
+	if err != nil {
+		t.Fatalf("createGitHubIssue() failed: %v", err)
+	}
+	if url != "https://github.com/acme/api/issues/7" {
+		t.Errorf("url = %q", url)
+	}
+	if gotPath != "/repos/acme/api/issues" || gotAuth != "Bearer token" {
+		t.Errorf("request = %s with %q", gotPath, gotAuth)
+	}
+	if gotIssue["title"] != "Login fails" || gotIssue["body"] != "## Summary" {
+		t.Errorf("issue = %v", gotIssue)
+	}
+
+	_, err = createGitHubIssue(context.Background(), "token", "acme/api", "forbidden", "")
+	if err == nil || !strings.Contains(err.Error(), "Resource not accessible") {
+		t.Errorf("createGitHubIssue() error = %v, expected GitHub's message", err)
+	}
+}
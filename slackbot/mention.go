@@ -0,0 +1,192 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// handleThreadMention answers a mention of the bot in a thread. A thread with
+// a Claude session gets the message in that session, a thread whose session
+// ended gets a new one that picks up its conversation, and any other thread
+// gets a new session that starts from the thread's earlier messages. Files
+// shared with the mention are saved first and listed after the message.
+func (b *SlackBot) handleThreadMention(ev *slackevents.AppMentionEvent, files []slackevents.File, role userRole, text string) {
+	if b.rateLimited(ev.User, ev.Channel, ev.ThreadTimeStamp) {
+		return
+	}
+
+	// Track user activity for context management
+	if b.contextManager != nil {
+		b.contextManager.TrackUserActivity(ev.User, ev.ThreadTimeStamp)
+		if b.contextManager.ShouldShowContext(ev.User, ev.ThreadTimeStamp) {
+			go b.handleContextDisplay(ev.User, ev.Channel, ev.ThreadTimeStamp)
+		}
+	}
+
+	if strings.HasPrefix(text, "/flow") {
+		b.handleFlowInThreadMessage(&slackevents.MessageEvent{
+			User:            ev.User,
+			Channel:         ev.Channel,
+			ThreadTimeStamp: ev.ThreadTimeStamp,
+			Text:            text,
+		})
+		return
+	}
+
+	session, exists := b.getSession(ev.ThreadTimeStamp)
+	if exists && !session.Active {
+		slog.Warn("Attempted to send message to inactive session",
+			"session_id", session.SessionID,
+			"thread_ts", ev.ThreadTimeStamp)
+		if _, err := b.postMessage(ev.Channel, ev.ThreadTimeStamp,
+			"⚠️ _Claude session is currently inactive. Use `/flow <your message>` to start a new conversation._"); err != nil {
+			slog.Error("Failed to post inactive session message", "error", err)
+		}
+		return
+	}
+	if !exists {
+		// Ideation threads only take /flow commands
+		if _, ideation := b.ideationManager.GetSession(ev.ThreadTimeStamp); ideation {
+			return
+		}
+		text += b.mentionFilesNote(ev, files)
+		if restored, prompt, ok := b.takeOverThread(ev.User, ev.Channel, ev.ThreadTimeStamp, text); ok {
+			if restored != nil {
+				b.streamClaudeInteraction(restored, prompt)
+			}
+			return
+		}
+		b.startMentionSession(ev, role, text)
+		return
+	}
+
+	processedText, err := b.preprocessMessage(ev.Text, ev.User)
+	if err != nil {
+		slog.Error("Failed to preprocess message", "error", err)
+		if _, err := b.postMessage(ev.Channel, ev.ThreadTimeStamp,
+			"❌ _Unable to process your message. Please try rephrasing._"); err != nil {
+			slog.Error("Failed to post preprocessing error message", "error", err)
+		}
+		return
+	}
+	if len(strings.TrimSpace(processedText)) < 2 {
+		if _, err := b.postMessage(ev.Channel, ev.ThreadTimeStamp,
+			"🤔 _Your message seems a bit short. Could you provide more detail?_"); err != nil {
+			slog.Error("Failed to post short message feedback", "error", err)
+		}
+		return
+	}
+	processedText += b.mentionFilesNote(ev, files)
+
+	b.updateSessionActivity(ev.ThreadTimeStamp)
+	if b.config.Debug {
+		slog.Debug("Handling thread mention",
+			"user_id", ev.User,
+			"channel_id", ev.Channel,
+			"thread_ts", ev.ThreadTimeStamp,
+			"session_id", session.SessionID,
+			"text_length", len(processedText))
+	}
+	b.sendToClaudeSessionWithTyping(session, processedText)
+}
+
+// mentionFilesNote saves the files shared with a mention for Claude, and
+// lists them for the mention's message
+func (b *SlackBot) mentionFilesNote(ev *slackevents.AppMentionEvent, files []slackevents.File) string {
+	if len(files) == 0 {
+		return ""
+	}
+	return uploadedFilesNote(b.ingestThreadFiles(files, ev.User, ev.Channel, ev.ThreadTimeStamp, false))
+}
+
+// mentionFiles returns the files shared with an app_mention event.
+// slackevents.AppMentionEvent leaves them out, so they're read from the raw
+// event.
+func mentionFiles(event slackevents.EventsAPIEvent) []slackevents.File {
+	callback, ok := event.Data.(*slackevents.EventsAPICallbackEvent)
+	if !ok || callback.InnerEvent == nil {
+		return nil
+	}
+	var mention struct {
+		Files []slackevents.File `json:"files"`
+	}
+	if err := json.Unmarshal(*callback.InnerEvent, &mention); err != nil {
+		slog.Error("Failed to read files shared with mention", "error", err)
+		return nil
+	}
+	return mention.Files
+}
+
+// startMentionSession starts a Claude session in a thread the bot was
+// mentioned in, giving Claude the thread's earlier messages with the request
+func (b *SlackBot) startMentionSession(ev *slackevents.AppMentionEvent, role userRole, text string) {
+	var thread []slack.Message
+	if b.config.MentionContextMessages > 0 {
+		thread = b.threadMessagesBefore(ev.Channel, ev.ThreadTimeStamp, ev.TimeStamp, b.config.MentionContextMessages)
+	}
+	prompt := mentionPrompt(text, thread)
+
+	slog.Info("Starting Claude session from a thread mention",
+		"user_id", ev.User,
+		"channel_id", ev.Channel,
+		"thread_ts", ev.ThreadTimeStamp,
+		"thread_messages", len(thread),
+		"action", "thread_mention_session",
+	)
+
+	statusTS, err := b.postMessage(ev.Channel, ev.ThreadTimeStamp, "🤖 Starting Claude session...")
+	if err != nil {
+		slog.Error("Failed to reply in thread for app mention", "error", err, "thread_ts", ev.ThreadTimeStamp)
+		return
+	}
+	opts := role.sessionOptions()
+	session, err := b.createClaudeSession(ev.User, ev.Channel, ev.ThreadTimeStamp, opts...)
+	if err != nil {
+		b.updateMessage(ev.Channel, statusTS, startFailedMessage)
+		b.reportStartFailure(ev.User, ev.Channel, ev.ThreadTimeStamp, prompt, opts, err)
+		return
+	}
+	b.streamClaudeInteraction(session, prompt)
+}
+
+// mentionPrompt is the first prompt of a session started by a mention in a
+// thread: the request, after the thread's earlier messages when there are any
+func mentionPrompt(text string, thread []slack.Message) string {
+	if len(thread) == 0 {
+		return text
+	}
+	return fmt.Sprintf("You were mentioned in a Slack thread. Its earlier messages, oldest first:\n%s\nThe request:\n%s", threadTranscript(thread), text)
+}
+
+// rateLimited tells a user who sent too many messages when they can send the
+// next one, and reports whether they did
+func (b *SlackBot) rateLimited(userID, channelID, threadTS string) bool {
+	allowed, resetTime := b.rateLimiter.CheckRateLimit(userID)
+	if allowed {
+		return false
+	}
+
+	timeToReset := time.Until(resetTime)
+	minutes := int(timeToReset.Minutes())
+	seconds := int(timeToReset.Seconds()) % 60
+	resetMessage := fmt.Sprintf("⏱️ _Rate limit exceeded. Please wait %ds before sending another message._", seconds)
+	if minutes > 0 {
+		resetMessage = fmt.Sprintf("⏱️ _Rate limit exceeded. Please wait %dm %ds before sending another message._", minutes, seconds)
+	}
+	if _, err := b.postMessage(channelID, threadTS, resetMessage); err != nil {
+		slog.Error("Failed to post rate limit message", "error", err)
+	}
+	if b.config.Debug {
+		slog.Debug("Message rate limited",
+			"user_id", userID,
+			"thread_ts", threadTS,
+			"time_to_reset", timeToReset)
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/claude"
+	"github.com/breadchris/flow/config"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+func TestMentionPrompt(t *testing.T) {
+	if prompt := mentionPrompt("summarize this", nil); prompt != "summarize this" {
+		t.Errorf("mentionPrompt without a thread = %q, want the request alone", prompt)
+	}
+
+	thread := []slack.Message{
+		{Msg: slack.Msg{User: "UOTHER", Text: "The cache keeps filling up"}},
+		{Msg: slack.Msg{User: "UTHIRD", Text: "Only on the staging hosts"}},
+	}
+	prompt := mentionPrompt("summarize this", thread)
+	for _, want := range []string{
+		"<thread>\n<@UOTHER>: The cache keeps filling up\n<@UTHIRD>: Only on the staging hosts\n</thread>",
+		"The request:\nsummarize this",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt is missing %q:\n%s", want, prompt)
+		}
+	}
+	if strings.Index(prompt, "<thread>") > strings.Index(prompt, "summarize this") {
+		t.Errorf("thread should come before the request:\n%s", prompt)
+	}
+}
+
+func TestMentionFiles(t *testing.T) {
+	payload := `{
+		"type": "event_callback",
+		"event": {
+			"type": "app_mention",
+			"user": "U1",
+			"text": "<@UBOT> what's in this?",
+			"ts": "1700000001.000200",
+			"thread_ts": "1700000000.000100",
+			"channel": "C1",
+			"files": [{"id": "F1", "name": "notes.txt"}, {"id": "F2", "name": "plot.png"}]
+		}
+	}`
+	event, err := slackevents.ParseEvent(json.RawMessage(payload), slackevents.OptionNoVerifyToken())
+	if err != nil {
+		t.Fatalf("ParseEvent() failed: %v", err)
+	}
+	files := mentionFiles(event)
+	if len(files) != 2 || files[0].ID != "F1" || files[1].ID != "F2" {
+		t.Errorf("mentionFiles() = %+v, want F1 and F2", files)
+	}
+
+	if files := mentionFiles(slackevents.EventsAPIEvent{}); files != nil {
+		t.Errorf("mentionFiles() without a callback = %+v, want none", files)
+	}
+}
+
+func TestThreadMentionInInactiveSession(t *testing.T) {
+	var posted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.postMessage" {
+			t.Errorf("unexpected Slack call %s", r.URL.Path)
+		}
+		posted = append(posted, r.FormValue("text"))
+		w.Write([]byte(`{"ok":true,"channel":"C1","ts":"1700000002.000300"}`))
+	}))
+	defer server.Close()
+
+	threadTS := "1700000000.000100"
+	bot := &SlackBot{
+		client:      slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/")),
+		config:      &config.SlackBotConfig{},
+		rateLimiter: NewMessageRateLimiter(10, time.Minute),
+		sessions: map[string]*SlackClaudeSession{
+			threadTS: {ThreadTS: threadTS, ChannelID: "C1", UserID: "U1", SessionID: "session-1", Process: &claude.Process{}},
+		},
+	}
+	ev := &slackevents.AppMentionEvent{User: "U1", Channel: "C1", ThreadTimeStamp: threadTS, Text: "<@UBOT> keep going"}
+	// Files shared with the mention aren't fetched for a session that ended
+	bot.handleThreadMention(ev, []slackevents.File{{ID: "F1"}}, roleDeveloper, "keep going")
+
+	if len(posted) != 1 || !strings.Contains(posted[0], "session is currently inactive") {
+		t.Errorf("posted %q, want the inactive session message", posted)
+	}
+}
@@ -0,0 +1,132 @@
+package slackbot
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// askClaudeCallbackID is the callback ID of the "Ask Claude about this" message shortcut
+const askClaudeCallbackID = "ask_claude"
+
+const (
+	askClaudeThreadLimit = 50   // Most earlier thread messages sent along with the message
+	askClaudeMessageMax  = 2000 // Longest earlier thread message kept, in bytes
+)
+
+// askClaudeAboutMessage starts a Claude session about a message from the
+// "Ask Claude about this" shortcut and replies in the message's thread. A
+// thread that already has a session gets the question in that session.
+func (b *SlackBot) askClaudeAboutMessage(callback *slack.InteractionCallback) {
+	channelID := callback.Channel.ID
+	userID := callback.User.ID
+	message := callback.Message
+	threadTS := message.ThreadTimestamp
+	if threadTS == "" {
+		threadTS = message.Timestamp
+	}
+
+	role := b.userRole(userID)
+	if role == roleNone {
+		b.denyUser(channelID, threadTS, userID, notAuthorizedMessage)
+		return
+	}
+	if !b.canUseThread(userID, threadTS) {
+		b.denyUser(channelID, threadTS, userID, sessionNotOwnedMessage)
+		return
+	}
+
+	var thread []slack.Message
+	if message.ThreadTimestamp != "" {
+		thread = b.threadMessagesBefore(channelID, threadTS, message.Timestamp, askClaudeThreadLimit)
+	}
+	prompt := askClaudePrompt(userID, message, thread)
+
+	slog.Info("Asked Claude about a Slack message",
+		"user_id", userID,
+		"channel_id", channelID,
+		"thread_ts", threadTS,
+		"thread_messages", len(thread),
+		"action", "message_action_ask_claude",
+	)
+
+	if session, exists := b.getSession(threadTS); exists {
+		b.sendToClaudeSession(session, prompt)
+		return
+	}
+
+	if _, err := b.postMessage(channelID, threadTS,
+		fmt.Sprintf("🤖 _<@%s> asked Claude about this message. Starting Claude session..._", userID)); err != nil {
+		slog.Error("Failed to post message action acknowledgment", "error", err)
+		return
+	}
+	opts := role.sessionOptions()
+	session, err := b.createClaudeSession(userID, channelID, threadTS, opts...)
+	if err != nil {
+		if _, err := b.postMessage(channelID, threadTS, startFailedMessage); err != nil {
+			slog.Error("Failed to post session failure", "error", err)
+		}
+		b.reportStartFailure(userID, channelID, threadTS, prompt, opts, err)
+		return
+	}
+	b.streamClaudeInteraction(session, prompt)
+}
+
+// threadMessagesBefore returns up to limit of the messages in a thread that
+// were posted before ts, oldest first
+func (b *SlackBot) threadMessagesBefore(channelID, threadTS, ts string, limit int) []slack.Message {
+	replies, _, _, err := b.client.GetConversationReplies(&slack.GetConversationRepliesParameters{
+		ChannelID: channelID,
+		Timestamp: threadTS,
+		Latest:    ts,
+		Limit:     limit + 1,
+	})
+	if err != nil {
+		slog.Error("Failed to read thread", "channel_id", channelID, "thread_ts", threadTS, "error", err)
+		return nil
+	}
+
+	var thread []slack.Message
+	for _, reply := range replies {
+		if reply.Timestamp < ts {
+			thread = append(thread, reply)
+		}
+	}
+	return thread[max(0, len(thread)-limit):]
+}
+
+// askClaudePrompt asks Claude about a message, with the thread messages
+// before it as context
+func askClaudePrompt(userID string, message slack.Message, thread []slack.Message) string {
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "<@%s> wants your help with this Slack message", userID)
+	if message.User != "" {
+		fmt.Fprintf(&prompt, " from <@%s>", message.User)
+	}
+	fmt.Fprintf(&prompt, ":\n<message>\n%s\n</message>\n", message.Text)
+
+	if len(thread) > 0 {
+		prompt.WriteString("\nThe earlier messages in its thread, oldest first:\n" + threadTranscript(thread))
+	}
+
+	prompt.WriteString("\nExplain what the message is about and help with it: answer its questions, look into the problems it describes, or review what it proposes.")
+	return prompt.String()
+}
+
+// threadTranscript lays out thread messages for Claude, each clipped to
+// askClaudeMessageMax bytes
+func threadTranscript(thread []slack.Message) string {
+	var transcript strings.Builder
+	transcript.WriteString("<thread>\n")
+	for _, reply := range thread {
+		author := reply.User
+		if author == "" {
+			author = reply.BotID
+		}
+		fmt.Fprintf(&transcript, "<@%s>: %s\n", author, clipBytes(reply.Text, askClaudeMessageMax))
+	}
+	transcript.WriteString("</thread>\n")
+	return transcript.String()
+}
@@ -0,0 +1,34 @@
+package slackbot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestAskClaudePrompt(t *testing.T) {
+	message := slack.Message{Msg: slack.Msg{User: "UAUTHOR", Text: "Why does the deploy fail?"}}
+	thread := []slack.Message{
+		{Msg: slack.Msg{User: "UOTHER", Text: "Deploys started failing this morning"}},
+		{Msg: slack.Msg{BotID: "BBUILD", Text: strings.Repeat("x", askClaudeMessageMax+100)}},
+	}
+
+	prompt := askClaudePrompt("UASKER", message, thread)
+	for _, want := range []string{
+		"<@UASKER> wants your help with this Slack message from <@UAUTHOR>",
+		"<message>\nWhy does the deploy fail?\n</message>",
+		"<thread>\n<@UOTHER>: Deploys started failing this morning\n<@BBUILD>: ",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt is missing %q:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, strings.Repeat("x", askClaudeMessageMax+1)) {
+		t.Errorf("long thread message wasn't clipped")
+	}
+
+	if prompt := askClaudePrompt("UASKER", message, nil); strings.Contains(prompt, "<thread>") {
+		t.Errorf("prompt without a thread has a thread block:\n%s", prompt)
+	}
+}
@@ -0,0 +1,199 @@
+package slackbot
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/breadchris/flow/metrics"
+	"github.com/slack-go/slack"
+)
+
+const (
+	outboxInterval = time.Second // Slack allows about one message per second in each channel
+	outboxRetries  = 3           // Times a rate-limited message is retried before giving up
+)
+
+// outbox sends the bot's messages to each Slack channel one at a time, no
+// faster than Slack allows, retrying them when Slack rate limits them.
+// Consecutive notices to the same thread, such as a burst of tool updates,
+// are combined into one message.
+type outbox struct {
+	postText func(channel, threadTS, text string) error // Posts combined notices
+	interval time.Duration
+
+	mu     sync.Mutex
+	queues map[string]*channelQueue
+}
+
+// channelQueue holds the messages waiting to be sent to one channel
+type channelQueue struct {
+	pending  []*outboundMessage
+	draining bool      // Whether a goroutine is sending the pending messages
+	lastSent time.Time // When a message was last sent, or Slack's rate limit lifts
+}
+
+// outboundMessage is either a message whose sender waits for the result, or
+// a notice that nobody waits for and may be combined with its neighbours
+type outboundMessage struct {
+	send func() (string, error) // Sends the message; nil for a notice
+	done chan outboundResult
+
+	threadTS, text string // A notice's thread and text
+}
+
+type outboundResult struct {
+	timestamp string
+	err       error
+}
+
+func newOutbox(postText func(channel, threadTS, text string) error) *outbox {
+	return &outbox{
+		postText: postText,
+		interval: outboxInterval,
+		queues:   make(map[string]*channelQueue),
+	}
+}
+
+// send queues a message for a channel and waits for send to be called for
+// it, returning the message's timestamp. Without an outbox the message is
+// sent right away.
+func (o *outbox) send(channel string, send func() (string, error)) (string, error) {
+	if o == nil {
+		return send()
+	}
+	message := &outboundMessage{send: send, done: make(chan outboundResult, 1)}
+	o.enqueue(channel, message)
+	result := <-message.done
+	return result.timestamp, result.err
+}
+
+// notify queues a notice for a thread without waiting for it to be posted
+func (o *outbox) notify(channel, threadTS, text string) {
+	o.enqueue(channel, &outboundMessage{threadTS: threadTS, text: text})
+}
+
+func (o *outbox) enqueue(channel string, message *outboundMessage) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	queue, ok := o.queues[channel]
+	if !ok {
+		queue = &channelQueue{}
+		o.queues[channel] = queue
+	}
+	queue.pending = append(queue.pending, message)
+	if !queue.draining {
+		queue.draining = true
+		go o.drain(channel, queue)
+	}
+}
+
+// drain sends a channel's pending messages in order until none are left
+func (o *outbox) drain(channel string, queue *channelQueue) {
+	for {
+		o.mu.Lock()
+		if len(queue.pending) == 0 {
+			queue.draining = false
+			o.mu.Unlock()
+			return
+		}
+		batch := takeBatch(queue)
+		wait := time.Until(queue.lastSent.Add(o.interval))
+		o.mu.Unlock()
+
+		time.Sleep(wait)
+		o.deliver(channel, queue, batch)
+	}
+}
+
+// takeBatch removes the next message from a queue, along with the notices
+// right behind it when it's a notice to the same thread that fit in one
+// Slack message
+func takeBatch(queue *channelQueue) []*outboundMessage {
+	first := queue.pending[0]
+	n, size := 1, len(first.text)
+	for first.send == nil && n < len(queue.pending) {
+		next := queue.pending[n]
+		if next.send != nil || next.threadTS != first.threadTS || size+1+len(next.text) > streamMessageLimit {
+			break
+		}
+		size += 1 + len(next.text)
+		n++
+	}
+	batch := queue.pending[:n:n]
+	queue.pending = queue.pending[n:]
+	return batch
+}
+
+// deliver sends a batch, retrying while Slack rate limits it
+func (o *outbox) deliver(channel string, queue *channelQueue, batch []*outboundMessage) {
+	send := batch[0].send
+	if send == nil {
+		texts := make([]string, len(batch))
+		for i, notice := range batch {
+			texts[i] = notice.text
+		}
+		text := strings.Join(texts, "\n")
+		send = func() (string, error) {
+			return "", o.postText(channel, batch[0].threadTS, text)
+		}
+	}
+
+	var result outboundResult
+	for attempt := 0; ; attempt++ {
+		result.timestamp, result.err = send()
+
+		o.mu.Lock()
+		queue.lastSent = time.Now()
+		var rateLimited *slack.RateLimitedError
+		retry := errors.As(result.err, &rateLimited) && attempt < outboxRetries
+		if retry {
+			queue.lastSent = queue.lastSent.Add(rateLimited.RetryAfter)
+		}
+		o.mu.Unlock()
+		if !retry {
+			break
+		}
+
+		slog.Warn("Slack rate limited a message, retrying",
+			"channel_id", channel,
+			"retry_after", rateLimited.RetryAfter,
+			"attempt", attempt+1,
+			"action", "slack_rate_limited",
+		)
+		time.Sleep(rateLimited.RetryAfter)
+	}
+
+	if batch[0].done != nil {
+		batch[0].done <- result
+	} else if result.err != nil {
+		slog.Error("Failed to post notice", "channel_id", channel, "thread_ts", batch[0].threadTS, "notices", len(batch), "error", result.err)
+	}
+}
+
+// queueMessage posts a notice to a thread without waiting for it, combined
+// with any other notices queued for the thread in the meantime
+func (b *SlackBot) queueMessage(channel, threadTS, text string) {
+	if b.outbox == nil {
+		if err := b.postNotice(channel, threadTS, text); err != nil {
+			slog.Error("Failed to post notice", "channel_id", channel, "thread_ts", threadTS, "error", err)
+		}
+		return
+	}
+	b.outbox.notify(channel, threadTS, text)
+}
+
+// postNotice posts queued notices for the outbox
+func (b *SlackBot) postNotice(channel, threadTS, text string) error {
+	_, _, err := b.client.PostMessage(channel,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionAsUser(true),
+		slack.MsgOptionTS(threadTS),
+	)
+	if err != nil {
+		metrics.SlackAPIErrorsTotal.WithLabelValues("chat.postMessage").Inc()
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package slackbot
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+func TestOutboxCombinesNotices(t *testing.T) {
+	var mu sync.Mutex
+	var posted []string
+	o := newOutbox(func(channel, threadTS, text string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		posted = append(posted, threadTS+": "+text)
+		return nil
+	})
+	o.interval = 10 * time.Millisecond
+
+	// The first message holds up the notices behind it so they're sent together
+	sending, release := make(chan struct{}), make(chan struct{})
+	go o.send("C1", func() (string, error) {
+		close(sending)
+		<-release
+		return "1.0", nil
+	})
+	<-sending
+	o.notify("C1", "T1", "one")
+	o.notify("C1", "T1", "two")
+	o.notify("C1", "T2", "three")
+	close(release)
+
+	ts, err := o.send("C1", func() (string, error) { return "2.0", nil })
+	if ts != "2.0" || err != nil {
+		t.Errorf("send() = %q, %v; want 2.0", ts, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"T1: one\ntwo", "T2: three"}
+	if len(posted) != len(want) || posted[0] != want[0] || posted[1] != want[1] {
+		t.Errorf("posted %q; want %q", posted, want)
+	}
+}
+
+func TestOutboxRetriesRateLimitedMessages(t *testing.T) {
+	o := newOutbox(nil)
+	o.interval = time.Millisecond
+
+	attempts := 0
+	ts, err := o.send("C1", func() (string, error) {
+		attempts++
+		if attempts < 3 {
+			return "", &slack.RateLimitedError{RetryAfter: 5 * time.Millisecond}
+		}
+		return "1.0", nil
+	})
+	if ts != "1.0" || err != nil || attempts != 3 {
+		t.Errorf("send() = %q, %v after %d attempts; want 1.0 after 3", ts, err, attempts)
+	}
+
+	attempts = 0
+	_, err = o.send("C1", func() (string, error) {
+		attempts++
+		return "", &slack.RateLimitedError{RetryAfter: time.Millisecond}
+	})
+	if err == nil || attempts != outboxRetries+1 {
+		t.Errorf("send() = %v after %d attempts; want a rate limit error after %d", err, attempts, outboxRetries+1)
+	}
+}
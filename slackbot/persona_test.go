@@ -0,0 +1,26 @@
+package slackbot
+
+import "testing"
+
+func TestParsePersonaFlag(t *testing.T) {
+	tests := []struct {
+		text    string
+		persona string
+		prompt  string
+		ok      bool
+	}{
+		{"--persona reviewer Check the auth code", "reviewer", "Check the auth code", true},
+		{"--persona=sre  Why is the API slow?", "sre", "Why is the API slow?", true},
+		{"--persona reviewer", "reviewer", "", true},
+		{"--personas are fun", "", "--personas are fun", false},
+		{"Explain --persona flags", "", "Explain --persona flags", false},
+	}
+
+	for _, tt := range tests {
+		persona, prompt, ok := parsePersonaFlag(tt.text)
+		if persona != tt.persona || prompt != tt.prompt || ok != tt.ok {
+			t.Errorf("parsePersonaFlag(%q) = %q, %q, %v; want %q, %q, %v",
+				tt.text, persona, prompt, ok, tt.persona, tt.prompt, tt.ok)
+		}
+	}
+}
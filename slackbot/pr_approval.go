@@ -0,0 +1,197 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/metrics"
+	"github.com/breadchris/flow/worklet"
+	"github.com/slack-go/slack"
+)
+
+// Action IDs of the buttons that decide whether a worklet's changes become a
+// pull request. Their value is the thread's timestamp and the worklet ID,
+// separated by a space.
+const (
+	approvePRActionID = "worklet_pr_approve"
+	discardPRActionID = "worklet_pr_discard"
+)
+
+// postPullRequestApproval asks a thread whether to push a running worklet's
+// changes and open a pull request for them
+func (b *SlackBot) postPullRequestApproval(channelID, threadTS string, workletObj *worklet.Worklet) {
+	text := fmt.Sprintf("🔀 _Worklet *%s* is ready. Open a pull request with its changes?_", workletObj.Name)
+	if diff, err := b.workletManager.Diff(b.ctx, workletObj); err != nil {
+		slog.Warn("Failed to diff worklet", "worklet_id", workletObj.ID, "error", err)
+	} else {
+		text += "\n\n" + diffSummary(diff)
+	}
+	_, _, err := b.client.PostMessage(channelID,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionBlocks(pullRequestApprovalBlocks(text, threadTS, workletObj.ID)...),
+		slack.MsgOptionTS(threadTS),
+		slack.MsgOptionAsUser(true),
+	)
+	if err != nil {
+		metrics.SlackAPIErrorsTotal.WithLabelValues("chat.postMessage").Inc()
+		slog.Error("Failed to post pull request approval", "thread_ts", threadTS, "error", err)
+	}
+}
+
+// maxSummaryFiles is how many changed files a diff summary lists
+const maxSummaryFiles = 10
+
+// diffSummary describes a worklet's changes for Slack: how many files changed
+// and the lines added and removed from each
+func diffSummary(diff *worklet.Diff) string {
+	if len(diff.Files) == 0 {
+		return "_No files have changed._"
+	}
+	var summary strings.Builder
+	noun := "files"
+	if len(diff.Files) == 1 {
+		noun = "file"
+	}
+	fmt.Fprintf(&summary, "📝 *%d %s changed* (+%d −%d)", len(diff.Files), noun, diff.Additions, diff.Deletions)
+	for i, file := range diff.Files {
+		if i == maxSummaryFiles {
+			fmt.Fprintf(&summary, "\n…and %d more", len(diff.Files)-maxSummaryFiles)
+			break
+		}
+		if file.Binary {
+			fmt.Fprintf(&summary, "\n• `%s` (binary)", file.Path)
+		} else {
+			fmt.Fprintf(&summary, "\n• `%s` +%d −%d", file.Path, file.Additions, file.Deletions)
+		}
+	}
+	return summary.String()
+}
+
+// pullRequestApprovalBlocks renders the Create PR and Discard buttons for a worklet
+func pullRequestApprovalBlocks(text, threadTS, workletID string) []slack.Block {
+	value := threadTS + " " + workletID
+
+	create := slack.NewButtonBlockElement(approvePRActionID, value,
+		slack.NewTextBlockObject(slack.PlainTextType, "Create PR", false, false))
+	create.Style = slack.StylePrimary
+	discard := slack.NewButtonBlockElement(discardPRActionID, value,
+		slack.NewTextBlockObject(slack.PlainTextType, "Discard", false, false))
+	discard.Style = slack.StyleDanger
+
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+		slack.NewActionBlock("", create, discard),
+	}
+}
+
+// decidePullRequest creates or discards a worklet's pull request from a
+// button press. Only the first press counts, so a double click can't open
+// two pull requests.
+func (b *SlackBot) decidePullRequest(callback *slack.InteractionCallback, action *slack.BlockAction) {
+	threadTS, workletID, _ := strings.Cut(action.Value, " ")
+	userID := callback.User.ID
+
+	if _, decided := b.prDecisions.LoadOrStore(workletID, userID); decided {
+		b.resolveControls(callback, "_This pull request was already decided._")
+		return
+	}
+	if b.workletManager == nil {
+		b.resolveControls(callback, "There is no worklet for this thread to open a pull request from.")
+		return
+	}
+	workletObj, err := b.workletManager.GetWorklet(workletID)
+	if err != nil {
+		slog.Error("Failed to get worklet for pull request", "worklet_id", workletID, "error", err)
+		b.resolveControls(callback, "There is no worklet for this thread to open a pull request from.")
+		return
+	}
+
+	if action.ActionID == discardPRActionID {
+		b.resolveControls(callback, fmt.Sprintf("🗑️ _<@%s> discarded the changes. No pull request was created._", userID))
+		slog.Info("Discarded worklet pull request",
+			"worklet_id", workletID,
+			"user_id", userID,
+			"action", "pr_discarded",
+		)
+		return
+	}
+
+	b.resolveControls(callback, fmt.Sprintf("🔄 _<@%s> approved. Creating a pull request..._", userID))
+	slog.Info("Approved worklet pull request",
+		"worklet_id", workletID,
+		"user_id", userID,
+		"action", "pr_approved",
+	)
+	b.createPullRequestForWorklet(context.Background(), workletObj, callback.Channel.ID, threadTS, workletObj.BasePrompt, userID)
+}
+
+// approverName returns how the PR description names the Slack user who
+// approved it, falling back to their ID when their profile can't be loaded
+func (b *SlackBot) approverName(userID string) string {
+	user, err := b.client.GetUserInfo(userID)
+	if err != nil {
+		slog.Error("Failed to get approving Slack user", "user_id", userID, "error", err)
+		return userID
+	}
+	name := user.RealName
+	if name == "" {
+		name = user.Name
+	}
+	return fmt.Sprintf("%s (%s)", name, userID)
+}
+
+// notifyPullRequests posts in the thread a worklet was created from when its
+// pull request is merged or closed or its checks finish
+func (b *SlackBot) notifyPullRequests(ctx context.Context) {
+	if b.workletManager == nil {
+		return
+	}
+	statuses, unsubscribe := events.Channel(b.events, events.TopicPRStatus)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case status := <-statuses:
+			text := pullRequestStatusMessage(status)
+			if text == "" {
+				continue
+			}
+			workletObj, err := b.workletManager.GetWorklet(status.WorkletID)
+			if err != nil || workletObj.Environment == nil {
+				continue
+			}
+			channelID := workletObj.Environment.Data["SLACK_CHANNEL"]
+			threadTS := workletObj.Environment.Data["SLACK_THREAD_TS"]
+			if channelID == "" || threadTS == "" {
+				continue
+			}
+			if _, err := b.postMessage(channelID, threadTS, text); err != nil {
+				slog.Error("Failed to post pull request status", "error", err, "worklet_id", status.WorkletID)
+			}
+		}
+	}
+}
+
+// pullRequestStatusMessage describes a change to a worklet's pull request, or
+// returns "" for changes not worth a message, such as checks starting
+func pullRequestStatusMessage(status events.PRStatus) string {
+	link := fmt.Sprintf("<%s|#%d>", status.URL, status.Number)
+	switch {
+	case status.State != status.PreviousState && status.State == string(worklet.PRMerged):
+		return fmt.Sprintf("🟣 Pull request %s was merged.", link)
+	case status.State != status.PreviousState && status.State == string(worklet.PRClosed):
+		return fmt.Sprintf("⚪ Pull request %s was closed without merging.", link)
+	case status.State != string(worklet.PROpen) || status.Checks == status.PreviousChecks:
+		return ""
+	case status.Checks == string(worklet.ChecksPassed):
+		return fmt.Sprintf("✅ Checks passed on pull request %s.", link)
+	case status.Checks == string(worklet.ChecksFailed):
+		return fmt.Sprintf("❌ Checks failed on pull request %s.", link)
+	}
+	return ""
+}
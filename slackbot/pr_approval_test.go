@@ -0,0 +1,102 @@
+package slackbot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/models"
+	"github.com/breadchris/flow/worklet"
+	"github.com/slack-go/slack"
+)
+
+func TestPullRequestApprovalBlocks(t *testing.T) {
+	blocks := pullRequestApprovalBlocks("Open a pull request?", "1700000000.000100", "worklet-1")
+	if len(blocks) != 2 {
+		t.Fatalf("pullRequestApprovalBlocks() returned %d blocks; want 2", len(blocks))
+	}
+
+	actions := blocks[1].(*slack.ActionBlock).Elements.ElementSet
+	if len(actions) != 2 {
+		t.Fatalf("approval has %d buttons; want 2", len(actions))
+	}
+	for i, actionID := range []string{approvePRActionID, discardPRActionID} {
+		button := actions[i].(*slack.ButtonBlockElement)
+		if button.ActionID != actionID || button.Value != "1700000000.000100 worklet-1" {
+			t.Errorf("button %d is %s with value %q", i, button.ActionID, button.Value)
+		}
+	}
+}
+
+func TestPullRequestDescriptionRecordsApprover(t *testing.T) {
+	w := &worklet.Worklet{Model: models.Model{ID: "worklet-1"}, GitRepo: "https://github.com/user/repo.git"}
+
+	description := pullRequestDescription(w, "Add dark mode", "Chris (U0123ABC)")
+	if !strings.Contains(description, "**Approved By:** Chris (U0123ABC)") {
+		t.Errorf("description doesn't name the approver:\n%s", description)
+	}
+}
+
+func TestPullRequestActionsNeedDevelopers(t *testing.T) {
+	bot := &SlackBot{config: &config.SlackBotConfig{
+		Roles:       map[string]string{"UDEV": "developer", "UREADER": "read_only"},
+		DefaultRole: "none",
+	}}
+	for _, actionID := range []string{approvePRActionID, discardPRActionID} {
+		action := &slack.BlockAction{ActionID: actionID, Value: "1700000000.000100 worklet-1"}
+		if problem := bot.actionProblem("UDEV", action); problem != "" {
+			t.Errorf("developer can't press %s: %s", actionID, problem)
+		}
+		if problem := bot.actionProblem("UREADER", action); problem != developersOnlyMessage {
+			t.Errorf("read-only user pressing %s got %q", actionID, problem)
+		}
+	}
+}
+
+func TestDiffSummary(t *testing.T) {
+	if got := diffSummary(&worklet.Diff{}); got != "_No files have changed._" {
+		t.Errorf("diffSummary() of no changes = %q", got)
+	}
+
+	diff := &worklet.Diff{Additions: 12, Deletions: 3, Files: []worklet.FileDiff{
+		{Path: "src/app.js", Additions: 10, Deletions: 3},
+		{Path: "public/logo.png", Binary: true},
+		{Path: "README.md", Additions: 2},
+	}}
+	want := "📝 *3 files changed* (+12 −3)\n• `src/app.js` +10 −3\n• `public/logo.png` (binary)\n• `README.md` +2 −0"
+	if got := diffSummary(diff); got != want {
+		t.Errorf("diffSummary() = %q; want %q", got, want)
+	}
+
+	diff = &worklet.Diff{}
+	for i := 0; i < maxSummaryFiles+2; i++ {
+		diff.Files = append(diff.Files, worklet.FileDiff{Path: "file.txt", Additions: 1})
+	}
+	if got := diffSummary(diff); !strings.HasSuffix(got, "\n…and 2 more") || strings.Count(got, "• ") != maxSummaryFiles {
+		t.Errorf("diffSummary() of many files = %q; want %d files listed and the rest counted", got, maxSummaryFiles)
+	}
+}
+
+func TestPullRequestStatusMessage(t *testing.T) {
+	base := events.PRStatus{URL: "https://github.com/acme/site/pull/7", Number: 7, PreviousState: "open"}
+	tests := []struct {
+		name           string
+		state, checks  string
+		previousChecks string
+		want           string
+	}{
+		{"merged", "merged", "passed", "passed", "🟣 Pull request <https://github.com/acme/site/pull/7|#7> was merged."},
+		{"closed", "closed", "", "", "⚪ Pull request <https://github.com/acme/site/pull/7|#7> was closed without merging."},
+		{"checks passed", "open", "passed", "pending", "✅ Checks passed on pull request <https://github.com/acme/site/pull/7|#7>."},
+		{"checks failed", "open", "failed", "", "❌ Checks failed on pull request <https://github.com/acme/site/pull/7|#7>."},
+		{"checks started", "open", "pending", "", ""},
+	}
+	for _, tt := range tests {
+		status := base
+		status.State, status.Checks, status.PreviousChecks = tt.state, tt.checks, tt.previousChecks
+		if got := pullRequestStatusMessage(status); got != tt.want {
+			t.Errorf("%s: pullRequestStatusMessage() = %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package slackbot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRestoreSessions_Integration(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.SlackSession{}); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	sessionDB := NewSessionDBService(db, false)
+
+	// Sessions saved by the bot before it restarted
+	for i, threadTS := range []string{"1.000001", "1.000002", "1.000003"} {
+		err := sessionDB.SetSession(&SlackClaudeSession{
+			ThreadTS:     threadTS,
+			ChannelID:    "C1234567890",
+			UserID:       "U1234567890",
+			SessionID:    "session-" + threadTS,
+			LastActivity: time.Now().Add(-time.Duration(i) * time.Hour),
+			Active:       true,
+		})
+		if err != nil {
+			t.Fatalf("Failed to store session: %v", err)
+		}
+	}
+	if err := sessionDB.RemoveSession("1.000002"); err != nil {
+		t.Fatalf("Failed to remove session: %v", err)
+	}
+
+	bot := &SlackBot{
+		sessionDB:    sessionDB,
+		sessionCache: NewSlackBotSessionCache(),
+		config:       &config.SlackBotConfig{MaxSessions: 10},
+		sessions:     make(map[string]*SlackClaudeSession),
+	}
+	bot.restoreSessions()
+
+	if len(bot.sessions) != 2 {
+		t.Fatalf("Restored %d sessions; want the 2 active ones", len(bot.sessions))
+	}
+	session, exists := bot.sessionByID("session-1.000003")
+	if !exists || session.ThreadTS != "1.000003" || session.Process != nil {
+		t.Errorf("Restored session = %+v", session)
+	}
+	if _, exists := bot.sessionCache.GetSession("1.000001"); !exists {
+		t.Error("Restored session should be in the session cache")
+	}
+
+	// Only the most recently active sessions are kept in memory
+	bot = &SlackBot{
+		sessionDB:    sessionDB,
+		sessionCache: NewSlackBotSessionCache(),
+		config:       &config.SlackBotConfig{MaxSessions: 1},
+		sessions:     make(map[string]*SlackClaudeSession),
+	}
+	bot.restoreSessions()
+	if _, exists := bot.sessions["1.000001"]; !exists || len(bot.sessions) != 1 {
+		t.Errorf("Restored %d sessions; want only the latest", len(bot.sessions))
+	}
+}
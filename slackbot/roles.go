@@ -0,0 +1,317 @@
+package slackbot
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/breadchris/flow/claude"
+	"github.com/breadchris/flow/models"
+	"github.com/google/uuid"
+	"github.com/slack-go/slack"
+	"gorm.io/gorm"
+)
+
+// userRole is what a Slack user may do with the bot
+type userRole string
+
+const (
+	roleNone      userRole = "none"      // May not use the bot
+	roleReadOnly  userRole = "read_only" // Starts sessions that can only read files
+	roleDeveloper userRole = "developer" // Starts sessions with every tool and worklets, and approves commands
+	roleAdmin     userRole = "admin"     // A developer who also changes roles and channel settings
+)
+
+// roleRanks orders the roles from least to most trusted
+var roleRanks = map[userRole]int{roleNone: 0, roleReadOnly: 1, roleDeveloper: 2, roleAdmin: 3}
+
+const (
+	notAuthorizedMessage   = "🔒 You aren't authorized to use Claude here. Ask a Slack bot admin for access."
+	sessionNotOwnedMessage = "🔒 Read-only users can only use the Claude sessions they started."
+	developersOnlyMessage  = "🔒 Only developers can do that."
+)
+
+// slackUserIDRegex matches a Slack user ID, bare or as a mention
+var slackUserIDRegex = regexp.MustCompile(`^<?@?([UW][A-Z0-9]+)(?:\|[^>]*)?>?$`)
+
+// parseRole reads a role's name, accepting read-only for read_only. Names
+// that aren't roles read as none.
+func parseRole(s string) (userRole, bool) {
+	role := userRole(strings.ReplaceAll(strings.ToLower(strings.TrimSpace(s)), "-", "_"))
+	if _, ok := roleRanks[role]; !ok {
+		return roleNone, false
+	}
+	return role, true
+}
+
+// atLeast reports whether the role is as trusted as min
+func (r userRole) atLeast(min userRole) bool {
+	return roleRanks[r] >= roleRanks[min]
+}
+
+// sessionOptions returns the options a session started by someone with the role gets
+func (r userRole) sessionOptions() []claude.SessionOption {
+	if r == roleReadOnly {
+		return []claude.SessionOption{claude.ReadOnly()}
+	}
+	return nil
+}
+
+// parseSlackUserID reads a user ID typed as U0123 or as a mention like <@U0123|name>
+func parseSlackUserID(s string) (string, bool) {
+	match := slackUserIDRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// UserRoleStore keeps the roles admins grant with /flow admin grant
+type UserRoleStore struct {
+	db *gorm.DB
+}
+
+func NewUserRoleStore(db *gorm.DB) *UserRoleStore {
+	return &UserRoleStore{db: db}
+}
+
+// Get returns a user's granted role, or nil when they have none
+func (s *UserRoleStore) Get(userID string) (*models.SlackUserRole, error) {
+	var grant models.SlackUserRole
+	err := s.db.Where("user_id = ?", userID).First(&grant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user role: %w", err)
+	}
+	return &grant, nil
+}
+
+// Grant gives a user a role, replacing any they were granted before
+func (s *UserRoleStore) Grant(userID string, role userRole, grantedBy string) error {
+	grant, err := s.Get(userID)
+	if err != nil {
+		return err
+	}
+	created := grant == nil
+	if created {
+		grant = &models.SlackUserRole{Model: models.Model{ID: uuid.NewString()}, UserID: userID}
+	}
+	grant.Role = string(role)
+	grant.GrantedBy = grantedBy
+
+	if created {
+		err = s.db.Create(grant).Error
+	} else {
+		err = s.db.Save(grant).Error
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save user role: %w", err)
+	}
+	return nil
+}
+
+// Revoke removes a user's granted role
+func (s *UserRoleStore) Revoke(userID string) error {
+	if err := s.db.Unscoped().Where("user_id = ?", userID).Delete(&models.SlackUserRole{}).Error; err != nil {
+		return fmt.Errorf("failed to revoke user role: %w", err)
+	}
+	return nil
+}
+
+// List returns every granted role
+func (s *UserRoleStore) List() ([]models.SlackUserRole, error) {
+	var grants []models.SlackUserRole
+	if err := s.db.Order("user_id").Find(&grants).Error; err != nil {
+		return nil, fmt.Errorf("failed to list user roles: %w", err)
+	}
+	return grants, nil
+}
+
+// userRole returns a Slack user's role. Configured admins are always admins;
+// otherwise a granted role wins over the configured one, and users with
+// neither get the default role. An unknown role counts as none.
+func (b *SlackBot) userRole(userID string) userRole {
+	if slices.Contains(b.config.Admins, userID) {
+		return roleAdmin
+	}
+	if b.userRoles != nil {
+		grant, err := b.userRoles.Get(userID)
+		if err != nil {
+			slog.Error("Failed to load user role", "user_id", userID, "error", err)
+			return roleNone
+		}
+		if grant != nil {
+			role, _ := parseRole(grant.Role)
+			return role
+		}
+	}
+	if name, ok := b.config.Roles[userID]; ok {
+		role, _ := parseRole(name)
+		return role
+	}
+	return b.defaultRole()
+}
+
+// defaultRole returns the role of users who weren't given one, developer
+// when it isn't configured
+func (b *SlackBot) defaultRole() userRole {
+	if b.config.DefaultRole == "" {
+		return roleDeveloper
+	}
+	role, _ := parseRole(b.config.DefaultRole)
+	return role
+}
+
+// canUseSession reports whether a user may send messages to a session.
+// Read-only users may only use the sessions they started, which are read-only.
+func (b *SlackBot) canUseSession(userID string, session *SlackClaudeSession) bool {
+	role := b.userRole(userID)
+	return role.atLeast(roleDeveloper) || (role == roleReadOnly && session.UserID == userID)
+}
+
+// canUseThread reports whether a user may act on the session in a thread. A
+// thread without a session is left to the action to report.
+func (b *SlackBot) canUseThread(userID, threadTS string) bool {
+	session, exists := b.getSession(threadTS)
+	return !exists || b.canUseSession(userID, session)
+}
+
+// actionProblem explains why a user may not press a button, or returns ""
+// when they may. Approving commands and opening pull requests takes a developer.
+func (b *SlackBot) actionProblem(userID string, action *slack.BlockAction) string {
+	switch action.ActionID {
+	case approveActionID, denyActionID, createPRActionID, approvePRActionID, discardPRActionID:
+		if !b.userRole(userID).atLeast(roleDeveloper) {
+			return developersOnlyMessage
+		}
+	case retryStartActionID:
+		if b.userRole(userID) == roleNone {
+			return notAuthorizedMessage
+		}
+	case continueActionID, continueTurnActionID, stopSessionActionID, cancelQueuedTurnActionID:
+		if b.userRole(userID) == roleNone {
+			return notAuthorizedMessage
+		}
+		threadTS, _, _ := strings.Cut(action.Value, " ")
+		if !b.canUseThread(userID, threadTS) {
+			return sessionNotOwnedMessage
+		}
+	}
+	return ""
+}
+
+// denyUser tells a user, and only them, that they may not do something
+func (b *SlackBot) denyUser(channelID, threadTS, userID, text string) {
+	options := []slack.MsgOption{slack.MsgOptionText(text, false)}
+	if threadTS != "" {
+		options = append(options, slack.MsgOptionTS(threadTS))
+	}
+	if _, err := b.client.PostEphemeral(channelID, userID, options...); err != nil {
+		slog.Error("Failed to tell user they aren't authorized", "user_id", userID, "error", err)
+	}
+	slog.Info("Denied Slack user",
+		"user_id", userID,
+		"channel_id", channelID,
+		"thread_ts", threadTS,
+		"action", "user_denied",
+	)
+}
+
+// flowAdminReply runs /flow admin and returns the reply
+func (b *SlackBot) flowAdminReply(userID, channelID string, args []string) string {
+	usage := "Usage: `/flow admin roles`, `/flow admin grant <@user> <admin|developer|read_only|none>`, `/flow admin revoke <@user>`, or `/flow admin channels [#channel]`."
+	if b.userRole(userID) != roleAdmin {
+		return "Only Slack bot admins can manage roles and channels."
+	}
+	if strings.EqualFold(args[0], "channels") {
+		if len(args) > 2 {
+			return usage
+		}
+		return b.describeChannelPolicy(channelID, args[1:])
+	}
+	if b.userRoles == nil {
+		return "❌ Roles can't be granted here."
+	}
+
+	action := strings.ToLower(args[0])
+	if action == "roles" {
+		return b.describeRoles()
+	}
+	if (action != "grant" || len(args) != 3) && (action != "revoke" || len(args) != 2) {
+		return usage
+	}
+	target, ok := parseSlackUserID(args[1])
+	if !ok {
+		return fmt.Sprintf("%q isn't a Slack user. %s", args[1], usage)
+	}
+	if slices.Contains(b.config.Admins, target) {
+		return fmt.Sprintf("<@%s> is an admin in the bot's configuration, so their role can't be changed here.", target)
+	}
+
+	if action == "revoke" {
+		if err := b.userRoles.Revoke(target); err != nil {
+			slog.Error("Failed to revoke user role", "user_id", target, "error", err)
+			return "❌ Failed to revoke the role. Please try again."
+		}
+		slog.Info("Revoked Slack user role",
+			"user_id", target,
+			"revoked_by", userID,
+			"action", "role_revoked",
+		)
+		return fmt.Sprintf("✅ Revoked <@%s>'s role. They're now %s.", target, b.userRole(target))
+	}
+
+	role, ok := parseRole(args[2])
+	if !ok {
+		return fmt.Sprintf("%q isn't a role. %s", args[2], usage)
+	}
+	if err := b.userRoles.Grant(target, role, userID); err != nil {
+		slog.Error("Failed to grant user role", "user_id", target, "role", role, "error", err)
+		return "❌ Failed to grant the role. Please try again."
+	}
+	slog.Info("Granted Slack user role",
+		"user_id", target,
+		"role", role,
+		"granted_by", userID,
+		"action", "role_granted",
+	)
+	return fmt.Sprintf("✅ <@%s> is now %s.", target, role)
+}
+
+// describeRoles lists who has which role
+func (b *SlackBot) describeRoles() string {
+	grants, err := b.userRoles.List()
+	if err != nil {
+		slog.Error("Failed to list user roles", "error", err)
+		return "❌ Failed to list roles. Please try again."
+	}
+
+	roles := make(map[string]string)
+	for userID, role := range b.config.Roles {
+		roles[userID] = role
+	}
+	for _, grant := range grants {
+		roles[grant.UserID] = grant.Role
+	}
+	for _, userID := range b.config.Admins {
+		roles[userID] = string(roleAdmin)
+	}
+	userIDs := make([]string, 0, len(roles))
+	for userID := range roles {
+		userIDs = append(userIDs, userID)
+	}
+	slices.Sort(userIDs)
+
+	var reply strings.Builder
+	reply.WriteString("*Slack bot roles*\n")
+	for _, userID := range userIDs {
+		fmt.Fprintf(&reply, "• <@%s>: %s\n", userID, roles[userID])
+	}
+	fmt.Fprintf(&reply, "Everyone else: %s", b.defaultRole())
+	return reply.String()
+}
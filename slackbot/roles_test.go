@@ -0,0 +1,117 @@
+package slackbot
+
+import (
+	"testing"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestParseRole(t *testing.T) {
+	tests := []struct {
+		name string
+		role userRole
+		ok   bool
+	}{
+		{"admin", roleAdmin, true},
+		{"Developer", roleDeveloper, true},
+		{"read-only", roleReadOnly, true},
+		{"read_only", roleReadOnly, true},
+		{"none", roleNone, true},
+		{"owner", roleNone, false},
+		{"", roleNone, false},
+	}
+	for _, tt := range tests {
+		if role, ok := parseRole(tt.name); role != tt.role || ok != tt.ok {
+			t.Errorf("parseRole(%q) = %q, %v; want %q, %v", tt.name, role, ok, tt.role, tt.ok)
+		}
+	}
+}
+
+func TestParseSlackUserID(t *testing.T) {
+	for _, s := range []string{"U0123ABC", "<@U0123ABC>", "<@U0123ABC|chris>", "@U0123ABC"} {
+		if id, ok := parseSlackUserID(s); !ok || id != "U0123ABC" {
+			t.Errorf("parseSlackUserID(%q) = %q, %v", s, id, ok)
+		}
+	}
+	for _, s := range []string{"chris", "<#C0123ABC>", "u0123abc"} {
+		if id, ok := parseSlackUserID(s); ok {
+			t.Errorf("parseSlackUserID(%q) = %q; want no user", s, id)
+		}
+	}
+}
+
+func TestUserRole(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.SlackUserRole{}); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	bot := &SlackBot{
+		config: &config.SlackBotConfig{
+			Admins:      []string{"UADMIN"},
+			Roles:       map[string]string{"UDEV": "developer", "UREADER": "read_only", "UTYPO": "devloper"},
+			DefaultRole: "none",
+		},
+		userRoles: NewUserRoleStore(db),
+	}
+	want := map[string]userRole{
+		"UADMIN":   roleAdmin,
+		"UDEV":     roleDeveloper,
+		"UREADER":  roleReadOnly,
+		"UTYPO":    roleNone,
+		"USTRANGE": roleNone,
+	}
+	for userID, role := range want {
+		if got := bot.userRole(userID); got != role {
+			t.Errorf("userRole(%s) = %q; want %q", userID, got, role)
+		}
+	}
+
+	// Grants replace configured roles but never demote configured admins
+	if err := bot.userRoles.Grant("UREADER", roleDeveloper, "UADMIN"); err != nil {
+		t.Fatalf("Grant() = %v", err)
+	}
+	if err := bot.userRoles.Grant("UADMIN", roleNone, "UADMIN"); err != nil {
+		t.Fatalf("Grant() = %v", err)
+	}
+	if bot.userRole("UREADER") != roleDeveloper || bot.userRole("UADMIN") != roleAdmin {
+		t.Errorf("after grants, UREADER is %q and UADMIN is %q", bot.userRole("UREADER"), bot.userRole("UADMIN"))
+	}
+	if err := bot.userRoles.Revoke("UREADER"); err != nil {
+		t.Fatalf("Revoke() = %v", err)
+	}
+	if role := bot.userRole("UREADER"); role != roleReadOnly {
+		t.Errorf("after revoking, UREADER is %q; want the configured read_only", role)
+	}
+
+	// Without a default role, everyone may use the bot as before roles existed
+	bot.config.DefaultRole = ""
+	if role := bot.userRole("USTRANGE"); role != roleDeveloper {
+		t.Errorf("userRole() without a default = %q; want developer", role)
+	}
+}
+
+func TestCanUseSession(t *testing.T) {
+	bot := &SlackBot{config: &config.SlackBotConfig{
+		Roles:       map[string]string{"UDEV": "developer", "UREADER": "read_only"},
+		DefaultRole: "none",
+	}}
+	own := &SlackClaudeSession{UserID: "UREADER"}
+	other := &SlackClaudeSession{UserID: "UDEV"}
+
+	if !bot.canUseSession("UDEV", own) || !bot.canUseSession("UDEV", other) {
+		t.Error("developers should be able to use any session")
+	}
+	if !bot.canUseSession("UREADER", own) || bot.canUseSession("UREADER", other) {
+		t.Error("read-only users should only be able to use their own sessions")
+	}
+	if bot.canUseSession("USTRANGE", own) {
+		t.Error("users without a role shouldn't be able to use sessions")
+	}
+}
@@ -0,0 +1,357 @@
+package slackbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/breadchris/flow/claude"
+	"github.com/breadchris/flow/cron"
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// scheduleCheckInterval is how often the scheduler looks for prompts that are due
+const scheduleCheckInterval = time.Minute
+
+// scheduleUsage explains /flow schedule
+const scheduleUsage = "Usage: `/flow schedule \"<minute> <hour> <day> <month> <weekday>\" <prompt>`, such as `/flow schedule \"0 9 * * 1\" Summarize last week's commits`, `/flow schedule list`, or `/flow schedule delete <id>`."
+
+// ScheduleStore keeps the prompts scheduled with /flow schedule
+type ScheduleStore struct {
+	db *gorm.DB
+}
+
+func NewScheduleStore(db *gorm.DB) *ScheduleStore {
+	return &ScheduleStore{db: db}
+}
+
+// Create saves a new schedule
+func (s *ScheduleStore) Create(schedule *models.SlackSchedule) error {
+	schedule.ID = uuid.NewString()
+	if err := s.db.Create(schedule).Error; err != nil {
+		return fmt.Errorf("failed to save schedule: %w", err)
+	}
+	return nil
+}
+
+// Save updates a schedule after it runs
+func (s *ScheduleStore) Save(schedule *models.SlackSchedule) error {
+	if err := s.db.Save(schedule).Error; err != nil {
+		return fmt.Errorf("failed to save schedule: %w", err)
+	}
+	return nil
+}
+
+// Get returns a schedule, or nil when there is none with the ID
+func (s *ScheduleStore) Get(id string) (*models.SlackSchedule, error) {
+	var schedule models.SlackSchedule
+	err := s.db.Where("id = ?", id).First(&schedule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule: %w", err)
+	}
+	return &schedule, nil
+}
+
+// ByJob returns the schedule whose most recent run started a job, or nil when
+// the job wasn't scheduled
+func (s *ScheduleStore) ByJob(jobID string) (*models.SlackSchedule, error) {
+	var schedule models.SlackSchedule
+	err := s.db.Where("last_job_id = ?", jobID).First(&schedule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule: %w", err)
+	}
+	return &schedule, nil
+}
+
+// List returns a channel's schedules, soonest first
+func (s *ScheduleStore) List(channelID string) ([]models.SlackSchedule, error) {
+	var schedules []models.SlackSchedule
+	if err := s.db.Where("channel_id = ?", channelID).Order("next_run_at").Find(&schedules).Error; err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+// Due returns the schedules whose next run is at or before now
+func (s *ScheduleStore) Due(now time.Time) ([]models.SlackSchedule, error) {
+	var schedules []models.SlackSchedule
+	if err := s.db.Where("next_run_at <= ?", now).Order("next_run_at").Find(&schedules).Error; err != nil {
+		return nil, fmt.Errorf("failed to list due schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+// Delete removes a schedule
+func (s *ScheduleStore) Delete(id string) error {
+	if err := s.db.Unscoped().Where("id = ?", id).Delete(&models.SlackSchedule{}).Error; err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	return nil
+}
+
+// startsWithQuote reports whether s starts with a straight or curly double quote
+func startsWithQuote(s string) bool {
+	return strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "“")
+}
+
+// parseScheduleArgs splits the text after /flow schedule into its quoted
+// cron expression and the prompt after it. Slack's curly quotes work too.
+func parseScheduleArgs(text string) (expr, prompt string, ok bool) {
+	text = strings.TrimSpace(text)
+	rest, found := strings.CutPrefix(text, `"`)
+	if !found {
+		if rest, found = strings.CutPrefix(text, "“"); !found {
+			return "", "", false
+		}
+	}
+	end := strings.IndexAny(rest, `"”`)
+	if end < 0 {
+		return "", "", false
+	}
+	expr = strings.TrimSpace(rest[:end])
+	_, size := utf8.DecodeRuneInString(rest[end:])
+	prompt = strings.TrimSpace(rest[end+size:])
+	return expr, prompt, expr != "" && prompt != ""
+}
+
+// flowScheduleReply runs /flow schedule and returns the reply
+func (b *SlackBot) flowScheduleReply(userID, channelID string, sub flowSubcommand) string {
+	if b.schedules == nil {
+		return "❌ Prompts can't be scheduled here."
+	}
+	switch strings.ToLower(sub.args[0]) {
+	case "list":
+		return b.describeSchedules(channelID)
+	case "delete":
+		if len(sub.args) != 2 {
+			return scheduleUsage
+		}
+		return b.deleteSchedule(userID, sub.args[1])
+	}
+
+	if !b.userRole(userID).atLeast(roleDeveloper) {
+		return developersOnlyMessage
+	}
+	expr, prompt, ok := parseScheduleArgs(sub.rest)
+	if !ok {
+		return scheduleUsage
+	}
+	parsed, err := cron.Parse(expr)
+	if err != nil {
+		return fmt.Sprintf("❌ %s. %s", err, scheduleUsage)
+	}
+	next := parsed.Next(time.Now())
+	if next.IsZero() {
+		return fmt.Sprintf("❌ `%s` never runs. %s", expr, scheduleUsage)
+	}
+
+	schedule := &models.SlackSchedule{
+		ChannelID: channelID,
+		UserID:    userID,
+		Cron:      expr,
+		Prompt:    prompt,
+		NextRunAt: next,
+	}
+	if err := b.schedules.Create(schedule); err != nil {
+		slog.Error("Failed to create schedule", "user_id", userID, "channel_id", channelID, "error", err)
+		return "❌ Failed to schedule the prompt. Please try again."
+	}
+	slog.Info("Scheduled Slack prompt",
+		"schedule_id", schedule.ID,
+		"user_id", userID,
+		"channel_id", channelID,
+		"cron", expr,
+		"action", "schedule_created",
+	)
+	return fmt.Sprintf("⏰ Scheduled `%s` to run `%s` in this channel, next at %s. Remove it with `/flow schedule delete %s`.",
+		schedule.ID, expr, next.Format(time.DateTime), schedule.ID)
+}
+
+// describeSchedules lists a channel's schedules
+func (b *SlackBot) describeSchedules(channelID string) string {
+	schedules, err := b.schedules.List(channelID)
+	if err != nil {
+		slog.Error("Failed to list schedules", "channel_id", channelID, "error", err)
+		return "❌ Failed to list schedules. Please try again."
+	}
+	if len(schedules) == 0 {
+		return "Nothing is scheduled in this channel. " + scheduleUsage
+	}
+
+	var reply strings.Builder
+	reply.WriteString("*Scheduled prompts in this channel*\n")
+	for _, schedule := range schedules {
+		fmt.Fprintf(&reply, "• `%s` `%s` by <@%s>, next at %s: %s\n",
+			schedule.ID, schedule.Cron, schedule.UserID, schedule.NextRunAt.Format(time.DateTime), clipBytes(schedule.Prompt, 100))
+	}
+	return reply.String()
+}
+
+// deleteSchedule removes a schedule, which only its creator and admins may do
+func (b *SlackBot) deleteSchedule(userID, id string) string {
+	schedule, err := b.schedules.Get(id)
+	if err != nil {
+		slog.Error("Failed to get schedule", "schedule_id", id, "error", err)
+		return "❌ Failed to delete the schedule. Please try again."
+	}
+	if schedule == nil {
+		return fmt.Sprintf("There is no schedule `%s`. See `/flow schedule list` for this channel's schedules.", id)
+	}
+	if schedule.UserID != userID && b.userRole(userID) != roleAdmin {
+		return "Only the person who scheduled a prompt, or an admin, can delete it."
+	}
+	if err := b.schedules.Delete(id); err != nil {
+		slog.Error("Failed to delete schedule", "schedule_id", id, "error", err)
+		return "❌ Failed to delete the schedule. Please try again."
+	}
+	slog.Info("Deleted Slack schedule",
+		"schedule_id", id,
+		"user_id", userID,
+		"action", "schedule_deleted",
+	)
+	return fmt.Sprintf("🗑️ Deleted schedule `%s`.", id)
+}
+
+// runSchedules starts scheduled prompts as Claude jobs when they're due and
+// posts each job's result in the thread of the message announcing it, until
+// ctx is cancelled
+func (b *SlackBot) runSchedules(ctx context.Context) {
+	if b.schedules == nil || b.claudeService.Jobs() == nil {
+		return
+	}
+	finished, unsubscribe := events.Channel(b.events, events.TopicJobFinished)
+	defer unsubscribe()
+
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+
+	// Schedules that came due while the bot was down run once now. Results are
+	// handled on this goroutine too, so a job can't finish before its schedule
+	// records it.
+	b.runDueSchedules(time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			b.runDueSchedules(now)
+		case job := <-finished:
+			b.postScheduledResult(job)
+		}
+	}
+}
+
+// runDueSchedules starts every schedule that is due
+func (b *SlackBot) runDueSchedules(now time.Time) {
+	due, err := b.schedules.Due(now)
+	if err != nil {
+		slog.Error("Failed to load due schedules", "error", err)
+		return
+	}
+	for i := range due {
+		b.runSchedule(&due[i], now)
+	}
+}
+
+// runSchedule announces a scheduled prompt in its channel and starts it as a
+// Claude job with the channel's settings. The schedule moves on to its next
+// run even if this one can't start, so a failure isn't retried every minute.
+func (b *SlackBot) runSchedule(schedule *models.SlackSchedule, now time.Time) {
+	logger := slog.With("schedule_id", schedule.ID, "channel_id", schedule.ChannelID)
+
+	parsed, err := cron.Parse(schedule.Cron)
+	if err != nil {
+		logger.Error("Failed to parse schedule", "error", err)
+		return
+	}
+	schedule.NextRunAt = parsed.Next(now)
+	schedule.LastRunAt = &now
+	schedule.LastJobID = ""
+	schedule.LastThreadTS = ""
+	defer func() {
+		if err := b.schedules.Save(schedule); err != nil {
+			logger.Error("Failed to record schedule run", "error", err)
+		}
+	}()
+
+	// The channel or its owner may have lost access since the prompt was scheduled
+	if !b.isChannelAllowed(schedule.ChannelID) || !b.userRole(schedule.UserID).atLeast(roleDeveloper) {
+		logger.Warn("Skipped scheduled prompt that is no longer allowed", "user_id", schedule.UserID)
+		return
+	}
+
+	ts, err := b.postMessage(schedule.ChannelID, "",
+		fmt.Sprintf("⏰ _Running <@%s>'s scheduled prompt (`%s`):_ %s", schedule.UserID, schedule.ID, schedule.Prompt))
+	if err != nil {
+		logger.Error("Failed to announce scheduled prompt", "error", err)
+		return
+	}
+	schedule.LastThreadTS = ts
+
+	workspace := b.config.WorkingDirectory
+	switch settings := b.channelSettings(schedule.ChannelID); {
+	case settings.SharedWorkspace:
+		workspace = channelWorkspaceDir(schedule.ChannelID)
+	case settings.WorkingDir != "":
+		workspace = settings.WorkingDir
+	}
+	if err := os.MkdirAll(workspace, 0755); err != nil {
+		logger.Error("Failed to ensure schedule workspace", "workspace", workspace, "error", err)
+	}
+	opts := append(b.sessionOptions(schedule.ChannelID), claude.WithUser(schedule.UserID))
+	jobID, err := b.claudeService.Jobs().SubmitJob(schedule.Prompt, workspace, opts...)
+	if err != nil {
+		logger.Error("Failed to start scheduled prompt", "error", err)
+		if _, err := b.postMessage(schedule.ChannelID, ts, "❌ Failed to start Claude for this scheduled prompt."); err != nil {
+			logger.Error("Failed to post schedule failure", "error", err)
+		}
+		return
+	}
+	schedule.LastJobID = jobID
+
+	logger.Info("Started scheduled prompt",
+		"job_id", jobID,
+		"next_run_at", schedule.NextRunAt,
+		"action", "schedule_run",
+	)
+}
+
+// postScheduledResult posts a scheduled job's result in the thread that
+// announced it. Jobs that weren't scheduled are ignored.
+func (b *SlackBot) postScheduledResult(job events.JobFinished) {
+	schedule, err := b.schedules.ByJob(job.JobID)
+	if err != nil {
+		slog.Error("Failed to find schedule for job", "job_id", job.JobID, "error", err)
+		return
+	}
+	if schedule == nil || schedule.LastThreadTS == "" {
+		return
+	}
+
+	var reply string
+	switch job.Status {
+	case claude.JobSucceeded:
+		reply = b.formatClaudeResponse(job.Result)
+	case claude.JobCancelled:
+		reply = "⏹️ _This scheduled prompt was cancelled._"
+	default:
+		reply = fmt.Sprintf("❌ This scheduled prompt failed: %s", job.Error)
+	}
+	if _, err := b.postMessage(schedule.ChannelID, schedule.LastThreadTS, reply); err != nil {
+		slog.Error("Failed to post scheduled prompt result", "schedule_id", schedule.ID, "job_id", job.JobID, "error", err)
+	}
+}
@@ -0,0 +1,93 @@
+package slackbot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestParseScheduleArgs(t *testing.T) {
+	tests := []struct {
+		text, expr, prompt string
+		ok                 bool
+	}{
+		{`"0 9 * * 1" Summarize last week's commits`, "0 9 * * 1", "Summarize last week's commits", true},
+		{"“*/30 * * * *” Check the build", "*/30 * * * *", "Check the build", true},
+		{`"0 9 * * 1"`, "0 9 * * 1", "", false},
+		{`"0 9 * * 1 Summarize`, "", "", false},
+		{`0 9 * * 1 Summarize`, "", "", false},
+	}
+	for _, tt := range tests {
+		expr, prompt, ok := parseScheduleArgs(tt.text)
+		if expr != tt.expr || prompt != tt.prompt || ok != tt.ok {
+			t.Errorf("parseScheduleArgs(%q) = %q, %q, %v; want %q, %q, %v", tt.text, expr, prompt, ok, tt.expr, tt.prompt, tt.ok)
+		}
+	}
+}
+
+func TestFlowScheduleReply(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.SlackSchedule{}); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	bot := &SlackBot{
+		config: &config.SlackBotConfig{
+			Roles:       map[string]string{"UDEV": "developer", "UREADER": "read_only"},
+			DefaultRole: "none",
+		},
+		schedules: NewScheduleStore(db),
+	}
+	reply := func(userID, text string) string {
+		sub, ok := parseFlowSubcommand(text)
+		if !ok {
+			t.Fatalf("parseFlowSubcommand(%q) isn't a subcommand", text)
+		}
+		return bot.flowSubcommandReply(userID, "C1", sub)
+	}
+
+	if got := reply("UREADER", `schedule "0 9 * * 1" Summarize commits`); got != developersOnlyMessage {
+		t.Errorf("read-only user scheduling got %q", got)
+	}
+	if got := reply("UDEV", `schedule "0 25 * * 1" Summarize commits`); !strings.HasPrefix(got, "❌") {
+		t.Errorf("scheduling with a bad hour got %q", got)
+	}
+	if got := reply("UDEV", `schedule "0 9 * * 1" Summarize commits`); !strings.HasPrefix(got, "⏰ Scheduled") {
+		t.Fatalf("scheduling got %q", got)
+	}
+
+	schedules, err := bot.schedules.List("C1")
+	if err != nil || len(schedules) != 1 {
+		t.Fatalf("List() = %v, %v; want one schedule", schedules, err)
+	}
+	schedule := schedules[0]
+	if schedule.UserID != "UDEV" || schedule.Prompt != "Summarize commits" || schedule.NextRunAt.Weekday() != time.Monday {
+		t.Errorf("saved schedule = %+v", schedule)
+	}
+	if due, _ := bot.schedules.Due(schedule.NextRunAt.Add(-time.Minute)); len(due) != 0 {
+		t.Errorf("schedule is due before its next run")
+	}
+	if due, _ := bot.schedules.Due(schedule.NextRunAt); len(due) != 1 {
+		t.Errorf("schedule isn't due at its next run")
+	}
+
+	if got := reply("UDEV", "schedule list"); !strings.Contains(got, schedule.ID) {
+		t.Errorf("/flow schedule list = %q", got)
+	}
+	if got := reply("UREADER", "schedule delete "+schedule.ID); !strings.HasPrefix(got, "Only") {
+		t.Errorf("someone else deleting the schedule got %q", got)
+	}
+	if got := reply("UDEV", "schedule delete "+schedule.ID); !strings.HasPrefix(got, "🗑️") {
+		t.Errorf("deleting the schedule got %q", got)
+	}
+	if schedules, _ := bot.schedules.List("C1"); len(schedules) != 0 {
+		t.Errorf("schedule wasn't deleted")
+	}
+}
@@ -3,21 +3,31 @@ package slackbot
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/breadchris/flow/claude"
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/metrics"
+	"github.com/slack-go/slack"
 )
 
+// partialTextUpdateInterval limits how often a streaming response's Slack message is edited
+const partialTextUpdateInterval = 2 * time.Second
+
 // createClaudeSession initializes a new Claude session for a Slack thread
-func (b *SlackBot) createClaudeSession(userID, channelID, threadTS string) (*SlackClaudeSession, error) {
-	return b.resumeOrCreateSession(userID, channelID, threadTS)
+func (b *SlackBot) createClaudeSession(userID, channelID, threadTS string, opts ...claude.SessionOption) (*SlackClaudeSession, error) {
+	return b.resumeOrCreateSession(userID, channelID, threadTS, opts...)
 }
 
-// resumeOrCreateSession attempts to resume an existing session or creates a new one
-func (b *SlackBot) resumeOrCreateSession(userID, channelID, threadTS string) (*SlackClaudeSession, error) {
+// resumeOrCreateSession attempts to resume an existing session or creates a new one.
+// opts only apply to a new session; a resumed one keeps the options it was created with.
+func (b *SlackBot) resumeOrCreateSession(userID, channelID, threadTS string, opts ...claude.SessionOption) (*SlackClaudeSession, error) {
 	if err := os.MkdirAll(b.config.WorkingDirectory, 0755); err != nil && !os.IsExist(err) {
 		return nil, fmt.Errorf("failed to ensure working directory: %w", err)
 	}
@@ -29,6 +39,9 @@ func (b *SlackBot) resumeOrCreateSession(userID, channelID, threadTS string) (*S
 		// Continue to create new session
 	}
 
+	// Tells the thread its place in line if every Claude process is busy
+	notice := b.newQueueNotice(channelID, threadTS)
+
 	if sessionInfo != nil && sessionInfo.Active {
 		// Try to resume existing session
 		if b.config.Debug {
@@ -40,7 +53,11 @@ func (b *SlackBot) resumeOrCreateSession(userID, channelID, threadTS string) (*S
 
 		// If process doesn't exist in memory, try to resume it
 		if !sessionInfo.ProcessExists {
-			process, err := b.claudeService.ResumeSession(sessionInfo.SessionID, userID)
+			process, err := b.claudeService.ResumeSession(sessionInfo.SessionID, userID, notice.option())
+			notice.finish(err)
+			if errors.Is(err, claude.ErrQueueTimeout) {
+				return nil, fmt.Errorf("failed to resume Claude session: %w", err)
+			}
 			if err != nil {
 				slog.Warn("Failed to resume Claude session, creating new one",
 					"session_id", sessionInfo.SessionID,
@@ -120,7 +137,11 @@ func (b *SlackBot) resumeOrCreateSession(userID, channelID, threadTS string) (*S
 		}
 		
 		// Try to resume this session instead of creating a new one
-		process, err := b.claudeService.ResumeSession(existingSession.SessionID, userID)
+		process, err := b.claudeService.ResumeSession(existingSession.SessionID, userID, notice.option())
+		notice.finish(err)
+		if errors.Is(err, claude.ErrQueueTimeout) {
+			return nil, fmt.Errorf("failed to resume Claude session: %w", err)
+		}
 		if err == nil {
 			existingSession.Process = process
 			existingSession.Resumed = true
@@ -136,11 +157,17 @@ func (b *SlackBot) resumeOrCreateSession(userID, channelID, threadTS string) (*S
 		}
 	}
 
-	process, newSessionInfo, err := b.claudeService.CreateSessionWithPersistence(threadTS, channelID, userID, b.config.WorkingDirectory)
+	opts = append(append(b.sessionOptions(channelID), opts...), notice.option())
+	process, newSessionInfo, err := b.claudeService.CreateSessionWithPersistence(threadTS, channelID, userID, b.config.WorkingDirectory, opts...)
+	notice.finish(err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Claude session: %w", err)
 	}
 
+	workingDir := b.config.WorkingDirectory
+	if dir := b.channelSettings(channelID).WorkingDir; dir != "" {
+		workingDir = dir
+	}
 	session := &SlackClaudeSession{
 		ThreadTS:     threadTS,
 		ChannelID:    channelID,
@@ -148,7 +175,7 @@ func (b *SlackBot) resumeOrCreateSession(userID, channelID, threadTS string) (*S
 		SessionID:    newSessionInfo.SessionID,
 		ProcessID:    process.GetCorrelationID(),
 		LastActivity: time.Now(),
-		Context:      b.config.WorkingDirectory,
+		Context:      workingDir,
 		Active:       true,
 		Resumed:      false,
 		Process:      process,
@@ -190,6 +217,15 @@ func (b *SlackBot) resumeOrCreateSession(userID, channelID, threadTS string) (*S
 
 // streamClaudeInteraction handles the bidirectional communication with Claude
 func (b *SlackBot) streamClaudeInteraction(session *SlackClaudeSession, prompt string) {
+	run := func() { b.streamPrompt(session, prompt) }
+	if b.queueTurn(session, run) {
+		b.runTurns(session, run)
+	}
+}
+
+// streamPrompt sends a session's first prompt to Claude and streams its
+// response to the thread. The caller holds the thread's turn.
+func (b *SlackBot) streamPrompt(session *SlackClaudeSession, prompt string) {
 	if b.config.Debug {
 		slog.Debug("Starting Claude interaction",
 			"session_id", session.SessionID,
@@ -197,6 +233,12 @@ func (b *SlackBot) streamClaudeInteraction(session *SlackClaudeSession, prompt s
 			"resumed", session.Resumed)
 	}
 
+	done, ok := b.trackClaudeWork(session)
+	if !ok {
+		return
+	}
+	defer done()
+
 	ctx := context.Background()
 
 	// Use existing process or create/resume as needed
@@ -228,10 +270,354 @@ func (b *SlackBot) streamClaudeInteraction(session *SlackClaudeSession, prompt s
 	b.handleClaudeResponseStream(ctx, process, session)
 }
 
+// sessionOptions returns the Claude session options for a channel from its
+// settings. Read-only channels stay read-only whatever tools they allow.
+func (b *SlackBot) sessionOptions(channelID string) []claude.SessionOption {
+	settings := b.channelSettings(channelID)
+	readOnly := b.readOnlyChannels != nil && b.readOnlyChannels.IsAllowed(channelID)
+
+	var opts []claude.SessionOption
+	if len(settings.AllowedTools) > 0 {
+		opts = append(opts, claude.WithAllowedTools(settings.AllowedTools...))
+	}
+	if settings.Model != "" {
+		opts = append(opts, claude.WithModel(settings.Model))
+	}
+	if settings.WorkingDir != "" {
+		dir := claude.ReadWriteDir(settings.WorkingDir)
+		if readOnly {
+			dir = claude.ReadOnlyDir(settings.WorkingDir)
+		}
+		opts = append(opts, claude.WithDirectories(dir))
+	}
+	if settings.SharedWorkspace {
+		opts = append(opts, claude.WithSessionDir(channelWorkspaceDir(channelID)))
+	}
+	if readOnly {
+		opts = append(opts, claude.ReadOnly())
+	}
+	return opts
+}
+
+// queueNotice shows a thread its place in line while its Claude session waits for a free process
+type queueNotice struct {
+	bot       *SlackBot
+	channelID string
+	threadTS  string
+	messageTS string // Notice message, posted the first time the session is queued
+}
+
+func (b *SlackBot) newQueueNotice(channelID, threadTS string) *queueNotice {
+	return &queueNotice{bot: b, channelID: channelID, threadTS: threadTS}
+}
+
+// option reports the session's queue position to the thread
+func (n *queueNotice) option() claude.SessionOption {
+	return claude.WithQueueNotifier(n.update)
+}
+
+// update posts or edits the notice with the session's place in line
+func (n *queueNotice) update(position int) {
+	text := fmt.Sprintf("⏳ _All Claude sessions are busy. You're #%d in line and Claude will start as soon as one frees up..._", position)
+	if n.messageTS == "" {
+		timestamp, err := n.bot.postMessage(n.channelID, n.threadTS, text)
+		if err != nil {
+			slog.Error("Failed to post queue position", "thread_ts", n.threadTS, "error", err)
+			return
+		}
+		n.messageTS = timestamp
+		return
+	}
+	if err := n.bot.updateMessage(n.channelID, n.messageTS, text); err != nil {
+		slog.Error("Failed to update queue position", "thread_ts", n.threadTS, "error", err)
+	}
+}
+
+// finish edits the notice once the session has stopped waiting; it does nothing if the session was never queued
+func (n *queueNotice) finish(err error) {
+	if n.messageTS == "" {
+		return
+	}
+
+	text := "▶️ _A Claude session is free, starting yours..._"
+	switch {
+	case errors.Is(err, claude.ErrQueueTimeout):
+		text = "⌛ _Claude is still busy and your request timed out. Please try again in a few minutes._"
+	case err != nil:
+		text = "❌ _Stopped waiting for Claude._"
+	}
+	if err := n.bot.updateMessage(n.channelID, n.messageTS, text); err != nil {
+		slog.Error("Failed to update queue notice", "thread_ts", n.threadTS, "error", err)
+	}
+	n.messageTS = ""
+}
+
+// stopClaudeTurn interrupts the response Claude is writing in a thread without ending the session
+func (b *SlackBot) stopClaudeTurn(channelID, threadTS string) {
+	reply := "🛑 Stopped Claude. Send another message to continue the conversation."
+
+	session, exists := b.getSession(threadTS)
+	if !exists || session.Process == nil {
+		reply = "There is no running Claude session in this thread."
+	} else if err := b.claudeService.CancelTurn(session.SessionID); err != nil {
+		if errors.Is(err, claude.ErrNoActiveTurn) || errors.Is(err, claude.ErrSessionNotRunning) {
+			reply = "Claude isn't working on anything in this thread."
+		} else {
+			slog.Error("Failed to stop Claude turn", "error", err, "thread_ts", threadTS)
+			reply = "❌ Failed to stop Claude. Please try again."
+		}
+	}
+
+	if _, err := b.postMessage(channelID, threadTS, reply); err != nil {
+		slog.Error("Failed to post stop confirmation", "error", err)
+	}
+}
+
+// continueActionID is the action ID of the button that continues a session paused by its budget
+const continueActionID = "claude_budget_continue"
+
+// postBudgetPause tells a thread that its session reached limit, with a button to continue it
+func (b *SlackBot) postBudgetPause(channelID, threadTS, limit string) {
+	text := "⏸️ _Claude is paused because this session used up its budget._"
+	if limit != "" {
+		text = fmt.Sprintf("⏸️ _Claude is paused because this session reached %s._", limit)
+	}
+	text += " Press Continue or reply `/flow continue` to let it keep working."
+
+	button := slack.NewButtonBlockElement(continueActionID, threadTS,
+		slack.NewTextBlockObject(slack.PlainTextType, "Continue", false, false))
+	button.Style = slack.StylePrimary
+	_, _, err := b.client.PostMessage(channelID,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionBlocks(
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+			slack.NewActionBlock("", button),
+		),
+		slack.MsgOptionTS(threadTS),
+		slack.MsgOptionAsUser(true),
+	)
+	if err != nil {
+		metrics.SlackAPIErrorsTotal.WithLabelValues("chat.postMessage").Inc()
+		slog.Error("Failed to post budget pause notice", "thread_ts", threadTS, "error", err)
+	}
+}
+
+// continueClaudeSession gives a thread's session paused by its budget a fresh one
+func (b *SlackBot) continueClaudeSession(channelID, threadTS string) {
+	reply := "▶️ Claude has a fresh budget. Send a message to pick up where it left off."
+
+	session, exists := b.getSession(threadTS)
+	if !exists {
+		reply = "There is no Claude session in this thread."
+	} else if err := b.claudeService.ContinueSession(session.SessionID); err != nil && !errors.Is(err, claude.ErrSessionNotRunning) {
+		// A session that isn't running gets a fresh budget when the next message resumes it
+		slog.Error("Failed to continue Claude session", "error", err, "thread_ts", threadTS)
+		reply = "❌ Failed to continue the session. Please try again."
+	}
+
+	if _, err := b.postMessage(channelID, threadTS, reply); err != nil {
+		slog.Error("Failed to post continue confirmation", "error", err)
+	}
+}
+
+// Action IDs of the buttons that decide on a command held for approval. Their
+// value is the thread's timestamp and the tool use ID, separated by a space.
+const (
+	approveActionID = "claude_tool_approve"
+	denyActionID    = "claude_tool_deny"
+)
+
+// postApprovalRequest asks a thread to approve or deny a command Claude wants to run
+func (b *SlackBot) postApprovalRequest(channelID, threadTS string, approval *claude.ApprovalRequest) {
+	if approval == nil {
+		return
+	}
+	text := fmt.Sprintf("🛑 _Claude wants to run a command that needs approval:_\n```%s```", approval.Command)
+	value := threadTS + " " + approval.ToolUseID
+
+	approve := slack.NewButtonBlockElement(approveActionID, value,
+		slack.NewTextBlockObject(slack.PlainTextType, "Approve", false, false))
+	approve.Style = slack.StylePrimary
+	deny := slack.NewButtonBlockElement(denyActionID, value,
+		slack.NewTextBlockObject(slack.PlainTextType, "Deny", false, false))
+	deny.Style = slack.StyleDanger
+	_, _, err := b.client.PostMessage(channelID,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionBlocks(
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+			slack.NewActionBlock("", approve, deny),
+		),
+		slack.MsgOptionTS(threadTS),
+		slack.MsgOptionAsUser(true),
+	)
+	if err != nil {
+		metrics.SlackAPIErrorsTotal.WithLabelValues("chat.postMessage").Inc()
+		slog.Error("Failed to post approval request", "thread_ts", threadTS, "error", err)
+	}
+}
+
+// decideToolUse approves or denies a held command from a button press and
+// replaces the buttons with the decision
+func (b *SlackBot) decideToolUse(callback *slack.InteractionCallback, action *slack.BlockAction) {
+	threadTS, toolUseID, _ := strings.Cut(action.Value, " ")
+	approved := action.ActionID == approveActionID
+
+	var reply string
+	session, exists := b.getSession(threadTS)
+	switch {
+	case !exists:
+		reply = "There is no Claude session in this thread."
+	case approved:
+		if err := b.claudeService.ApproveToolUse(session.SessionID, toolUseID); err != nil {
+			reply = approvalFailure(err, threadTS)
+		} else {
+			reply = fmt.Sprintf("✅ _<@%s> approved the command._", callback.User.ID)
+			b.audit(callback.User.ID, callback.Channel.ID, threadTS, auditToolApproved, map[string]interface{}{
+				"session_id":  session.SessionID,
+				"tool_use_id": toolUseID,
+			})
+		}
+	default:
+		if err := b.claudeService.DenyToolUse(session.SessionID, toolUseID); err != nil {
+			reply = approvalFailure(err, threadTS)
+		} else {
+			reply = fmt.Sprintf("🚫 _<@%s> denied the command._", callback.User.ID)
+			b.audit(callback.User.ID, callback.Channel.ID, threadTS, auditToolDenied, map[string]interface{}{
+				"session_id":  session.SessionID,
+				"tool_use_id": toolUseID,
+			})
+		}
+	}
+
+	_, _, _, err := b.client.UpdateMessage(callback.Channel.ID, callback.Message.Timestamp,
+		slack.MsgOptionText(reply, false),
+		slack.MsgOptionBlocks(slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, reply, false, false), nil, nil)),
+	)
+	if err != nil {
+		metrics.SlackAPIErrorsTotal.WithLabelValues("chat.update").Inc()
+		slog.Error("Failed to update approval request", "thread_ts", threadTS, "error", err)
+	}
+}
+
+// approvalFailure explains why a decision on a held command didn't go through
+func approvalFailure(err error, threadTS string) string {
+	if errors.Is(err, claude.ErrNoPendingApproval) || errors.Is(err, claude.ErrSessionNotRunning) {
+		return "_This command was already decided or timed out._"
+	}
+	slog.Error("Failed to decide on Claude command", "error", err, "thread_ts", threadTS)
+	return "❌ Failed to send the decision to Claude. Please try again."
+}
+
+// parsePersonaFlag recognizes a leading "--persona <name>" or "--persona=<name>",
+// returning the name and the rest of the prompt
+func parsePersonaFlag(text string) (persona, prompt string, ok bool) {
+	rest, found := strings.CutPrefix(strings.TrimSpace(text), "--persona")
+	if !found || (rest != "" && rest[0] != '=' && !unicode.IsSpace(rune(rest[0]))) {
+		return "", text, false
+	}
+	rest = strings.TrimLeftFunc(strings.TrimPrefix(rest, "="), unicode.IsSpace)
+	if i := strings.IndexFunc(rest, unicode.IsSpace); i >= 0 {
+		return rest[:i], strings.TrimSpace(rest[i:]), true
+	}
+	return rest, "", true
+}
+
+// personaOption returns the session option for a /flow --persona name, or the reply
+// explaining why it can't be used
+func (b *SlackBot) personaOption(name, prompt string) (claude.SessionOption, string) {
+	opt, err := b.claudeService.Persona(name)
+	if err != nil {
+		personas := b.claudeService.Personas()
+		if len(personas) == 0 {
+			return nil, "No Claude personas are configured."
+		}
+		return nil, fmt.Sprintf("Unknown persona %q. Available personas: `%s`", name, strings.Join(personas, "`, `"))
+	}
+	if prompt == "" {
+		return nil, fmt.Sprintf("Please provide a prompt after the persona, e.g. `/flow --persona %s Review the session handling code`", name)
+	}
+	return opt, ""
+}
+
+// exportClaudeSession uploads the transcript of the thread's Claude session to the thread
+func (b *SlackBot) exportClaudeSession(channelID, threadTS, formatArg string) {
+	format, err := claude.ParseExportFormat(formatArg)
+	if err != nil {
+		if _, err := b.postMessage(channelID, threadTS,
+			"Unknown export format. Use `/flow export` for Markdown or `/flow export json` for JSON."); err != nil {
+			slog.Error("Failed to post export usage", "error", err)
+		}
+		return
+	}
+
+	session, exists := b.getSession(threadTS)
+	if !exists {
+		if _, err := b.postMessage(channelID, threadTS, "There is no Claude session in this thread to export."); err != nil {
+			slog.Error("Failed to post export error", "error", err)
+		}
+		return
+	}
+
+	data, err := b.claudeService.ExportSession(session.SessionID, format)
+	if err == nil {
+		_, err = b.client.UploadFileV2(slack.UploadFileV2Parameters{
+			Content:         string(data),
+			FileSize:        len(data),
+			Filename:        format.Filename(session.SessionID),
+			Title:           "Claude session transcript",
+			Channel:         channelID,
+			ThreadTimestamp: threadTS,
+		})
+	}
+	if err != nil {
+		slog.Error("Failed to export Claude session", "error", err, "session_id", session.SessionID)
+		if _, err := b.postMessage(channelID, threadTS, "❌ Failed to export the Claude session. Please try again."); err != nil {
+			slog.Error("Failed to post export error", "error", err)
+		}
+	}
+}
+
+// notifySessionRestarts posts in a thread whenever the supervisor restarts its
+// Claude process, since a reply in progress is lost with the old process
+func (b *SlackBot) notifySessionRestarts(ctx context.Context) {
+	restarts, unsubscribe := events.Channel(b.events, events.TopicSessionRestarted)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case restart := <-restarts:
+			session, exists := b.sessionByID(restart.SessionID)
+			if !exists {
+				continue
+			}
+			if _, err := b.postMessage(session.ChannelID, session.ThreadTS,
+				"♻️ _Claude stopped responding and was restarted with this conversation. If a reply is missing, please send your last message again._"); err != nil {
+				slog.Error("Failed to post session restart notice", "error", err, "session_id", restart.SessionID)
+			}
+		}
+	}
+}
+
+// sessionByID finds the in-memory thread session for a flow session ID
+func (b *SlackBot) sessionByID(sessionID string) (*SlackClaudeSession, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, session := range b.sessions {
+		if session.SessionID == sessionID {
+			return session, true
+		}
+	}
+	return nil, false
+}
+
 // handleClaudeResponseStream processes the streaming response from Claude
 func (b *SlackBot) handleClaudeResponseStream(ctx context.Context, process *claude.Process, session *SlackClaudeSession) {
 	// Get message channel from Claude service
 	messageChan := b.claudeService.ReceiveMessages(process)
+	errorChan := b.claudeService.Errors(process)
+	output := b.claudeService.Output(claude.OutputSlack)
 	timeout := time.After(5 * time.Minute)
 
 	if b.config.Debug {
@@ -240,6 +626,13 @@ func (b *SlackBot) handleClaudeResponseStream(ctx context.Context, process *clau
 			"channel_available", messageChan != nil)
 	}
 
+	// Partial text is shown in one Slack message that is edited as Claude writes
+	partial := claude.NewPartialTextBuffer(partialTextUpdateInterval)
+	stream := b.newStreamingMessage(session)
+	toolCalls := make(map[string]claude.ToolEvent)
+	// Files Claude writes during a turn are shared when it ends
+	artifacts := newArtifactSnapshot(process.SessionDir())
+
 	messageCount := 0
 	for {
 		select {
@@ -257,6 +650,18 @@ func (b *SlackBot) handleClaudeResponseStream(ctx context.Context, process *clau
 			slog.Debug("Context cancelled during Claude interaction")
 			return
 
+		case sessionErr := <-errorChan:
+			slog.Warn("Claude session reported an error",
+				"session_id", session.SessionID,
+				"error", sessionErr)
+			// Unrecognized stderr output is often harmless, so only known failures reach the thread
+			if sessionErr.Kind == nil {
+				continue
+			}
+			if _, err := b.postMessage(session.ChannelID, session.ThreadTS, "❌ "+sessionErr.UserMessage()); err != nil {
+				slog.Error("Failed to post Claude error", "error", err)
+			}
+
 		case claudeMsg, ok := <-messageChan:
 			messageCount++
 			if !ok {
@@ -284,6 +689,15 @@ func (b *SlackBot) handleClaudeResponseStream(ctx context.Context, process *clau
 					}())
 			}
 
+			if claudeMsg.Type == claude.MessageTypePartialText {
+				// Deltas are processed together so a secret split between them is still redacted
+				if text, ok := partial.Add(claudeMsg.Text); ok {
+					stream.show(output.Text(text))
+				}
+				continue
+			}
+			claudeMsg = output.Apply(claudeMsg)
+
 			// Update session activity
 			b.updateSessionActivity(session.ThreadTS)
 
@@ -310,8 +724,15 @@ func (b *SlackBot) handleClaudeResponseStream(ctx context.Context, process *clau
 						// Successfully parsed Claude message format
 						for _, content := range messageContent.Content {
 							if content.Type == "text" && content.Text != "" {
-								formattedContent := b.formatClaudeResponse(content.Text)
-								_, err := b.postMessage(session.ChannelID, session.ThreadTS, formattedContent)
+								if stream.started() {
+									// Replace the streamed preview with the complete text
+									if err := stream.finish(content.Text); err != nil {
+										slog.Error("Failed to finalize streamed message", "error", err)
+									}
+									stream = b.newStreamingMessage(session)
+									continue
+								}
+								err := b.postResponse(session, "", content.Text)
 								if err != nil {
 									slog.Error("Failed to post parsed text message", "error", err)
 								} else if b.config.Debug {
@@ -320,6 +741,21 @@ func (b *SlackBot) handleClaudeResponseStream(ctx context.Context, process *clau
 								}
 							}
 						}
+						partial.Reset()
+
+						// Tool results are posted with the call that produced them
+						for _, event := range claude.ParseToolEvents(claudeMsg) {
+							if event.Type == claude.ToolCall {
+								toolCalls[event.ToolUseID] = event
+								continue
+							}
+							call := toolCalls[event.ToolUseID]
+							delete(toolCalls, event.ToolUseID)
+							if event.Output != "" {
+								event.Tool = call.Tool
+								b.postToolOutput(session, call, event)
+							}
+						}
 					} else {
 						// Fallback to treating the entire message as text content
 						textContent := string(claudeMsg.Message)
@@ -341,22 +777,12 @@ func (b *SlackBot) handleClaudeResponseStream(ctx context.Context, process *clau
 				// Post tool usage as individual message
 				if claudeMsg.Subtype == "start" {
 					// Tool is starting
-					_, err := b.postMessage(session.ChannelID, session.ThreadTS, "🔧 _Claude is using tools..._")
-					if err != nil {
-						slog.Error("Failed to post tool start message", "error", err)
-					} else if b.config.Debug {
-						slog.Debug("Posted tool start message to Slack")
-					}
+					b.queueMessage(session.ChannelID, session.ThreadTS, "🔧 _Claude is using tools..._")
 				} else if claudeMsg.Subtype == "result" {
 					// Tool completed - show result
 					toolDisplay := b.formatToolUse(&claudeMsg)
 					if toolDisplay != "" {
-						_, err := b.postMessage(session.ChannelID, session.ThreadTS, toolDisplay)
-						if err != nil {
-							slog.Error("Failed to post tool result message", "error", err)
-						} else if b.config.Debug {
-							slog.Debug("Posted tool result message to Slack")
-						}
+						b.queueMessage(session.ChannelID, session.ThreadTS, toolDisplay)
 					}
 				} else {
 					// Generic tool use message
@@ -398,21 +824,31 @@ func (b *SlackBot) handleClaudeResponseStream(ctx context.Context, process *clau
 				// Note: Not posting a completion message to keep the conversation clean
 				return
 
-			//case "result":
-			//	// Handle tool use result messages
-			//	if b.config.Debug {
-			//		slog.Debug("Received tool use result",
-			//			"session_id", session.SessionID,
-			//			"result_length", len(claudeMsg.Result))
-			//	}
-			//	continue
+			case "result":
+				// The turn is over, so share what it produced and offer what can be done next
+				b.postArtifacts(session, artifacts)
+				b.postSessionControls(session)
+				go b.summarizeLongSession(session)
 
 			case "system":
 				// Handle system messages (like init messages)
 				if b.config.Debug {
 					slog.Debug("Received system message", "subtype", claudeMsg.Subtype)
 				}
-				// Don't forward system messages to Slack
+				switch claudeMsg.Subtype {
+				case claude.MessageSubtypeModelFallback:
+					notice := fmt.Sprintf("⚠️ _Claude is rate limited, retrying with `%s`..._", claudeMsg.Text)
+					if _, err := b.postMessage(session.ChannelID, session.ThreadTS, notice); err != nil {
+						slog.Error("Failed to post model fallback notice", "error", err)
+					}
+				case claude.MessageSubtypeBudgetExceeded:
+					b.postBudgetPause(session.ChannelID, session.ThreadTS, claudeMsg.Text)
+				case claude.MessageSubtypeApprovalRequired:
+					b.postApprovalRequest(session.ChannelID, session.ThreadTS, claudeMsg.Approval)
+					// Give whoever answers a fresh window before the response times out
+					timeout = time.After(5 * time.Minute)
+				}
+				// Don't forward other system messages to Slack
 				continue
 
 			default:
@@ -525,15 +961,7 @@ func (b *SlackBot) parseAndPostAssistantMessage(session *SlackClaudeSession, mes
 				}
 
 				formattedContent := b.formatClaudeResponse(toolMessage)
-				_, err := b.postMessage(session.ChannelID, session.ThreadTS, formattedContent)
-				if err != nil {
-					slog.Error("Failed to post tool use content", "error", err)
-					return err
-				} else if b.config.Debug {
-					slog.Debug("Posted tool use content to Slack",
-						"tool_name", content.Name,
-						"message_id", assistantWrapper.Message.ID)
-				}
+				b.queueMessage(session.ChannelID, session.ThreadTS, formattedContent)
 			}
 
 		default:
@@ -617,82 +1045,184 @@ func (b *SlackBot) sendToClaudeSession(session *SlackClaudeSession, message stri
 			"message_length", len(message))
 	}
 
-	go func() {
-		ctx := context.Background()
-
-		// Post immediate acknowledgment that we received the message
-		_, err := b.postMessage(session.ChannelID, session.ThreadTS, "🤔 _Processing your message..._")
-		if err != nil {
-			slog.Error("Failed to post processing acknowledgment", "error", err)
-		}
-
-		// Get or resume Claude process for this session
-		process := session.Process
-		if process == nil {
-			// Try to resume the session
-			if b.config.Debug {
-				slog.Debug("Claude process not in memory, attempting to resume",
-					"session_id", session.SessionID,
-					"thread_ts", session.ThreadTS)
-			}
+	// Messages sent while Claude is still answering wait their turn
+	run := func() { b.sendFollowUp(session, message) }
+	if b.queueTurn(session, run) {
+		go b.runTurns(session, run)
+	}
+}
 
-			resumedProcess, err := b.claudeService.ResumeSession(session.SessionID, session.UserID)
-			if err != nil {
-				slog.Error("Failed to resume Claude session", 
-					"session_id", session.SessionID,
-					"thread_ts", session.ThreadTS,
-					"error", err)
-				_, err := b.postMessage(session.ChannelID, session.ThreadTS,
-					"❌ Claude session expired and could not be resumed. Use `/flow <your message>` to start a new conversation.")
-				if err != nil {
-					slog.Error("Failed to post session resume error message", "error", err)
-				}
-				return
-			}
+// sendFollowUp resumes a session's process if it was shut down, sends it a
+// message, and streams the response to the thread. The caller holds the
+// thread's turn.
+func (b *SlackBot) sendFollowUp(session *SlackClaudeSession, message string) {
+	done, ok := b.trackClaudeWork(session)
+	if !ok {
+		return
+	}
+	defer done()
 
-			// Update session with resumed process
-			session.Process = resumedProcess
-			session.Resumed = true
-			session.LastActivity = time.Now()
-			process = resumedProcess
+	ctx := context.Background()
 
-			if b.config.Debug {
-				slog.Debug("Successfully resumed Claude session",
-					"session_id", session.SessionID,
-					"thread_ts", session.ThreadTS)
-			}
+	// Post immediate acknowledgment that we received the message
+	_, err := b.postMessage(session.ChannelID, session.ThreadTS, "🤔 _Processing your message..._")
+	if err != nil {
+		slog.Error("Failed to post processing acknowledgment", "error", err)
+	}
 
-			// Post a notification about resumption
-			_, err = b.postMessage(session.ChannelID, session.ThreadTS, "🔄 _Resumed previous Claude session with full context..._")
-			if err != nil {
-				slog.Error("Failed to post resumption notification", "error", err)
-			}
+	// Get or resume Claude process for this session. An idle session's
+	// process may have been hibernated since session.Process was set.
+	process, running := b.claudeService.GetProcess(session.SessionID)
+	if !running {
+		// Try to resume the session
+		if b.config.Debug {
+			slog.Debug("Claude process not in memory, attempting to resume",
+				"session_id", session.SessionID,
+				"thread_ts", session.ThreadTS)
 		}
 
-		// Update session activity in database
-		if err := b.claudeService.UpdateSessionActivity(session.SessionID); err != nil {
-			slog.Error("Failed to update session activity", "error", err)
+		notice := b.newQueueNotice(session.ChannelID, session.ThreadTS)
+		resumedProcess, err := b.claudeService.ResumeSession(session.SessionID, session.UserID, notice.option())
+		notice.finish(err)
+		if errors.Is(err, claude.ErrQueueTimeout) {
+			// The queue notice already told the thread
+			return
 		}
-
-		// Send follow-up message to Claude process
-		if err := b.claudeService.SendMessage(process, message); err != nil {
-			slog.Error("Failed to send follow-up to Claude", "error", err)
+		if err != nil {
+			slog.Error("Failed to resume Claude session", 
+				"session_id", session.SessionID,
+				"thread_ts", session.ThreadTS,
+				"error", err)
+			// Start over from what was said in the session instead
+			b.removeSession(session.ThreadTS)
+			if restored, prompt, ok := b.takeOverThread(session.UserID, session.ChannelID, session.ThreadTS, message); ok {
+				// This turn still holds the thread, so the new session answers in it
+				if restored != nil {
+					b.streamPrompt(restored, prompt)
+				}
+				return
+			}
 			_, err := b.postMessage(session.ChannelID, session.ThreadTS,
-				"❌ Failed to send message to Claude. Please try again, or use `/flow <your message>` to start a new conversation.")
+				"❌ Claude session expired and could not be resumed. Use `/flow <your message>` to start a new conversation.")
 			if err != nil {
-				slog.Error("Failed to post error message", "error", err)
+				slog.Error("Failed to post session resume error message", "error", err)
 			}
 			return
 		}
 
+		// Update session with resumed process
+		session.Process = resumedProcess
+		session.ProcessID = resumedProcess.GetCorrelationID()
+		session.Resumed = true
+		session.LastActivity = time.Now()
+		process = resumedProcess
+		if err := b.sessionDB.SetSession(session); err != nil {
+			slog.Error("Failed to store resumed session", "error", err, "thread_ts", session.ThreadTS)
+		}
+
 		if b.config.Debug {
-			slog.Debug("Sent follow-up message to Claude successfully",
+			slog.Debug("Successfully resumed Claude session",
 				"session_id", session.SessionID,
-				"message_length", len(message),
-				"resumed", session.Resumed)
+				"thread_ts", session.ThreadTS)
 		}
 
-		// Handle the response stream
-		b.handleClaudeResponseStream(ctx, process, session)
-	}()
+		// Post a notification about resumption
+		resumedNotice := "🔄 _Resumed previous Claude session with full context..._"
+		switch {
+		case resumedProcess.Replayed():
+			resumedNotice = "🔄 _Claude's saved session was lost, so it was restarted with a summary of this thread. Some details may need repeating._"
+		case resumedProcess.Summarized():
+			resumedNotice = "🗜️ _Continuing from a summary of this thread to keep Claude's context small..._"
+		}
+		_, err = b.postMessage(session.ChannelID, session.ThreadTS, resumedNotice)
+		if err != nil {
+			slog.Error("Failed to post resumption notification", "error", err)
+		}
+	}
+
+	// Update session activity in database
+	if err := b.claudeService.UpdateSessionActivity(session.SessionID); err != nil {
+		slog.Error("Failed to update session activity", "error", err)
+	}
+
+	// Send follow-up message to Claude process
+	err = b.claudeService.SendMessage(process, message)
+	if errors.Is(err, claude.ErrBudgetExceeded) {
+		b.postBudgetPause(session.ChannelID, session.ThreadTS, "")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to send follow-up to Claude", "error", err)
+		_, err := b.postMessage(session.ChannelID, session.ThreadTS,
+			"❌ Failed to send message to Claude. Please try again, or use `/flow <your message>` to start a new conversation.")
+		if err != nil {
+			slog.Error("Failed to post error message", "error", err)
+		}
+		return
+	}
+
+	if b.config.Debug {
+		slog.Debug("Sent follow-up message to Claude successfully",
+			"session_id", session.SessionID,
+			"message_length", len(message),
+			"resumed", session.Resumed)
+	}
+
+	// Handle the response stream
+	b.handleClaudeResponseStream(ctx, process, session)
+}
+
+// summarizeLongSession has Claude summarize a thread's conversation once it
+// runs past the configured number of turns, so the next message continues from
+// the summary rather than the whole conversation
+func (b *SlackBot) summarizeLongSession(session *SlackClaudeSession) {
+	summarized, err := b.claudeService.SummarizeSession(context.Background(), session.SessionID, b.config.SummarizeAfterTurns)
+	if err != nil {
+		slog.Error("Failed to summarize long Claude session", "session_id", session.SessionID, "thread_ts", session.ThreadTS, "error", err)
+		return
+	}
+	if !summarized {
+		return
+	}
+	if _, err := b.postMessage(session.ChannelID, session.ThreadTS,
+		"🗜️ _This thread is getting long, so Claude summarized it. Your next message continues from the summary._"); err != nil {
+		slog.Error("Failed to post session summary notice", "error", err)
+	}
+}
+
+// trackClaudeWork registers a Claude response stream with the shutdown drainer.
+// It returns false after telling the thread to retry when the server is shutting down.
+func (b *SlackBot) trackClaudeWork(session *SlackClaudeSession) (func(), bool) {
+	if b.drainer == nil {
+		return func() {}, true
+	}
+
+	done, err := b.drainer.Start("claude_stream", session.ThreadTS, func() {
+		b.interruptSession(session)
+	})
+	if err != nil {
+		if _, err := b.postMessage(session.ChannelID, session.ThreadTS,
+			"🔄 Server is restarting. Please send your message again in a minute."); err != nil {
+			slog.Error("Failed to post restart notice", "error", err)
+		}
+		return nil, false
+	}
+	return done, true
+}
+
+// interruptSession persists a session cut off by shutdown so the next message
+// in the thread resumes it, and lets the thread know what happened
+func (b *SlackBot) interruptSession(session *SlackClaudeSession) {
+	session.LastActivity = time.Now()
+	if err := b.sessionDB.SetSession(session); err != nil {
+		slog.Error("Failed to persist session at shutdown",
+			"thread_ts", session.ThreadTS,
+			"session_id", session.SessionID,
+			"error", err)
+	}
+
+	if _, err := b.postMessage(session.ChannelID, session.ThreadTS,
+		"⚠️ Server is restarting, so this response was cut short. Reply in this thread once it's back to pick up where you left off."); err != nil {
+		slog.Error("Failed to post restart notice", "error", err)
+	}
 }
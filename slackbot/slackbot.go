@@ -4,12 +4,17 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/breadchris/flow/claude"
 	"github.com/breadchris/flow/config"
 	"github.com/breadchris/flow/deps"
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/metrics"
+	"github.com/breadchris/flow/shutdown"
 	"github.com/breadchris/flow/worklet"
 	"github.com/google/uuid"
 	"github.com/slack-go/slack"
@@ -30,6 +35,7 @@ type SlackBot struct {
 	contextDB          *ContextDBService              // Database service for contexts
 	fileManager        *FileManager                   // File management service for uploads
 	rateLimiter        *MessageRateLimiter            // Rate limiter for messages
+	outbox             *outbox                        // Paces messages to each channel
 	sessions           map[string]*SlackClaudeSession // thread_ts -> session (temporary for process references)
 	mu                 sync.RWMutex
 	config             *config.SlackBotConfig
@@ -37,10 +43,21 @@ type SlackBot struct {
 	ctx                context.Context
 	cancel             context.CancelFunc
 	channelWhitelist   *ChannelWhitelist       // Channel access control
+	readOnlyChannels   *ChannelWhitelist       // Channels whose sessions get read-only tools; nil when none
+	channelConfig      *ChannelConfigStore     // Per-channel overrides of the bot's settings
+	userRoles          *UserRoleStore          // Roles admins granted with /flow admin grant
+	schedules          *ScheduleStore          // Prompts scheduled with /flow schedule
+	auditLog           *AuditStore             // What the bot did for its users, for /flow audit and the audit export
+	prDecisions        sync.Map                // Worklet ID -> Slack user who created or discarded its pull request
+	startFailures      startFailureLog         // Recent sessions that failed to start, for retries and debug bundles
+	turns              turnQueue               // Messages waiting for Claude to finish its turn in their thread
 	sessionCache       *SlackBotSessionCache   // Session cache
 	sessionActivityMgr *SessionActivityManager // Session activity manager with error handling
 	wg                 sync.WaitGroup          // Wait group for tracking goroutines
 	botUserID          string                  // Bot's own user ID to filter out self-messages
+	connected          atomic.Bool             // Whether the socket mode connection is up
+	drainer            *shutdown.Drainer       // Tracks in-flight Claude streams for graceful shutdown
+	events             *events.Bus             // Cross-module event bus
 }
 
 // SlackClaudeSession represents a Claude session tied to a Slack thread
@@ -58,8 +75,9 @@ type SlackClaudeSession struct {
 	SessionInfo  *claude.SessionInfo `json:"-"`       // Database session info (not serialized)
 }
 
-// New creates a new SlackBot instance
-func New(d deps.Deps) (*SlackBot, error) {
+// New creates a new SlackBot instance that runs worklets on workletManager,
+// the same manager the worklet API uses
+func New(d deps.Deps, workletManager *worklet.Manager) (*SlackBot, error) {
 	slackConfig := d.Config.GetSlackBotConfig()
 	if !d.Config.IsSlackBotEnabled() {
 		return nil, fmt.Errorf("slack bot is disabled")
@@ -80,9 +98,6 @@ func New(d deps.Deps) (*SlackBot, error) {
 	// Create database-integrated Claude service
 	claudeService := claude.NewClaudeService(d)
 
-	// Create worklet manager
-	workletManager := worklet.NewManager(&d)
-
 	// Create ChatGPT service
 	chatgptService := NewChatGPTService(d.AI, slackConfig.Debug)
 
@@ -107,6 +122,16 @@ func New(d deps.Deps) (*SlackBot, error) {
 
 	contextManager := NewContextManager(chatgptService, contextConfig, contextDB, slackConfig.Debug)
 
+	// Channels matching these patterns get read-only Claude sessions
+	var readOnlyChannels *ChannelWhitelist
+	if len(slackConfig.ReadOnlyChannels) > 0 {
+		var err error
+		readOnlyChannels, err = NewChannelWhitelist(slackConfig.ReadOnlyChannels, slackConfig.Debug)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create read-only channel list: %w", err)
+		}
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Create channel whitelist
@@ -114,6 +139,9 @@ func New(d deps.Deps) (*SlackBot, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create channel whitelist: %w", err)
 	}
+	if err := channelWhitelist.SetDenyPatterns(slackConfig.ChannelDenylist); err != nil {
+		return nil, fmt.Errorf("failed to create channel deny list: %w", err)
+	}
 
 	// Create session cache
 	sessionCache := NewSlackBotSessionCache()
@@ -139,8 +167,15 @@ func New(d deps.Deps) (*SlackBot, error) {
 		ctx:                ctx,
 		cancel:             cancel,
 		channelWhitelist:   channelWhitelist,
+		readOnlyChannels:   readOnlyChannels,
+		channelConfig:      NewChannelConfigStore(d.DB),
+		userRoles:          NewUserRoleStore(d.DB),
+		schedules:          NewScheduleStore(d.DB),
+		auditLog:           NewAuditStore(d.DB),
 		sessionCache:       sessionCache,
 		sessionActivityMgr: sessionActivityMgr,
+		drainer:            d.Drainer,
+		events:             d.Events,
 	}
 
 	// Get bot's own user ID to filter out self-messages
@@ -149,6 +184,8 @@ func New(d deps.Deps) (*SlackBot, error) {
 		return nil, fmt.Errorf("failed to get bot user ID: %w", err)
 	}
 	bot.botUserID = authResponse.UserID
+	bot.outbox = newOutbox(bot.postNotice)
+	channelWhitelist.SetLookup(bot.lookupChannel)
 
 	if slackConfig.Debug {
 		slog.Debug("SlackBot initialized", 
@@ -163,6 +200,9 @@ func New(d deps.Deps) (*SlackBot, error) {
 func (b *SlackBot) Start(ctx context.Context) error {
 	slog.Info("Starting Slack bot", "debug", b.config.Debug)
 
+	// Pick up the threads that were live before the bot last stopped
+	b.restoreSessions()
+
 	// Start session cleanup goroutine
 	b.wg.Add(1)
 	go func() {
@@ -197,6 +237,67 @@ func (b *SlackBot) Start(ctx context.Context) error {
 		}()
 	}
 
+	// Keep pre-warmed Claude processes ready for new sessions
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.claudeService.RunPool(b.ctx)
+	}()
+
+	// Restart crashed or hung Claude processes and tell their threads
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.claudeService.RunSupervisor(b.ctx)
+	}()
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.notifySessionRestarts(b.ctx)
+	}()
+
+	// Tell worklets' threads when their pull requests are merged, closed, or checked
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.notifyPullRequests(b.ctx)
+	}()
+
+	// Post links to worklets' build artifacts in their threads
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.notifyArtifacts(b.ctx)
+	}()
+
+	// Post how worklets' scheduled prompts went in their threads
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.notifyScheduleRuns(b.ctx)
+	}()
+
+	// Run prompts scheduled with /flow schedule and post their results
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.runSchedules(b.ctx)
+	}()
+
+	// Record Claude sessions starting in the audit log
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.auditSessionStarts(b.ctx)
+	}()
+
+	// Archive old session directories and keep the rest within their disk quotas
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.claudeService.RunJanitor(b.ctx)
+	}()
+
 	// Handle socket mode events
 	b.wg.Add(1)
 	go func() {
@@ -222,15 +323,21 @@ func (b *SlackBot) Start(ctx context.Context) error {
 					return
 				}
 
+				metrics.SlackEventsTotal.WithLabelValues(string(evt.Type)).Inc()
+
 				switch evt.Type {
 				case socketmode.EventTypeConnecting:
 					slog.Info("Slack bot connecting...")
+					b.connected.Store(false)
 
 				case socketmode.EventTypeConnectionError:
 					slog.Error("Slack bot connection error", "error", evt.Data)
+					b.connected.Store(false)
+					metrics.SlackAPIErrorsTotal.WithLabelValues("socket_mode.connect").Inc()
 
 				case socketmode.EventTypeConnected:
 					slog.Info("Slack bot connected")
+					b.connected.Store(true)
 
 				case socketmode.EventTypeSlashCommand:
 					cmd, ok := evt.Data.(slack.SlashCommand)
@@ -248,6 +355,14 @@ func (b *SlackBot) Start(ctx context.Context) error {
 					}
 					b.handleEventsAPI(&evt, &eventsAPIEvent)
 
+				case socketmode.EventTypeInteractive:
+					callback, ok := evt.Data.(slack.InteractionCallback)
+					if !ok {
+						slog.Error("Failed to type assert interaction callback")
+						continue
+					}
+					b.handleInteraction(&evt, &callback)
+
 				default:
 					if b.config.Debug {
 						slog.Debug("Unhandled socket mode event", "type", evt.Type)
@@ -261,6 +376,16 @@ func (b *SlackBot) Start(ctx context.Context) error {
 	return b.socketMode.RunContext(b.ctx)
 }
 
+// ClaudeService returns the Claude service shared by the bot's sessions
+func (b *SlackBot) ClaudeService() *claude.ClaudeService {
+	return b.claudeService
+}
+
+// IsConnected reports whether the socket mode connection to Slack is established
+func (b *SlackBot) IsConnected() bool {
+	return b.connected.Load()
+}
+
 // Stop gracefully shuts down the bot
 func (b *SlackBot) Stop() error {
 	slog.Info("Stopping Slack bot")
@@ -324,6 +449,38 @@ func (b *SlackBot) getSession(threadTS string) (*SlackClaudeSession, bool) {
 	return session, true
 }
 
+// restoreSessions loads the most recently active sessions from the database so
+// their threads keep working after a restart. Their Claude processes are
+// resumed when the thread next gets a reply.
+func (b *SlackBot) restoreSessions() {
+	sessions, err := b.sessionDB.GetAllActiveSessions()
+	if err != nil {
+		slog.Error("Failed to load sessions from database", "error", err)
+		return
+	}
+	slices.SortFunc(sessions, func(x, y *SlackClaudeSession) int {
+		return y.LastActivity.Compare(x.LastActivity)
+	})
+	if b.config.MaxSessions > 0 && len(sessions) > b.config.MaxSessions {
+		sessions = sessions[:b.config.MaxSessions]
+	}
+
+	b.mu.Lock()
+	for _, session := range sessions {
+		b.sessions[session.ThreadTS] = session
+		b.sessionCache.SetSession(session.ThreadTS, session)
+	}
+	metrics.SlackSessionsActive.Set(float64(len(b.sessions)))
+	b.mu.Unlock()
+
+	if len(sessions) > 0 {
+		slog.Info("Restored Slack sessions from database",
+			"count", len(sessions),
+			"action", "sessions_restored",
+		)
+	}
+}
+
 // setSession stores a session by thread timestamp in both database and memory
 func (b *SlackBot) setSession(threadTS string, session *SlackClaudeSession) {
 	// Store in database for persistence
@@ -358,10 +515,18 @@ func (b *SlackBot) setSession(threadTS string, session *SlackClaudeSession) {
 	}
 
 	b.sessions[threadTS] = session
+	metrics.SlackSessionsActive.Set(float64(len(b.sessions)))
 }
 
 // removeSession removes a session by thread timestamp from both database and memory
 func (b *SlackBot) removeSession(threadTS string) {
+	b.mu.RLock()
+	session, exists := b.sessions[threadTS]
+	b.mu.RUnlock()
+	if exists {
+		b.dropQueuedTurns(session.ChannelID, threadTS)
+	}
+
 	// Mark as inactive in database
 	if err := b.sessionDB.RemoveSession(threadTS); err != nil {
 		slog.Error("Failed to remove session from database", "error", err, "thread_ts", threadTS)
@@ -374,6 +539,7 @@ func (b *SlackBot) removeSession(threadTS string) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	delete(b.sessions, threadTS)
+	metrics.SlackSessionsActive.Set(float64(len(b.sessions)))
 }
 
 // cleanupSessions periodically removes inactive sessions
@@ -384,15 +550,25 @@ func (b *SlackBot) cleanupSessions() {
 	for {
 		select {
 		case <-ticker.C:
+			// Channels can override how long their sessions may sit idle
+			channelTimeouts, err := b.channelConfig.Timeouts()
+			if err != nil {
+				slog.Error("Failed to load channel session timeouts", "error", err)
+			}
+
 			// Cleanup database sessions
-			if err := b.sessionDB.CleanupInactiveSessions(b.config.SessionTimeout); err != nil {
+			if err := b.sessionDB.CleanupInactiveSessions(b.config.SessionTimeout, channelTimeouts); err != nil {
 				slog.Error("Failed to cleanup database sessions", "error", err)
 			}
 
 			// Cleanup memory cache sessions
 			b.mu.Lock()
 			for threadTS, session := range b.sessions {
-				if time.Since(session.LastActivity) > b.config.SessionTimeout {
+				timeout, ok := channelTimeouts[session.ChannelID]
+				if !ok {
+					timeout = b.config.SessionTimeout
+				}
+				if time.Since(session.LastActivity) > timeout {
 					delete(b.sessions, threadTS)
 					session.Active = false
 					slog.Info("Cleaned up inactive session from memory",
@@ -519,7 +695,7 @@ func (b *SlackBot) createSessionID(userID string) (string, string) {
 	return sessionID, correlationID
 }
 
-// isChannelAllowed checks if a channel ID matches the whitelist patterns
+// isChannelAllowed checks if a channel is whitelisted and not denied
 func (b *SlackBot) isChannelAllowed(channelID string) bool {
 	return b.channelWhitelist.IsAllowed(channelID)
 }
@@ -0,0 +1,269 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/breadchris/flow/claude"
+	"github.com/slack-go/slack"
+)
+
+// StartFailurePath is where the debug bundle of a session that failed to
+// start is served, followed by the failure's ID
+const StartFailurePath = "/debug/slack/start-failures/"
+
+// Action IDs of the buttons sent when a session fails to start. The retry
+// button's value is the failure's ID; the debug bundle button is a link.
+const (
+	retryStartActionID  = "claude_start_retry"
+	debugBundleActionID = "claude_start_debug_bundle"
+)
+
+// startFailureLimit is how many failures are kept for retries and debug bundles
+const startFailureLimit = 100
+
+// startFailedMessage replaces the thread's status when a session doesn't start
+const startFailedMessage = "❌ _The Claude session didn't start. Whoever asked was sent the details._"
+
+// Categories of the reasons a session fails to start
+const (
+	startFailureBusy      = "busy"
+	startFailureCLI       = "claude_cli"
+	startFailureAuth      = "auth"
+	startFailureWorkspace = "workspace"
+	startFailureTimeout   = "timeout"
+	startFailureUnknown   = "unknown"
+)
+
+// startFailureHints explain each category to the user who started the session
+var startFailureHints = map[string]string{
+	startFailureBusy:      "Every Claude process is in use and the wait for one timed out. Retry in a few minutes.",
+	startFailureCLI:       "The Claude CLI isn't installed or couldn't be run. An administrator needs to check the server.",
+	startFailureAuth:      "Claude couldn't authenticate. An administrator needs to check the Claude CLI login or API key.",
+	startFailureWorkspace: "The session's working directory couldn't be set up. An administrator should check the channel's `working_dir` and the disk.",
+	startFailureTimeout:   "Claude took too long to start. Retrying usually works.",
+	startFailureUnknown:   "Something unexpected went wrong. Retry, or share the error ID with an administrator.",
+}
+
+// startFailure is a session that failed to start, kept so it can be retried
+// and inspected
+type startFailure struct {
+	ID         string    `json:"id"`
+	Category   string    `json:"category"`
+	Error      string    `json:"error"`
+	UserID     string    `json:"user_id"`
+	ChannelID  string    `json:"channel_id"`
+	ThreadTS   string    `json:"thread_ts"`
+	Prompt     string    `json:"prompt"`
+	WorkingDir string    `json:"working_dir"`
+	Model      string    `json:"model,omitempty"`
+	Time       time.Time `json:"time"`
+
+	opts    []claude.SessionOption // Options the session was started with, for retrying
+	retried bool
+}
+
+// startFailureLog holds the most recent start failures by ID. The zero value is ready to use.
+type startFailureLog struct {
+	mu       sync.Mutex
+	failures map[string]*startFailure
+	order    []string // IDs, oldest first
+}
+
+func (l *startFailureLog) add(failure *startFailure) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.failures == nil {
+		l.failures = make(map[string]*startFailure)
+	}
+	l.failures[failure.ID] = failure
+	l.order = append(l.order, failure.ID)
+	for len(l.order) > startFailureLimit {
+		delete(l.failures, l.order[0])
+		l.order = l.order[1:]
+	}
+}
+
+func (l *startFailureLog) get(id string) *startFailure {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.failures[id]
+}
+
+// retry marks a failure as retried, reporting false if it already was so a
+// double click starts one session
+func (l *startFailureLog) retry(failure *startFailure) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if failure.retried {
+		return false
+	}
+	failure.retried = true
+	return true
+}
+
+// classifyStartFailure names the category of the reason a session didn't start
+func classifyStartFailure(err error) string {
+	switch {
+	case errors.Is(err, claude.ErrQueueTimeout):
+		return startFailureBusy
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, claude.ErrCrash):
+		return startFailureCLI
+	case errors.Is(err, claude.ErrAuth):
+		return startFailureAuth
+	case errors.Is(err, claude.ErrInvalidDirectory), errors.Is(err, fs.ErrPermission), errors.Is(err, fs.ErrNotExist):
+		return startFailureWorkspace
+	case errors.Is(err, claude.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return startFailureTimeout
+	}
+	return startFailureUnknown
+}
+
+// reportStartFailure tells the user who asked for a session, and only them,
+// why it didn't start, with buttons to retry and to open its debug bundle
+func (b *SlackBot) reportStartFailure(userID, channelID, threadTS, prompt string, opts []claude.SessionOption, err error) {
+	_, id := b.createSessionID(userID)
+	settings := b.channelSettings(channelID)
+	failure := &startFailure{
+		ID:         id,
+		Category:   classifyStartFailure(err),
+		Error:      err.Error(),
+		UserID:     userID,
+		ChannelID:  channelID,
+		ThreadTS:   threadTS,
+		Prompt:     prompt,
+		WorkingDir: settings.WorkingDir,
+		Model:      settings.Model,
+		Time:       time.Now(),
+		opts:       opts,
+	}
+	b.startFailures.add(failure)
+
+	slog.Error("Claude session failed to start",
+		"correlation_id", failure.ID,
+		"category", failure.Category,
+		"user_id", userID,
+		"channel_id", channelID,
+		"thread_ts", threadTS,
+		"error", err,
+		"action", "session_start_failed",
+	)
+
+	text := startFailureText(failure)
+	options := []slack.MsgOption{
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionBlocks(startFailureBlocks(failure, text, b.startFailureURL(failure.ID))...),
+	}
+	if threadTS != "" {
+		options = append(options, slack.MsgOptionTS(threadTS))
+	}
+	if _, err := b.client.PostEphemeral(channelID, userID, options...); err != nil {
+		slog.Error("Failed to send start failure details", "correlation_id", failure.ID, "user_id", userID, "error", err)
+	}
+}
+
+// startFailureText describes a start failure for the user who asked
+func startFailureText(failure *startFailure) string {
+	return fmt.Sprintf("❌ *Claude session didn't start* (`%s`)\n%s\n*Error ID:* `%s`\n```%s```",
+		failure.Category, startFailureHints[failure.Category], failure.ID, clipBytes(failure.Error, 1000))
+}
+
+// startFailureBlocks shows a start failure with a button to retry, and one
+// linking to its debug bundle when bundleURL is set
+func startFailureBlocks(failure *startFailure, text, bundleURL string) []slack.Block {
+	retry := slack.NewButtonBlockElement(retryStartActionID, failure.ID,
+		slack.NewTextBlockObject(slack.PlainTextType, "Retry", false, false))
+	retry.Style = slack.StylePrimary
+	buttons := []slack.BlockElement{retry}
+	if bundleURL != "" {
+		bundle := slack.NewButtonBlockElement(debugBundleActionID, "",
+			slack.NewTextBlockObject(slack.PlainTextType, "Debug bundle", false, false))
+		bundle.URL = bundleURL
+		buttons = append(buttons, bundle)
+	}
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+		slack.NewActionBlock("", buttons...),
+	}
+}
+
+// startFailureURL links to a failure's debug bundle, or is empty when debug
+// endpoints aren't served
+func (b *SlackBot) startFailureURL(id string) string {
+	if b.appConfig == nil || !b.appConfig.Debug.Enabled {
+		return ""
+	}
+	return strings.TrimSuffix(b.getExternalURL(), "/") + StartFailurePath + id
+}
+
+// retryStart tries again to start the session a failure is about, replacing
+// the failure's message with the outcome
+func (b *SlackBot) retryStart(callback *slack.InteractionCallback, action *slack.BlockAction) {
+	reply := func(text string) {
+		if err := slack.PostWebhook(callback.ResponseURL, &slack.WebhookMessage{Text: text, ReplaceOriginal: true}); err != nil {
+			slog.Error("Failed to update start failure details", "error", err)
+		}
+	}
+
+	failure := b.startFailures.get(action.Value)
+	if failure == nil {
+		reply("That error is too old to retry. Start a new session with `/flow <prompt>`.")
+		return
+	}
+	if failure.UserID != callback.User.ID {
+		reply("Only the person who started this session can retry it.")
+		return
+	}
+	if !b.startFailures.retry(failure) {
+		return
+	}
+
+	reply(fmt.Sprintf("🔁 Retrying the Claude session that failed with error `%s`...", failure.ID))
+	slog.Info("Retrying Claude session start",
+		"correlation_id", failure.ID,
+		"user_id", failure.UserID,
+		"thread_ts", failure.ThreadTS,
+		"action", "session_start_retried",
+	)
+	session, err := b.createClaudeSession(failure.UserID, failure.ChannelID, failure.ThreadTS, failure.opts...)
+	if err != nil {
+		b.reportStartFailure(failure.UserID, failure.ChannelID, failure.ThreadTS, failure.Prompt, failure.opts, err)
+		return
+	}
+	b.streamClaudeInteraction(session, failure.Prompt)
+}
+
+// StartFailureHandler serves the debug bundle of a session that failed to
+// start: the failure, the channel's settings, and whether the Claude CLI runs
+func (b *SlackBot) StartFailureHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failure := b.startFailures.get(strings.TrimPrefix(r.URL.Path, StartFailurePath))
+		if failure == nil {
+			http.Error(w, "Start failure not found", http.StatusNotFound)
+			return
+		}
+
+		bundle := struct {
+			Failure        *startFailure `json:"failure"`
+			ChannelAllowed bool          `json:"channel_allowed"`
+			CLIError       string        `json:"cli_error,omitempty"`
+		}{Failure: failure, ChannelAllowed: b.isChannelAllowed(failure.ChannelID)}
+		if err := claude.CheckCLI(); err != nil {
+			bundle.CLIError = err.Error()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(bundle); err != nil {
+			slog.Error("Failed to write start failure bundle", "correlation_id", failure.ID, "error", err)
+		}
+	})
+}
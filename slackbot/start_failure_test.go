@@ -0,0 +1,89 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/breadchris/flow/claude"
+	"github.com/slack-go/slack"
+)
+
+func TestClassifyStartFailure(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("failed to create Claude session: %w", claude.ErrQueueTimeout), startFailureBusy},
+		{&exec.Error{Name: "claude", Err: exec.ErrNotFound}, startFailureCLI},
+		{fmt.Errorf("failed to create session directory: %w", os.ErrPermission), startFailureWorkspace},
+		{&claude.SessionError{Kind: claude.ErrAuth}, startFailureAuth},
+		{errors.New("something else"), startFailureUnknown},
+	}
+	for _, tt := range tests {
+		if got := classifyStartFailure(tt.err); got != tt.want {
+			t.Errorf("classifyStartFailure(%v) = %q; want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestStartFailureLog(t *testing.T) {
+	var log startFailureLog
+	for i := 0; i <= startFailureLimit; i++ {
+		log.add(&startFailure{ID: fmt.Sprint(i)})
+	}
+	if log.get("0") != nil {
+		t.Error("oldest failure wasn't dropped")
+	}
+	failure := log.get(fmt.Sprint(startFailureLimit))
+	if failure == nil {
+		t.Fatal("newest failure is missing")
+	}
+	if !log.retry(failure) || log.retry(failure) {
+		t.Error("a failure should only be retried once")
+	}
+}
+
+func TestStartFailureBlocks(t *testing.T) {
+	failure := &startFailure{ID: "slack-U1-abcd1234", Category: startFailureBusy, Error: "timed out"}
+	buttons := func(blocks []slack.Block) []slack.BlockElement {
+		return blocks[len(blocks)-1].(*slack.ActionBlock).Elements.ElementSet
+	}
+
+	blocks := startFailureBlocks(failure, startFailureText(failure), "")
+	if got := buttons(blocks); len(got) != 1 || got[0].(*slack.ButtonBlockElement).Value != failure.ID {
+		t.Errorf("buttons without a bundle = %+v", got)
+	}
+	blocks = startFailureBlocks(failure, startFailureText(failure), "https://flow.example.com"+StartFailurePath+failure.ID)
+	if got := buttons(blocks); len(got) != 2 || got[1].(*slack.ButtonBlockElement).URL == "" {
+		t.Errorf("buttons with a bundle = %+v", got)
+	}
+}
+
+func TestStartFailureHandler(t *testing.T) {
+	bot := &SlackBot{channelWhitelist: &ChannelWhitelist{}}
+	bot.startFailures.add(&startFailure{ID: "slack-U1-abcd1234", Category: startFailureUnknown, Prompt: "hi"})
+
+	recorder := httptest.NewRecorder()
+	bot.StartFailureHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, StartFailurePath+"missing", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("missing failure returned %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	bot.StartFailureHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, StartFailurePath+"slack-U1-abcd1234", nil))
+	var bundle struct {
+		Failure struct {
+			ID     string `json:"id"`
+			Prompt string `json:"prompt"`
+		} `json:"failure"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&bundle); err != nil || bundle.Failure.ID != "slack-U1-abcd1234" || bundle.Failure.Prompt != "hi" {
+		t.Errorf("bundle = %+v, %v", bundle, err)
+	}
+}
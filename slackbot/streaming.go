@@ -0,0 +1,147 @@
+package slackbot
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/slack-go/slack"
+)
+
+// streamMessageLimit is the most response text streamed into one Slack
+// message; longer responses continue in a new message in the thread. It
+// leaves room under Slack's 4000 character limit for markdown conversion.
+const streamMessageLimit = 3500
+
+// streamingMessage shows a response as Claude writes it by editing one Slack
+// message in place, moving on to a new message in the thread whenever the
+// current one is full
+type streamingMessage struct {
+	bot        *SlackBot
+	session    *SlackClaudeSession
+	timestamps []string  // Messages posted so far, one per chunk of the response
+	finished   int       // Chunks whose messages hold their final text
+	retryAt    time.Time // When Slack's rate limit on posting and editing lifts
+}
+
+func (b *SlackBot) newStreamingMessage(session *SlackClaudeSession) *streamingMessage {
+	return &streamingMessage{bot: b, session: session}
+}
+
+// started reports whether any of the response has been shown
+func (m *streamingMessage) started() bool {
+	return len(m.timestamps) > 0
+}
+
+// show displays the response so far. Edits are skipped while Slack is rate
+// limiting them, since a later call shows the same text and more.
+func (m *streamingMessage) show(text string) {
+	if time.Now().Before(m.retryAt) {
+		return
+	}
+	chunks := streamChunks(text)
+	for m.finished < len(chunks)-1 {
+		if !m.write(m.finished, chunks[m.finished], true) {
+			return
+		}
+		m.finished++
+	}
+	m.write(len(chunks)-1, chunks[len(chunks)-1], false)
+}
+
+// finish replaces the streamed text with the complete response. Nothing is
+// posted in advance of the response when none was streamed.
+func (m *streamingMessage) finish(text string) error {
+	if !m.started() {
+		return m.bot.postResponse(m.session, "", text)
+	}
+	time.Sleep(time.Until(m.retryAt))
+
+	chunks := streamChunks(text)
+	for i := m.finished; i < len(chunks); i++ {
+		ts := ""
+		if i < len(m.timestamps) {
+			ts = m.timestamps[i]
+		}
+		if err := m.bot.postResponse(m.session, ts, chunks[i]); err != nil {
+			return err
+		}
+	}
+	m.finished = len(chunks)
+	return nil
+}
+
+// write shows a chunk in its message, posting the message if it doesn't exist
+// yet. Chunks still being written are marked with ✍️. It reports whether the
+// message was written.
+func (m *streamingMessage) write(i int, chunk string, final bool) bool {
+	var err error
+	switch {
+	case final && i < len(m.timestamps):
+		err = m.bot.postResponse(m.session, m.timestamps[i], chunk)
+	case final:
+		var ts string
+		ts, err = m.bot.postBlocks(m.session.ChannelID, m.session.ThreadTS,
+			m.bot.formatClaudeResponse(chunk), m.bot.responseBlocks(chunk))
+		if err == nil {
+			m.timestamps = append(m.timestamps, ts)
+		}
+	case i < len(m.timestamps):
+		err = m.bot.updateMessage(m.session.ChannelID, m.timestamps[i], m.bot.formatClaudeResponse(chunk)+" ✍️")
+	default:
+		var ts string
+		ts, err = m.bot.postMessage(m.session.ChannelID, m.session.ThreadTS, m.bot.formatClaudeResponse(chunk)+" ✍️")
+		if err == nil {
+			m.timestamps = append(m.timestamps, ts)
+		}
+	}
+	if err == nil {
+		return true
+	}
+
+	var rateLimited *slack.RateLimitedError
+	if errors.As(err, &rateLimited) {
+		m.retryAt = time.Now().Add(rateLimited.RetryAfter)
+		slog.Warn("Slack rate limited a streaming Claude response",
+			"thread_ts", m.session.ThreadTS,
+			"retry_after", rateLimited.RetryAfter)
+		return false
+	}
+	slog.Error("Failed to show streaming Claude response", "thread_ts", m.session.ThreadTS, "error", err)
+	return false
+}
+
+// streamChunks splits a response into pieces that each fit in one streamed
+// message, preferring to break at a line. A code block split across messages
+// is closed at the end of one and reopened at the start of the next. Earlier
+// chunks don't change as the response grows, so messages holding them can be
+// finished as soon as the text moves past them.
+func streamChunks(text string) []string {
+	var chunks []string
+	reopen := ""
+	for {
+		text = reopen + text
+		if len(text) <= streamMessageLimit {
+			return append(chunks, text)
+		}
+
+		cut := streamMessageLimit
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		if newline := strings.LastIndex(text[:cut], "\n"); newline > streamMessageLimit/2 {
+			cut = newline + 1
+		}
+		chunk := text[:cut]
+		text = text[cut:]
+
+		reopen = ""
+		if strings.Count(chunk, "```")%2 == 1 {
+			chunk = strings.TrimSuffix(chunk, "\n") + "\n```"
+			reopen = "```\n"
+		}
+		chunks = append(chunks, chunk)
+	}
+}
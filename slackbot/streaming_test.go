@@ -0,0 +1,56 @@
+package slackbot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStreamChunksShortResponse(t *testing.T) {
+	chunks := streamChunks("Hello, world")
+	if len(chunks) != 1 || chunks[0] != "Hello, world" {
+		t.Errorf("streamChunks() = %q", chunks)
+	}
+}
+
+func TestStreamChunksBreaksAtLines(t *testing.T) {
+	text := strings.Repeat("a line of prose\n", 400)
+
+	chunks := streamChunks(text)
+	if len(chunks) != 2 {
+		t.Fatalf("streamChunks() returned %d chunks; want 2", len(chunks))
+	}
+	if len(chunks[0]) > streamMessageLimit || !strings.HasSuffix(chunks[0], "prose\n") {
+		t.Errorf("first chunk is %d bytes ending %q", len(chunks[0]), chunks[0][len(chunks[0])-10:])
+	}
+	if strings.Join(chunks, "") != text {
+		t.Error("chunks don't add up to the response")
+	}
+
+	// Earlier chunks stay the same as the response grows
+	if grown := streamChunks(text + "more text"); grown[0] != chunks[0] {
+		t.Error("first chunk changed when the response grew")
+	}
+}
+
+func TestStreamChunksReopensCodeBlocks(t *testing.T) {
+	text := "Here's the file:\n```go\n" + strings.Repeat("fmt.Println(\"hi\")\n", 300) + "```\nDone."
+
+	chunks := streamChunks(text)
+	if len(chunks) != 2 {
+		t.Fatalf("streamChunks() returned %d chunks; want 2", len(chunks))
+	}
+	if !strings.HasSuffix(chunks[0], "\n```") {
+		t.Errorf("first chunk doesn't close its code block: ...%q", chunks[0][len(chunks[0])-20:])
+	}
+	if !strings.HasPrefix(chunks[1], "```\nfmt.Println") || !strings.HasSuffix(chunks[1], "```\nDone.") {
+		t.Errorf("second chunk doesn't reopen the code block: %q...", chunks[1][:20])
+	}
+}
+
+func TestStreamChunksKeepsRunesWhole(t *testing.T) {
+	for _, chunk := range streamChunks(strings.Repeat("é", 3000)) {
+		if !strings.HasPrefix(chunk, "é") || !strings.HasSuffix(chunk, "é") {
+			t.Errorf("chunk splits a rune: %q...%q", chunk[:2], chunk[len(chunk)-2:])
+		}
+	}
+}
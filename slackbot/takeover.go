@@ -0,0 +1,60 @@
+package slackbot
+
+import (
+	"log/slog"
+)
+
+const restoredSessionMessage = "♻️ _This thread's Claude session had ended, so a new one was started that picks up the conversation so far._"
+
+// takeOverThread starts a new Claude session in a thread whose session was
+// cleaned up and returns it with the message to send it: the message, seeded
+// with what was said in the old session. It reports whether the thread had an
+// ended session to take over; session is nil when that failed or the user
+// may not, after the thread or user was told.
+func (b *SlackBot) takeOverThread(userID, channelID, threadTS, message string) (session *SlackClaudeSession, prompt string, ok bool) {
+	ended, err := b.sessionDB.GetEndedSession(threadTS)
+	if err != nil {
+		slog.Error("Failed to look up ended session", "thread_ts", threadTS, "error", err)
+		return nil, "", false
+	}
+	if ended == nil {
+		return nil, "", false
+	}
+	if !b.canUseSession(userID, ended) {
+		b.denyUser(channelID, threadTS, userID, sessionNotOwnedMessage)
+		return nil, "", true
+	}
+
+	seed, err := b.claudeService.RestorePrompt(ended.SessionID)
+	if err != nil {
+		slog.Warn("Failed to load ended session's conversation", "session_id", ended.SessionID, "error", err)
+	}
+	// Keep the new session from resuming the old one
+	if err := b.claudeService.DeactivateSession(ended.SessionID); err != nil {
+		slog.Warn("Failed to deactivate ended session", "session_id", ended.SessionID, "error", err)
+	}
+
+	slog.Info("Restoring ended Claude session",
+		"user_id", userID,
+		"channel_id", channelID,
+		"thread_ts", threadTS,
+		"ended_session_id", ended.SessionID,
+		"seeded", seed != "",
+		"action", "session_restored",
+	)
+
+	statusTS, err := b.postMessage(channelID, threadTS, restoredSessionMessage)
+	if err != nil {
+		slog.Error("Failed to post session restored message", "thread_ts", threadTS, "error", err)
+	}
+	opts := b.userRole(userID).sessionOptions()
+	session, err = b.createClaudeSession(userID, channelID, threadTS, opts...)
+	if err != nil {
+		if statusTS != "" {
+			b.updateMessage(channelID, statusTS, startFailedMessage)
+		}
+		b.reportStartFailure(userID, channelID, threadTS, seed+message, opts, err)
+		return nil, "", true
+	}
+	return session, seed + message, true
+}
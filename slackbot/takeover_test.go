@@ -0,0 +1,50 @@
+package slackbot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestGetEndedSession(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.SlackSession{}); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	sessionDB := NewSessionDBService(db, false)
+	for _, threadTS := range []string{"1.1", "2.2"} {
+		session := &SlackClaudeSession{
+			ThreadTS:     threadTS,
+			ChannelID:    "C1",
+			UserID:       "U1",
+			SessionID:    "session-" + threadTS,
+			LastActivity: time.Now(),
+			Active:       true,
+		}
+		if err := sessionDB.SetSession(session); err != nil {
+			t.Fatalf("SetSession() = %v", err)
+		}
+	}
+	if err := sessionDB.RemoveSession("1.1"); err != nil {
+		t.Fatalf("RemoveSession() = %v", err)
+	}
+
+	ended, err := sessionDB.GetEndedSession("1.1")
+	if err != nil {
+		t.Fatalf("GetEndedSession() = %v", err)
+	}
+	if ended == nil || ended.SessionID != "session-1.1" || ended.UserID != "U1" {
+		t.Errorf("GetEndedSession(1.1) = %+v, want session-1.1 of U1", ended)
+	}
+	for _, threadTS := range []string{"2.2", "3.3"} {
+		if ended, err := sessionDB.GetEndedSession(threadTS); err != nil || ended != nil {
+			t.Errorf("GetEndedSession(%s) = %+v, %v, want nil", threadTS, ended, err)
+		}
+	}
+}
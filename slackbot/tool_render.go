@@ -0,0 +1,158 @@
+package slackbot
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/breadchris/flow/claude"
+	"github.com/go-git/go-git/v5"
+	"github.com/slack-go/slack"
+)
+
+// toolCommandBlockID marks the block showing a Bash command, which is kept
+// when the output under it is expanded or collapsed
+const toolCommandBlockID = "claude_tool_command"
+
+var githubRemoteRegex = regexp.MustCompile(`github\.com[:/]([\w.-]+)/([\w.-]+?)(?:\.git)?/?$`)
+
+// toolResultBlocks renders a tool's result with the call that produced it:
+// Bash commands with their output, file changes as diffs, and reads as links
+// to the file. Other tools show their output collapsed under a heading. It
+// returns the heading, which is also the message's fallback text.
+func toolResultBlocks(call, result claude.ToolEvent) (string, []slack.Block) {
+	heading := toolOutputHeading(result)
+	switch {
+	case call.Bash != nil:
+		blocks := toolOutputBlocks(heading, result.Output, false)
+		return heading, withCommandBlocks(blocks, bashCommandBlocks(call.Bash))
+	case call.Edit != nil && !result.IsError && call.Edit.Diff != "":
+		heading = fmt.Sprintf("✏️ %s %s", call.Tool, call.Edit.FilePath)
+		return heading, toolOutputBlocks(heading, diffBody(call.Edit.Diff), false)
+	case call.Read != nil && !result.IsError:
+		text := "📄 Read " + fileLink(call.Read.FilePath, call.Read.Offset, call.Read.Limit)
+		heading = "📄 Read " + call.Read.FilePath
+		return heading, []slack.Block{
+			slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, text, false, false)),
+		}
+	}
+	return heading, toolOutputBlocks(heading, result.Output, false)
+}
+
+// diffBody drops the --- and +++ lines naming the file from the top of a diff
+func diffBody(diff string) string {
+	for _, prefix := range []string{"--- ", "+++ "} {
+		if strings.HasPrefix(diff, prefix) {
+			_, diff, _ = strings.Cut(diff, "\n")
+		}
+	}
+	return diff
+}
+
+// bashCommandBlocks shows a Bash command as a code block, under its
+// description when Claude gave one
+func bashCommandBlocks(bash *claude.BashCommand) []slack.Block {
+	var blocks []slack.Block
+	if bash.Description != "" {
+		blocks = append(blocks, slack.NewContextBlock(toolCommandBlockID+"_description",
+			slack.NewTextBlockObject(slack.PlainTextType, bash.Description, false, false)))
+	}
+	for i, section := range codeSections(clipBytes(bash.Command, sectionTextLimit*2)) {
+		section.(*slack.SectionBlock).BlockID = fmt.Sprintf("%s_%d", toolCommandBlockID, i)
+		blocks = append(blocks, section)
+	}
+	return blocks
+}
+
+// withCommandBlocks puts a command's blocks under the heading of its output's blocks
+func withCommandBlocks(blocks, command []slack.Block) []slack.Block {
+	if len(command) == 0 {
+		return blocks
+	}
+	return append(append(append([]slack.Block{}, blocks[0]), command...), blocks[1:]...)
+}
+
+// commandBlocks returns the blocks of a tool output message that show the
+// command, so they survive re-rendering the output
+func commandBlocks(message slack.Message) []slack.Block {
+	var blocks []slack.Block
+	for _, block := range message.Blocks.BlockSet {
+		var id string
+		switch block := block.(type) {
+		case *slack.SectionBlock:
+			id = block.BlockID
+		case *slack.ContextBlock:
+			id = block.BlockID
+		}
+		if strings.HasPrefix(id, toolCommandBlockID) {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// fileLink shows a file's path, linked to the file on GitHub when it's in a
+// checkout of a GitHub repository. offset and limit pick out the lines read,
+// when they're set.
+func fileLink(path string, offset, limit int) string {
+	lines := ""
+	switch {
+	case offset > 0 && limit > 0:
+		lines = fmt.Sprintf(" (lines %d-%d)", offset, offset+limit-1)
+	case offset > 0:
+		lines = fmt.Sprintf(" (from line %d)", offset)
+	}
+
+	url, relPath := githubFileURL(path)
+	if url == "" {
+		return "`" + path + "`" + lines
+	}
+	if offset > 0 {
+		url += fmt.Sprintf("#L%d", offset)
+		if limit > 0 {
+			url += fmt.Sprintf("-L%d", offset+limit-1)
+		}
+	}
+	return fmt.Sprintf("<%s|%s>%s", url, relPath, lines)
+}
+
+// githubFileURL returns the GitHub URL of a file at its checkout's current
+// branch or commit, and the file's path in the repository. The URL is empty
+// when the file isn't in a checkout of a GitHub repository.
+func githubFileURL(path string) (string, string) {
+	if !filepath.IsAbs(path) {
+		return "", ""
+	}
+	repo, err := git.PlainOpenWithOptions(filepath.Dir(path), &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return "", ""
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", ""
+	}
+	relPath, err := filepath.Rel(worktree.Filesystem.Root(), path)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return "", ""
+	}
+	remote, err := repo.Remote("origin")
+	if err != nil || len(remote.Config().URLs) == 0 {
+		return "", ""
+	}
+	match := githubRemoteRegex.FindStringSubmatch(remote.Config().URLs[0])
+	if match == nil {
+		return "", ""
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", ""
+	}
+	ref := head.Hash().String()
+	if head.Name().IsBranch() {
+		ref = head.Name().Short()
+	}
+
+	relPath = filepath.ToSlash(relPath)
+	return fmt.Sprintf("https://github.com/%s/%s/blob/%s/%s", match[1], match[2], ref, relPath), relPath
+}
@@ -0,0 +1,86 @@
+package slackbot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/claude"
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/slack-go/slack"
+)
+
+func TestToolResultBlocksBash(t *testing.T) {
+	call := claude.ToolEvent{Type: claude.ToolCall, Tool: "Bash", Bash: &claude.BashCommand{Command: "go test ./...", Description: "Run the tests"}}
+	result := claude.ToolEvent{Type: claude.ToolResult, Tool: "Bash", Output: "ok one\nok two\nok three\nok four"}
+
+	heading, blocks := toolResultBlocks(call, result)
+	if heading != "🔧 Bash finished" {
+		t.Errorf("heading = %q", heading)
+	}
+	texts := blockTexts(blocks)
+	if len(texts) < 4 || texts[1] != "Run the tests" || texts[2] != "```go test ./...```" || texts[3] != "```ok one\nok two\nok three\n…```" {
+		t.Errorf("Bash blocks = %q", texts)
+	}
+
+	// The command is kept when the output is expanded
+	message := slack.Message{Msg: slack.Msg{Blocks: slack.Blocks{BlockSet: blocks}}}
+	expanded := withCommandBlocks(toolOutputBlocks(heading, result.Output, true), commandBlocks(message))
+	if texts := blockTexts(expanded); texts[2] != "```go test ./...```" || texts[3] != "```"+result.Output+"```" {
+		t.Errorf("expanded Bash blocks = %q", texts)
+	}
+}
+
+func TestToolResultBlocksEditAndRead(t *testing.T) {
+	edit := claude.ToolEvent{Type: claude.ToolCall, Tool: "Edit", Edit: &claude.EditFile{FilePath: "main.go", Diff: "--- a/main.go\n+++ b/main.go\n-old\n+new\n"}}
+	heading, blocks := toolResultBlocks(edit, claude.ToolEvent{Type: claude.ToolResult, Tool: "Edit", Output: "The file main.go has been updated."})
+	if texts := blockTexts(blocks); heading != "✏️ Edit main.go" || texts[1] != "```-old\n+new```" {
+		t.Errorf("Edit rendered as %q, %q", heading, texts)
+	}
+
+	read := claude.ToolEvent{Type: claude.ToolCall, Tool: "Read", Read: &claude.ReadFile{FilePath: "/tmp/notes.txt", Offset: 10, Limit: 5}}
+	_, blocks = toolResultBlocks(read, claude.ToolEvent{Type: claude.ToolResult, Tool: "Read", Output: "file contents"})
+	if texts := blockTexts(blocks); len(texts) != 1 || texts[0] != "📄 Read `/tmp/notes.txt` (lines 10-14)" {
+		t.Errorf("Read blocks = %q", texts)
+	}
+
+	// A failed read shows the error instead
+	_, blocks = toolResultBlocks(read, claude.ToolEvent{Type: claude.ToolResult, Tool: "Read", Output: "File does not exist.", IsError: true})
+	if texts := blockTexts(blocks); texts[0] != "*❌ Read failed*" {
+		t.Errorf("failed Read blocks = %q", texts)
+	}
+}
+
+func TestGithubFileURL(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	if _, err := repo.CreateRemote(&gitconfig.RemoteConfig{Name: "origin", URLs: []string{"git@github.com:breadchris/flow.git"}}); err != nil {
+		t.Fatalf("Failed to add remote: %v", err)
+	}
+	path := filepath.Join(dir, "slackbot", "bot.go")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("package slackbot\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	worktree, _ := repo.Worktree()
+	if _, err := worktree.Add("slackbot/bot.go"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worktree.Commit("Add bot", &git.CommitOptions{Author: &object.Signature{Name: "test", When: time.Now()}}); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	head, _ := repo.Head()
+	want := "<https://github.com/breadchris/flow/blob/" + head.Name().Short() + "/slackbot/bot.go#L3-L4|slackbot/bot.go> (lines 3-4)"
+	if got := fileLink(path, 3, 2); got != want {
+		t.Errorf("fileLink() = %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,246 @@
+package slackbot
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/slack-go/slack"
+)
+
+// cancelQueuedTurnActionID is the action ID of the button that drops a queued
+// message. Its value is the thread's timestamp and the turn's ID, separated by a space.
+const cancelQueuedTurnActionID = "claude_turn_cancel_queued"
+
+// queuedTurn is a message waiting for Claude to finish its turn in a thread
+type queuedTurn struct {
+	id       string
+	run      func()
+	noticeTS string // Queue notice with the turn's place in line
+}
+
+// threadTurns is a thread's running Claude turn and the messages queued behind it
+type threadTurns struct {
+	busy    bool
+	pending []*queuedTurn
+}
+
+// turnQueue serializes the Claude turns of each thread, so a message sent
+// while Claude is still answering waits instead of interleaving with the answer
+type turnQueue struct {
+	mu      sync.Mutex
+	nextID  int
+	threads map[string]*threadTurns
+}
+
+// start claims the thread for a turn, reporting false when one is already running
+func (q *turnQueue) start(threadTS string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.threads == nil {
+		q.threads = make(map[string]*threadTurns)
+	}
+	turns, ok := q.threads[threadTS]
+	if !ok {
+		turns = &threadTurns{}
+		q.threads[threadTS] = turns
+	}
+	if turns.busy {
+		return false
+	}
+	turns.busy = true
+	return true
+}
+
+// enqueue adds a turn behind the thread's running turn and those queued
+// before it, returning how many are ahead of it
+func (q *turnQueue) enqueue(threadTS string, run func()) (*queuedTurn, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.nextID++
+	turn := &queuedTurn{id: strconv.Itoa(q.nextID), run: run}
+	turns := q.threads[threadTS]
+	turns.pending = append(turns.pending, turn)
+	return turn, len(turns.pending)
+}
+
+// next takes the thread's first queued turn once the running one finishes.
+// It returns false and frees the thread when nothing is queued.
+func (q *turnQueue) next(threadTS string) (queuedTurn, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	turns := q.threads[threadTS]
+	if len(turns.pending) == 0 {
+		delete(q.threads, threadTS)
+		return queuedTurn{}, false
+	}
+	turn := turns.pending[0]
+	turns.pending = turns.pending[1:]
+	return *turn, true
+}
+
+// cancel drops a queued turn, reporting false when it already started or was dropped
+func (q *turnQueue) cancel(threadTS, id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	turns, ok := q.threads[threadTS]
+	if !ok {
+		return false
+	}
+	for i, turn := range turns.pending {
+		if turn.id == id {
+			turns.pending = slices.Delete(turns.pending, i, i+1)
+			return true
+		}
+	}
+	return false
+}
+
+// clear drops every turn queued in a thread and returns their notices
+func (q *turnQueue) clear(threadTS string) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	turns, ok := q.threads[threadTS]
+	if !ok {
+		return nil
+	}
+	var noticeTSs []string
+	for _, turn := range turns.pending {
+		noticeTSs = append(noticeTSs, turn.noticeTS)
+	}
+	turns.pending = nil
+	return noticeTSs
+}
+
+// setNotice records the message telling a turn its place in line
+func (q *turnQueue) setNotice(turn *queuedTurn, noticeTS string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	turn.noticeTS = noticeTS
+}
+
+// notices returns the queue notice of each turn queued in a thread, in order
+func (q *turnQueue) notices(threadTS string) []queuedTurn {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	turns, ok := q.threads[threadTS]
+	if !ok {
+		return nil
+	}
+	notices := make([]queuedTurn, len(turns.pending))
+	for i, turn := range turns.pending {
+		notices[i] = queuedTurn{id: turn.id, noticeTS: turn.noticeTS}
+	}
+	return notices
+}
+
+// queueTurn reports whether run may start as the thread's Claude turn now.
+// When Claude is busy in the thread, run is queued behind the turns ahead of
+// it instead, and the thread is told its place in line.
+func (b *SlackBot) queueTurn(session *SlackClaudeSession, run func()) bool {
+	if b.turns.start(session.ThreadTS) {
+		return true
+	}
+	turn, ahead := b.turns.enqueue(session.ThreadTS, run)
+	text := queuedTurnText(ahead)
+	noticeTS, err := b.postBlocks(session.ChannelID, session.ThreadTS, text, queuedTurnBlocks(text, session.ThreadTS, turn.id))
+	if err != nil {
+		slog.Error("Failed to post queued message notice", "thread_ts", session.ThreadTS, "error", err)
+	}
+	b.turns.setNotice(turn, noticeTS)
+	slog.Info("Queued message behind Claude's turn",
+		"session_id", session.SessionID,
+		"thread_ts", session.ThreadTS,
+		"ahead", ahead,
+		"action", "turn_queued",
+	)
+	return false
+}
+
+// runTurns runs a thread's Claude turn and then each turn queued behind it.
+// Each turn holds the session's turn lock, so prompts sent from the browser
+// wait for it instead of reading its response.
+func (b *SlackBot) runTurns(session *SlackClaudeSession, run func()) {
+	for {
+		b.runTurn(session, run)
+		turn, ok := b.turns.next(session.ThreadTS)
+		if !ok {
+			return
+		}
+		b.resolveQueuedTurn(session.ChannelID, turn.noticeTS, "▶️ _Claude is on this queued message._")
+		b.updateQueuedTurns(session.ChannelID, session.ThreadTS)
+		run = turn.run
+	}
+}
+
+// runTurn runs one turn while holding its session's turn lock
+func (b *SlackBot) runTurn(session *SlackClaudeSession, run func()) {
+	if b.claudeService != nil {
+		lock := b.claudeService.TurnLock(session.SessionID)
+		lock.Lock()
+		defer lock.Unlock()
+	}
+	run()
+}
+
+// updateQueuedTurns tells each turn still queued in a thread its new place in line
+func (b *SlackBot) updateQueuedTurns(channelID, threadTS string) {
+	for i, turn := range b.turns.notices(threadTS) {
+		if turn.noticeTS == "" {
+			continue
+		}
+		text := queuedTurnText(i + 1)
+		if err := b.updateBlocks(channelID, turn.noticeTS, text, queuedTurnBlocks(text, threadTS, turn.id)); err != nil {
+			slog.Error("Failed to update queued message notice", "thread_ts", threadTS, "error", err)
+		}
+	}
+}
+
+// cancelQueuedTurn drops a queued message from a Cancel button press
+func (b *SlackBot) cancelQueuedTurn(callback *slack.InteractionCallback, action *slack.BlockAction) {
+	threadTS, id, _ := strings.Cut(action.Value, " ")
+	if !b.turns.cancel(threadTS, id) {
+		b.resolveControls(callback, "_Claude already started on this message._")
+		return
+	}
+	b.resolveControls(callback, fmt.Sprintf("🗑️ _<@%s> cancelled this queued message._", callback.User.ID))
+	b.updateQueuedTurns(callback.Channel.ID, threadTS)
+}
+
+// dropQueuedTurns cancels every message queued in a thread whose session ended
+func (b *SlackBot) dropQueuedTurns(channelID, threadTS string) {
+	for _, noticeTS := range b.turns.clear(threadTS) {
+		b.resolveQueuedTurn(channelID, noticeTS, "_The session ended before Claude got to this message._")
+	}
+}
+
+// resolveQueuedTurn replaces a queue notice and its Cancel button with text
+func (b *SlackBot) resolveQueuedTurn(channelID, noticeTS, text string) {
+	if noticeTS == "" {
+		return
+	}
+	err := b.updateBlocks(channelID, noticeTS, text, []slack.Block{
+		slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, text, false, false)),
+	})
+	if err != nil {
+		slog.Error("Failed to update queued message notice", "error", err)
+	}
+}
+
+// queuedTurnText tells a queued message how many turns are ahead of it
+func queuedTurnText(ahead int) string {
+	return fmt.Sprintf("⏳ _Queued (%d ahead). Claude will get this message when it finishes._", ahead)
+}
+
+// queuedTurnBlocks renders a queue notice with a button that cancels the message
+func queuedTurnBlocks(text, threadTS, id string) []slack.Block {
+	button := slack.NewButtonBlockElement(cancelQueuedTurnActionID, threadTS+" "+id,
+		slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false))
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+		slack.NewActionBlock("", button),
+	}
+}
@@ -0,0 +1,82 @@
+package slackbot
+
+import (
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestTurnQueue(t *testing.T) {
+	var q turnQueue
+	if !q.start("1.1") {
+		t.Fatal("start() on an idle thread = false")
+	}
+	if q.start("1.1") {
+		t.Fatal("start() on a busy thread = true")
+	}
+	if !q.start("2.2") {
+		t.Error("start() on another thread = false")
+	}
+
+	var ran []string
+	first, ahead := q.enqueue("1.1", func() { ran = append(ran, "first") })
+	if ahead != 1 {
+		t.Errorf("first queued turn has %d ahead; want 1", ahead)
+	}
+	second, ahead := q.enqueue("1.1", func() { ran = append(ran, "second") })
+	if ahead != 2 {
+		t.Errorf("second queued turn has %d ahead; want 2", ahead)
+	}
+	third, _ := q.enqueue("1.1", func() { ran = append(ran, "third") })
+	q.setNotice(third, "3.3")
+
+	if !q.cancel("1.1", second.id) {
+		t.Error("cancel() of a queued turn = false")
+	}
+	if q.cancel("1.1", second.id) {
+		t.Error("cancel() of a cancelled turn = true")
+	}
+	if notices := q.notices("1.1"); len(notices) != 2 || notices[0].id != first.id || notices[1].noticeTS != "3.3" {
+		t.Errorf("notices() = %+v", notices)
+	}
+
+	for {
+		turn, ok := q.next("1.1")
+		if !ok {
+			break
+		}
+		turn.run()
+	}
+	if len(ran) != 2 || ran[0] != "first" || ran[1] != "third" {
+		t.Errorf("ran %q; want first, third", ran)
+	}
+	if !q.start("1.1") {
+		t.Error("start() after the queue emptied = false")
+	}
+
+	q.enqueue("1.1", func() {})
+	q.enqueue("1.1", func() {})
+	if dropped := q.clear("1.1"); len(dropped) != 2 {
+		t.Errorf("clear() dropped %d turns; want 2", len(dropped))
+	}
+	if _, ok := q.next("1.1"); ok {
+		t.Error("next() after clear() found a turn")
+	}
+}
+
+func TestQueuedTurnBlocks(t *testing.T) {
+	blocks := queuedTurnBlocks(queuedTurnText(2), "1.1", "7")
+	if len(blocks) != 2 {
+		t.Fatalf("queuedTurnBlocks() returned %d blocks; want 2", len(blocks))
+	}
+	actions, ok := blocks[1].(*slack.ActionBlock)
+	if !ok || len(actions.Elements.ElementSet) != 1 {
+		t.Fatalf("queuedTurnBlocks() second block = %#v; want the Cancel button", blocks[1])
+	}
+	if button := actions.Elements.ElementSet[0].(*slack.ButtonBlockElement); button.ActionID != cancelQueuedTurnActionID || button.Value != "1.1 7" {
+		t.Errorf("Cancel button = %q, %q", button.ActionID, button.Value)
+	}
+	if got := queuedTurnText(2); got != "⏳ _Queued (2 ahead). Claude will get this message when it finishes._" {
+		t.Errorf("queuedTurnText(2) = %q", got)
+	}
+}
@@ -0,0 +1,136 @@
+package slackbot
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/breadchris/flow/claude"
+)
+
+const usageCommandHelp = "Usage: `/flow usage [me|channel|team] [7d|30d]`."
+
+// usageTopSessions is how many of the most expensive sessions /flow usage lists
+const usageTopSessions = 5
+
+// usagePeriods are the time spans /flow usage reports on
+var usagePeriods = map[string]int{"7d": 7, "30d": 30}
+
+// usageReport is the usage /flow usage shows
+type usageReport struct {
+	title    string
+	total    *claude.UsageSummary
+	users    []claude.UserUsage // Left out of reports about one user
+	sessions []claude.SessionUsage
+}
+
+// parseUsageArgs reads the scope and number of days of /flow usage, which
+// default to the user's own usage over 30 days
+func parseUsageArgs(args []string) (scope string, days int, ok bool) {
+	scope, days = "me", 30
+	seenScope, seenPeriod := false, false
+	for _, arg := range args {
+		arg = strings.ToLower(arg)
+		switch {
+		case (arg == "me" || arg == "channel" || arg == "team") && !seenScope:
+			scope, seenScope = arg, true
+		case usagePeriods[arg] > 0 && !seenPeriod:
+			days, seenPeriod = usagePeriods[arg], true
+		default:
+			return "", 0, false
+		}
+	}
+	return scope, days, true
+}
+
+// flowUsageReply runs /flow usage and returns the reply. Anyone may see their
+// own and the channel's usage; the whole team's is for admins.
+func (b *SlackBot) flowUsageReply(userID, channelID string, args []string) string {
+	scope, days, ok := parseUsageArgs(args)
+	if !ok {
+		return usageCommandHelp
+	}
+	if scope == "team" && b.userRole(userID) != roleAdmin {
+		return "Only Slack bot admins can see the team's usage."
+	}
+	if b.claudeService == nil || b.claudeService.Usage() == nil {
+		return "❌ Usage isn't available."
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	var usageScope claude.UsageScope
+	var title string
+	switch scope {
+	case "me":
+		usageScope.UserID = userID
+		title = fmt.Sprintf("Your Claude usage, last %d days", days)
+	case "channel":
+		sessionIDs, err := b.sessionDB.ChannelSessionIDs(channelID, since)
+		if err != nil {
+			slog.Error("Failed to list channel sessions for usage", "channel_id", channelID, "error", err)
+			return "❌ Failed to get usage. Please try again."
+		}
+		usageScope.SessionIDs = sessionIDs
+		title = fmt.Sprintf("Claude usage in <#%s>, last %d days", channelID, days)
+	case "team":
+		title = fmt.Sprintf("Team Claude usage, last %d days", days)
+	}
+
+	report, err := loadUsageReport(b.claudeService.Usage(), usageScope, since, scope != "me")
+	if err != nil {
+		slog.Error("Failed to load usage report", "user_id", userID, "scope", scope, "error", err)
+		return "❌ Failed to get usage. Please try again."
+	}
+	report.title = title
+	return report.format()
+}
+
+// loadUsageReport gathers the usage in a scope since the given time, broken
+// down by user when byUser is set
+func loadUsageReport(tracker *claude.UsageTracker, scope claude.UsageScope, since time.Time, byUser bool) (*usageReport, error) {
+	report := &usageReport{}
+	var err error
+	if report.total, err = tracker.GetScopedUsage(scope, since); err != nil {
+		return nil, err
+	}
+	if byUser {
+		if report.users, err = tracker.GetUsageByUser(scope, since); err != nil {
+			return nil, err
+		}
+	}
+	if report.sessions, err = tracker.GetTopSessions(scope, since, usageTopSessions); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// format lays out a usage report for Slack
+func (r *usageReport) format() string {
+	var reply strings.Builder
+	fmt.Fprintf(&reply, "*%s*\n", r.title)
+	if r.total.Turns == 0 {
+		reply.WriteString("No Claude usage yet.")
+		return reply.String()
+	}
+	fmt.Fprintf(&reply, "%s\n", formatUsage(*r.total))
+
+	if len(r.users) > 0 {
+		reply.WriteString("\n*By user*\n")
+		for _, user := range r.users {
+			fmt.Fprintf(&reply, "• <@%s>: %s\n", user.UserID, formatUsage(user.UsageSummary))
+		}
+	}
+
+	reply.WriteString("\n*Top sessions*\n")
+	for _, session := range r.sessions {
+		fmt.Fprintf(&reply, "• `%s` (<@%s>): %s\n", session.SessionID, session.UserID, formatUsage(session.UsageSummary))
+	}
+	return strings.TrimSuffix(reply.String(), "\n")
+}
+
+// formatUsage describes usage in a line, like the App Home's usage section
+func formatUsage(usage claude.UsageSummary) string {
+	return fmt.Sprintf("$%.2f · %d turns · %d input and %d output tokens",
+		usage.CostUSD, usage.Turns, usage.InputTokens, usage.OutputTokens)
+}
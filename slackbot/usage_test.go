@@ -0,0 +1,91 @@
+package slackbot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/claude"
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestParseUsageArgs(t *testing.T) {
+	tests := []struct {
+		args  []string
+		scope string
+		days  int
+		ok    bool
+	}{
+		{nil, "me", 30, true},
+		{[]string{"channel"}, "channel", 30, true},
+		{[]string{"7d"}, "me", 7, true},
+		{[]string{"Team", "7d"}, "team", 7, true},
+		{[]string{"7d", "channel"}, "channel", 7, true},
+		{[]string{"me", "team"}, "", 0, false},
+		{[]string{"90d"}, "", 0, false},
+	}
+	for _, tt := range tests {
+		scope, days, ok := parseUsageArgs(tt.args)
+		if scope != tt.scope || days != tt.days || ok != tt.ok {
+			t.Errorf("parseUsageArgs(%q) = %q, %d, %v; want %q, %d, %v", tt.args, scope, days, ok, tt.scope, tt.days, tt.ok)
+		}
+	}
+}
+
+func TestUsageReport(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.ClaudeUsage{}); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	tracker := claude.NewUsageTracker(db)
+	for _, turn := range []struct {
+		session, user string
+		cost          float64
+	}{{"s1", "U1", 0.25}, {"s1", "U1", 0.25}, {"s2", "U2", 1.5}, {"s3", "U2", 0.1}} {
+		msg := claude.Message{Type: "result", Usage: &claude.Usage{InputTokens: 100, OutputTokens: 10}, TotalCostUSD: turn.cost}
+		if err := tracker.Record(turn.session, turn.user, msg); err != nil {
+			t.Fatalf("Record() = %v", err)
+		}
+	}
+
+	report, err := loadUsageReport(tracker, claude.UsageScope{SessionIDs: []string{"s1", "s2"}}, time.Now().Add(-time.Hour), true)
+	if err != nil {
+		t.Fatalf("loadUsageReport() = %v", err)
+	}
+	report.title = "Claude usage in <#C1>, last 7 days"
+	want := "*Claude usage in <#C1>, last 7 days*\n" +
+		"$2.00 · 3 turns · 300 input and 30 output tokens\n" +
+		"\n*By user*\n" +
+		"• <@U2>: $1.50 · 1 turns · 100 input and 10 output tokens\n" +
+		"• <@U1>: $0.50 · 2 turns · 200 input and 20 output tokens\n" +
+		"\n*Top sessions*\n" +
+		"• `s2` (<@U2>): $1.50 · 1 turns · 100 input and 10 output tokens\n" +
+		"• `s1` (<@U1>): $0.50 · 2 turns · 200 input and 20 output tokens"
+	if got := report.format(); got != want {
+		t.Errorf("format() = %q; want %q", got, want)
+	}
+
+	report, err = loadUsageReport(tracker, claude.UsageScope{UserID: "U3"}, time.Now().Add(-time.Hour), false)
+	if err != nil {
+		t.Fatalf("loadUsageReport() = %v", err)
+	}
+	if got := report.format(); !strings.HasSuffix(got, "No Claude usage yet.") {
+		t.Errorf("format() without usage = %q", got)
+	}
+}
+
+func TestFlowUsageReplyTeamIsForAdmins(t *testing.T) {
+	bot := &SlackBot{config: &config.SlackBotConfig{DefaultRole: "developer"}}
+	if got := bot.flowUsageReply("U1", "C1", []string{"team"}); !strings.HasPrefix(got, "Only") {
+		t.Errorf("developer asking for the team's usage got %q", got)
+	}
+	if got := bot.flowUsageReply("U1", "C1", []string{"month"}); got != usageCommandHelp {
+		t.Errorf("bad arguments got %q", got)
+	}
+}
@@ -4,13 +4,62 @@ import (
 	"fmt"
 	"log/slog"
 	"regexp"
+	"strings"
+	"sync"
+	"time"
 )
 
-// ChannelWhitelist manages channel access control using regex patterns
+// Prefixes of patterns that match something other than a channel's ID
+const (
+	channelNamePrefix = "name:" // Matches the channel's name, without the #
+	channelTeamPrefix = "team:" // Matches the ID of a workspace the channel belongs to
+)
+
+// channelInfoTTL is how long a channel's name and workspaces are cached
+const channelInfoTTL = time.Hour
+
+// ChannelInfo is what name: and team: patterns match a channel by
+type ChannelInfo struct {
+	Name    string
+	TeamIDs []string // The workspace the channel was created in and those it's shared with
+}
+
+// ChannelLookup resolves a channel's name and workspaces from its ID
+type ChannelLookup func(channelID string) (*ChannelInfo, error)
+
+// channelPattern is a compiled whitelist or deny list pattern
+type channelPattern struct {
+	pattern string
+	field   string // "name:", "team:", or "" for the channel ID
+	regex   *regexp.Regexp
+}
+
+// cachedChannelInfo is a looked up channel and when it was looked up
+type cachedChannelInfo struct {
+	info      *ChannelInfo
+	fetchedAt time.Time
+}
+
+// ChannelDecision is whether a channel is allowed and the pattern that decided it
+type ChannelDecision struct {
+	Allowed bool
+	Reason  string
+	Info    *ChannelInfo // nil when the channel wasn't looked up
+}
+
+// ChannelWhitelist manages channel access control using regex patterns.
+// Patterns match the channel ID, or with a name: or team: prefix the
+// channel's name or workspace. A channel matching a deny pattern is never
+// allowed.
 type ChannelWhitelist struct {
-	patterns []string
-	regexes  []*regexp.Regexp
-	debug    bool
+	patterns     []string
+	denyPatterns []string
+	allow        []channelPattern
+	deny         []channelPattern
+	lookup       ChannelLookup // Resolves names and workspaces; nil leaves name: and team: patterns unmatched
+	mu           sync.Mutex
+	infoCache    map[string]cachedChannelInfo
+	debug        bool
 }
 
 // NewChannelWhitelist creates a new channel whitelist
@@ -19,11 +68,11 @@ func NewChannelWhitelist(patterns []string, debug bool) (*ChannelWhitelist, erro
 		patterns: patterns,
 		debug:    debug,
 	}
-	
+
 	if err := whitelist.CompilePatterns(); err != nil {
 		return nil, err
 	}
-	
+
 	return whitelist, nil
 }
 
@@ -31,63 +80,188 @@ func NewChannelWhitelist(patterns []string, debug bool) (*ChannelWhitelist, erro
 func (w *ChannelWhitelist) CompilePatterns() error {
 	if len(w.patterns) == 0 {
 		// No whitelist configured - allow all channels
-		w.regexes = nil
+		w.allow = nil
 		return nil
 	}
 
-	w.regexes = make([]*regexp.Regexp, 0, len(w.patterns))
-
-	for _, pattern := range w.patterns {
-		regex, err := regexp.Compile(pattern)
-		if err != nil {
-			return fmt.Errorf("invalid regex pattern '%s': %w", pattern, err)
-		}
-		w.regexes = append(w.regexes, regex)
+	allow, err := compileChannelPatterns(w.patterns)
+	if err != nil {
+		return err
 	}
+	w.allow = allow
 
 	if w.debug {
 		slog.Debug("Compiled channel whitelist patterns",
 			"patterns", w.patterns,
-			"count", len(w.regexes))
+			"count", len(w.allow))
 	}
 
 	return nil
 }
 
-// IsAllowed checks if a channel ID matches the whitelist patterns
-func (w *ChannelWhitelist) IsAllowed(channelID string) bool {
-	// If no whitelist is configured, allow all channels
-	if len(w.regexes) == 0 {
-		return true
+// SetDenyPatterns sets the patterns of channels the bot never works in,
+// even when the whitelist allows them
+func (w *ChannelWhitelist) SetDenyPatterns(patterns []string) error {
+	deny, err := compileChannelPatterns(patterns)
+	if err != nil {
+		return err
 	}
+	w.denyPatterns = patterns
+	w.deny = deny
+	return nil
+}
 
-	// Check if channel matches any whitelist pattern
-	for _, regex := range w.regexes {
-		if regex.MatchString(channelID) {
-			if w.debug {
-				slog.Debug("Channel allowed by whitelist",
-					"channel_id", channelID,
-					"pattern", regex.String())
+// SetLookup sets how name: and team: patterns find a channel's name and workspaces
+func (w *ChannelWhitelist) SetLookup(lookup ChannelLookup) {
+	w.lookup = lookup
+}
+
+// compileChannelPatterns compiles patterns, reading their name: or team: prefix
+func compileChannelPatterns(patterns []string) ([]channelPattern, error) {
+	compiled := make([]channelPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		field, expr := "", pattern
+		for _, prefix := range []string{channelNamePrefix, channelTeamPrefix} {
+			if rest, ok := strings.CutPrefix(pattern, prefix); ok {
+				field, expr = prefix, rest
 			}
-			return true
 		}
+		regex, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex pattern '%s': %w", pattern, err)
+		}
+		compiled = append(compiled, channelPattern{pattern: pattern, field: field, regex: regex})
 	}
+	return compiled, nil
+}
 
+// IsAllowed checks if a channel matches the whitelist patterns and none of the deny patterns
+func (w *ChannelWhitelist) IsAllowed(channelID string) bool {
+	decision := w.Check(channelID)
 	if w.debug {
-		slog.Debug("Channel rejected by whitelist",
+		slog.Debug("Checked channel against whitelist",
 			"channel_id", channelID,
-			"whitelist_patterns", w.patterns)
+			"allowed", decision.Allowed,
+			"reason", decision.Reason)
+	}
+	return decision.Allowed
+}
+
+// Check decides whether the bot works in a channel and explains why. A
+// channel that can't be looked up is denied when a name: or team: deny
+// pattern could have matched it.
+func (w *ChannelWhitelist) Check(channelID string) ChannelDecision {
+	var decision ChannelDecision
+	if w.needsLookup() {
+		info, err := w.channelInfo(channelID)
+		if err != nil {
+			slog.Warn("Failed to look up channel for the whitelist", "channel_id", channelID, "error", err)
+			if w.denyNeedsLookup() {
+				return ChannelDecision{Reason: "the channel couldn't be looked up to check the deny list"}
+			}
+		}
+		decision.Info = info
 	}
 
+	for _, pattern := range w.deny {
+		if pattern.matches(channelID, decision.Info) {
+			decision.Reason = fmt.Sprintf("denied by `%s`", pattern.pattern)
+			return decision
+		}
+	}
+	// If no whitelist is configured, allow all channels
+	if len(w.allow) == 0 {
+		decision.Allowed = true
+		decision.Reason = "no whitelist is configured"
+		return decision
+	}
+	for _, pattern := range w.allow {
+		if pattern.matches(channelID, decision.Info) {
+			decision.Allowed = true
+			decision.Reason = fmt.Sprintf("allowed by `%s`", pattern.pattern)
+			return decision
+		}
+	}
+	decision.Reason = "matches no whitelist pattern"
+	return decision
+}
+
+// matches reports whether a pattern matches a channel. name: and team:
+// patterns never match a channel that wasn't looked up.
+func (p channelPattern) matches(channelID string, info *ChannelInfo) bool {
+	switch p.field {
+	case channelNamePrefix:
+		return info != nil && info.Name != "" && p.regex.MatchString(info.Name)
+	case channelTeamPrefix:
+		if info == nil {
+			return false
+		}
+		for _, teamID := range info.TeamIDs {
+			if p.regex.MatchString(teamID) {
+				return true
+			}
+		}
+		return false
+	}
+	return p.regex.MatchString(channelID)
+}
+
+// needsLookup reports whether any pattern matches a channel's name or workspace
+func (w *ChannelWhitelist) needsLookup() bool {
+	if w.lookup == nil {
+		return false
+	}
+	for _, pattern := range w.allow {
+		if pattern.field != "" {
+			return true
+		}
+	}
+	return w.denyNeedsLookup()
+}
+
+// denyNeedsLookup reports whether any deny pattern matches a channel's name or workspace
+func (w *ChannelWhitelist) denyNeedsLookup() bool {
+	for _, pattern := range w.deny {
+		if pattern.field != "" {
+			return true
+		}
+	}
 	return false
 }
 
+// channelInfo looks up a channel, caching what it finds for channelInfoTTL
+func (w *ChannelWhitelist) channelInfo(channelID string) (*ChannelInfo, error) {
+	w.mu.Lock()
+	cached, ok := w.infoCache[channelID]
+	w.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < channelInfoTTL {
+		return cached.info, nil
+	}
+
+	info, err := w.lookup(channelID)
+	if err != nil {
+		return nil, err
+	}
+	w.mu.Lock()
+	if w.infoCache == nil {
+		w.infoCache = make(map[string]cachedChannelInfo)
+	}
+	w.infoCache[channelID] = cachedChannelInfo{info: info, fetchedAt: time.Now()}
+	w.mu.Unlock()
+	return info, nil
+}
+
 // GetPatterns returns the configured patterns
 func (w *ChannelWhitelist) GetPatterns() []string {
 	return w.patterns
 }
 
+// GetDenyPatterns returns the configured deny patterns
+func (w *ChannelWhitelist) GetDenyPatterns() []string {
+	return w.denyPatterns
+}
+
 // HasWhitelist returns true if whitelist patterns are configured
 func (w *ChannelWhitelist) HasWhitelist() bool {
 	return len(w.patterns) > 0
-}
\ No newline at end of file
+}
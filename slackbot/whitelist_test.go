@@ -1,8 +1,10 @@
 package slackbot
 
 import (
+	"fmt"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewChannelWhitelist(t *testing.T) {
@@ -402,4 +404,125 @@ func TestChannelWhitelist_GetPatterns(t *testing.T) {
 			t.Errorf("GetPatterns()[%d] = %q, expected %q", i, result[i], pattern)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestChannelWhitelist_Check(t *testing.T) {
+	channels := map[string]*ChannelInfo{
+		"C001": {Name: "eng-backend", TeamIDs: []string{"T001"}},
+		"C002": {Name: "eng-secrets", TeamIDs: []string{"T001"}},
+		"C003": {Name: "sales", TeamIDs: []string{"T002", "T001"}},
+		"C004": {Name: "random", TeamIDs: []string{"T003"}},
+	}
+	lookup := func(channelID string) (*ChannelInfo, error) {
+		info, ok := channels[channelID]
+		if !ok {
+			return nil, fmt.Errorf("channel_not_found")
+		}
+		return info, nil
+	}
+
+	tests := []struct {
+		name      string
+		allow     []string
+		deny      []string
+		channelID string
+		expected  bool
+	}{
+		{"name pattern", []string{"name:^eng-"}, nil, "C001", true},
+		{"name pattern misses", []string{"name:^eng-"}, nil, "C004", false},
+		{"team pattern matches a shared workspace", []string{"team:^T001$"}, nil, "C003", true},
+		{"team pattern misses", []string{"team:^T001$"}, nil, "C004", false},
+		{"ID and name patterns together", []string{"^C004$", "name:^eng-"}, nil, "C004", true},
+		{"deny wins over allow", []string{"name:^eng-"}, []string{"name:secrets"}, "C002", false},
+		{"deny by ID", []string{"team:T001"}, []string{"^C001$"}, "C001", false},
+		{"deny without whitelist", nil, []string{"team:^T003$"}, "C004", false},
+		{"not denied without whitelist", nil, []string{"team:^T003$"}, "C001", true},
+		{"name allow fails for unknown channel", []string{"name:.*"}, nil, "C999", false},
+		{"name deny fails closed for unknown channel", nil, []string{"name:secrets"}, "C999", false},
+		{"ID deny doesn't need a lookup", nil, []string{"^C001$"}, "C999", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			whitelist, err := NewChannelWhitelist(tt.allow, false)
+			if err != nil {
+				t.Fatalf("NewChannelWhitelist() failed: %v", err)
+			}
+			if err := whitelist.SetDenyPatterns(tt.deny); err != nil {
+				t.Fatalf("SetDenyPatterns() failed: %v", err)
+			}
+			whitelist.SetLookup(lookup)
+
+			decision := whitelist.Check(tt.channelID)
+			if decision.Allowed != tt.expected {
+				t.Errorf("Check(%q) = %v (%s), expected %v", tt.channelID, decision.Allowed, decision.Reason, tt.expected)
+			}
+		})
+	}
+}
+
+func TestChannelWhitelist_NameWithoutLookup(t *testing.T) {
+	whitelist, err := NewChannelWhitelist([]string{"name:.*"}, false)
+	if err != nil {
+		t.Fatalf("NewChannelWhitelist() failed: %v", err)
+	}
+	if whitelist.IsAllowed("C001") {
+		t.Errorf("IsAllowed() = true, expected name patterns not to match without a lookup")
+	}
+}
+
+func TestChannelWhitelist_CachesLookups(t *testing.T) {
+	lookups := 0
+	whitelist, err := NewChannelWhitelist([]string{"name:^eng-"}, false)
+	if err != nil {
+		t.Fatalf("NewChannelWhitelist() failed: %v", err)
+	}
+	whitelist.SetLookup(func(channelID string) (*ChannelInfo, error) {
+		lookups++
+		return &ChannelInfo{Name: "eng-backend"}, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		if !whitelist.IsAllowed("C001") {
+			t.Fatalf("IsAllowed() = false, expected true")
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("lookups = %d, expected 1", lookups)
+	}
+
+	whitelist.infoCache["C001"] = cachedChannelInfo{info: &ChannelInfo{Name: "eng-backend"}, fetchedAt: time.Now().Add(-2 * channelInfoTTL)}
+	whitelist.IsAllowed("C001")
+	if lookups != 2 {
+		t.Errorf("lookups = %d, expected an expired entry to be looked up again", lookups)
+	}
+}
+
+func TestSetDenyPatternsInvalid(t *testing.T) {
+	whitelist, err := NewChannelWhitelist(nil, false)
+	if err != nil {
+		t.Fatalf("NewChannelWhitelist() failed: %v", err)
+	}
+	if err := whitelist.SetDenyPatterns([]string{"name:[invalid"}); err == nil {
+		t.Errorf("SetDenyPatterns() expected error but got none")
+	}
+}
+
+func TestParseSlackChannelID(t *testing.T) {
+	tests := []struct {
+		input string
+		id    string
+		ok    bool
+	}{
+		{"<#C0123ABC|general>", "C0123ABC", true},
+		{"<#C0123ABC>", "C0123ABC", true},
+		{"#general", "", false},
+		{"<@U0123ABC>", "", false},
+	}
+	for _, tt := range tests {
+		id, ok := parseSlackChannelID(tt.input)
+		if id != tt.id || ok != tt.ok {
+			t.Errorf("parseSlackChannelID(%q) = %q, %v, expected %q, %v", tt.input, id, ok, tt.id, tt.ok)
+		}
+	}
+}
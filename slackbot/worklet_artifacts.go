@@ -0,0 +1,64 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/breadchris/flow/events"
+)
+
+// notifyArtifacts posts links to a worklet's build artifacts in the thread it
+// was created from when a prompt publishes them
+func (b *SlackBot) notifyArtifacts(ctx context.Context) {
+	if b.workletManager == nil {
+		return
+	}
+	published, unsubscribe := events.Channel(b.events, events.TopicArtifacts)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case artifacts := <-published:
+			workletObj, err := b.workletManager.GetWorklet(artifacts.WorkletID)
+			if err != nil || workletObj.Environment == nil {
+				continue
+			}
+			channelID := workletObj.Environment.Data["SLACK_CHANNEL"]
+			threadTS := workletObj.Environment.Data["SLACK_THREAD_TS"]
+			if channelID == "" || threadTS == "" {
+				continue
+			}
+			if _, err := b.postMessage(channelID, threadTS, artifactsMessage(artifacts)); err != nil {
+				slog.Error("Failed to post worklet artifacts", "error", err, "worklet_id", artifacts.WorkletID)
+			}
+		}
+	}
+}
+
+// artifactsMessage lists published artifacts as download links
+func artifactsMessage(published events.ArtifactsPublished) string {
+	var text strings.Builder
+	text.WriteString("📦 Build artifacts published:")
+	for _, artifact := range published.Artifacts {
+		fmt.Fprintf(&text, "\n• <%s|%s> (%s)", artifact.URL, artifact.Path, formatSize(artifact.Size))
+	}
+	return text.String()
+}
+
+// formatSize shows a number of bytes in the largest unit it's at least one of
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit && exp < 3; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGT"[exp])
+}
@@ -0,0 +1,38 @@
+package slackbot
+
+import (
+	"testing"
+
+	"github.com/breadchris/flow/events"
+)
+
+func TestArtifactsMessage(t *testing.T) {
+	got := artifactsMessage(events.ArtifactsPublished{
+		WorkletID: "w1",
+		Artifacts: []events.Artifact{
+			{Path: "dist/app.zip", URL: "https://example.com/app.zip", Size: 3 << 20},
+			{Path: "report.txt", URL: "https://example.com/report.txt", Size: 512},
+		},
+	})
+	want := "📦 Build artifacts published:\n" +
+		"• <https://example.com/app.zip|dist/app.zip> (3.0 MB)\n" +
+		"• <https://example.com/report.txt|report.txt> (512 B)"
+	if got != want {
+		t.Errorf("artifactsMessage() = %q; want %q", got, want)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		0:         "0 B",
+		1023:      "1023 B",
+		1536:      "1.5 KB",
+		5 << 30:   "5.0 GB",
+		123 << 20: "123.0 MB",
+	}
+	for size, want := range tests {
+		if got := formatSize(size); got != want {
+			t.Errorf("formatSize(%d) = %q; want %q", size, got, want)
+		}
+	}
+}
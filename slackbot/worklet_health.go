@@ -0,0 +1,64 @@
+package slackbot
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/worklet"
+)
+
+// watchWorkletHealth tells a running worklet's thread when it fails its
+// health check and when it recovers or goes to sleep, until it's stopped or
+// rebuilt
+func (b *SlackBot) watchWorkletHealth(ctx context.Context, workletID, channelID, threadTS string) {
+	statuses, unsubscribe := events.Channel(b.events, events.TopicWorkletStatus)
+	defer unsubscribe()
+
+	last := events.WorkletStatus{Status: string(worklet.StatusRunning)}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case status := <-statuses:
+			if status.WorkletID != workletID {
+				continue
+			}
+			text, done := workletHealthMessage(last, status)
+			last = status
+			if text != "" {
+				if _, err := b.postMessage(channelID, threadTS, text); err != nil {
+					slog.Error("Failed to post worklet health", "worklet_id", workletID, "error", err)
+				}
+			}
+			if done {
+				return
+			}
+		}
+	}
+}
+
+// workletHealthMessage returns what to tell a worklet's thread about a status
+// change, if anything, and whether the worklet is no longer running
+func workletHealthMessage(last, status events.WorkletStatus) (string, bool) {
+	switch worklet.Status(status.Status) {
+	case worklet.StatusUnhealthy:
+		if status == last {
+			return "", false
+		}
+		return "⚠️ Worklet is unhealthy: " + status.Error, false
+	case worklet.StatusRunning:
+		if worklet.Status(last.Status) == worklet.StatusUnhealthy {
+			return "✅ Worklet is healthy again.", false
+		}
+		return "", false
+	case worklet.StatusSleeping:
+		// Its next request wakes it, so it's still followed
+		if status == last {
+			return "", false
+		}
+		return "💤 Worklet went to sleep after being idle. Opening its preview wakes it up.", false
+	default:
+		return "", true
+	}
+}
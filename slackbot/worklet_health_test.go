@@ -0,0 +1,35 @@
+package slackbot
+
+import (
+	"testing"
+
+	"github.com/breadchris/flow/events"
+)
+
+func TestWorkletHealthMessage(t *testing.T) {
+	running := events.WorkletStatus{WorkletID: "w1", Status: "running"}
+	unhealthy := events.WorkletStatus{WorkletID: "w1", Status: "unhealthy", Error: "Health check failed: / returned 502; restarting (attempt 1 of 3)"}
+	sleeping := events.WorkletStatus{WorkletID: "w1", Status: "sleeping"}
+
+	tests := []struct {
+		name       string
+		last, next events.WorkletStatus
+		text       string
+		done       bool
+	}{
+		{"fails its check", running, unhealthy, "⚠️ Worklet is unhealthy: Health check failed: / returned 502; restarting (attempt 1 of 3)", false},
+		{"same failure again", unhealthy, unhealthy, "", false},
+		{"recovers", unhealthy, running, "✅ Worklet is healthy again.", false},
+		{"still running", running, running, "", false},
+		{"goes to sleep", running, sleeping, "💤 Worklet went to sleep after being idle. Opening its preview wakes it up.", false},
+		{"wakes up", sleeping, running, "", false},
+		{"stopped", running, events.WorkletStatus{WorkletID: "w1", Status: "stopped"}, "", true},
+		{"rebuilt", unhealthy, events.WorkletStatus{WorkletID: "w1", Status: "building"}, "", true},
+	}
+	for _, tt := range tests {
+		text, done := workletHealthMessage(tt.last, tt.next)
+		if text != tt.text || done != tt.done {
+			t.Errorf("%s: workletHealthMessage() = %q, %v, expected %q, %v", tt.name, text, done, tt.text, tt.done)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package slackbot
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/worklet"
+	"github.com/slack-go/slack"
+)
+
+const (
+	workletLogInterval = 20 * time.Second // Time between build log snippets posted to a worklet's thread
+	workletLogLines    = 30               // Most lines in each snippet
+)
+
+// workletLogHeadings name the stages of a worklet's log
+var workletLogHeadings = map[string]string{
+	"build":  "🔨 Build log",
+	"deploy": "🚀 Container log",
+}
+
+// workletLogTail keeps the last lines a worklet logged since its last snippet
+type workletLogTail struct {
+	stage   string
+	lines   []string
+	skipped int // Lines logged since the last snippet that no longer fit
+}
+
+func (t *workletLogTail) add(log events.WorkletLog) {
+	t.stage = log.Stage
+	t.lines = append(t.lines, log.Line)
+	if len(t.lines) > workletLogLines {
+		t.lines = t.lines[1:]
+		t.skipped++
+	}
+}
+
+// snippet returns the heading and text of the lines kept since the last
+// snippet and starts a new one. ok is false when nothing was logged.
+func (t *workletLogTail) snippet() (heading, text string, ok bool) {
+	if len(t.lines) == 0 {
+		return "", "", false
+	}
+	heading = workletLogHeadings[t.stage]
+	if heading == "" {
+		heading = "📜 Worklet log"
+	}
+	if t.skipped > 0 {
+		heading += fmt.Sprintf(" (last %d lines, %d earlier skipped)", len(t.lines), t.skipped)
+	}
+	text = strings.Join(t.lines, "\n")
+	t.lines, t.skipped = nil, 0
+	return heading, text, true
+}
+
+// postWorkletLog posts what a worklet logged since its last snippet to its
+// thread, collapsed like tool output
+func (b *SlackBot) postWorkletLog(channelID, threadTS string, tail *workletLogTail) {
+	heading, text, ok := tail.snippet()
+	if !ok {
+		return
+	}
+	if _, err := b.postBlocks(channelID, threadTS, heading, toolOutputBlocks(heading, text, false)); err != nil {
+		slog.Error("Failed to post worklet log", "thread_ts", threadTS, "error", err)
+	}
+}
+
+// uploadWorkletLog attaches the full build and container log of a worklet
+// that failed to its thread
+func (b *SlackBot) uploadWorkletLog(channelID, threadTS string, workletObj *worklet.Worklet) {
+	if workletObj.BuildLogs == "" {
+		return
+	}
+	_, err := b.client.UploadFileV2(slack.UploadFileV2Parameters{
+		Content:         workletObj.BuildLogs,
+		FileSize:        len(workletObj.BuildLogs),
+		Filename:        fmt.Sprintf("worklet-%s.log", workletObj.ID),
+		Title:           "Worklet build log",
+		Channel:         channelID,
+		ThreadTimestamp: threadTS,
+	})
+	if err != nil {
+		slog.Error("Failed to upload worklet log", "worklet_id", workletObj.ID, "thread_ts", threadTS, "error", err)
+		return
+	}
+	slog.Info("Uploaded worklet log",
+		"worklet_id", workletObj.ID,
+		"thread_ts", threadTS,
+		"size", len(workletObj.BuildLogs),
+		"action", "worklet_log_uploaded",
+	)
+}
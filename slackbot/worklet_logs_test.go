@@ -0,0 +1,38 @@
+package slackbot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/breadchris/flow/events"
+)
+
+func TestWorkletLogTail(t *testing.T) {
+	var tail workletLogTail
+	if _, _, ok := tail.snippet(); ok {
+		t.Errorf("empty tail returned a snippet")
+	}
+
+	tail.add(events.WorkletLog{WorkletID: "w1", Stage: "build", Line: "Step 1/4 : FROM node:18"})
+	tail.add(events.WorkletLog{WorkletID: "w1", Stage: "build", Line: "Step 2/4 : COPY . ."})
+	heading, text, ok := tail.snippet()
+	if !ok || heading != "🔨 Build log" || text != "Step 1/4 : FROM node:18\nStep 2/4 : COPY . ." {
+		t.Errorf("snippet() = %q, %q, %v", heading, text, ok)
+	}
+	if _, _, ok := tail.snippet(); ok {
+		t.Errorf("snippet repeated lines that were already posted")
+	}
+
+	for i := 1; i <= workletLogLines+5; i++ {
+		tail.add(events.WorkletLog{WorkletID: "w1", Stage: "deploy", Line: fmt.Sprintf("line %d", i)})
+	}
+	heading, text, _ = tail.snippet()
+	if want := fmt.Sprintf("🚀 Container log (last %d lines, 5 earlier skipped)", workletLogLines); heading != want {
+		t.Errorf("heading = %q, want %q", heading, want)
+	}
+	lines := strings.Split(text, "\n")
+	if len(lines) != workletLogLines || lines[0] != "line 6" {
+		t.Errorf("snippet kept %d lines starting with %q, want %d starting with \"line 6\"", len(lines), lines[0], workletLogLines)
+	}
+}
@@ -0,0 +1,54 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/worklet"
+)
+
+// notifyScheduleRuns posts how each scheduled prompt to a worklet went in the
+// thread the worklet was created from
+func (b *SlackBot) notifyScheduleRuns(ctx context.Context) {
+	if b.workletManager == nil {
+		return
+	}
+	finished, unsubscribe := events.Channel(b.events, events.TopicScheduleRun)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case run := <-finished:
+			workletObj, err := b.workletManager.GetWorklet(run.WorkletID)
+			if err != nil || workletObj.Environment == nil {
+				continue
+			}
+			channelID := workletObj.Environment.Data["SLACK_CHANNEL"]
+			threadTS := workletObj.Environment.Data["SLACK_THREAD_TS"]
+			if channelID == "" || threadTS == "" {
+				continue
+			}
+			if _, err := b.postMessage(channelID, threadTS, scheduleRunMessage(run)); err != nil {
+				slog.Error("Failed to post worklet schedule run", "error", err, "worklet_id", run.WorkletID, "schedule_id", run.ScheduleID)
+			}
+		}
+	}
+}
+
+// scheduleRunMessage describes how a scheduled prompt went
+func scheduleRunMessage(run events.ScheduleRunFinished) string {
+	header := fmt.Sprintf("⏰ _Scheduled prompt (`%s`):_ %s", run.Cron, clipBytes(run.Prompt, 200))
+	switch {
+	case run.Status == worklet.ScheduleRunFailed:
+		return fmt.Sprintf("%s\n❌ Failed: %s", header, run.Error)
+	case run.PRURL != "":
+		return fmt.Sprintf("%s\n✅ Changed %d file(s) and opened <%s|a pull request>.", header, run.FilesChanged, run.PRURL)
+	case run.FilesChanged > 0:
+		return fmt.Sprintf("%s\n✅ Changed %d file(s).", header, run.FilesChanged)
+	}
+	return fmt.Sprintf("%s\n✅ Finished with nothing to change.", header)
+}
@@ -0,0 +1,36 @@
+package slackbot
+
+import (
+	"testing"
+
+	"github.com/breadchris/flow/events"
+)
+
+func TestScheduleRunMessage(t *testing.T) {
+	base := events.ScheduleRunFinished{Cron: "0 3 * * *", Prompt: "Update dependencies", Status: "succeeded"}
+	header := "⏰ _Scheduled prompt (`0 3 * * *`):_ Update dependencies\n"
+
+	tests := []struct {
+		name string
+		edit func(*events.ScheduleRunFinished)
+		want string
+	}{
+		{"unchanged", func(r *events.ScheduleRunFinished) {}, "✅ Finished with nothing to change."},
+		{"changed", func(r *events.ScheduleRunFinished) { r.FilesChanged = 2 }, "✅ Changed 2 file(s)."},
+		{"pull request", func(r *events.ScheduleRunFinished) {
+			r.FilesChanged = 3
+			r.PRURL = "https://github.com/acme/app/pull/7"
+		}, "✅ Changed 3 file(s) and opened <https://github.com/acme/app/pull/7|a pull request>."},
+		{"failed", func(r *events.ScheduleRunFinished) {
+			r.Status = "failed"
+			r.Error = "worklet tests failed"
+		}, "❌ Failed: worklet tests failed"},
+	}
+	for _, tt := range tests {
+		run := base
+		tt.edit(&run)
+		if got := scheduleRunMessage(run); got != header+tt.want {
+			t.Errorf("%s: scheduleRunMessage() = %q; want %q", tt.name, got, header+tt.want)
+		}
+	}
+}
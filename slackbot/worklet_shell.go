@@ -0,0 +1,84 @@
+package slackbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/breadchris/flow/worklet"
+	"github.com/slack-go/slack"
+	"gorm.io/gorm"
+)
+
+// shellOutputLimit is how much of a /flow sh command's output is posted; the end is kept
+const shellOutputLimit = 3000
+
+// flowShellReply answers /flow sh without a command to run, or typed in a
+// thread, with how to use a worklet's shell
+func (b *SlackBot) flowShellReply(userID string, args []string) string {
+	if b.userRole(userID) != roleAdmin {
+		return "Only Slack bot admins can use worklet shells."
+	}
+	if len(args) > 1 {
+		return "Run `/flow sh <worklet> <command>` in a channel, outside a thread."
+	}
+	return fmt.Sprintf("Run a command with `/flow sh %s <command>`, or open an interactive shell with a WebSocket to `/api/worklet/worklets/%s/shell`. Both are recorded.", args[0], args[0])
+}
+
+// runWorkletShellCommand runs /flow sh <worklet> <command> and shows its
+// output to the admin who ran it
+func (b *SlackBot) runWorkletShellCommand(userID, channelID, workletID, command string) {
+	text := b.workletShellCommandReply(userID, workletID, command)
+	if _, err := b.client.PostEphemeral(channelID, userID, slack.MsgOptionText(text, false)); err != nil {
+		slog.Error("Failed to post worklet shell output", "user_id", userID, "worklet_id", workletID, "error", err)
+	}
+}
+
+func (b *SlackBot) workletShellCommandReply(userID, workletID, command string) string {
+	if b.workletManager == nil {
+		return "Worklets aren't available on this server."
+	}
+	output, exitCode, err := b.workletManager.RunShellCommand(context.Background(), workletID, userID, command)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return fmt.Sprintf("There's no worklet `%s`.", workletID)
+	case errors.Is(err, worklet.ErrNoContainer), errors.Is(err, worklet.ErrNotRunning):
+		return fmt.Sprintf("Worklet `%s` has no running container to run commands in.", workletID)
+	case err != nil && output == "":
+		return fmt.Sprintf("❌ Failed to run the command: %v", err)
+	}
+	return formatShellOutput(command, output, exitCode, err)
+}
+
+// formatShellOutput shows a command's output, or its end when it's long
+func formatShellOutput(command, output string, exitCode int, err error) string {
+	var reply strings.Builder
+	fmt.Fprintf(&reply, "`$ %s`", command)
+	switch {
+	case err != nil:
+		fmt.Fprintf(&reply, " didn't finish: %v\n", err)
+	case exitCode != 0:
+		fmt.Fprintf(&reply, " exited with code %d\n", exitCode)
+	default:
+		reply.WriteString("\n")
+	}
+
+	output = strings.TrimRight(output, "\n")
+	if output == "" {
+		reply.WriteString("_No output._")
+		return reply.String()
+	}
+	if len(output) > shellOutputLimit {
+		start := len(output) - shellOutputLimit
+		for start < len(output) && !utf8.RuneStart(output[start]) {
+			start++
+		}
+		output = output[start:]
+		reply.WriteString("_Only the end of the output is shown._\n")
+	}
+	fmt.Fprintf(&reply, "```\n%s\n```", strings.ReplaceAll(output, "```", "` ` `"))
+	return reply.String()
+}
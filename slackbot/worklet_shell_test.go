@@ -0,0 +1,51 @@
+package slackbot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/breadchris/flow/config"
+)
+
+func TestFlowShellReply(t *testing.T) {
+	bot := &SlackBot{config: &config.SlackBotConfig{Admins: []string{"UADMIN"}, DefaultRole: "developer"}}
+
+	if reply := bot.flowShellReply("UDEV", []string{"w1"}); !strings.Contains(reply, "Only Slack bot admins") {
+		t.Errorf("a developer's /flow sh replied %q", reply)
+	}
+	if reply := bot.flowShellReply("UADMIN", []string{"w1"}); !strings.Contains(reply, "/api/worklet/worklets/w1/shell") {
+		t.Errorf("/flow sh w1 replied %q", reply)
+	}
+	if reply := bot.flowShellReply("UADMIN", []string{"w1", "ls"}); !strings.Contains(reply, "outside a thread") {
+		t.Errorf("/flow sh w1 ls in a thread replied %q", reply)
+	}
+}
+
+func TestFormatShellOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		exitCode int
+		err      error
+		want     []string
+	}{
+		{"succeeded", "app.js\npackage.json\n", 0, nil, []string{"`$ ls`\n```\napp.js\npackage.json\n```"}},
+		{"failed", "ls: cannot access 'nope'\n", 2, nil, []string{"`$ ls` exited with code 2\n", "cannot access"}},
+		{"timed out", "partial\n", 0, errors.New("command didn't finish"), []string{"didn't finish", "partial"}},
+		{"no output", "", 0, nil, []string{"_No output._"}},
+		{"long", strings.Repeat("x", shellOutputLimit) + "the end", 0, nil, []string{"Only the end", "the end\n```"}},
+	}
+
+	for _, tt := range tests {
+		got := formatShellOutput("ls", tt.output, tt.exitCode, tt.err)
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: formatShellOutput() = %q, missing %q", tt.name, got, want)
+			}
+		}
+		if len(got) > shellOutputLimit+200 {
+			t.Errorf("%s: formatShellOutput() is %d bytes", tt.name, len(got))
+		}
+	}
+}
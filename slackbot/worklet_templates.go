@@ -0,0 +1,63 @@
+package slackbot
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/breadchris/flow/worklet"
+	"gorm.io/gorm"
+)
+
+// flowTemplate looks up the template /flow template names, returning why it
+// can't be used if it can't
+func (b *SlackBot) flowTemplate(name string) (*worklet.WorkletTemplate, string) {
+	if b.workletManager == nil {
+		return nil, "Worklets aren't available on this server."
+	}
+	template, err := b.workletManager.GetTemplate(name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Sprintf("There's no template named `%s`. `/flow template list` shows the templates, and `/flow new template ...` starts a Claude session with a prompt that begins with \"template\".", name)
+	}
+	if err != nil {
+		slog.Error("Failed to get worklet template", "template", name, "error", err)
+		return nil, "❌ Failed to look up the template. Please try again."
+	}
+	return template, ""
+}
+
+// listWorkletTemplates is the reply to /flow template list
+func (b *SlackBot) listWorkletTemplates() string {
+	if b.workletManager == nil {
+		return "Worklets aren't available on this server."
+	}
+	templates, err := b.workletManager.ListTemplates()
+	if err != nil {
+		slog.Error("Failed to list worklet templates", "error", err)
+		return "❌ Failed to list the templates. Please try again."
+	}
+	return formatWorkletTemplates(templates)
+}
+
+// formatWorkletTemplates lists templates with their repositories and descriptions
+func formatWorkletTemplates(templates []*worklet.WorkletTemplate) string {
+	if len(templates) == 0 {
+		return "There are no worklet templates yet. Create one with `POST /api/worklet/templates`."
+	}
+
+	var reply strings.Builder
+	fmt.Fprintf(&reply, "*Worklet templates* (%d)\n", len(templates))
+	for _, template := range templates {
+		fmt.Fprintf(&reply, "• `%s`: %s", template.Name, template.GitRepo)
+		if template.Branch != "" {
+			fmt.Fprintf(&reply, " on `%s`", template.Branch)
+		}
+		if template.Description != "" {
+			fmt.Fprintf(&reply, ". %s", template.Description)
+		}
+		reply.WriteString("\n")
+	}
+	reply.WriteString("Start one with `/flow template <name> <prompt>`.")
+	return reply.String()
+}
@@ -0,0 +1,29 @@
+package slackbot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/breadchris/flow/worklet"
+)
+
+func TestFormatWorkletTemplates(t *testing.T) {
+	if got := formatWorkletTemplates(nil); !strings.Contains(got, "no worklet templates") {
+		t.Errorf("formatWorkletTemplates(nil) = %q", got)
+	}
+
+	got := formatWorkletTemplates([]*worklet.WorkletTemplate{
+		{Name: "landing", GitRepo: "https://github.com/acme/site", Branch: "main", Description: "Marketing pages"},
+		{Name: "api", GitRepo: "https://github.com/acme/api"},
+	})
+	for _, want := range []string{
+		"*Worklet templates* (2)",
+		"• `landing`: https://github.com/acme/site on `main`. Marketing pages\n",
+		"• `api`: https://github.com/acme/api\n",
+		"/flow template <name> <prompt>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("formatWorkletTemplates() = %q, missing %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Package storage uploads objects to S3-compatible storage, such as Amazon S3
+// or Google Cloud Storage with HMAC keys, and links to them
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/breadchris/flow/config"
+)
+
+// maxLinkExpiry is the longest a presigned link can work for
+const maxLinkExpiry = 7 * 24 * time.Hour
+
+const (
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	timeFormat       = "20060102T150405Z"
+	dateFormat       = "20060102"
+)
+
+// Client stores objects in one bucket, under its prefix, signing requests
+// with AWS Signature Version 4
+type Client struct {
+	cfg        config.ArtifactStorageConfig
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// New returns a client for the configured bucket, or nil when none is configured
+func New(cfg config.ArtifactStorageConfig) *Client {
+	if cfg.Bucket == "" {
+		return nil
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return &Client{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+		now:        time.Now,
+	}
+}
+
+// Put uploads data as the object key
+func (c *Client) Put(ctx context.Context, key, contentType string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.objectURL(key).String(), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create upload request: %w", err)
+	}
+	// The path is sent encoded as it's signed
+	req.URL.RawPath = escapePath(req.URL.Path)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	sum := sha256.Sum256(data)
+	c.sign(req, hex.EncodeToString(sum[:]), c.now())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to upload %s: %s: %s", key, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// URL returns a link that downloads the object key: under the public URL
+// when there is one, or else presigned to work for the link expiry
+func (c *Client) URL(key string) string {
+	if c.cfg.PublicURL != "" {
+		return strings.TrimSuffix(c.cfg.PublicURL, "/") + "/" + escapePath(c.cfg.Prefix+key)
+	}
+	expiry := c.cfg.LinkExpiry
+	if expiry <= 0 || expiry > maxLinkExpiry {
+		expiry = maxLinkExpiry
+	}
+	return c.presign(c.objectURL(key), expiry, c.now())
+}
+
+// objectURL returns the URL of the object key: path-style under a configured
+// endpoint, or on the bucket's Amazon S3 host
+func (c *Client) objectURL(key string) *url.URL {
+	objectPath := "/" + c.cfg.Prefix + key
+	if c.cfg.Endpoint == "" {
+		return &url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", c.cfg.Bucket, c.cfg.Region), Path: objectPath}
+	}
+	u, err := url.Parse(c.cfg.Endpoint)
+	if err != nil {
+		u = &url.URL{Scheme: "https", Host: c.cfg.Endpoint}
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.cfg.Bucket + objectPath
+	return u
+}
+
+// sign adds the headers that authenticate a request whose body hashes to payloadHash
+func (c *Client) sign(req *http.Request, payloadHash string, now time.Time) {
+	req.Header.Set("X-Amz-Date", now.UTC().Format(timeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	signature := c.signature(canonicalRequest, now)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signingAlgorithm, c.cfg.AccessKeyID, c.scope(now), signedHeaders, signature))
+}
+
+// presign returns u with a query that authenticates GETs of it until expiry passes
+func (c *Client) presign(u *url.URL, expiry time.Duration, now time.Time) string {
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", signingAlgorithm)
+	query.Set("X-Amz-Credential", c.cfg.AccessKeyID+"/"+c.scope(now))
+	query.Set("X-Amz-Date", now.UTC().Format(timeFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expiry.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		escapePath(u.Path),
+		canonicalQuery(query),
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+	query.Set("X-Amz-Signature", c.signature(canonicalRequest, now))
+
+	signed := *u
+	signed.RawPath = escapePath(u.Path)
+	signed.RawQuery = canonicalQuery(query)
+	return signed.String()
+}
+
+// scope is the date, region, and service a signature is good for
+func (c *Client) scope(now time.Time) string {
+	return now.UTC().Format(dateFormat) + "/" + c.cfg.Region + "/s3/aws4_request"
+}
+
+// signature signs a canonical request with a key derived from the secret for the day
+func (c *Client) signature(canonicalRequest string, now time.Time) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		signingAlgorithm,
+		now.UTC().Format(timeFormat),
+		c.scope(now),
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.cfg.SecretAccessKey), now.UTC().Format(dateFormat))
+	for _, part := range []string{c.cfg.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes a query sorted by name, as signatures require
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, escape(name, true)+"="+escape(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escapePath encodes each segment of an object's path
+func escapePath(p string) string {
+	return escape(p, false)
+}
+
+// escape percent-encodes everything but unreserved characters, and slashes
+// unless encodeSlash is set
+func escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~', ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,155 @@
+package worklet
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/models"
+)
+
+// maxArtifactPatterns is the most artifact paths or globs a worklet can declare
+const maxArtifactPatterns = 20
+
+// defaultArtifactMaxSize is the largest file published when the config sets no limit
+const defaultArtifactMaxSize = 100 << 20
+
+// artifactPatternRegex matches the paths and globs a worklet can declare;
+// they're expanded by the container's shell, so nothing else is allowed
+var artifactPatternRegex = regexp.MustCompile(`^[\w.*?\[\]/-]+$`)
+
+// PublishedArtifact is a build artifact uploaded to object storage after a prompt
+type PublishedArtifact struct {
+	Path        string    `json:"path"` // Relative to the container's working directory
+	Key         string    `json:"key"`  // The object's key under the configured prefix
+	Size        int64     `json:"size"`
+	URL         string    `json:"url"`
+	PromptID    string    `json:"prompt_id"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// ArtifactFile is a file read from a worklet's container to publish
+type ArtifactFile struct {
+	Path string
+	Data []byte
+}
+
+// validateArtifacts rejects artifact patterns outside the container's working
+// directory or that the shell would do more than expand
+func validateArtifacts(patterns []string) error {
+	if len(patterns) > maxArtifactPatterns {
+		return fmt.Errorf("at most %d artifacts can be declared", maxArtifactPatterns)
+	}
+	for _, pattern := range patterns {
+		if !artifactPatternRegex.MatchString(pattern) || strings.HasPrefix(pattern, "/") ||
+			strings.HasPrefix(pattern, "-") || strings.Contains(pattern, "..") {
+			return fmt.Errorf("invalid artifact %q: use a path or glob relative to the working directory", pattern)
+		}
+	}
+	return nil
+}
+
+// publishArtifacts uploads the files matching the worklet's artifacts from
+// its container and records links to them on the worklet. Worklets without
+// artifacts, or servers without storage, publish nothing.
+func (m *Manager) publishArtifacts(ctx context.Context, worklet *Worklet, promptID string) {
+	if m.artifacts == nil || worklet.Artifacts == nil || len(worklet.Artifacts.Data) == 0 {
+		return
+	}
+	logLine := m.publishLog(worklet)
+
+	read := m.readArtifacts
+	if read == nil {
+		read = m.dockerClient.ReadFiles
+	}
+	files, err := read(ctx, worklet.ContainerID, worklet.Artifacts.Data, m.artifactMaxSize())
+	if err != nil {
+		slog.Error("Failed to read worklet artifacts", "error", err, "workletID", worklet.ID)
+		logLine("artifacts", fmt.Sprintf("Failed to read artifacts: %v", err))
+		return
+	}
+	if len(files) == 0 {
+		logLine("artifacts", "No files match "+strings.Join(worklet.Artifacts.Data, ", "))
+		return
+	}
+
+	now := time.Now()
+	published := make([]PublishedArtifact, 0, len(files))
+	for _, file := range files {
+		key := path.Join(worklet.ID, promptID, file.Path)
+		if err := m.artifacts.Put(ctx, key, artifactContentType(file), file.Data); err != nil {
+			slog.Error("Failed to upload worklet artifact", "error", err, "workletID", worklet.ID, "path", file.Path)
+			logLine("artifacts", fmt.Sprintf("Failed to upload %s: %v", file.Path, err))
+			continue
+		}
+		logLine("artifacts", fmt.Sprintf("Published %s (%d bytes)", file.Path, len(file.Data)))
+		published = append(published, PublishedArtifact{
+			Path:        file.Path,
+			Key:         key,
+			Size:        int64(len(file.Data)),
+			URL:         m.artifacts.URL(key),
+			PromptID:    promptID,
+			PublishedAt: now,
+		})
+	}
+	if len(published) == 0 {
+		return
+	}
+
+	worklet.PublishedArtifacts = models.MakeJSONField(published)
+	if err := m.db.Model(worklet).UpdateColumn("published_artifacts", worklet.PublishedArtifacts).Error; err != nil {
+		slog.Error("Failed to record worklet artifacts", "error", err, "workletID", worklet.ID)
+	}
+
+	slog.Info("Published worklet artifacts",
+		"workletID", worklet.ID,
+		"promptID", promptID,
+		"count", len(published),
+		"action", "worklet_artifacts_published",
+	)
+	event := events.ArtifactsPublished{WorkletID: worklet.ID, UserID: worklet.UserID, PromptID: promptID}
+	for _, artifact := range published {
+		event.Artifacts = append(event.Artifacts, events.Artifact{Path: artifact.Path, URL: artifact.URL, Size: artifact.Size})
+	}
+	events.Publish(m.events, events.TopicArtifacts, event)
+}
+
+// Artifacts returns the worklet's latest published artifacts with links that
+// work from now, since presigned links expire
+func (m *Manager) Artifacts(worklet *Worklet) []PublishedArtifact {
+	if worklet.PublishedArtifacts == nil {
+		return nil
+	}
+	artifacts := make([]PublishedArtifact, len(worklet.PublishedArtifacts.Data))
+	copy(artifacts, worklet.PublishedArtifacts.Data)
+	if m.artifacts != nil {
+		for i := range artifacts {
+			artifacts[i].URL = m.artifacts.URL(artifacts[i].Key)
+		}
+	}
+	return artifacts
+}
+
+// artifactMaxSize is the largest file that's published
+func (m *Manager) artifactMaxSize() int64 {
+	if m.deps != nil && m.deps.Config.Worklet.Artifacts.MaxSize > 0 {
+		return m.deps.Config.Worklet.Artifacts.MaxSize
+	}
+	return defaultArtifactMaxSize
+}
+
+// artifactContentType is the content type an artifact is served with, from
+// its extension or else its contents
+func artifactContentType(file ArtifactFile) string {
+	if contentType := mime.TypeByExtension(path.Ext(file.Path)); contentType != "" {
+		return contentType
+	}
+	return http.DetectContentType(file.Data)
+}
@@ -0,0 +1,111 @@
+package worklet
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/models"
+	"github.com/breadchris/flow/storage"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateArtifacts(t *testing.T) {
+	assert.NoError(t, validateArtifacts(nil))
+	assert.NoError(t, validateArtifacts([]string{"dist/*.zip", "build/app", "coverage/report-[0-9].html"}))
+	for _, pattern := range []string{"/etc/passwd", "../secrets", "dist/*.zip; rm -rf /", "$(id)", "-rf", "a b"} {
+		assert.Error(t, validateArtifacts([]string{pattern}), pattern)
+	}
+}
+
+func TestPublishArtifacts(t *testing.T) {
+	var mu sync.Mutex
+	uploads := make(map[string]string)
+	bucket := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		uploads[r.URL.Path] = r.Header.Get("Content-Type") + " " + string(body)
+		mu.Unlock()
+	}))
+	defer bucket.Close()
+
+	_, db := newTestAPI(t)
+	require.NoError(t, db.Model(&Worklet{}).Where("id = ?", "w1").UpdateColumn("artifacts", models.MakeJSONField([]string{"dist/*"})).Error)
+	manager := &Manager{
+		db:        db,
+		worklets:  make(map[string]*Worklet),
+		webServer: NewWebServer(),
+		events:    events.New(),
+		artifacts: storage.New(config.ArtifactStorageConfig{
+			Bucket:     "builds",
+			Endpoint:   bucket.URL,
+			Prefix:     "worklets/",
+			LinkExpiry: time.Hour,
+		}),
+		readArtifacts: func(ctx context.Context, containerID string, patterns []string, maxSize int64) ([]ArtifactFile, error) {
+			assert.Equal(t, []string{"dist/*"}, patterns)
+			return []ArtifactFile{
+				{Path: "dist/app.zip", Data: []byte("PK zip")},
+				{Path: "dist/notes", Data: []byte("plain notes")},
+			}, nil
+		},
+	}
+	published, unsubscribe := events.Channel(manager.events, events.TopicArtifacts)
+	defer unsubscribe()
+
+	worklet, err := manager.GetWorklet("w1")
+	require.NoError(t, err)
+	manager.publishArtifacts(context.Background(), worklet, "p1")
+
+	assert.Equal(t, map[string]string{
+		"/builds/worklets/w1/p1/dist/app.zip": "application/zip PK zip",
+		"/builds/worklets/w1/p1/dist/notes":   "text/plain; charset=utf-8 plain notes",
+	}, uploads)
+	select {
+	case event := <-published:
+		assert.Equal(t, "alice", event.UserID)
+		require.Len(t, event.Artifacts, 2)
+		assert.Equal(t, "dist/app.zip", event.Artifacts[0].Path)
+		assert.True(t, strings.HasPrefix(event.Artifacts[0].URL, bucket.URL+"/builds/worklets/w1/p1/dist/app.zip?"), event.Artifacts[0].URL)
+	case <-time.After(time.Second):
+		t.Fatal("no artifacts event")
+	}
+
+	// The API lists them with links from the storage
+	router := mux.NewRouter()
+	(&WorkletHandler{manager: manager}).RegisterRoutes(router.PathPrefix("/api/worklet").Subrouter())
+	rr := serve(router, "GET", "/api/worklet/worklets/w1/artifacts", "alice", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var artifacts []PublishedArtifact
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &artifacts))
+	require.Len(t, artifacts, 2)
+	assert.Equal(t, "w1/p1/dist/notes", artifacts[1].Key)
+	assert.Equal(t, int64(11), artifacts[1].Size)
+	assert.Contains(t, artifacts[1].URL, "X-Amz-Expires=3600")
+
+	rr = serve(router, "GET", "/api/worklet/worklets/w1/artifacts", "bob", "")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestPublishArtifactsWithoutStorage(t *testing.T) {
+	_, db := newTestAPI(t)
+	manager := &Manager{db: db, worklets: make(map[string]*Worklet), webServer: NewWebServer(), events: events.New()}
+	manager.readArtifacts = func(ctx context.Context, containerID string, patterns []string, maxSize int64) ([]ArtifactFile, error) {
+		t.Fatal("artifacts aren't read without storage")
+		return nil, nil
+	}
+	worklet, err := manager.GetWorklet("w1")
+	require.NoError(t, err)
+	worklet.Artifacts = models.MakeJSONField([]string{"dist/*"})
+	manager.publishArtifacts(context.Background(), worklet, "p1")
+}
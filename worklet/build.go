@@ -0,0 +1,339 @@
+package worklet
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// BuildStrategy is how a worklet's image is built from its repository
+type BuildStrategy string
+
+const (
+	BuildAuto       BuildStrategy = ""           // Picked by inspecting the repository
+	BuildDockerfile BuildStrategy = "dockerfile" // The repository's own Dockerfile
+	BuildNixpacks   BuildStrategy = "nixpacks"   // The nixpacks CLI
+	BuildBuildpacks BuildStrategy = "buildpacks" // Cloud Native Buildpacks through the pack CLI
+	BuildNode       BuildStrategy = "node"       // A generated Dockerfile that builds and runs npm start
+	BuildPython     BuildStrategy = "python"     // A generated Dockerfile that runs app.py
+	BuildGo         BuildStrategy = "go"         // A generated Dockerfile that builds the main package
+	BuildStatic     BuildStrategy = "static"     // nginx serving the repository's files
+)
+
+// buildStrategies are the strategies a worklet can ask for by name
+var buildStrategies = []BuildStrategy{
+	BuildDockerfile, BuildNixpacks, BuildBuildpacks, BuildNode, BuildPython, BuildGo, BuildStatic,
+}
+
+// buildpacksBuilder is the builder image pack builds with
+const buildpacksBuilder = "paketobuildpacks/builder-jammy-base"
+
+// generatedDockerfile is the name the Dockerfile written for a repository
+// without its own is given in the build context
+const generatedDockerfile = "Dockerfile.worklet"
+
+// generatedDockerfiles are the Dockerfiles written for repositories without
+// their own. Each serves on port 3000, where the worklet's container is reached.
+var generatedDockerfiles = map[BuildStrategy]string{
+	BuildNode: `FROM node:18-alpine
+WORKDIR /app
+COPY package*.json ./
+RUN npm ci
+COPY . .
+RUN npm run build --if-present
+EXPOSE 3000
+CMD ["npm", "start"]
+`,
+	BuildPython: `FROM python:3.9-slim
+WORKDIR /app
+COPY requirements.txt .
+RUN pip install -r requirements.txt
+COPY . .
+EXPOSE 3000
+CMD ["python", "app.py"]
+`,
+	BuildGo: `FROM golang:1.21-alpine AS builder
+WORKDIR /app
+COPY go.* ./
+RUN go mod download
+COPY . .
+RUN go build -o main .
+
+FROM alpine:latest
+RUN apk --no-cache add ca-certificates
+WORKDIR /root/
+COPY --from=builder /app/main .
+EXPOSE 3000
+CMD ["./main"]
+`,
+	BuildStatic: `FROM nginx:alpine
+COPY . /usr/share/nginx/html
+RUN sed -i -E 's/listen( +)80;/listen\13000;/' /etc/nginx/conf.d/default.conf
+EXPOSE 3000
+CMD ["nginx", "-g", "daemon off;"]
+`,
+}
+
+// ParseBuildStrategy reads a build strategy by name; empty means automatic
+func ParseBuildStrategy(s string) (BuildStrategy, error) {
+	strategy := BuildStrategy(strings.ToLower(strings.TrimSpace(s)))
+	if strategy == BuildAuto {
+		return BuildAuto, nil
+	}
+	for _, known := range buildStrategies {
+		if strategy == known {
+			return strategy, nil
+		}
+	}
+	names := make([]string, len(buildStrategies))
+	for i, known := range buildStrategies {
+		names[i] = string(known)
+	}
+	return "", fmt.Errorf("unknown build strategy %q, expected one of %s", s, strings.Join(names, ", "))
+}
+
+// DetectBuildStrategy picks how to build the repository at repoPath: with its
+// own Dockerfile, with nixpacks or buildpacks when it's configured for them,
+// or with a generated Dockerfile for its language, falling back to serving it
+// as a static site
+func DetectBuildStrategy(repoPath string) BuildStrategy {
+	has := func(name string) bool {
+		_, err := os.Stat(filepath.Join(repoPath, name))
+		return err == nil
+	}
+	switch {
+	case has("Dockerfile"):
+		return BuildDockerfile
+	case has("nixpacks.toml"), has("nixpacks.json"):
+		return BuildNixpacks
+	case has("project.toml"):
+		return BuildBuildpacks
+	case has("package.json"):
+		return BuildNode
+	case has("requirements.txt"):
+		return BuildPython
+	case has("go.mod"):
+		return BuildGo
+	default:
+		return BuildStatic
+	}
+}
+
+// buildImage builds imageName from the repository at repoPath with the
+// worklet's build strategy, detecting one if it has none
+func (d *DockerClient) buildImage(ctx context.Context, repoPath, imageName string, worklet *Worklet, logLine LogFunc) error {
+	strategy := worklet.BuildStrategy
+	if strategy == BuildAuto {
+		strategy = DetectBuildStrategy(repoPath)
+	}
+	logLine("build", fmt.Sprintf("Building with the %s strategy", strategy))
+
+	switch strategy {
+	case BuildNixpacks:
+		return d.buildWithCLI(ctx, worklet, logLine, "nixpacks", "build", repoPath, "--name", imageName)
+	case BuildBuildpacks:
+		args := []string{"build", imageName, "--path", repoPath, "--builder", buildpacksBuilder}
+		// Builds of the same repository restore its dependencies from the last one
+		if d.caches {
+			cacheVolume := buildCacheVolume(worklet.GitRepo)
+			if err := d.ensureCacheVolume(ctx, cacheVolume); err != nil {
+				slog.Warn("Failed to create build cache", "error", err, "volume", cacheVolume)
+			} else {
+				args = append(args, "--cache", "type=build;format=volume;name="+cacheVolume)
+			}
+		}
+		return d.buildWithCLI(ctx, worklet, logLine, "pack", args...)
+	case BuildDockerfile:
+		if _, err := os.Stat(filepath.Join(repoPath, "Dockerfile")); err != nil {
+			return fmt.Errorf("repository has no Dockerfile")
+		}
+		return d.buildDockerfile(ctx, repoPath, imageName, "Dockerfile", worklet, logLine)
+	}
+
+	dockerfile, ok := generatedDockerfiles[strategy]
+	if !ok {
+		return fmt.Errorf("unknown build strategy %q", strategy)
+	}
+	dockerfilePath := filepath.Join(repoPath, generatedDockerfile)
+	if err := os.WriteFile(dockerfilePath, []byte(dockerfile), 0644); err != nil {
+		return fmt.Errorf("failed to write Dockerfile: %w", err)
+	}
+	defer os.Remove(dockerfilePath)
+	return d.buildDockerfile(ctx, repoPath, imageName, generatedDockerfile, worklet, logLine)
+}
+
+// buildHash returns the key of the image the repository at repoPath builds
+// with strategy: a hash of the strategy and every file outside .git. Images
+// are tagged with it, so a tree that was built before needn't be built again.
+func buildHash(repoPath string, strategy BuildStrategy) (string, error) {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "strategy %s\n", strategy)
+	err := walkBuildContext(repoPath, func(name, path string, entry fs.DirEntry) error {
+		switch {
+		case entry.IsDir(), name == generatedDockerfile:
+			return nil
+		case entry.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(hash, "link %s %s\n", name, target)
+			return nil
+		case !entry.Type().IsRegular():
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "file %s %o %d\n", name, info.Mode().Perm(), info.Size())
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(hash, file)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash repository: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// walkBuildContext calls fn with the slash-separated name, path, and entry of
+// everything in the repository that goes into its image, in lexical order
+func walkBuildContext(repoPath string, fn func(name, path string, entry fs.DirEntry) error) error {
+	return filepath.WalkDir(repoPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(repoPath, path)
+		if err != nil || name == "." {
+			return err
+		}
+		if entry.IsDir() && entry.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		return fn(filepath.ToSlash(name), path, entry)
+	})
+}
+
+// buildDockerfile builds imageName with the Docker daemon from the named
+// Dockerfile in the repository at repoPath
+func (d *DockerClient) buildDockerfile(ctx context.Context, repoPath, imageName, dockerfile string, worklet *Worklet, logLine LogFunc) error {
+	buildContext := createBuildContext(repoPath)
+	defer buildContext.Close()
+
+	buildResponse, err := d.client.ImageBuild(ctx, buildContext, types.ImageBuildOptions{
+		Tags:       []string{imageName},
+		Dockerfile: dockerfile,
+		Remove:     true,
+		Context:    buildContext,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build image: %w", err)
+	}
+	defer buildResponse.Body.Close()
+
+	buildLogs, err := readBuildOutput(buildResponse.Body, func(line string) { logLine("build", line) })
+	worklet.BuildLogs = buildLogs
+	return err
+}
+
+// createBuildContext streams the repository at repoPath as the tar archive
+// the Docker daemon builds from
+func createBuildContext(repoPath string) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		archive := tar.NewWriter(pw)
+		err := walkBuildContext(repoPath, func(name, path string, entry fs.DirEntry) error {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			var link string
+			if info.Mode()&fs.ModeSymlink != 0 {
+				if link, err = os.Readlink(path); err != nil {
+					return err
+				}
+			} else if !info.IsDir() && !info.Mode().IsRegular() {
+				return nil
+			}
+			header, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			header.Name = name
+			if err := archive.WriteHeader(header); err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			_, err = io.Copy(archive, file)
+			return err
+		})
+		if err == nil {
+			err = archive.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// buildWithCLI builds an image by running a builder such as nixpacks or pack,
+// keeping its output as the worklet's build logs
+func (d *DockerClient) buildWithCLI(ctx context.Context, worklet *Worklet, logLine LogFunc, name string, args ...string) error {
+	if _, err := exec.LookPath(name); err != nil {
+		return fmt.Errorf("%s is not installed on the server", name)
+	}
+
+	pr, pw := io.Pipe()
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", name, err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		pw.Close()
+		done <- err
+	}()
+
+	var logs strings.Builder
+	scanner := bufio.NewScanner(pr)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		logs.WriteString(scanner.Text() + "\n")
+		forEachLine(scanner.Text(), func(line string) { logLine("build", line) })
+	}
+	// Keep the pipe drained if the output had a line too long to scan
+	io.Copy(io.Discard, pr)
+	worklet.BuildLogs = logs.String()
+
+	if err := <-done; err != nil {
+		return fmt.Errorf("%s build failed: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package worklet
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectBuildStrategy(t *testing.T) {
+	tests := []struct {
+		files    []string
+		strategy BuildStrategy
+	}{
+		{[]string{"Dockerfile", "package.json"}, BuildDockerfile},
+		{[]string{"nixpacks.toml", "package.json"}, BuildNixpacks},
+		{[]string{"project.toml", "go.mod"}, BuildBuildpacks},
+		{[]string{"package.json", "requirements.txt"}, BuildNode},
+		{[]string{"requirements.txt"}, BuildPython},
+		{[]string{"go.mod", "main.go"}, BuildGo},
+		{[]string{"index.html"}, BuildStatic},
+		{nil, BuildStatic},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		for _, name := range tt.files {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0644))
+		}
+		assert.Equal(t, tt.strategy, DetectBuildStrategy(dir), "files %v", tt.files)
+	}
+}
+
+func TestParseBuildStrategy(t *testing.T) {
+	strategy, err := ParseBuildStrategy(" Nixpacks ")
+	require.NoError(t, err)
+	assert.Equal(t, BuildNixpacks, strategy)
+
+	strategy, err = ParseBuildStrategy("")
+	require.NoError(t, err)
+	assert.Equal(t, BuildAuto, strategy)
+
+	_, err = ParseBuildStrategy("heroku")
+	assert.ErrorContains(t, err, "unknown build strategy")
+}
+
+func TestGeneratedDockerfilesServeOnContainerPort(t *testing.T) {
+	for strategy, dockerfile := range generatedDockerfiles {
+		assert.Contains(t, dockerfile, "EXPOSE 3000", "%s Dockerfile", strategy)
+	}
+}
+
+func TestCreateWorkletRejectsUnknownBuildStrategy(t *testing.T) {
+	router, _ := newTestAPI(t)
+
+	rr := serve(router, "POST", "/api/worklet/worklets", "alice",
+		`{"name":"Site","git_repo":"https://github.com/acme/site","build_strategy":"heroku"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "invalid_request", errorCode(t, rr))
+}
+
+// writeFiles writes files, by slash-separated name, under dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func TestBuildHash(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"index.html": "<h1>Hi</h1>", "css/site.css": "h1 {}"})
+	hash := func(strategy BuildStrategy) string {
+		h, err := buildHash(dir, strategy)
+		require.NoError(t, err)
+		return h
+	}
+	original := hash(BuildAuto)
+
+	writeFiles(t, dir, map[string]string{".git/HEAD": "ref: refs/heads/main", generatedDockerfile: "FROM nginx"})
+	assert.Equal(t, original, hash(BuildAuto), ".git and the generated Dockerfile aren't part of the image's files")
+	assert.NotEqual(t, original, hash(BuildStatic), "the strategy is part of the hash")
+
+	writeFiles(t, dir, map[string]string{"css/site.css": "h1 { color: red }"})
+	assert.NotEqual(t, original, hash(BuildAuto))
+
+	writeFiles(t, dir, map[string]string{"css/site.css": "h1 {}"})
+	assert.Equal(t, original, hash(BuildAuto), "restoring the files restores the hash")
+}
+
+func TestCreateBuildContext(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"Dockerfile": "FROM nginx", "src/app.js": "console.log(1)", ".git/HEAD": "ref"})
+
+	buildContext := createBuildContext(dir)
+	defer buildContext.Close()
+	files := map[string]string{}
+	archive := tar.NewReader(buildContext)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if header.Typeflag == tar.TypeReg {
+			content, err := io.ReadAll(archive)
+			require.NoError(t, err)
+			files[header.Name] = string(content)
+		}
+	}
+	assert.Equal(t, map[string]string{"Dockerfile": "FROM nginx", "src/app.js": "console.log(1)"}, files)
+}
+
+func TestRebuildIfChangedSkipsUnchangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"index.html": "<h1>Hi</h1>"})
+	hash, err := buildHash(dir, BuildAuto)
+	require.NoError(t, err)
+	worklet := &Worklet{Status: StatusRunning, ContainerID: "c1", BuildHash: hash}
+
+	// Without a Docker client, anything but skipping the rebuild would fail
+	(&Manager{}).rebuildIfChanged(context.Background(), worklet, dir)
+	assert.Equal(t, StatusRunning, worklet.Status)
+	assert.Equal(t, "c1", worklet.ContainerID)
+}
@@ -0,0 +1,207 @@
+package worklet
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+)
+
+// cacheLabel marks the Docker volumes that are worklets' dependency caches
+const cacheLabel = "flow.worklet.cache"
+
+// cacheTrimInterval is how often the dependency caches' size is checked
+const cacheTrimInterval = 10 * time.Minute
+
+// cacheClearImage is the image of the container that empties a cache volume
+const cacheClearImage = "alpine:latest"
+
+// dependencyCache is a Docker volume worklets' containers share that keeps a
+// package manager's downloads between builds
+type dependencyCache struct {
+	Volume string
+	Target string   // Where it's mounted in containers
+	Env    string   // The variable pointing the package manager at it
+	Files  []string // Files in a repository that mean it uses the package manager
+}
+
+// dependencyCaches are the caches a worklet's container can mount
+var dependencyCaches = []dependencyCache{
+	{Volume: "worklet-cache-go", Target: "/cache/go", Env: "GOMODCACHE", Files: []string{"go.mod"}},
+	{Volume: "worklet-cache-npm", Target: "/cache/npm", Env: "npm_config_cache", Files: []string{"package.json"}},
+	{Volume: "worklet-cache-pip", Target: "/cache/pip", Env: "PIP_CACHE_DIR", Files: []string{"requirements.txt", "pyproject.toml"}},
+}
+
+// cachesFor returns the dependency caches of the package managers the
+// repository at repoPath uses
+func cachesFor(repoPath string) []dependencyCache {
+	var caches []dependencyCache
+	for _, cache := range dependencyCaches {
+		for _, file := range cache.Files {
+			if _, err := os.Stat(filepath.Join(repoPath, file)); err == nil {
+				caches = append(caches, cache)
+				break
+			}
+		}
+	}
+	return caches
+}
+
+// buildCacheVolume is the volume buildpacks builds of a repository keep
+// their cache in; builds of other repositories don't share it
+func buildCacheVolume(gitRepo string) string {
+	sum := sha256.Sum256([]byte(gitRepo))
+	return "worklet-cache-build-" + hex.EncodeToString(sum[:6])
+}
+
+// ensureCacheVolume creates a dependency cache's volume if it doesn't exist
+func (d *DockerClient) ensureCacheVolume(ctx context.Context, name string) error {
+	if _, err := d.client.VolumeCreate(ctx, volume.CreateOptions{
+		Name:   name,
+		Labels: map[string]string{cacheLabel: "true"},
+	}); err != nil {
+		return fmt.Errorf("failed to create cache volume %s: %w", name, err)
+	}
+	return nil
+}
+
+// cacheMounts returns the mounts and environment that give a container the
+// caches, creating their volumes as needed. Caches whose volume can't be
+// created are left out.
+func (d *DockerClient) cacheMounts(ctx context.Context, caches []dependencyCache) ([]mount.Mount, []string) {
+	var mounts []mount.Mount
+	var env []string
+	for _, cache := range caches {
+		if err := d.ensureCacheVolume(ctx, cache.Volume); err != nil {
+			slog.Warn("Failed to create dependency cache", "error", err, "volume", cache.Volume)
+			continue
+		}
+		mounts = append(mounts, mount.Mount{Type: mount.TypeVolume, Source: cache.Volume, Target: cache.Target})
+		env = append(env, cache.Env+"="+cache.Target)
+	}
+	return mounts, env
+}
+
+// CacheUsage returns the bytes each dependency cache volume uses
+func (d *DockerClient) CacheUsage(ctx context.Context) (map[string]int64, error) {
+	usage, err := d.client.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.VolumeObject}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get volume usage: %w", err)
+	}
+	sizes := make(map[string]int64)
+	for _, vol := range usage.Volumes {
+		if vol.Labels[cacheLabel] == "" || vol.UsageData == nil || vol.UsageData.Size < 0 {
+			continue
+		}
+		sizes[vol.Name] = vol.UsageData.Size
+	}
+	return sizes, nil
+}
+
+// ClearCache empties a dependency cache volume. Containers using it keep it
+// mounted, so its contents are deleted by a container of its own.
+func (d *DockerClient) ClearCache(ctx context.Context, name string) error {
+	if !d.imageExists(ctx, cacheClearImage) {
+		pull, err := d.client.ImagePull(ctx, cacheClearImage, image.PullOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to pull %s: %w", cacheClearImage, err)
+		}
+		io.Copy(io.Discard, pull)
+		pull.Close()
+	}
+
+	resp, err := d.client.ContainerCreate(ctx,
+		&container.Config{Image: cacheClearImage, Cmd: []string{"find", "/cache", "-mindepth", "1", "-delete"}},
+		&container.HostConfig{Mounts: []mount.Mount{{Type: mount.TypeVolume, Source: name, Target: "/cache"}}},
+		nil, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to create container to clear %s: %w", name, err)
+	}
+	defer d.RemoveContainer(resp.ID)
+
+	waitCh, errCh := d.client.ContainerWait(ctx, resp.ID, container.WaitConditionNextExit)
+	if err := d.client.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start container to clear %s: %w", name, err)
+	}
+	select {
+	case result := <-waitCh:
+		if result.StatusCode != 0 {
+			return fmt.Errorf("failed to clear %s: exited with status %d", name, result.StatusCode)
+		}
+		return nil
+	case err := <-errCh:
+		return fmt.Errorf("failed to clear %s: %w", name, err)
+	}
+}
+
+// TrimDependencyCaches keeps the dependency cache volumes under maxSize bytes
+// in all until ctx is done, emptying the largest when they're over it
+func (m *Manager) TrimDependencyCaches(ctx context.Context, maxSize int64) {
+	if maxSize <= 0 || (m.deps != nil && !m.deps.Config.Worklet.CacheEnabled) {
+		return
+	}
+	ticker := time.NewTicker(cacheTrimInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.trimCaches(ctx, maxSize)
+		}
+	}
+}
+
+// trimCaches empties the largest dependency caches until the rest fit in maxSize
+func (m *Manager) trimCaches(ctx context.Context, maxSize int64) {
+	usage := m.cacheUsage
+	if usage == nil {
+		usage = m.dockerClient.CacheUsage
+	}
+	clearCache := m.clearCache
+	if clearCache == nil {
+		clearCache = m.dockerClient.ClearCache
+	}
+
+	sizes, err := usage(ctx)
+	if err != nil {
+		slog.Error("Failed to check dependency cache sizes", "error", err)
+		return
+	}
+	var total int64
+	names := make([]string, 0, len(sizes))
+	for name, size := range sizes {
+		total += size
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return sizes[names[i]] > sizes[names[j]] })
+
+	for _, name := range names {
+		if total <= maxSize {
+			return
+		}
+		if err := clearCache(ctx, name); err != nil {
+			slog.Error("Failed to clear dependency cache", "error", err, "volume", name)
+			continue
+		}
+		total -= sizes[name]
+		slog.Info("Cleared dependency cache",
+			"volume", name,
+			"size", sizes[name],
+			"maxSize", maxSize,
+			"action", "worklet_cache_cleared",
+		)
+	}
+}
@@ -0,0 +1,62 @@
+package worklet
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachesFor(t *testing.T) {
+	dir := t.TempDir()
+	assert.Empty(t, cachesFor(dir))
+
+	writeFiles(t, dir, map[string]string{"go.mod": "module example", "pyproject.toml": "[project]"})
+	var volumes []string
+	for _, cache := range cachesFor(dir) {
+		volumes = append(volumes, cache.Volume)
+	}
+	assert.Equal(t, []string{"worklet-cache-go", "worklet-cache-pip"}, volumes)
+}
+
+func TestBuildCacheVolume(t *testing.T) {
+	volume := buildCacheVolume("https://github.com/example/app")
+	assert.Equal(t, volume, buildCacheVolume("https://github.com/example/app"))
+	assert.NotEqual(t, volume, buildCacheVolume("https://github.com/example/other"))
+}
+
+func TestTrimCaches(t *testing.T) {
+	var cleared []string
+	manager := &Manager{
+		cacheUsage: func(ctx context.Context) (map[string]int64, error) {
+			return map[string]int64{"worklet-cache-go": 600, "worklet-cache-npm": 300, "worklet-cache-pip": 200}, nil
+		},
+		clearCache: func(ctx context.Context, name string) error {
+			cleared = append(cleared, name)
+			return nil
+		},
+	}
+
+	manager.trimCaches(context.Background(), 2000)
+	assert.Empty(t, cleared, "the caches fit")
+
+	manager.trimCaches(context.Background(), 1000)
+	assert.Equal(t, []string{"worklet-cache-go"}, cleared, "emptying the largest is enough")
+
+	cleared = nil
+	manager.trimCaches(context.Background(), 250)
+	assert.Equal(t, []string{"worklet-cache-go", "worklet-cache-npm"}, cleared)
+
+	// A cache that can't be cleared is skipped for the next largest
+	cleared = nil
+	manager.clearCache = func(ctx context.Context, name string) error {
+		if name == "worklet-cache-go" {
+			return errors.New("volume is busy")
+		}
+		cleared = append(cleared, name)
+		return nil
+	}
+	manager.trimCaches(context.Background(), 700)
+	assert.Equal(t, []string{"worklet-cache-npm", "worklet-cache-pip"}, cleared)
+}
@@ -2,12 +2,14 @@ package worklet
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/breadchris/flow/claude"
@@ -15,6 +17,9 @@ import (
 
 type ClaudeClient struct {
 	claudeService *claude.Service
+
+	mu       sync.Mutex
+	sessions map[string]*claude.Process // Worklets' Claude processes, by worklet session ID
 }
 
 func NewClaudeClient() *ClaudeClient {
@@ -28,7 +33,7 @@ func NewClaudeClient() *ClaudeClient {
 	}
 }
 
-func (c *ClaudeClient) ApplyPrompt(ctx context.Context, repoPath, prompt string) error {
+func (c *ClaudeClient) ApplyPrompt(ctx context.Context, repoPath, prompt string, opts ...claude.SessionOption) error {
 	if prompt == "" {
 		return nil
 	}
@@ -36,10 +41,11 @@ func (c *ClaudeClient) ApplyPrompt(ctx context.Context, repoPath, prompt string)
 	slog.Info("Applying prompt to worklet", "repoPath", repoPath)
 
 	// Create a new Claude session with the repository as working directory
-	process, err := c.claudeService.CreateSessionWithOptions(repoPath)
+	process, err := c.claudeService.CreateSessionWithOptions(repoPath, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create Claude session: %w", err)
 	}
+	defer process.OnToolUse(logToolCall(repoPath))()
 
 	// Send the prompt to Claude
 	if err := c.claudeService.SendMessage(process, prompt); err != nil {
@@ -55,17 +61,21 @@ func (c *ClaudeClient) ApplyPrompt(ctx context.Context, repoPath, prompt string)
 	return nil
 }
 
-func (c *ClaudeClient) ProcessPrompt(ctx context.Context, repoPath, prompt string) (string, error) {
-	slog.Info("Processing prompt for worklet", "repoPath", repoPath)
+// ProcessPrompt sends a prompt to the worklet session's Claude process and
+// returns Claude's response. Prompts to the same session continue one
+// conversation for as long as its process runs.
+func (c *ClaudeClient) ProcessPrompt(ctx context.Context, sessionID, repoPath, prompt string, opts ...claude.SessionOption) (string, error) {
+	slog.Info("Processing prompt for worklet", "repoPath", repoPath, "sessionID", sessionID)
 
-	// Create a new Claude session with repository as working directory
-	process, err := c.claudeService.CreateSessionWithOptions(repoPath)
+	process, err := c.session(sessionID, repoPath, opts...)
 	if err != nil {
-		return "", fmt.Errorf("failed to create Claude session: %w", err)
+		return "", err
 	}
+	defer process.OnToolUse(logToolCall(repoPath))()
 
 	// Send the prompt to Claude
 	if err := c.claudeService.SendMessage(process, prompt); err != nil {
+		c.forget(sessionID, process)
 		return "", fmt.Errorf("failed to send prompt to Claude: %w", err)
 	}
 
@@ -79,14 +89,71 @@ func (c *ClaudeClient) ProcessPrompt(ctx context.Context, repoPath, prompt strin
 	return response, nil
 }
 
+// session returns the worklet session's Claude process, starting one in
+// repoPath when it has none or its process has exited
+func (c *ClaudeClient) session(sessionID, repoPath string, opts ...claude.SessionOption) (*claude.Process, error) {
+	c.mu.Lock()
+	process, ok := c.sessions[sessionID]
+	c.mu.Unlock()
+	if ok && !process.Exited() {
+		return process, nil
+	}
+
+	process, err := c.claudeService.CreateSessionWithOptions(repoPath, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Claude session: %w", err)
+	}
+	c.mu.Lock()
+	if c.sessions == nil {
+		c.sessions = make(map[string]*claude.Process)
+	}
+	c.sessions[sessionID] = process
+	c.mu.Unlock()
+	return process, nil
+}
+
+// forget drops a session's process, if it's still the one it has, so its
+// next prompt starts a new one
+func (c *ClaudeClient) forget(sessionID string, process *claude.Process) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sessions[sessionID] == process {
+		delete(c.sessions, sessionID)
+	}
+}
+
+// logToolCall logs each tool Claude calls while working in repoPath
+func logToolCall(repoPath string) func(claude.ToolEvent) {
+	return func(event claude.ToolEvent) {
+		if event.Type == claude.ToolCall {
+			slog.Info("Claude tool call", "repoPath", repoPath, "tool", event.Tool, "summary", event.Summary())
+		}
+	}
+}
+
+// turnError returns the error that ends a worklet's Claude turn, or nil for
+// ones Claude works around, like a denied tool call, and unrecognized stderr output
+func turnError(sessionErr *claude.SessionError) error {
+	if sessionErr.Kind == nil || errors.Is(sessionErr, claude.ErrToolDenied) {
+		slog.Warn("Claude reported an error", "error", sessionErr)
+		return nil
+	}
+	return sessionErr
+}
+
 func (c *ClaudeClient) waitForResponse(ctx context.Context, process *claude.Process) error {
 	timeout := time.After(5 * time.Minute)
 	messageChan := c.claudeService.ReceiveMessages(process)
+	errorChan := c.claudeService.Errors(process)
 
 	for {
 		select {
 		case <-timeout:
 			return fmt.Errorf("timeout waiting for Claude response")
+		case sessionErr := <-errorChan:
+			if err := turnError(sessionErr); err != nil {
+				return err
+			}
 		case msg, ok := <-messageChan:
 			if !ok {
 				return fmt.Errorf("message channel closed")
@@ -104,12 +171,17 @@ func (c *ClaudeClient) waitForResponse(ctx context.Context, process *claude.Proc
 func (c *ClaudeClient) collectResponse(ctx context.Context, process *claude.Process) (string, error) {
 	timeout := time.After(5 * time.Minute)
 	messageChan := c.claudeService.ReceiveMessages(process)
+	errorChan := c.claudeService.Errors(process)
 	var responseBuilder strings.Builder
 
 	for {
 		select {
 		case <-timeout:
 			return "", fmt.Errorf("timeout waiting for Claude response")
+		case sessionErr := <-errorChan:
+			if err := turnError(sessionErr); err != nil {
+				return "", err
+			}
 		case msg, ok := <-messageChan:
 			if !ok {
 				return responseBuilder.String(), nil
@@ -128,30 +200,33 @@ func (c *ClaudeClient) collectResponse(ctx context.Context, process *claude.Proc
 	}
 }
 
-func (c *ClaudeClient) CreatePR(ctx context.Context, repoPath, branchName, title, description string) error {
+// CreatePR commits the checkout's changes to branchName, pushes it, and opens
+// a pull request, returning its URL
+func (c *ClaudeClient) CreatePR(ctx context.Context, repoPath, branchName, title, description string) (string, error) {
 	slog.Info("Creating PR for worklet", "repoPath", repoPath, "branch", branchName)
 
 	if !c.isGitRepo(repoPath) {
-		return fmt.Errorf("not a git repository")
+		return "", fmt.Errorf("not a git repository")
 	}
 
 	if err := c.createBranch(repoPath, branchName); err != nil {
-		return fmt.Errorf("failed to create branch: %w", err)
+		return "", fmt.Errorf("failed to create branch: %w", err)
 	}
 
-	if err := c.commitChanges(repoPath, title); err != nil {
-		return fmt.Errorf("failed to commit changes: %w", err)
+	if err := c.commitChanges(ctx, repoPath, title); err != nil {
+		return "", fmt.Errorf("failed to commit changes: %w", err)
 	}
 
 	if err := c.pushBranch(repoPath, branchName); err != nil {
-		return fmt.Errorf("failed to push branch: %w", err)
+		return "", fmt.Errorf("failed to push branch: %w", err)
 	}
 
-	if err := c.createGitHubPR(repoPath, branchName, title, description); err != nil {
-		return fmt.Errorf("failed to create GitHub PR: %w", err)
+	url, err := c.createGitHubPR(repoPath, branchName, title, description)
+	if err != nil {
+		return "", fmt.Errorf("failed to create GitHub PR: %w", err)
 	}
 
-	return nil
+	return url, nil
 }
 
 func (c *ClaudeClient) isGitRepo(repoPath string) bool {
@@ -171,7 +246,9 @@ func (c *ClaudeClient) createBranch(repoPath, branchName string) error {
 	return nil
 }
 
-func (c *ClaudeClient) commitChanges(repoPath, message string) error {
+// commitChanges commits everything in the worktree with a message Claude
+// writes from the diff, or fallback if it can't
+func (c *ClaudeClient) commitChanges(ctx context.Context, repoPath, fallback string) error {
 	addCmd := exec.Command("git", "add", ".")
 	addCmd.Dir = repoPath
 
@@ -192,6 +269,7 @@ func (c *ClaudeClient) commitChanges(repoPath, message string) error {
 		return nil
 	}
 
+	message := c.commitMessage(ctx, repoPath, fallback)
 	commitCmd := exec.Command("git", "commit", "-m", message)
 	commitCmd.Dir = repoPath
 
@@ -202,6 +280,33 @@ func (c *ClaudeClient) commitChanges(repoPath, message string) error {
 	return nil
 }
 
+// maxCommitDiff limits how much of the staged diff is sent when asking for a commit message
+const maxCommitDiff = 20000
+
+// commitMessage asks Claude for a commit message describing the staged changes
+func (c *ClaudeClient) commitMessage(ctx context.Context, repoPath, fallback string) string {
+	diffCmd := exec.Command("git", "diff", "--cached", "--stat", "--patch")
+	diffCmd.Dir = repoPath
+	diff, err := diffCmd.Output()
+	if err != nil {
+		slog.Warn("Failed to read staged diff for commit message", "repoPath", repoPath, "error", err)
+		return fallback
+	}
+	if len(diff) > maxCommitDiff {
+		diff = append(diff[:maxCommitDiff], "\n[diff truncated]"...)
+	}
+
+	prompt := "Write a git commit message for the change below. Reply with only the message: " +
+		"a summary line of at most 72 characters, then a blank line and a short body if the change needs explaining.\n\n" +
+		"Pull request title: " + fallback + "\n\n" + string(diff)
+	message, err := c.claudeService.Query(ctx, prompt)
+	if err != nil || strings.TrimSpace(message) == "" {
+		slog.Warn("Failed to generate commit message, using the PR title", "repoPath", repoPath, "error", err)
+		return fallback
+	}
+	return strings.TrimSpace(message)
+}
+
 func (c *ClaudeClient) pushBranch(repoPath, branchName string) error {
 	cmd := exec.Command("git", "push", "-u", "origin", branchName)
 	cmd.Dir = repoPath
@@ -219,9 +324,10 @@ func (c *ClaudeClient) pushBranch(repoPath, branchName string) error {
 	return nil
 }
 
-func (c *ClaudeClient) createGitHubPR(repoPath, branchName, title, description string) error {
+// createGitHubPR opens a pull request for branchName and returns its URL
+func (c *ClaudeClient) createGitHubPR(repoPath, branchName, title, description string) (string, error) {
 	if !c.isGitHubCLIAvailable() {
-		return fmt.Errorf("GitHub CLI (gh) is not available")
+		return "", fmt.Errorf("GitHub CLI (gh) is not available")
 	}
 
 	cmd := exec.Command("gh", "pr", "create", "--title", title, "--body", description, "--head", branchName)
@@ -234,12 +340,12 @@ func (c *ClaudeClient) createGitHubPR(repoPath, branchName, title, description s
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("failed to create PR: %s", string(output))
+		return "", fmt.Errorf("failed to create PR: %s", string(output))
 	}
 
 	slog.Info("Created PR successfully", "output", string(output))
 
-	return nil
+	return pullRequestURL(string(output)), nil
 }
 
 func (c *ClaudeClient) isGitHubCLIAvailable() bool {
@@ -253,8 +359,15 @@ func (c *ClaudeClient) GetSessionStatus(sessionID string) (string, error) {
 	return "unknown", nil
 }
 
+// CloseSession stops the worklet session's Claude process, if it has one
 func (c *ClaudeClient) CloseSession(sessionID string) error {
-	c.claudeService.StopSession(sessionID)
+	c.mu.Lock()
+	process, ok := c.sessions[sessionID]
+	delete(c.sessions, sessionID)
+	c.mu.Unlock()
+	if ok {
+		c.claudeService.StopSession(process.FlowSessionID())
+	}
 	return nil
 }
 
@@ -0,0 +1,143 @@
+package worklet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrNoCheckout is returned for worklets whose repository hasn't been cloned
+var ErrNoCheckout = errors.New("worklet has no checkout")
+
+// maxDiffPatch limits how much of a patch a diff includes
+const maxDiffPatch = 1 << 20
+
+// Diff is what has changed in a worklet's checkout since the commit it was
+// deployed from: Claude's edits, along with anything committed for a PR
+type Diff struct {
+	BaseCommit string     `json:"base_commit,omitempty"` // Empty for a prompt's diff
+	Files      []FileDiff `json:"files"`
+	Additions  int        `json:"additions"`
+	Deletions  int        `json:"deletions"`
+	Patch      string     `json:"patch"`               // Unified diff
+	Truncated  bool       `json:"truncated,omitempty"` // The patch was cut off at 1 MB
+}
+
+// FileDiff is the lines added to and removed from one changed file
+type FileDiff struct {
+	Path      string `json:"path"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary,omitempty"`
+}
+
+// Diff returns the changes in the worklet's checkout since the commit it was
+// deployed from
+func (m *Manager) Diff(ctx context.Context, worklet *Worklet) (*Diff, error) {
+	repoPath := m.gitClient.RepoPath(worklet.checkoutSpec())
+	if _, err := os.Stat(filepath.Join(repoPath, ".git")); err != nil {
+		return nil, ErrNoCheckout
+	}
+	base := worklet.CommitSHA
+	if base == "" {
+		base = "HEAD"
+	}
+	return diffWorkTree(ctx, repoPath, base)
+}
+
+// diffWorkTree diffs the files in the repository at repoPath, including new
+// ones git doesn't track yet, against the base commit
+func diffWorkTree(ctx context.Context, repoPath, base string) (*Diff, error) {
+	tree, err := snapshotWorkTree(ctx, repoPath, base)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := diffTrees(ctx, repoPath, base, tree)
+	if err != nil {
+		return nil, err
+	}
+	diff.BaseCommit = base
+	return diff, nil
+}
+
+// snapshotWorkTree writes the files in the repository at repoPath, including
+// new ones git doesn't track yet, to a tree object and returns its hash. Files
+// are staged in a scratch index, starting from the start commit so tracked
+// files that are now ignored are kept, and the checkout's own index is left
+// alone.
+func snapshotWorkTree(ctx context.Context, repoPath, start string) (string, error) {
+	scratch, err := os.MkdirTemp("", "worklet-diff-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create index directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	index := "GIT_INDEX_FILE=" + filepath.Join(scratch, "index")
+	if _, err := runGit(ctx, repoPath, []string{index}, "read-tree", start); err != nil {
+		return "", err
+	}
+	if _, err := runGit(ctx, repoPath, []string{index}, "add", "--all", "--", ".", ":!"+generatedDockerfile); err != nil {
+		return "", err
+	}
+	tree, err := runGit(ctx, repoPath, []string{index}, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(tree)), nil
+}
+
+// diffTrees diffs two trees or commits in the repository at repoPath
+func diffTrees(ctx context.Context, repoPath, from, to string) (*Diff, error) {
+	numstat, err := runGit(ctx, repoPath, nil, "diff", "--numstat", from, to)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := runGit(ctx, repoPath, nil, "diff", from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &Diff{Files: []FileDiff{}, Patch: string(patch)}
+	if len(diff.Patch) > maxDiffPatch {
+		diff.Patch = diff.Patch[:maxDiffPatch]
+		diff.Truncated = true
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(numstat)), "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		file := FileDiff{Path: fields[2]}
+		if fields[0] == "-" {
+			file.Binary = true
+		} else {
+			file.Additions, _ = strconv.Atoi(fields[0])
+			file.Deletions, _ = strconv.Atoi(fields[1])
+		}
+		diff.Files = append(diff.Files, file)
+		diff.Additions += file.Additions
+		diff.Deletions += file.Deletions
+	}
+	return diff, nil
+}
+
+// runGit runs git in the repository at repoPath with env added to its
+// environment and returns its output
+func runGit(ctx context.Context, repoPath string, env []string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = repoPath
+	cmd.Env = append(os.Environ(), env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
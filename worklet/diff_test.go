@@ -0,0 +1,81 @@
+package worklet
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffWorkTree(t *testing.T) {
+	repoPath, commits := newTestRemote(t)
+	writeFiles(t, repoPath, map[string]string{
+		"version.txt":       "four\n",
+		"src/new.js":        "console.log(1)\nconsole.log(2)\n",
+		generatedDockerfile: "FROM nginx",
+	})
+
+	diff, err := diffWorkTree(context.Background(), repoPath, commits[2])
+	require.NoError(t, err)
+	assert.Equal(t, commits[2], diff.BaseCommit)
+	assert.Equal(t, []FileDiff{
+		{Path: "src/new.js", Additions: 2},
+		{Path: "version.txt", Additions: 1, Deletions: 1},
+	}, diff.Files, "new files are included and the generated Dockerfile isn't")
+	assert.Equal(t, 3, diff.Additions)
+	assert.Equal(t, 1, diff.Deletions)
+	assert.Contains(t, diff.Patch, "+++ b/src/new.js\n")
+	assert.Contains(t, diff.Patch, "-three\n\\ No newline at end of file\n+four\n")
+
+	// The checkout's own index isn't touched
+	repo, err := git.PlainOpen(repoPath)
+	require.NoError(t, err)
+	workTree, err := repo.Worktree()
+	require.NoError(t, err)
+	status, err := workTree.Status()
+	require.NoError(t, err)
+	assert.Equal(t, git.Untracked, status.File("src/new.js").Staging)
+
+	diff, err = diffWorkTree(context.Background(), repoPath, commits[0])
+	require.NoError(t, err)
+	assert.Len(t, diff.Files, 2, "diffing against an older commit includes everything since")
+}
+
+func TestGetDiff(t *testing.T) {
+	_, db := newTestAPI(t)
+	manager := &Manager{db: db, worklets: make(map[string]*Worklet), webServer: NewWebServer(), gitClient: &GitClient{baseDir: t.TempDir()}}
+	router := mux.NewRouter()
+	(&WorkletHandler{manager: manager}).RegisterRoutes(router.PathPrefix("/api/worklet").Subrouter())
+
+	rr := serve(router, "GET", "/api/worklet/w1/diff", "alice", "")
+	assert.Equal(t, http.StatusConflict, rr.Code, "worklets that were never cloned have no diff")
+
+	remote, commits := newTestRemote(t)
+	worklet, err := manager.GetWorklet("w1")
+	require.NoError(t, err)
+	worklet.GitRepo, worklet.Branch, worklet.CommitSHA = remote, "trunk", commits[2]
+	repoPath, _, err := manager.gitClient.Checkout(worklet.checkoutSpec())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(repoPath, "index.html"), []byte("<h1>Hi</h1>\n"), 0644))
+
+	rr = serve(router, "GET", "/api/worklet/w1/diff", "alice", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var diff Diff
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &diff))
+	assert.Equal(t, []FileDiff{{Path: "index.html", Additions: 1}}, diff.Files)
+
+	rr = serve(router, "GET", "/api/worklet/worklets/w1/diff?format=patch", "alice", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/x-diff; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), "+<h1>Hi</h1>\n")
+
+	rr = serve(router, "GET", "/api/worklet/w1/diff", "bob", "")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
@@ -1,25 +1,43 @@
 package worklet
 
 import (
+	"archive/tar"
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
-	"os"
-	"path/filepath"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/breadchris/flow/metrics"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
 )
 
 type DockerClient struct {
 	client *client.Client
+	caches bool // Whether containers and builds mount dependency cache volumes
+}
+
+// LogFunc receives each line of a worklet's Docker build ("build" stage),
+// container ("deploy" stage), and test ("test" stage) output
+type LogFunc func(stage, line string)
+
+// buildMessage is one message of the JSON stream the Docker daemon sends while building an image
+type buildMessage struct {
+	Stream string `json:"stream"`
+	Error  string `json:"error"`
 }
 
 func NewDockerClient() *DockerClient {
@@ -34,18 +52,57 @@ func NewDockerClient() *DockerClient {
 	}
 }
 
-func (d *DockerClient) BuildAndRun(ctx context.Context, repoPath string, worklet *Worklet) (string, int, error) {
+// Ping checks that the Docker daemon is reachable
+func (d *DockerClient) Ping(ctx context.Context) error {
+	if d == nil || d.client == nil {
+		return fmt.Errorf("docker client not initialized")
+	}
+
+	if _, err := d.client.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to ping docker daemon: %w", err)
+	}
+	return nil
+}
+
+// BuildAndRun builds the worklet's image and replaces its container with one
+// running it, published on port; 0 publishes it on any free port
+func (d *DockerClient) BuildAndRun(ctx context.Context, repoPath string, worklet *Worklet, port int, logLine LogFunc) (string, int, error) {
 	if d.client == nil {
 		return "", 0, fmt.Errorf("docker client not initialized")
 	}
 	
-	imageName := fmt.Sprintf("worklet-%s", worklet.ID)
+	hash, err := buildHash(repoPath, worklet.BuildStrategy)
+	if err != nil {
+		return "", 0, err
+	}
+	// Images are tagged with their build hash, so files that were built
+	// before reuse that image; other builds still reuse Docker's layer cache
+	imageName := fmt.Sprintf("worklet-%s:%s", worklet.ID, hash[:16])
+	
+	if d.imageExists(ctx, imageName) {
+		logLine("build", fmt.Sprintf("Files are unchanged since %s was built, reusing it", imageName))
+		metrics.WorkletImageBuildsTotal.WithLabelValues("cached").Inc()
+	} else {
+		if err := d.buildImage(ctx, repoPath, imageName, worklet, logLine); err != nil {
+			return "", 0, fmt.Errorf("failed to build image: %w", err)
+		}
+		metrics.WorkletImageBuildsTotal.WithLabelValues("built").Inc()
+	}
+	worklet.BuildHash = hash
 	
-	if err := d.buildImage(ctx, repoPath, imageName, worklet); err != nil {
-		return "", 0, fmt.Errorf("failed to build image: %w", err)
+	// The container being replaced holds the worklet's container name
+	if worklet.ContainerID != "" {
+		if err := d.RemoveContainer(worklet.ContainerID); err != nil {
+			slog.Warn("Failed to remove previous container", "error", err, "containerID", worklet.ContainerID)
+		}
+		worklet.ContainerID = ""
 	}
 	
-	containerID, port, err := d.runContainer(ctx, imageName, worklet)
+	var caches []dependencyCache
+	if d.caches {
+		caches = cachesFor(repoPath)
+	}
+	containerID, port, err := d.runContainer(ctx, imageName, worklet, port, caches, logLine)
 	if err != nil {
 		return "", 0, fmt.Errorf("failed to run container: %w", err)
 	}
@@ -53,48 +110,106 @@ func (d *DockerClient) BuildAndRun(ctx context.Context, repoPath string, worklet
 	return containerID, port, nil
 }
 
-func (d *DockerClient) buildImage(ctx context.Context, repoPath, imageName string, worklet *Worklet) error {
-	dockerfile := d.generateDockerfile(repoPath)
+// readBuildOutput returns the text of a Docker build's output stream, passing
+// each line to logLine, and the error the build failed with, if any
+func readBuildOutput(body io.Reader, logLine func(string)) (string, error) {
+	var logs strings.Builder
+	decoder := json.NewDecoder(body)
+	for {
+		var message buildMessage
+		if err := decoder.Decode(&message); err == io.EOF {
+			return logs.String(), nil
+		} else if err != nil {
+			return logs.String(), fmt.Errorf("failed to read build output: %w", err)
+		}
 	
-	dockerfilePath := filepath.Join(repoPath, "Dockerfile.worklet")
-	if err := os.WriteFile(dockerfilePath, []byte(dockerfile), 0644); err != nil {
-		return fmt.Errorf("failed to write Dockerfile: %w", err)
+		text := message.Stream
+		if message.Error != "" {
+			text = message.Error + "\n"
+		}
+		logs.WriteString(text)
+		forEachLine(text, logLine)
+		if message.Error != "" {
+			return logs.String(), errors.New(message.Error)
+		}
 	}
-	defer os.Remove(dockerfilePath)
-	
-	buildContext, err := d.createBuildContext(repoPath)
+}
+
+// appendContainerLogs adds a container's output so far to the worklet's
+// logs, passing each line to logLine
+func (d *DockerClient) appendContainerLogs(ctx context.Context, containerID string, worklet *Worklet, logLine LogFunc) {
+	reader, err := d.client.ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       "200",
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create build context: %w", err)
+		slog.Error("Failed to read container logs", "error", err, "workletID", worklet.ID)
+		return
 	}
-	defer buildContext.Close()
+	defer reader.Close()
 	
-	buildOptions := types.ImageBuildOptions{
-		Tags:       []string{imageName},
-		Dockerfile: "Dockerfile.worklet",
-		Remove:     true,
-		Context:    buildContext,
+	var output bytes.Buffer
+	if _, err := stdcopy.StdCopy(&output, &output, reader); err != nil {
+		slog.Error("Failed to read container logs", "error", err, "workletID", worklet.ID)
 	}
-	
-	buildResponse, err := d.client.ImageBuild(ctx, buildContext, buildOptions)
-	if err != nil {
-		return fmt.Errorf("failed to build image: %w", err)
+	if output.Len() == 0 {
+		return
 	}
-	defer buildResponse.Body.Close()
-	
-	buildLogs, err := io.ReadAll(buildResponse.Body)
+	worklet.BuildLogs += "\n--- Container output ---\n" + output.String()
+	forEachLine(output.String(), func(line string) { logLine("deploy", line) })
+}
+
+// forEachLine calls fn with each non-blank line of text
+func forEachLine(text string, fn func(string)) {
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) != "" {
+			fn(strings.TrimRight(line, "\r"))
+		}
+	}
+}
+
+// FollowContainerLogs calls logLine with each line a container writes from
+// since on, until the container stops or ctx is done
+func (d *DockerClient) FollowContainerLogs(ctx context.Context, containerID string, since time.Time, logLine func(string)) error {
+	if d == nil || d.client == nil {
+		return fmt.Errorf("docker client not initialized")
+	}
+	reader, err := d.client.ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+		Since:      strconv.FormatInt(since.Unix(), 10),
+	})
 	if err != nil {
-		slog.Error("Failed to read build logs", "error", err)
-	} else {
-		worklet.BuildLogs = string(buildLogs)
+		return fmt.Errorf("failed to follow container logs: %w", err)
+	}
+	defer reader.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := stdcopy.StdCopy(pw, pw, reader)
+		pw.CloseWithError(err)
+	}()
+	defer pr.Close()
+
+	scanner := bufio.NewScanner(pr)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		forEachLine(scanner.Text(), logLine)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read container logs: %w", err)
 	}
-	
 	return nil
 }
 
-func (d *DockerClient) runContainer(ctx context.Context, imageName string, worklet *Worklet) (string, int, error) {
-	port, err := d.findFreePort()
-	if err != nil {
-		return "", 0, fmt.Errorf("failed to find free port: %w", err)
+func (d *DockerClient) runContainer(ctx context.Context, imageName string, worklet *Worklet, port int, caches []dependencyCache, logLine LogFunc) (string, int, error) {
+	if port == 0 {
+		var err error
+		if port, err = d.findFreePort(); err != nil {
+			return "", 0, fmt.Errorf("failed to find free port: %w", err)
+		}
 	}
 	
 	containerPort := nat.Port("3000/tcp")
@@ -108,6 +223,9 @@ func (d *DockerClient) runContainer(ctx context.Context, imageName string, workl
 		"PORT=3000",
 	}
 	
+	mounts, cacheEnv := d.cacheMounts(ctx, caches)
+	env = append(env, cacheEnv...)
+	
 	if worklet.Environment != nil {
 		for key, value := range worklet.Environment.Data {
 			env = append(env, fmt.Sprintf("%s=%s", key, value))
@@ -118,8 +236,6 @@ func (d *DockerClient) runContainer(ctx context.Context, imageName string, workl
 		Image:        imageName,
 		ExposedPorts: nat.PortSet{containerPort: struct{}{}},
 		Env:          env,
-		WorkingDir:   "/app",
-		Cmd:          []string{"npm", "start"},
 	}
 	
 	hostConfig := &container.HostConfig{
@@ -129,6 +245,7 @@ func (d *DockerClient) runContainer(ctx context.Context, imageName string, workl
 		RestartPolicy: container.RestartPolicy{
 			Name: "unless-stopped",
 		},
+		Mounts: mounts,
 	}
 	
 	networkConfig := &network.NetworkingConfig{}
@@ -141,11 +258,19 @@ func (d *DockerClient) runContainer(ctx context.Context, imageName string, workl
 	}
 	
 	if err := d.client.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		// The container holds the worklet's name, so it's removed to make way for the next try
+		if removeErr := d.RemoveContainer(resp.ID); removeErr != nil {
+			slog.Warn("Failed to remove container that didn't start", "error", removeErr, "containerID", resp.ID)
+		}
+		if isBindError(err) {
+			return "", 0, &PortConflictError{Port: port, Err: err}
+		}
 		return "", 0, fmt.Errorf("failed to start container: %w", err)
 	}
 	
 	time.Sleep(2 * time.Second)
 	
+	d.appendContainerLogs(ctx, resp.ID, worklet, logLine)
 	if !d.isContainerHealthy(ctx, resp.ID) {
 		return "", 0, fmt.Errorf("container failed to start properly")
 	}
@@ -179,7 +304,7 @@ func (d *DockerClient) RemoveContainer(containerID string) error {
 }
 
 func (d *DockerClient) RestartContainer(containerID string) error {
-	if d.client == nil {
+	if d == nil || d.client == nil {
 		return fmt.Errorf("docker client not initialized")
 	}
 	
@@ -191,69 +316,179 @@ func (d *DockerClient) RestartContainer(containerID string) error {
 	})
 }
 
-func (d *DockerClient) generateDockerfile(repoPath string) string {
-	hasPackageJson := false
-	hasRequirementsTxt := false
-	hasGoMod := false
-	
-	if _, err := os.Stat(filepath.Join(repoPath, "package.json")); err == nil {
-		hasPackageJson = true
+// Exec runs cmd in the container and returns its output, stdout and stderr
+// together, and its exit code
+func (d *DockerClient) Exec(ctx context.Context, containerID string, cmd []string) (string, int, error) {
+	if d == nil || d.client == nil {
+		return "", 0, fmt.Errorf("docker client not initialized")
+	}
+	if containerID == "" {
+		return "", 0, ErrNoContainer
 	}
-	if _, err := os.Stat(filepath.Join(repoPath, "requirements.txt")); err == nil {
-		hasRequirementsTxt = true
+
+	created, err := d.client.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create exec: %w", err)
 	}
-	if _, err := os.Stat(filepath.Join(repoPath, "go.mod")); err == nil {
-		hasGoMod = true
+	attached, err := d.client.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to attach to exec: %w", err)
 	}
-	
-	var dockerfile strings.Builder
-	
-	if hasPackageJson {
-		dockerfile.WriteString(`FROM node:18-alpine
-WORKDIR /app
-COPY package*.json ./
-RUN npm ci --only=production
-COPY . .
-EXPOSE 3000
-CMD ["npm", "start"]
-`)
-	} else if hasRequirementsTxt {
-		dockerfile.WriteString(`FROM python:3.9-slim
-WORKDIR /app
-COPY requirements.txt .
-RUN pip install -r requirements.txt
-COPY . .
-EXPOSE 3000
-CMD ["python", "app.py"]
-`)
-	} else if hasGoMod {
-		dockerfile.WriteString(`FROM golang:1.21-alpine AS builder
-WORKDIR /app
-COPY go.mod go.sum ./
-RUN go mod download
-COPY . .
-RUN go build -o main .
-
-FROM alpine:latest
-RUN apk --no-cache add ca-certificates
-WORKDIR /root/
-COPY --from=builder /app/main .
-EXPOSE 3000
-CMD ["./main"]
-`)
-	} else {
-		dockerfile.WriteString(`FROM nginx:alpine
-COPY . /usr/share/nginx/html
-EXPOSE 80
-CMD ["nginx", "-g", "daemon off;"]
-`)
+	defer attached.Close()
+	// Closing the connection stops the copy below when ctx is done
+	stop := context.AfterFunc(ctx, attached.Close)
+	defer stop()
+
+	var output bytes.Buffer
+	_, err = stdcopy.StdCopy(&output, &output, attached.Reader)
+	if ctx.Err() != nil {
+		return output.String(), 0, fmt.Errorf("command didn't finish: %w", ctx.Err())
 	}
-	
-	return dockerfile.String()
+	if err != nil {
+		return output.String(), 0, fmt.Errorf("failed to read exec output: %w", err)
+	}
+
+	inspect, err := d.client.ContainerExecInspect(ctx, created.ID)
+	if err != nil {
+		return output.String(), 0, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+	return output.String(), inspect.ExitCode, nil
+}
+
+// dockerShell is an interactive exec in a container, attached to a terminal
+type dockerShell struct {
+	client   *client.Client
+	execID   string
+	hijacked types.HijackedResponse
+}
+
+// Shell starts cmd in the container with a terminal and attaches to it
+func (d *DockerClient) Shell(ctx context.Context, containerID string, cmd []string) (ShellProcess, error) {
+	if d == nil || d.client == nil {
+		return nil, fmt.Errorf("docker client not initialized")
+	}
+	if containerID == "" {
+		return nil, ErrNoContainer
+	}
+
+	created, err := d.client.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd:          cmd,
+		Tty:          true,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Env:          []string{"TERM=xterm-256color"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec: %w", err)
+	}
+	hijacked, err := d.client.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{Tty: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach to exec: %w", err)
+	}
+	return &dockerShell{client: d.client, execID: created.ID, hijacked: hijacked}, nil
+}
+
+func (s *dockerShell) Read(p []byte) (int, error) {
+	return s.hijacked.Reader.Read(p)
+}
+
+func (s *dockerShell) Write(p []byte) (int, error) {
+	return s.hijacked.Conn.Write(p)
+}
+
+func (s *dockerShell) Resize(rows, cols uint) error {
+	return s.client.ContainerExecResize(context.Background(), s.execID, container.ResizeOptions{Height: rows, Width: cols})
+}
+
+func (s *dockerShell) Close() error {
+	s.hijacked.Close()
+	return nil
+}
+
+func (s *dockerShell) ExitCode() (int, error) {
+	inspect, err := s.client.ContainerExecInspect(context.Background(), s.execID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+	if inspect.Running {
+		return 0, errShellRunning
+	}
+	return inspect.ExitCode, nil
+}
+
+// ReadFiles returns the regular files in the container matching patterns,
+// paths or globs relative to its working directory. A directory matches
+// everything in it. Files larger than maxSize are skipped.
+func (d *DockerClient) ReadFiles(ctx context.Context, containerID string, patterns []string, maxSize int64) ([]ArtifactFile, error) {
+	if d == nil || d.client == nil {
+		return nil, fmt.Errorf("docker client not initialized")
+	}
+
+	// The shell expands the globs; patterns are validated to have no other special characters
+	var script strings.Builder
+	script.WriteString(`echo "$PWD"; `)
+	for _, pattern := range patterns {
+		fmt.Fprintf(&script, `for f in %s; do [ -e "$f" ] && echo "$f"; done; `, pattern)
+	}
+	output, _, err := d.Exec(ctx, containerID, []string{"sh", "-c", script.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find artifacts: %w", err)
+	}
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	workDir, matches := lines[0], lines[1:]
+
+	var files []ArtifactFile
+	seen := make(map[string]bool)
+	for _, match := range matches {
+		if match == "" {
+			continue
+		}
+		archive, _, err := d.client.CopyFromContainer(ctx, containerID, path.Join(workDir, match))
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy %s: %w", match, err)
+		}
+		// Entries are named from the match's base name
+		dir := path.Dir(path.Clean(match))
+		reader := tar.NewReader(archive)
+		for {
+			header, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				archive.Close()
+				return nil, fmt.Errorf("failed to read %s: %w", match, err)
+			}
+			name := path.Join(dir, header.Name)
+			if header.Typeflag != tar.TypeReg || seen[name] {
+				continue
+			}
+			if header.Size > maxSize {
+				slog.Warn("Skipping artifact larger than the limit", "path", name, "size", header.Size, "maxSize", maxSize)
+				continue
+			}
+			data, err := io.ReadAll(reader)
+			if err != nil {
+				archive.Close()
+				return nil, fmt.Errorf("failed to read %s: %w", name, err)
+			}
+			seen[name] = true
+			files = append(files, ArtifactFile{Path: name, Data: data})
+		}
+		archive.Close()
+	}
+	return files, nil
 }
 
-func (d *DockerClient) createBuildContext(repoPath string) (io.ReadCloser, error) {
-	return os.Open(repoPath)
+// imageExists reports whether the daemon has the image
+func (d *DockerClient) imageExists(ctx context.Context, imageName string) bool {
+	_, _, err := d.client.ImageInspectWithRaw(ctx, imageName)
+	return err == nil
 }
 
 func (d *DockerClient) findFreePort() (int, error) {
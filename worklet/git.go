@@ -6,13 +6,17 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/memory"
 )
 
 type GitClient struct {
@@ -30,52 +34,148 @@ func NewGitClient() *GitClient {
 	}
 }
 
+// CheckoutSpec is the revision of a repository a worklet runs
+type CheckoutSpec struct {
+	RepoURL   string
+	Branch    string // Branch to clone; empty for the repository's default branch
+	Ref       string // Optional tag or commit SHA to pin to instead of the branch's latest commit
+	Depth     int    // Commits of history to clone; 0 for all of it
+	WorkletID string // Worklet the checkout is for; each worklet gets its own, so prompts to one don't edit another's files
+}
+
+// commitSHARegex matches an abbreviated or full commit SHA
+var commitSHARegex = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+// pinsCommit reports whether the spec's ref is a commit SHA rather than a tag
+func (s CheckoutSpec) pinsCommit() bool {
+	return commitSHARegex.MatchString(s.Ref)
+}
+
 func (g *GitClient) CloneRepository(repoURL, branch string) (string, error) {
-	repoPath := g.getRepoPath(repoURL, branch)
+	repoPath, _, err := g.Checkout(CheckoutSpec{RepoURL: repoURL, Branch: branch})
+	return repoPath, err
+}
+
+// Checkout clones the repository, or updates an earlier clone, at spec's
+// revision and returns where it is and the SHA of the commit checked out
+func (g *GitClient) Checkout(spec CheckoutSpec) (string, string, error) {
+	repoPath := g.RepoPath(spec)
 	
 	if _, err := os.Stat(repoPath); err == nil {
-		slog.Info("Repository already exists, pulling latest changes", "path", repoPath)
-		if err := g.pullRepository(repoPath, branch); err != nil {
-			slog.Error("Failed to pull repository, will re-clone", "error", err)
+		slog.Info("Repository already exists, updating it", "path", repoPath, "ref", spec.Ref)
+		if err := g.updateCheckout(repoPath, spec); err != nil {
+			slog.Error("Failed to update repository, will re-clone", "error", err)
 			if err := os.RemoveAll(repoPath); err != nil {
-				return "", fmt.Errorf("failed to remove existing repo: %w", err)
+				return "", "", fmt.Errorf("failed to remove existing repo: %w", err)
 			}
 		} else {
-			return repoPath, nil
+			return g.headCommit(repoPath)
 		}
 	}
 	
-	slog.Info("Cloning repository", "url", repoURL, "branch", branch, "path", repoPath)
+	slog.Info("Cloning repository", "url", spec.RepoURL, "branch", spec.Branch, "ref", spec.Ref, "depth", spec.Depth, "path", repoPath)
 	
 	cloneOptions := &git.CloneOptions{
-		URL:      repoURL,
+		URL:      spec.RepoURL,
 		Progress: os.Stdout,
+		Auth:     g.auth(spec.RepoURL),
 	}
 	
-	if branch != "" && branch != "main" && branch != "master" {
-		cloneOptions.ReferenceName = plumbing.NewBranchReferenceName(branch)
+	switch {
+	case spec.Ref != "" && !spec.pinsCommit():
+		cloneOptions.ReferenceName = plumbing.NewTagReferenceName(spec.Ref)
 		cloneOptions.SingleBranch = true
+		cloneOptions.Depth = spec.Depth
+	case spec.Branch != "":
+		cloneOptions.ReferenceName = plumbing.NewBranchReferenceName(spec.Branch)
+		cloneOptions.SingleBranch = true
+		// A pinned commit may be older than a shallow clone's history
+		if !spec.pinsCommit() {
+			cloneOptions.Depth = spec.Depth
+		}
 	}
 	
-	if g.isPrivateRepo(repoURL) {
-		token := os.Getenv("GITHUB_TOKEN")
-		if token != "" {
-			cloneOptions.Auth = &http.BasicAuth{
-				Username: "token",
-				Password: token,
-			}
+	repo, err := git.PlainClone(repoPath, false, cloneOptions)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to clone repository: %w", err)
+	}
+	if spec.pinsCommit() {
+		if err := checkoutRevision(repo, spec.Ref); err != nil {
+			return "", "", err
 		}
 	}
 	
-	_, err := git.PlainClone(repoPath, false, cloneOptions)
+	return g.headCommit(repoPath)
+}
+
+// updateCheckout brings an earlier clone to spec's revision: the pinned
+// commit or tag, or the branch's latest commit
+func (g *GitClient) updateCheckout(repoPath string, spec CheckoutSpec) error {
+	if spec.Ref == "" {
+		return g.pullRepository(repoPath, spec.Branch, spec.Depth)
+	}
+	
+	repo, err := git.PlainOpen(repoPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to clone repository: %w", err)
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+	revision := spec.Ref
+	if !spec.pinsCommit() {
+		revision = plumbing.NewTagReferenceName(spec.Ref).String()
+	}
+	return checkoutRevision(repo, revision)
+}
+
+// checkoutRevision checks out the commit a SHA, tag, or other revision names
+func checkoutRevision(repo *git.Repository, revision string) error {
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return fmt.Errorf("failed to find %s: %w", revision, err)
 	}
 	
-	return repoPath, nil
+	workTree, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+	if err := workTree.Checkout(&git.CheckoutOptions{Hash: *hash}); err != nil {
+		return fmt.Errorf("failed to checkout %s: %w", revision, err)
+	}
+	return nil
 }
 
-func (g *GitClient) pullRepository(repoPath, branch string) error {
+// headCommit returns repoPath and the SHA of the commit checked out there
+func (g *GitClient) headCommit(repoPath string) (string, string, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open repository: %w", err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get HEAD reference: %w", err)
+	}
+	return repoPath, head.Hash().String(), nil
+}
+
+// DefaultBranch returns the branch the repository's HEAD points to
+func (g *GitClient) DefaultBranch(repoURL string) (string, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{repoURL},
+	})
+	refs, err := remote.List(&git.ListOptions{Auth: g.auth(repoURL)})
+	if err != nil {
+		return "", fmt.Errorf("failed to list repository references: %w", err)
+	}
+	
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference && ref.Target().IsBranch() {
+			return ref.Target().Short(), nil
+		}
+	}
+	return "", fmt.Errorf("repository has no default branch")
+}
+
+func (g *GitClient) pullRepository(repoPath, branch string, depth int) error {
 	repo, err := git.PlainOpen(repoPath)
 	if err != nil {
 		return fmt.Errorf("failed to open repository: %w", err)
@@ -88,9 +188,10 @@ func (g *GitClient) pullRepository(repoPath, branch string) error {
 	
 	pullOptions := &git.PullOptions{
 		RemoteName: "origin",
+		Depth:      depth,
 	}
 	
-	if branch != "" && branch != "main" && branch != "master" {
+	if branch != "" {
 		pullOptions.ReferenceName = plumbing.NewBranchReferenceName(branch)
 	}
 	
@@ -103,13 +204,29 @@ func (g *GitClient) pullRepository(repoPath, branch string) error {
 }
 
 func (g *GitClient) GetRepoPath(repoURL, branch string) string {
-	return g.getRepoPath(repoURL, branch)
+	return g.RepoPath(CheckoutSpec{RepoURL: repoURL, Branch: branch})
+}
+
+// RepoPath returns where the spec's revision of the repository is checked out
+func (g *GitClient) RepoPath(spec CheckoutSpec) string {
+	repoName := g.extractRepoName(spec.RepoURL)
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(spec.RepoURL+spec.Branch+spec.Ref+spec.WorkletID)))[:8]
+	return filepath.Join(g.baseDir, fmt.Sprintf("%s-%s-%s", repoName, spec.Branch, hash))
 }
 
-func (g *GitClient) getRepoPath(repoURL, branch string) string {
-	repoName := g.extractRepoName(repoURL)
-	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(repoURL+branch)))[:8]
-	return filepath.Join(g.baseDir, fmt.Sprintf("%s-%s-%s", repoName, branch, hash))
+// auth returns the credentials for cloning repoURL, if it needs any
+func (g *GitClient) auth(repoURL string) transport.AuthMethod {
+	if !g.isPrivateRepo(repoURL) {
+		return nil
+	}
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		return nil
+	}
+	return &http.BasicAuth{
+		Username: "token",
+		Password: token,
+	}
 }
 
 func (g *GitClient) extractRepoName(repoURL string) string {
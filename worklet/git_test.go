@@ -0,0 +1,136 @@
+package worklet
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRemote creates a repository on the "trunk" branch with three
+// commits, the first tagged v1, and returns its path and commits in order
+func newTestRemote(t *testing.T) (string, []string) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	require.NoError(t, repo.Storer.SetReference(
+		plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName("trunk"))))
+	workTree, err := repo.Worktree()
+	require.NoError(t, err)
+
+	var commits []string
+	for i, content := range []string{"one", "two", "three"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "version.txt"), []byte(content), 0644))
+		_, err := workTree.Add("version.txt")
+		require.NoError(t, err)
+		hash, err := workTree.Commit("Version "+content, &git.CommitOptions{
+			Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now().Add(time.Duration(i) * time.Minute)},
+		})
+		require.NoError(t, err)
+		commits = append(commits, hash.String())
+		if i == 0 {
+			_, err := repo.CreateTag("v1", hash, nil)
+			require.NoError(t, err)
+		}
+	}
+	return dir, commits
+}
+
+func readVersion(t *testing.T, repoPath string) string {
+	content, err := os.ReadFile(filepath.Join(repoPath, "version.txt"))
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestCheckout(t *testing.T) {
+	remote, commits := newTestRemote(t)
+
+	tests := []struct {
+		name    string
+		spec    CheckoutSpec
+		commit  string
+		version string
+	}{
+		{"branch", CheckoutSpec{Branch: "trunk"}, commits[2], "three"},
+		{"tag", CheckoutSpec{Branch: "trunk", Ref: "v1"}, commits[0], "one"},
+		{"commit", CheckoutSpec{Branch: "trunk", Ref: commits[1]}, commits[1], "two"},
+		{"short commit", CheckoutSpec{Branch: "trunk", Ref: commits[1][:8]}, commits[1], "two"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &GitClient{baseDir: t.TempDir()}
+			tt.spec.RepoURL = remote
+
+			repoPath, commit, err := g.Checkout(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, g.RepoPath(tt.spec), repoPath)
+			assert.Equal(t, tt.commit, commit)
+			assert.Equal(t, tt.version, readVersion(t, repoPath))
+
+			// Checking out again updates the existing clone
+			_, commit, err = g.Checkout(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.commit, commit)
+		})
+	}
+}
+
+func TestCheckoutUnknownRef(t *testing.T) {
+	remote, _ := newTestRemote(t)
+	g := &GitClient{baseDir: t.TempDir()}
+
+	_, _, err := g.Checkout(CheckoutSpec{RepoURL: remote, Branch: "trunk", Ref: "deadbeef"})
+	assert.Error(t, err)
+}
+
+func TestRepoPathDependsOnRef(t *testing.T) {
+	g := &GitClient{baseDir: "/repos"}
+	branch := g.RepoPath(CheckoutSpec{RepoURL: "https://github.com/acme/site", Branch: "main"})
+	tag := g.RepoPath(CheckoutSpec{RepoURL: "https://github.com/acme/site", Branch: "main", Ref: "v1"})
+
+	assert.NotEqual(t, branch, tag)
+	assert.Equal(t, g.GetRepoPath("https://github.com/acme/site", "main"), branch)
+}
+
+func TestDefaultBranch(t *testing.T) {
+	remote, _ := newTestRemote(t)
+
+	branch, err := (&GitClient{}).DefaultBranch(remote)
+	require.NoError(t, err)
+	assert.Equal(t, "trunk", branch)
+}
+
+func TestCreateWorkletValidatesCheckout(t *testing.T) {
+	router, _ := newTestAPI(t)
+
+	for _, body := range []string{
+		`{"name":"Site","git_repo":"https://github.com/acme/site","branch":"--upload-pack=evil"}`,
+		`{"name":"Site","git_repo":"https://github.com/acme/site","ref":"v1..v2"}`,
+		`{"name":"Site","git_repo":"https://github.com/acme/site","ref":"v1 v2"}`,
+		`{"name":"Site","git_repo":"https://github.com/acme/site","clone_depth":-1}`,
+	} {
+		rr := serve(router, "POST", "/api/worklet/worklets", "alice", body)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+	}
+}
+
+func TestCheckoutShallow(t *testing.T) {
+	remote, commits := newTestRemote(t)
+	g := &GitClient{baseDir: t.TempDir()}
+
+	repoPath, commit, err := g.Checkout(CheckoutSpec{RepoURL: "file://" + remote, Branch: "trunk", Depth: 1})
+	require.NoError(t, err)
+	assert.Equal(t, commits[2], commit)
+
+	repo, err := git.PlainOpen(repoPath)
+	require.NoError(t, err)
+	_, err = repo.CommitObject(plumbing.NewHash(commits[1]))
+	assert.Error(t, err, "history beyond the clone depth shouldn't be fetched")
+}
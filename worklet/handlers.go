@@ -1,334 +1,1108 @@
 package worklet
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/breadchris/flow/deps"
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/shutdown"
 	"github.com/gorilla/mux"
+	"gorm.io/gorm"
 )
 
 type WorkletHandler struct {
-	manager *Manager
-	deps    *deps.Deps
+	manager  *Manager
+	deps     *deps.Deps
+	signedIn func(r *http.Request) (string, error) // Who signed in to make a request, from the session cookie
 }
 
-func NewWorkletHandler(deps *deps.Deps) *WorkletHandler {
+// NewWorkletHandler serves the API for manager's worklets. The manager is
+// passed in so the Slack bot and the API share its cache, prompt locks, and
+// background loops.
+func NewWorkletHandler(deps *deps.Deps, manager *Manager) *WorkletHandler {
 	return &WorkletHandler{
-		manager: NewManager(deps),
-		deps:    deps,
+		manager:  manager,
+		deps:     deps,
+		signedIn: sessionUserID(deps),
 	}
 }
 
+// sessionUserID returns the function that finds who signed in to make a
+// request. Unlike the X-User-ID header, the session can't be forged.
+func sessionUserID(d *deps.Deps) func(r *http.Request) (string, error) {
+	return func(r *http.Request) (string, error) {
+		if d == nil || d.Session == nil {
+			return "", fmt.Errorf("no session manager")
+		}
+		return d.Session.UserIDFromRequest(r)
+	}
+}
+
+// Manager returns the worklet manager backing the handler
+func (h *WorkletHandler) Manager() *Manager {
+	return h.manager
+}
+
+// Actions POST /worklets/{id}/actions/{action} takes
+const (
+	ActionStart   = "start"   // Rebuild a stopped worklet
+	ActionStop    = "stop"    // Stop the worklet's container
+	ActionRestart = "restart" // Restart the container without rebuilding
+	ActionRebuild = "rebuild" // Clone, build, and deploy the worklet again
+)
+
+// logKeepalive is how often an idle log or status stream sends a comment so
+// proxies don't close it
+const logKeepalive = 15 * time.Second
+
+// ErrorResponse is the envelope every error from the worklet API is sent in
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody is a machine-readable error code and a message for people
+type ErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// errorStatus maps manager errors to HTTP status codes
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, shutdown.ErrDraining):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrTemplateNotOwned):
+		return http.StatusForbidden
+	case errors.As(err, new(*PortConflictError)):
+		return http.StatusConflict
+	case errors.Is(err, ErrPortsExhausted):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrNotRunning), errors.Is(err, ErrNoContainer), errors.Is(err, ErrNoCheckout),
+		errors.Is(err, ErrTestsFailed), errors.Is(err, ErrTestsRunning), errors.Is(err, ErrNoPromptDiff),
+		errors.Is(err, ErrTemplateExists):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// errorCodes are the codes sent with each status
+var errorCodes = map[int]string{
+	http.StatusBadRequest:          "invalid_request",
+	http.StatusForbidden:           "forbidden",
+	http.StatusNotFound:            "not_found",
+	http.StatusConflict:            "conflict",
+	http.StatusInternalServerError: "internal",
+	http.StatusServiceUnavailable:  "unavailable",
+}
+
+// writeJSON sends v as JSON with status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError sends an error in the API's envelope
+func writeError(w http.ResponseWriter, status int, message string) {
+	code, ok := errorCodes[status]
+	if !ok {
+		code = "error"
+	}
+	writeJSON(w, status, ErrorResponse{Error: ErrorBody{Code: code, Message: message}})
+}
+
+// writeManagerError sends a manager error with the status it maps to
+func writeManagerError(w http.ResponseWriter, action string, err error) {
+	writeError(w, errorStatus(err), fmt.Sprintf("Failed to %s: %v", action, err))
+}
+
 func (h *WorkletHandler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/worklets", h.CreateWorklet).Methods("POST")
 	router.HandleFunc("/worklets", h.ListWorklets).Methods("GET")
 	router.HandleFunc("/worklets/{id}", h.GetWorklet).Methods("GET")
+	router.HandleFunc("/worklets/{id}", h.UpdateWorklet).Methods("PATCH")
 	router.HandleFunc("/worklets/{id}", h.DeleteWorklet).Methods("DELETE")
+	router.HandleFunc("/worklets/{id}/actions/{action}", h.WorkletAction).Methods("POST")
 	router.HandleFunc("/worklets/{id}/start", h.StartWorklet).Methods("POST")
 	router.HandleFunc("/worklets/{id}/stop", h.StopWorklet).Methods("POST")
 	router.HandleFunc("/worklets/{id}/restart", h.RestartWorklet).Methods("POST")
 	router.HandleFunc("/worklets/{id}/prompt", h.ProcessPrompt).Methods("POST")
+	router.HandleFunc("/worklets/{id}/prompts", h.ListPrompts).Methods("GET")
+	router.HandleFunc("/worklets/{id}/prompts/{promptID}/diff", h.GetPromptDiff).Methods("GET")
 	router.HandleFunc("/worklets/{id}/pr", h.CreatePR).Methods("POST")
 	router.HandleFunc("/worklets/{id}/proxy", h.ProxyToWorklet).Methods("GET", "POST", "PUT", "DELETE", "PATCH")
 	router.HandleFunc("/worklets/{id}/proxy/{path:.*}", h.ProxyToWorklet).Methods("GET", "POST", "PUT", "DELETE", "PATCH")
 	router.HandleFunc("/worklets/{id}/logs", h.GetLogs).Methods("GET")
 	router.HandleFunc("/worklets/{id}/status", h.GetStatus).Methods("GET")
+	router.HandleFunc("/worklets/{id}/events", h.StreamEvents).Methods("GET")
+	router.HandleFunc("/worklets/{id}/diff", h.GetDiff).Methods("GET")
+	router.HandleFunc("/worklets/{id}/clone", h.CloneWorklet).Methods("POST")
+	router.HandleFunc("/worklets/{id}/artifacts", h.ListArtifacts).Methods("GET")
+	router.HandleFunc("/worklets/{id}/shell", h.ShellWorklet).Methods("GET")
+	router.HandleFunc("/worklets/{id}/shell/sessions", h.ListShellSessions).Methods("GET")
+	router.HandleFunc("/worklets/{id}/shell/sessions/{sessionID}", h.GetShellRecording).Methods("GET")
+	router.HandleFunc("/worklets/{id}/schedules", h.CreateSchedule).Methods("POST")
+	router.HandleFunc("/worklets/{id}/schedules", h.ListSchedules).Methods("GET")
+	router.HandleFunc("/worklets/{id}/schedules/{scheduleID}", h.DeleteSchedule).Methods("DELETE")
+	router.HandleFunc("/worklets/{id}/schedules/{scheduleID}/runs", h.ListScheduleRuns).Methods("GET")
+	router.HandleFunc("/hooks/github", h.GitHubWebhook).Methods("POST")
+	router.HandleFunc("/templates", h.CreateTemplate).Methods("POST")
+	router.HandleFunc("/templates", h.ListTemplates).Methods("GET")
+	router.HandleFunc("/templates/{name}", h.GetTemplate).Methods("GET")
+	router.HandleFunc("/templates/{name}", h.DeleteTemplate).Methods("DELETE")
+	router.HandleFunc("/templates/{name}/worklets", h.CreateFromTemplate).Methods("POST")
+	router.HandleFunc("/{id}/logs", h.GetLogs).Methods("GET")
+	router.HandleFunc("/{id}/diff", h.GetDiff).Methods("GET")
+	router.HandleFunc("/{id}/prompt", h.ProcessPrompt).Methods("POST")
+	router.HandleFunc("/{id}/prompts", h.ListPrompts).Methods("GET")
+	router.HandleFunc("/{id}/clone", h.CloneWorklet).Methods("POST")
 }
 
 // New returns a *http.ServeMux with worklet routes following the main.go pattern
 func New(deps *deps.Deps) *http.ServeMux {
-	h := NewWorkletHandler(deps)
+	h := NewWorkletHandler(deps, NewManager(deps))
 	m := http.NewServeMux()
-	
+
 	// Convert mux.Router patterns to http.ServeMux patterns
 	m.HandleFunc("POST /worklets", h.CreateWorklet)
 	m.HandleFunc("GET /worklets", h.ListWorklets)
 	m.HandleFunc("GET /worklets/{id}", h.GetWorklet)
+	m.HandleFunc("PATCH /worklets/{id}", h.UpdateWorklet)
 	m.HandleFunc("DELETE /worklets/{id}", h.DeleteWorklet)
+	m.HandleFunc("POST /worklets/{id}/actions/{action}", h.WorkletAction)
 	m.HandleFunc("POST /worklets/{id}/start", h.StartWorklet)
 	m.HandleFunc("POST /worklets/{id}/stop", h.StopWorklet)
 	m.HandleFunc("POST /worklets/{id}/restart", h.RestartWorklet)
 	m.HandleFunc("POST /worklets/{id}/prompt", h.ProcessPrompt)
+	m.HandleFunc("GET /worklets/{id}/prompts", h.ListPrompts)
+	m.HandleFunc("GET /worklets/{id}/prompts/{promptID}/diff", h.GetPromptDiff)
 	m.HandleFunc("POST /worklets/{id}/pr", h.CreatePR)
 	m.HandleFunc("/worklets/{id}/proxy", h.ProxyToWorklet)
 	m.HandleFunc("/worklets/{id}/proxy/{path...}", h.ProxyToWorklet)
 	m.HandleFunc("GET /worklets/{id}/logs", h.GetLogs)
 	m.HandleFunc("GET /worklets/{id}/status", h.GetStatus)
-	
+	m.HandleFunc("GET /worklets/{id}/events", h.StreamEvents)
+	m.HandleFunc("GET /worklets/{id}/diff", h.GetDiff)
+	m.HandleFunc("POST /worklets/{id}/clone", h.CloneWorklet)
+	m.HandleFunc("GET /worklets/{id}/artifacts", h.ListArtifacts)
+	m.HandleFunc("GET /worklets/{id}/shell", h.ShellWorklet)
+	m.HandleFunc("GET /worklets/{id}/shell/sessions", h.ListShellSessions)
+	m.HandleFunc("GET /worklets/{id}/shell/sessions/{sessionID}", h.GetShellRecording)
+	m.HandleFunc("POST /worklets/{id}/schedules", h.CreateSchedule)
+	m.HandleFunc("GET /worklets/{id}/schedules", h.ListSchedules)
+	m.HandleFunc("DELETE /worklets/{id}/schedules/{scheduleID}", h.DeleteSchedule)
+	m.HandleFunc("GET /worklets/{id}/schedules/{scheduleID}/runs", h.ListScheduleRuns)
+	m.HandleFunc("POST /hooks/github", h.GitHubWebhook)
+	m.HandleFunc("POST /templates", h.CreateTemplate)
+	m.HandleFunc("GET /templates", h.ListTemplates)
+	m.HandleFunc("GET /templates/{name}", h.GetTemplate)
+	m.HandleFunc("DELETE /templates/{name}", h.DeleteTemplate)
+	m.HandleFunc("POST /templates/{name}/worklets", h.CreateFromTemplate)
+	// The /{id}/... shorthands aren't registered here: ServeMux can't tell
+	// them apart from /worklets/{id} and /templates/{name}
+
 	return m
 }
 
+// pathValue reads a path parameter from either the gorilla router or http.ServeMux
+func pathValue(r *http.Request, name string) string {
+	if value := r.PathValue(name); value != "" {
+		return value
+	}
+	return mux.Vars(r)[name]
+}
+
+// ownedWorklet loads the worklet in the request's path, sending an error and
+// returning false when it doesn't exist or belongs to someone else
+func (h *WorkletHandler) ownedWorklet(w http.ResponseWriter, r *http.Request) (*Worklet, bool) {
+	worklet, err := h.manager.GetWorklet(pathValue(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Worklet not found: %v", err))
+		return nil, false
+	}
+	if err := h.validateWorkletAccess(r, worklet); err != nil {
+		writeError(w, http.StatusForbidden, "You don't have access to this worklet")
+		return nil, false
+	}
+	return worklet, true
+}
+
 func (h *WorkletHandler) CreateWorklet(w http.ResponseWriter, r *http.Request) {
 	var req CreateWorkletRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
 		return
 	}
-	
+
 	if req.Name == "" {
-		http.Error(w, "Name is required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "Name is required")
 		return
 	}
-	
+
 	if req.GitRepo == "" {
-		http.Error(w, "Git repository is required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "Git repository is required")
+		return
+	}
+
+	if err := validateEnvironment(req.Environment); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateCheckout(req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateArtifacts(req.Artifacts); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.HealthCheck != nil {
+		if err := req.HealthCheck.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.Preview != nil {
+		if err := req.Preview.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	strategy, err := ParseBuildStrategy(string(req.BuildStrategy))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	
+	req.BuildStrategy = strategy
+
 	userID := h.getUserID(r)
-	
-	worklet, err := h.manager.CreateWorklet(r.Context(), req, userID)
+
+	// Deployment continues in the background after the request returns
+	worklet, err := h.manager.CreateWorklet(context.WithoutCancel(r.Context()), req, userID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create worklet: %v", err), http.StatusInternalServerError)
+		writeManagerError(w, "create worklet", err)
 		return
 	}
-	
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(worklet.ToResponse())
+
+	writeJSON(w, http.StatusOK, h.response(worklet))
 }
 
+// ListWorklets lists the user's worklets, newest first. status, name, and
+// git_repo filter the list, and limit and offset page through it. The
+// X-Total-Count header is how many match in all.
 func (h *WorkletHandler) ListWorklets(w http.ResponseWriter, r *http.Request) {
-	userID := h.getUserID(r)
-	
-	worklets, err := h.manager.ListWorklets(userID)
+	query := r.URL.Query()
+	filter := WorkletFilter{
+		Status:  Status(query.Get("status")),
+		Name:    query.Get("name"),
+		GitRepo: query.Get("git_repo"),
+		Limit:   h.parseIntParam(r, "limit", 50),
+		Offset:  h.parseIntParam(r, "offset", 0),
+	}
+	if filter.Limit <= 0 || filter.Offset < 0 {
+		writeError(w, http.StatusBadRequest, "limit must be positive and offset may not be negative")
+		return
+	}
+	filter.Limit = min(filter.Limit, maxListLimit)
+
+	worklets, total, err := h.manager.QueryWorklets(h.getUserID(r), filter)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to list worklets: %v", err), http.StatusInternalServerError)
+		writeManagerError(w, "list worklets", err)
 		return
 	}
-	
-	var responses []WorkletResponse
+
+	responses := make([]WorkletResponse, 0, len(worklets))
 	for _, worklet := range worklets {
-		responses = append(responses, worklet.ToResponse())
+		responses = append(responses, h.response(worklet))
 	}
-	
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(responses)
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	writeJSON(w, http.StatusOK, responses)
 }
 
 func (h *WorkletHandler) GetWorklet(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	
-	worklet, err := h.manager.GetWorklet(id)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Worklet not found: %v", err), http.StatusNotFound)
+	worklet, ok := h.ownedWorklet(w, r)
+	if !ok {
 		return
 	}
-	
-	userID := h.getUserID(r)
-	if worklet.UserID != userID {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+
+	writeJSON(w, http.StatusOK, h.response(worklet))
+}
+
+// UpdateWorklet changes a worklet's name, description, base prompt, or
+// environment. Rebuild the worklet to deploy a new prompt or environment.
+func (h *WorkletHandler) UpdateWorklet(w http.ResponseWriter, r *http.Request) {
+	worklet, ok := h.ownedWorklet(w, r)
+	if !ok {
 		return
 	}
-	
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(worklet.ToResponse())
-}
 
-func (h *WorkletHandler) DeleteWorklet(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	
-	worklet, err := h.manager.GetWorklet(id)
+	var req UpdateWorkletRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		writeError(w, http.StatusBadRequest, "Name can't be empty")
+		return
+	}
+	if req.Environment != nil {
+		if err := validateEnvironment(*req.Environment); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.Artifacts != nil {
+		if err := validateArtifacts(*req.Artifacts); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.HealthCheck != nil {
+		if err := req.HealthCheck.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.Preview != nil {
+		if err := req.Preview.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	updated, err := h.manager.UpdateWorklet(worklet.ID, req)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Worklet not found: %v", err), http.StatusNotFound)
+		writeManagerError(w, "update worklet", err)
 		return
 	}
-	
-	userID := h.getUserID(r)
-	if worklet.UserID != userID {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+
+	writeJSON(w, http.StatusOK, h.response(updated))
+}
+
+// response is the worklet as the API returns it, with its preview URL
+func (h *WorkletHandler) response(worklet *Worklet) WorkletResponse {
+	resp := worklet.ToResponse()
+	resp.PreviewURL = h.manager.PreviewURL(worklet)
+	resp.PublishedArtifacts = h.manager.Artifacts(worklet)
+	if worklet.PreviewAccess == PreviewToken {
+		resp.PreviewToken = worklet.PreviewToken
+	}
+	return resp
+}
+
+// validateEnvironment rejects environment variables without a name
+func validateEnvironment(env map[string]string) error {
+	for name := range env {
+		if strings.TrimSpace(name) == "" || strings.ContainsAny(name, "= ") {
+			return fmt.Errorf("invalid environment variable name %q", name)
+		}
+	}
+	return nil
+}
+
+// gitNameRegex matches the branch and tag names a worklet can ask for
+var gitNameRegex = regexp.MustCompile(`^[\w./-]+$`)
+
+// validateCheckout rejects branch and ref names git wouldn't accept and
+// negative clone depths
+func validateCheckout(req CreateWorkletRequest) error {
+	for field, name := range map[string]string{"branch": req.Branch, "ref": req.Ref} {
+		if name == "" {
+			continue
+		}
+		if !gitNameRegex.MatchString(name) || strings.HasPrefix(name, "-") || strings.Contains(name, "..") {
+			return fmt.Errorf("invalid %s %q", field, name)
+		}
+	}
+	if req.CloneDepth != nil && *req.CloneDepth < 0 {
+		return fmt.Errorf("clone depth can't be negative")
+	}
+	return nil
+}
+
+func (h *WorkletHandler) DeleteWorklet(w http.ResponseWriter, r *http.Request) {
+	worklet, ok := h.ownedWorklet(w, r)
+	if !ok {
 		return
 	}
-	
-	if err := h.manager.DeleteWorklet(id); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to delete worklet: %v", err), http.StatusInternalServerError)
+
+	if err := h.manager.DeleteWorklet(worklet.ID); err != nil {
+		writeManagerError(w, "delete worklet", err)
 		return
 	}
-	
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// WorkletAction runs a lifecycle action on a worklet: start, stop, restart,
+// or rebuild. Builds continue in the background, so the response only says
+// the action began; the worklet's status shows when it's done.
+func (h *WorkletHandler) WorkletAction(w http.ResponseWriter, r *http.Request) {
+	h.runAction(w, r, pathValue(r, "action"))
+}
+
+// StartWorklet rebuilds the worklet, like the rebuild action
 func (h *WorkletHandler) StartWorklet(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	
-	if err := h.manager.RestartWorklet(r.Context(), id); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to start worklet: %v", err), http.StatusInternalServerError)
-		return
-	}
-	
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "starting"})
+	h.runAction(w, r, ActionStart)
 }
 
 func (h *WorkletHandler) StopWorklet(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	
-	if err := h.manager.StopWorklet(id); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to stop worklet: %v", err), http.StatusInternalServerError)
-		return
-	}
-	
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "stopped"})
+	h.runAction(w, r, ActionStop)
 }
 
+// RestartWorklet rebuilds the worklet, like the rebuild action. Use the
+// restart action to restart its container without rebuilding.
 func (h *WorkletHandler) RestartWorklet(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	
-	if err := h.manager.RestartWorklet(r.Context(), id); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to restart worklet: %v", err), http.StatusInternalServerError)
+	h.runAction(w, r, ActionRebuild)
+}
+
+// runAction runs a lifecycle action on the worklet in the request's path
+func (h *WorkletHandler) runAction(w http.ResponseWriter, r *http.Request, action string) {
+	worklet, ok := h.ownedWorklet(w, r)
+	if !ok {
 		return
 	}
-	
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "restarting"})
+
+	var err error
+	status := ""
+	switch action {
+	case ActionStart, ActionRebuild:
+		// The build continues in the background after the request returns
+		err = h.manager.RestartWorklet(context.WithoutCancel(r.Context()), worklet.ID)
+		status = "rebuilding"
+	case ActionStop:
+		err = h.manager.StopWorklet(worklet.ID)
+		status = "stopped"
+	case ActionRestart:
+		err = h.manager.RestartContainer(worklet.ID)
+		status = "restarted"
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Unknown action %q; actions are start, stop, restart, and rebuild", action))
+		return
+	}
+	if err != nil {
+		writeManagerError(w, action+" worklet", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"id": worklet.ID, "action": action, "status": status})
 }
 
 func (h *WorkletHandler) ProcessPrompt(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	
+	worklet, ok := h.ownedWorklet(w, r)
+	if !ok {
+		return
+	}
+
 	var req PromptRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
 		return
 	}
-	
+
 	if req.Prompt == "" {
-		http.Error(w, "Prompt is required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "Prompt is required")
 		return
 	}
-	
+
 	userID := h.getUserID(r)
-	
-	workletPrompt, err := h.manager.ProcessPrompt(r.Context(), id, req.Prompt, userID)
+
+	// The prompt is processed in the background after the request returns
+	workletPrompt, err := h.manager.ProcessPrompt(context.WithoutCancel(r.Context()), worklet.ID, req.Prompt, userID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to process prompt: %v", err), http.StatusInternalServerError)
+		writeManagerError(w, "process prompt", err)
 		return
 	}
-	
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(workletPrompt)
+
+	writeJSON(w, http.StatusOK, workletPrompt)
 }
 
 func (h *WorkletHandler) CreatePR(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	
+	worklet, ok := h.ownedWorklet(w, r)
+	if !ok {
+		return
+	}
+
 	var req struct {
 		Title       string `json:"title"`
 		Description string `json:"description"`
 		BranchName  string `json:"branch_name"`
+		Force       bool   `json:"force"` // Open it even though the worklet's tests failed
 	}
-	
+
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
 		return
 	}
-	
+
 	if req.Title == "" {
-		req.Title = fmt.Sprintf("Worklet changes from %s", id)
+		req.Title = fmt.Sprintf("Worklet changes from %s", worklet.ID)
 	}
-	
+
 	if req.BranchName == "" {
-		req.BranchName = fmt.Sprintf("worklet-%s-%d", id, time.Now().Unix())
-	}
-	
-	worklet, err := h.manager.GetWorklet(id)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Worklet not found: %v", err), http.StatusNotFound)
-		return
-	}
-	
-	userID := h.getUserID(r)
-	if worklet.UserID != userID {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
+		req.BranchName = fmt.Sprintf("worklet-%s-%d", worklet.ID, time.Now().Unix())
 	}
-	
-	repoPath := h.manager.gitClient.GetRepoPath(worklet.GitRepo, worklet.Branch)
-	
-	if err := h.manager.claudeClient.CreatePR(r.Context(), repoPath, req.BranchName, req.Title, req.Description); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create PR: %v", err), http.StatusInternalServerError)
+
+	if err := h.manager.CreatePR(r.Context(), worklet, req.BranchName, req.Title, req.Description, req.Force); err != nil {
+		writeManagerError(w, "create PR", err)
 		return
 	}
-	
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"status":      "created",
 		"branch_name": req.BranchName,
 		"title":       req.Title,
+		"pr_url":      worklet.PRURL,
+		"pr_number":   worklet.PRNumber,
 	})
 }
 
 func (h *WorkletHandler) ProxyToWorklet(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	
+	id := pathValue(r, "id")
+
 	worklet, err := h.manager.GetWorklet(id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Worklet not found: %v", err), http.StatusNotFound)
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Worklet not found: %v", err))
+		return
+	}
+
+	// The proxy serves the same app as the preview host, so it has the same gate
+	if !previewAllowed(w, r, worklet, proxyPath(r.URL.Path, id)) {
 		return
 	}
-	
-	if worklet.Status != StatusRunning {
-		http.Error(w, fmt.Sprintf("Worklet is not running, status: %s", worklet.Status), http.StatusServiceUnavailable)
+	if h.manager.currentStatus(worklet).Status == StatusSleeping && !h.awaken(w, r, worklet) {
 		return
 	}
-	
+	if status := h.manager.currentStatus(worklet).Status; status != StatusRunning {
+		writeError(w, http.StatusServiceUnavailable, fmt.Sprintf("Worklet is not running, status: %s", status))
+		return
+	}
+	if err := h.manager.ensureProxy(worklet); err != nil {
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("Failed to reach worklet: %v", err))
+		return
+	}
+
+	h.manager.touch(worklet)
 	h.manager.webServer.ServeWorklet(w, r, id)
 }
 
+// proxyPath returns the part of a proxy request's path that is the worklet's
+// proxy, such as /api/worklet/worklets/{id}/proxy
+func proxyPath(path, id string) string {
+	base := "/worklets/" + id + "/proxy"
+	if i := strings.Index(path, base); i >= 0 {
+		return path[:i+len(base)]
+	}
+	return "/"
+}
+
+// GetLogs returns a worklet's saved build logs and its most recent output.
+// ?kb=N keeps only the last N KB of output, and ?follow=true streams it as
+// server-sent events instead.
 func (h *WorkletHandler) GetLogs(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	
-	worklet, err := h.manager.GetWorklet(id)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Worklet not found: %v", err), http.StatusNotFound)
+	worklet, ok := h.ownedWorklet(w, r)
+	if !ok {
 		return
 	}
-	
-	userID := h.getUserID(r)
-	if worklet.UserID != userID {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+
+	maxBytes := 0
+	if kb := r.URL.Query().Get("kb"); kb != "" {
+		n, err := strconv.Atoi(kb)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "kb must be a positive number")
+			return
+		}
+		maxBytes = n * 1024
+	}
+
+	if follow, _ := strconv.ParseBool(r.URL.Query().Get("follow")); follow {
+		h.streamLogs(w, r, worklet, maxBytes)
 		return
 	}
-	
-	logs := map[string]string{
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"build_logs": worklet.BuildLogs,
 		"last_error": worklet.LastError,
+		"lines":      h.manager.LogTail(worklet, maxBytes, 0),
+	})
+}
+
+// streamLogs sends a worklet's retained output and then each new line as
+// server-sent events, until the client leaves or the worklet stops. Clients
+// that reconnect with Last-Event-ID pick up after the last line they saw.
+func (h *WorkletHandler) streamLogs(w http.ResponseWriter, r *http.Request, worklet *Worklet, maxBytes int) {
+	lines, stopFollowing := h.manager.FollowLogs(worklet)
+	defer stopFollowing()
+	statuses, unsubscribe := events.Channel(h.manager.events, events.TopicWorkletStatus)
+	defer unsubscribe()
+
+	var lastSeq int64
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		lastSeq, _ = strconv.ParseInt(id, 10, 64)
+	}
+
+	stream := newEventStream(w)
+	send := func(line LogLine) bool {
+		if line.Seq <= lastSeq {
+			return true
+		}
+		lastSeq = line.Seq
+		return stream.send("log", strconv.FormatInt(line.Seq, 10), line)
+	}
+
+	for _, line := range h.manager.LogTail(worklet, maxBytes, lastSeq) {
+		if !send(line) {
+			return
+		}
+	}
+	current := h.manager.currentStatus(worklet)
+	if !stream.send("status", "", current) || logsFinished(current.Status) {
+		return
+	}
+
+	keepalive := time.NewTicker(logKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case line := <-lines:
+			if !send(line) {
+				return
+			}
+		case status := <-statuses:
+			if status.WorkletID != worklet.ID {
+				continue
+			}
+			event := statusEvent{Status: Status(status.Status), Error: status.Error, WebURL: status.WebURL}
+			if !stream.send("status", "", event) || logsFinished(event.Status) {
+				return
+			}
+		case <-keepalive.C:
+			if !stream.keepalive() {
+				return
+			}
+		}
 	}
-	
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(logs)
 }
 
-func (h *WorkletHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	
-	worklet, err := h.manager.GetWorklet(id)
+// streamStatus sends a worklet's status and then each change to it, such as
+// failing its health check, as server-sent events until the client leaves
+func (h *WorkletHandler) streamStatus(w http.ResponseWriter, r *http.Request, worklet *Worklet) {
+	statuses, unsubscribe := events.Channel(h.manager.events, events.TopicWorkletStatus)
+	defer unsubscribe()
+
+	stream := newEventStream(w)
+	if !stream.send("status", "", h.manager.currentStatus(worklet)) {
+		return
+	}
+
+	keepalive := time.NewTicker(logKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case status := <-statuses:
+			if status.WorkletID != worklet.ID {
+				continue
+			}
+			if !stream.send("status", "", statusEvent{Status: Status(status.Status), Error: status.Error, WebURL: status.WebURL}) {
+				return
+			}
+		case <-keepalive.C:
+			if !stream.keepalive() {
+				return
+			}
+		}
+	}
+}
+
+// statusEvent is the data of a status event in a log or status stream
+type statusEvent struct {
+	Status Status `json:"status"`
+	Error  string `json:"error,omitempty"`
+	WebURL string `json:"web_url,omitempty"`
+}
+
+// currentStatus returns the worklet's status as a status event. It's read
+// under the manager's lock, since a deploy or wakeup may be changing it.
+func (m *Manager) currentStatus(worklet *Worklet) statusEvent {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return statusEvent{Status: worklet.Status, Error: worklet.LastError, WebURL: worklet.WebURL}
+}
+
+// eventStream writes server-sent events
+type eventStream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// newEventStream starts a server-sent event response
+func newEventStream(w http.ResponseWriter) *eventStream {
+	rc := http.NewResponseController(w)
+	// The server's write timeout would otherwise cut off a long stream
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	return &eventStream{w: w, rc: rc}
+}
+
+// send writes an event with data as JSON, reporting whether the client is
+// still there. id is left out when empty.
+func (s *eventStream) send(event, id string, data any) bool {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return false
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(s.w, "id: %s\n", id); err != nil {
+			return false
+		}
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return false
+	}
+	return s.rc.Flush() == nil
+}
+
+// keepalive writes a comment so proxies don't close an idle stream
+func (s *eventStream) keepalive() bool {
+	if _, err := fmt.Fprint(s.w, ": keepalive\n\n"); err != nil {
+		return false
+	}
+	return s.rc.Flush() == nil
+}
+
+// logsFinished reports whether a worklet in status will write no more output
+func logsFinished(status Status) bool {
+	return status == StatusStopped || status == StatusError
+}
+
+// GetStatus returns a worklet's status, or with ?follow=true streams it and
+// each change to it as server-sent events
+// GetDiff returns the changes made to a worklet's code since the commit it was
+// deployed from, with per-file line counts. With format=patch only the
+// unified diff is sent, as text.
+func (h *WorkletHandler) GetDiff(w http.ResponseWriter, r *http.Request) {
+	worklet, ok := h.ownedWorklet(w, r)
+	if !ok {
+		return
+	}
+
+	diff, err := h.manager.Diff(r.Context(), worklet)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Worklet not found: %v", err), http.StatusNotFound)
+		writeManagerError(w, "diff worklet", err)
 		return
 	}
-	
-	userID := h.getUserID(r)
-	if worklet.UserID != userID {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	writeDiff(w, r, diff)
+}
+
+// CloneWorklet starts a new worklet with the settings of one of the user's
+// worklets, such as to try a different prompt on the same repository
+func (h *WorkletHandler) CloneWorklet(w http.ResponseWriter, r *http.Request) {
+	worklet, ok := h.ownedWorklet(w, r)
+	if !ok {
+		return
+	}
+
+	var req CloneWorkletRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if err := validateEnvironment(req.Environment); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Deployment continues in the background after the request returns
+	clone, err := h.manager.CloneWorklet(context.WithoutCancel(r.Context()), worklet.ID, req, h.getUserID(r))
+	if err != nil {
+		writeManagerError(w, "clone worklet", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.response(clone))
+}
+
+// ListArtifacts lists the build artifacts the worklet's latest prompt
+// published, with links that download them
+func (h *WorkletHandler) ListArtifacts(w http.ResponseWriter, r *http.Request) {
+	worklet, ok := h.ownedWorklet(w, r)
+	if !ok {
+		return
+	}
+
+	artifacts := h.manager.Artifacts(worklet)
+	if artifacts == nil {
+		artifacts = []PublishedArtifact{}
+	}
+	writeJSON(w, http.StatusOK, artifacts)
+}
+
+// CreateTemplate saves a template anyone can start worklets from
+func (h *WorkletHandler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	var req CreateTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	template, err := h.manager.CreateTemplate(req, h.getUserID(r))
+	if err != nil {
+		writeManagerError(w, "create template", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, template)
+}
+
+func (h *WorkletHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := h.manager.ListTemplates()
+	if err != nil {
+		writeManagerError(w, "list templates", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, templates)
+}
+
+func (h *WorkletHandler) GetTemplate(w http.ResponseWriter, r *http.Request) {
+	template, err := h.manager.GetTemplate(pathValue(r, "name"))
+	if err != nil {
+		writeManagerError(w, "get template", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, template)
+}
+
+// DeleteTemplate deletes a template the user created
+func (h *WorkletHandler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	if err := h.manager.DeleteTemplate(pathValue(r, "name"), h.getUserID(r)); err != nil {
+		writeManagerError(w, "delete template", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateFromTemplate starts a worklet from a template, with a prompt that
+// follows the template's base prompt
+func (h *WorkletHandler) CreateFromTemplate(w http.ResponseWriter, r *http.Request) {
+	var req TemplateWorkletRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if err := validateEnvironment(req.Environment); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Deployment continues in the background after the request returns
+	worklet, err := h.manager.CreateFromTemplate(context.WithoutCancel(r.Context()), pathValue(r, "name"), req, h.getUserID(r))
+	if err != nil {
+		writeManagerError(w, "create worklet from template", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.response(worklet))
+}
+
+// operatorWorklet loads the worklet in the request's path for an operator,
+// an admin in the server's configuration, who may open a shell in any
+// worklet, and returns the operator. Operators are who signed in, never the
+// X-User-ID header. It sends an error and returns false for anyone else.
+func (h *WorkletHandler) operatorWorklet(w http.ResponseWriter, r *http.Request) (*Worklet, string, bool) {
+	var operatorID string
+	if h.signedIn != nil {
+		if userID, err := h.signedIn(r); err == nil {
+			operatorID = userID
+		}
+	}
+	if operatorID == "" {
+		writeError(w, http.StatusUnauthorized, "Sign in to use worklet shells")
+		return nil, "", false
+	}
+	if h.deps == nil || !h.deps.Config.IsAdmin(operatorID) {
+		writeError(w, http.StatusForbidden, "Only operators can use worklet shells")
+		return nil, "", false
+	}
+	worklet, err := h.manager.GetWorklet(pathValue(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Worklet not found: %v", err))
+		return nil, "", false
+	}
+	return worklet, operatorID, true
+}
+
+// ShellWorklet attaches an operator's WebSocket to an interactive shell in a
+// worklet's container. The session is recorded for the audit trail.
+func (h *WorkletHandler) ShellWorklet(w http.ResponseWriter, r *http.Request) {
+	worklet, operatorID, ok := h.operatorWorklet(w, r)
+	if !ok {
+		return
+	}
+
+	// The shell outlives the request's context once the connection is upgraded
+	shell, err := h.manager.OpenShell(context.WithoutCancel(r.Context()), worklet.ID, operatorID)
+	if err != nil {
+		writeManagerError(w, "open shell", err)
 		return
 	}
-	
-	status := map[string]interface{}{
+	conn, err := shellUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		shell.Close()
+		return
+	}
+	relayShell(conn, shell)
+}
+
+// ListShellSessions returns the shell sessions opened in a worklet, newest first
+func (h *WorkletHandler) ListShellSessions(w http.ResponseWriter, r *http.Request) {
+	worklet, _, ok := h.operatorWorklet(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.manager.ListShellSessions(worklet.ID)
+	if err != nil {
+		writeManagerError(w, "list shell sessions", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+// GetShellRecording sends a shell session's recording as an asciicast, which
+// asciinema plays back
+func (h *WorkletHandler) GetShellRecording(w http.ResponseWriter, r *http.Request) {
+	worklet, _, ok := h.operatorWorklet(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := h.manager.GetShellSession(worklet.ID, pathValue(r, "sessionID"))
+	if err != nil {
+		writeManagerError(w, "get shell session", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-asciicast")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "worklet-"+worklet.ID+"-shell-"+session.ID+".cast"))
+	io.WriteString(w, session.Recording)
+}
+
+// ListPrompts returns the prompts sent to a worklet, oldest first, with how
+// many files and lines each one changed
+func (h *WorkletHandler) ListPrompts(w http.ResponseWriter, r *http.Request) {
+	worklet, ok := h.ownedWorklet(w, r)
+	if !ok {
+		return
+	}
+
+	prompts, err := h.manager.ListPrompts(worklet.ID)
+	if err != nil {
+		writeManagerError(w, "list prompts", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, prompts)
+}
+
+// GetPromptDiff returns the changes one prompt made to a worklet's files
+func (h *WorkletHandler) GetPromptDiff(w http.ResponseWriter, r *http.Request) {
+	worklet, ok := h.ownedWorklet(w, r)
+	if !ok {
+		return
+	}
+
+	diff, err := h.manager.PromptDiff(r.Context(), worklet, pathValue(r, "promptID"))
+	if err != nil {
+		writeManagerError(w, "diff prompt", err)
+		return
+	}
+	writeDiff(w, r, diff)
+}
+
+// writeDiff sends a diff as JSON, or only its patch with format=patch
+func writeDiff(w http.ResponseWriter, r *http.Request, diff *Diff) {
+	if r.URL.Query().Get("format") == "patch" {
+		w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+		w.Write([]byte(diff.Patch))
+		return
+	}
+	writeJSON(w, http.StatusOK, diff)
+}
+
+func (h *WorkletHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	worklet, ok := h.ownedWorklet(w, r)
+	if !ok {
+		return
+	}
+	if follow, _ := strconv.ParseBool(r.URL.Query().Get("follow")); follow {
+		h.streamStatus(w, r, worklet)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"status":      worklet.Status,
 		"created_at":  worklet.CreatedAt,
 		"updated_at":  worklet.UpdatedAt,
 		"web_url":     worklet.WebURL,
 		"last_prompt": worklet.LastPrompt,
 		"last_error":  worklet.LastError,
+	})
+}
+
+// StreamEvents sends a worklet's status and then each of its lifecycle events
+// (created, building, deployed, prompt_applied, error, stopped) as
+// server-sent events named for their type, until the client leaves
+func (h *WorkletHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	worklet, ok := h.ownedWorklet(w, r)
+	if !ok {
+		return
+	}
+	lifecycle, unsubscribe := events.Channel(h.manager.events, events.TopicWorkletEvent)
+	defer unsubscribe()
+
+	stream := newEventStream(w)
+	if !stream.send("status", "", h.manager.currentStatus(worklet)) {
+		return
+	}
+
+	keepalive := time.NewTicker(logKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-lifecycle:
+			if event.WorkletID != worklet.ID {
+				continue
+			}
+			if !stream.send(string(event.Type), "", event) {
+				return
+			}
+		case <-keepalive.C:
+			if !stream.keepalive() {
+				return
+			}
+		}
 	}
-	
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(status)
 }
 
 func (h *WorkletHandler) getUserID(r *http.Request) string {
@@ -352,11 +1126,85 @@ func (h *WorkletHandler) parseIntParam(r *http.Request, param string, defaultVal
 	if value == "" {
 		return defaultValue
 	}
-	
+
 	intValue, err := strconv.Atoi(value)
 	if err != nil {
 		return defaultValue
 	}
-	
+
 	return intValue
-}
\ No newline at end of file
+}
+
+// CreateSchedule schedules a prompt to a worklet on a cron expression
+func (h *WorkletHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	worklet, ok := h.ownedWorklet(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	schedule, err := h.manager.CreateSchedule(worklet.ID, req, h.getUserID(r))
+	if err != nil {
+		writeManagerError(w, "create schedule", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, schedule)
+}
+
+// ListSchedules returns a worklet's schedules, soonest first
+func (h *WorkletHandler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	worklet, ok := h.ownedWorklet(w, r)
+	if !ok {
+		return
+	}
+
+	schedules, err := h.manager.ListSchedules(worklet.ID)
+	if err != nil {
+		writeManagerError(w, "list schedules", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, schedules)
+}
+
+// DeleteSchedule stops a worklet's schedule from running again
+func (h *WorkletHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	worklet, ok := h.ownedWorklet(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.manager.DeleteSchedule(worklet.ID, pathValue(r, "scheduleID")); err != nil {
+		writeManagerError(w, "delete schedule", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListScheduleRuns returns the history of a schedule's runs, newest first
+func (h *WorkletHandler) ListScheduleRuns(w http.ResponseWriter, r *http.Request) {
+	worklet, ok := h.ownedWorklet(w, r)
+	if !ok {
+		return
+	}
+
+	schedule, err := h.manager.GetSchedule(worklet.ID, pathValue(r, "scheduleID"))
+	if err != nil {
+		writeManagerError(w, "get schedule", err)
+		return
+	}
+	runs, err := h.manager.ListScheduleRuns(schedule.ID)
+	if err != nil {
+		writeManagerError(w, "list schedule runs", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
@@ -0,0 +1,271 @@
+package worklet
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/models"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newTestAPI serves the worklet API over an in-memory database with a few
+// worklets that were never deployed
+func newTestAPI(t *testing.T) (*mux.Router, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&Worklet{}, &WorkletPrompt{}, &WorkletTemplate{}))
+
+	for i, w := range []struct {
+		id, name, user string
+		status         Status
+	}{
+		{"w1", "Dashboard", "alice", StatusRunning},
+		{"w2", "Dashboard v2", "alice", StatusStopped},
+		{"w3", "Landing page", "alice", StatusRunning},
+		{"w4", "Bob's app", "bob", StatusRunning},
+	} {
+		require.NoError(t, db.Create(&Worklet{
+			Model:   models.Model{ID: w.id, CreatedAt: time.Date(2026, 1, 1+i, 0, 0, 0, 0, time.UTC)},
+			Name:    w.name,
+			Status:  w.status,
+			GitRepo: "https://github.com/acme/" + w.id,
+			Branch:  "main",
+			UserID:  w.user,
+		}).Error)
+	}
+
+	handler := &WorkletHandler{manager: &Manager{db: db, worklets: make(map[string]*Worklet), webServer: NewWebServer()}}
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router.PathPrefix("/api/worklet").Subrouter())
+	return router, db
+}
+
+// serve sends a request to the API as user
+func serve(router http.Handler, method, path, user, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("X-User-ID", user)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+// errorCode reads the code of an error response
+func errorCode(t *testing.T, rr *httptest.ResponseRecorder) string {
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp), rr.Body.String())
+	assert.NotEmpty(t, resp.Error.Message)
+	return resp.Error.Code
+}
+
+func TestListWorkletsFiltersAndPages(t *testing.T) {
+	router, _ := newTestAPI(t)
+
+	list := func(query string) ([]string, string) {
+		rr := serve(router, "GET", "/api/worklet/worklets"+query, "alice", "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var worklets []WorkletResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &worklets))
+		var ids []string
+		for _, w := range worklets {
+			ids = append(ids, w.ID)
+		}
+		return ids, rr.Header().Get("X-Total-Count")
+	}
+
+	ids, total := list("")
+	assert.Equal(t, []string{"w3", "w2", "w1"}, ids, "newest first, only the user's")
+	assert.Equal(t, "3", total)
+
+	ids, _ = list("?status=running")
+	assert.Equal(t, []string{"w3", "w1"}, ids)
+	ids, _ = list("?name=Dashboard")
+	assert.Equal(t, []string{"w2", "w1"}, ids)
+	ids, _ = list("?git_repo=https://github.com/acme/w3")
+	assert.Equal(t, []string{"w3"}, ids)
+
+	ids, total = list("?limit=1&offset=1")
+	assert.Equal(t, []string{"w2"}, ids)
+	assert.Equal(t, "3", total)
+
+	rr := serve(router, "GET", "/api/worklet/worklets?limit=0", "alice", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "invalid_request", errorCode(t, rr))
+}
+
+func TestGetWorkletErrors(t *testing.T) {
+	router, _ := newTestAPI(t)
+
+	rr := serve(router, "GET", "/api/worklet/worklets/w1", "alice", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = serve(router, "GET", "/api/worklet/worklets/missing", "alice", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "not_found", errorCode(t, rr))
+
+	rr = serve(router, "GET", "/api/worklet/worklets/w4", "alice", "")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Equal(t, "forbidden", errorCode(t, rr))
+}
+
+func TestUpdateWorklet(t *testing.T) {
+	router, db := newTestAPI(t)
+
+	rr := serve(router, "PATCH", "/api/worklet/worklets/w1", "alice",
+		`{"base_prompt":"Add a dark mode","environment":{"API_URL":"https://api.example.com"}}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp WorkletResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, "Dashboard", resp.Name, "fields not in the request are kept")
+	assert.Equal(t, "Add a dark mode", resp.BasePrompt)
+	assert.Equal(t, map[string]string{"API_URL": "https://api.example.com"}, resp.Environment)
+
+	var saved Worklet
+	require.NoError(t, db.First(&saved, "id = ?", "w1").Error)
+	assert.Equal(t, "Add a dark mode", saved.BasePrompt)
+
+	rr = serve(router, "PATCH", "/api/worklet/worklets/w1", "alice", `{"name":" "}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = serve(router, "PATCH", "/api/worklet/worklets/w1", "alice", `{"environment":{"BAD NAME":"x"}}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = serve(router, "PATCH", "/api/worklet/worklets/w4", "alice", `{"name":"Mine now"}`)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestDeleteWorklet(t *testing.T) {
+	router, db := newTestAPI(t)
+
+	rr := serve(router, "DELETE", "/api/worklet/worklets/w2", "alice", "")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	var count int64
+	require.NoError(t, db.Model(&Worklet{}).Where("id = ?", "w2").Count(&count).Error)
+	assert.Zero(t, count)
+
+	rr = serve(router, "DELETE", "/api/worklet/worklets/w2", "alice", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestWorkletActions(t *testing.T) {
+	router, _ := newTestAPI(t)
+
+	rr := serve(router, "POST", "/api/worklet/worklets/w1/actions/stop", "alice", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.JSONEq(t, `{"id":"w1","action":"stop","status":"stopped"}`, rr.Body.String())
+
+	rr = serve(router, "POST", "/api/worklet/worklets/w1/actions/restart", "alice", "")
+	assert.Equal(t, http.StatusConflict, rr.Code, "a worklet without a container can't be restarted")
+	assert.Equal(t, "conflict", errorCode(t, rr))
+
+	rr = serve(router, "POST", "/api/worklet/worklets/w1/actions/explode", "alice", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = serve(router, "POST", "/api/worklet/worklets/w4/actions/stop", "alice", "")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestProcessPromptNeedsRunningWorklet(t *testing.T) {
+	router, _ := newTestAPI(t)
+
+	rr := serve(router, "POST", "/api/worklet/worklets/w2/prompt", "alice", `{"prompt":"Add a footer"}`)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, "conflict", errorCode(t, rr))
+
+	rr = serve(router, "POST", "/api/worklet/worklets/w1/prompt", "alice", `{"prompt":""}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestErrorStatus(t *testing.T) {
+	assert.Equal(t, http.StatusNotFound, errorStatus(fmt.Errorf("worklet not found: %w", gorm.ErrRecordNotFound)))
+	assert.Equal(t, http.StatusConflict, errorStatus(fmt.Errorf("%w, current status: stopped", ErrNotRunning)))
+	assert.Equal(t, http.StatusInternalServerError, errorStatus(fmt.Errorf("disk full")))
+}
+
+func TestGetLogs(t *testing.T) {
+	router, db := newTestAPI(t)
+	require.NoError(t, db.Model(&Worklet{}).Where("id = ?", "w2").
+		Update("build_logs", "Step 1/2 : FROM node\nStep 2/2 : RUN npm ci\n").Error)
+
+	rr := serve(router, "GET", "/api/worklet/worklets/w2/logs?kb=1", "alice", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp struct {
+		Lines []LogLine `json:"lines"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, []string{"Step 1/2 : FROM node", "Step 2/2 : RUN npm ci"}, lineTexts(resp.Lines))
+
+	rr = serve(router, "GET", "/api/worklet/worklets/w2/logs?kb=0", "alice", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = serve(router, "GET", "/api/worklet/w4/logs", "alice", "")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestFollowLogsOfStoppedWorklet(t *testing.T) {
+	router, db := newTestAPI(t)
+	require.NoError(t, db.Model(&Worklet{}).Where("id = ?", "w2").
+		Update("build_logs", "Step 1/2 : FROM node\nStep 2/2 : RUN npm ci\n").Error)
+
+	rr := serve(router, "GET", "/api/worklet/w2/logs?follow=true", "alice", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/event-stream", rr.Header().Get("Content-Type"))
+	body := rr.Body.String()
+	assert.Contains(t, body, "id: 1\nevent: log\n")
+	assert.Contains(t, body, `"line":"Step 2/2 : RUN npm ci"`)
+	assert.True(t, strings.HasSuffix(body, "event: status\ndata: {\"status\":\"stopped\"}\n\n"),
+		"the stream ends with the status of a worklet that won't write more output: %s", body)
+
+	req := httptest.NewRequest("GET", "/api/worklet/w2/logs?follow=true", nil)
+	req.Header.Set("X-User-ID", "alice")
+	req.Header.Set("Last-Event-ID", "1")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.NotContains(t, rr.Body.String(), "id: 1\n", "lines the client saw aren't resent")
+	assert.Contains(t, rr.Body.String(), "id: 2\n")
+}
+
+func TestStreamEvents(t *testing.T) {
+	_, db := newTestAPI(t)
+	manager := &Manager{db: db, worklets: make(map[string]*Worklet), webServer: NewWebServer(), events: events.New()}
+	router := mux.NewRouter()
+	(&WorkletHandler{manager: manager}).RegisterRoutes(router.PathPrefix("/api/worklet").Subrouter())
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", server.URL+"/api/worklet/worklets/w1/events", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-User-ID", "alice")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		var event strings.Builder
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			if line == "\n" {
+				return event.String()
+			}
+			event.WriteString(line)
+		}
+	}
+
+	assert.Equal(t, "event: status\ndata: {\"status\":\"running\"}\n", readEvent())
+
+	// Events for other worklets aren't sent
+	w3, err := manager.GetWorklet("w3")
+	require.NoError(t, err)
+	manager.publishEvent(w3, events.WorkletEvent{Type: events.WorkletPromptApplied, PromptID: "p1"})
+	require.NoError(t, manager.StopWorklet("w1"))
+	assert.Equal(t, "event: stopped\ndata: {\"worklet_id\":\"w1\",\"user_id\":\"alice\",\"type\":\"stopped\"}\n", readEvent())
+}
@@ -0,0 +1,275 @@
+package worklet
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/breadchris/flow/metrics"
+)
+
+// Health check types
+const (
+	HealthCheckHTTP = "http" // GET the path; any status below 500 is healthy
+	HealthCheckTCP  = "tcp"  // Open a connection to the app's port
+	HealthCheckNone = "none" // Don't check the worklet
+)
+
+// HealthCheck is how a running worklet is probed. Zero fields take the
+// defaults below.
+type HealthCheck struct {
+	Type             string `json:"type,omitempty"`              // "http" (the default), "tcp", or "none"
+	Path             string `json:"path,omitempty"`              // Path an HTTP check requests
+	IntervalSeconds  int    `json:"interval_seconds,omitempty"`  // Time between checks
+	TimeoutSeconds   int    `json:"timeout_seconds,omitempty"`   // Time a check may take
+	FailureThreshold int    `json:"failure_threshold,omitempty"` // Failed checks in a row before the worklet is unhealthy
+	MaxRestarts      int    `json:"max_restarts,omitempty"`      // Restarts tried before giving up until it recovers
+}
+
+// Health check defaults
+const (
+	defaultHealthPath             = "/"
+	defaultHealthInterval         = 30 * time.Second
+	defaultHealthTimeout          = 5 * time.Second
+	defaultHealthFailureThreshold = 3
+	defaultHealthMaxRestarts      = 3
+)
+
+// withDefaults fills in the fields left unset
+func (c HealthCheck) withDefaults() HealthCheck {
+	if c.Type == "" {
+		c.Type = HealthCheckHTTP
+	}
+	if c.Path == "" {
+		c.Path = defaultHealthPath
+	}
+	if c.IntervalSeconds <= 0 {
+		c.IntervalSeconds = int(defaultHealthInterval.Seconds())
+	}
+	if c.TimeoutSeconds <= 0 {
+		c.TimeoutSeconds = int(defaultHealthTimeout.Seconds())
+	}
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = defaultHealthFailureThreshold
+	}
+	if c.MaxRestarts <= 0 {
+		c.MaxRestarts = defaultHealthMaxRestarts
+	}
+	return c
+}
+
+// Validate rejects unknown check types and out of range settings
+func (c HealthCheck) Validate() error {
+	switch c.Type {
+	case "", HealthCheckHTTP, HealthCheckTCP, HealthCheckNone:
+	default:
+		return fmt.Errorf("unknown health check type %q, expected http, tcp, or none", c.Type)
+	}
+	if c.Path != "" && !strings.HasPrefix(c.Path, "/") {
+		return fmt.Errorf("health check path must start with /")
+	}
+	if c.IntervalSeconds < 0 || c.TimeoutSeconds < 0 || c.FailureThreshold < 0 || c.MaxRestarts < 0 {
+		return fmt.Errorf("health check settings can't be negative")
+	}
+	return nil
+}
+
+// probeHealth runs one check against the app on port
+func probeHealth(ctx context.Context, check HealthCheck, port int) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(check.TimeoutSeconds)*time.Second)
+	defer cancel()
+	addr := fmt.Sprintf("localhost:%d", port)
+
+	if check.Type == HealthCheckTCP {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+		return conn.Close()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://"+addr+check.Path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create health check request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", check.Path, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%s returned %d", check.Path, resp.StatusCode)
+	}
+	return nil
+}
+
+// healthAction is what to do about a worklet after a check
+type healthAction int
+
+const (
+	healthNoChange  healthAction = iota
+	healthRecovered              // Mark it running again
+	healthRestart                // Mark it unhealthy and restart its container
+	healthGiveUp                 // Mark it unhealthy and stop restarting it
+)
+
+// healthTracker counts a worklet's failed checks in a row and the restarts
+// they've led to
+type healthTracker struct {
+	check     HealthCheck
+	failures  int
+	restarts  int
+	unhealthy bool
+}
+
+// observe records the result of a check and returns what to do about it
+func (t *healthTracker) observe(err error) healthAction {
+	if err == nil {
+		t.failures = 0
+		if !t.unhealthy {
+			return healthNoChange
+		}
+		t.unhealthy = false
+		t.restarts = 0
+		return healthRecovered
+	}
+
+	t.failures++
+	if t.failures < t.check.FailureThreshold {
+		return healthNoChange
+	}
+	t.failures = 0
+	if t.restarts < t.check.MaxRestarts {
+		t.restarts++
+		t.unhealthy = true
+		return healthRestart
+	}
+	// Giving up is only reported once
+	if t.unhealthy && t.restarts > t.check.MaxRestarts {
+		return healthNoChange
+	}
+	t.restarts++
+	t.unhealthy = true
+	return healthGiveUp
+}
+
+// healthCheck returns the worklet's health check with defaults filled in
+func (w *Worklet) healthCheck() HealthCheck {
+	if w.HealthCheck == nil {
+		return HealthCheck{}.withDefaults()
+	}
+	return w.HealthCheck.Data.withDefaults()
+}
+
+// watchHealth checks a running worklet on its interval until it's stopped,
+// marking it unhealthy and restarting its container when checks keep failing
+func (m *Manager) watchHealth(worklet *Worklet) {
+	check := worklet.healthCheck()
+	if check.Type == HealthCheckNone || worklet.Port == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.healthMu.Lock()
+	if m.healthChecks == nil {
+		m.healthChecks = make(map[string]context.CancelFunc)
+	}
+	if stop, ok := m.healthChecks[worklet.ID]; ok {
+		stop()
+	}
+	m.healthChecks[worklet.ID] = cancel
+	m.healthMu.Unlock()
+
+	go m.runHealthChecks(ctx, worklet.ID, check)
+}
+
+// healthChecked reports whether a worklet in status is checked: it's up, and
+// not asleep, stopped, or being built
+func healthChecked(status Status) bool {
+	return status == StatusRunning || status == StatusUnhealthy
+}
+
+// ResumeHealthChecks starts checking the worklets left running when the
+// server last stopped, whose containers outlive it but whose checks don't
+func (m *Manager) ResumeHealthChecks() {
+	var running []*Worklet
+	if err := m.db.Select("id").
+		Where("status IN ? AND container_id <> ''", []Status{StatusRunning, StatusUnhealthy}).
+		Find(&running).Error; err != nil {
+		slog.Error("Failed to find running worklets to health check", "error", err)
+		return
+	}
+	for _, row := range running {
+		worklet, err := m.GetWorklet(row.ID)
+		if err != nil {
+			continue
+		}
+		m.watchHealth(worklet)
+	}
+	slog.Info("Resumed worklet health checks", "worklets", len(running), "action", "worklet_health_resumed")
+}
+
+// stopHealthChecks stops checking a worklet
+func (m *Manager) stopHealthChecks(workletID string) {
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+	if stop, ok := m.healthChecks[workletID]; ok {
+		stop()
+		delete(m.healthChecks, workletID)
+	}
+}
+
+// runHealthChecks is the check loop watchHealth starts
+func (m *Manager) runHealthChecks(ctx context.Context, workletID string, check HealthCheck) {
+	tracker := &healthTracker{check: check}
+	ticker := time.NewTicker(time.Duration(check.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		worklet, err := m.GetWorklet(workletID)
+		if err != nil || !healthChecked(m.currentStatus(worklet).Status) {
+			return
+		}
+		probeErr := probeHealth(ctx, check, worklet.Port)
+		// A worklet put to sleep or stopped during the check isn't unhealthy
+		if ctx.Err() != nil || !healthChecked(m.currentStatus(worklet).Status) {
+			return
+		}
+
+		switch tracker.observe(probeErr) {
+		case healthRecovered:
+			slog.Info("Worklet is healthy again", "workletID", workletID, "action", "worklet_recovered")
+			m.updateWorkletStatus(worklet, StatusRunning, "")
+
+		case healthRestart:
+			slog.Warn("Worklet failed its health check, restarting",
+				"workletID", workletID,
+				"error", probeErr,
+				"restart", tracker.restarts,
+				"action", "worklet_unhealthy",
+			)
+			m.updateWorkletStatus(worklet, StatusUnhealthy, fmt.Sprintf("Health check failed: %v; restarting (attempt %d of %d)",
+				probeErr, tracker.restarts, check.MaxRestarts))
+			err := m.dockerClient.RestartContainer(worklet.ContainerID)
+			metrics.WorkletHealthRestartsTotal.WithLabelValues(metrics.Result(err)).Inc()
+			if err != nil {
+				slog.Error("Failed to restart unhealthy worklet", "error", err, "workletID", workletID)
+			}
+
+		case healthGiveUp:
+			slog.Error("Worklet is still unhealthy after restarts", "workletID", workletID, "error", probeErr, "action", "worklet_unhealthy")
+			m.updateWorkletStatus(worklet, StatusUnhealthy, fmt.Sprintf("Health check failed: %v; gave up after %d restarts",
+				probeErr, check.MaxRestarts))
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package worklet
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthTrackerRestartsThenGivesUp(t *testing.T) {
+	tracker := &healthTracker{check: HealthCheck{FailureThreshold: 2, MaxRestarts: 2}.withDefaults()}
+	failed := errors.New("connection refused")
+
+	var actions []healthAction
+	for i := 0; i < 8; i++ {
+		actions = append(actions, tracker.observe(failed))
+	}
+	assert.Equal(t, []healthAction{
+		healthNoChange, healthRestart,
+		healthNoChange, healthRestart,
+		healthNoChange, healthGiveUp,
+		healthNoChange, healthNoChange,
+	}, actions, "every second failure restarts, up to two restarts, and giving up is reported once")
+
+	assert.Equal(t, healthRecovered, tracker.observe(nil))
+	assert.Equal(t, healthNoChange, tracker.observe(nil))
+	tracker.observe(failed)
+	assert.Equal(t, healthRestart, tracker.observe(failed), "restarts are counted again after recovering")
+}
+
+func TestHealthTrackerFailuresMustBeInARow(t *testing.T) {
+	tracker := &healthTracker{check: HealthCheck{FailureThreshold: 2}.withDefaults()}
+	failed := errors.New("timeout")
+
+	assert.Equal(t, healthNoChange, tracker.observe(failed))
+	assert.Equal(t, healthNoChange, tracker.observe(nil))
+	assert.Equal(t, healthNoChange, tracker.observe(failed))
+	assert.Equal(t, healthRestart, tracker.observe(failed))
+}
+
+func serverPort(t *testing.T, server *httptest.Server) int {
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+	return port
+}
+
+func TestProbeHealth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/broken":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	port := serverPort(t, server)
+	ctx := context.Background()
+
+	assert.NoError(t, probeHealth(ctx, HealthCheck{}.withDefaults(), port))
+	assert.NoError(t, probeHealth(ctx, HealthCheck{Path: "/missing"}.withDefaults(), port), "only server errors are unhealthy")
+	assert.ErrorContains(t, probeHealth(ctx, HealthCheck{Path: "/broken"}.withDefaults(), port), "returned 502")
+	assert.NoError(t, probeHealth(ctx, HealthCheck{Type: HealthCheckTCP}.withDefaults(), port))
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	closedPort := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	assert.Error(t, probeHealth(ctx, HealthCheck{Type: HealthCheckTCP}.withDefaults(), closedPort))
+	assert.Error(t, probeHealth(ctx, HealthCheck{}.withDefaults(), closedPort))
+}
+
+func TestHealthCheckValidate(t *testing.T) {
+	assert.NoError(t, HealthCheck{}.Validate())
+	assert.NoError(t, HealthCheck{Type: "tcp", IntervalSeconds: 10}.Validate())
+	assert.Error(t, HealthCheck{Type: "grpc"}.Validate())
+	assert.Error(t, HealthCheck{Path: "healthz"}.Validate())
+	assert.Error(t, HealthCheck{MaxRestarts: -1}.Validate())
+}
+
+func TestUpdateWorkletHealthCheck(t *testing.T) {
+	router, _ := newTestAPI(t)
+
+	rr := serve(router, "PATCH", "/api/worklet/worklets/w2", "alice", `{"health_check":{"type":"tcp","interval_seconds":10}}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), `"health_check":{"type":"tcp","path":"/","interval_seconds":10,"timeout_seconds":5,"failure_threshold":3,"max_restarts":3}`)
+
+	rr = serve(router, "PATCH", "/api/worklet/worklets/w2", "alice", `{"health_check":{"type":"grpc"}}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestFollowStatus(t *testing.T) {
+	router, _ := newTestAPI(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/api/worklet/worklets/w1/status?follow=true", nil).WithContext(ctx)
+	req.Header.Set("X-User-ID", "alice")
+	rr := httptest.NewRecorder()
+	cancel()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, "text/event-stream", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "event: status\ndata: {\"status\":\"running\"}\n\n", rr.Body.String())
+}
+
+func TestHealthCheckSkipsWorkletPutToSleep(t *testing.T) {
+	_, db := newTestAPI(t)
+	manager, _ := newIdleTestManager(t, db)
+	probed := make(chan struct{})
+	release := make(chan struct{})
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(probed)
+		<-release
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer app.Close()
+	require.NoError(t, db.Model(&Worklet{}).Where("id = ?", "w1").Updates(map[string]any{"container_id": "container", "port": serverPort(t, app)}).Error)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		check := HealthCheck{IntervalSeconds: 1, FailureThreshold: 1}.withDefaults()
+		manager.runHealthChecks(context.Background(), "w1", check)
+	}()
+
+	// The worklet goes to sleep while it's being checked
+	<-probed
+	worklet, err := manager.GetWorklet("w1")
+	require.NoError(t, err)
+	require.NoError(t, manager.sleepWorklet(worklet))
+	close(release)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("health checks kept running after the worklet went to sleep")
+	}
+	var saved Worklet
+	require.NoError(t, db.First(&saved, "id = ?", "w1").Error)
+	assert.Equal(t, StatusSleeping, saved.Status, "a failed check doesn't mark a sleeping worklet unhealthy or restart it")
+}
+
+func TestResumeHealthChecks(t *testing.T) {
+	_, db := newTestAPI(t)
+	manager, _ := newIdleTestManager(t, db)
+	for id, port := range map[string]int{"w1": 20001, "w2": 20002, "w4": 20004} {
+		require.NoError(t, db.Model(&Worklet{}).Where("id = ?", id).Updates(map[string]any{"container_id": "container-" + id, "port": port}).Error)
+	}
+	require.NoError(t, db.Model(&Worklet{}).Where("id = ?", "w4").Update("status", StatusSleeping).Error)
+
+	manager.ResumeHealthChecks()
+	defer func() {
+		for _, id := range []string{"w1", "w2", "w3", "w4"} {
+			manager.stopHealthChecks(id)
+		}
+	}()
+
+	manager.healthMu.Lock()
+	defer manager.healthMu.Unlock()
+	var checked []string
+	for id := range manager.healthChecks {
+		checked = append(checked, id)
+	}
+	assert.Equal(t, []string{"w1"}, checked, "only running worklets with containers are checked, not stopped or sleeping ones")
+}
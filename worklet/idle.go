@@ -0,0 +1,251 @@
+package worklet
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/breadchris/flow/events"
+)
+
+// activityInterval is how often a worklet's latest request is saved; the
+// requests in between don't touch the database
+const activityInterval = time.Minute
+
+// idleCheckInterval is how often worklets are checked for being idle, at most
+const idleCheckInterval = time.Minute
+
+// wakeTimeout is how long a waking worklet's app has to start listening
+const wakeTimeout = time.Minute
+
+// wakeRequestWait is how long a request that isn't from a browser waits for
+// a sleeping worklet to wake before it's told to retry
+const wakeRequestWait = 30 * time.Second
+
+// wakeup is a sleeping worklet being woken; done is closed when it's awake
+// or failed to wake
+type wakeup struct {
+	done chan struct{}
+	err  error
+}
+
+// touch records a request to the worklet, so it isn't put to sleep
+func (m *Manager) touch(worklet *Worklet) {
+	now := time.Now()
+	if last, ok := m.activity.Load(worklet.ID); ok && now.Sub(last.(time.Time)) < activityInterval {
+		return
+	}
+	m.activity.Store(worklet.ID, now)
+	m.mu.Lock()
+	worklet.LastActiveAt = &now
+	m.mu.Unlock()
+	// Not an update of the worklet itself, so updated_at is left alone
+	if err := m.db.Model(&Worklet{}).Where("id = ?", worklet.ID).UpdateColumn("last_active_at", now).Error; err != nil {
+		slog.Error("Failed to record worklet activity", "error", err, "workletID", worklet.ID)
+	}
+}
+
+// SleepIdleWorklets stops the containers of running worklets that haven't
+// had a request in idleTimeout, until ctx is done. Their images, checkouts,
+// and ports are kept, and their next request wakes them.
+func (m *Manager) SleepIdleWorklets(ctx context.Context, idleTimeout time.Duration) {
+	if idleTimeout <= 0 {
+		return
+	}
+	ticker := time.NewTicker(min(idleTimeout, idleCheckInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.sleepIdle(time.Now().Add(-idleTimeout))
+		}
+	}
+}
+
+// sleepIdle puts to sleep the running worklets last active before cutoff. A
+// worklet that's never had a request was last active when it was deployed.
+func (m *Manager) sleepIdle(cutoff time.Time) {
+	var idle []*Worklet
+	if err := m.db.Select("id").
+		Where("status = ? AND container_id <> ''", StatusRunning).
+		Where("(last_active_at IS NULL AND updated_at < ?) OR last_active_at < ?", cutoff, cutoff).
+		Find(&idle).Error; err != nil {
+		slog.Error("Failed to find idle worklets", "error", err)
+		return
+	}
+
+	for _, row := range idle {
+		worklet, err := m.GetWorklet(row.ID)
+		if err != nil || m.currentStatus(worklet).Status != StatusRunning || worklet.TestStatus == TestsRunning {
+			continue
+		}
+		if last, ok := m.activity.Load(worklet.ID); ok && last.(time.Time).After(cutoff) {
+			continue
+		}
+		// A worklet working on a prompt isn't idle
+		lock := m.promptLock(worklet.ID)
+		if !lock.TryLock() {
+			continue
+		}
+		err = m.sleepWorklet(worklet)
+		lock.Unlock()
+		if err != nil {
+			slog.Error("Failed to put idle worklet to sleep", "error", err, "workletID", worklet.ID)
+		}
+	}
+}
+
+// sleepWorklet stops a worklet's container, keeping everything it needs to
+// start again
+func (m *Manager) sleepWorklet(worklet *Worklet) error {
+	stop := m.stopContainer
+	if stop == nil {
+		stop = m.dockerClient.StopContainer
+	}
+	if err := stop(worklet.ContainerID); err != nil {
+		return fmt.Errorf("failed to stop container: %w", err)
+	}
+	m.stopRuntimeLogs(worklet.ID)
+	m.stopHealthChecks(worklet.ID)
+	m.webServer.RemoveProxy(worklet.ID)
+	m.updateWorkletStatus(worklet, StatusSleeping, "")
+
+	slog.Info("Put idle worklet to sleep", "workletID", worklet.ID, "lastActive", worklet.LastActiveAt, "action", "worklet_slept")
+	return nil
+}
+
+// Wake starts waking a sleeping worklet, or joins the wakeup already under
+// way, returning it
+func (m *Manager) Wake(worklet *Worklet) *wakeup {
+	pending, loaded := m.wakeups.LoadOrStore(worklet.ID, &wakeup{done: make(chan struct{})})
+	w := pending.(*wakeup)
+	if !loaded {
+		go func() {
+			w.err = m.wakeWorklet(worklet.ID)
+			m.wakeups.Delete(worklet.ID)
+			close(w.done)
+		}()
+	}
+	return w
+}
+
+// wakeWorklet starts a sleeping worklet's container and waits for its app to
+// listen. It's marked running only then, so requests aren't sent to an app
+// that's still starting.
+func (m *Manager) wakeWorklet(workletID string) error {
+	worklet, err := m.GetWorklet(workletID)
+	if err != nil {
+		return err
+	}
+	if m.currentStatus(worklet).Status != StatusSleeping {
+		return nil
+	}
+	if m.ports != nil && worklet.Port != 0 && !m.ports.Holds(worklet.ID, worklet.Port) {
+		return &PortConflictError{Port: worklet.Port, Err: errPortReassigned}
+	}
+
+	started := time.Now()
+	start := m.startContainer
+	if start == nil {
+		start = m.dockerClient.RestartContainer
+	}
+	if err := start(worklet.ContainerID); err != nil {
+		m.updateWorkletStatus(worklet, StatusError, fmt.Sprintf("Failed to wake worklet: %v; rebuild it to start it again", err))
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+	if err := waitForPort(worklet.Port, wakeTimeout); err != nil {
+		m.updateWorkletStatus(worklet, StatusError, fmt.Sprintf("Worklet woke but its app didn't start: %v", err))
+		return err
+	}
+
+	m.touch(worklet)
+	m.updateWorkletStatus(worklet, StatusRunning, "")
+	m.publishEvent(worklet, events.WorkletEvent{Type: events.WorkletWoke, WebURL: worklet.WebURL})
+	m.captureRuntimeLogs(worklet)
+	m.watchHealth(worklet)
+
+	slog.Info("Woke sleeping worklet", "workletID", worklet.ID, "duration", time.Since(started), "action", "worklet_woke")
+	return nil
+}
+
+// waitForPort waits until something listens on the local port
+func waitForPort(port int, timeout time.Duration) error {
+	address := fmt.Sprintf("localhost:%d", port)
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", address, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("nothing listening on port %d after %s", port, timeout)
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+// awaken wakes the sleeping worklet a proxied request is for. Browsers are
+// sent a page that reloads until it's awake; other clients wait for it. It
+// returns whether the request can be proxied now, having answered it if not.
+func (h *WorkletHandler) awaken(w http.ResponseWriter, r *http.Request, worklet *Worklet) bool {
+	wakeup := h.manager.Wake(worklet)
+
+	select {
+	case <-wakeup.done:
+	default:
+		w.Header().Set("Retry-After", "2")
+		if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			wakingPage.Execute(w, worklet.Name)
+			return false
+		}
+		timer := time.NewTimer(wakeRequestWait)
+		defer timer.Stop()
+		select {
+		case <-wakeup.done:
+			w.Header().Del("Retry-After")
+		case <-r.Context().Done():
+			return false
+		case <-timer.C:
+			http.Error(w, "Worklet is waking up; try again shortly", http.StatusServiceUnavailable)
+			return false
+		}
+	}
+
+	if wakeup.err != nil {
+		http.Error(w, fmt.Sprintf("Failed to wake worklet: %v", wakeup.err), http.StatusBadGateway)
+		return false
+	}
+	return true
+}
+
+// wakingPage is shown to browsers while a sleeping worklet starts, and
+// reloads until the worklet's app answers instead
+var wakingPage = template.Must(template.New("waking").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="2">
+<title>Waking up {{.}}</title>
+<style>
+body { font-family: system-ui, sans-serif; display: flex; align-items: center; justify-content: center; height: 100vh; margin: 0; color: #333; }
+</style>
+</head>
+<body>
+<div>
+<h1>💤 Waking up {{.}}</h1>
+<p>This worklet went to sleep after being idle. It'll be back in a few seconds.</p>
+</div>
+</body>
+</html>
+`))
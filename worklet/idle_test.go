@@ -0,0 +1,139 @@
+package worklet
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/models"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newIdleTestManager returns a manager over the test API's worklets whose
+// containers are stopped and started by recording their IDs
+func newIdleTestManager(t *testing.T, db *gorm.DB) (*Manager, *[]string) {
+	var calls []string
+	return &Manager{
+		db:        db,
+		worklets:  make(map[string]*Worklet),
+		webServer: NewWebServer(),
+		events:    events.New(),
+		stopContainer: func(containerID string) error {
+			calls = append(calls, "stop "+containerID)
+			return nil
+		},
+		startContainer: func(containerID string) error {
+			calls = append(calls, "start "+containerID)
+			return nil
+		},
+	}, &calls
+}
+
+func TestTouchIsThrottled(t *testing.T) {
+	_, db := newTestAPI(t)
+	manager, _ := newIdleTestManager(t, db)
+	worklet, err := manager.GetWorklet("w1")
+	require.NoError(t, err)
+	updatedAt := worklet.UpdatedAt
+
+	manager.touch(worklet)
+	var saved Worklet
+	require.NoError(t, db.First(&saved, "id = ?", "w1").Error)
+	require.NotNil(t, saved.LastActiveAt)
+	first := *saved.LastActiveAt
+	assert.True(t, saved.UpdatedAt.Equal(updatedAt), "activity isn't an update of the worklet")
+
+	manager.touch(worklet)
+	require.NoError(t, db.First(&saved, "id = ?", "w1").Error)
+	assert.True(t, saved.LastActiveAt.Equal(first), "requests within a minute aren't saved")
+}
+
+func TestSleepIdle(t *testing.T) {
+	_, db := newTestAPI(t)
+	manager, calls := newIdleTestManager(t, db)
+	recent := time.Now()
+	require.NoError(t, db.Model(&Worklet{}).Where("id IN ?", []string{"w1", "w2", "w3"}).UpdateColumn("container_id", "container").Error)
+	require.NoError(t, db.Model(&Worklet{}).Where("id IN ?", []string{"w1", "w3"}).UpdateColumn("updated_at", recent.Add(-time.Hour)).Error)
+	require.NoError(t, db.Model(&Worklet{}).Where("id = ?", "w3").UpdateColumn("last_active_at", recent).Error)
+	statuses, unsubscribe := events.Channel(manager.events, events.TopicWorkletEvent)
+	defer unsubscribe()
+
+	manager.sleepIdle(recent.Add(-time.Minute))
+
+	assert.Equal(t, []string{"stop container"}, *calls, "only w1 is running and idle; w2 is stopped and w3 had a request")
+	var w1, w3 Worklet
+	require.NoError(t, db.First(&w1, "id = ?", "w1").Error)
+	require.NoError(t, db.First(&w3, "id = ?", "w3").Error)
+	assert.Equal(t, StatusSleeping, w1.Status)
+	assert.Equal(t, StatusRunning, w3.Status)
+	select {
+	case event := <-statuses:
+		assert.Equal(t, events.WorkletSlept, event.Type)
+		assert.Equal(t, "w1", event.WorkletID)
+	case <-time.After(time.Second):
+		t.Fatal("no slept event")
+	}
+
+	// Nor are worklets with a prompt running
+	*calls = nil
+	require.NoError(t, db.Model(&Worklet{}).Where("id = ?", "w3").UpdateColumn("last_active_at", nil).Error)
+	lock := manager.promptLock("w3")
+	lock.Lock()
+	manager.sleepIdle(time.Now())
+	lock.Unlock()
+	assert.Empty(t, *calls)
+}
+
+func TestRequestWakesSleepingWorklet(t *testing.T) {
+	_, db := newTestAPI(t)
+	manager, calls := newIdleTestManager(t, db)
+	router := mux.NewRouter()
+	(&WorkletHandler{manager: manager}).RegisterRoutes(router.PathPrefix("/api/worklet").Subrouter())
+
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello from the app"))
+	}))
+	defer app.Close()
+	port := app.Listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, db.Model(&Worklet{}).Where("id = ?", "w1").Updates(map[string]any{
+		"status":         StatusSleeping,
+		"container_id":   "container",
+		"port":           port,
+		"health_check":   models.MakeJSONField(HealthCheck{Type: HealthCheckNone}),
+		"preview_access": PreviewPublic,
+	}).Error)
+
+	// The container takes a moment to start
+	started := make(chan struct{})
+	manager.startContainer = func(containerID string) error {
+		<-started
+		*calls = append(*calls, "start "+containerID)
+		return nil
+	}
+
+	req := httptest.NewRequest("GET", "/api/worklet/worklets/w1/proxy", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Waking up Dashboard")
+	assert.Equal(t, "2", rr.Header().Get("Retry-After"))
+
+	// Other clients wait for it to wake
+	close(started)
+	rr = serve(router, "GET", "/api/worklet/worklets/w1/proxy", "alice", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "hello from the app", rr.Body.String())
+	assert.Equal(t, []string{"start container"}, *calls, "the container is started once")
+
+	var saved Worklet
+	require.NoError(t, db.First(&saved, "id = ?", "w1").Error)
+	assert.Equal(t, StatusRunning, saved.Status)
+	assert.NotNil(t, saved.LastActiveAt)
+}
@@ -0,0 +1,184 @@
+package worklet
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logRetentionBytes is how much of each worklet's most recent output is kept
+// in memory for clients that ask for its logs after the fact
+const logRetentionBytes = 256 * 1024
+
+// logFollowerBuffer is how many lines a slow follower may fall behind before
+// lines are dropped for it
+const logFollowerBuffer = 256
+
+// LogLine is one line of a worklet's build, deploy, or runtime output
+type LogLine struct {
+	Seq   int64     `json:"seq"` // Increases by one with each line of the worklet's output
+	Time  time.Time `json:"time"`
+	Stage string    `json:"stage"` // "build", "deploy", "runtime", or "test"
+	Line  string    `json:"line"`
+}
+
+// logBuffer is a ring buffer of a worklet's most recent output, up to
+// maxBytes, that also passes each new line to its followers
+type logBuffer struct {
+	mu        sync.Mutex
+	maxBytes  int
+	size      int
+	nextSeq   int64
+	lines     []LogLine
+	followers map[chan LogLine]struct{}
+}
+
+func newLogBuffer(maxBytes int) *logBuffer {
+	return &logBuffer{maxBytes: maxBytes, followers: make(map[chan LogLine]struct{})}
+}
+
+// append adds a line, dropping the oldest lines past maxBytes
+func (b *logBuffer) append(stage, text string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextSeq++
+	line := LogLine{Seq: b.nextSeq, Time: time.Now(), Stage: stage, Line: text}
+	b.lines = append(b.lines, line)
+	b.size += len(text)
+	drop := 0
+	for b.size > b.maxBytes && drop < len(b.lines)-1 {
+		b.size -= len(b.lines[drop].Line)
+		drop++
+	}
+	b.lines = b.lines[drop:]
+
+	for follower := range b.followers {
+		select {
+		case follower <- line:
+		default:
+		}
+	}
+}
+
+// tail returns the retained lines after afterSeq, keeping only the most
+// recent maxBytes of them when maxBytes is positive
+func (b *logBuffer) tail(maxBytes int, afterSeq int64) []LogLine {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start := len(b.lines)
+	size := 0
+	for start > 0 && b.lines[start-1].Seq > afterSeq {
+		size += len(b.lines[start-1].Line)
+		if maxBytes > 0 && size > maxBytes {
+			break
+		}
+		start--
+	}
+	return append([]LogLine(nil), b.lines[start:]...)
+}
+
+// empty reports whether the buffer has never had a line
+func (b *logBuffer) empty() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.nextSeq == 0
+}
+
+// follow returns a channel of each line appended until stop is called
+func (b *logBuffer) follow() (<-chan LogLine, func()) {
+	ch := make(chan LogLine, logFollowerBuffer)
+	b.mu.Lock()
+	b.followers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.followers, ch)
+			b.mu.Unlock()
+		})
+	}
+}
+
+// logBuffer returns a worklet's log buffer. A worklet the server hasn't seen
+// output from since it started gets its saved build logs.
+func (m *Manager) logBuffer(worklet *Worklet) *logBuffer {
+	m.logsMu.Lock()
+	if m.logs == nil {
+		m.logs = make(map[string]*logBuffer)
+	}
+	buffer, ok := m.logs[worklet.ID]
+	if !ok {
+		buffer = newLogBuffer(logRetentionBytes)
+		m.logs[worklet.ID] = buffer
+	}
+	m.logsMu.Unlock()
+
+	if !ok && worklet.BuildLogs != "" && buffer.empty() {
+		forEachLine(worklet.BuildLogs, func(line string) { buffer.append("build", line) })
+	}
+	return buffer
+}
+
+// LogTail returns a worklet's retained output after afterSeq, keeping the most
+// recent maxBytes of it when maxBytes is positive
+func (m *Manager) LogTail(worklet *Worklet, maxBytes int, afterSeq int64) []LogLine {
+	return m.logBuffer(worklet).tail(maxBytes, afterSeq)
+}
+
+// FollowLogs returns a channel of each line a worklet writes until stop is called
+func (m *Manager) FollowLogs(worklet *Worklet) (<-chan LogLine, func()) {
+	return m.logBuffer(worklet).follow()
+}
+
+// captureRuntimeLogs follows a running worklet's container output into its
+// log buffer until the container stops or the capture is replaced
+func (m *Manager) captureRuntimeLogs(worklet *Worklet) {
+	if m.dockerClient == nil || worklet.ContainerID == "" {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.logsMu.Lock()
+	if m.runtimeLogs == nil {
+		m.runtimeLogs = make(map[string]context.CancelFunc)
+	}
+	if stop, ok := m.runtimeLogs[worklet.ID]; ok {
+		stop()
+	}
+	m.runtimeLogs[worklet.ID] = cancel
+	m.logsMu.Unlock()
+
+	buffer := m.logBuffer(worklet)
+	containerID := worklet.ContainerID
+	go func() {
+		defer cancel()
+		err := m.dockerClient.FollowContainerLogs(ctx, containerID, time.Now(), func(line string) {
+			buffer.append("runtime", line)
+		})
+		if err != nil && ctx.Err() == nil && !strings.Contains(err.Error(), "context canceled") {
+			buffer.append("runtime", "Stopped following container output: "+err.Error())
+		}
+	}()
+}
+
+// stopRuntimeLogs stops following a worklet's container output
+func (m *Manager) stopRuntimeLogs(workletID string) {
+	m.logsMu.Lock()
+	defer m.logsMu.Unlock()
+	if stop, ok := m.runtimeLogs[workletID]; ok {
+		stop()
+		delete(m.runtimeLogs, workletID)
+	}
+}
+
+// dropLogs forgets a deleted worklet's output
+func (m *Manager) dropLogs(workletID string) {
+	m.stopRuntimeLogs(workletID)
+	m.logsMu.Lock()
+	delete(m.logs, workletID)
+	m.logsMu.Unlock()
+}
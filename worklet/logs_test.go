@@ -0,0 +1,70 @@
+package worklet
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lineTexts(lines []LogLine) []string {
+	var texts []string
+	for _, line := range lines {
+		texts = append(texts, line.Line)
+	}
+	return texts
+}
+
+func TestLogBufferKeepsMostRecentBytes(t *testing.T) {
+	buffer := newLogBuffer(10)
+	for _, text := range []string{"aaaa", "bbbb", "cccc", "dddd"} {
+		buffer.append("build", text)
+	}
+
+	lines := buffer.tail(0, 0)
+	assert.Equal(t, []string{"cccc", "dddd"}, lineTexts(lines), "oldest lines past 10 bytes are dropped")
+	assert.Equal(t, int64(3), lines[0].Seq)
+
+	assert.Equal(t, []string{"dddd"}, lineTexts(buffer.tail(5, 0)))
+	assert.Equal(t, []string{"dddd"}, lineTexts(buffer.tail(0, 3)))
+	assert.Empty(t, buffer.tail(0, 4))
+
+	buffer.append("build", strings.Repeat("x", 20))
+	assert.Len(t, buffer.tail(0, 0), 1, "a line longer than the buffer is still kept")
+}
+
+func TestLogBufferFollow(t *testing.T) {
+	buffer := newLogBuffer(1024)
+	lines, stop := buffer.follow()
+
+	buffer.append("runtime", "listening on :3000")
+	select {
+	case line := <-lines:
+		assert.Equal(t, "runtime", line.Stage)
+		assert.Equal(t, "listening on :3000", line.Line)
+	case <-time.After(time.Second):
+		t.Fatal("follower didn't get the line")
+	}
+
+	stop()
+	buffer.append("runtime", "after stop")
+	assert.Empty(t, lines)
+}
+
+func TestManagerSeedsLogsFromBuildLogs(t *testing.T) {
+	m := &Manager{}
+	worklet := &Worklet{BuildLogs: "Step 1/3\n\nStep 2/3\n"}
+	worklet.ID = "w1"
+
+	require.Equal(t, []string{"Step 1/3", "Step 2/3"}, lineTexts(m.LogTail(worklet, 0, 0)))
+
+	m.publishLog(worklet)("deploy", "Container started")
+	assert.Equal(t, []string{"Step 1/3", "Step 2/3", "Container started"}, lineTexts(m.LogTail(worklet, 0, 0)),
+		"saved build logs are only loaded once")
+
+	m.dropLogs("w1")
+	worklet.BuildLogs = ""
+	assert.Empty(t, m.LogTail(worklet, 0, 0))
+}
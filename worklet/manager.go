@@ -2,16 +2,39 @@ package worklet
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/breadchris/flow/deps"
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/metrics"
 	"github.com/breadchris/flow/models"
+	"github.com/breadchris/flow/shutdown"
+	"github.com/breadchris/flow/storage"
 	"gorm.io/gorm"
 )
 
+// Errors for worklets that can't take an action in their current state
+var (
+	ErrNotRunning  = errors.New("worklet is not running")
+	ErrNoContainer = errors.New("worklet has no container")
+)
+
+// maxListLimit is the most worklets one list returns
+const maxListLimit = 200
+
+// WorkletFilter narrows and pages a list of worklets; empty fields match everything
+type WorkletFilter struct {
+	Status  Status
+	Name    string // Matches names containing it
+	GitRepo string
+	Limit   int // Defaults to 50, at most maxListLimit
+	Offset  int
+}
+
 type Manager struct {
 	db           *gorm.DB
 	deps         *deps.Deps
@@ -21,32 +44,133 @@ type Manager struct {
 	gitClient    *GitClient
 	webServer    *WebServer
 	claudeClient *ClaudeClient
+	drainer      *shutdown.Drainer
+	events       *events.Bus
+
+	logsMu      sync.Mutex
+	logs        map[string]*logBuffer
+	runtimeLogs map[string]context.CancelFunc
+
+	ports        *PortAllocator // nil publishes containers on any free port
+	artifacts    *storage.Client // nil when no artifact storage is configured
+	
+	healthMu     sync.Mutex
+	healthChecks map[string]context.CancelFunc
+
+	promptLocks sync.Map // Worklet ID to the *sync.Mutex held while a prompt runs
+	deployLocks sync.Map // Worklet ID to the *sync.Mutex held while its deploy is replaced
+	deploys     sync.Map // Worklet ID to the *deployment running for it
+
+	webhookMu sync.Mutex // Held while a webhook finds or creates its worklet
+
+	activity sync.Map // Worklet ID to when its latest request was recorded
+	wakeups  sync.Map // Worklet ID to the *wakeup of a sleeping worklet being woken
+
+	// fetchPullRequest looks up a pull request's state; nil asks GitHub with gh
+	fetchPullRequest func(ctx context.Context, url string) (PullRequest, error)
+	// execInContainer runs a command in a container; nil uses Docker
+	execInContainer func(ctx context.Context, containerID string, cmd []string) (string, int, error)
+	// stopContainer and startContainer put a worklet's container to sleep and
+	// wake it; nil uses Docker
+	stopContainer  func(containerID string) error
+	startContainer func(containerID string) error
+	// openShell starts a shell in a container; nil uses Docker
+	openShell func(ctx context.Context, containerID string, cmd []string) (ShellProcess, error)
+	// cacheUsage and clearCache measure and empty dependency cache volumes; nil uses Docker
+	cacheUsage func(ctx context.Context) (map[string]int64, error)
+	clearCache func(ctx context.Context, name string) error
+	// deploy builds and runs a worklet; nil clones, builds, and runs it with deployWorklet
+	deploy func(ctx context.Context, worklet *Worklet)
+	// readArtifacts reads the files matching patterns from a container; nil uses Docker
+	readArtifacts func(ctx context.Context, containerID string, patterns []string, maxSize int64) ([]ArtifactFile, error)
+	// applyPrompt has Claude apply a prompt and rebuilds the worklet; nil uses processPromptAsync
+	applyPrompt func(ctx context.Context, worklet *Worklet, workletPrompt *WorkletPrompt)
+	// openPR pushes a worklet's changes and opens a pull request; nil uses CreatePR
+	openPR func(ctx context.Context, worklet *Worklet, branchName, title, description string) error
 }
 
 func NewManager(deps *deps.Deps) *Manager {
+	migrateTemplates(deps.DB)
+	migrateShellSessions(deps.DB)
+	migrateSchedules(deps.DB)
+	dockerClient := NewDockerClient()
+	if dockerClient != nil {
+		dockerClient.caches = deps.Config.Worklet.CacheEnabled
+	}
 	return &Manager{
 		db:           deps.DB,
 		deps:         deps,
 		worklets:     make(map[string]*Worklet),
-		dockerClient: NewDockerClient(),
+		dockerClient: dockerClient,
 		gitClient:    NewGitClient(),
 		webServer:    NewWebServer(),
 		claudeClient: NewClaudeClient(),
+		drainer:      deps.Drainer,
+		events:       deps.Events,
+		ports:        NewPortAllocator(deps.DB, deps.Config.Worklet.PortRangeStart, deps.Config.Worklet.PortRangeEnd),
+		artifacts:    storage.New(deps.Config.Worklet.Artifacts),
 	}
 }
 
+// PingDocker checks that the Docker daemon used for worklet builds is reachable
+func (m *Manager) PingDocker(ctx context.Context) error {
+	return m.dockerClient.Ping(ctx)
+}
+
 func (m *Manager) CreateWorklet(ctx context.Context, req CreateWorkletRequest, userID string) (*Worklet, error) {
+	return m.startWorklet(ctx, m.newWorklet(req, userID))
+}
+
+// newWorklet returns a worklet for req with the server's defaults for what it leaves out
+func (m *Manager) newWorklet(req CreateWorkletRequest, userID string) *Worklet {
 	worklet := NewWorklet(req, userID)
+	if worklet.PreviewAccess == "" {
+		PreviewSettings{Access: m.defaultPreviewAccess()}.apply(worklet)
+	}
+	if req.CloneDepth == nil && m.deps != nil {
+		worklet.CloneDepth = m.deps.Config.Worklet.CloneDepth
+	}
+	return worklet
+}
+
+// CloneWorklet creates a worklet for userID with another's settings: its
+// repository and revision, base prompt, environment, build, health check,
+// preview access, tool permissions, tests, and artifacts. What prompts
+// changed in the original isn't copied.
+func (m *Manager) CloneWorklet(ctx context.Context, workletID string, req CloneWorkletRequest, userID string) (*Worklet, error) {
+	source, err := m.GetWorklet(workletID)
+	if err != nil {
+		return nil, err
+	}
+	clone := newClone(source, req, userID)
+	if clone.PreviewAccess == "" {
+		PreviewSettings{Access: m.defaultPreviewAccess()}.apply(clone)
+	}
+	if _, err := m.startWorklet(ctx, clone); err != nil {
+		return nil, err
+	}
+	slog.Info("Cloned worklet", "workletID", clone.ID, "sourceID", source.ID, "userID", userID, "action", "worklet_cloned")
+	return clone, nil
+}
+
+// startWorklet saves a new worklet and deploys it in the background
+func (m *Manager) startWorklet(ctx context.Context, worklet *Worklet) (*Worklet, error) {
+	done, err := m.trackBuild(worklet.ID)
+	if err != nil {
+		return nil, err
+	}
 	
 	if err := m.db.Create(worklet).Error; err != nil {
+		done()
 		return nil, fmt.Errorf("failed to create worklet in database: %w", err)
 	}
 	
 	m.mu.Lock()
 	m.worklets[worklet.ID] = worklet
 	m.mu.Unlock()
+	m.publishEvent(worklet, events.WorkletEvent{Type: events.WorkletCreated})
 	
-	go m.deployWorklet(ctx, worklet)
+	m.startDeploy(ctx, worklet, done)
 	
 	return worklet, nil
 }
@@ -81,16 +205,122 @@ func (m *Manager) ListWorklets(userID string) ([]*Worklet, error) {
 	return worklets, nil
 }
 
-func (m *Manager) ProcessPrompt(ctx context.Context, workletID string, prompt string, userID string) (*WorkletPrompt, error) {
+// QueryWorklets returns a page of a user's worklets matching filter, newest
+// first, and how many match in all
+func (m *Manager) QueryWorklets(userID string, filter WorkletFilter) ([]*Worklet, int64, error) {
+	query := m.db.Model(&Worklet{}).Where("user_id = ?", userID)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Name != "" {
+		query = query.Where("name LIKE ?", "%"+filter.Name+"%")
+	}
+	if filter.GitRepo != "" {
+		query = query.Where("git_repo = ?", filter.GitRepo)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count worklets: %w", err)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	var worklets []*Worklet
+	if err := query.Order("created_at DESC").Limit(min(limit, maxListLimit)).Offset(filter.Offset).Find(&worklets).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list worklets: %w", err)
+	}
+	return worklets, total, nil
+}
+
+// UpdateWorklet changes the fields of a worklet set in req. A new base prompt
+// or environment is used the next time the worklet is rebuilt.
+func (m *Manager) UpdateWorklet(workletID string, req UpdateWorkletRequest) (*Worklet, error) {
 	worklet, err := m.GetWorklet(workletID)
 	if err != nil {
 		return nil, err
 	}
+
+	if req.Name != nil {
+		worklet.Name = *req.Name
+	}
+	if req.Description != nil {
+		worklet.Description = *req.Description
+	}
+	if req.BasePrompt != nil {
+		worklet.BasePrompt = *req.BasePrompt
+	}
+	if req.Environment != nil {
+		worklet.Environment = models.MakeJSONField(*req.Environment)
+	}
+	if req.HealthCheck != nil {
+		worklet.HealthCheck = models.MakeJSONField(*req.HealthCheck)
+	}
+	if req.Preview != nil {
+		req.Preview.apply(worklet)
+	}
+	if req.RunTests != nil {
+		worklet.RunTests = *req.RunTests
+	}
+	if req.TestCommand != nil {
+		worklet.TestCommand = *req.TestCommand
+	}
+	if req.Artifacts != nil {
+		worklet.Artifacts = models.MakeJSONField(*req.Artifacts)
+	}
+	worklet.UpdatedAt = time.Now()
+
+	if err := m.db.Save(worklet).Error; err != nil {
+		return nil, fmt.Errorf("failed to update worklet: %w", err)
+	}
+	// A new health check applies to a worklet that's already up right away
+	if req.HealthCheck != nil && (worklet.Status == StatusRunning || worklet.Status == StatusUnhealthy) {
+		m.stopHealthChecks(worklet.ID)
+		m.watchHealth(worklet)
+	}
+	return worklet, nil
+}
+
+func (m *Manager) ProcessPrompt(ctx context.Context, workletID string, prompt string, userID string) (*WorkletPrompt, error) {
+	worklet, workletPrompt, done, err := m.submitPrompt(ctx, workletID, prompt, userID)
+	if err != nil {
+		return nil, err
+	}
+	
+	go func() {
+		defer done()
+		m.runPrompt(ctx, worklet, workletPrompt)
+	}()
 	
-	if worklet.Status != StatusRunning {
-		return nil, fmt.Errorf("worklet is not running, current status: %s", worklet.Status)
+	return workletPrompt, nil
+}
+
+// submitPrompt records a prompt to a running worklet, waking it if it's
+// asleep, for the caller to run. done must be called once it has run.
+func (m *Manager) submitPrompt(ctx context.Context, workletID string, prompt string, userID string) (*Worklet, *WorkletPrompt, func(), error) {
+	worklet, err := m.GetWorklet(workletID)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 	
+	if m.currentStatus(worklet).Status == StatusSleeping {
+		wakeup := m.Wake(worklet)
+		select {
+		case <-wakeup.done:
+		case <-ctx.Done():
+			return nil, nil, nil, ctx.Err()
+		}
+		if wakeup.err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to wake worklet: %w", wakeup.err)
+		}
+	}
+	if status := m.currentStatus(worklet).Status; status != StatusRunning {
+		return nil, nil, nil, fmt.Errorf("%w, current status: %s", ErrNotRunning, status)
+	}
+	m.touch(worklet)
+	
 	workletPrompt := &WorkletPrompt{
 		Model:     models.Model{ID: generateID()},
 		WorkletID: workletID,
@@ -99,13 +329,21 @@ func (m *Manager) ProcessPrompt(ctx context.Context, workletID string, prompt st
 		UserID:    userID,
 	}
 	
-	if err := m.db.Create(workletPrompt).Error; err != nil {
-		return nil, fmt.Errorf("failed to create worklet prompt: %w", err)
+	done, err := m.trackWork("worklet_prompt", workletPrompt.ID, func() {
+		m.markInterrupted(&WorkletPrompt{}, workletPrompt.ID, map[string]any{
+			"status":   "error",
+			"response": "Interrupted by server restart. Please send the prompt again.",
+		})
+	})
+	if err != nil {
+		return nil, nil, nil, err
 	}
 	
-	go m.processPromptAsync(ctx, worklet, workletPrompt)
-	
-	return workletPrompt, nil
+	if err := m.db.Create(workletPrompt).Error; err != nil {
+		done()
+		return nil, nil, nil, fmt.Errorf("failed to create worklet prompt: %w", err)
+	}
+	return worklet, workletPrompt, done, nil
 }
 
 func (m *Manager) StopWorklet(workletID string) error {
@@ -114,6 +352,12 @@ func (m *Manager) StopWorklet(workletID string) error {
 		return err
 	}
 	
+	m.stopRuntimeLogs(workletID)
+	m.stopHealthChecks(workletID)
+	m.webServer.RemoveProxy(workletID)
+	if m.claudeClient != nil {
+		m.claudeClient.CloseSession(worklet.SessionID)
+	}
 	if worklet.ContainerID != "" {
 		if err := m.dockerClient.StopContainer(worklet.ContainerID); err != nil {
 			slog.Error("Failed to stop container", "error", err, "containerID", worklet.ContainerID)
@@ -126,32 +370,71 @@ func (m *Manager) StopWorklet(workletID string) error {
 	if err := m.db.Save(worklet).Error; err != nil {
 		return fmt.Errorf("failed to update worklet status: %w", err)
 	}
+	m.publishStatus(worklet)
 	
 	return nil
 }
 
 func (m *Manager) RestartWorklet(ctx context.Context, workletID string) error {
+	// A deploy still running, such as when pushes come in quick succession,
+	// is cancelled so the two don't overlap
+	lock := m.deployLock(workletID)
+	lock.Lock()
+	defer lock.Unlock()
+	m.cancelDeploy(workletID)
+	
 	worklet, err := m.GetWorklet(workletID)
 	if err != nil {
 		return err
 	}
 	
+	done, err := m.trackBuild(workletID)
+	if err != nil {
+		return err
+	}
+	
 	if err := m.StopWorklet(workletID); err != nil {
 		slog.Error("Failed to stop worklet before restart", "error", err)
 	}
 	
+	m.mu.Lock()
 	worklet.Status = StatusCreating
 	worklet.UpdatedAt = time.Now()
+	updated := *worklet
+	m.mu.Unlock()
 	
-	if err := m.db.Save(worklet).Error; err != nil {
+	if err := m.db.Save(&updated).Error; err != nil {
+		done()
 		return fmt.Errorf("failed to update worklet status: %w", err)
 	}
 	
-	go m.deployWorklet(ctx, worklet)
+	m.startDeploy(ctx, worklet, done)
 	
 	return nil
 }
 
+// RestartContainer restarts a worklet's container without rebuilding it
+func (m *Manager) RestartContainer(workletID string) error {
+	worklet, err := m.GetWorklet(workletID)
+	if err != nil {
+		return err
+	}
+	if worklet.ContainerID == "" {
+		return fmt.Errorf("%w to restart; rebuild it instead", ErrNoContainer)
+	}
+	if m.ports != nil && worklet.Port != 0 && !m.ports.Holds(worklet.ID, worklet.Port) {
+		return &PortConflictError{Port: worklet.Port, Err: errPortReassigned}
+	}
+
+	if err := m.dockerClient.RestartContainer(worklet.ContainerID); err != nil {
+		return fmt.Errorf("failed to restart container: %w", err)
+	}
+	m.updateWorkletStatus(worklet, StatusRunning, "")
+	m.captureRuntimeLogs(worklet)
+	m.watchHealth(worklet)
+	return nil
+}
+
 func (m *Manager) DeleteWorklet(workletID string) error {
 	worklet, err := m.GetWorklet(workletID)
 	if err != nil {
@@ -175,10 +458,71 @@ func (m *Manager) DeleteWorklet(workletID string) error {
 	m.mu.Lock()
 	delete(m.worklets, workletID)
 	m.mu.Unlock()
+	m.dropLogs(workletID)
+	m.promptLocks.Delete(workletID)
+	m.deployLocks.Delete(workletID)
+	if m.ports != nil {
+		if err := m.ports.Release(workletID); err != nil {
+			slog.Error("Failed to release worklet port", "error", err, "workletID", workletID)
+		}
+	}
 	
 	return nil
 }
 
+// deployment is a deploy running for a worklet
+type deployment struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// deployLock returns the lock held while a worklet's deploy is replaced
+func (m *Manager) deployLock(workletID string) *sync.Mutex {
+	lock, _ := m.deployLocks.LoadOrStore(workletID, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// startDeploy deploys the worklet in the background, calling done when it
+// finishes. The deploy changes its own copy of the worklet, which replaces the
+// cached one when its status changes.
+func (m *Manager) startDeploy(ctx context.Context, worklet *Worklet, done func()) {
+	m.mu.RLock()
+	deploying := *worklet
+	m.mu.RUnlock()
+	
+	ctx, cancel := context.WithCancel(ctx)
+	d := &deployment{cancel: cancel, done: make(chan struct{})}
+	m.deploys.Store(worklet.ID, d)
+	go func() {
+		defer done()
+		defer close(d.done)
+		defer m.deploys.CompareAndDelete(worklet.ID, d)
+		defer cancel()
+		m.runDeploy(ctx, &deploying)
+	}()
+}
+
+// cancelDeploy cancels the deploy running for a worklet, if there is one, and
+// waits for it to stop
+func (m *Manager) cancelDeploy(workletID string) {
+	value, ok := m.deploys.Load(workletID)
+	if !ok {
+		return
+	}
+	d := value.(*deployment)
+	d.cancel()
+	<-d.done
+}
+
+// runDeploy deploys the worklet with the deploy hook, or deployWorklet without one
+func (m *Manager) runDeploy(ctx context.Context, worklet *Worklet) {
+	if m.deploy != nil {
+		m.deploy(ctx, worklet)
+		return
+	}
+	m.deployWorklet(ctx, worklet)
+}
+
 func (m *Manager) deployWorklet(ctx context.Context, worklet *Worklet) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -189,33 +533,141 @@ func (m *Manager) deployWorklet(ctx context.Context, worklet *Worklet) {
 	
 	m.updateWorkletStatus(worklet, StatusBuilding, "")
 	
-	repoPath, err := m.gitClient.CloneRepository(worklet.GitRepo, worklet.Branch)
+	if worklet.Branch == "" {
+		branch, err := m.gitClient.DefaultBranch(worklet.GitRepo)
+		if err != nil {
+			m.updateWorkletStatus(worklet, StatusError, fmt.Sprintf("Failed to find the repository's default branch: %v", err))
+			return
+		}
+		worklet.Branch = branch
+	}
+	
+	repoPath, commitSHA, err := m.gitClient.Checkout(worklet.checkoutSpec())
 	if err != nil {
 		m.updateWorkletStatus(worklet, StatusError, fmt.Sprintf("Failed to clone repository: %v", err))
 		return
 	}
+	worklet.CommitSHA = commitSHA
+	slog.Info("Checked out worklet repository",
+		"workletID", worklet.ID,
+		"branch", worklet.Branch,
+		"ref", worklet.Ref,
+		"commit", commitSHA,
+		"action", "worklet_checkout",
+	)
+	
+	if !m.buildAndRun(ctx, worklet, repoPath) {
+		return
+	}
+	
+	if worklet.BasePrompt != "" {
+		// The base prompt starts the conversation the worklet's later prompts continue
+		if _, err := m.claudeClient.ProcessPrompt(ctx, worklet.SessionID, repoPath, worklet.BasePrompt, worklet.SessionOptions()...); err != nil {
+			slog.Error("Failed to apply base prompt", "error", err, "workletID", worklet.ID)
+		}
+	}
 	
+	m.updateWorkletStatus(worklet, StatusRunning, "")
+	m.publishEvent(worklet, events.WorkletEvent{Type: events.WorkletDeployed, WebURL: worklet.WebURL})
+	m.captureRuntimeLogs(worklet)
+	m.watchHealth(worklet)
+	
+	slog.Info("Worklet deployed successfully", "workletID", worklet.ID, "url", worklet.WebURL)
+	
+	if worklet.RunTests {
+		m.runTests(ctx, worklet, repoPath)
+	}
+}
+
+// buildAndRun builds the worklet's image from repoPath and replaces its
+// container with one running it, marking the worklet failed if it can't
+func (m *Manager) buildAndRun(ctx context.Context, worklet *Worklet, repoPath string) bool {
 	m.updateWorkletStatus(worklet, StatusDeploying, "")
 	
-	containerID, port, err := m.dockerClient.BuildAndRun(ctx, repoPath, worklet)
+	buildStart := time.Now()
+	containerID, port, err := m.runOnPort(ctx, worklet, repoPath)
+	metrics.WorkletBuildDuration.WithLabelValues(metrics.Result(err)).Observe(time.Since(buildStart).Seconds())
 	if err != nil {
 		m.updateWorkletStatus(worklet, StatusError, fmt.Sprintf("Failed to build and run container: %v", err))
-		return
+		return false
 	}
 	
 	worklet.ContainerID = containerID
 	worklet.Port = port
 	worklet.WebURL = fmt.Sprintf("http://localhost:%d", port)
+	if err := m.ensureProxy(worklet); err != nil {
+		slog.Error("Failed to create worklet proxy", "error", err, "workletID", worklet.ID)
+	}
+	return true
+}
+
+// runOnPort builds the worklet and runs it on its allocated port. If
+// something else took the port since it was allocated, the worklet gets
+// another one.
+func (m *Manager) runOnPort(ctx context.Context, worklet *Worklet, repoPath string) (string, int, error) {
+	logLine := m.publishLog(worklet)
+	if m.ports == nil {
+		return m.dockerClient.BuildAndRun(ctx, repoPath, worklet, 0, logLine)
+	}
 	
-	if worklet.BasePrompt != "" {
-		if err := m.claudeClient.ApplyPrompt(ctx, repoPath, worklet.BasePrompt); err != nil {
-			slog.Error("Failed to apply base prompt", "error", err, "workletID", worklet.ID)
-		}
+	port, err := m.ports.Allocate(worklet.ID)
+	if err != nil {
+		return "", 0, err
+	}
+	containerID, port, err := m.dockerClient.BuildAndRun(ctx, repoPath, worklet, port, logLine)
+	var conflict *PortConflictError
+	if !errors.As(err, &conflict) {
+		return containerID, port, err
 	}
 	
-	m.updateWorkletStatus(worklet, StatusRunning, "")
+	slog.Warn("Worklet port is taken, allocating another",
+		"workletID", worklet.ID,
+		"port", conflict.Port,
+		"action", "worklet_port_conflict",
+	)
+	if err := m.ports.Release(worklet.ID); err != nil {
+		return "", 0, err
+	}
+	if port, err = m.ports.Allocate(worklet.ID); err != nil {
+		return "", 0, err
+	}
+	return m.dockerClient.BuildAndRun(ctx, repoPath, worklet, port, logLine)
+}
+
+// rebuildIfChanged redeploys the worklet after a prompt, but only when the
+// prompt changed the files its running image was built from
+func (m *Manager) rebuildIfChanged(ctx context.Context, worklet *Worklet, repoPath string) {
+	hash, err := buildHash(repoPath, worklet.BuildStrategy)
+	if err != nil {
+		slog.Error("Failed to check worklet for changes", "error", err, "workletID", worklet.ID)
+		return
+	}
+	if hash == worklet.BuildHash && worklet.ContainerID != "" {
+		slog.Info("Prompt changed no files, skipping rebuild", "workletID", worklet.ID, "action", "worklet_rebuild_skipped")
+		metrics.WorkletImageBuildsTotal.WithLabelValues("skipped").Inc()
+		return
+	}
 	
-	slog.Info("Worklet deployed successfully", "workletID", worklet.ID, "url", worklet.WebURL)
+	slog.Info("Rebuilding worklet after prompt", "workletID", worklet.ID, "action", "worklet_rebuild")
+	m.stopRuntimeLogs(worklet.ID)
+	m.stopHealthChecks(worklet.ID)
+	m.updateWorkletStatus(worklet, StatusBuilding, "")
+	if !m.buildAndRun(ctx, worklet, repoPath) {
+		return
+	}
+	m.updateWorkletStatus(worklet, StatusRunning, "")
+	m.publishEvent(worklet, events.WorkletEvent{Type: events.WorkletDeployed, WebURL: worklet.WebURL})
+	m.captureRuntimeLogs(worklet)
+	m.watchHealth(worklet)
+}
+
+// runPrompt runs a prompt with the applyPrompt hook, or processPromptAsync without one
+func (m *Manager) runPrompt(ctx context.Context, worklet *Worklet, workletPrompt *WorkletPrompt) {
+	if m.applyPrompt != nil {
+		m.applyPrompt(ctx, worklet, workletPrompt)
+		return
+	}
+	m.processPromptAsync(ctx, worklet, workletPrompt)
 }
 
 func (m *Manager) processPromptAsync(ctx context.Context, worklet *Worklet, workletPrompt *WorkletPrompt) {
@@ -228,23 +680,43 @@ func (m *Manager) processPromptAsync(ctx context.Context, worklet *Worklet, work
 		}
 	}()
 	
-	repoPath := m.gitClient.GetRepoPath(worklet.GitRepo, worklet.Branch)
+	// Prompts to a worklet run one at a time, so each one's diff is only its own changes
+	lock := m.promptLock(worklet.ID)
+	lock.Lock()
+	defer lock.Unlock()
 	
-	response, err := m.claudeClient.ProcessPrompt(ctx, repoPath, workletPrompt.Prompt)
+	repoPath := m.gitClient.RepoPath(worklet.checkoutSpec())
+	baseTree, err := snapshotWorkTree(ctx, repoPath, "HEAD")
+	if err != nil {
+		slog.Warn("Failed to snapshot worklet files before prompt", "error", err, "workletID", worklet.ID)
+	}
+	
+	response, err := m.claudeClient.ProcessPrompt(ctx, worklet.SessionID, repoPath, workletPrompt.Prompt, worklet.SessionOptions()...)
 	if err != nil {
 		workletPrompt.Status = "error"
 		workletPrompt.Response = fmt.Sprintf("Failed to process prompt: %v", err)
 		slog.Error("Failed to process prompt", "error", err, "workletID", worklet.ID)
+		m.publishEvent(worklet, events.WorkletEvent{Type: events.WorkletError, Error: workletPrompt.Response, PromptID: workletPrompt.ID})
 	} else {
 		workletPrompt.Status = "completed"
 		workletPrompt.Response = response
+		if baseTree != "" {
+			m.recordPromptDiff(ctx, workletPrompt, repoPath, baseTree)
+		}
+		m.db.Save(workletPrompt)
 		
 		worklet.LastPrompt = workletPrompt.Prompt
 		worklet.UpdatedAt = time.Now()
 		m.db.Save(worklet)
+		m.publishEvent(worklet, events.WorkletEvent{Type: events.WorkletPromptApplied, PromptID: workletPrompt.ID})
 		
-		if err := m.dockerClient.RestartContainer(worklet.ContainerID); err != nil {
-			slog.Error("Failed to restart container after prompt", "error", err, "workletID", worklet.ID)
+		m.rebuildIfChanged(ctx, worklet, repoPath)
+		if worklet.RunTests && worklet.Status == StatusRunning {
+			m.runTests(ctx, worklet, repoPath)
+		}
+		// Artifacts are only published from builds whose tests didn't fail
+		if worklet.Status == StatusRunning && !(worklet.RunTests && worklet.TestStatus == TestsFailed) {
+			m.publishArtifacts(ctx, worklet, workletPrompt.ID)
 		}
 	}
 	
@@ -252,17 +724,147 @@ func (m *Manager) processPromptAsync(ctx context.Context, worklet *Worklet, work
 }
 
 func (m *Manager) updateWorkletStatus(worklet *Worklet, status Status, errorMsg string) {
+	// The cached worklet is shared with request handlers, so it's changed
+	// under the lock and a copy of it is saved and published
+	m.mu.Lock()
 	worklet.Status = status
 	worklet.LastError = errorMsg
 	worklet.UpdatedAt = time.Now()
+	m.worklets[worklet.ID] = worklet
+	updated := *worklet
+	m.mu.Unlock()
+	metrics.WorkletStatusTransitionsTotal.WithLabelValues(string(status)).Inc()
 	
-	if err := m.db.Save(worklet).Error; err != nil {
+	if err := m.db.Save(&updated).Error; err != nil {
 		slog.Error("Failed to update worklet status", "error", err, "workletID", worklet.ID)
 	}
 	
-	m.mu.Lock()
-	m.worklets[worklet.ID] = worklet
-	m.mu.Unlock()
+	m.publishStatus(&updated)
+}
+
+// statusEvents are the lifecycle events published when a worklet moves to a status
+var statusEvents = map[Status]events.WorkletEventType{
+	StatusBuilding: events.WorkletBuilding,
+	StatusError:    events.WorkletError,
+	StatusStopped:  events.WorkletStopped,
+	StatusSleeping: events.WorkletSlept,
+}
+
+// publishStatus publishes the worklet's status, along with the lifecycle
+// event moving to it is, if any
+func (m *Manager) publishStatus(worklet *Worklet) {
+	events.Publish(m.events, events.TopicWorkletStatus, events.WorkletStatus{
+		WorkletID: worklet.ID,
+		UserID:    worklet.UserID,
+		Status:    string(worklet.Status),
+		Error:     worklet.LastError,
+		WebURL:    worklet.WebURL,
+	})
+	if eventType, ok := statusEvents[worklet.Status]; ok {
+		event := events.WorkletEvent{Type: eventType}
+		if eventType == events.WorkletError {
+			event.Error = worklet.LastError
+		}
+		m.publishEvent(worklet, event)
+	}
+}
+
+// publishEvent publishes a lifecycle event for the worklet
+func (m *Manager) publishEvent(worklet *Worklet, event events.WorkletEvent) {
+	event.WorkletID = worklet.ID
+	event.UserID = worklet.UserID
+	events.Publish(m.events, events.TopicWorkletEvent, event)
+}
+
+// publishLog returns a LogFunc that publishes a worklet's build and deploy
+// output and keeps it in the worklet's log buffer
+func (m *Manager) publishLog(worklet *Worklet) LogFunc {
+	buffer := m.logBuffer(worklet)
+	return func(stage, line string) {
+		buffer.append(stage, line)
+		events.Publish(m.events, events.TopicWorkletLog, events.WorkletLog{
+			WorkletID: worklet.ID,
+			Stage:     stage,
+			Line:      line,
+		})
+	}
+}
+
+// CreatePR pushes the worklet's changes to branchName and opens a pull
+// request. Worklets whose tests failed or are still running can't open one
+// unless force is set.
+func (m *Manager) CreatePR(ctx context.Context, worklet *Worklet, branchName, title, description string, force bool) error {
+	if err := checkTests(worklet); err != nil {
+		if !force {
+			return err
+		}
+		slog.Warn("Creating pull request despite tests",
+			"workletID", worklet.ID,
+			"tests", worklet.TestStatus,
+			"action", "worklet_pr_forced",
+		)
+	}
+	
+	repoPath := m.gitClient.RepoPath(worklet.checkoutSpec())
+	
+	url, err := m.claudeClient.CreatePR(ctx, repoPath, branchName, title, description)
+	if err != nil {
+		return err
+	}
+	
+	// The pull request is tracked from here on, until it's merged or closed
+	worklet.PRURL = url
+	worklet.PRNumber = pullRequestNumber(url)
+	worklet.PRState = PROpen
+	worklet.PRChecks = ChecksNone
+	if err := m.db.Model(worklet).Updates(map[string]any{
+		"pr_url":    worklet.PRURL,
+		"pr_number": worklet.PRNumber,
+		"pr_state":  worklet.PRState,
+		"pr_checks": worklet.PRChecks,
+	}).Error; err != nil {
+		slog.Error("Failed to record pull request", "error", err, "workletID", worklet.ID)
+	}
+	
+	events.Publish(m.events, events.TopicPRCreated, events.PRCreated{
+		WorkletID: worklet.ID,
+		UserID:    worklet.UserID,
+		Repo:      worklet.GitRepo,
+		Branch:    branchName,
+		Title:     title,
+		URL:       url,
+		Number:    worklet.PRNumber,
+	})
+	return nil
+}
+
+// trackBuild registers a worklet build with the shutdown drainer. A build cut
+// off at the drain deadline is left in the error state so it can be restarted.
+func (m *Manager) trackBuild(workletID string) (func(), error) {
+	return m.trackWork("worklet_build", workletID, func() {
+		m.markInterrupted(&Worklet{}, workletID, map[string]any{
+			"status":     StatusError,
+			"last_error": "Build interrupted by server restart. Restart the worklet to try again.",
+			"updated_at": time.Now(),
+		})
+	})
+}
+
+// trackWork registers background work with the shutdown drainer, returning
+// shutdown.ErrDraining when the server is no longer accepting work
+func (m *Manager) trackWork(kind, id string, onInterrupt func()) (func(), error) {
+	if m.drainer == nil {
+		return func() {}, nil
+	}
+	return m.drainer.Start(kind, id, onInterrupt)
+}
+
+// markInterrupted persists the interrupted state of a row without touching the
+// in-memory object still owned by the background goroutine
+func (m *Manager) markInterrupted(model any, id string, updates map[string]any) {
+	if err := m.db.Model(model).Where("id = ?", id).Updates(updates).Error; err != nil {
+		slog.Error("Failed to persist interrupted state", "error", err, "id", id)
+	}
 }
 
 func generateID() string {
@@ -0,0 +1,174 @@
+package worklet
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrPortsExhausted is returned when every port in the worklet port range is
+// taken, even after reclaiming stopped worklets' ports
+var ErrPortsExhausted = errors.New("no worklet ports are free")
+
+// PortConflictError is returned when a worklet's host port is held by
+// something else
+type PortConflictError struct {
+	Port int
+	Err  error
+}
+
+func (e *PortConflictError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("port %d is in use", e.Port)
+	}
+	return fmt.Sprintf("port %d is in use: %v", e.Port, e.Err)
+}
+
+func (e *PortConflictError) Unwrap() error {
+	return e.Err
+}
+
+// isBindError reports whether Docker failed to start a container because its
+// host port was taken
+func isBindError(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "port is already allocated") || strings.Contains(message, "address already in use")
+}
+
+// PortAllocation records the host port a worklet's container is published on
+type PortAllocation struct {
+	Port      int    `gorm:"primaryKey;autoIncrement:false"`
+	WorkletID string `gorm:"uniqueIndex;not null"`
+	CreatedAt time.Time
+}
+
+// PortAllocator hands out host ports from a range, recording them in the
+// database so servers sharing it don't give the same port to two worklets
+type PortAllocator struct {
+	db         *gorm.DB
+	start, end int
+	mu         sync.Mutex
+
+	// available reports whether nothing is listening on a port; nil checks by listening on it
+	available func(port int) bool
+}
+
+// NewPortAllocator returns an allocator of the ports from start to end
+func NewPortAllocator(db *gorm.DB, start, end int) *PortAllocator {
+	if err := db.AutoMigrate(&PortAllocation{}); err != nil {
+		slog.Error("Failed to migrate port allocations", "error", err)
+	}
+	return &PortAllocator{db: db, start: start, end: end}
+}
+
+// Allocate returns the worklet's port: the one it already has, or else the
+// first free one in the range. When the range is full, the ports of stopped
+// and failed worklets are reclaimed.
+func (a *PortAllocator) Allocate(workletID string) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// The worklet's own container may be listening on it, so it isn't checked;
+	// if something else took it, starting the container fails with a conflict
+	var current PortAllocation
+	err := a.db.First(&current, "worklet_id = ?", workletID).Error
+	if err == nil {
+		return current.Port, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, fmt.Errorf("failed to load port allocation: %w", err)
+	}
+
+	port, err := a.claim(workletID)
+	if !errors.Is(err, ErrPortsExhausted) {
+		return port, err
+	}
+	reclaimed, err := a.reclaim()
+	if err != nil {
+		return 0, err
+	}
+	if reclaimed == 0 {
+		return 0, ErrPortsExhausted
+	}
+	return a.claim(workletID)
+}
+
+// claim records the first free port in the range as the worklet's
+func (a *PortAllocator) claim(workletID string) (int, error) {
+	var taken []int
+	if err := a.db.Model(&PortAllocation{}).Pluck("port", &taken).Error; err != nil {
+		return 0, fmt.Errorf("failed to load port allocations: %w", err)
+	}
+	allocated := make(map[int]bool, len(taken))
+	for _, port := range taken {
+		allocated[port] = true
+	}
+
+	for port := a.start; port <= a.end; port++ {
+		if allocated[port] || !a.isAvailable(port) {
+			continue
+		}
+		if err := a.db.Create(&PortAllocation{Port: port, WorkletID: workletID}).Error; err != nil {
+			// Another server may have claimed it first
+			var count int64
+			if a.db.Model(&PortAllocation{}).Where("port = ?", port).Count(&count); count > 0 {
+				continue
+			}
+			return 0, fmt.Errorf("failed to allocate port: %w", err)
+		}
+		slog.Info("Allocated worklet port", "workletID", workletID, "port", port, "action", "worklet_port_allocated")
+		return port, nil
+	}
+	return 0, ErrPortsExhausted
+}
+
+// reclaim releases the ports of worklets that are stopped, failed, or gone,
+// returning how many it released
+func (a *PortAllocator) reclaim() (int64, error) {
+	active := a.db.Model(&Worklet{}).Select("id").Where("status NOT IN ?", []Status{StatusStopped, StatusError})
+	result := a.db.Where("worklet_id NOT IN (?)", active).Delete(&PortAllocation{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to reclaim ports: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		slog.Info("Reclaimed ports of stopped worklets", "count", result.RowsAffected, "action", "worklet_ports_reclaimed")
+	}
+	return result.RowsAffected, nil
+}
+
+// Release frees the worklet's port
+func (a *PortAllocator) Release(workletID string) error {
+	if err := a.db.Where("worklet_id = ?", workletID).Delete(&PortAllocation{}).Error; err != nil {
+		return fmt.Errorf("failed to release port: %w", err)
+	}
+	return nil
+}
+
+// errPortReassigned is why a stopped worklet's container can't be restarted
+// after its port was reclaimed
+var errPortReassigned = errors.New("it was reclaimed while the worklet was stopped; rebuild the worklet to get a new port")
+
+// Holds reports whether port is still allocated to the worklet
+func (a *PortAllocator) Holds(workletID string, port int) bool {
+	var count int64
+	a.db.Model(&PortAllocation{}).Where("worklet_id = ? AND port = ?", workletID, port).Count(&count)
+	return count > 0
+}
+
+func (a *PortAllocator) isAvailable(port int) bool {
+	if a.available != nil {
+		return a.available(port)
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return false
+	}
+	listener.Close()
+	return true
+}
@@ -0,0 +1,110 @@
+package worklet
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/breadchris/flow/models"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newTestAllocator returns an allocator of ports 100 to 102 that treats the
+// busy ports as taken by something else
+func newTestAllocator(t *testing.T, busy ...int) (*PortAllocator, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&Worklet{}))
+	allocator := NewPortAllocator(db, 100, 102)
+	allocator.available = func(port int) bool {
+		for _, b := range busy {
+			if port == b {
+				return false
+			}
+		}
+		return true
+	}
+	return allocator, db
+}
+
+func TestPortAllocator(t *testing.T) {
+	allocator, _ := newTestAllocator(t, 101)
+
+	first, err := allocator.Allocate("w1")
+	require.NoError(t, err)
+	assert.Equal(t, 100, first)
+
+	second, err := allocator.Allocate("w2")
+	require.NoError(t, err)
+	assert.Equal(t, 102, second, "ports something else listens on are skipped")
+
+	again, err := allocator.Allocate("w1")
+	require.NoError(t, err)
+	assert.Equal(t, first, again, "a worklet keeps its port")
+	assert.True(t, allocator.Holds("w1", 100))
+
+	require.NoError(t, allocator.Release("w1"))
+	assert.False(t, allocator.Holds("w1", 100))
+	third, err := allocator.Allocate("w3")
+	require.NoError(t, err)
+	assert.Equal(t, 100, third, "released ports are handed out again")
+}
+
+func TestPortAllocatorReclaimsStoppedWorklets(t *testing.T) {
+	allocator, db := newTestAllocator(t)
+	for id, status := range map[string]Status{"w1": StatusRunning, "w2": StatusStopped, "w3": StatusRunning, "w4": StatusDeploying, "w5": StatusDeploying} {
+		require.NoError(t, db.Create(&Worklet{Model: models.Model{ID: id}, Status: status}).Error)
+	}
+	for _, id := range []string{"w1", "w2", "w3"} {
+		_, err := allocator.Allocate(id)
+		require.NoError(t, err)
+	}
+	stoppedPort := 0
+	for port := 100; port <= 102; port++ {
+		if allocator.Holds("w2", port) {
+			stoppedPort = port
+		}
+	}
+
+	port, err := allocator.Allocate("w4")
+	require.NoError(t, err)
+	assert.Equal(t, stoppedPort, port)
+	assert.False(t, allocator.Holds("w2", stoppedPort))
+
+	// Only running and deploying worklets are left
+	_, err = allocator.Allocate("w5")
+	assert.ErrorIs(t, err, ErrPortsExhausted)
+}
+
+func TestRestartContainerAfterPortReclaimed(t *testing.T) {
+	allocator, db := newTestAllocator(t)
+	require.NoError(t, db.AutoMigrate(&WorkletPrompt{}))
+	worklet := &Worklet{Model: models.Model{ID: "w1"}, Status: StatusStopped, UserID: "alice", ContainerID: "c1", Port: 100}
+	require.NoError(t, db.Create(worklet).Error)
+	manager := &Manager{db: db, worklets: make(map[string]*Worklet), webServer: NewWebServer(), ports: allocator}
+	router := mux.NewRouter()
+	(&WorkletHandler{manager: manager}).RegisterRoutes(router.PathPrefix("/api/worklet").Subrouter())
+
+	// Another worklet was given the stopped worklet's port
+	_, err := allocator.Allocate("w2")
+	require.NoError(t, err)
+
+	err = manager.RestartContainer("w1")
+	var conflict *PortConflictError
+	require.True(t, errors.As(err, &conflict), "got %v", err)
+	assert.Equal(t, 100, conflict.Port)
+
+	rr := serve(router, "POST", "/api/worklet/worklets/w1/actions/restart", "alice", "")
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, "conflict", errorCode(t, rr))
+}
+
+func TestIsBindError(t *testing.T) {
+	assert.True(t, isBindError(errors.New("Bind for 0.0.0.0:20000 failed: port is already allocated")))
+	assert.True(t, isBindError(errors.New("listen tcp4 0.0.0.0:20000: bind: address already in use")))
+	assert.False(t, isBindError(errors.New("no such image")))
+}
@@ -0,0 +1,258 @@
+package worklet
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/breadchris/flow/models"
+)
+
+// PreviewAccess is who may open a worklet's preview URL
+type PreviewAccess string
+
+const (
+	PreviewPublic PreviewAccess = "public" // Anyone with the URL
+	PreviewToken  PreviewAccess = "token"  // Requests carrying the worklet's preview token
+	PreviewBasic  PreviewAccess = "basic"  // Requests with the worklet's basic auth username and password
+)
+
+// previewTokenParam is the query parameter a preview token can be given in;
+// it's moved into previewTokenCookie so the app's own links keep working
+const (
+	previewTokenParam  = "token"
+	previewTokenCookie = "worklet_preview_token"
+)
+
+// PreviewSettings sets how a worklet's preview URL is protected
+type PreviewSettings struct {
+	Access   PreviewAccess `json:"access"`
+	Username string        `json:"username,omitempty"` // Required for basic access
+	Password string        `json:"password,omitempty"` // Required for basic access
+}
+
+// Validate rejects unknown access modes and basic access without credentials
+func (p PreviewSettings) Validate() error {
+	switch p.Access {
+	case "", PreviewPublic, PreviewToken:
+		return nil
+	case PreviewBasic:
+		if p.Username == "" || p.Password == "" {
+			return fmt.Errorf("basic preview access needs a username and password")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown preview access %q, expected public, token, or basic", p.Access)
+	}
+}
+
+// apply sets the worklet's preview access, keeping its current mode when
+// the settings have none
+func (p PreviewSettings) apply(w *Worklet) {
+	if p.Access != "" {
+		w.PreviewAccess = p.Access
+	}
+	if p.Access == PreviewBasic {
+		w.PreviewUsername = p.Username
+		w.PreviewPasswordHash = models.HashToken(p.Password)
+	}
+	if w.PreviewToken == "" {
+		w.PreviewToken = newPreviewToken()
+	}
+}
+
+// newPreviewToken returns a random token for a worklet's preview URL
+func newPreviewToken() string {
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); err != nil {
+		panic(fmt.Sprintf("failed to generate preview token: %v", err))
+	}
+	return hex.EncodeToString(bytes)
+}
+
+// defaultPreviewAccess is the access new worklets get unless they ask for
+// another: token access, or public when the server is set up that way
+func (m *Manager) defaultPreviewAccess() PreviewAccess {
+	if m.deps != nil && PreviewAccess(m.deps.Config.Worklet.PreviewAccess) == PreviewPublic {
+		return PreviewPublic
+	}
+	return PreviewToken
+}
+
+// previewDomain returns the domain worklet previews are served under, such as
+// worklets.example.com, and whether subdomain previews are enabled
+func (m *Manager) previewDomain() (string, bool) {
+	if m.deps == nil || m.deps.Config.Worklet.PreviewDomain == "" {
+		return "", false
+	}
+	return strings.ToLower(m.deps.Config.Worklet.PreviewDomain), true
+}
+
+// PreviewURL returns the worklet's URL under the preview domain, or "" when
+// subdomain previews are off
+func (m *Manager) PreviewURL(worklet *Worklet) string {
+	domain, ok := m.previewDomain()
+	if !ok {
+		return ""
+	}
+	scheme := "http"
+	if m.deps.Config.Server.TLS.Enabled() {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s.%s", scheme, worklet.ID, domain)
+}
+
+// PreviewLink returns a link that opens the running worklet: its preview URL,
+// with its token when one is needed, or its local address
+func (m *Manager) PreviewLink(worklet *Worklet) string {
+	link := m.PreviewURL(worklet)
+	if link == "" {
+		return worklet.WebURL
+	}
+	if worklet.PreviewAccess == PreviewToken {
+		link += "/?" + previewTokenParam + "=" + url.QueryEscape(worklet.PreviewToken)
+	}
+	return link
+}
+
+// previewWorkletID returns the worklet ID a request's host names under the
+// preview domain
+func (m *Manager) previewWorkletID(host string) (string, bool) {
+	domain, ok := m.previewDomain()
+	if !ok {
+		return "", false
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if d, _, err := net.SplitHostPort(domain); err == nil {
+		domain = d
+	}
+	id, found := strings.CutSuffix(strings.ToLower(host), "."+domain)
+	if !found || id == "" || strings.Contains(id, ".") {
+		return "", false
+	}
+	return id, true
+}
+
+// PreviewHostPolicy allows TLS certificates only for the preview hosts of
+// worklets that exist, so certificates aren't requested for arbitrary names
+func (m *Manager) PreviewHostPolicy(_ context.Context, host string) error {
+	id, ok := m.previewWorkletID(host)
+	if !ok {
+		return fmt.Errorf("host %q is not a worklet preview", host)
+	}
+	if _, err := m.GetWorklet(id); err != nil {
+		return fmt.Errorf("host %q is not a worklet preview: %w", host, err)
+	}
+	return nil
+}
+
+// ServePreviews serves requests for a worklet's preview host from the
+// worklet's app and passes everything else to next
+func (h *WorkletHandler) ServePreviews(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := h.manager.previewWorkletID(r.Host)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.servePreview(w, r, id)
+	})
+}
+
+func (h *WorkletHandler) servePreview(w http.ResponseWriter, r *http.Request, id string) {
+	worklet, err := h.manager.GetWorklet(id)
+	if err != nil {
+		http.Error(w, "Worklet not found", http.StatusNotFound)
+		return
+	}
+	if !previewAllowed(w, r, worklet, "/") {
+		return
+	}
+	if h.manager.currentStatus(worklet).Status == StatusSleeping && !h.awaken(w, r, worklet) {
+		return
+	}
+	if status := h.manager.currentStatus(worklet).Status; status != StatusRunning && status != StatusUnhealthy {
+		http.Error(w, fmt.Sprintf("Worklet is not running, status: %s", status), http.StatusServiceUnavailable)
+		return
+	}
+	if err := h.manager.ensureProxy(worklet); err != nil {
+		http.Error(w, "Failed to reach worklet", http.StatusBadGateway)
+		return
+	}
+	h.manager.touch(worklet)
+	h.manager.webServer.ServeWorklet(w, r, worklet.ID)
+}
+
+// previewAllowed checks a preview request against the worklet's access mode,
+// answering it itself when it's refused or needs a redirect. A token from the
+// URL is kept in a cookie for the paths under cookiePath.
+func previewAllowed(w http.ResponseWriter, r *http.Request, worklet *Worklet, cookiePath string) bool {
+	switch worklet.PreviewAccess {
+	case PreviewPublic:
+		return true
+
+	case PreviewBasic:
+		username, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(username), []byte(worklet.PreviewUsername)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(models.HashToken(password)), []byte(worklet.PreviewPasswordHash)) != 1 {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", "worklet "+worklet.Name))
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return false
+		}
+		// The app never sees the preview's credentials
+		r.Header.Del("Authorization")
+		return true
+
+	default:
+		matches := func(token string) bool {
+			return token != "" && worklet.PreviewToken != "" &&
+				subtle.ConstantTimeCompare([]byte(token), []byte(worklet.PreviewToken)) == 1
+		}
+		if token := r.URL.Query().Get(previewTokenParam); token != "" {
+			if !matches(token) {
+				http.Error(w, "Invalid preview token", http.StatusForbidden)
+				return false
+			}
+			http.SetCookie(w, &http.Cookie{
+				Name:     previewTokenCookie,
+				Value:    token,
+				Path:     cookiePath,
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteLaxMode,
+			})
+			query := r.URL.Query()
+			query.Del(previewTokenParam)
+			target := *r.URL
+			target.RawQuery = query.Encode()
+			http.Redirect(w, r, target.RequestURI(), http.StatusFound)
+			return false
+		}
+		if cookie, err := r.Cookie(previewTokenCookie); err == nil && matches(cookie.Value) {
+			return true
+		}
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && matches(bearer) {
+			r.Header.Del("Authorization")
+			return true
+		}
+		http.Error(w, "This worklet preview needs its token", http.StatusUnauthorized)
+		return false
+	}
+}
+
+// ensureProxy points the worklet's proxy at its container's current port
+func (m *Manager) ensureProxy(worklet *Worklet) error {
+	if worklet.Port == 0 {
+		return fmt.Errorf("worklet %s has no port", worklet.ID)
+	}
+	return m.webServer.ProxyTo(worklet.ID, fmt.Sprintf("http://localhost:%d", worklet.Port))
+}
@@ -0,0 +1,175 @@
+package worklet
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/deps"
+	"github.com/breadchris/flow/models"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newPreviewHandler serves previews under worklets.test:8082 for worklets
+// whose app is a test server
+func newPreviewHandler(t *testing.T, worklets ...*Worklet) http.Handler {
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "app "+r.URL.Path+" auth="+r.Header.Get("Authorization"))
+	}))
+	t.Cleanup(app.Close)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&Worklet{}))
+	for _, w := range worklets {
+		w.Status = StatusRunning
+		w.Port = serverPort(t, app)
+		require.NoError(t, db.Create(w).Error)
+	}
+
+	d := &deps.Deps{DB: db, Config: config.AppConfig{Worklet: config.WorkletConfig{PreviewDomain: "worklets.test:8082"}}}
+	handler := &WorkletHandler{manager: &Manager{db: db, deps: d, worklets: make(map[string]*Worklet), webServer: NewWebServer()}}
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router.PathPrefix("/api/worklet").Subrouter())
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "flow")
+	})
+	return handler.ServePreviews(router)
+}
+
+func previewWorklet(id string, settings PreviewSettings) *Worklet {
+	w := &Worklet{Model: models.Model{ID: id}, Name: id, GitRepo: "https://github.com/acme/" + id, Branch: "main", UserID: "alice"}
+	settings.apply(w)
+	return w
+}
+
+func get(handler http.Handler, url string, setup func(*http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", url, nil)
+	if setup != nil {
+		setup(req)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestPreviewWorkletID(t *testing.T) {
+	m := &Manager{deps: &deps.Deps{Config: config.AppConfig{Worklet: config.WorkletConfig{PreviewDomain: "Worklets.Example.com"}}}}
+
+	id, ok := m.previewWorkletID("abc-123.worklets.example.com")
+	assert.True(t, ok)
+	assert.Equal(t, "abc-123", id)
+	id, ok = m.previewWorkletID("ABC-123.worklets.example.com:8443")
+	assert.True(t, ok)
+	assert.Equal(t, "abc-123", id)
+
+	for _, host := range []string{"worklets.example.com", "a.b.worklets.example.com", "flow.example.com", "abc.worklets.example.com.evil.io"} {
+		_, ok := m.previewWorkletID(host)
+		assert.False(t, ok, host)
+	}
+
+	_, ok = (&Manager{}).previewWorkletID("abc.worklets.example.com")
+	assert.False(t, ok, "previews are off without a domain")
+}
+
+func TestPreviewTokenAccess(t *testing.T) {
+	worklet := previewWorklet("w1", PreviewSettings{Access: PreviewToken})
+	handler := newPreviewHandler(t, worklet)
+
+	rr := get(handler, "http://flow.test:8082/api", nil)
+	assert.Equal(t, "flow", rr.Body.String(), "other hosts reach the app's own routes")
+
+	rr = get(handler, "http://w1.worklets.test:8082/", nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr = get(handler, "http://w1.worklets.test:8082/?token=wrong", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = get(handler, "http://w1.worklets.test:8082/page?token="+worklet.PreviewToken+"&tab=2", nil)
+	require.Equal(t, http.StatusFound, rr.Code)
+	assert.Equal(t, "/page?tab=2", rr.Header().Get("Location"), "the token is moved out of the URL")
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	rr = get(handler, "http://w1.worklets.test:8082/page", func(r *http.Request) { r.AddCookie(cookies[0]) })
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "app /page auth=", rr.Body.String())
+
+	rr = get(handler, "http://w1.worklets.test:8082/", func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+worklet.PreviewToken) })
+	assert.Equal(t, "app / auth=", rr.Body.String(), "the token isn't passed on to the app")
+}
+
+func TestProxyHasThePreviewGate(t *testing.T) {
+	worklet := previewWorklet("w1", PreviewSettings{Access: PreviewToken})
+	handler := newPreviewHandler(t, worklet, previewWorklet("w2", PreviewSettings{Access: PreviewPublic}))
+
+	rr := get(handler, "http://flow.test:8082/api/worklet/worklets/w1/proxy/page", nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "the main host doesn't skip the preview's gate")
+
+	rr = get(handler, "http://flow.test:8082/api/worklet/worklets/w1/proxy/page?token="+worklet.PreviewToken, nil)
+	require.Equal(t, http.StatusFound, rr.Code)
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "/api/worklet/worklets/w1/proxy", cookies[0].Path, "the token is kept for w1's proxy only")
+
+	rr = get(handler, "http://flow.test:8082/api/worklet/worklets/w1/proxy/page", func(r *http.Request) { r.AddCookie(cookies[0]) })
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = get(handler, "http://flow.test:8082/api/worklet/worklets/w2/proxy", nil)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
+
+func TestPreviewBasicAndPublicAccess(t *testing.T) {
+	handler := newPreviewHandler(t,
+		previewWorklet("w1", PreviewSettings{Access: PreviewBasic, Username: "demo", Password: "hunter2"}),
+		previewWorklet("w2", PreviewSettings{Access: PreviewPublic}),
+	)
+
+	rr := get(handler, "http://w1.worklets.test:8082/", nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, `Basic realm="worklet w1"`, rr.Header().Get("WWW-Authenticate"))
+	rr = get(handler, "http://w1.worklets.test:8082/", func(r *http.Request) { r.SetBasicAuth("demo", "wrong") })
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr = get(handler, "http://w1.worklets.test:8082/", func(r *http.Request) { r.SetBasicAuth("demo", "hunter2") })
+	assert.Equal(t, "app / auth=", rr.Body.String())
+
+	rr = get(handler, "http://w2.worklets.test:8082/about", nil)
+	assert.Equal(t, "app /about auth=", rr.Body.String())
+
+	rr = get(handler, "http://missing.worklets.test:8082/", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestPreviewHostPolicyAndLinks(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&Worklet{}))
+	worklet := previewWorklet("w1", PreviewSettings{Access: PreviewToken})
+	require.NoError(t, db.Create(worklet).Error)
+
+	cfg := config.AppConfig{Worklet: config.WorkletConfig{PreviewDomain: "worklets.example.com"}}
+	cfg.Server.TLS.AutocertDomains = []string{"flow.example.com"}
+	m := &Manager{db: db, deps: &deps.Deps{Config: cfg}, worklets: make(map[string]*Worklet)}
+
+	assert.NoError(t, m.PreviewHostPolicy(context.Background(), "w1.worklets.example.com"))
+	assert.Error(t, m.PreviewHostPolicy(context.Background(), "w9.worklets.example.com"))
+	assert.Error(t, m.PreviewHostPolicy(context.Background(), "flow.example.com"))
+
+	assert.Equal(t, "https://w1.worklets.example.com", m.PreviewURL(worklet))
+	assert.Equal(t, "https://w1.worklets.example.com/?token="+worklet.PreviewToken, m.PreviewLink(worklet))
+
+	worklet.WebURL = "http://localhost:4000"
+	assert.Equal(t, "http://localhost:4000", (&Manager{}).PreviewLink(worklet), "without a preview domain links go to the container")
+}
+
+func TestPreviewSettingsValidate(t *testing.T) {
+	assert.NoError(t, PreviewSettings{Access: PreviewToken}.Validate())
+	assert.Error(t, PreviewSettings{Access: PreviewBasic, Username: "demo"}.Validate())
+	assert.Error(t, PreviewSettings{Access: "sso"}.Validate())
+}
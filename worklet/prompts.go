@@ -0,0 +1,66 @@
+package worklet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrNoPromptDiff is returned for prompts whose changes weren't recorded,
+// such as ones that failed
+var ErrNoPromptDiff = errors.New("prompt has no recorded changes")
+
+// promptLock returns the lock held while a prompt runs on the worklet
+func (m *Manager) promptLock(workletID string) *sync.Mutex {
+	lock, _ := m.promptLocks.LoadOrStore(workletID, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// recordPromptDiff records what a prompt changed in the repository at
+// repoPath since its files were snapshotted as baseTree
+func (m *Manager) recordPromptDiff(ctx context.Context, workletPrompt *WorkletPrompt, repoPath, baseTree string) {
+	tree, err := snapshotWorkTree(ctx, repoPath, "HEAD")
+	if err != nil {
+		slog.Warn("Failed to snapshot worklet files after prompt", "error", err, "promptID", workletPrompt.ID)
+		return
+	}
+	diff, err := diffTrees(ctx, repoPath, baseTree, tree)
+	if err != nil {
+		slog.Warn("Failed to diff prompt changes", "error", err, "promptID", workletPrompt.ID)
+		return
+	}
+	workletPrompt.BaseTree = baseTree
+	workletPrompt.Tree = tree
+	workletPrompt.FilesChanged = len(diff.Files)
+	workletPrompt.Additions = diff.Additions
+	workletPrompt.Deletions = diff.Deletions
+}
+
+// ListPrompts returns the prompts sent to a worklet, oldest first
+func (m *Manager) ListPrompts(workletID string) ([]*WorkletPrompt, error) {
+	var prompts []*WorkletPrompt
+	if err := m.db.Where("worklet_id = ?", workletID).Order("created_at, id").Find(&prompts).Error; err != nil {
+		return nil, fmt.Errorf("failed to list prompts: %w", err)
+	}
+	return prompts, nil
+}
+
+// PromptDiff returns the changes one of the worklet's prompts made
+func (m *Manager) PromptDiff(ctx context.Context, worklet *Worklet, promptID string) (*Diff, error) {
+	var workletPrompt WorkletPrompt
+	if err := m.db.First(&workletPrompt, "id = ? AND worklet_id = ?", promptID, worklet.ID).Error; err != nil {
+		return nil, fmt.Errorf("prompt not found: %w", err)
+	}
+	if workletPrompt.BaseTree == "" {
+		return nil, ErrNoPromptDiff
+	}
+	repoPath := m.gitClient.RepoPath(worklet.checkoutSpec())
+	if _, err := os.Stat(filepath.Join(repoPath, ".git")); err != nil {
+		return nil, ErrNoCheckout
+	}
+	return diffTrees(ctx, repoPath, workletPrompt.BaseTree, workletPrompt.Tree)
+}
@@ -0,0 +1,88 @@
+package worklet
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/models"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptHistory(t *testing.T) {
+	_, db := newTestAPI(t)
+	require.NoError(t, db.AutoMigrate(&WorkletPrompt{}))
+	manager := &Manager{db: db, worklets: make(map[string]*Worklet), webServer: NewWebServer(), gitClient: &GitClient{baseDir: t.TempDir()}}
+	router := mux.NewRouter()
+	(&WorkletHandler{manager: manager}).RegisterRoutes(router.PathPrefix("/api/worklet").Subrouter())
+
+	remote, commits := newTestRemote(t)
+	worklet, err := manager.GetWorklet("w1")
+	require.NoError(t, err)
+	worklet.GitRepo, worklet.Branch, worklet.CommitSHA = remote, "trunk", commits[2]
+	repoPath, _, err := manager.gitClient.Checkout(worklet.checkoutSpec())
+	require.NoError(t, err)
+
+	// Two prompts, each changing a file; the second diff has only its own change
+	prompt := func(id, file, content string) *WorkletPrompt {
+		ctx := context.Background()
+		base, err := snapshotWorkTree(ctx, repoPath, "HEAD")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(repoPath, file), []byte(content), 0644))
+		workletPrompt := &WorkletPrompt{
+			Model:     models.Model{ID: id, CreatedAt: time.Now()},
+			WorkletID: "w1",
+			Prompt:    "Add " + file,
+			Status:    "completed",
+			UserID:    "alice",
+		}
+		manager.recordPromptDiff(ctx, workletPrompt, repoPath, base)
+		require.NoError(t, db.Create(workletPrompt).Error)
+		return workletPrompt
+	}
+	first := prompt("p1", "index.html", "<h1>Hi</h1>\n")
+	prompt("p2", "style.css", "h1 {\n  color: red;\n}\n")
+	require.NoError(t, db.Create(&WorkletPrompt{
+		Model: models.Model{ID: "p3", CreatedAt: time.Now()}, WorkletID: "w1", Prompt: "Break it", Status: "error", UserID: "alice",
+	}).Error)
+	assert.Equal(t, 1, first.FilesChanged)
+	assert.Equal(t, 1, first.Additions)
+
+	rr := serve(router, "GET", "/api/worklet/w1/prompts", "alice", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var prompts []WorkletPrompt
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &prompts))
+	require.Len(t, prompts, 3)
+	assert.Equal(t, []string{"p1", "p2", "p3"}, []string{prompts[0].ID, prompts[1].ID, prompts[2].ID})
+	assert.Equal(t, 3, prompts[1].Additions)
+
+	rr = serve(router, "GET", "/api/worklet/worklets/w1/prompts/p2/diff", "alice", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var diff Diff
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &diff))
+	assert.Equal(t, []FileDiff{{Path: "style.css", Additions: 3}}, diff.Files)
+
+	rr = serve(router, "GET", "/api/worklet/worklets/w1/prompts/p1/diff?format=patch", "alice", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "+<h1>Hi</h1>\n")
+	assert.NotContains(t, rr.Body.String(), "style.css")
+
+	rr = serve(router, "GET", "/api/worklet/worklets/w1/prompts/p3/diff", "alice", "")
+	assert.Equal(t, http.StatusConflict, rr.Code, "failed prompts have no diff")
+	rr = serve(router, "GET", "/api/worklet/worklets/w1/prompts/missing/diff", "alice", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = serve(router, "GET", "/api/worklet/w1/prompts", "bob", "")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestPromptLockIsPerWorklet(t *testing.T) {
+	manager := &Manager{}
+	assert.Same(t, manager.promptLock("w1"), manager.promptLock("w1"))
+	assert.NotSame(t, manager.promptLock("w1"), manager.promptLock("w2"))
+}
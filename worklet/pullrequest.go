@@ -0,0 +1,209 @@
+package worklet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/breadchris/flow/events"
+)
+
+// PRState is where a worklet's pull request is in review
+type PRState string
+
+const (
+	PROpen   PRState = "open"
+	PRMerged PRState = "merged"
+	PRClosed PRState = "closed"
+)
+
+// CheckStatus sums up the CI checks on a pull request
+type CheckStatus string
+
+const (
+	ChecksNone    CheckStatus = ""        // The pull request has no checks
+	ChecksPending CheckStatus = "pending" // Some checks haven't finished, and none have failed
+	ChecksPassed  CheckStatus = "passed"
+	ChecksFailed  CheckStatus = "failed"
+)
+
+// PullRequest is a pull request's state as GitHub reports it
+type PullRequest struct {
+	State  PRState
+	Checks CheckStatus
+}
+
+// pullRequestURLRegex matches a GitHub pull request URL and its number
+var pullRequestURLRegex = regexp.MustCompile(`https://\S+/pull/(\d+)`)
+
+// pullRequestURL returns the last pull request URL in gh's output, which is
+// the one it created
+func pullRequestURL(output string) string {
+	matches := pullRequestURLRegex.FindAllString(output, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	return matches[len(matches)-1]
+}
+
+// pullRequestNumber returns the number at the end of a pull request URL
+func pullRequestNumber(url string) int {
+	match := pullRequestURLRegex.FindStringSubmatch(url)
+	if match == nil {
+		return 0
+	}
+	number, _ := strconv.Atoi(match[1])
+	return number
+}
+
+// ghPullRequest is the JSON gh pr view prints for the fields fetchPullRequest asks for
+type ghPullRequest struct {
+	State             string    `json:"state"`
+	StatusCheckRollup []ghCheck `json:"statusCheckRollup"`
+}
+
+// ghCheck is a check run (with a status and conclusion) or a commit status
+// (with a state) on a pull request's head commit
+type ghCheck struct {
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	State      string `json:"state"`
+}
+
+// fetchPullRequest asks GitHub for a pull request's state and checks with the gh CLI
+func fetchPullRequest(ctx context.Context, url string) (PullRequest, error) {
+	cmd := exec.CommandContext(ctx, "gh", "pr", "view", url, "--json", "state,statusCheckRollup")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		cmd.Env = append(os.Environ(), "GITHUB_TOKEN="+token)
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return PullRequest{}, fmt.Errorf("failed to view pull request: %w", err)
+	}
+
+	var pr ghPullRequest
+	if err := json.Unmarshal(output, &pr); err != nil {
+		return PullRequest{}, fmt.Errorf("failed to read pull request: %w", err)
+	}
+	return PullRequest{State: PRState(strings.ToLower(pr.State)), Checks: summarizeChecks(pr.StatusCheckRollup)}, nil
+}
+
+// summarizeChecks sums up a pull request's checks: failed if any failed,
+// pending if any are still running, otherwise passed
+func summarizeChecks(checks []ghCheck) CheckStatus {
+	if len(checks) == 0 {
+		return ChecksNone
+	}
+	pending := false
+	for _, check := range checks {
+		result := check.Conclusion
+		if check.State != "" {
+			result = check.State
+		} else if check.Status != "COMPLETED" {
+			pending = true
+			continue
+		}
+		switch result {
+		case "SUCCESS", "NEUTRAL", "SKIPPED":
+		case "PENDING", "EXPECTED":
+			pending = true
+		default:
+			return ChecksFailed
+		}
+	}
+	if pending {
+		return ChecksPending
+	}
+	return ChecksPassed
+}
+
+// TrackPullRequests checks the worklets' open pull requests every interval
+// until ctx is done, publishing an event when one is merged or closed or its
+// checks change
+func (m *Manager) TrackPullRequests(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.refreshPullRequests(ctx)
+		}
+	}
+}
+
+// refreshPullRequests checks every open pull request once
+func (m *Manager) refreshPullRequests(ctx context.Context) {
+	var worklets []*Worklet
+	if err := m.db.Where("pr_state = ?", PROpen).Find(&worklets).Error; err != nil {
+		slog.Error("Failed to load worklets with open pull requests", "error", err)
+		return
+	}
+
+	fetch := m.fetchPullRequest
+	if fetch == nil {
+		fetch = fetchPullRequest
+	}
+	for _, worklet := range worklets {
+		pr, err := fetch(ctx, worklet.PRURL)
+		if err != nil {
+			slog.Warn("Failed to check pull request", "workletID", worklet.ID, "url", worklet.PRURL, "error", err)
+			continue
+		}
+		m.updatePullRequest(worklet, pr)
+	}
+}
+
+// updatePullRequest records a pull request's latest state, publishing the
+// change if there is one. Only the first to record a change publishes it, so
+// two managers watching the same pull request don't both announce it.
+func (m *Manager) updatePullRequest(worklet *Worklet, pr PullRequest) {
+	if pr.State == worklet.PRState && pr.Checks == worklet.PRChecks {
+		return
+	}
+	result := m.db.Model(&Worklet{}).
+		Where("id = ? AND pr_state = ? AND pr_checks = ?", worklet.ID, worklet.PRState, worklet.PRChecks).
+		Updates(map[string]any{"pr_state": pr.State, "pr_checks": pr.Checks})
+	if result.Error != nil {
+		slog.Error("Failed to update pull request state", "workletID", worklet.ID, "error", result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	if cached, ok := m.worklets[worklet.ID]; ok {
+		cached.PRState, cached.PRChecks = pr.State, pr.Checks
+	}
+	m.mu.Unlock()
+
+	slog.Info("Pull request changed",
+		"workletID", worklet.ID,
+		"url", worklet.PRURL,
+		"state", pr.State,
+		"checks", pr.Checks,
+		"action", "worklet_pr_status",
+	)
+	events.Publish(m.events, events.TopicPRStatus, events.PRStatus{
+		WorkletID:      worklet.ID,
+		UserID:         worklet.UserID,
+		URL:            worklet.PRURL,
+		Number:         worklet.PRNumber,
+		State:          string(pr.State),
+		Checks:         string(pr.Checks),
+		PreviousState:  string(worklet.PRState),
+		PreviousChecks: string(worklet.PRChecks),
+	})
+}
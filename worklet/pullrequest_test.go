@@ -0,0 +1,146 @@
+package worklet
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestPullRequestURL(t *testing.T) {
+	output := "Creating pull request for worklet-1 into main in acme/site\n\nhttps://github.com/acme/site/pull/42\n"
+
+	url := pullRequestURL(output)
+	assert.Equal(t, "https://github.com/acme/site/pull/42", url)
+	assert.Equal(t, 42, pullRequestNumber(url))
+	assert.Empty(t, pullRequestURL("no pull request here"))
+	assert.Zero(t, pullRequestNumber(""))
+}
+
+func TestSummarizeChecks(t *testing.T) {
+	passed := ghCheck{Status: "COMPLETED", Conclusion: "SUCCESS"}
+	tests := []struct {
+		name   string
+		checks []ghCheck
+		want   CheckStatus
+	}{
+		{"none", nil, ChecksNone},
+		{"passed", []ghCheck{passed, {Status: "COMPLETED", Conclusion: "SKIPPED"}, {State: "SUCCESS"}}, ChecksPassed},
+		{"running", []ghCheck{passed, {Status: "IN_PROGRESS"}}, ChecksPending},
+		{"status pending", []ghCheck{passed, {State: "PENDING"}}, ChecksPending},
+		{"failed", []ghCheck{{Status: "IN_PROGRESS"}, {Status: "COMPLETED", Conclusion: "FAILURE"}}, ChecksFailed},
+		{"status error", []ghCheck{passed, {State: "ERROR"}}, ChecksFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, summarizeChecks(tt.checks))
+		})
+	}
+}
+
+func TestRefreshPullRequests(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&Worklet{}))
+	require.NoError(t, db.Create(&Worklet{
+		Model:    models.Model{ID: "w1"},
+		UserID:   "alice",
+		PRURL:    "https://github.com/acme/site/pull/7",
+		PRNumber: 7,
+		PRState:  PROpen,
+	}).Error)
+	require.NoError(t, db.Create(&Worklet{Model: models.Model{ID: "w2"}, UserID: "alice"}).Error)
+
+	bus := events.New()
+	statuses, unsubscribe := events.Channel(bus, events.TopicPRStatus)
+	defer unsubscribe()
+
+	var fetched []string
+	pr := PullRequest{State: PROpen, Checks: ChecksPassed}
+	manager := &Manager{db: db, worklets: make(map[string]*Worklet), events: bus,
+		fetchPullRequest: func(ctx context.Context, url string) (PullRequest, error) {
+			fetched = append(fetched, url)
+			return pr, nil
+		}}
+
+	manager.refreshPullRequests(context.Background())
+	assert.Equal(t, []string{"https://github.com/acme/site/pull/7"}, fetched, "only open pull requests are checked")
+	select {
+	case status := <-statuses:
+		assert.Equal(t, events.PRStatus{
+			WorkletID:     "w1",
+			UserID:        "alice",
+			URL:           "https://github.com/acme/site/pull/7",
+			Number:        7,
+			State:         "open",
+			Checks:        "passed",
+			PreviousState: "open",
+		}, status)
+	case <-time.After(time.Second):
+		t.Fatal("no status published for the checks passing")
+	}
+
+	// Nothing has changed since
+	manager.refreshPullRequests(context.Background())
+
+	pr.State = PRMerged
+	manager.refreshPullRequests(context.Background())
+	select {
+	case status := <-statuses:
+		assert.Equal(t, "merged", status.State)
+		assert.Equal(t, "open", status.PreviousState)
+	case <-time.After(time.Second):
+		t.Fatal("no status published for the merge")
+	}
+
+	var worklet Worklet
+	require.NoError(t, db.First(&worklet, "id = ?", "w1").Error)
+	assert.Equal(t, PRMerged, worklet.PRState)
+
+	// Merged pull requests aren't checked again
+	fetched = nil
+	manager.refreshPullRequests(context.Background())
+	assert.Empty(t, fetched)
+	select {
+	case status := <-statuses:
+		t.Fatalf("unexpected status %+v", status)
+	default:
+	}
+}
+
+func TestUpdatePullRequestPublishesOnce(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&Worklet{}))
+	stale := &Worklet{Model: models.Model{ID: "w1"}, PRURL: "https://github.com/acme/site/pull/7", PRState: PROpen}
+	require.NoError(t, db.Create(stale).Error)
+
+	bus := events.New()
+	statuses, unsubscribe := events.Channel(bus, events.TopicPRStatus)
+	defer unsubscribe()
+
+	// Two managers that loaded the worklet before either saw it merged
+	merged := PullRequest{State: PRMerged}
+	first := &Manager{db: db, worklets: make(map[string]*Worklet), events: bus}
+	second := &Manager{db: db, worklets: make(map[string]*Worklet), events: bus}
+	copied := *stale
+	first.updatePullRequest(stale, merged)
+	second.updatePullRequest(&copied, merged)
+
+	select {
+	case <-statuses:
+	case <-time.After(time.Second):
+		t.Fatal("no status published")
+	}
+	select {
+	case status := <-statuses:
+		t.Fatalf("merge published twice: %+v", status)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
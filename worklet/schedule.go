@@ -0,0 +1,297 @@
+package worklet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/breadchris/flow/cron"
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/models"
+	"gorm.io/gorm"
+)
+
+// scheduleCheckInterval is how often the scheduler looks for runs that are due
+const scheduleCheckInterval = time.Minute
+
+// The statuses of a scheduled run
+const (
+	ScheduleRunRunning   = "running"
+	ScheduleRunSucceeded = "succeeded"
+	ScheduleRunFailed    = "failed"
+)
+
+// WorkletSchedule sends a worklet a prompt on a cron schedule, such as a
+// nightly "update dependencies" that opens a pull request with the changes
+type WorkletSchedule struct {
+	models.Model
+	WorkletID string     `json:"worklet_id" gorm:"index;not null"`
+	UserID    string     `json:"user_id" gorm:"not null"`
+	Cron      string     `json:"cron" gorm:"not null"` // Five fields: minute, hour, day, month, weekday
+	Prompt    string     `json:"prompt" gorm:"type:text;not null"`
+	CreatePR  bool       `json:"create_pr"` // Open a pull request when a run changes files
+	NextRunAt time.Time  `json:"next_run_at" gorm:"index"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+}
+
+// WorkletScheduleRun is the history of one run of a schedule
+type WorkletScheduleRun struct {
+	models.Model
+	ScheduleID   string     `json:"schedule_id" gorm:"index;not null"`
+	WorkletID    string     `json:"worklet_id" gorm:"not null"`
+	PromptID     string     `json:"prompt_id,omitempty"`
+	Status       string     `json:"status"` // "running", "succeeded", or "failed"
+	Error        string     `json:"error,omitempty" gorm:"type:text"`
+	FilesChanged int        `json:"files_changed"`
+	PRURL        string     `json:"pr_url,omitempty"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
+
+// CreateScheduleRequest schedules a prompt to a worklet
+type CreateScheduleRequest struct {
+	Cron     string `json:"cron"`
+	Prompt   string `json:"prompt"`
+	CreatePR bool   `json:"create_pr"`
+}
+
+// Validate checks that the request's cron expression runs and it has a prompt
+func (r *CreateScheduleRequest) Validate() error {
+	r.Cron = strings.TrimSpace(r.Cron)
+	r.Prompt = strings.TrimSpace(r.Prompt)
+	if r.Prompt == "" {
+		return errors.New("prompt is required")
+	}
+	schedule, err := cron.Parse(r.Cron)
+	if err != nil {
+		return err
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		return fmt.Errorf("%q never runs", r.Cron)
+	}
+	return nil
+}
+
+// migrateSchedules creates the schedule tables, which nothing else migrates
+func migrateSchedules(db *gorm.DB) {
+	if err := db.AutoMigrate(&WorkletSchedule{}, &WorkletScheduleRun{}); err != nil {
+		slog.Error("Failed to migrate worklet schedules", "error", err)
+	}
+}
+
+// CreateSchedule schedules a validated prompt to a worklet for userID
+func (m *Manager) CreateSchedule(workletID string, req CreateScheduleRequest, userID string) (*WorkletSchedule, error) {
+	parsed, err := cron.Parse(req.Cron)
+	if err != nil {
+		return nil, err
+	}
+	schedule := &WorkletSchedule{
+		Model:     models.Model{ID: generateID()},
+		WorkletID: workletID,
+		UserID:    userID,
+		Cron:      req.Cron,
+		Prompt:    req.Prompt,
+		CreatePR:  req.CreatePR,
+		NextRunAt: parsed.Next(time.Now()),
+	}
+	if err := m.db.Create(schedule).Error; err != nil {
+		return nil, fmt.Errorf("failed to create schedule: %w", err)
+	}
+	slog.Info("Scheduled worklet prompt",
+		"workletID", workletID,
+		"scheduleID", schedule.ID,
+		"cron", schedule.Cron,
+		"action", "worklet_schedule_created",
+	)
+	return schedule, nil
+}
+
+// ListSchedules returns a worklet's schedules, soonest first
+func (m *Manager) ListSchedules(workletID string) ([]*WorkletSchedule, error) {
+	schedules := []*WorkletSchedule{}
+	if err := m.db.Where("worklet_id = ?", workletID).Order("next_run_at").Find(&schedules).Error; err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+// GetSchedule returns one of a worklet's schedules
+func (m *Manager) GetSchedule(workletID, scheduleID string) (*WorkletSchedule, error) {
+	var schedule WorkletSchedule
+	if err := m.db.First(&schedule, "id = ? AND worklet_id = ?", scheduleID, workletID).Error; err != nil {
+		return nil, fmt.Errorf("schedule not found: %w", err)
+	}
+	return &schedule, nil
+}
+
+// DeleteSchedule removes one of a worklet's schedules; its runs are kept
+func (m *Manager) DeleteSchedule(workletID, scheduleID string) error {
+	schedule, err := m.GetSchedule(workletID, scheduleID)
+	if err != nil {
+		return err
+	}
+	if err := m.db.Delete(schedule).Error; err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	slog.Info("Deleted worklet schedule", "workletID", workletID, "scheduleID", scheduleID, "action", "worklet_schedule_deleted")
+	return nil
+}
+
+// ListScheduleRuns returns a schedule's runs, newest first
+func (m *Manager) ListScheduleRuns(scheduleID string) ([]*WorkletScheduleRun, error) {
+	runs := []*WorkletScheduleRun{}
+	if err := m.db.Where("schedule_id = ?", scheduleID).Order("created_at DESC").Find(&runs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list schedule runs: %w", err)
+	}
+	return runs, nil
+}
+
+// RunSchedules sends worklets their scheduled prompts when they're due until
+// ctx is done. Each run happens on its own goroutine, so a long prompt
+// doesn't hold up the others.
+func (m *Manager) RunSchedules(ctx context.Context) {
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+
+	// Schedules that came due while the server was down run once now
+	m.runDueSchedules(ctx, time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.runDueSchedules(ctx, now)
+		}
+	}
+}
+
+// runDueSchedules starts every schedule that is due
+func (m *Manager) runDueSchedules(ctx context.Context, now time.Time) {
+	var due []*WorkletSchedule
+	if err := m.db.Where("next_run_at <= ?", now).Order("next_run_at").Find(&due).Error; err != nil {
+		slog.Error("Failed to load due worklet schedules", "error", err)
+		return
+	}
+	for _, schedule := range due {
+		// The schedule moves on before it runs, so it isn't started again
+		// while this run is still going
+		if !m.advanceSchedule(schedule, now) {
+			continue
+		}
+		go m.runSchedule(ctx, schedule)
+	}
+}
+
+// advanceSchedule records that a schedule ran at now and when it runs next.
+// A schedule that can no longer be parsed isn't run.
+func (m *Manager) advanceSchedule(schedule *WorkletSchedule, now time.Time) bool {
+	parsed, err := cron.Parse(schedule.Cron)
+	if err != nil {
+		slog.Error("Failed to parse worklet schedule", "error", err, "scheduleID", schedule.ID)
+		return false
+	}
+	schedule.NextRunAt = parsed.Next(now)
+	schedule.LastRunAt = &now
+	if err := m.db.Save(schedule).Error; err != nil {
+		slog.Error("Failed to record worklet schedule run", "error", err, "scheduleID", schedule.ID)
+		return false
+	}
+	return true
+}
+
+// runSchedule sends a worklet its scheduled prompt, waits for it to be
+// applied, and opens a pull request with what it changed if the schedule
+// asks for one. The run's outcome is recorded and published.
+func (m *Manager) runSchedule(ctx context.Context, schedule *WorkletSchedule) {
+	run := &WorkletScheduleRun{
+		Model:      models.Model{ID: generateID()},
+		ScheduleID: schedule.ID,
+		WorkletID:  schedule.WorkletID,
+		Status:     ScheduleRunRunning,
+	}
+	if err := m.db.Create(run).Error; err != nil {
+		slog.Error("Failed to record worklet schedule run", "error", err, "scheduleID", schedule.ID)
+		return
+	}
+
+	worklet, workletPrompt, done, err := m.submitPrompt(ctx, schedule.WorkletID, schedule.Prompt, schedule.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// The worklet was deleted, so its schedule goes too
+		m.db.Delete(schedule)
+	}
+	if err == nil {
+		run.PromptID = workletPrompt.ID
+		m.runPrompt(ctx, worklet, workletPrompt)
+		done()
+		run.FilesChanged = workletPrompt.FilesChanged
+		run.PRURL, err = m.scheduleOutcome(ctx, schedule, worklet, workletPrompt)
+	}
+
+	now := time.Now()
+	run.FinishedAt = &now
+	run.Status = ScheduleRunSucceeded
+	if err != nil {
+		run.Status = ScheduleRunFailed
+		run.Error = err.Error()
+	}
+	if err := m.db.Save(run).Error; err != nil {
+		slog.Error("Failed to record worklet schedule run", "error", err, "scheduleID", schedule.ID, "runID", run.ID)
+	}
+
+	slog.Info("Ran worklet schedule",
+		"workletID", schedule.WorkletID,
+		"scheduleID", schedule.ID,
+		"runID", run.ID,
+		"status", run.Status,
+		"error", run.Error,
+		"filesChanged", run.FilesChanged,
+		"action", "worklet_schedule_run",
+	)
+	events.Publish(m.events, events.TopicScheduleRun, events.ScheduleRunFinished{
+		ScheduleID:   schedule.ID,
+		RunID:        run.ID,
+		WorkletID:    schedule.WorkletID,
+		UserID:       schedule.UserID,
+		Cron:         schedule.Cron,
+		Prompt:       schedule.Prompt,
+		Status:       run.Status,
+		Error:        run.Error,
+		FilesChanged: run.FilesChanged,
+		PRURL:        run.PRURL,
+	})
+}
+
+// scheduleOutcome returns why an applied scheduled prompt failed, or opens
+// the pull request the schedule asks for when it changed files and returns
+// its link
+func (m *Manager) scheduleOutcome(ctx context.Context, schedule *WorkletSchedule, worklet *Worklet, workletPrompt *WorkletPrompt) (string, error) {
+	switch {
+	case workletPrompt.Status == "error":
+		return "", errors.New(workletPrompt.Response)
+	case worklet.Status != StatusRunning:
+		return "", fmt.Errorf("worklet is %s after the prompt: %s", worklet.Status, worklet.LastError)
+	case worklet.RunTests && worklet.TestStatus == TestsFailed:
+		return "", ErrTestsFailed
+	case !schedule.CreatePR || workletPrompt.FilesChanged == 0:
+		return "", nil
+	}
+
+	open := m.openPR
+	if open == nil {
+		open = func(ctx context.Context, worklet *Worklet, branchName, title, description string) error {
+			return m.CreatePR(ctx, worklet, branchName, title, description, false)
+		}
+	}
+	title := schedule.Prompt
+	if runes := []rune(title); len(runes) > 72 {
+		title = strings.TrimSpace(string(runes[:69])) + "..."
+	}
+	description := fmt.Sprintf("Opened by the worklet's `%s` schedule, which prompted:\n\n%s", schedule.Cron, schedule.Prompt)
+	branchName := fmt.Sprintf("worklet-%s-schedule-%d", worklet.ID, time.Now().Unix())
+	if err := open(ctx, worklet, branchName, title, description); err != nil {
+		return "", fmt.Errorf("failed to open pull request: %w", err)
+	}
+	return worklet.PRURL, nil
+}
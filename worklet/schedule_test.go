@@ -0,0 +1,178 @@
+package worklet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/events"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newScheduleTestAPI(t *testing.T) (*mux.Router, *Manager, *gorm.DB) {
+	_, db := newTestAPI(t)
+	migrateSchedules(db)
+	manager := &Manager{db: db, worklets: make(map[string]*Worklet), webServer: NewWebServer(), events: events.New()}
+	handler := &WorkletHandler{manager: manager}
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router.PathPrefix("/api/worklet").Subrouter())
+	return router, manager, db
+}
+
+func TestCreateScheduleValidates(t *testing.T) {
+	router, _, _ := newScheduleTestAPI(t)
+
+	for name, body := range map[string]string{
+		"bad cron":   `{"cron": "every night", "prompt": "Update dependencies"}`,
+		"never runs": `{"cron": "0 0 30 2 *", "prompt": "Update dependencies"}`,
+		"no prompt":  `{"cron": "0 3 * * *", "prompt": " "}`,
+	} {
+		rr := serve(router, "POST", "/api/worklet/worklets/w1/schedules", "alice", body)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, name)
+	}
+	rr := serve(router, "POST", "/api/worklet/worklets/w1/schedules", "bob", `{"cron": "0 3 * * *", "prompt": "Update dependencies"}`)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestScheduleLifecycle(t *testing.T) {
+	router, manager, _ := newScheduleTestAPI(t)
+
+	rr := serve(router, "POST", "/api/worklet/worklets/w1/schedules", "alice",
+		`{"cron": "0 3 * * *", "prompt": "Update dependencies", "create_pr": true}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var schedule WorkletSchedule
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &schedule))
+	assert.Equal(t, "alice", schedule.UserID)
+	assert.True(t, schedule.CreatePR)
+	assert.Equal(t, 3, schedule.NextRunAt.Hour())
+	assert.True(t, schedule.NextRunAt.After(time.Now()))
+
+	rr = serve(router, "GET", "/api/worklet/worklets/w1/schedules", "alice", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var schedules []WorkletSchedule
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &schedules))
+	require.Len(t, schedules, 1)
+
+	manager.applyPrompt = func(ctx context.Context, worklet *Worklet, workletPrompt *WorkletPrompt) {
+		workletPrompt.Status = "completed"
+	}
+	manager.runSchedule(context.Background(), &schedule)
+
+	rr = serve(router, "GET", "/api/worklet/worklets/w1/schedules/"+schedule.ID+"/runs", "alice", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var runs []WorkletScheduleRun
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &runs))
+	require.Len(t, runs, 1)
+	assert.Equal(t, ScheduleRunSucceeded, runs[0].Status)
+	assert.NotEmpty(t, runs[0].PromptID)
+	assert.NotNil(t, runs[0].FinishedAt)
+	assert.Empty(t, runs[0].PRURL, "nothing changed, so no pull request was opened")
+
+	rr = serve(router, "GET", "/api/worklet/worklets/w3/schedules/"+schedule.ID+"/runs", "alice", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code, "the schedule is w1's")
+	rr = serve(router, "DELETE", "/api/worklet/worklets/w1/schedules/"+schedule.ID, "alice", "")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = serve(router, "DELETE", "/api/worklet/worklets/w1/schedules/"+schedule.ID, "alice", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestScheduleRunOpensPullRequest(t *testing.T) {
+	_, manager, db := newScheduleTestAPI(t)
+	schedule, err := manager.CreateSchedule("w1", CreateScheduleRequest{Cron: "0 3 * * *", Prompt: "Update dependencies", CreatePR: true}, "alice")
+	require.NoError(t, err)
+	finished, unsubscribe := events.Channel(manager.events, events.TopicScheduleRun)
+	defer unsubscribe()
+
+	manager.applyPrompt = func(ctx context.Context, worklet *Worklet, workletPrompt *WorkletPrompt) {
+		workletPrompt.Status = "completed"
+		workletPrompt.FilesChanged = 2
+	}
+	var branch, title string
+	manager.openPR = func(ctx context.Context, worklet *Worklet, branchName, prTitle, description string) error {
+		branch, title = branchName, prTitle
+		worklet.PRURL = "https://github.com/acme/w1/pull/7"
+		return nil
+	}
+	manager.runSchedule(context.Background(), schedule)
+
+	assert.Contains(t, branch, "worklet-w1-schedule-")
+	assert.Equal(t, "Update dependencies", title)
+	var run WorkletScheduleRun
+	require.NoError(t, db.First(&run, "schedule_id = ?", schedule.ID).Error)
+	assert.Equal(t, ScheduleRunSucceeded, run.Status)
+	assert.Equal(t, 2, run.FilesChanged)
+	assert.Equal(t, "https://github.com/acme/w1/pull/7", run.PRURL)
+
+	select {
+	case event := <-finished:
+		assert.Equal(t, run.ID, event.RunID)
+		assert.Equal(t, "alice", event.UserID)
+		assert.Equal(t, ScheduleRunSucceeded, event.Status)
+		assert.Equal(t, run.PRURL, event.PRURL)
+	case <-time.After(time.Second):
+		t.Fatal("no schedule run event was published")
+	}
+}
+
+func TestScheduleRunFailures(t *testing.T) {
+	_, manager, db := newScheduleTestAPI(t)
+	manager.openPR = func(ctx context.Context, worklet *Worklet, branchName, title, description string) error {
+		return errors.New("push rejected")
+	}
+
+	tests := []struct {
+		name      string
+		workletID string
+		apply     func(worklet *Worklet, workletPrompt *WorkletPrompt)
+		want      string
+	}{
+		{"stopped worklet", "w2", nil, "not running"},
+		{"prompt error", "w1", func(worklet *Worklet, workletPrompt *WorkletPrompt) {
+			workletPrompt.Status = "error"
+			workletPrompt.Response = "Failed to process prompt: claude exited"
+		}, "claude exited"},
+		{"tests failed", "w3", func(worklet *Worklet, workletPrompt *WorkletPrompt) {
+			workletPrompt.Status = "completed"
+			worklet.RunTests = true
+			worklet.TestStatus = TestsFailed
+		}, "tests failed"},
+		{"pull request", "w1", func(worklet *Worklet, workletPrompt *WorkletPrompt) {
+			workletPrompt.Status = "completed"
+			workletPrompt.FilesChanged = 1
+		}, "push rejected"},
+	}
+	for _, tt := range tests {
+		schedule, err := manager.CreateSchedule(tt.workletID, CreateScheduleRequest{Cron: "0 3 * * *", Prompt: "Update dependencies", CreatePR: true}, "alice")
+		require.NoError(t, err)
+		manager.applyPrompt = func(ctx context.Context, worklet *Worklet, workletPrompt *WorkletPrompt) {
+			tt.apply(worklet, workletPrompt)
+		}
+		manager.runSchedule(context.Background(), schedule)
+
+		var run WorkletScheduleRun
+		require.NoError(t, db.First(&run, "schedule_id = ?", schedule.ID).Error, tt.name)
+		assert.Equal(t, ScheduleRunFailed, run.Status, tt.name)
+		assert.Contains(t, run.Error, tt.want, tt.name)
+	}
+}
+
+func TestAdvanceSchedule(t *testing.T) {
+	_, manager, db := newScheduleTestAPI(t)
+	schedule, err := manager.CreateSchedule("w1", CreateScheduleRequest{Cron: "30 * * * *", Prompt: "Check links"}, "alice")
+	require.NoError(t, err)
+
+	now := time.Date(2026, 3, 1, 10, 45, 0, 0, time.Local)
+	require.True(t, manager.advanceSchedule(schedule, now))
+
+	var saved WorkletSchedule
+	require.NoError(t, db.First(&saved, "id = ?", schedule.ID).Error)
+	assert.True(t, saved.NextRunAt.Equal(time.Date(2026, 3, 1, 11, 30, 0, 0, time.Local)), saved.NextRunAt)
+	require.NotNil(t, saved.LastRunAt)
+	assert.True(t, saved.LastRunAt.Equal(now))
+}
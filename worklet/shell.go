@@ -0,0 +1,343 @@
+package worklet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/breadchris/flow/models"
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+// Where a shell session was opened from
+const (
+	ShellSourceWebSocket = "websocket"
+	ShellSourceSlack     = "slack"
+)
+
+// shellCommand starts bash when the image has it, or else sh
+var shellCommand = []string{"sh", "-c", "command -v bash >/dev/null && exec bash || exec sh"}
+
+// shellCommandTimeout is how long a one-off command, such as /flow sh runs, may take
+const shellCommandTimeout = 30 * time.Second
+
+// shellRecordingLimit is the most of a shell session that's recorded; the
+// rest of the session isn't
+const shellRecordingLimit = 1 << 20
+
+// errShellRunning is returned for the exit code of a shell still running
+var errShellRunning = errors.New("shell is still running")
+
+// ShellProcess is a process in a worklet's container attached to a terminal.
+// Reads return its output until it exits, and writes are typed into it.
+type ShellProcess interface {
+	io.ReadWriteCloser
+	Resize(rows, cols uint) error
+	ExitCode() (int, error)
+}
+
+// WorkletShellSession is the audit record of an operator's shell in a
+// worklet's container: who opened it, when, and what was typed and shown
+type WorkletShellSession struct {
+	models.Model
+	WorkletID string     `json:"worklet_id" gorm:"index;not null"`
+	UserID    string     `json:"user_id" gorm:"index;not null"`
+	Source    string     `json:"source"`                             // "websocket" or "slack"
+	Command   string     `json:"command,omitempty" gorm:"type:text"` // A one-off command; empty for an interactive shell
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	ExitCode  *int       `json:"exit_code,omitempty"`
+	Recording string     `json:"-" gorm:"type:text"`  // In the asciicast v2 format asciinema plays
+	Truncated bool       `json:"truncated,omitempty"` // The session went on past shellRecordingLimit
+}
+
+// migrateShellSessions creates the shell sessions table, which nothing else migrates
+func migrateShellSessions(db *gorm.DB) {
+	if err := db.AutoMigrate(&WorkletShellSession{}); err != nil {
+		slog.Error("Failed to migrate worklet shell sessions", "error", err)
+	}
+}
+
+// shellRecorder records a terminal session as asciicast v2 events: what was
+// typed ("i"), shown ("o"), and how the terminal was resized ("r")
+type shellRecorder struct {
+	mu        sync.Mutex
+	started   time.Time
+	events    bytes.Buffer
+	truncated bool
+}
+
+func newShellRecorder() *shellRecorder {
+	return &shellRecorder{started: time.Now()}
+}
+
+func (r *shellRecorder) record(kind, data string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.truncated {
+		return
+	}
+	event, _ := json.Marshal([]any{time.Since(r.started).Seconds(), kind, data})
+	if r.events.Len()+len(event) >= shellRecordingLimit {
+		r.truncated = true
+		return
+	}
+	r.events.Write(event)
+	r.events.WriteByte('\n')
+}
+
+// recording returns the asciicast: a header, then a line for each event
+func (r *shellRecorder) recording() (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	header, _ := json.Marshal(map[string]any{"version": 2, "width": 80, "height": 24, "timestamp": r.started.Unix()})
+	return string(header) + "\n" + r.events.String(), r.truncated
+}
+
+// Shell is an operator's interactive shell in a worklet's container. What's
+// typed and shown is recorded, and saved with its session when it's closed.
+type Shell struct {
+	Session *WorkletShellSession
+
+	m        *Manager
+	process  ShellProcess
+	recorder *shellRecorder
+
+	closeOnce sync.Once
+	exitCode  int
+	exitErr   error
+}
+
+func (s *Shell) Read(p []byte) (int, error) {
+	n, err := s.process.Read(p)
+	if n > 0 {
+		s.recorder.record("o", string(p[:n]))
+	}
+	return n, err
+}
+
+func (s *Shell) Write(p []byte) (int, error) {
+	s.recorder.record("i", string(p))
+	return s.process.Write(p)
+}
+
+// Resize sets the size of the shell's terminal
+func (s *Shell) Resize(rows, cols uint) error {
+	s.recorder.record("r", fmt.Sprintf("%dx%d", cols, rows))
+	return s.process.Resize(rows, cols)
+}
+
+// Close disconnects from the shell and saves its session, returning the
+// shell's exit code. It can be called more than once.
+func (s *Shell) Close() (int, error) {
+	s.closeOnce.Do(func() {
+		s.process.Close()
+		s.exitCode, s.exitErr = s.process.ExitCode()
+
+		now := time.Now()
+		s.Session.EndedAt = &now
+		if s.exitErr == nil {
+			s.Session.ExitCode = &s.exitCode
+		}
+		s.Session.Recording, s.Session.Truncated = s.recorder.recording()
+		if err := s.m.db.Save(s.Session).Error; err != nil {
+			slog.Error("Failed to save worklet shell session", "error", err, "workletID", s.Session.WorkletID, "sessionID", s.Session.ID)
+		}
+		slog.Info("Closed worklet shell", "workletID", s.Session.WorkletID, "sessionID", s.Session.ID, "userID", s.Session.UserID,
+			"duration", now.Sub(s.Session.CreatedAt), "action", "worklet_shell_closed")
+	})
+	return s.exitCode, s.exitErr
+}
+
+// shellContainer returns the container of a worklet a shell can be opened
+// in. A worklet whose deployment failed keeps its container, so it can be
+// debugged.
+func (m *Manager) shellContainer(workletID string) (*Worklet, error) {
+	worklet, err := m.GetWorklet(workletID)
+	if err != nil {
+		return nil, err
+	}
+	if worklet.ContainerID == "" {
+		return nil, ErrNoContainer
+	}
+	if worklet.Status == StatusSleeping || worklet.Status == StatusStopped {
+		return nil, ErrNotRunning
+	}
+	return worklet, nil
+}
+
+// OpenShell starts an interactive shell in a worklet's container for userID,
+// an operator. Its session is recorded from the start, so it's in the audit
+// trail even if it's never closed.
+func (m *Manager) OpenShell(ctx context.Context, workletID, userID string) (*Shell, error) {
+	worklet, err := m.shellContainer(workletID)
+	if err != nil {
+		return nil, err
+	}
+
+	open := m.openShell
+	if open == nil {
+		open = m.dockerClient.Shell
+	}
+	process, err := open(ctx, worklet.ContainerID, shellCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start shell: %w", err)
+	}
+	session := &WorkletShellSession{
+		Model:     models.Model{ID: generateID()},
+		WorkletID: worklet.ID,
+		UserID:    userID,
+		Source:    ShellSourceWebSocket,
+	}
+	if err := m.db.Create(session).Error; err != nil {
+		process.Close()
+		return nil, fmt.Errorf("failed to record shell session: %w", err)
+	}
+
+	slog.Info("Opened worklet shell", "workletID", worklet.ID, "sessionID", session.ID, "userID", userID, "action", "worklet_shell_opened")
+	return &Shell{Session: session, m: m, process: process, recorder: newShellRecorder()}, nil
+}
+
+// RunShellCommand runs a one-off command with sh -c in a worklet's container
+// for userID, an operator, and returns its output and exit code. It's
+// recorded like a shell.
+func (m *Manager) RunShellCommand(ctx context.Context, workletID, userID, command string) (string, int, error) {
+	worklet, err := m.shellContainer(workletID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, shellCommandTimeout)
+	defer cancel()
+	exec := m.execInContainer
+	if exec == nil {
+		exec = m.dockerClient.Exec
+	}
+	recorder := newShellRecorder()
+	recorder.record("i", command+"\n")
+	output, exitCode, err := exec(ctx, worklet.ContainerID, []string{"sh", "-c", command})
+	recorder.record("o", output)
+
+	now := time.Now()
+	session := &WorkletShellSession{
+		Model:     models.Model{ID: generateID()},
+		WorkletID: worklet.ID,
+		UserID:    userID,
+		Source:    ShellSourceSlack,
+		Command:   command,
+		EndedAt:   &now,
+	}
+	if err == nil {
+		session.ExitCode = &exitCode
+	}
+	session.Recording, session.Truncated = recorder.recording()
+	if dbErr := m.db.Create(session).Error; dbErr != nil {
+		slog.Error("Failed to record worklet shell command", "error", dbErr, "workletID", worklet.ID)
+	}
+
+	slog.Info("Ran command in worklet shell", "workletID", worklet.ID, "sessionID", session.ID, "userID", userID,
+		"exitCode", exitCode, "error", err, "action", "worklet_shell_command")
+	return output, exitCode, err
+}
+
+// ListShellSessions returns a worklet's shell sessions, newest first, without
+// their recordings
+func (m *Manager) ListShellSessions(workletID string) ([]*WorkletShellSession, error) {
+	sessions := []*WorkletShellSession{}
+	if err := m.db.Omit("recording").Where("worklet_id = ?", workletID).Order("created_at DESC").Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list shell sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// GetShellSession returns one of a worklet's shell sessions with its recording
+func (m *Manager) GetShellSession(workletID, sessionID string) (*WorkletShellSession, error) {
+	var session WorkletShellSession
+	if err := m.db.First(&session, "id = ? AND worklet_id = ?", sessionID, workletID).Error; err != nil {
+		return nil, fmt.Errorf("shell session not found: %w", err)
+	}
+	return &session, nil
+}
+
+// shellMessage is a message a shell's client sends as text. Keystrokes may
+// also be sent as binary messages.
+type shellMessage struct {
+	Type string `json:"type"` // "input" or "resize"
+	Data string `json:"data"`
+	Rows uint   `json:"rows"`
+	Cols uint   `json:"cols"`
+}
+
+// shellUpgrader accepts shells from pages on the same host only, so another
+// site can't open one with an operator's credentials
+var shellUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		u, err := url.Parse(origin)
+		return err == nil && u.Host == r.Host
+	},
+}
+
+// relayShell connects a WebSocket to a shell until either ends. The shell's
+// output is sent as binary messages, and when it exits the connection is
+// closed with its exit code as the reason.
+func relayShell(conn *websocket.Conn, shell *Shell) {
+	defer conn.Close()
+
+	go func() {
+		// Disconnecting closes the shell, which ends its output
+		defer shell.Close()
+		for {
+			kind, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if kind == websocket.BinaryMessage {
+				shell.Write(data)
+				continue
+			}
+			var msg shellMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				continue
+			}
+			switch msg.Type {
+			case "input":
+				shell.Write([]byte(msg.Data))
+			case "resize":
+				if msg.Rows > 0 && msg.Cols > 0 {
+					shell.Resize(msg.Rows, msg.Cols)
+				}
+			}
+		}
+	}()
+
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := shell.Read(buf)
+		if n > 0 {
+			if conn.WriteMessage(websocket.BinaryMessage, buf[:n]) != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	reason := "shell closed"
+	if exitCode, err := shell.Close(); err == nil {
+		reason = fmt.Sprintf("exited with code %d", exitCode)
+	}
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, reason), time.Now().Add(time.Second))
+}
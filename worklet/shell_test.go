@@ -0,0 +1,201 @@
+package worklet
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/deps"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// fakeShell echoes each line typed into it and exits with code 3 on "exit"
+type fakeShell struct {
+	in      *io.PipeWriter
+	out     *io.PipeReader
+	resized chan [2]uint
+}
+
+func newFakeShell() *fakeShell {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	go func() {
+		lines := bufio.NewScanner(inReader)
+		for lines.Scan() {
+			if lines.Text() == "exit" {
+				break
+			}
+			io.WriteString(outWriter, "$ "+lines.Text()+"\r\n")
+		}
+		outWriter.Close()
+	}()
+	return &fakeShell{in: inWriter, out: outReader, resized: make(chan [2]uint, 1)}
+}
+
+func (s *fakeShell) Read(p []byte) (int, error)  { return s.out.Read(p) }
+func (s *fakeShell) Write(p []byte) (int, error) { return s.in.Write(p) }
+func (s *fakeShell) Close() error                { s.out.Close(); return s.in.Close() }
+func (s *fakeShell) ExitCode() (int, error)      { return 3, nil }
+func (s *fakeShell) Resize(rows, cols uint) error {
+	s.resized <- [2]uint{rows, cols}
+	return nil
+}
+
+// testSessionHeader stands in for the session cookie in shell tests
+const testSessionHeader = "X-Test-Session"
+
+// newShellTestAPI serves the worklet API with "ops" as an operator and w1 and
+// w3 having containers. Requests are signed in as the user in
+// testSessionHeader; see serveSignedIn.
+func newShellTestAPI(t *testing.T) (*mux.Router, *Manager, *gorm.DB) {
+	_, db := newTestAPI(t)
+	require.NoError(t, db.AutoMigrate(&WorkletShellSession{}))
+	require.NoError(t, db.Model(&Worklet{}).Where("id IN ?", []string{"w1", "w3"}).UpdateColumn("container_id", "container").Error)
+	manager := &Manager{db: db, worklets: make(map[string]*Worklet), webServer: NewWebServer()}
+	handler := &WorkletHandler{
+		manager: manager,
+		deps:    &deps.Deps{Config: config.AppConfig{Admins: []string{"ops"}}},
+		signedIn: func(r *http.Request) (string, error) {
+			if userID := r.Header.Get(testSessionHeader); userID != "" {
+				return userID, nil
+			}
+			return "", errors.New("not signed in")
+		},
+	}
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router.PathPrefix("/api/worklet").Subrouter())
+	return router, manager, db
+}
+
+// serveSignedIn serves a request from a user who signed in
+func serveSignedIn(router http.Handler, method, path, user string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set(testSessionHeader, user)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestShellRequiresOperator(t *testing.T) {
+	router, _, _ := newShellTestAPI(t)
+
+	// The X-User-ID header doesn't sign anyone in
+	for _, path := range []string{"/shell", "/shell/sessions", "/shell/sessions/s1"} {
+		rr := serve(router, "GET", "/api/worklet/worklets/w1"+path, "ops", "")
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, path)
+	}
+
+	rr := serveSignedIn(router, "GET", "/api/worklet/worklets/w1/shell", "alice")
+	assert.Equal(t, http.StatusForbidden, rr.Code, "owning the worklet isn't enough")
+	rr = serveSignedIn(router, "GET", "/api/worklet/worklets/w1/shell/sessions", "alice")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = serveSignedIn(router, "GET", "/api/worklet/worklets/missing/shell", "ops")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = serveSignedIn(router, "GET", "/api/worklet/worklets/w2/shell", "ops")
+	assert.Equal(t, http.StatusConflict, rr.Code, "w2 has no container")
+}
+
+func TestShellSessionIsRecorded(t *testing.T) {
+	router, manager, _ := newShellTestAPI(t)
+	shell := newFakeShell()
+	manager.openShell = func(ctx context.Context, containerID string, cmd []string) (ShellProcess, error) {
+		assert.Equal(t, "container", containerID)
+		return shell, nil
+	}
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/worklet/worklets/w1/shell"
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{testSessionHeader: {"ops"}})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"resize","rows":40,"cols":120}`)))
+	assert.Equal(t, [2]uint{40, 120}, <-shell.resized)
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, []byte("ls /app\n")))
+	kind, output, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, websocket.BinaryMessage, kind)
+	assert.Equal(t, "$ ls /app\r\n", string(output))
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"input","data":"exit\n"}`)))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, "exited with code 3", closeErr.Text)
+
+	// The session is saved once the shell has closed
+	var sessions []WorkletShellSession
+	require.Eventually(t, func() bool {
+		rr := serveSignedIn(router, "GET", "/api/worklet/worklets/w1/shell/sessions", "ops")
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &sessions))
+		return len(sessions) == 1 && sessions[0].EndedAt != nil
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "ops", sessions[0].UserID)
+	assert.Equal(t, ShellSourceWebSocket, sessions[0].Source)
+	require.NotNil(t, sessions[0].ExitCode)
+	assert.Equal(t, 3, *sessions[0].ExitCode)
+
+	rr := serveSignedIn(router, "GET", "/api/worklet/worklets/w1/shell/sessions/"+sessions[0].ID, "ops")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/x-asciicast", rr.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	require.Len(t, lines, 5, rr.Body.String())
+	assert.Contains(t, lines[0], `"version":2`)
+	assert.Contains(t, lines[1], `"r","120x40"]`)
+	assert.Contains(t, lines[2], `"i","ls /app\n"]`)
+	assert.Contains(t, lines[3], `"o","$ ls /app\r\n"]`)
+	assert.Contains(t, lines[4], `"i","exit\n"]`)
+
+	rr = serveSignedIn(router, "GET", "/api/worklet/worklets/w3/shell/sessions/"+sessions[0].ID, "ops")
+	assert.Equal(t, http.StatusNotFound, rr.Code, "the session is w1's")
+}
+
+func TestRunShellCommand(t *testing.T) {
+	_, manager, db := newShellTestAPI(t)
+	manager.execInContainer = func(ctx context.Context, containerID string, cmd []string) (string, int, error) {
+		assert.Equal(t, []string{"sh", "-c", "cat missing"}, cmd)
+		return "cat: missing: No such file or directory\n", 1, nil
+	}
+
+	output, exitCode, err := manager.RunShellCommand(context.Background(), "w3", "UADMIN", "cat missing")
+	require.NoError(t, err)
+	assert.Equal(t, 1, exitCode)
+	assert.Contains(t, output, "No such file")
+
+	var session WorkletShellSession
+	require.NoError(t, db.First(&session, "worklet_id = ?", "w3").Error)
+	assert.Equal(t, ShellSourceSlack, session.Source)
+	assert.Equal(t, "cat missing", session.Command)
+	assert.Equal(t, "UADMIN", session.UserID)
+	require.NotNil(t, session.ExitCode)
+	assert.Equal(t, 1, *session.ExitCode)
+	assert.Contains(t, session.Recording, "No such file")
+
+	_, _, err = manager.RunShellCommand(context.Background(), "w2", "UADMIN", "ls")
+	assert.ErrorIs(t, err, ErrNoContainer)
+}
+
+func TestShellRecorderLimit(t *testing.T) {
+	recorder := newShellRecorder()
+	chunk := strings.Repeat("x", 64<<10)
+	for range 32 {
+		recorder.record("o", chunk)
+	}
+	recording, truncated := recorder.recording()
+	assert.True(t, truncated)
+	assert.Less(t, len(recording), shellRecordingLimit+1024)
+}
@@ -0,0 +1,175 @@
+package worklet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"regexp"
+	"strings"
+
+	"github.com/breadchris/flow/models"
+	"gorm.io/gorm"
+)
+
+// Errors for worklet templates
+var (
+	ErrTemplateExists   = errors.New("a template with that name already exists")
+	ErrTemplateNotOwned = errors.New("only the template's creator can delete it")
+)
+
+// templateNameRegex matches template names, which are single words so they
+// can be typed in /flow template <name> <prompt>
+var templateNameRegex = regexp.MustCompile(`^[\w.-]+$`)
+
+// WorkletTemplate is a repository, branch, base prompt, and environment that
+// anyone can start worklets from
+type WorkletTemplate struct {
+	models.Model
+	Name        string                               `json:"name" gorm:"uniqueIndex;not null"`
+	Description string                               `json:"description"`
+	GitRepo     string                               `json:"git_repo" gorm:"not null"`
+	Branch      string                               `json:"branch"` // The repository's default branch when empty
+	BasePrompt  string                               `json:"base_prompt" gorm:"type:text"`
+	Environment *models.JSONField[map[string]string] `json:"environment"` // Defaults a worklet's environment can add to
+	CreatedBy   string                               `json:"created_by" gorm:"index;not null"`
+}
+
+// CreateTemplateRequest defines a template
+type CreateTemplateRequest struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	GitRepo     string            `json:"git_repo"`
+	Branch      string            `json:"branch"`
+	BasePrompt  string            `json:"base_prompt"`
+	Environment map[string]string `json:"environment"`
+}
+
+// Validate rejects templates without a usable name or repository
+func (r CreateTemplateRequest) Validate() error {
+	if !templateNameRegex.MatchString(r.Name) {
+		return fmt.Errorf("template name %q must be one word of letters, digits, '.', '_', or '-'", r.Name)
+	}
+	if strings.TrimSpace(r.GitRepo) == "" {
+		return errors.New("git repository is required")
+	}
+	if err := validateCheckout(CreateWorkletRequest{Branch: r.Branch}); err != nil {
+		return err
+	}
+	return validateEnvironment(r.Environment)
+}
+
+// TemplateWorkletRequest starts a worklet from a template
+type TemplateWorkletRequest struct {
+	Name        string            `json:"name"`        // The template's name when empty
+	Prompt      string            `json:"prompt"`      // Follows the template's base prompt
+	Environment map[string]string `json:"environment"` // Added to the template's environment
+}
+
+// workletRequest returns the request that creates a worklet from the template
+func (t *WorkletTemplate) workletRequest(req TemplateWorkletRequest) CreateWorkletRequest {
+	env := make(map[string]string)
+	if t.Environment != nil {
+		maps.Copy(env, t.Environment.Data)
+	}
+	maps.Copy(env, req.Environment)
+	name := req.Name
+	if name == "" {
+		name = t.Name
+	}
+	var prompts []string
+	for _, prompt := range []string{t.BasePrompt, req.Prompt} {
+		if prompt = strings.TrimSpace(prompt); prompt != "" {
+			prompts = append(prompts, prompt)
+		}
+	}
+
+	return CreateWorkletRequest{
+		Name:        name,
+		Description: fmt.Sprintf("Started from the %s template", t.Name),
+		GitRepo:     t.GitRepo,
+		Branch:      t.Branch,
+		BasePrompt:  strings.Join(prompts, "\n\n"),
+		Environment: env,
+	}
+}
+
+// migrateTemplates creates the templates table, which nothing else migrates
+func migrateTemplates(db *gorm.DB) {
+	if err := db.AutoMigrate(&WorkletTemplate{}); err != nil {
+		slog.Error("Failed to migrate worklet templates", "error", err)
+	}
+}
+
+// CreateTemplate saves a template for everyone to use
+func (m *Manager) CreateTemplate(req CreateTemplateRequest, userID string) (*WorkletTemplate, error) {
+	var count int64
+	if err := m.db.Model(&WorkletTemplate{}).Where("name = ?", req.Name).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check template name: %w", err)
+	}
+	if count > 0 {
+		return nil, ErrTemplateExists
+	}
+
+	template := &WorkletTemplate{
+		Model:       models.Model{ID: generateID()},
+		Name:        req.Name,
+		Description: req.Description,
+		GitRepo:     req.GitRepo,
+		Branch:      req.Branch,
+		BasePrompt:  req.BasePrompt,
+		Environment: models.MakeJSONField(req.Environment),
+		CreatedBy:   userID,
+	}
+	if err := m.db.Create(template).Error; err != nil {
+		return nil, fmt.Errorf("failed to create template: %w", err)
+	}
+	slog.Info("Created worklet template", "template", template.Name, "userID", userID, "action", "worklet_template_created")
+	return template, nil
+}
+
+// ListTemplates returns every template, by name
+func (m *Manager) ListTemplates() ([]*WorkletTemplate, error) {
+	templates := []*WorkletTemplate{}
+	if err := m.db.Order("name").Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	return templates, nil
+}
+
+// GetTemplate returns the template with the name
+func (m *Manager) GetTemplate(name string) (*WorkletTemplate, error) {
+	var template WorkletTemplate
+	if err := m.db.First(&template, "name = ?", name).Error; err != nil {
+		return nil, fmt.Errorf("template not found: %w", err)
+	}
+	return &template, nil
+}
+
+// DeleteTemplate deletes a template userID created. Worklets started from it
+// are left running.
+func (m *Manager) DeleteTemplate(name, userID string) error {
+	template, err := m.GetTemplate(name)
+	if err != nil {
+		return err
+	}
+	if template.CreatedBy != userID {
+		return ErrTemplateNotOwned
+	}
+	// Deleted for good so the name can be used again
+	if err := m.db.Unscoped().Delete(template).Error; err != nil {
+		return fmt.Errorf("failed to delete template: %w", err)
+	}
+	slog.Info("Deleted worklet template", "template", name, "userID", userID, "action", "worklet_template_deleted")
+	return nil
+}
+
+// CreateFromTemplate creates a worklet for userID from the named template
+func (m *Manager) CreateFromTemplate(ctx context.Context, name string, req TemplateWorkletRequest, userID string) (*Worklet, error) {
+	template, err := m.GetTemplate(name)
+	if err != nil {
+		return nil, err
+	}
+	return m.CreateWorklet(ctx, template.workletRequest(req), userID)
+}
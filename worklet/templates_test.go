@@ -0,0 +1,185 @@
+package worklet
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/breadchris/flow/deps"
+	"github.com/breadchris/flow/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestTemplates(t *testing.T) {
+	router, _ := newTestAPI(t)
+
+	rr := serve(router, "POST", "/api/worklet/templates", "alice",
+		`{"name":"landing","git_repo":"https://github.com/acme/site","branch":"main","base_prompt":"Use our brand colors","environment":{"THEME":"dark"}}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = serve(router, "POST", "/api/worklet/templates", "bob", `{"name":"landing","git_repo":"https://github.com/bob/site"}`)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	rr = serve(router, "POST", "/api/worklet/templates", "bob", `{"name":"two words","git_repo":"https://github.com/bob/site"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = serve(router, "POST", "/api/worklet/templates", "bob", `{"name":"norepo"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = serve(router, "GET", "/api/worklet/templates/landing", "bob", "")
+	require.Equal(t, http.StatusOK, rr.Code, "templates are shared")
+	var template WorkletTemplate
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &template))
+	assert.Equal(t, "https://github.com/acme/site", template.GitRepo)
+	assert.Equal(t, map[string]string{"THEME": "dark"}, template.Environment.Data)
+	assert.Equal(t, "alice", template.CreatedBy)
+
+	rr = serve(router, "GET", "/api/worklet/templates", "bob", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var templates []WorkletTemplate
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &templates))
+	assert.Len(t, templates, 1)
+
+	rr = serve(router, "DELETE", "/api/worklet/templates/landing", "bob", "")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = serve(router, "DELETE", "/api/worklet/templates/landing", "alice", "")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = serve(router, "GET", "/api/worklet/templates/landing", "alice", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = serve(router, "POST", "/api/worklet/templates", "bob", `{"name":"landing","git_repo":"https://github.com/bob/site"}`)
+	assert.Equal(t, http.StatusOK, rr.Code, "a deleted template's name can be used again")
+
+	rr = serve(router, "POST", "/api/worklet/templates/missing/worklets", "alice", `{"prompt":"Add a pricing page"}`)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestTemplateWorkletRequest(t *testing.T) {
+	template := &WorkletTemplate{
+		Name:        "landing",
+		GitRepo:     "https://github.com/acme/site",
+		Branch:      "main",
+		BasePrompt:  "Use our brand colors",
+		Environment: models.MakeJSONField(map[string]string{"THEME": "dark", "API_URL": "https://api.example.com"}),
+	}
+
+	req := template.workletRequest(TemplateWorkletRequest{
+		Prompt:      "Add a pricing page",
+		Environment: map[string]string{"THEME": "light"},
+	})
+	assert.Equal(t, "landing", req.Name)
+	assert.Equal(t, "https://github.com/acme/site", req.GitRepo)
+	assert.Equal(t, "main", req.Branch)
+	assert.Equal(t, "Use our brand colors\n\nAdd a pricing page", req.BasePrompt)
+	assert.Equal(t, map[string]string{"THEME": "light", "API_URL": "https://api.example.com"}, req.Environment)
+	assert.Equal(t, "dark", template.Environment.Data["THEME"], "the template's defaults aren't changed")
+
+	req = template.workletRequest(TemplateWorkletRequest{Name: "Pricing"})
+	assert.Equal(t, "Pricing", req.Name)
+	assert.Equal(t, "Use our brand colors", req.BasePrompt)
+}
+
+func TestNewClone(t *testing.T) {
+	source := &Worklet{
+		Model:               models.Model{ID: "w1"},
+		Name:                "Dashboard",
+		Status:              StatusRunning,
+		GitRepo:             "https://github.com/acme/dashboard",
+		Branch:              "main",
+		Ref:                 "v1.2.0",
+		BasePrompt:          "Add charts",
+		Environment:         models.MakeJSONField(map[string]string{"A": "1"}),
+		UserID:              "alice",
+		SessionID:           "session",
+		ContainerID:         "container",
+		Port:                20001,
+		PreviewAccess:       PreviewBasic,
+		PreviewToken:        "token",
+		PreviewUsername:     "demo",
+		PreviewPasswordHash: "hash",
+		AllowedTools:        models.MakeJSONField([]string{"Read"}),
+		RunTests:            true,
+		TestCommand:         "make test",
+		TestStatus:          TestsFailed,
+		PRURL:               "https://github.com/acme/dashboard/pull/1",
+	}
+
+	clone := newClone(source, CloneWorkletRequest{Environment: map[string]string{"B": "2"}}, "bob")
+	assert.NotEqual(t, source.ID, clone.ID)
+	assert.Equal(t, "Dashboard (copy)", clone.Name)
+	assert.Equal(t, StatusCreating, clone.Status)
+	assert.Equal(t, "bob", clone.UserID)
+	assert.Equal(t, source.GitRepo, clone.GitRepo)
+	assert.Equal(t, "v1.2.0", clone.Ref)
+	assert.Equal(t, "Add charts", clone.BasePrompt)
+	assert.Equal(t, map[string]string{"A": "1", "B": "2"}, clone.Environment.Data)
+	assert.Equal(t, map[string]string{"A": "1"}, source.Environment.Data)
+	assert.Equal(t, []string{"Read"}, clone.AllowedTools.Data)
+	assert.Equal(t, "make test", clone.TestCommand)
+	assert.True(t, clone.RunTests)
+	assert.Equal(t, PreviewBasic, clone.PreviewAccess)
+	assert.Equal(t, "hash", clone.PreviewPasswordHash)
+
+	// What belongs to the original's deployment isn't copied
+	assert.NotEqual(t, source.SessionID, clone.SessionID)
+	assert.NotEqual(t, source.PreviewToken, clone.PreviewToken)
+	assert.Empty(t, clone.ContainerID)
+	assert.Zero(t, clone.Port)
+	assert.Empty(t, clone.TestStatus)
+	assert.Empty(t, clone.PRURL)
+
+	prompt := ""
+	clone = newClone(source, CloneWorkletRequest{Name: "Fresh", BasePrompt: &prompt}, "alice")
+	assert.Equal(t, "Fresh", clone.Name)
+	assert.Empty(t, clone.BasePrompt)
+}
+
+func TestCloneHasItsOwnCheckout(t *testing.T) {
+	remote, commits := newTestRemote(t)
+	manager := &Manager{gitClient: &GitClient{baseDir: t.TempDir()}}
+	source := &Worklet{Model: models.Model{ID: "w1"}, GitRepo: remote, Branch: "trunk", CommitSHA: commits[2]}
+	clone := newClone(source, CloneWorkletRequest{}, "alice")
+	clone.CommitSHA = commits[2]
+
+	sourcePath, _, err := manager.gitClient.Checkout(source.checkoutSpec())
+	require.NoError(t, err)
+	clonePath, _, err := manager.gitClient.Checkout(clone.checkoutSpec())
+	require.NoError(t, err)
+	assert.NotEqual(t, sourcePath, clonePath)
+
+	// A prompt to the clone edits its checkout alone
+	writeFiles(t, clonePath, map[string]string{"index.html": "<h1>Clone</h1>\n"})
+	diff, err := manager.Diff(context.Background(), clone)
+	require.NoError(t, err)
+	assert.Equal(t, []FileDiff{{Path: "index.html", Additions: 1}}, diff.Files)
+	diff, err = manager.Diff(context.Background(), source)
+	require.NoError(t, err)
+	assert.Empty(t, diff.Files, "the clone's edits don't show up in the original")
+	assert.NoFileExists(t, filepath.Join(sourcePath, "index.html"))
+}
+
+func TestCloneWorkletErrors(t *testing.T) {
+	router, _ := newTestAPI(t)
+
+	rr := serve(router, "POST", "/api/worklet/worklets/w4/clone", "alice", "")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = serve(router, "POST", "/api/worklet/worklets/missing/clone", "alice", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = serve(router, "POST", "/api/worklet/w1/clone", "alice", `{"environment":{"BAD NAME":"x"}}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestNewServeMux(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&Worklet{}))
+
+	var mux *http.ServeMux
+	require.NotPanics(t, func() { mux = New(&deps.Deps{DB: db}) }, "the routes' patterns mustn't conflict")
+	rr := serve(mux, "GET", "/templates", "alice", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `[]`, rr.Body.String())
+}
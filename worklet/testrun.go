@@ -0,0 +1,154 @@
+package worklet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/breadchris/flow/events"
+)
+
+// TestStatus is the result of a worklet's last test run
+type TestStatus string
+
+const (
+	TestsRunning TestStatus = "running"
+	TestsPassed  TestStatus = "passed"
+	TestsFailed  TestStatus = "failed"
+)
+
+// Errors for pull requests that wait on a worklet's tests
+var (
+	ErrTestsFailed  = errors.New("worklet tests failed")
+	ErrTestsRunning = errors.New("worklet tests are still running")
+)
+
+// maxTestOutput limits how much of a test run's output is kept; the end is
+// kept, since that's where failures are summed up
+const maxTestOutput = 64 << 10
+
+// defaultTestTimeout is how long tests may run without a configured timeout
+const defaultTestTimeout = 10 * time.Minute
+
+// makeTestTarget matches a Makefile's test target
+var makeTestTarget = regexp.MustCompile(`(?m)^test\s*:([^=]|$)`)
+
+// DetectTestCommand returns the command that runs the repository's tests:
+// npm test for a package.json with a test script, make test for a Makefile
+// with a test target, or go test for a Go module. It returns "" if there's
+// none of them.
+func DetectTestCommand(repoPath string) string {
+	if data, err := os.ReadFile(filepath.Join(repoPath, "package.json")); err == nil {
+		var pkg struct {
+			Scripts map[string]string `json:"scripts"`
+		}
+		// npm init's placeholder script only fails
+		if json.Unmarshal(data, &pkg) == nil && pkg.Scripts["test"] != "" &&
+			!strings.Contains(pkg.Scripts["test"], "no test specified") {
+			return "npm test"
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(repoPath, "Makefile")); err == nil && makeTestTarget.Match(data) {
+		return "make test"
+	}
+	if _, err := os.Stat(filepath.Join(repoPath, "go.mod")); err == nil {
+		return "go test ./..."
+	}
+	return ""
+}
+
+// runTests runs the worklet's test command in its container and records the
+// result on the worklet. Worklets without a command of their own that
+// DetectTestCommand doesn't find one for aren't tested.
+func (m *Manager) runTests(ctx context.Context, worklet *Worklet, repoPath string) {
+	command := worklet.TestCommand
+	if command == "" {
+		command = DetectTestCommand(repoPath)
+	}
+	if command == "" {
+		slog.Info("No test command found, skipping tests", "workletID", worklet.ID)
+		return
+	}
+
+	m.recordTests(worklet, TestsRunning, "")
+	logLine := m.publishLog(worklet)
+	logLine("test", "$ "+command)
+
+	ctx, cancel := context.WithTimeout(ctx, m.testTimeout())
+	defer cancel()
+	exec := m.execInContainer
+	if exec == nil {
+		exec = m.dockerClient.Exec
+	}
+	output, exitCode, err := exec(ctx, worklet.ContainerID, []string{"sh", "-c", command})
+	forEachLine(output, func(line string) { logLine("test", line) })
+
+	status := TestsPassed
+	switch {
+	case err != nil:
+		status = TestsFailed
+		output += fmt.Sprintf("\nFailed to run tests: %v\n", err)
+	case exitCode != 0:
+		status = TestsFailed
+		output += fmt.Sprintf("\nTests exited with status %d\n", exitCode)
+	}
+	if len(output) > maxTestOutput {
+		output = output[len(output)-maxTestOutput:]
+	}
+	m.recordTests(worklet, status, output)
+
+	slog.Info("Ran worklet tests",
+		"workletID", worklet.ID,
+		"command", command,
+		"result", status,
+		"exitCode", exitCode,
+		"action", "worklet_tests",
+	)
+	event := events.WorkletEvent{Type: events.WorkletTestsPassed}
+	if status == TestsFailed {
+		event = events.WorkletEvent{Type: events.WorkletTestsFailed, Error: fmt.Sprintf("%s failed", command)}
+	}
+	m.publishEvent(worklet, event)
+}
+
+// recordTests saves the status and output of the worklet's test run
+func (m *Manager) recordTests(worklet *Worklet, status TestStatus, output string) {
+	now := time.Now()
+	worklet.TestStatus = status
+	worklet.TestOutput = output
+	worklet.TestedAt = &now
+	if err := m.db.Model(worklet).Updates(map[string]any{
+		"test_status": status,
+		"test_output": output,
+		"tested_at":   now,
+	}).Error; err != nil {
+		slog.Error("Failed to record test results", "error", err, "workletID", worklet.ID)
+	}
+}
+
+// testTimeout is how long a test run may take
+func (m *Manager) testTimeout() time.Duration {
+	if m.deps != nil && m.deps.Config.Worklet.TestTimeout > 0 {
+		return m.deps.Config.Worklet.TestTimeout
+	}
+	return defaultTestTimeout
+}
+
+// checkTests returns why the worklet's tests keep a pull request from being
+// opened, if they do
+func checkTests(worklet *Worklet) error {
+	switch worklet.TestStatus {
+	case TestsFailed:
+		return ErrTestsFailed
+	case TestsRunning:
+		return ErrTestsRunning
+	}
+	return nil
+}
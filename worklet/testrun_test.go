@@ -0,0 +1,126 @@
+package worklet
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/breadchris/flow/events"
+	"github.com/breadchris/flow/models"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestDetectTestCommand(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"npm", map[string]string{"package.json": `{"scripts":{"test":"jest"}}`, "go.mod": "module x"}, "npm test"},
+		{"npm placeholder", map[string]string{"package.json": `{"scripts":{"test":"echo \"Error: no test specified\" && exit 1"}}`}, ""},
+		{"make", map[string]string{"Makefile": "build:\n\tgo build\n\ntest: build\n\tgo test ./...\n", "go.mod": "module x"}, "make test"},
+		{"make variable", map[string]string{"Makefile": "test:=unit\n"}, ""},
+		{"go", map[string]string{"go.mod": "module x", "Makefile": "build:\n\tgo build\n"}, "go test ./..."},
+		{"none", map[string]string{"index.html": "<h1>Hi</h1>"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			assert.Equal(t, tt.want, DetectTestCommand(dir))
+		})
+	}
+}
+
+// newTestRunManager returns a manager whose commands in containers exit with
+// exitCode, and the worklet it tests
+func newTestRunManager(t *testing.T, exitCode int, execErr error) (*Manager, *Worklet) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&Worklet{}))
+	worklet := &Worklet{Model: models.Model{ID: "w1"}, Status: StatusRunning, ContainerID: "c1", RunTests: true}
+	require.NoError(t, db.Create(worklet).Error)
+
+	manager := &Manager{db: db, worklets: map[string]*Worklet{"w1": worklet}, events: events.New(),
+		execInContainer: func(ctx context.Context, containerID string, cmd []string) (string, int, error) {
+			assert.Equal(t, "c1", containerID)
+			return "ran " + strings.Join(cmd, " ") + "\n", exitCode, execErr
+		}}
+	return manager, worklet
+}
+
+func TestRunTests(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"go.mod": "module x"})
+
+	t.Run("passed", func(t *testing.T) {
+		manager, worklet := newTestRunManager(t, 0, nil)
+		lifecycle, unsubscribe := events.Channel(manager.events, events.TopicWorkletEvent)
+		defer unsubscribe()
+
+		manager.runTests(context.Background(), worklet, dir)
+		assert.Equal(t, TestsPassed, worklet.TestStatus)
+		assert.Equal(t, "ran sh -c go test ./...\n", worklet.TestOutput)
+		assert.NotNil(t, worklet.TestedAt)
+		assert.Equal(t, events.WorkletTestsPassed, (<-lifecycle).Type)
+
+		var saved Worklet
+		require.NoError(t, manager.db.First(&saved, "id = ?", "w1").Error)
+		assert.Equal(t, TestsPassed, saved.TestStatus)
+
+		// The output is in the worklet's logs too
+		lines := manager.LogTail(worklet, 0, 0)
+		require.Len(t, lines, 2)
+		assert.Equal(t, "test", lines[0].Stage)
+		assert.Equal(t, "$ go test ./...", lines[0].Line)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		manager, worklet := newTestRunManager(t, 2, nil)
+		worklet.TestCommand = "make check"
+
+		manager.runTests(context.Background(), worklet, dir)
+		assert.Equal(t, TestsFailed, worklet.TestStatus)
+		assert.Contains(t, worklet.TestOutput, "ran sh -c make check")
+		assert.Contains(t, worklet.TestOutput, "Tests exited with status 2")
+	})
+
+	t.Run("couldn't run", func(t *testing.T) {
+		manager, worklet := newTestRunManager(t, 0, errors.New("no such container"))
+
+		manager.runTests(context.Background(), worklet, dir)
+		assert.Equal(t, TestsFailed, worklet.TestStatus)
+		assert.Contains(t, worklet.TestOutput, "no such container")
+	})
+
+	t.Run("no command", func(t *testing.T) {
+		manager, worklet := newTestRunManager(t, 0, nil)
+
+		manager.runTests(context.Background(), worklet, t.TempDir())
+		assert.Empty(t, worklet.TestStatus)
+	})
+}
+
+func TestCreatePRBlockedByTests(t *testing.T) {
+	manager, worklet := newTestRunManager(t, 0, nil)
+	handler := &WorkletHandler{manager: manager}
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router.PathPrefix("/api/worklet").Subrouter())
+
+	for status, err := range map[TestStatus]error{TestsFailed: ErrTestsFailed, TestsRunning: ErrTestsRunning} {
+		worklet.TestStatus = status
+		assert.ErrorIs(t, manager.CreatePR(context.Background(), worklet, "fix", "Fix", "", false), err)
+	}
+
+	worklet.UserID = "alice"
+	worklet.TestStatus = TestsFailed
+	rr := serve(router, "POST", "/api/worklet/worklets/w1/pr", "alice", `{"title":"Fix"}`)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, "conflict", errorCode(t, rr))
+}
@@ -12,12 +12,14 @@ import (
 
 type WebServer struct {
 	proxies map[string]*httputil.ReverseProxy
+	targets map[string]string // URL each worklet's proxy forwards to
 	mu      sync.RWMutex
 }
 
 func NewWebServer() *WebServer {
 	return &WebServer{
 		proxies: make(map[string]*httputil.ReverseProxy),
+		targets: make(map[string]string),
 	}
 }
 
@@ -44,10 +46,24 @@ func (ws *WebServer) CreateProxy(workletID string, targetURL string) error {
 	}
 	
 	ws.proxies[workletID] = proxy
+	ws.targets[workletID] = targetURL
 	
 	return nil
 }
 
+// ProxyTo points the worklet's proxy at targetURL, creating it if there's
+// none or it forwards elsewhere, such as to a rebuilt container's old port
+func (ws *WebServer) ProxyTo(workletID string, targetURL string) error {
+	ws.mu.RLock()
+	current := ws.targets[workletID]
+	ws.mu.RUnlock()
+	
+	if current == targetURL {
+		return nil
+	}
+	return ws.CreateProxy(workletID, targetURL)
+}
+
 func (ws *WebServer) GetProxy(workletID string) (*httputil.ReverseProxy, bool) {
 	ws.mu.RLock()
 	defer ws.mu.RUnlock()
@@ -61,6 +77,7 @@ func (ws *WebServer) RemoveProxy(workletID string) {
 	defer ws.mu.Unlock()
 	
 	delete(ws.proxies, workletID)
+	delete(ws.targets, workletID)
 }
 
 func (ws *WebServer) ServeWorklet(w http.ResponseWriter, r *http.Request, workletID string) {
@@ -177,6 +194,7 @@ func (ws *WebServer) CleanupInactiveProxies(activeWorkletIDs []string) {
 	for workletID := range ws.proxies {
 		if !activeSet[workletID] {
 			delete(ws.proxies, workletID)
+			delete(ws.targets, workletID)
 			slog.Info("Removed inactive proxy", "workletID", workletID)
 		}
 	}
@@ -0,0 +1,275 @@
+package worklet
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/breadchris/flow/config"
+	"gorm.io/gorm"
+)
+
+// maxWebhookBody is the largest webhook delivery read
+const maxWebhookBody = 5 << 20
+
+// What a webhook delivery did
+const (
+	WebhookCreated = "created"
+	WebhookRebuilt = "rebuilt"
+	WebhookDeleted = "deleted"
+	WebhookIgnored = "ignored"
+)
+
+// WebhookResult is the response to a webhook delivery
+type WebhookResult struct {
+	Action    string `json:"action"`
+	WorkletID string `json:"worklet_id,omitempty"`
+	Reason    string `json:"reason,omitempty"` // Why it was ignored
+}
+
+// githubRepository is the repository in a GitHub webhook payload
+type githubRepository struct {
+	FullName      string `json:"full_name"`
+	CloneURL      string `json:"clone_url"`
+	DefaultBranch string `json:"default_branch"`
+}
+
+// githubPushEvent is the payload of a GitHub push webhook
+type githubPushEvent struct {
+	Ref        string           `json:"ref"` // e.g. refs/heads/main
+	Deleted    bool             `json:"deleted"`
+	Repository githubRepository `json:"repository"`
+}
+
+// githubPullRequestEvent is the payload of a GitHub pull_request webhook
+type githubPullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Title   string `json:"title"`
+		HTMLURL string `json:"html_url"`
+		Head    struct {
+			Ref  string            `json:"ref"`
+			Repo *githubRepository `json:"repo"` // nil when the fork was deleted
+		} `json:"head"`
+	} `json:"pull_request"`
+	Repository githubRepository `json:"repository"`
+}
+
+// GitHubWebhook creates, rebuilds, and deletes worklets for the configured
+// repositories' pull requests and default branches. Deliveries must be
+// signed with the webhook secret.
+func (h *WorkletHandler) GitHubWebhook(w http.ResponseWriter, r *http.Request) {
+	if h.deps == nil || h.deps.Config.Worklet.Webhook.Secret == "" {
+		writeError(w, http.StatusNotFound, "GitHub webhooks aren't configured")
+		return
+	}
+	cfg := h.deps.Config.Worklet.Webhook
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Failed to read webhook: %v", err))
+		return
+	}
+	if !validWebhookSignature(cfg.Secret, body, r.Header.Get("X-Hub-Signature-256")) {
+		writeError(w, http.StatusUnauthorized, "Invalid webhook signature")
+		return
+	}
+
+	// Worklets are deployed after the delivery is answered
+	ctx := context.WithoutCancel(r.Context())
+	var result WebhookResult
+	switch event := r.Header.Get("X-GitHub-Event"); event {
+	case "ping":
+		writeJSON(w, http.StatusOK, map[string]string{"status": "pong"})
+		return
+	case "push":
+		var push githubPushEvent
+		if err := json.Unmarshal(body, &push); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid push event: %v", err))
+			return
+		}
+		result, err = h.manager.handlePush(ctx, cfg, push)
+	case "pull_request":
+		var pr githubPullRequestEvent
+		if err := json.Unmarshal(body, &pr); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid pull request event: %v", err))
+			return
+		}
+		result, err = h.manager.handlePullRequest(ctx, cfg, pr)
+	default:
+		result = WebhookResult{Action: WebhookIgnored, Reason: fmt.Sprintf("%s events aren't handled", event)}
+	}
+	if err != nil {
+		writeManagerError(w, "handle webhook", err)
+		return
+	}
+
+	slog.Info("Handled GitHub webhook",
+		"event", r.Header.Get("X-GitHub-Event"),
+		"delivery", r.Header.Get("X-GitHub-Delivery"),
+		"result", result.Action,
+		"workletID", result.WorkletID,
+		"action", "worklet_webhook",
+	)
+	writeJSON(w, http.StatusOK, result)
+}
+
+// validWebhookSignature reports whether header is the sha256 HMAC of body with secret
+func validWebhookSignature(secret string, body []byte, header string) bool {
+	signature, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// webhookRepo reports whether the repository's events are acted on
+func webhookRepo(cfg config.WorkletWebhookConfig, repo githubRepository) bool {
+	return slices.ContainsFunc(cfg.Repos, func(name string) bool { return strings.EqualFold(name, repo.FullName) })
+}
+
+// trustedHead reports whether a pull request's code may be deployed: it's a
+// branch of the repository itself, or of a fork configured as trusted.
+// Anyone can open a pull request from a fork, so other forks' code isn't run.
+func trustedHead(cfg config.WorkletWebhookConfig, base, head githubRepository) bool {
+	if strings.EqualFold(head.FullName, base.FullName) {
+		return true
+	}
+	return slices.ContainsFunc(cfg.Forks, func(name string) bool { return strings.EqualFold(name, head.FullName) })
+}
+
+// handlePush rebuilds the worklet of a pushed branch, creating one for the
+// default branch, and deletes it when the branch is deleted
+func (m *Manager) handlePush(ctx context.Context, cfg config.WorkletWebhookConfig, push githubPushEvent) (WebhookResult, error) {
+	if !webhookRepo(cfg, push.Repository) {
+		return WebhookResult{Action: WebhookIgnored, Reason: fmt.Sprintf("%s isn't configured for webhooks", push.Repository.FullName)}, nil
+	}
+	branch, ok := strings.CutPrefix(push.Ref, "refs/heads/")
+	if !ok {
+		return WebhookResult{Action: WebhookIgnored, Reason: "not a branch"}, nil
+	}
+
+	key := fmt.Sprintf("branch:%s@%s", push.Repository.FullName, branch)
+	if push.Deleted {
+		return m.deleteWebhookWorklet(key)
+	}
+	if branch != push.Repository.DefaultBranch {
+		// Other branches only have worklets through their pull requests
+		return m.rebuildWebhookWorklet(ctx, key, nil, cfg.UserID)
+	}
+	return m.rebuildWebhookWorklet(ctx, key, &CreateWorkletRequest{
+		Name:        fmt.Sprintf("%s@%s", push.Repository.FullName, branch),
+		Description: fmt.Sprintf("The %s branch of %s, kept up to date by its pushes", branch, push.Repository.FullName),
+		GitRepo:     push.Repository.CloneURL,
+		Branch:      branch,
+	}, cfg.UserID)
+}
+
+// handlePullRequest starts a preview worklet when a pull request is opened,
+// rebuilds it when it's pushed to, and deletes it when it's closed. Pull
+// requests from forks are only deployed from trusted forks.
+func (m *Manager) handlePullRequest(ctx context.Context, cfg config.WorkletWebhookConfig, event githubPullRequestEvent) (WebhookResult, error) {
+	if !webhookRepo(cfg, event.Repository) {
+		return WebhookResult{Action: WebhookIgnored, Reason: fmt.Sprintf("%s isn't configured for webhooks", event.Repository.FullName)}, nil
+	}
+
+	key := fmt.Sprintf("pr:%s#%d", event.Repository.FullName, event.Number)
+	switch event.Action {
+	case "closed":
+		return m.deleteWebhookWorklet(key)
+	case "opened", "reopened", "synchronize":
+	default:
+		return WebhookResult{Action: WebhookIgnored, Reason: fmt.Sprintf("%s pull requests aren't handled", event.Action)}, nil
+	}
+
+	head := event.PullRequest.Head
+	if head.Repo == nil {
+		return WebhookResult{Action: WebhookIgnored, Reason: "the pull request's repository was deleted"}, nil
+	}
+	if !trustedHead(cfg, event.Repository, *head.Repo) {
+		slog.Warn("Not deploying a pull request from an untrusted fork",
+			"repository", event.Repository.FullName,
+			"number", event.Number,
+			"fork", head.Repo.FullName,
+			"action", "worklet_webhook_fork_ignored",
+		)
+		return WebhookResult{Action: WebhookIgnored, Reason: fmt.Sprintf("%s is a fork that isn't trusted for webhooks", head.Repo.FullName)}, nil
+	}
+	return m.rebuildWebhookWorklet(ctx, key, &CreateWorkletRequest{
+		Name:        fmt.Sprintf("PR #%d: %s", event.Number, event.PullRequest.Title),
+		Description: fmt.Sprintf("Preview of %s", event.PullRequest.HTMLURL),
+		GitRepo:     head.Repo.CloneURL,
+		Branch:      head.Ref,
+	}, cfg.UserID)
+}
+
+// rebuildWebhookWorklet rebuilds the worklet with the webhook key from its
+// branch's latest commit. Without one, a worklet is created from req for
+// userID, or nothing is done when req is nil.
+func (m *Manager) rebuildWebhookWorklet(ctx context.Context, key string, req *CreateWorkletRequest, userID string) (WebhookResult, error) {
+	m.webhookMu.Lock()
+	defer m.webhookMu.Unlock()
+
+	worklet, err := m.webhookWorklet(key)
+	switch {
+	case err == nil:
+		if err := m.RestartWorklet(ctx, worklet.ID); err != nil {
+			return WebhookResult{}, err
+		}
+		return WebhookResult{Action: WebhookRebuilt, WorkletID: worklet.ID}, nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return WebhookResult{}, err
+	case req == nil:
+		return WebhookResult{Action: WebhookIgnored, Reason: "no worklet tracks the branch"}, nil
+	}
+
+	worklet = m.newWorklet(*req, userID)
+	worklet.WebhookKey = key
+	if _, err := m.startWorklet(ctx, worklet); err != nil {
+		return WebhookResult{}, err
+	}
+	return WebhookResult{Action: WebhookCreated, WorkletID: worklet.ID}, nil
+}
+
+// deleteWebhookWorklet deletes the worklet with the webhook key, if there is one
+func (m *Manager) deleteWebhookWorklet(key string) (WebhookResult, error) {
+	m.webhookMu.Lock()
+	defer m.webhookMu.Unlock()
+
+	worklet, err := m.webhookWorklet(key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return WebhookResult{Action: WebhookIgnored, Reason: "no worklet tracks it"}, nil
+	}
+	if err != nil {
+		return WebhookResult{}, err
+	}
+	if err := m.DeleteWorklet(worklet.ID); err != nil {
+		return WebhookResult{}, err
+	}
+	return WebhookResult{Action: WebhookDeleted, WorkletID: worklet.ID}, nil
+}
+
+// webhookWorklet returns the worklet a webhook started with the key
+func (m *Manager) webhookWorklet(key string) (*Worklet, error) {
+	var worklet Worklet
+	if err := m.db.Select("id").Where("webhook_key = ?", key).First(&worklet).Error; err != nil {
+		return nil, err
+	}
+	return m.GetWorklet(worklet.ID)
+}
@@ -0,0 +1,197 @@
+package worklet
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/breadchris/flow/config"
+	"github.com/breadchris/flow/deps"
+	"github.com/breadchris/flow/events"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+const testWebhookSecret = "hook-secret"
+
+// newWebhookTestAPI serves the worklet API with webhooks for acme/app and the
+// trusted forks, and records the worklets deployed instead of deploying them
+func newWebhookTestAPI(t *testing.T, forks ...string) (*mux.Router, *gorm.DB, func() []string) {
+	_, db := newTestAPI(t)
+	var mu sync.Mutex
+	var deployed []string
+	manager := &Manager{
+		db:        db,
+		worklets:  make(map[string]*Worklet),
+		webServer: NewWebServer(),
+		events:    events.New(),
+		deploy: func(ctx context.Context, worklet *Worklet) {
+			mu.Lock()
+			defer mu.Unlock()
+			deployed = append(deployed, worklet.Name)
+		},
+	}
+	cfg := config.AppConfig{}
+	cfg.Worklet.Webhook = config.WorkletWebhookConfig{Secret: testWebhookSecret, Repos: []string{"acme/app"}, Forks: forks, UserID: "github"}
+	handler := &WorkletHandler{manager: manager, deps: &deps.Deps{Config: cfg}}
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router.PathPrefix("/api/worklet").Subrouter())
+	return router, db, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), deployed...)
+	}
+}
+
+// deliver sends a webhook signed with secret
+func deliver(t *testing.T, router http.Handler, event, secret, payload string) (*httptest.ResponseRecorder, WebhookResult) {
+	req := httptest.NewRequest("POST", "/api/worklet/hooks/github", strings.NewReader(payload))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	req.Header.Set("X-GitHub-Event", event)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var result WebhookResult
+	if rr.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result), rr.Body.String())
+	}
+	return rr, result
+}
+
+const testRepository = `{"full_name": "acme/app", "clone_url": "https://github.com/acme/app.git", "default_branch": "main"}`
+
+func pullRequestPayload(action string) string {
+	return forkPullRequestPayload(action, "acme/app")
+}
+
+// forkPullRequestPayload is a pull request event for a branch of headRepo
+func forkPullRequestPayload(action, headRepo string) string {
+	return `{"action": "` + action + `", "number": 12, "pull_request": {"title": "Add dark mode", "html_url": "https://github.com/acme/app/pull/12",
+		"head": {"ref": "dark-mode", "repo": {"full_name": "` + headRepo + `", "clone_url": "https://github.com/` + headRepo + `.git"}}},
+		"repository": ` + testRepository + `}`
+}
+
+func TestWebhookSignature(t *testing.T) {
+	router, _, _ := newWebhookTestAPI(t)
+
+	rr, _ := deliver(t, router, "ping", "wrong-secret", `{}`)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr, _ = deliver(t, router, "ping", testWebhookSecret, `{}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	unconfigured, _ := newTestAPI(t)
+	rr, _ = deliver(t, unconfigured, "ping", testWebhookSecret, `{}`)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestPullRequestWebhook(t *testing.T) {
+	router, db, deployed := newWebhookTestAPI(t)
+
+	rr, result := deliver(t, router, "pull_request", testWebhookSecret, pullRequestPayload("opened"))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, WebhookCreated, result.Action)
+	var worklet Worklet
+	require.NoError(t, db.First(&worklet, "id = ?", result.WorkletID).Error)
+	assert.Equal(t, "PR #12: Add dark mode", worklet.Name)
+	assert.Equal(t, "https://github.com/acme/app.git", worklet.GitRepo)
+	assert.Equal(t, "dark-mode", worklet.Branch)
+	assert.Equal(t, "github", worklet.UserID)
+	assert.Equal(t, "pr:acme/app#12", worklet.WebhookKey)
+
+	_, result = deliver(t, router, "pull_request", testWebhookSecret, pullRequestPayload("synchronize"))
+	assert.Equal(t, WebhookRebuilt, result.Action)
+	assert.Equal(t, worklet.ID, result.WorkletID)
+	_, result = deliver(t, router, "pull_request", testWebhookSecret, pullRequestPayload("labeled"))
+	assert.Equal(t, WebhookIgnored, result.Action)
+	assert.Eventually(t, func() bool { return len(deployed()) == 2 }, time.Second, 10*time.Millisecond)
+
+	_, result = deliver(t, router, "pull_request", testWebhookSecret, pullRequestPayload("closed"))
+	assert.Equal(t, WebhookDeleted, result.Action)
+	assert.ErrorIs(t, db.First(&Worklet{}, "id = ?", worklet.ID).Error, gorm.ErrRecordNotFound)
+	_, result = deliver(t, router, "pull_request", testWebhookSecret, pullRequestPayload("closed"))
+	assert.Equal(t, WebhookIgnored, result.Action)
+}
+
+func TestPullRequestWebhookFromFork(t *testing.T) {
+	router, db, deployed := newWebhookTestAPI(t, "friend/app")
+
+	for _, action := range []string{"opened", "reopened", "synchronize"} {
+		rr, result := deliver(t, router, "pull_request", testWebhookSecret, forkPullRequestPayload(action, "stranger/app"))
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, WebhookIgnored, result.Action, "untrusted forks' code isn't run")
+		assert.Contains(t, result.Reason, "stranger/app")
+	}
+	var count int64
+	require.NoError(t, db.Model(&Worklet{}).Where("webhook_key <> ''").Count(&count).Error)
+	assert.Zero(t, count)
+
+	_, result := deliver(t, router, "pull_request", testWebhookSecret, forkPullRequestPayload("opened", "Friend/App"))
+	require.Equal(t, WebhookCreated, result.Action, "trusted forks are deployed")
+	var worklet Worklet
+	require.NoError(t, db.First(&worklet, "id = ?", result.WorkletID).Error)
+	assert.Equal(t, "https://github.com/Friend/App.git", worklet.GitRepo, "the fork's own repository is built")
+	assert.Eventually(t, func() bool { return len(deployed()) == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestPushWebhook(t *testing.T) {
+	router, db, _ := newWebhookTestAPI(t)
+	push := func(ref string) string {
+		return `{"ref": "` + ref + `", "repository": ` + testRepository + `}`
+	}
+
+	_, result := deliver(t, router, "push", testWebhookSecret, push("refs/heads/feature"))
+	assert.Equal(t, WebhookIgnored, result.Action, "only the default branch gets a worklet from pushes")
+	_, result = deliver(t, router, "push", testWebhookSecret, push("refs/tags/v1"))
+	assert.Equal(t, WebhookIgnored, result.Action)
+
+	_, result = deliver(t, router, "push", testWebhookSecret, push("refs/heads/main"))
+	require.Equal(t, WebhookCreated, result.Action)
+	var worklet Worklet
+	require.NoError(t, db.First(&worklet, "id = ?", result.WorkletID).Error)
+	assert.Equal(t, "acme/app@main", worklet.Name)
+	assert.Equal(t, "https://github.com/acme/app.git", worklet.GitRepo)
+
+	_, result = deliver(t, router, "push", testWebhookSecret, push("refs/heads/main"))
+	assert.Equal(t, WebhookRebuilt, result.Action)
+	assert.Equal(t, worklet.ID, result.WorkletID)
+
+	other := strings.Replace(push("refs/heads/main"), "acme/app", "acme/other", 1)
+	_, result = deliver(t, router, "push", testWebhookSecret, other)
+	assert.Equal(t, WebhookIgnored, result.Action, "acme/other isn't configured")
+}
+
+func TestRestartCancelsRunningDeploy(t *testing.T) {
+	_, db := newTestAPI(t)
+	deploys := make(chan context.Context, 2)
+	manager := &Manager{
+		db:        db,
+		worklets:  make(map[string]*Worklet),
+		webServer: NewWebServer(),
+		deploy: func(ctx context.Context, worklet *Worklet) {
+			deploys <- ctx
+			<-ctx.Done()
+		},
+	}
+
+	require.NoError(t, manager.RestartWorklet(context.Background(), "w1"))
+	first := <-deploys
+	require.NoError(t, manager.RestartWorklet(context.Background(), "w1"))
+	assert.Error(t, first.Err(), "the first deploy is cancelled before the second starts")
+	second := <-deploys
+	assert.NoError(t, second.Err())
+
+	manager.cancelDeploy("w1")
+	_, running := manager.deploys.Load("w1")
+	assert.False(t, running)
+}
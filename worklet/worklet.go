@@ -1,9 +1,11 @@
 package worklet
 
 import (
+	"maps"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/breadchris/flow/claude"
 	"github.com/breadchris/flow/models"
 )
 
@@ -16,6 +18,8 @@ const (
 	StatusError     Status = "error"
 	StatusBuilding  Status = "building"
 	StatusDeploying Status = "deploying"
+	StatusUnhealthy Status = "unhealthy" // Running but failing its health check
+	StatusSleeping  Status = "sleeping"  // Stopped for being idle; its next request starts it again
 )
 
 type Worklet struct {
@@ -25,6 +29,10 @@ type Worklet struct {
 	Status      Status                        `json:"status" gorm:"not null"`
 	GitRepo     string                        `json:"git_repo" gorm:"not null"`
 	Branch      string                        `json:"branch" gorm:"not null"`
+	// Optional tag or commit SHA the worklet is pinned to instead of the branch's latest commit
+	Ref         string `json:"ref,omitempty"`
+	CloneDepth  int    `json:"clone_depth"` // Commits of history cloned; 0 for all of it
+	CommitSHA   string `json:"commit_sha"`  // Commit the worklet was last deployed from
 	BasePrompt  string                        `json:"base_prompt" gorm:"type:text"`
 	WebURL      string                        `json:"web_url"`
 	Port        int                           `json:"port"`
@@ -35,6 +43,42 @@ type Worklet struct {
 	LastPrompt  string                        `json:"last_prompt" gorm:"type:text"`
 	LastError   string                        `json:"last_error" gorm:"type:text"`
 	BuildLogs   string                        `json:"build_logs" gorm:"type:text"`
+	BuildHash   string `json:"build_hash"` // Hash of the files and build strategy the running image was built from
+	// How the image is built; empty picks a strategy from the repository on each build
+	BuildStrategy BuildStrategy `json:"build_strategy"`
+	// How the running worklet is checked; nil means the default HTTP check
+	HealthCheck *models.JSONField[HealthCheck] `json:"health_check,omitempty"`
+	// Who may open the worklet's preview URL, and the secrets they need
+	PreviewAccess       PreviewAccess `json:"preview_access"`
+	PreviewToken        string        `json:"-"`
+	PreviewUsername     string        `json:"preview_username,omitempty"`
+	PreviewPasswordHash string        `json:"-"`
+	// The pull request opened with the worklet's changes, if any, and where it is in review
+	PRURL    string      `json:"pr_url,omitempty"`
+	PRNumber int         `json:"pr_number,omitempty"`
+	PRState  PRState     `json:"pr_state,omitempty" gorm:"index"`
+	PRChecks CheckStatus `json:"pr_checks,omitempty"`
+	// Whether the worklet's tests run in its container after each prompt, the
+	// command that runs them (detected from the repository when empty), and
+	// the last run's result. Failing tests block pull requests.
+	RunTests    bool       `json:"run_tests"`
+	TestCommand string     `json:"test_command,omitempty"`
+	TestStatus  TestStatus `json:"test_status,omitempty"`
+	TestOutput  string     `json:"test_output,omitempty" gorm:"type:text"`
+	TestedAt    *time.Time `json:"tested_at,omitempty"`
+	// When the worklet's preview last had a request, to the minute; it's put
+	// to sleep once it's been idle long enough
+	LastActiveAt *time.Time `json:"last_active_at,omitempty"`
+	// Tool permissions for Claude sessions on the worklet; empty means the defaults
+	AllowedTools    *models.JSONField[[]string] `json:"allowed_tools,omitempty"`
+	DisallowedTools *models.JSONField[[]string] `json:"disallowed_tools,omitempty"`
+	// Paths or globs of build artifacts uploaded after each successful
+	// prompt, and the uploads from the latest one
+	Artifacts          *models.JSONField[[]string]            `json:"artifacts,omitempty"`
+	PublishedArtifacts *models.JSONField[[]PublishedArtifact] `json:"published_artifacts,omitempty"`
+	// The pull request or branch a GitHub webhook started the worklet for,
+	// e.g. "pr:owner/name#12" or "branch:owner/name@main"
+	WebhookKey string `json:"webhook_key,omitempty" gorm:"index"`
 	User        *models.User                  `gorm:"foreignKey:UserID"`
 	Container   *models.Container             `gorm:"foreignKey:ContainerID"`
 }
@@ -46,6 +90,13 @@ type WorkletPrompt struct {
 	Response  string `json:"response" gorm:"type:text"`
 	Status    string `json:"status" gorm:"not null"`
 	UserID    string `json:"user_id" gorm:"index;not null"`
+	// What the prompt changed: the trees of the worklet's files before and
+	// after it, which its diff is between, and the totals of that diff
+	BaseTree     string `json:"-"`
+	Tree         string `json:"-"`
+	FilesChanged int    `json:"files_changed"`
+	Additions    int    `json:"additions"`
+	Deletions    int    `json:"deletions"`
 	Worklet   *Worklet `gorm:"foreignKey:WorkletID"`
 	User      *models.User `gorm:"foreignKey:UserID"`
 }
@@ -54,9 +105,50 @@ type CreateWorkletRequest struct {
 	Name        string            `json:"name" binding:"required"`
 	Description string            `json:"description"`
 	GitRepo     string            `json:"git_repo" binding:"required"`
-	Branch      string            `json:"branch"`
+	Branch      string            `json:"branch"` // The repository's default branch when empty
+	// Optional tag or commit SHA to deploy instead of the branch's latest commit
+	Ref         string            `json:"ref"`
+	// Optional commits of history to clone; the server's default when unset, 0 for all of it
+	CloneDepth  *int              `json:"clone_depth"`
 	BasePrompt  string            `json:"base_prompt"`
 	Environment map[string]string `json:"environment"`
+	// Optional build strategy such as "dockerfile", "nixpacks", or "static"; detected from the repository when empty
+	BuildStrategy BuildStrategy `json:"build_strategy"`
+	// Optional health check; an HTTP check of / every 30 seconds when unset
+	HealthCheck *HealthCheck `json:"health_check"`
+	// Optional protection for the preview URL; the server's default access when unset
+	Preview *PreviewSettings `json:"preview"`
+	// Optional Claude tool permissions, e.g. ["Read", "Grep"] for a worklet that must not edit code
+	AllowedTools    []string `json:"allowed_tools"`
+	DisallowedTools []string `json:"disallowed_tools"`
+	// Optional test run after each prompt; setting test_command turns it on.
+	// The command runs with sh in the container, and is detected from
+	// package.json, a Makefile, or go.mod when empty.
+	RunTests    bool   `json:"run_tests"`
+	TestCommand string `json:"test_command"`
+	// Optional build artifacts to publish after each prompt, as paths or globs
+	// relative to the container's working directory, e.g. ["dist/*.zip"]
+	Artifacts []string `json:"artifacts"`
+}
+
+// UpdateWorkletRequest changes the fields it sets; an empty environment clears it
+type UpdateWorkletRequest struct {
+	Name        *string            `json:"name"`
+	Description *string            `json:"description"`
+	BasePrompt  *string            `json:"base_prompt"`
+	Environment *map[string]string `json:"environment"`
+	HealthCheck *HealthCheck       `json:"health_check"`
+	Preview     *PreviewSettings   `json:"preview"`
+	RunTests    *bool              `json:"run_tests"`
+	TestCommand *string            `json:"test_command"`
+	Artifacts   *[]string          `json:"artifacts"`
+}
+
+// CloneWorkletRequest changes what a clone takes from the original worklet
+type CloneWorkletRequest struct {
+	Name        string            `json:"name"` // The original's name with " (copy)" when empty
+	BasePrompt  *string           `json:"base_prompt"`
+	Environment map[string]string `json:"environment"` // Added to the original's environment
 }
 
 type PromptRequest struct {
@@ -70,9 +162,29 @@ type WorkletResponse struct {
 	Status      Status            `json:"status"`
 	GitRepo     string            `json:"git_repo"`
 	Branch      string            `json:"branch"`
+	Ref         string            `json:"ref,omitempty"`
+	CommitSHA   string            `json:"commit_sha,omitempty"`
+	BasePrompt  string            `json:"base_prompt"`
 	WebURL      string            `json:"web_url"`
 	Port        int               `json:"port"`
 	Environment map[string]string `json:"environment"`
+	BuildStrategy BuildStrategy   `json:"build_strategy,omitempty"`
+	HealthCheck   HealthCheck     `json:"health_check"`
+	PreviewAccess PreviewAccess   `json:"preview_access,omitempty"`
+	PreviewURL    string          `json:"preview_url,omitempty"`   // Set when previews are served on subdomains
+	PreviewToken  string          `json:"preview_token,omitempty"` // Only for token access
+	PRURL         string          `json:"pr_url,omitempty"`
+	PRNumber      int             `json:"pr_number,omitempty"`
+	PRState       PRState         `json:"pr_state,omitempty"`
+	PRChecks      CheckStatus     `json:"pr_checks,omitempty"`
+	RunTests      bool            `json:"run_tests"`
+	TestCommand   string          `json:"test_command,omitempty"`
+	TestStatus    TestStatus      `json:"test_status,omitempty"`
+	TestOutput    string          `json:"test_output,omitempty"`
+	TestedAt      *time.Time      `json:"tested_at,omitempty"`
+	LastActiveAt  *time.Time      `json:"last_active_at,omitempty"`
+	Artifacts     []string        `json:"artifacts,omitempty"`
+	PublishedArtifacts []PublishedArtifact `json:"published_artifacts,omitempty"`
 	CreatedAt   time.Time         `json:"created_at"`
 	UpdatedAt   time.Time         `json:"updated_at"`
 	LastPrompt  string            `json:"last_prompt"`
@@ -84,6 +196,14 @@ func (w *Worklet) ToResponse() WorkletResponse {
 	if w.Environment != nil {
 		env = w.Environment.Data
 	}
+	var artifacts []string
+	if w.Artifacts != nil {
+		artifacts = w.Artifacts.Data
+	}
+	var published []PublishedArtifact
+	if w.PublishedArtifacts != nil {
+		published = w.PublishedArtifacts.Data
+	}
 	
 	return WorkletResponse{
 		ID:          w.ID,
@@ -92,9 +212,27 @@ func (w *Worklet) ToResponse() WorkletResponse {
 		Status:      w.Status,
 		GitRepo:     w.GitRepo,
 		Branch:      w.Branch,
+		Ref:         w.Ref,
+		CommitSHA:   w.CommitSHA,
+		BasePrompt:  w.BasePrompt,
 		WebURL:      w.WebURL,
 		Port:        w.Port,
 		Environment: env,
+		BuildStrategy: w.BuildStrategy,
+		HealthCheck:   w.healthCheck(),
+		PreviewAccess: w.PreviewAccess,
+		PRURL:         w.PRURL,
+		PRNumber:      w.PRNumber,
+		PRState:       w.PRState,
+		PRChecks:      w.PRChecks,
+		RunTests:      w.RunTests,
+		TestCommand:   w.TestCommand,
+		TestStatus:    w.TestStatus,
+		TestOutput:    w.TestOutput,
+		TestedAt:      w.TestedAt,
+		LastActiveAt:  w.LastActiveAt,
+		Artifacts:     artifacts,
+		PublishedArtifacts: published,
 		CreatedAt:   w.CreatedAt,
 		UpdatedAt:   w.UpdatedAt,
 		LastPrompt:  w.LastPrompt,
@@ -102,22 +240,116 @@ func (w *Worklet) ToResponse() WorkletResponse {
 	}
 }
 
+// checkoutSpec returns the revision of the worklet's repository it runs, in
+// a checkout of its own
+func (w *Worklet) checkoutSpec() CheckoutSpec {
+	return CheckoutSpec{RepoURL: w.GitRepo, Branch: w.Branch, Ref: w.Ref, Depth: w.CloneDepth, WorkletID: w.ID}
+}
+
 func NewWorklet(req CreateWorkletRequest, userID string) *Worklet {
-	branch := req.Branch
-	if branch == "" {
-		branch = "main"
+	var cloneDepth int
+	if req.CloneDepth != nil {
+		cloneDepth = *req.CloneDepth
+	}
+	var healthCheck *models.JSONField[HealthCheck]
+	if req.HealthCheck != nil {
+		healthCheck = models.MakeJSONField(*req.HealthCheck)
 	}
 	
-	return &Worklet{
+	worklet := &Worklet{
 		Model:       models.Model{ID: uuid.New().String()},
 		Name:        req.Name,
 		Description: req.Description,
 		Status:      StatusCreating,
 		GitRepo:     req.GitRepo,
-		Branch:      branch,
+		Branch:      req.Branch,
+		Ref:         req.Ref,
+		CloneDepth:  cloneDepth,
 		BasePrompt:  req.BasePrompt,
 		Environment: models.MakeJSONField(req.Environment),
+		BuildStrategy: req.BuildStrategy,
+		HealthCheck: healthCheck,
 		UserID:      userID,
 		SessionID:   uuid.New().String(),
+
+		AllowedTools:    models.MakeJSONField(req.AllowedTools),
+		DisallowedTools: models.MakeJSONField(req.DisallowedTools),
+
+		RunTests:    req.RunTests || req.TestCommand != "",
+		TestCommand: req.TestCommand,
+
+		Artifacts: models.MakeJSONField(req.Artifacts),
+	}
+	if req.Preview != nil {
+		req.Preview.apply(worklet)
+	}
+	return worklet
+}
+
+// newClone returns a new worklet for userID with the source's settings
+func newClone(source *Worklet, req CloneWorkletRequest, userID string) *Worklet {
+	env := make(map[string]string)
+	if source.Environment != nil {
+		maps.Copy(env, source.Environment.Data)
+	}
+	maps.Copy(env, req.Environment)
+	name := req.Name
+	if name == "" {
+		name = source.Name + " (copy)"
+	}
+	basePrompt := source.BasePrompt
+	if req.BasePrompt != nil {
+		basePrompt = *req.BasePrompt
+	}
+
+	return &Worklet{
+		Model:         models.Model{ID: uuid.New().String()},
+		Name:          name,
+		Description:   source.Description,
+		Status:        StatusCreating,
+		GitRepo:       source.GitRepo,
+		Branch:        source.Branch,
+		Ref:           source.Ref,
+		CloneDepth:    source.CloneDepth,
+		BasePrompt:    basePrompt,
+		Environment:   models.MakeJSONField(env),
+		BuildStrategy: source.BuildStrategy,
+		HealthCheck:   copyField(source.HealthCheck),
+		UserID:        userID,
+		SessionID:     uuid.New().String(),
+
+		// A clone gets its own preview token, but basic access keeps the password
+		PreviewAccess:       source.PreviewAccess,
+		PreviewToken:        newPreviewToken(),
+		PreviewUsername:     source.PreviewUsername,
+		PreviewPasswordHash: source.PreviewPasswordHash,
+
+		AllowedTools:    copyField(source.AllowedTools),
+		DisallowedTools: copyField(source.DisallowedTools),
+
+		RunTests:    source.RunTests,
+		TestCommand: source.TestCommand,
+
+		Artifacts: copyField(source.Artifacts),
+	}
+}
+
+// copyField returns a field of its own holding the same data
+func copyField[T any](field *models.JSONField[T]) *models.JSONField[T] {
+	if field == nil {
+		return nil
+	}
+	return models.MakeJSONField(field.Data)
+}
+
+// SessionOptions returns the options for Claude sessions working on the worklet
+func (w *Worklet) SessionOptions() []claude.SessionOption {
+	var opts []claude.SessionOption
+	if w.AllowedTools != nil && len(w.AllowedTools.Data) > 0 {
+		opts = append(opts, claude.WithAllowedTools(w.AllowedTools.Data...))
+	}
+	if w.DisallowedTools != nil && len(w.DisallowedTools.Data) > 0 {
+		opts = append(opts, claude.WithDisallowedTools(w.DisallowedTools.Data...))
 	}
+	return opts
 }
\ No newline at end of file
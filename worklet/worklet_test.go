@@ -62,7 +62,7 @@ func NewTestHelper(t *testing.T) *TestHelper {
 
 	// Create manager and handler
 	manager := NewManager(&dependencies)
-	handler := NewWorkletHandler(&dependencies)
+	handler := NewWorkletHandler(&dependencies, manager)
 
 	// Create test user
 	testUser := uuid.New().String()